@@ -2,13 +2,26 @@ package main
 
 import (
 	"context"
+	"cruder/internal/auth"
 	"cruder/internal/config"
 	"cruder/internal/controller"
+	"cruder/internal/events"
 	"cruder/internal/handler"
+	"cruder/internal/jobs"
+	"cruder/internal/mail"
 	"cruder/internal/middleware"
+	"cruder/internal/migration"
+	"cruder/internal/openapi"
 	"cruder/internal/repository"
+	"cruder/internal/rpc"
+	"cruder/internal/scheduler"
 	"cruder/internal/service"
+	"cruder/internal/token"
+	"encoding/json"
+	stdErrors "errors"
+	"fmt"
 	"log/slog"
+	"net"
 	"net/http"
 	"os"
 	"os/signal"
@@ -18,7 +31,58 @@ import (
 	"github.com/gin-gonic/gin"
 )
 
+const usage = `Usage: cruder [command]
+
+Commands:
+  serve    start the HTTP server (default)
+  migrate  manage database schema migrations
+  seed     load fixture or fake users
+  users    look up, create, update, delete or import users
+  openapi  print the OpenAPI 3.1 document to stdout
+`
+
+// usageError signals that the command line was malformed; the text is printed as-is.
+type usageError string
+
+func (e usageError) Error() string { return string(e) }
+
 func main() {
+	command := "serve"
+	if len(os.Args) > 1 {
+		command = os.Args[1]
+	}
+
+	var err error
+	switch command {
+	case "serve":
+		runServer()
+		return
+	case "migrate":
+		err = runMigrate(os.Args[2:])
+	case "seed":
+		err = runSeed(os.Args[2:])
+	case "users":
+		err = runUsers(os.Args[2:])
+	case "openapi":
+		enc := json.NewEncoder(os.Stdout)
+		enc.SetIndent("", "  ")
+		err = enc.Encode(openapi.Spec())
+	default:
+		err = usageError(usage)
+	}
+
+	var ue usageError
+	if stdErrors.As(err, &ue) {
+		fmt.Fprint(os.Stderr, ue)
+		os.Exit(2)
+	}
+	if err != nil {
+		fmt.Fprintln(os.Stderr, "Error:", err)
+		os.Exit(1)
+	}
+}
+
+func runServer() {
 	// Creating structured JSON logger early for consistent logging
 	logger := middleware.NewStructuredLogger()
 
@@ -39,16 +103,209 @@ func main() {
 		os.Exit(1)
 	}
 
+	migrator, err := migration.NewMigrator(dbConn.DB())
+	if err != nil {
+		logger.Error("Failed to load migrations",
+			slog.String("error", err.Error()))
+		os.Exit(1)
+	}
+
+	if cfg.Migration.OnStart {
+		// Every replica may start at once; the advisory lock lets only one migrate while the others wait
+		err := dbConn.WithAdvisoryLock(context.Background(), migration.LockKey, func() error {
+			results, err := migrator.Up(context.Background())
+			for _, r := range results {
+				logger.Info("Applied migration",
+					slog.Int64("version", r.Source.Version),
+					slog.Duration("duration", r.Duration))
+			}
+			return err
+		})
+		if err != nil {
+			logger.Error("Failed to apply migrations",
+				slog.String("error", err.Error()))
+			os.Exit(1)
+		}
+	}
+
+	// Verification, email change and invitation emails go out through the configured transport
+	mailer, err := mail.New(cfg.MailerConfig())
+	if err != nil {
+		logger.Error("Failed to configure mail transport",
+			slog.String("error", err.Error()))
+		os.Exit(1)
+	}
+	if cfg.Mail.Transport != "smtp" {
+		logger.Warn("MAIL_TRANSPORT is not smtp; verification emails are not delivered",
+			slog.String("transport", cfg.Mail.Transport))
+	}
+
+	// User changes go out via Postgres NOTIFY so WatchUsers streams on every replica see them
 	repositories := repository.NewRepository(dbConn.DB())
-	services := service.NewService(repositories)
-	controllers := controller.NewController(services, dbConn)
+	services := newServices(cfg, repositories, events.NewPostgresNotifier(dbConn.DB()), mailer, logger)
+
+	broker := events.NewBroker()
+	listenCtx, stopListening := context.WithCancel(context.Background())
+	defer stopListening()
+	go func() {
+		if err := events.ListenPostgres(listenCtx, dsn, broker, logger); err != nil {
+			logger.Error("User event listener stopped",
+				slog.String("error", err.Error()))
+		}
+	}()
+
+	// API keys are exchanged for short-lived access tokens signed with a key every replica shares
+	var keys *token.KeySet
+	if cfg.Auth.SigningKeysDir != "" {
+		keys, err = token.LoadKeySet(cfg.Auth.SigningKeysDir, cfg.Auth.SigningKeyID)
+	} else {
+		logger.Warn("TOKEN_SIGNING_KEYS_DIR is not set; access tokens are signed with a temporary key and only accepted by this process")
+		keys, err = token.GenerateKeySet()
+	}
+	if err != nil {
+		logger.Error("Failed to load token signing keys",
+			slog.String("error", err.Error()))
+		os.Exit(1)
+	}
+	services.Tokens = service.NewTokenService(repositories.TokenRevocations, keys, service.TokenOptions{
+		Issuer: cfg.Auth.TokenIssuer,
+		TTL:    cfg.Auth.TokenTTL,
+	})
+	clients, err := auth.LoadClients(cfg.Auth.APIKey, cfg.Auth.ClientsFile)
+	if err != nil {
+		logger.Error("Failed to load API clients",
+			slog.String("error", err.Error()))
+		os.Exit(1)
+	}
+	// Clients that keep presenting wrong API keys are slowed down and then banned, on every replica
+	services.AuthFailures = service.NewAuthFailureService(repositories.AuthFailures, service.AuthFailureOptions{
+		Window:      cfg.Auth.FailureWindow,
+		DelayAfter:  cfg.Auth.DelayAfter,
+		DelayBase:   cfg.Auth.DelayBase,
+		DelayMax:    cfg.Auth.DelayMax,
+		BanAfter:    cfg.Auth.BanAfter,
+		BanDuration: cfg.Auth.BanDuration,
+	}, logger)
+	// Each client works on the users of one organization; a typo there would lock it out at runtime
+	for _, c := range clients {
+		if c.Organization == "" {
+			continue
+		}
+		if _, err := services.Organizations.Resolve(c.Organization); err != nil {
+			logger.Error("Failed to resolve organization of API client",
+				slog.String("client", c.ID),
+				slog.String("organization", c.Organization),
+				slog.String("error", err.Error()))
+			os.Exit(1)
+		}
+	}
+	authenticator := auth.NewAuthenticator(clients, services.Tokens, services.AuthFailures, services.Organizations)
+	// The OpenID Connect provider signs ID tokens with the same keys, published at the same JWKS
+	services.OAuth = service.NewOAuthService(repositories.OAuth, services.Users, services.Auth, keys, service.OAuthOptions{
+		Issuer:   cfg.OIDC.Issuer,
+		CodeTTL:  cfg.OIDC.CodeTTL,
+		TokenTTL: cfg.OIDC.TokenTTL,
+	})
+	logger.Info("Loaded token signing keys",
+		slog.String("active_kid", keys.ActiveKeyID()),
+		slog.Int("api_clients", len(clients)))
+
+	// Exports and imports too large for one request run as jobs on whichever replica claims them
+	jobsCtx, stopJobs := context.WithCancel(context.Background())
+	defer stopJobs()
+	jobsDone := make(chan struct{})
+	if cfg.Jobs.Workers > 0 {
+		worker := jobs.NewWorker(repositories.Jobs, jobs.Config{Concurrency: cfg.Jobs.Workers}, logger)
+		jobs.RegisterUserHandlers(worker, services.Users)
+		go func() {
+			defer close(jobsDone)
+			worker.Run(jobsCtx)
+		}()
+	} else {
+		close(jobsDone)
+	}
+
+	// Periodic maintenance. Every replica runs the scheduler; each slot runs on only one of them
+	sched := scheduler.New(dbConn, repositories.TaskRuns, logger)
+	tasks := []struct {
+		name, spec string
+		fn         scheduler.Func
+	}{
+		{"jobs.purge", "17 * * * *", func(context.Context) error {
+			purged, err := services.Jobs.PurgeFinished(cfg.Scheduler.JobRetention)
+			if err == nil && purged > 0 {
+				logger.Info("Purged finished jobs", slog.Int64("count", purged))
+			}
+			return err
+		}},
+		{"email_verifications.prune", "@hourly", func(context.Context) error {
+			_, err := services.Verification.PruneExpired()
+			return err
+		}},
+		{"email_changes.prune", "@hourly", func(context.Context) error {
+			_, err := services.EmailChanges.PruneExpired()
+			return err
+		}},
+		{"auth_failures.prune", "@hourly", func(context.Context) error {
+			_, err := services.AuthFailures.PruneExpired()
+			return err
+		}},
+		{"oauth_codes.prune", "@hourly", func(context.Context) error {
+			_, err := services.OAuth.PruneExpiredCodes()
+			return err
+		}},
+		{"task_runs.prune", "@daily", func(context.Context) error {
+			_, err := services.TaskRuns.Prune(cfg.Scheduler.RunRetention)
+			return err
+		}},
+	}
+	for _, t := range tasks {
+		if err := sched.Register(t.name, t.spec, t.fn); err != nil {
+			logger.Error("Failed to register scheduled task",
+				slog.String("error", err.Error()))
+			os.Exit(1)
+		}
+	}
+	schedCtx, stopSched := context.WithCancel(context.Background())
+	defer stopSched()
+	schedDone := make(chan struct{})
+	if cfg.Scheduler.Enabled {
+		go func() {
+			defer close(schedDone)
+			sched.Run(schedCtx)
+		}()
+	} else {
+		close(schedDone)
+	}
+
+	controllers := controller.NewController(services, dbConn, migrator)
 
 	r := gin.New()
+	// Failed API keys are counted per client IP, so X-Forwarded-For is only believed from known proxies
+	if err := r.SetTrustedProxies(cfg.Server.TrustedProxies); err != nil {
+		logger.Error("Invalid TRUSTED_PROXIES",
+			slog.String("error", err.Error()))
+		os.Exit(1)
+	}
 	r.Use(gin.Recovery())
 	r.Use(middleware.RequestLogger(logger))
-	r.Use(middleware.APIKeyAuth())
+	r.Use(middleware.Authenticate(authenticator))
 
-	handler.New(r, controllers.Users, controllers.Health)
+	handler.New(r, handler.Controllers{
+		Organizations: controllers.Organizations,
+		Users:         controllers.Users,
+		Groups:        controllers.Groups,
+		Invitations:   controllers.Invitations,
+		Jobs:          controllers.Jobs,
+		Scheduler:     controllers.Scheduler,
+		Auth:          controllers.Auth,
+		Verification:  controllers.Verification,
+		Health:        controllers.Health,
+		Docs:          controllers.Docs,
+		GraphQL:       controllers.GraphQL,
+		OAuth:         controllers.OAuth,
+		SCIM:          controllers.SCIM,
+	})
 
 	addr := ":" + cfg.Server.Port
 	logger.Info("Starting server",
@@ -73,6 +330,23 @@ func main() {
 		}
 	}()
 
+	grpcAddr := ":" + cfg.Server.GRPCPort
+	grpcServer := rpc.NewServer(services.Users, services.EmailChanges, broker, authenticator, logger)
+	go func() {
+		lis, err := net.Listen("tcp", grpcAddr)
+		if err == nil {
+			logger.Info("Starting gRPC server",
+				slog.String("address", grpcAddr))
+			err = grpcServer.Serve(lis)
+		}
+		if err != nil {
+			logger.Error("Failed to start gRPC server",
+				slog.String("error", err.Error()),
+				slog.String("address", grpcAddr))
+			os.Exit(1)
+		}
+	}()
+
 	// Graceful shutdown: Wait for interrupt signal
 	quit := make(chan os.Signal, 1)
 	signal.Notify(quit, syscall.SIGINT, syscall.SIGTERM)
@@ -84,6 +358,11 @@ func main() {
 	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
 	defer cancel()
 
+	// End watch streams first, otherwise they keep the gRPC graceful stop waiting
+	stopListening()
+	broker.Close()
+	grpcServer.Shutdown(ctx)
+
 	// Shutdown server
 	if err := srv.Shutdown(ctx); err != nil {
 		logger.Error("Server forced to shutdown",
@@ -91,6 +370,12 @@ func main() {
 		os.Exit(1)
 	}
 
+	// Running jobs go back to the queue for another replica; running maintenance tasks are interrupted
+	stopJobs()
+	stopSched()
+	<-jobsDone
+	<-schedDone
+
 	// Close database connection
 	if err := dbConn.Close(); err != nil {
 		logger.Error("Error closing database connection",
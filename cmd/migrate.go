@@ -0,0 +1,106 @@
+package main
+
+import (
+	"context"
+	"cruder/internal/config"
+	"cruder/internal/migration"
+	"cruder/internal/repository"
+	"fmt"
+	"os"
+	"text/tabwriter"
+	"time"
+
+	"github.com/pressly/goose/v3"
+)
+
+const migrateUsage = `Usage: cruder migrate <command>
+
+Commands:
+  up      apply all pending migrations
+  down    roll back the most recently applied migration
+  status  list migrations and whether they are applied
+  redo    roll back the most recent migration and apply it again
+  reset   roll back every migration, dropping all tables and their data
+`
+
+// runMigrate applies the migrations embedded in the binary.
+// Mutating commands hold the same advisory lock as MIGRATE_ON_START,
+// so running them by hand never races with a starting replica.
+func runMigrate(args []string) error {
+	if len(args) != 1 {
+		return usageError(migrateUsage)
+	}
+
+	cfg, err := config.LoadFromEnv()
+	if err != nil {
+		return err
+	}
+
+	dbConn, err := repository.NewPostgresConnection(cfg.BuildDSN())
+	if err != nil {
+		return err
+	}
+	defer func() { _ = dbConn.Close() }()
+
+	migrator, err := migration.NewMigrator(dbConn.DB())
+	if err != nil {
+		return err
+	}
+
+	ctx := context.Background()
+
+	switch args[0] {
+	case "up":
+		return dbConn.WithAdvisoryLock(ctx, migration.LockKey, func() error {
+			results, err := migrator.Up(ctx)
+			printMigrationResults(results)
+			if err == nil && len(results) == 0 {
+				fmt.Println("no pending migrations")
+			}
+			return err
+		})
+	case "down":
+		return dbConn.WithAdvisoryLock(ctx, migration.LockKey, func() error {
+			result, err := migrator.Down(ctx)
+			if result != nil {
+				printMigrationResults([]*goose.MigrationResult{result})
+			}
+			return err
+		})
+	case "redo":
+		return dbConn.WithAdvisoryLock(ctx, migration.LockKey, func() error {
+			results, err := migrator.Redo(ctx)
+			printMigrationResults(results)
+			return err
+		})
+	case "reset":
+		return dbConn.WithAdvisoryLock(ctx, migration.LockKey, func() error {
+			results, err := migrator.Reset(ctx)
+			printMigrationResults(results)
+			return err
+		})
+	case "status":
+		statuses, err := migrator.Status(ctx)
+		if err != nil {
+			return err
+		}
+		w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+		_, _ = fmt.Fprintln(w, "VERSION\tSTATE\tAPPLIED AT\tFILE")
+		for _, s := range statuses {
+			appliedAt := "-"
+			if !s.AppliedAt.IsZero() {
+				appliedAt = s.AppliedAt.UTC().Format(time.RFC3339)
+			}
+			_, _ = fmt.Fprintf(w, "%d\t%s\t%s\t%s\n", s.Source.Version, s.State, appliedAt, s.Source.Path)
+		}
+		return w.Flush()
+	default:
+		return usageError(migrateUsage)
+	}
+}
+
+func printMigrationResults(results []*goose.MigrationResult) {
+	for _, r := range results {
+		fmt.Println(r.String())
+	}
+}
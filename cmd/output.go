@@ -0,0 +1,107 @@
+package main
+
+import (
+	"cruder/internal/importer"
+	"cruder/internal/model"
+	"encoding/csv"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+	"text/tabwriter"
+	"time"
+)
+
+// Output formats supported by the admin subcommands.
+const (
+	formatTable = "table"
+	formatJSON  = "json"
+	formatCSV   = "csv"
+)
+
+var userColumns = []string{"id", "username", "email", "full_name", "created_at", "updated_at", "status"}
+
+func userRow(u model.User) []string {
+	return []string{
+		u.ID.String(),
+		u.Username,
+		u.Email,
+		u.FullName,
+		u.CreatedAt.UTC().Format(time.RFC3339),
+		u.UpdatedAt.UTC().Format(time.RFC3339),
+		string(u.Status),
+	}
+}
+
+// writeUsers renders users to w in the requested format.
+func writeUsers(w io.Writer, format string, users []model.User) error {
+	switch format {
+	case formatJSON:
+		enc := json.NewEncoder(w)
+		enc.SetIndent("", "  ")
+		if users == nil {
+			users = []model.User{}
+		}
+		return enc.Encode(users)
+	case formatCSV:
+		cw := csv.NewWriter(w)
+		if err := cw.Write(userColumns); err != nil {
+			return err
+		}
+		for _, u := range users {
+			if err := cw.Write(userRow(u)); err != nil {
+				return err
+			}
+		}
+		cw.Flush()
+		return cw.Error()
+	case formatTable:
+		tw := tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
+		_, _ = fmt.Fprintln(tw, "ID\tUSERNAME\tEMAIL\tFULL NAME\tCREATED AT\tUPDATED AT\tSTATUS")
+		for _, u := range users {
+			r := userRow(u)
+			_, _ = fmt.Fprintf(tw, "%s\t%s\t%s\t%s\t%s\t%s\t%s\n", r[0], r[1], r[2], r[3], r[4], r[5], r[6])
+		}
+		return tw.Flush()
+	default:
+		return usageError(fmt.Sprintf("unknown output format %q: expected table, json or csv\n", format))
+	}
+}
+
+// writeImportReport renders an import report's rows to w in the requested format.
+func writeImportReport(w io.Writer, format string, report *model.ImportReport) error {
+	switch format {
+	case formatJSON:
+		enc := json.NewEncoder(w)
+		enc.SetIndent("", "  ")
+		return enc.Encode(report)
+	case formatCSV:
+		return importer.WriteCSV(w, report)
+	case formatTable:
+		tw := tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
+		_, _ = fmt.Fprintln(tw, "LINE\tSTATUS\tUSERNAME\tEMAIL\tREASON")
+		for _, row := range report.Rows {
+			_, _ = fmt.Fprintf(tw, "%d\t%s\t%s\t%s\t%s\n", row.Line, row.Status, row.Username, row.Email, row.Reason)
+		}
+		return tw.Flush()
+	default:
+		return usageError(fmt.Sprintf("unknown output format %q: expected table, json or csv\n", format))
+	}
+}
+
+// parseInterspersed parses flags that may appear before or after positional arguments,
+// e.g. `users get jdoe -o json`. The standard flag package stops at the first positional.
+func parseInterspersed(fs *flag.FlagSet, args []string) ([]string, error) {
+	var positional []string
+	for {
+		if err := fs.Parse(args); err != nil {
+			return nil, usageError("")
+		}
+		args = fs.Args()
+		if len(args) == 0 {
+			return positional, nil
+		}
+		positional = append(positional, args[0])
+		args = args[1:]
+	}
+}
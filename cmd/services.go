@@ -0,0 +1,52 @@
+package main
+
+import (
+	"cruder/internal/config"
+	"cruder/internal/events"
+	"cruder/internal/mail"
+	"cruder/internal/password"
+	"cruder/internal/repository"
+	"cruder/internal/service"
+	"log/slog"
+)
+
+// newServices wires the service layer over repositories, decorators included. The server and the
+// users and seed commands all build their services here, so a change made from the shell follows
+// the same rules as one made through the API. User and group changes go to publisher; verification,
+// confirmation and invitation emails go out through mailer.
+func newServices(cfg *config.Config, repositories *repository.Repository, publisher events.Publisher, mailer mail.Mailer, logger *slog.Logger) *service.Service {
+	services := service.NewService(repositories, password.NewHasher(cfg.PasswordParams()))
+
+	// Former usernames stay reserved for a while, so old links cannot be taken over by someone else
+	services.Users = service.WithUsernameQuarantine(services.Users, repositories.Users, cfg.Users.UsernameQuarantine)
+
+	// Committed changes are announced, e.g. to WatchUsers streams
+	services.Users = service.WithEvents(services.Users, publisher, logger)
+	services.Groups = service.WithGroupEvents(services.Groups, publisher, logger)
+	// Deleted users leave their groups first, so the groups' history and subscribers hear of it
+	services.Users = service.WithGroupRemoval(services.Users, services.Groups)
+
+	// Email changes wait for the new address to confirm; the old one gets a link to revert them
+	services.EmailChanges = service.NewEmailChangeService(repositories.EmailChanges, services.Users, repositories.Users, mailer, service.EmailChangeOptions{
+		TTL:        cfg.Mail.EmailChangeTTL,
+		RevertTTL:  cfg.Mail.EmailChangeRevertTTL,
+		ConfirmURL: cfg.Mail.EmailChangeConfirmURL,
+		RevertURL:  cfg.Mail.EmailChangeRevertURL,
+	})
+	services.Users = service.WithEmailChangeConfirmation(services.Users, services.EmailChanges, logger)
+
+	// Invitees create their own account; redeeming the mailed token proves the address, so the
+	// users they are created through are not the ones that send verification emails
+	services.Invitations = service.NewInvitationService(repositories.Invitations, services.Users, repositories.Users, services.EmailChanges, mailer, service.InvitationOptions{
+		TTL: cfg.Mail.InvitationTTL,
+		URL: cfg.Mail.InvitationURL,
+	}, logger)
+
+	// New users and users who change address are mailed a token to confirm it
+	services.Verification = service.NewEmailVerificationService(repositories.EmailVerifications, repositories.Users, mailer, service.VerificationOptions{
+		TTL: cfg.Mail.VerificationTTL,
+		URL: cfg.Mail.VerificationURL,
+	})
+	services.Users = service.WithEmailVerification(services.Users, services.Verification, logger)
+	return services
+}
@@ -0,0 +1,339 @@
+package main
+
+import (
+	"context"
+	"cruder/internal/config"
+	"cruder/internal/errors"
+	"cruder/internal/events"
+	"cruder/internal/importer"
+	"cruder/internal/mail"
+	"cruder/internal/model"
+	"cruder/internal/repository"
+	"cruder/internal/service"
+	"flag"
+	"fmt"
+	"log/slog"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin/binding"
+	"github.com/google/uuid"
+
+	stdErrors "errors"
+)
+
+const usersUsage = `Usage: cruder users <command> [flags]
+
+Commands:
+  list                              list all users
+  get <id|username>                 show a single user
+  search <query>                    find users by username, email or full name
+  create --username --email --full-name
+  update <id|username> [--username] [--email] [--full-name]
+                                    a new email address takes effect once confirmed from it
+  delete <id|username>
+  activate <id|username> [--reason]
+  suspend <id|username> --reason
+  deactivate <id|username> --reason
+                                    move a user through the account lifecycle; deactivating
+                                    keeps the user and their history, unlike delete
+  import <file.csv|-> [--mode skip-existing|upsert-by-email]
+                                    create users from a CSV file (username,email,full_name columns)
+                                    and print a per-row report; exits non-zero if any row failed
+
+Common flags:
+  -o, --output table|json|csv       output format (default table)
+  --dry-run                         for create/update/delete/import and the lifecycle commands: validate and
+                                    show the result without writing
+  --org <slug|id>                   organization whose users to manage (default "default")
+`
+
+// openServices connects to the database configured in the environment and wires the service layer
+// with newServices, as the server does. The returned func closes the connection.
+// Without sendMail the emails the services send, such as verification tokens, are dropped.
+func openServices(sendMail bool) (*service.Service, func(), error) {
+	cfg, err := config.LoadFromEnv()
+	if err != nil {
+		return nil, nil, err
+	}
+
+	dbConn, err := repository.NewPostgresConnection(cfg.BuildDSN())
+	if err != nil {
+		return nil, nil, err
+	}
+
+	var mailer mail.Mailer = discardMailer{}
+	if sendMail {
+		if mailer, err = mail.New(cfg.MailerConfig()); err != nil {
+			_ = dbConn.Close()
+			return nil, nil, err
+		}
+	}
+
+	// Changes are published like the server's, so WatchUsers streams see them
+	logger := slog.New(slog.NewTextHandler(os.Stderr, nil))
+	services := newServices(cfg, repository.NewRepository(dbConn.DB()), events.NewPostgresNotifier(dbConn.DB()), mailer, logger)
+	return services, func() { _ = dbConn.Close() }, nil
+}
+
+// discardMailer drops every message.
+type discardMailer struct{}
+
+func (discardMailer) Send(context.Context, mail.Message) error { return nil }
+
+// cliActor is recorded as the actor of renames and status changes made with these commands.
+const cliActor = "cli"
+
+// runUsers implements the operator-facing user management commands.
+func runUsers(args []string) error {
+	if len(args) == 0 {
+		return usageError(usersUsage)
+	}
+	command, args := args[0], args[1:]
+
+	fs := flag.NewFlagSet("users "+command, flag.ContinueOnError)
+	fs.Usage = func() { fmt.Fprint(fs.Output(), usersUsage) }
+	output := fs.String("output", formatTable, "output format: table, json or csv")
+	fs.StringVar(output, "o", formatTable, "shorthand for --output")
+	dryRun := fs.Bool("dry-run", false, "validate and show the result without writing")
+	username := fs.String("username", "", "username")
+	email := fs.String("email", "", "email address")
+	fullName := fs.String("full-name", "", "full name")
+	reason := fs.String("reason", "", "activate, suspend, deactivate: why, kept in the status history")
+	mode := fs.String("mode", string(model.ImportSkipExisting), "import: skip-existing or upsert-by-email")
+	orgRef := fs.String("org", model.DefaultOrganizationSlug, "slug or ID of the organization whose users to manage")
+
+	positional, err := parseInterspersed(fs, args)
+	if err != nil {
+		return err
+	}
+	set := map[string]bool{}
+	fs.Visit(func(f *flag.Flag) { set[f.Name] = true })
+
+	switch *output {
+	case formatTable, formatJSON, formatCSV:
+	default:
+		return usageError(fmt.Sprintf("unknown output format %q: expected table, json or csv\n", *output))
+	}
+
+	services, closeDB, err := openServices(true)
+	if err != nil {
+		return err
+	}
+	defer closeDB()
+	org, err := services.Organizations.Resolve(*orgRef)
+	if err != nil {
+		return fmt.Errorf("organization %q: %w", *orgRef, err)
+	}
+	users := services.Users.InOrganization(org.ID)
+	changes := services.EmailChanges.InOrganization(org.ID)
+
+	switch command {
+	case "list":
+		all, err := users.GetAll(nil)
+		if err != nil {
+			return err
+		}
+		return writeUsers(os.Stdout, *output, all)
+
+	case "get":
+		if len(positional) != 1 {
+			return usageError(usersUsage)
+		}
+		user, err := resolveUser(users, positional[0])
+		if err != nil {
+			return err
+		}
+		return writeUsers(os.Stdout, *output, []model.User{*user})
+
+	case "search":
+		if len(positional) == 0 {
+			return usageError(usersUsage)
+		}
+		found, err := users.Search(strings.Join(positional, " "), nil)
+		if err != nil {
+			return err
+		}
+		return writeUsers(os.Stdout, *output, found)
+
+	case "create":
+		req := model.CreateUserRequest{Username: *username, Email: *email, FullName: *fullName}
+		if err := binding.Validator.ValidateStruct(&req); err != nil {
+			return fmt.Errorf("%w: %v", errors.ErrInvalidInput, err)
+		}
+		if *dryRun {
+			if err := users.ValidateCreate(&req); err != nil {
+				return err
+			}
+			if err := checkConflicts(users, changes, uuid.Nil, &req.Username, &req.Email); err != nil {
+				return err
+			}
+			fmt.Fprintln(os.Stderr, "dry run: would create user")
+			return writeUsers(os.Stdout, *output, []model.User{{Username: req.Username, Email: req.Email, FullName: req.FullName, Status: req.Status}})
+		}
+		user, err := users.Create(&req)
+		if err != nil {
+			return err
+		}
+		return writeUsers(os.Stdout, *output, []model.User{*user})
+
+	case "update":
+		if len(positional) != 1 {
+			return usageError(usersUsage)
+		}
+		req := model.UpdateUserRequest{Actor: cliActor}
+		if set["username"] {
+			req.Username = username
+		}
+		if set["email"] {
+			req.Email = email
+		}
+		if set["full-name"] {
+			req.FullName = fullName
+		}
+		if err := binding.Validator.ValidateStruct(&req); err != nil {
+			return fmt.Errorf("%w: %v", errors.ErrInvalidInput, err)
+		}
+		user, err := resolveUser(users, positional[0])
+		if err != nil {
+			return err
+		}
+		if *dryRun {
+			if err := users.ValidateUpdate(&req); err != nil {
+				return err
+			}
+			if err := checkConflicts(users, changes, user.ID, req.Username, req.Email); err != nil {
+				return err
+			}
+			preview := *user
+			if req.Username != nil {
+				preview.Username = *req.Username
+			}
+			if req.FullName != nil {
+				preview.FullName = *req.FullName
+			}
+			fmt.Fprintln(os.Stderr, "dry run: would update user")
+			if req.Email != nil && *req.Email != user.Email {
+				fmt.Fprintf(os.Stderr, "dry run: would mail %s a link to confirm the new address\n", *req.Email)
+			}
+			return writeUsers(os.Stdout, *output, []model.User{preview})
+		}
+		updated, err := users.Update(user.ID, &req)
+		if err != nil {
+			return err
+		}
+		// Like through the API, a new address only takes effect once its owner confirms it
+		if pending := updated.PendingEmailChange; pending != nil {
+			fmt.Fprintf(os.Stderr, "mailed %s a link to confirm the new address; it expires at %s\n",
+				pending.NewEmail, pending.ExpiresAt.UTC().Format(time.RFC3339))
+		}
+		return writeUsers(os.Stdout, *output, []model.User{*updated})
+
+	case "delete":
+		if len(positional) != 1 {
+			return usageError(usersUsage)
+		}
+		user, err := resolveUser(users, positional[0])
+		if err != nil {
+			return err
+		}
+		if *dryRun {
+			fmt.Fprintln(os.Stderr, "dry run: would delete user")
+			return writeUsers(os.Stdout, *output, []model.User{*user})
+		}
+		if err := users.Delete(user.ID, cliActor); err != nil {
+			return err
+		}
+		fmt.Fprintf(os.Stderr, "deleted user %s (%s)\n", user.Username, user.ID)
+		return nil
+
+	case "activate", "suspend", "deactivate":
+		if len(positional) != 1 {
+			return usageError(usersUsage)
+		}
+		to := map[string]model.UserStatus{"activate": model.UserActive, "suspend": model.UserSuspended, "deactivate": model.UserDeactivated}[command]
+		user, err := resolveUser(users, positional[0])
+		if err != nil {
+			return err
+		}
+		if *dryRun {
+			if !user.Status.CanBecome(to) {
+				return &errors.StatusTransitionError{From: string(user.Status), To: string(to)}
+			}
+			if strings.TrimSpace(*reason) == "" && to != model.UserActive {
+				return fmt.Errorf("%w: --reason is required to %s a user", errors.ErrInvalidInput, command)
+			}
+			preview := *user
+			preview.Status = to
+			fmt.Fprintf(os.Stderr, "dry run: would %s user\n", command)
+			return writeUsers(os.Stdout, *output, []model.User{preview})
+		}
+		changed, err := users.ChangeStatus(user.ID, to, &model.ChangeUserStatusRequest{Reason: *reason, Actor: cliActor})
+		if err != nil {
+			return err
+		}
+		return writeUsers(os.Stdout, *output, []model.User{*changed})
+
+	case "import":
+		if len(positional) != 1 {
+			return usageError(usersUsage)
+		}
+		importMode, err := model.ParseImportMode(*mode)
+		if err != nil {
+			return err
+		}
+		in := os.Stdin
+		if positional[0] != "-" {
+			if in, err = os.Open(positional[0]); err != nil {
+				return err
+			}
+			defer func() { _ = in.Close() }()
+		}
+		report, err := importer.New(users).Import(context.Background(), in, importer.Options{Mode: importMode, DryRun: *dryRun, Actor: cliActor})
+		if err != nil {
+			return err
+		}
+		if err := writeImportReport(os.Stdout, *output, report); err != nil {
+			return err
+		}
+		if *dryRun {
+			fmt.Fprint(os.Stderr, "dry run: ")
+		}
+		fmt.Fprintf(os.Stderr, "%d created, %d updated, %d skipped, %d failed\n", report.Created, report.Updated, report.Skipped, report.Failed)
+		if report.Failed > 0 {
+			return fmt.Errorf("%d of %d rows failed", report.Failed, len(report.Rows))
+		}
+		return nil
+
+	default:
+		return usageError(usersUsage)
+	}
+}
+
+// resolveUser looks a user up by UUID, falling back to username.
+func resolveUser(users service.UserService, ref string) (*model.User, error) {
+	if id, err := uuid.Parse(ref); err == nil {
+		return users.GetByID(id, nil)
+	}
+	return users.GetByUsername(ref, nil)
+}
+
+// checkConflicts reports the uniqueness errors a real create (self is uuid.Nil) or update of self
+// would hit for username and email; nil means the field is left as it is. Addresses awaiting
+// confirmation by another user count as taken. Best effort: a concurrent writer may still claim
+// the username or email before the real write.
+func checkConflicts(users service.UserService, changes service.EmailChangeService, self uuid.UUID, username, email *string) error {
+	if username != nil {
+		if owner, err := users.GetByUsername(*username, nil); err == nil && owner.ID != self {
+			return errors.ErrUsernameExists
+		} else if err != nil && !stdErrors.Is(err, errors.ErrUserNotFound) {
+			return err
+		}
+	}
+
+	if email != nil {
+		return changes.CheckAvailable(self, *email)
+	}
+	return nil
+}
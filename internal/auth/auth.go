@@ -0,0 +1,280 @@
+// Package auth identifies API callers, by API key or by an access token obtained in exchange for one,
+// for both the HTTP middleware and the gRPC interceptors.
+package auth
+
+import (
+	"context"
+	"cruder/internal/errors"
+	"cruder/internal/metrics"
+	"cruder/internal/model"
+	"cruder/internal/token"
+	"crypto/subtle"
+	"fmt"
+	"os"
+	"slices"
+	"time"
+
+	"github.com/google/uuid"
+	"gopkg.in/yaml.v3"
+
+	stdErrors "errors"
+)
+
+// DefaultClientID is the identity of the API key set with API_KEY, which holds every scope.
+const DefaultClientID = "default"
+
+// ErrUnknownAPIKey is returned for an API key that belongs to no client.
+var ErrUnknownAPIKey = stdErrors.New("invalid API key")
+
+// ErrOrganizationNotAllowed is returned by Authenticator.Organization when a caller without the admin
+// scope names an organization other than its own.
+var ErrOrganizationNotAllowed = stdErrors.New("the admin scope is required to act in another organization")
+
+// OrganizationHeader names the organization an admin caller acts in, by slug or ID. The gRPC
+// interceptors read the same name from the metadata.
+const OrganizationHeader = "X-Organization"
+
+// Anonymous is the caller in development mode, when no API key is configured.
+var Anonymous = &model.Principal{Subject: "anonymous", Scopes: []string{model.ScopeAdmin}, Method: model.AuthNone}
+
+// Client is an API key holder.
+type Client struct {
+	ID     string   `yaml:"id"`
+	Key    string   `yaml:"key"`
+	Scopes []string `yaml:"scopes"`
+	// Organization is the slug of the organization whose users the client works on; empty for
+	// the default organization
+	Organization string `yaml:"organization"`
+}
+
+// LoadClients returns the API clients: DefaultClientID with apiKey, if set, and those listed in the
+// YAML file at path, if set, as
+//
+//	clients:
+//	  - id: ci
+//	    key: <secret>
+//	    scopes: [users:read]
+//	    organization: acme
+func LoadClients(apiKey, path string) ([]Client, error) {
+	var clients []Client
+	if apiKey != "" {
+		clients = append(clients, Client{ID: DefaultClientID, Key: apiKey, Scopes: []string{model.ScopeAdmin}})
+	}
+	if path != "" {
+		data, err := os.ReadFile(path)
+		if err != nil {
+			return nil, err
+		}
+		var file struct {
+			Clients []Client `yaml:"clients"`
+		}
+		if err := yaml.Unmarshal(data, &file); err != nil {
+			return nil, fmt.Errorf("%s: %w", path, err)
+		}
+		clients = append(clients, file.Clients...)
+	}
+
+	ids, keys := map[string]bool{}, map[string]bool{}
+	for _, c := range clients {
+		switch {
+		case c.ID == "" || c.Key == "":
+			return nil, stdErrors.New("every API client needs an id and a key")
+		case ids[c.ID]:
+			return nil, fmt.Errorf("duplicate API client %q", c.ID)
+		case keys[c.Key]:
+			return nil, fmt.Errorf("API client %q reuses another client's key", c.ID)
+		case len(c.Scopes) == 0:
+			return nil, fmt.Errorf("API client %q has no scopes", c.ID)
+		}
+		for _, scope := range c.Scopes {
+			if !slices.Contains(model.Scopes, scope) {
+				return nil, fmt.Errorf("API client %q: unknown scope %q", c.ID, scope)
+			}
+		}
+		ids[c.ID], keys[c.Key] = true, true
+	}
+	return clients, nil
+}
+
+// TokenVerifier checks access tokens; implemented by service.TokenService.
+type TokenVerifier interface {
+	Verify(accessToken string) (*model.Principal, error)
+}
+
+// OrganizationResolver finds organizations by slug or ID; implemented by service.OrganizationService.
+type OrganizationResolver interface {
+	// Resolve returns errors.ErrOrganizationNotFound if ref names no organization.
+	Resolve(ref string) (*model.Organization, error)
+}
+
+// FailureGuard slows down clients that keep presenting wrong API keys; implemented by
+// service.AuthFailureService.
+type FailureGuard interface {
+	// Check returns until when ip must wait before trying a key, or the zero time.
+	Check(ip string) (time.Time, error)
+	// Failed records a wrong key from ip and returns until when it must now wait, or the zero time.
+	Failed(ip string) (time.Time, error)
+}
+
+// ThrottledError is returned by APIKeyFrom for a client that must wait until RetryAt before
+// trying again. It matches errors.ErrAuthThrottled.
+type ThrottledError struct {
+	RetryAt time.Time
+}
+
+func (e *ThrottledError) Error() string { return errors.ErrAuthThrottled.Error() }
+
+func (e *ThrottledError) Unwrap() error { return errors.ErrAuthThrottled }
+
+// Authenticator maps API keys and access tokens to the Principal making the call.
+type Authenticator struct {
+	clients []Client
+	tokens  TokenVerifier
+	guard   FailureGuard
+	orgs    OrganizationResolver
+}
+
+// NewAuthenticator returns an Authenticator for clients. tokens may be nil, in which case
+// only API keys are accepted, guard may be nil, in which case wrong keys are not limited, and
+// orgs may be nil, in which case every caller acts in the default organization.
+func NewAuthenticator(clients []Client, tokens TokenVerifier, guard FailureGuard, orgs OrganizationResolver) *Authenticator {
+	return &Authenticator{clients: clients, tokens: tokens, guard: guard, orgs: orgs}
+}
+
+// Enabled reports whether any API key is configured. Without one, callers are not checked (dev mode).
+func (a *Authenticator) Enabled() bool {
+	return len(a.clients) > 0
+}
+
+// APIKey returns the client holding key.
+func (a *Authenticator) APIKey(key string) (*model.Principal, error) {
+	// Compare against every client so the time taken does not tell which one nearly matched
+	var found *Client
+	for i := range a.clients {
+		if subtle.ConstantTimeCompare([]byte(key), []byte(a.clients[i].Key)) == 1 {
+			found = &a.clients[i]
+		}
+	}
+	if found == nil {
+		return nil, ErrUnknownAPIKey
+	}
+	return &model.Principal{Subject: found.ID, Scopes: found.Scopes, Method: model.AuthAPIKey, Organization: found.Organization}, nil
+}
+
+// APIKeyFrom is APIKey for a caller connecting from ip. A client that presented too many wrong
+// keys gets a *ThrottledError, even for a right key, until its delay or ban is over, so guessing
+// cannot go faster than the FailureGuard allows. Errors from the guard are returned as they are:
+// the key is refused whenever its attempts cannot be counted.
+func (a *Authenticator) APIKeyFrom(ip, key string) (*model.Principal, error) {
+	if a.guard != nil {
+		until, err := a.guard.Check(ip)
+		if err != nil {
+			return nil, err
+		}
+		if !until.IsZero() {
+			metrics.AuthFailures.Inc(metrics.ReasonThrottled)
+			return nil, &ThrottledError{RetryAt: until}
+		}
+	}
+
+	principal, err := a.APIKey(key)
+	if err == nil {
+		return principal, nil
+	}
+	metrics.AuthFailures.Inc(metrics.ReasonInvalidAPIKey)
+	if a.guard != nil {
+		if _, gerr := a.guard.Failed(ip); gerr != nil {
+			return nil, gerr
+		}
+	}
+	return nil, err
+}
+
+// Bearer verifies an access token, rejecting every token when no TokenVerifier is set.
+func (a *Authenticator) Bearer(accessToken string) (*model.Principal, error) {
+	if a.tokens == nil {
+		metrics.AuthFailures.Inc(metrics.ReasonInvalidToken)
+		return nil, token.ErrInvalidToken
+	}
+	principal, err := a.tokens.Verify(accessToken)
+	if IsTokenRejected(err) {
+		metrics.AuthFailures.Inc(metrics.ReasonInvalidToken)
+	}
+	return principal, err
+}
+
+// Organization returns the ID of the organization p acts in: the one named by requested, a slug
+// or ID that only callers with the admin scope may name (see OrganizationHeader), or else p's own.
+// Other callers get ErrOrganizationNotAllowed, and unknown organizations
+// errors.ErrOrganizationNotFound.
+func (a *Authenticator) Organization(p *model.Principal, requested string) (uuid.UUID, error) {
+	if a.orgs == nil {
+		return model.DefaultOrganizationID, nil
+	}
+	own := p.Organization
+	if own == "" {
+		own = model.DefaultOrganizationSlug
+	}
+	org, err := a.orgs.Resolve(own)
+	if err != nil {
+		return uuid.Nil, err
+	}
+	if requested == "" {
+		return org.ID, nil
+	}
+
+	named, err := a.orgs.Resolve(requested)
+	if err != nil {
+		return uuid.Nil, err
+	}
+	if named.ID != org.ID && !p.HasScope(model.ScopeAdmin) {
+		return uuid.Nil, ErrOrganizationNotAllowed
+	}
+	return named.ID, nil
+}
+
+// IsTokenRejected reports whether an error from Bearer is about the token itself, rather than
+// a failure to check it.
+func IsTokenRejected(err error) bool {
+	return stdErrors.Is(err, token.ErrInvalidToken) || stdErrors.Is(err, token.ErrExpired) ||
+		stdErrors.Is(err, errors.ErrTokenRevoked)
+}
+
+type principalKey struct{}
+
+type organizationKey struct{}
+
+// WithPrincipal returns ctx carrying p.
+func WithPrincipal(ctx context.Context, p *model.Principal) context.Context {
+	return context.WithValue(ctx, principalKey{}, p)
+}
+
+// PrincipalFrom returns the caller stored by WithPrincipal. It is absent when the request did
+// not go through authentication at all, as in handler tests.
+func PrincipalFrom(ctx context.Context) (*model.Principal, bool) {
+	p, ok := ctx.Value(principalKey{}).(*model.Principal)
+	return p, ok
+}
+
+// ActorFrom names the caller of ctx for audit records such as the username history: the
+// principal's subject, or "" when there is none, which the services record as model.ActorSystem.
+func ActorFrom(ctx context.Context) string {
+	if p, ok := PrincipalFrom(ctx); ok {
+		return p.Subject
+	}
+	return ""
+}
+
+// WithOrganization returns ctx carrying the ID of the organization the caller acts in.
+func WithOrganization(ctx context.Context, org uuid.UUID) context.Context {
+	return context.WithValue(ctx, organizationKey{}, org)
+}
+
+// OrganizationFrom returns the organization stored by WithOrganization, or the default
+// organization when the request did not go through authentication, as in handler tests.
+func OrganizationFrom(ctx context.Context) uuid.UUID {
+	if org, ok := ctx.Value(organizationKey{}).(uuid.UUID); ok {
+		return org
+	}
+	return model.DefaultOrganizationID
+}
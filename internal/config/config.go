@@ -1,16 +1,27 @@
 package config
 
 import (
+	"cruder/internal/mail"
+	"cruder/internal/password"
 	"fmt"
 	"os"
+	"time"
 
 	"github.com/kelseyhightower/envconfig"
 )
 
 // Config holds all application configuration loaded from environment variables
 type Config struct {
-	Database DatabaseConfig
-	Server   ServerConfig
+	Database  DatabaseConfig
+	Server    ServerConfig
+	Migration MigrationConfig
+	Jobs      JobsConfig
+	Scheduler SchedulerConfig
+	Password  PasswordConfig
+	Auth      AuthConfig
+	Mail      MailConfig
+	Users     UsersConfig
+	OIDC      OIDCConfig
 }
 
 // DatabaseConfig holds database connection parameters
@@ -25,7 +36,116 @@ type DatabaseConfig struct {
 
 // ServerConfig holds server configuration
 type ServerConfig struct {
-	Port string `envconfig:"PORT" default:"8080"`
+	Port     string `envconfig:"PORT" default:"8080"`
+	GRPCPort string `envconfig:"GRPC_PORT" default:"9090"`
+	// TrustedProxies lists the proxies (IPs or CIDRs) whose X-Forwarded-For header is believed
+	// when working out the client IP. Empty trusts none, so the peer address is the client.
+	TrustedProxies []string `envconfig:"TRUSTED_PROXIES"`
+}
+
+// MigrationConfig holds schema migration behaviour
+type MigrationConfig struct {
+	// OnStart applies pending migrations before the server starts listening.
+	// Replicas serialize on a Postgres advisory lock so only one of them migrates.
+	OnStart bool `envconfig:"MIGRATE_ON_START" default:"false"`
+}
+
+// JobsConfig holds background job worker settings
+type JobsConfig struct {
+	// Workers is how many jobs this replica runs at once; 0 disables the worker,
+	// leaving the jobs to other replicas.
+	Workers int `envconfig:"JOB_WORKERS" default:"2"`
+}
+
+// SchedulerConfig holds periodic maintenance settings
+type SchedulerConfig struct {
+	// Enabled runs the maintenance scheduler on this replica. Replicas coordinate through
+	// Postgres, so it is safe to leave on everywhere.
+	Enabled bool `envconfig:"SCHEDULER_ENABLED" default:"true"`
+	// JobRetention is how long finished jobs and their results are kept
+	JobRetention time.Duration `envconfig:"JOB_RETENTION" default:"168h"`
+	// RunRetention is how long the task run history is kept
+	RunRetention time.Duration `envconfig:"TASK_RUN_RETENTION" default:"720h"`
+}
+
+// PasswordConfig holds the Argon2id cost parameters for new password hashes.
+// Existing hashes keep verifying after a change and are upgraded at the user's next login.
+type PasswordConfig struct {
+	MemoryKiB   uint32 `envconfig:"ARGON2_MEMORY_KIB" default:"65536"`
+	Iterations  uint32 `envconfig:"ARGON2_ITERATIONS" default:"3"`
+	Parallelism uint8  `envconfig:"ARGON2_PARALLELISM" default:"2"`
+}
+
+// AuthConfig holds API authentication settings
+type AuthConfig struct {
+	// APIKey is the key of the "default" client, which holds every scope. With neither it nor
+	// ClientsFile set, requests are not authenticated (dev mode).
+	APIKey string `envconfig:"API_KEY"`
+	// ClientsFile is a YAML list of further API clients with their keys and scopes
+	ClientsFile string `envconfig:"API_CLIENTS_FILE"`
+	// TokenTTL is the lifetime of the access tokens issued for API keys
+	TokenTTL    time.Duration `envconfig:"ACCESS_TOKEN_TTL" default:"15m"`
+	TokenIssuer string        `envconfig:"ACCESS_TOKEN_ISSUER" default:"cruder"`
+	// SigningKeysDir holds the token signing keys as <kid>.pem. Every replica must share them;
+	// when unset, each process generates its own key and its tokens only work against it.
+	SigningKeysDir string `envconfig:"TOKEN_SIGNING_KEYS_DIR"`
+	// SigningKeyID selects the signing key; by default the one with the greatest kid
+	SigningKeyID string `envconfig:"TOKEN_SIGNING_KEY_ID"`
+	// Wrong API keys are counted per client IP over FailureWindow. From DelayAfter failures the
+	// client waits DelayBase before its next attempt, doubling up to DelayMax; at BanAfter it is
+	// banned for BanDuration. 0 for DelayAfter or BanAfter turns that step off.
+	FailureWindow time.Duration `envconfig:"AUTH_FAILURE_WINDOW" default:"15m"`
+	DelayAfter    int           `envconfig:"AUTH_DELAY_AFTER" default:"5"`
+	DelayBase     time.Duration `envconfig:"AUTH_DELAY_BASE" default:"1s"`
+	DelayMax      time.Duration `envconfig:"AUTH_DELAY_MAX" default:"1m"`
+	BanAfter      int           `envconfig:"AUTH_BAN_AFTER" default:"20"`
+	BanDuration   time.Duration `envconfig:"AUTH_BAN_DURATION" default:"1h"`
+}
+
+// MailConfig holds outgoing email settings, used for the email verification messages
+type MailConfig struct {
+	// Transport is "smtp", "file" (one .eml file per message in Dir, for development) or "memory"
+	Transport    string `envconfig:"MAIL_TRANSPORT" default:"file"`
+	From         string `envconfig:"MAIL_FROM" default:"Cruder <no-reply@cruder.local>"`
+	Dir          string `envconfig:"MAIL_DIR" default:"tmp/mail"`
+	SMTPHost     string `envconfig:"SMTP_HOST"`
+	SMTPPort     int    `envconfig:"SMTP_PORT" default:"587"`
+	SMTPUsername string `envconfig:"SMTP_USERNAME"`
+	SMTPPassword string `envconfig:"SMTP_PASSWORD"`
+	// VerificationURL is the page verification emails link to, with ?token=... appended;
+	// without it they only carry the token
+	VerificationURL string `envconfig:"EMAIL_VERIFICATION_URL"`
+	// VerificationTTL is how long a verification token stays valid
+	VerificationTTL time.Duration `envconfig:"EMAIL_VERIFICATION_TTL" default:"48h"`
+	// EmailChangeTTL is how long the new address has to confirm an email change, and
+	// EmailChangeRevertTTL how long the old one can cancel or undo it
+	EmailChangeTTL       time.Duration `envconfig:"EMAIL_CHANGE_TTL" default:"24h"`
+	EmailChangeRevertTTL time.Duration `envconfig:"EMAIL_CHANGE_REVERT_TTL" default:"168h"`
+	// EmailChangeConfirmURL and EmailChangeRevertURL are the pages the email change messages link to
+	EmailChangeConfirmURL string `envconfig:"EMAIL_CHANGE_CONFIRM_URL"`
+	EmailChangeRevertURL  string `envconfig:"EMAIL_CHANGE_REVERT_URL"`
+	// InvitationURL is the sign-up page invitation emails link to, and InvitationTTL how long
+	// an invitation can be redeemed
+	InvitationURL string        `envconfig:"INVITATION_URL"`
+	InvitationTTL time.Duration `envconfig:"INVITATION_TTL" default:"168h"`
+}
+
+// UsersConfig holds user account rules
+type UsersConfig struct {
+	// UsernameQuarantine is how long a username given up in a rename stays reserved for its
+	// former owner; 0 lets anyone claim it at once
+	UsernameQuarantine time.Duration `envconfig:"USERNAME_QUARANTINE" default:"720h"`
+}
+
+// OIDCConfig holds the built-in OpenID Connect provider settings
+type OIDCConfig struct {
+	// Issuer is the public base URL client applications reach this service at. It is the iss of
+	// ID tokens and the base of every endpoint in the discovery document.
+	Issuer string `envconfig:"OIDC_ISSUER" default:"http://localhost:8080"`
+	// CodeTTL is how long an authorization code can be exchanged
+	CodeTTL time.Duration `envconfig:"OIDC_CODE_TTL" default:"1m"`
+	// TokenTTL is the lifetime of the access and ID tokens issued to client applications
+	TokenTTL time.Duration `envconfig:"OIDC_TOKEN_TTL" default:"1h"`
 }
 
 // LoadFromEnv loads all configuration from environment variables using envconfig.
@@ -45,6 +165,28 @@ func LoadFromEnv() (*Config, error) {
 	return &cfg, nil
 }
 
+// PasswordParams returns the configured Argon2id parameters
+func (c *Config) PasswordParams() password.Params {
+	return password.Params{
+		Memory:      c.Password.MemoryKiB,
+		Iterations:  c.Password.Iterations,
+		Parallelism: c.Password.Parallelism,
+	}
+}
+
+// MailerConfig returns the configured mail transport settings
+func (c *Config) MailerConfig() mail.Config {
+	return mail.Config{
+		Transport:    c.Mail.Transport,
+		From:         c.Mail.From,
+		SMTPHost:     c.Mail.SMTPHost,
+		SMTPPort:     c.Mail.SMTPPort,
+		SMTPUsername: c.Mail.SMTPUsername,
+		SMTPPassword: c.Mail.SMTPPassword,
+		Dir:          c.Mail.Dir,
+	}
+}
+
 // BuildDSN builds the PostgreSQL connection string from loaded configuration
 func (c *Config) BuildDSN() string {
 	return fmt.Sprintf(
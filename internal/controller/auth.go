@@ -0,0 +1,255 @@
+package controller
+
+import (
+	"cruder/internal/auth"
+	"cruder/internal/errors"
+	"cruder/internal/model"
+	"cruder/internal/service"
+	"fmt"
+	"net"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"github.com/go-playground/validator/v10"
+	"github.com/google/uuid"
+
+	stdErrors "errors"
+)
+
+type AuthController struct {
+	service  service.AuthService
+	tokens   service.TokenService
+	failures service.AuthFailureService
+}
+
+func NewAuthController(service service.AuthService, tokens service.TokenService, failures service.AuthFailureService) *AuthController {
+	return &AuthController{service: service, tokens: tokens, failures: failures}
+}
+
+// bindJSON binds the request body into req, writing a 400 and returning false if it is invalid.
+func bindJSON(ctx *gin.Context, req any) bool {
+	if err := ctx.ShouldBindJSON(req); err != nil {
+		var ve validator.ValidationErrors
+		if stdErrors.As(err, &ve) {
+			ctx.JSON(http.StatusBadRequest, gin.H{
+				"error":   "Validation failed",
+				"code":    errors.CodeInvalidInput,
+				"message": "Invalid input data",
+				"details": formatValidationErrors(ve),
+			})
+			return false
+		}
+		ctx.JSON(http.StatusBadRequest, gin.H{
+			"error":   "Invalid request",
+			"code":    errors.CodeInvalidInput,
+			"message": fmt.Sprintf("Failed to parse request body: %v", err.Error()),
+		})
+		return false
+	}
+	return true
+}
+
+// Login checks a username-or-email and password and returns the user they belong to.
+// Every failure is the same 401, so it does not reveal whether the account exists; only with the
+// right password does a user who is not active learn so, from a 403.
+func (c *AuthController) Login(ctx *gin.Context) {
+	var req model.LoginRequest
+	if !bindJSON(ctx, &req) {
+		return
+	}
+
+	user, err := c.service.InOrganization(organization(ctx)).Login(req.Login, req.Password)
+	if err != nil {
+		if stdErrors.Is(err, errors.ErrInvalidCredentials) {
+			ctx.JSON(http.StatusUnauthorized, gin.H{
+				"error":   "Unauthorized",
+				"code":    errors.CodeInvalidCredentials,
+				"message": err.Error(),
+			})
+			return
+		}
+		if stdErrors.Is(err, errors.ErrUserNotActive) {
+			ctx.JSON(http.StatusForbidden, gin.H{
+				"error":   "Forbidden",
+				"code":    errors.CodeUserNotActive,
+				"message": err.Error(),
+			})
+			return
+		}
+		ctx.JSON(http.StatusInternalServerError, gin.H{
+			"error":   "Internal server error",
+			"message": "failed to log in",
+		})
+		return
+	}
+	ctx.JSON(http.StatusOK, user)
+}
+
+// ChangePassword sets a user's password; see service.AuthService.ChangePassword.
+func (c *AuthController) ChangePassword(ctx *gin.Context) {
+	id, err := uuid.Parse(ctx.Param("id"))
+	if err != nil {
+		ctx.JSON(http.StatusBadRequest, gin.H{
+			"error":   "Invalid input",
+			"code":    errors.CodeInvalidInput,
+			"message": "ID must be a valid UUID",
+		})
+		return
+	}
+	var req model.ChangePasswordRequest
+	if !bindJSON(ctx, &req) {
+		return
+	}
+
+	err = c.service.InOrganization(organization(ctx)).ChangePassword(id, &req)
+	switch {
+	case err == nil:
+		ctx.Status(http.StatusNoContent)
+	case stdErrors.Is(err, errors.ErrUserNotFound):
+		ctx.JSON(http.StatusNotFound, gin.H{
+			"error":   "Not found",
+			"code":    errors.CodeUserNotFound,
+			"message": fmt.Sprintf("user with id '%s' not found", id),
+		})
+	case stdErrors.Is(err, errors.ErrWrongPassword):
+		ctx.JSON(http.StatusForbidden, gin.H{
+			"error":   "Forbidden",
+			"code":    errors.CodeWrongPassword,
+			"message": err.Error(),
+		})
+	case stdErrors.Is(err, errors.ErrInvalidInput):
+		ctx.JSON(http.StatusBadRequest, gin.H{
+			"error":   "Invalid input",
+			"code":    errors.CodeInvalidInput,
+			"message": err.Error(),
+		})
+	default:
+		ctx.JSON(http.StatusInternalServerError, gin.H{
+			"error":   "Internal server error",
+			"message": "failed to change password",
+		})
+	}
+}
+
+// IssueToken trades the API key the request was authenticated with for a short-lived access token.
+// The optional body narrows its scopes.
+func (c *AuthController) IssueToken(ctx *gin.Context) {
+	principal, ok := auth.PrincipalFrom(ctx.Request.Context())
+	if !ok {
+		principal = auth.Anonymous
+	}
+	if principal.Method == model.AuthToken {
+		ctx.JSON(http.StatusForbidden, gin.H{
+			"error":   "Forbidden",
+			"message": "access tokens cannot be exchanged for new ones; authenticate with an API key",
+		})
+		return
+	}
+
+	var req model.AccessTokenRequest
+	if ctx.Request.ContentLength != 0 && !bindJSON(ctx, &req) {
+		return
+	}
+
+	issued, err := c.tokens.Issue(principal, req.Scope)
+	switch {
+	case err == nil:
+		// Tokens are credentials; RFC 6749 §5.1 forbids caching the response
+		ctx.Header("Cache-Control", "no-store")
+		ctx.JSON(http.StatusOK, issued)
+	case stdErrors.Is(err, errors.ErrScopeNotGranted):
+		ctx.JSON(http.StatusForbidden, gin.H{
+			"error":   "Forbidden",
+			"code":    errors.CodeScopeNotGranted,
+			"message": err.Error(),
+		})
+	case stdErrors.Is(err, errors.ErrInvalidInput):
+		ctx.JSON(http.StatusBadRequest, gin.H{
+			"error":   "Invalid input",
+			"code":    errors.CodeInvalidInput,
+			"message": err.Error(),
+		})
+	default:
+		ctx.JSON(http.StatusInternalServerError, gin.H{
+			"error":   "Internal server error",
+			"message": "failed to issue access token",
+		})
+	}
+}
+
+// RevokeTokens rejects from now on the access tokens of one client, or of every client,
+// issued before a point in time.
+func (c *AuthController) RevokeTokens(ctx *gin.Context) {
+	var req model.RevokeTokensRequest
+	if !bindJSON(ctx, &req) {
+		return
+	}
+
+	rev, err := c.tokens.Revoke(&req)
+	if err != nil {
+		if stdErrors.Is(err, errors.ErrInvalidInput) {
+			ctx.JSON(http.StatusBadRequest, gin.H{
+				"error":   "Invalid input",
+				"code":    errors.CodeInvalidInput,
+				"message": err.Error(),
+			})
+			return
+		}
+		ctx.JSON(http.StatusInternalServerError, gin.H{
+			"error":   "Internal server error",
+			"message": "failed to revoke access tokens",
+		})
+		return
+	}
+	ctx.JSON(http.StatusOK, rev)
+}
+
+// JWKS publishes the public keys access tokens are signed with, for verifying them elsewhere.
+func (c *AuthController) JWKS(ctx *gin.Context) {
+	// Short enough for a newly rotated key to be picked up before it signs anything widely used
+	ctx.Header("Cache-Control", "public, max-age=300")
+	ctx.JSON(http.StatusOK, c.tokens.JWKS())
+}
+
+// ListBans returns the client IPs currently banned for presenting too many wrong API keys.
+func (c *AuthController) ListBans(ctx *gin.Context) {
+	bans, err := c.failures.ListBans()
+	if err != nil {
+		ctx.JSON(http.StatusInternalServerError, gin.H{
+			"error":   "Internal server error",
+			"message": "failed to list bans",
+		})
+		return
+	}
+	ctx.JSON(http.StatusOK, bans)
+}
+
+// LiftBan lets a banned client IP authenticate again straight away.
+func (c *AuthController) LiftBan(ctx *gin.Context) {
+	ip := net.ParseIP(ctx.Param("ip"))
+	if ip == nil {
+		ctx.JSON(http.StatusBadRequest, gin.H{
+			"error":   "Invalid input",
+			"code":    errors.CodeInvalidInput,
+			"message": "IP must be a valid IPv4 or IPv6 address",
+		})
+		return
+	}
+
+	err := c.failures.LiftBan(ip.String(), auth.ActorFrom(ctx.Request.Context()))
+	switch {
+	case err == nil:
+		ctx.Status(http.StatusNoContent)
+	case stdErrors.Is(err, errors.ErrBanNotFound):
+		ctx.JSON(http.StatusNotFound, gin.H{
+			"error":   "Not found",
+			"code":    errors.CodeBanNotFound,
+			"message": fmt.Sprintf("client '%s' is not banned", ip),
+		})
+	default:
+		ctx.JSON(http.StatusInternalServerError, gin.H{
+			"error":   "Internal server error",
+			"message": "failed to lift ban",
+		})
+	}
+}
@@ -1,18 +1,49 @@
 package controller
 
 import (
+	"cruder/internal/auth"
 	"cruder/internal/repository"
 	"cruder/internal/service"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
 )
 
 type Controller struct {
-	Users  *UserController
-	Health *HealthController
+	Organizations *OrganizationController
+	Users         *UserController
+	Groups        *GroupController
+	Invitations   *InvitationController
+	Jobs          *JobController
+	Scheduler     *SchedulerController
+	Auth          *AuthController
+	Verification  *VerificationController
+	Health        *HealthController
+	Docs          *DocsController
+	GraphQL       *GraphQLController
+	OAuth         *OAuthController
+	SCIM          *SCIMController
 }
 
-func NewController(services *service.Service, dbConn *repository.PostgresConnection) *Controller {
+func NewController(services *service.Service, dbConn *repository.PostgresConnection, schema SchemaVersionChecker) *Controller {
 	return &Controller{
-		Users:  NewUserController(services.Users),
-		Health: NewHealthController(dbConn),
+		Organizations: NewOrganizationController(services.Organizations),
+		Users:         NewUserController(services.Users, services.Jobs, services.EmailChanges),
+		Groups:        NewGroupController(services.Groups),
+		Invitations:   NewInvitationController(services.Invitations),
+		Jobs:          NewJobController(services.Jobs),
+		Scheduler:     NewSchedulerController(services.TaskRuns),
+		Auth:          NewAuthController(services.Auth, services.Tokens, services.AuthFailures),
+		Verification:  NewVerificationController(services.Verification, services.EmailChanges),
+		Health:        NewHealthController(dbConn, schema),
+		Docs:          NewDocsController(),
+		GraphQL:       NewGraphQLController(services.Users),
+		OAuth:         NewOAuthController(services.OAuth, services.AuthFailures),
+		SCIM:          NewSCIMController(services.Users),
 	}
 }
+
+// organization is the organization the caller acts in, as resolved by middleware.Authenticate.
+func organization(ctx *gin.Context) uuid.UUID {
+	return auth.OrganizationFrom(ctx.Request.Context())
+}
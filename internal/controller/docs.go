@@ -0,0 +1,26 @@
+package controller
+
+import (
+	"cruder/internal/openapi"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+)
+
+// DocsController serves the API contract and its documentation page
+type DocsController struct{}
+
+// NewDocsController creates a new docs controller
+func NewDocsController() *DocsController {
+	return &DocsController{}
+}
+
+// OpenAPISpec handles GET /openapi.json
+func (d *DocsController) OpenAPISpec(ctx *gin.Context) {
+	ctx.JSON(http.StatusOK, openapi.Spec())
+}
+
+// DocsUI handles GET /docs with a page rendered from /openapi.json
+func (d *DocsController) DocsUI(ctx *gin.Context) {
+	ctx.Data(http.StatusOK, "text/html; charset=utf-8", openapi.DocsHTML)
+}
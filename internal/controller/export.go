@@ -0,0 +1,114 @@
+package controller
+
+import (
+	"cruder/internal/errors"
+	"cruder/internal/export"
+	"cruder/internal/model"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/gin-gonic/gin/binding"
+
+	stdErrors "errors"
+)
+
+// exportOffers are the response types of the user list endpoints, JSON first so it stays the default.
+var exportOffers = append([]string{binding.MIMEJSON}, export.MediaTypes...)
+
+// exportFormat returns the export media type picked by the Accept header,
+// or "" when the response should be the regular JSON array.
+func exportFormat(ctx *gin.Context) string {
+	format := ctx.NegotiateFormat(exportOffers...)
+	if format == binding.MIMEJSON {
+		return ""
+	}
+	return format
+}
+
+// writeExport streams the users handed over by source in mediaType. Nothing is buffered beyond
+// the encoder, so source should read straight from the database (service.StreamAll and friends).
+//
+// The status line goes out with the first flushed rows. If source fails before that the client
+// gets a regular 500; after that the body is cut short and the error is only logged.
+func writeExport(ctx *gin.Context, mediaType string, fields model.UserFields, filename string, source func(fn func(*model.User) error) error) {
+	// A large export can take longer than the server's WriteTimeout
+	_ = http.NewResponseController(ctx.Writer).SetWriteDeadline(time.Time{})
+
+	header := ctx.Writer.Header()
+	header.Set("Content-Type", mediaType)
+	if mediaType == export.MIMECSV {
+		header.Set("Content-Disposition", `attachment; filename="`+filename+`.csv"`)
+	}
+	ctx.Status(http.StatusOK)
+
+	w, err := export.NewWriter(mediaType, ctx.Writer, fields)
+	if err == nil {
+		err = source(w.Write)
+		if closeErr := w.Close(); err == nil {
+			err = closeErr
+		}
+	}
+	if err == nil {
+		return
+	}
+
+	if !ctx.Writer.Written() {
+		header.Del("Content-Type")
+		header.Del("Content-Disposition")
+		ctx.JSON(http.StatusInternalServerError, gin.H{
+			"error":   "Internal server error",
+			"message": "failed to export users: " + err.Error(),
+		})
+		return
+	}
+	if loggerVal, exists := ctx.Get("logger"); exists {
+		logger := loggerVal.(*slog.Logger)
+		logger.Error("User export aborted mid-stream",
+			slog.String("content_type", mediaType),
+			slog.String("error", err.Error()))
+	}
+	ctx.Abort()
+}
+
+// ExportUsers starts a users.export job for exports too large to stream within one request.
+// The body names the format and, optionally, fields and a search query; the response is 202
+// with the job, whose result is the export file.
+func (c *UserController) ExportUsers(ctx *gin.Context) {
+	var params model.UserExportParams
+	if !bindJSON(ctx, &params) {
+		return
+	}
+
+	job, err := c.jobs.InOrganization(organization(ctx)).EnqueueUserExport(params)
+	if err != nil {
+		if stdErrors.Is(err, errors.ErrInvalidInput) {
+			ctx.JSON(http.StatusBadRequest, gin.H{
+				"error":   "Invalid input",
+				"code":    errors.CodeInvalidInput,
+				"message": err.Error(),
+			})
+			return
+		}
+		ctx.JSON(http.StatusInternalServerError, gin.H{
+			"error":   "Internal server error",
+			"message": fmt.Sprintf("failed to start export: %v", err),
+		})
+		return
+	}
+	writeAccepted(ctx, job)
+}
+
+// writeUsers writes an already loaded page of users in mediaType.
+func writeUsers(ctx *gin.Context, mediaType string, fields model.UserFields, filename string, users []model.User) {
+	writeExport(ctx, mediaType, fields, filename, func(fn func(*model.User) error) error {
+		for i := range users {
+			if err := fn(&users[i]); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
@@ -0,0 +1,37 @@
+package controller
+
+import (
+	"cruder/internal/errors"
+	"cruder/internal/graph"
+	"cruder/internal/service"
+	"fmt"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+)
+
+// GraphQLController serves the GraphQL endpoint
+type GraphQLController struct {
+	schema *graph.Schema
+}
+
+// NewGraphQLController creates a new GraphQL controller over the user service
+func NewGraphQLController(service service.UserService) *GraphQLController {
+	return &GraphQLController{schema: graph.MustNewSchema(service, graph.DefaultLimits)}
+}
+
+// Query handles POST /graphql. Once the body parses, the response is 200 and
+// any failure is reported in its `errors` array, as GraphQL clients expect.
+func (c *GraphQLController) Query(ctx *gin.Context) {
+	var req graph.Request
+	if err := ctx.ShouldBindJSON(&req); err != nil {
+		ctx.JSON(http.StatusBadRequest, gin.H{
+			"error":   "Invalid request",
+			"code":    errors.CodeInvalidInput,
+			"message": fmt.Sprintf("Failed to parse request body: %v", err.Error()),
+		})
+		return
+	}
+
+	ctx.JSON(http.StatusOK, c.schema.Execute(ctx.Request.Context(), req))
+}
@@ -0,0 +1,278 @@
+package controller
+
+import (
+	"cruder/internal/auth"
+	"cruder/internal/errors"
+	"cruder/internal/model"
+	"cruder/internal/service"
+	"fmt"
+	"net/http"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+
+	stdErrors "errors"
+)
+
+// GroupController serves groups, their members and the groups of a user.
+type GroupController struct {
+	service service.GroupService
+}
+
+func NewGroupController(service service.GroupService) *GroupController {
+	return &GroupController{service: service}
+}
+
+// in returns the groups of the organization the caller acts in.
+func (c *GroupController) in(ctx *gin.Context) service.GroupService {
+	return c.service.InOrganization(organization(ctx))
+}
+
+// uuidParam parses the path parameter name, writing a 400 if it is not a UUID.
+func uuidParam(ctx *gin.Context, name string) (uuid.UUID, bool) {
+	id, err := uuid.Parse(ctx.Param(name))
+	if err != nil {
+		ctx.JSON(http.StatusBadRequest, gin.H{
+			"error":   "Invalid input",
+			"code":    errors.CodeInvalidInput,
+			"message": "ID must be a valid UUID",
+		})
+		return uuid.Nil, false
+	}
+	return id, true
+}
+
+// writeGroupError answers a failed request about the group with id; action completes "failed to".
+func writeGroupError(ctx *gin.Context, id uuid.UUID, err error, action string) {
+	switch {
+	case stdErrors.Is(err, errors.ErrInvalidInput):
+		ctx.JSON(http.StatusBadRequest, gin.H{
+			"error":   "Invalid input",
+			"code":    errors.CodeInvalidInput,
+			"message": err.Error(),
+		})
+	case stdErrors.Is(err, errors.ErrGroupNotFound):
+		ctx.JSON(http.StatusNotFound, gin.H{
+			"error":   "Not found",
+			"code":    errors.CodeGroupNotFound,
+			"message": fmt.Sprintf("group with id '%s' not found", id),
+		})
+	case stdErrors.Is(err, errors.ErrGroupMemberNotFound):
+		ctx.JSON(http.StatusNotFound, gin.H{
+			"error":   "Not found",
+			"code":    errors.CodeGroupMemberNotFound,
+			"message": err.Error(),
+		})
+	case stdErrors.Is(err, errors.ErrGroupExists), stdErrors.Is(err, errors.ErrGroupMemberExists),
+		stdErrors.Is(err, errors.ErrGroupCycle):
+		ctx.JSON(http.StatusConflict, gin.H{
+			"error":   "Conflict",
+			"code":    errors.Code(err),
+			"message": err.Error(),
+		})
+	default:
+		ctx.JSON(http.StatusInternalServerError, gin.H{
+			"error":   "Internal server error",
+			"message": fmt.Sprintf("failed to %s: %v", action, err),
+		})
+	}
+}
+
+// CreateGroup adds a group to the caller's organization.
+func (c *GroupController) CreateGroup(ctx *gin.Context) {
+	var req model.CreateGroupRequest
+	if !bindJSON(ctx, &req) {
+		return
+	}
+
+	group, err := c.in(ctx).Create(&req)
+	if err != nil {
+		writeGroupError(ctx, uuid.Nil, err, "create group")
+		return
+	}
+	ctx.JSON(http.StatusCreated, group)
+}
+
+// ListGroups returns the organization's groups, by name.
+func (c *GroupController) ListGroups(ctx *gin.Context) {
+	groups, err := c.in(ctx).List()
+	if err != nil {
+		writeGroupError(ctx, uuid.Nil, err, "list groups")
+		return
+	}
+	ctx.JSON(http.StatusOK, groups)
+}
+
+func (c *GroupController) GetGroup(ctx *gin.Context) {
+	id, ok := uuidParam(ctx, "id")
+	if !ok {
+		return
+	}
+	group, err := c.in(ctx).Get(id)
+	if err != nil {
+		writeGroupError(ctx, id, err, "retrieve group")
+		return
+	}
+	ctx.JSON(http.StatusOK, group)
+}
+
+// UpdateGroup renames a group or changes its description.
+func (c *GroupController) UpdateGroup(ctx *gin.Context) {
+	id, ok := uuidParam(ctx, "id")
+	if !ok {
+		return
+	}
+	var req model.UpdateGroupRequest
+	if !bindJSON(ctx, &req) {
+		return
+	}
+
+	group, err := c.in(ctx).Update(id, &req)
+	if err != nil {
+		writeGroupError(ctx, id, err, "update group")
+		return
+	}
+	ctx.JSON(http.StatusOK, group)
+}
+
+// DeleteGroup removes a group. Its members are not deleted, only their membership.
+func (c *GroupController) DeleteGroup(ctx *gin.Context) {
+	id, ok := uuidParam(ctx, "id")
+	if !ok {
+		return
+	}
+	if _, err := c.in(ctx).Delete(id, auth.ActorFrom(ctx.Request.Context())); err != nil {
+		writeGroupError(ctx, id, err, "delete group")
+		return
+	}
+	ctx.Status(http.StatusNoContent)
+}
+
+// ListGroupMembers returns the direct members of a group, users and nested groups.
+func (c *GroupController) ListGroupMembers(ctx *gin.Context) {
+	id, ok := uuidParam(ctx, "id")
+	if !ok {
+		return
+	}
+	members, err := c.in(ctx).Members(id)
+	if err != nil {
+		writeGroupError(ctx, id, err, "list group members")
+		return
+	}
+	ctx.JSON(http.StatusOK, members)
+}
+
+// ListEffectiveMembers returns every user in a group, directly or through nested groups.
+func (c *GroupController) ListEffectiveMembers(ctx *gin.Context) {
+	id, ok := uuidParam(ctx, "id")
+	if !ok {
+		return
+	}
+	users, err := c.in(ctx).EffectiveMembers(id)
+	if err != nil {
+		writeGroupError(ctx, id, err, "resolve effective members")
+		return
+	}
+	ctx.JSON(http.StatusOK, users)
+}
+
+// AddGroupMember adds a user or a group to a group; the response is the new member.
+func (c *GroupController) AddGroupMember(ctx *gin.Context) {
+	id, ok := uuidParam(ctx, "id")
+	if !ok {
+		return
+	}
+	var req model.AddGroupMemberRequest
+	if !bindJSON(ctx, &req) {
+		return
+	}
+	req.Actor = auth.ActorFrom(ctx.Request.Context())
+
+	change, err := c.in(ctx).AddMember(id, &req)
+	if err != nil {
+		writeGroupError(ctx, id, err, "add group member")
+		return
+	}
+	ctx.JSON(http.StatusCreated, model.GroupMember{
+		Type:    change.MemberType,
+		ID:      change.MemberID,
+		Name:    change.MemberName,
+		AddedBy: change.Actor,
+		AddedAt: change.ChangedAt,
+	})
+}
+
+// RemoveGroupUser removes a user from a group.
+func (c *GroupController) RemoveGroupUser(ctx *gin.Context) {
+	c.removeMember(ctx, model.MemberTypeUser, "user_id")
+}
+
+// RemoveGroupSubgroup removes a nested group from a group.
+func (c *GroupController) RemoveGroupSubgroup(ctx *gin.Context) {
+	c.removeMember(ctx, model.MemberTypeGroup, "group_id")
+}
+
+// removeMember removes the direct member of memberType whose ID is the path parameter param.
+func (c *GroupController) removeMember(ctx *gin.Context, memberType, param string) {
+	id, ok := uuidParam(ctx, "id")
+	if !ok {
+		return
+	}
+	memberID, ok := uuidParam(ctx, param)
+	if !ok {
+		return
+	}
+	if _, err := c.in(ctx).RemoveMember(id, memberType, memberID, auth.ActorFrom(ctx.Request.Context())); err != nil {
+		writeGroupError(ctx, id, err, "remove group member")
+		return
+	}
+	ctx.Status(http.StatusNoContent)
+}
+
+// GetGroupHistory returns who added and removed the group's members and when, newest first.
+func (c *GroupController) GetGroupHistory(ctx *gin.Context) {
+	id, ok := uuidParam(ctx, "id")
+	if !ok {
+		return
+	}
+	history, err := c.in(ctx).History(id)
+	if err != nil {
+		writeGroupError(ctx, id, err, "retrieve group history")
+		return
+	}
+	ctx.JSON(http.StatusOK, history)
+}
+
+// ListUserGroups returns the groups a user is a direct member of or, with `effective=true`, also
+// those containing them through nested groups.
+func (c *GroupController) ListUserGroups(ctx *gin.Context) {
+	id, ok := uuidParam(ctx, "id")
+	if !ok {
+		return
+	}
+	effective, err := strconv.ParseBool(ctx.DefaultQuery("effective", "false"))
+	if err != nil {
+		ctx.JSON(http.StatusBadRequest, gin.H{
+			"error":   "Invalid input",
+			"code":    errors.CodeInvalidInput,
+			"message": "effective must be true or false",
+		})
+		return
+	}
+
+	groups, err := c.in(ctx).GroupsOfUser(id, effective)
+	if err != nil {
+		if stdErrors.Is(err, errors.ErrUserNotFound) {
+			ctx.JSON(http.StatusNotFound, gin.H{
+				"error":   "Not found",
+				"code":    errors.CodeUserNotFound,
+				"message": fmt.Sprintf("user with id '%s' not found", id),
+			})
+			return
+		}
+		writeGroupError(ctx, id, err, "list the user's groups")
+		return
+	}
+	ctx.JSON(http.StatusOK, groups)
+}
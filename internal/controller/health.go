@@ -1,22 +1,33 @@
 package controller
 
 import (
+	"context"
+	"cruder/internal/metrics"
 	"cruder/internal/repository"
+	"fmt"
 	"net/http"
 	"time"
 
 	"github.com/gin-gonic/gin"
 )
 
+// SchemaVersionChecker reports the applied schema version and the version the binary expects.
+// Implemented by migration.Migrator.
+type SchemaVersionChecker interface {
+	Versions(ctx context.Context) (current, expected int64, err error)
+}
+
 // HealthController handles health check endpoints for Kubernetes probes
 type HealthController struct {
 	dbConn *repository.PostgresConnection
+	schema SchemaVersionChecker
 }
 
 // NewHealthController creates a new health controller
-func NewHealthController(dbConn *repository.PostgresConnection) *HealthController {
+func NewHealthController(dbConn *repository.PostgresConnection, schema SchemaVersionChecker) *HealthController {
 	return &HealthController{
 		dbConn: dbConn,
+		schema: schema,
 	}
 }
 
@@ -49,7 +60,8 @@ func (h *HealthController) LivenessProbe(ctx *gin.Context) {
 // - Returns 200: App is ready, send traffic
 // - Returns 500+: App is not ready, remove from load balancer
 //
-// Use case: Detects when app is starting up or database is unreachable
+// Use case: Detects when app is starting up, database is unreachable,
+// or the schema is behind the migrations embedded in this binary
 //
 // Difference from Liveness:
 // - Liveness: "Is app alive?" → Restart if not
@@ -73,6 +85,28 @@ func (h *HealthController) ReadinessProbe(ctx *gin.Context) {
 
 	checks["database"] = "healthy"
 
+	// Check schema version: serving traffic against an older schema leads to SQL errors
+	if h.schema != nil {
+		current, expected, err := h.schema.Versions(ctx.Request.Context())
+		if err != nil || current < expected {
+			if err != nil {
+				checks["migrations"] = "unhealthy: " + err.Error()
+			} else {
+				checks["migrations"] = fmt.Sprintf("behind: schema version %d, expected %d", current, expected)
+			}
+
+			response := HealthResponse{
+				Status:    "not_ready",
+				Timestamp: time.Now().UTC(),
+				Checks:    checks,
+			}
+
+			ctx.JSON(http.StatusServiceUnavailable, response)
+			return
+		}
+		checks["migrations"] = fmt.Sprintf("up to date: version %d", current)
+	}
+
 	response := HealthResponse{
 		Status:    "ready",
 		Timestamp: time.Now().UTC(),
@@ -81,3 +115,10 @@ func (h *HealthController) ReadinessProbe(ctx *gin.Context) {
 
 	ctx.JSON(http.StatusOK, response)
 }
+
+// Metrics handles the /metrics endpoint, serving the counters of internal/metrics to Prometheus
+func (h *HealthController) Metrics(ctx *gin.Context) {
+	ctx.Header("Content-Type", metrics.ContentType)
+	ctx.Status(http.StatusOK)
+	_ = metrics.WriteText(ctx.Writer)
+}
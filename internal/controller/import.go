@@ -0,0 +1,158 @@
+package controller
+
+import (
+	"bytes"
+	"cruder/internal/auth"
+	"cruder/internal/errors"
+	"cruder/internal/export"
+	"cruder/internal/importer"
+	"cruder/internal/model"
+	"fmt"
+	"io"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+	"github.com/gin-gonic/gin/binding"
+
+	stdErrors "errors"
+)
+
+// maxImportSize bounds an uploaded CSV file. The file is read in full before any row is imported,
+// so an oversized upload is rejected without leaving half of it behind.
+const maxImportSize = 10 << 20
+
+// ImportUsers handles POST /users/import: a CSV file, sent as the body (text/csv) or as the `file`
+// part of a multipart form, is imported row by row. `mode` and `dry_run` are query parameters.
+// The response is the per-row report, as JSON or, with `Accept: text/csv`, as CSV. Rows that fail
+// do not fail the request; only an unusable file does.
+//
+// With `Prefer: respond-async` (RFC 7240) the file is queued as a users.import job instead and the
+// response is 202 with the job; its result is the JSON report.
+func (c *UserController) ImportUsers(ctx *gin.Context) {
+	mode, err := model.ParseImportMode(ctx.Query("mode"))
+	if err != nil {
+		ctx.JSON(http.StatusBadRequest, gin.H{
+			"error":   "Invalid input",
+			"code":    errors.CodeInvalidInput,
+			"message": err.Error(),
+		})
+		return
+	}
+	dryRun, err := strconv.ParseBool(ctx.DefaultQuery("dry_run", "false"))
+	if err != nil {
+		ctx.JSON(http.StatusBadRequest, gin.H{
+			"error":   "Invalid input",
+			"code":    errors.CodeInvalidInput,
+			"message": "dry_run must be true or false",
+		})
+		return
+	}
+
+	var body io.Reader
+	switch ctx.ContentType() {
+	case export.MIMECSV:
+		body = ctx.Request.Body
+	case binding.MIMEMultipartPOSTForm:
+		ctx.Request.Body = http.MaxBytesReader(ctx.Writer, ctx.Request.Body, maxImportSize+(1<<20)) // room for the form framing
+		header, err := ctx.FormFile("file")
+		if err != nil {
+			ctx.JSON(http.StatusBadRequest, gin.H{
+				"error":   "Invalid request",
+				"code":    errors.CodeInvalidInput,
+				"message": fmt.Sprintf("Expected the CSV file in a form field named \"file\": %v", err),
+			})
+			return
+		}
+		if header.Size > maxImportSize {
+			writeImportTooLarge(ctx)
+			return
+		}
+		file, err := header.Open()
+		if err != nil {
+			ctx.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+			return
+		}
+		defer func() { _ = file.Close() }()
+		body = file
+	default:
+		ctx.JSON(http.StatusUnsupportedMediaType, gin.H{
+			"error":   "Unsupported media type",
+			"message": "Send the CSV file as text/csv or as the \"file\" field of multipart/form-data",
+		})
+		return
+	}
+
+	data, err := io.ReadAll(io.LimitReader(body, maxImportSize+1))
+	if err != nil {
+		ctx.JSON(http.StatusBadRequest, gin.H{
+			"error":   "Invalid request",
+			"code":    errors.CodeInvalidInput,
+			"message": fmt.Sprintf("Failed to read CSV file: %v", err),
+		})
+		return
+	}
+	if len(data) > maxImportSize {
+		writeImportTooLarge(ctx)
+		return
+	}
+
+	if preferAsync(ctx) {
+		job, err := c.jobs.InOrganization(organization(ctx)).EnqueueUserImport(model.UserImportParams{Mode: mode, DryRun: dryRun, Actor: auth.ActorFrom(ctx.Request.Context())}, data)
+		if err != nil {
+			ctx.JSON(http.StatusInternalServerError, gin.H{
+				"error":   "Internal server error",
+				"message": fmt.Sprintf("failed to start import: %v", err),
+			})
+			return
+		}
+		ctx.Header("Preference-Applied", "respond-async")
+		writeAccepted(ctx, job)
+		return
+	}
+
+	report, err := importer.New(c.in(ctx)).Import(ctx.Request.Context(), bytes.NewReader(data), importer.Options{Mode: mode, DryRun: dryRun, Actor: auth.ActorFrom(ctx.Request.Context())})
+	if err != nil {
+		if stdErrors.Is(err, errors.ErrInvalidInput) {
+			ctx.JSON(http.StatusBadRequest, gin.H{
+				"error":   "Invalid input",
+				"code":    errors.CodeInvalidInput,
+				"message": err.Error(),
+			})
+			return
+		}
+		ctx.JSON(http.StatusInternalServerError, gin.H{
+			"error":   "Internal server error",
+			"message": fmt.Sprintf("failed to import users: %v", err),
+		})
+		return
+	}
+
+	if ctx.NegotiateFormat(binding.MIMEJSON, export.MIMECSV) == export.MIMECSV {
+		ctx.Header("Content-Type", export.MIMECSV)
+		ctx.Status(http.StatusOK)
+		_ = importer.WriteCSV(ctx.Writer, report)
+		return
+	}
+	ctx.JSON(http.StatusOK, report)
+}
+
+// preferAsync reports whether the client sent `Prefer: respond-async`.
+func preferAsync(ctx *gin.Context) bool {
+	for _, header := range ctx.Request.Header.Values("Prefer") {
+		for _, pref := range strings.Split(header, ",") {
+			if strings.EqualFold(strings.TrimSpace(strings.SplitN(pref, ";", 2)[0]), "respond-async") {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+func writeImportTooLarge(ctx *gin.Context) {
+	ctx.JSON(http.StatusRequestEntityTooLarge, gin.H{
+		"error":   "Request entity too large",
+		"message": fmt.Sprintf("CSV file must be at most %d bytes", maxImportSize),
+	})
+}
@@ -0,0 +1,159 @@
+package controller
+
+import (
+	"cruder/internal/auth"
+	"cruder/internal/errors"
+	"cruder/internal/model"
+	"cruder/internal/service"
+	"fmt"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+
+	stdErrors "errors"
+)
+
+// InvitationController serves invitations and their redemption.
+type InvitationController struct {
+	service service.InvitationService
+}
+
+func NewInvitationController(service service.InvitationService) *InvitationController {
+	return &InvitationController{service: service}
+}
+
+// in returns the invitations of the organization the caller acts in.
+func (c *InvitationController) in(ctx *gin.Context) service.InvitationService {
+	return c.service.InOrganization(organization(ctx))
+}
+
+// writeInvitationError answers a failed request about the invitation with id; action completes "failed to".
+func writeInvitationError(ctx *gin.Context, id uuid.UUID, err error, action string) {
+	switch {
+	case stdErrors.Is(err, errors.ErrInvalidInput), stdErrors.Is(err, errors.ErrInvitationTokenInvalid):
+		ctx.JSON(http.StatusBadRequest, gin.H{
+			"error":   "Invalid input",
+			"code":    errors.Code(err),
+			"message": err.Error(),
+		})
+	case stdErrors.Is(err, errors.ErrInvitationNotFound):
+		ctx.JSON(http.StatusNotFound, gin.H{
+			"error":   "Not found",
+			"code":    errors.CodeInvitationNotFound,
+			"message": fmt.Sprintf("invitation with id '%s' not found", id),
+		})
+	case stdErrors.Is(err, errors.ErrInvitationExists), stdErrors.Is(err, errors.ErrInvitationNotPending):
+		ctx.JSON(http.StatusConflict, gin.H{
+			"error":   "Conflict",
+			"code":    errors.Code(err),
+			"message": err.Error(),
+		})
+	case stdErrors.Is(err, errors.ErrUsernameExists):
+		ctx.JSON(http.StatusConflict, gin.H{
+			"error":   "Conflict",
+			"code":    errors.CodeUsernameExists,
+			"message": "Username already exists",
+		})
+	case stdErrors.Is(err, errors.ErrUsernameReserved):
+		ctx.JSON(http.StatusConflict, gin.H{
+			"error":   "Conflict",
+			"code":    errors.CodeUsernameReserved,
+			"message": "Username is reserved: its previous owner gave it up recently",
+		})
+	case stdErrors.Is(err, errors.ErrEmailExists):
+		ctx.JSON(http.StatusConflict, gin.H{
+			"error":   "Conflict",
+			"code":    errors.CodeEmailExists,
+			"message": "Email already exists",
+		})
+	default:
+		ctx.JSON(http.StatusInternalServerError, gin.H{
+			"error":   "Internal server error",
+			"message": fmt.Sprintf("failed to %s: %v", action, err),
+		})
+	}
+}
+
+// CreateInvitation invites an email address to create an account and mails it the token.
+func (c *InvitationController) CreateInvitation(ctx *gin.Context) {
+	var req model.CreateInvitationRequest
+	if !bindJSON(ctx, &req) {
+		return
+	}
+	req.Actor = auth.ActorFrom(ctx.Request.Context())
+
+	invitation, err := c.in(ctx).Create(&req)
+	if err != nil {
+		writeInvitationError(ctx, uuid.Nil, err, "create invitation")
+		return
+	}
+	ctx.JSON(http.StatusCreated, invitation)
+}
+
+// ListInvitations returns the organization's pending invitations, newest first.
+func (c *InvitationController) ListInvitations(ctx *gin.Context) {
+	invitations, err := c.in(ctx).ListPending()
+	if err != nil {
+		writeInvitationError(ctx, uuid.Nil, err, "list invitations")
+		return
+	}
+	ctx.JSON(http.StatusOK, invitations)
+}
+
+// GetInvitation returns an invitation whatever its status.
+func (c *InvitationController) GetInvitation(ctx *gin.Context) {
+	id, ok := uuidParam(ctx, "id")
+	if !ok {
+		return
+	}
+	invitation, err := c.in(ctx).Get(id)
+	if err != nil {
+		writeInvitationError(ctx, id, err, "retrieve invitation")
+		return
+	}
+	ctx.JSON(http.StatusOK, invitation)
+}
+
+// ResendInvitation mails a new token, invalidating the previous one, and renews the expiry.
+func (c *InvitationController) ResendInvitation(ctx *gin.Context) {
+	id, ok := uuidParam(ctx, "id")
+	if !ok {
+		return
+	}
+	invitation, err := c.in(ctx).Resend(id)
+	if err != nil {
+		writeInvitationError(ctx, id, err, "resend invitation")
+		return
+	}
+	ctx.JSON(http.StatusOK, invitation)
+}
+
+// RevokeInvitation withdraws a pending or expired invitation; the record is kept.
+func (c *InvitationController) RevokeInvitation(ctx *gin.Context) {
+	id, ok := uuidParam(ctx, "id")
+	if !ok {
+		return
+	}
+	invitation, err := c.in(ctx).Revoke(id)
+	if err != nil {
+		writeInvitationError(ctx, id, err, "revoke invitation")
+		return
+	}
+	ctx.JSON(http.StatusOK, invitation)
+}
+
+// RedeemInvitation creates the invited user from the token in the invitation email and the
+// username and full name they chose, and returns the user.
+func (c *InvitationController) RedeemInvitation(ctx *gin.Context) {
+	var req model.RedeemInvitationRequest
+	if !bindJSON(ctx, &req) {
+		return
+	}
+	user, err := c.service.Redeem(&req)
+	if err != nil {
+		writeInvitationError(ctx, uuid.Nil, err, "redeem invitation")
+		return
+	}
+	ctx.JSON(http.StatusCreated, user)
+}
@@ -0,0 +1,163 @@
+package controller
+
+import (
+	"cruder/internal/errors"
+	"cruder/internal/export"
+	"cruder/internal/model"
+	"cruder/internal/service"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+
+	stdErrors "errors"
+)
+
+type JobController struct {
+	service service.JobService
+}
+
+func NewJobController(service service.JobService) *JobController {
+	return &JobController{service: service}
+}
+
+// in returns the jobs of the organization the caller acts in.
+func (c *JobController) in(ctx *gin.Context) service.JobService {
+	return c.service.InOrganization(organization(ctx))
+}
+
+// jobPath is where a job's status is polled, as sent in the Location header.
+func jobPath(id uuid.UUID) string {
+	return "/api/v1/jobs/" + id.String()
+}
+
+// writeAccepted answers a request that started a job: 202 with the job and where to follow it.
+func writeAccepted(ctx *gin.Context, job *model.Job) {
+	ctx.Header("Location", jobPath(job.ID))
+	ctx.JSON(http.StatusAccepted, job)
+}
+
+// jobID parses the :id path parameter, writing a 400 if it is not a UUID.
+func jobID(ctx *gin.Context) (uuid.UUID, bool) {
+	id, err := uuid.Parse(ctx.Param("id"))
+	if err != nil {
+		ctx.JSON(http.StatusBadRequest, gin.H{
+			"error":   "Invalid input",
+			"code":    errors.CodeInvalidInput,
+			"message": "ID must be a valid UUID",
+		})
+		return uuid.Nil, false
+	}
+	return id, true
+}
+
+func writeJobError(ctx *gin.Context, id uuid.UUID, err error) {
+	if stdErrors.Is(err, errors.ErrJobNotFound) {
+		ctx.JSON(http.StatusNotFound, gin.H{
+			"error":   "Not found",
+			"code":    errors.CodeJobNotFound,
+			"message": fmt.Sprintf("job with id '%s' not found", id),
+		})
+		return
+	}
+	ctx.JSON(http.StatusInternalServerError, gin.H{
+		"error":   "Internal server error",
+		"message": fmt.Sprintf("failed to retrieve job with id '%s': %v", id, err),
+	})
+}
+
+// GetJob reports a job's status and progress. Poll it until the status is final.
+func (c *JobController) GetJob(ctx *gin.Context) {
+	id, ok := jobID(ctx)
+	if !ok {
+		return
+	}
+	job, err := c.in(ctx).Get(id)
+	if err != nil {
+		writeJobError(ctx, id, err)
+		return
+	}
+	ctx.JSON(http.StatusOK, job)
+}
+
+// CancelJob cancels a queued job at once and asks a running one to stop; the response shows which.
+// Cancelling a finished job is a 409.
+func (c *JobController) CancelJob(ctx *gin.Context) {
+	id, ok := jobID(ctx)
+	if !ok {
+		return
+	}
+	job, err := c.in(ctx).Cancel(id)
+	if err != nil {
+		if stdErrors.Is(err, errors.ErrJobFinished) {
+			ctx.JSON(http.StatusConflict, gin.H{
+				"error":   "Conflict",
+				"code":    errors.CodeJobFinished,
+				"message": fmt.Sprintf("job already %s", job.Status),
+			})
+			return
+		}
+		writeJobError(ctx, id, err)
+		return
+	}
+	if job.Status == model.JobCancelled {
+		ctx.JSON(http.StatusOK, job)
+		return
+	}
+	ctx.JSON(http.StatusAccepted, job)
+}
+
+// GetJobResult downloads the result of a succeeded job, streamed from storage chunk by chunk.
+func (c *JobController) GetJobResult(ctx *gin.Context) {
+	id, ok := jobID(ctx)
+	if !ok {
+		return
+	}
+	job, err := c.in(ctx).Result(id)
+	if err != nil {
+		if stdErrors.Is(err, errors.ErrJobNotReady) {
+			ctx.JSON(http.StatusConflict, gin.H{
+				"error":   "Conflict",
+				"code":    errors.CodeJobNotReady,
+				"message": fmt.Sprintf("job is %s and has no result to download", job.Status),
+			})
+			return
+		}
+		writeJobError(ctx, id, err)
+		return
+	}
+
+	// Results can be far larger than what fits in the server's WriteTimeout
+	_ = http.NewResponseController(ctx.Writer).SetWriteDeadline(time.Time{})
+	ctx.Header("Content-Type", job.ResultType)
+	if job.ResultType == export.MIMECSV {
+		ctx.Header("Content-Disposition", `attachment; filename="`+job.Kind+"-"+job.ID.String()+`.csv"`)
+	}
+	ctx.Status(http.StatusOK)
+
+	err = c.in(ctx).StreamResult(id, func(chunk []byte) error {
+		_, err := ctx.Writer.Write(chunk)
+		return err
+	})
+	if err != nil && !ctx.Writer.Written() {
+		ctx.Writer.Header().Del("Content-Type")
+		ctx.Writer.Header().Del("Content-Disposition")
+		ctx.JSON(http.StatusInternalServerError, gin.H{
+			"error":   "Internal server error",
+			"message": fmt.Sprintf("failed to read job result: %v", err),
+		})
+		return
+	}
+	if err != nil {
+		if loggerVal, exists := ctx.Get("logger"); exists {
+			logger := loggerVal.(*slog.Logger)
+			logger.Error("Job result download aborted mid-stream",
+				slog.String("job_id", id.String()),
+				slog.String("error", err.Error()))
+		}
+		ctx.Abort()
+	}
+}
@@ -0,0 +1,401 @@
+package controller
+
+import (
+	"bytes"
+	"cruder/internal/errors"
+	"cruder/internal/metrics"
+	"cruder/internal/model"
+	"cruder/internal/oidc"
+	"cruder/internal/service"
+	"fmt"
+	"log/slog"
+	"math"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+
+	stdErrors "errors"
+)
+
+// OAuthController serves the built-in OpenID Connect provider: the protocol endpoints, which
+// answer in the OAuth 2.0 formats, and the admin API for clients and consents.
+type OAuthController struct {
+	service  service.OAuthService
+	failures service.AuthFailureService
+}
+
+// NewOAuthController returns the controller. failures may be nil, in which case wrong passwords
+// at the sign-in page are not limited.
+func NewOAuthController(service service.OAuthService, failures service.AuthFailureService) *OAuthController {
+	return &OAuthController{service: service, failures: failures}
+}
+
+// in returns the clients and users of the organization the caller acts in, for the admin API.
+// The protocol endpoints work in the organization of the client application instead.
+func (c *OAuthController) in(ctx *gin.Context) service.OAuthService {
+	return c.service.InOrganization(organization(ctx))
+}
+
+// Discovery serves the provider metadata.
+func (c *OAuthController) Discovery(ctx *gin.Context) {
+	ctx.Header("Cache-Control", "public, max-age=300")
+	ctx.JSON(http.StatusOK, c.service.Discovery())
+}
+
+// logError logs an error whose details the response does not show.
+func logError(ctx *gin.Context, msg string, err error) {
+	if loggerVal, exists := ctx.Get("logger"); exists {
+		loggerVal.(*slog.Logger).Error(msg, slog.String("error", err.Error()))
+	}
+}
+
+// renderPage writes the sign-in page with status.
+func (c *OAuthController) renderPage(ctx *gin.Context, status int, page *oidc.AuthorizePage) {
+	page.Action = oidc.AuthorizePath
+	var buf bytes.Buffer
+	if err := oidc.RenderAuthorizePage(&buf, page); err != nil {
+		logError(ctx, "Failed to render sign-in page", err)
+		ctx.String(http.StatusInternalServerError, "failed to render the sign-in page")
+		return
+	}
+	// The page holds a password form; it must not be framed by other sites or cached
+	ctx.Header("X-Frame-Options", "DENY")
+	ctx.Header("Content-Security-Policy", "frame-ancestors 'none'")
+	ctx.Header("Cache-Control", "no-store")
+	ctx.Data(status, "text/html; charset=utf-8", buf.Bytes())
+}
+
+// redirect sends the user back to the client's redirect URI with params and the request's state.
+func redirect(ctx *gin.Context, req *model.AuthorizationRequest, params url.Values) {
+	if req.State != "" {
+		params.Set("state", req.State)
+	}
+	target, _ := url.Parse(req.RedirectURI)
+	query := target.Query()
+	for k, v := range params {
+		query[k] = v
+	}
+	target.RawQuery = query.Encode()
+	ctx.Header("Cache-Control", "no-store")
+	ctx.Redirect(http.StatusFound, target.String())
+}
+
+// authorizationFailed reports err from checking or authorizing req: to the client if its
+// redirect URI can be trusted, on the page otherwise.
+func (c *OAuthController) authorizationFailed(ctx *gin.Context, req *model.AuthorizationRequest, err error) {
+	var oe *oidc.Error
+	if !stdErrors.As(err, &oe) {
+		logError(ctx, "Failed to authorize", err)
+		c.renderPage(ctx, http.StatusInternalServerError, &oidc.AuthorizePage{Error: "Something went wrong, please try again later."})
+		return
+	}
+	if !oe.Redirect {
+		c.renderPage(ctx, http.StatusBadRequest, &oidc.AuthorizePage{Error: oe.Description})
+		return
+	}
+	params := url.Values{"error": {oe.Code}}
+	if oe.Description != "" {
+		params.Set("error_description", oe.Description)
+	}
+	redirect(ctx, req, params)
+}
+
+// AuthorizePage shows the sign-in and consent page for an authorization request.
+func (c *OAuthController) AuthorizePage(ctx *gin.Context) {
+	var req model.AuthorizationRequest
+	_ = ctx.ShouldBindQuery(&req)
+
+	client, scopes, err := c.service.CheckAuthorization(&req)
+	if err != nil {
+		c.authorizationFailed(ctx, &req, err)
+		return
+	}
+	c.renderPage(ctx, http.StatusOK, &oidc.AuthorizePage{ClientName: client.Name, Scopes: scopes, Request: &req})
+}
+
+// Authorize handles the sign-in form: it signs the user in, records their consent and sends them
+// back to the client with an authorization code, or with access_denied if they declined.
+func (c *OAuthController) Authorize(ctx *gin.Context) {
+	var req model.AuthorizationRequest
+	_ = ctx.ShouldBind(&req)
+
+	client, scopes, err := c.service.CheckAuthorization(&req)
+	if err != nil {
+		c.authorizationFailed(ctx, &req, err)
+		return
+	}
+	if ctx.PostForm("decision") != "allow" {
+		redirect(ctx, &req, url.Values{"error": {oidc.AccessDenied}, "error_description": {"the user declined"}})
+		return
+	}
+
+	login := strings.TrimSpace(ctx.PostForm("login"))
+	page := &oidc.AuthorizePage{ClientName: client.Name, Scopes: scopes, Request: &req, Login: login}
+	ip := ctx.ClientIP()
+	if c.failures != nil {
+		until, err := c.failures.Check(ip)
+		if err != nil {
+			c.authorizationFailed(ctx, &req, err)
+			return
+		}
+		if !until.IsZero() {
+			c.throttled(ctx, page, until)
+			return
+		}
+	}
+
+	code, err := c.service.Authorize(&req, login, ctx.PostForm("password"))
+	if stdErrors.Is(err, errors.ErrInvalidCredentials) {
+		metrics.AuthFailures.Inc(metrics.ReasonInvalidPassword)
+		if c.failures != nil {
+			until, err := c.failures.FailedLogin(ip)
+			if err != nil {
+				c.authorizationFailed(ctx, &req, err)
+				return
+			}
+			if !until.IsZero() {
+				c.throttled(ctx, page, until)
+				return
+			}
+		}
+		page.Error = "Wrong username, email or password."
+		c.renderPage(ctx, http.StatusUnauthorized, page)
+		return
+	}
+	if stdErrors.Is(err, errors.ErrUserNotActive) {
+		page.Error = "This account is not active."
+		c.renderPage(ctx, http.StatusForbidden, page)
+		return
+	}
+	if err != nil {
+		c.authorizationFailed(ctx, &req, err)
+		return
+	}
+	redirect(ctx, &req, url.Values{"code": {code}})
+}
+
+// throttled shows the page with a 429 while the client must wait until until.
+func (c *OAuthController) throttled(ctx *gin.Context, page *oidc.AuthorizePage, until time.Time) {
+	metrics.AuthFailures.Inc(metrics.ReasonThrottled)
+	wait := math.Ceil(time.Until(until).Seconds())
+	ctx.Header("Retry-After", strconv.Itoa(max(int(wait), 1)))
+	page.Error = "Too many failed sign-in attempts, try again later."
+	c.renderPage(ctx, http.StatusTooManyRequests, page)
+}
+
+// oauthError writes err in the OAuth 2.0 error format (RFC 6749 §5.2).
+func oauthError(ctx *gin.Context, err error, what string) {
+	var oe *oidc.Error
+	if !stdErrors.As(err, &oe) {
+		logError(ctx, "Failed to "+what, err)
+		ctx.JSON(http.StatusInternalServerError, &oidc.Error{Code: "server_error", Description: "failed to " + what})
+		return
+	}
+	status := http.StatusBadRequest
+	switch oe.Code {
+	case oidc.InvalidClient:
+		status = http.StatusUnauthorized
+		ctx.Header("WWW-Authenticate", `Basic realm="cruder"`)
+	case oidc.InvalidToken:
+		status = http.StatusUnauthorized
+		ctx.Header("WWW-Authenticate", fmt.Sprintf("Bearer error=%q", oidc.InvalidToken))
+	}
+	ctx.JSON(status, oe)
+}
+
+// Token exchanges an authorization code for an access token and an ID token. Clients authenticate
+// with HTTP Basic or client_id and client_secret in the form; public clients send only client_id.
+func (c *OAuthController) Token(ctx *gin.Context) {
+	ctx.Header("Cache-Control", "no-store")
+	ctx.Header("Pragma", "no-cache")
+
+	var req model.TokenRequest
+	if err := ctx.ShouldBind(&req); err != nil {
+		oauthError(ctx, oidc.Errorf(oidc.InvalidRequest, "the request must be form-encoded"), "issue tokens")
+		return
+	}
+	if id, secret, ok := ctx.Request.BasicAuth(); ok {
+		// RFC 6749 §2.3.1: the credentials are form-encoded before going into the header
+		id, _ = url.QueryUnescape(id)
+		secret, _ = url.QueryUnescape(secret)
+		if req.ClientID != "" && req.ClientID != id {
+			oauthError(ctx, oidc.Errorf(oidc.InvalidRequest, "client_id does not match the Authorization header"), "issue tokens")
+			return
+		}
+		req.ClientID, req.ClientSecret = id, secret
+	}
+
+	tokens, err := c.service.Exchange(&req)
+	if err != nil {
+		oauthError(ctx, err, "issue tokens")
+		return
+	}
+	ctx.JSON(http.StatusOK, tokens)
+}
+
+// UserInfo returns the claims about the user an access token from Token was issued for.
+func (c *OAuthController) UserInfo(ctx *gin.Context) {
+	bearer, ok := strings.CutPrefix(ctx.GetHeader("Authorization"), "Bearer ")
+	if !ok || strings.TrimSpace(bearer) == "" {
+		ctx.Header("WWW-Authenticate", `Bearer realm="cruder"`)
+		ctx.JSON(http.StatusUnauthorized, oidc.Errorf(oidc.InvalidRequest, "a Bearer access token is required"))
+		return
+	}
+
+	info, err := c.service.UserInfo(strings.TrimSpace(bearer))
+	if err != nil {
+		oauthError(ctx, err, "load user info")
+		return
+	}
+	ctx.Header("Cache-Control", "no-store")
+	ctx.JSON(http.StatusOK, info)
+}
+
+// RegisterClient registers a client application. The response holds the client's secret, which is
+// not shown again.
+func (c *OAuthController) RegisterClient(ctx *gin.Context) {
+	var req model.RegisterOAuthClientRequest
+	if !bindJSON(ctx, &req) {
+		return
+	}
+
+	client, err := c.in(ctx).RegisterClient(&req)
+	if err != nil {
+		if stdErrors.Is(err, errors.ErrInvalidInput) {
+			ctx.JSON(http.StatusBadRequest, gin.H{
+				"error":   "Invalid input",
+				"code":    errors.CodeInvalidInput,
+				"message": err.Error(),
+			})
+			return
+		}
+		ctx.JSON(http.StatusInternalServerError, gin.H{
+			"error":   "Internal server error",
+			"message": "failed to register client",
+		})
+		return
+	}
+	ctx.Header("Cache-Control", "no-store")
+	ctx.JSON(http.StatusCreated, client)
+}
+
+// ListClients returns every registered client application.
+func (c *OAuthController) ListClients(ctx *gin.Context) {
+	clients, err := c.in(ctx).ListClients()
+	if err != nil {
+		ctx.JSON(http.StatusInternalServerError, gin.H{
+			"error":   "Internal server error",
+			"message": "failed to list clients",
+		})
+		return
+	}
+	ctx.JSON(http.StatusOK, clients)
+}
+
+// GetClient returns one client application.
+func (c *OAuthController) GetClient(ctx *gin.Context) {
+	id := ctx.Param("id")
+	client, err := c.in(ctx).GetClient(id)
+	switch {
+	case err == nil:
+		ctx.JSON(http.StatusOK, client)
+	case stdErrors.Is(err, errors.ErrOAuthClientNotFound):
+		ctx.JSON(http.StatusNotFound, gin.H{
+			"error":   "Not found",
+			"code":    errors.CodeOAuthClientNotFound,
+			"message": fmt.Sprintf("client with id '%s' not found", id),
+		})
+	default:
+		ctx.JSON(http.StatusInternalServerError, gin.H{
+			"error":   "Internal server error",
+			"message": "failed to get client",
+		})
+	}
+}
+
+// DeleteClient deletes a client application with the consents users gave it.
+func (c *OAuthController) DeleteClient(ctx *gin.Context) {
+	id := ctx.Param("id")
+	err := c.in(ctx).DeleteClient(id)
+	switch {
+	case err == nil:
+		ctx.Status(http.StatusNoContent)
+	case stdErrors.Is(err, errors.ErrOAuthClientNotFound):
+		ctx.JSON(http.StatusNotFound, gin.H{
+			"error":   "Not found",
+			"code":    errors.CodeOAuthClientNotFound,
+			"message": fmt.Sprintf("client with id '%s' not found", id),
+		})
+	default:
+		ctx.JSON(http.StatusInternalServerError, gin.H{
+			"error":   "Internal server error",
+			"message": "failed to delete client",
+		})
+	}
+}
+
+// ListConsents returns the client applications a user allowed access.
+func (c *OAuthController) ListConsents(ctx *gin.Context) {
+	id, err := uuid.Parse(ctx.Param("id"))
+	if err != nil {
+		ctx.JSON(http.StatusBadRequest, gin.H{
+			"error":   "Invalid input",
+			"code":    errors.CodeInvalidInput,
+			"message": "ID must be a valid UUID",
+		})
+		return
+	}
+
+	consents, err := c.in(ctx).Consents(id)
+	switch {
+	case err == nil:
+		ctx.JSON(http.StatusOK, consents)
+	case stdErrors.Is(err, errors.ErrUserNotFound):
+		ctx.JSON(http.StatusNotFound, gin.H{
+			"error":   "Not found",
+			"code":    errors.CodeUserNotFound,
+			"message": fmt.Sprintf("user with id '%s' not found", id),
+		})
+	default:
+		ctx.JSON(http.StatusInternalServerError, gin.H{
+			"error":   "Internal server error",
+			"message": "failed to list consents",
+		})
+	}
+}
+
+// RevokeConsent withdraws a user's consent to a client application.
+func (c *OAuthController) RevokeConsent(ctx *gin.Context) {
+	id, err := uuid.Parse(ctx.Param("id"))
+	if err != nil {
+		ctx.JSON(http.StatusBadRequest, gin.H{
+			"error":   "Invalid input",
+			"code":    errors.CodeInvalidInput,
+			"message": "ID must be a valid UUID",
+		})
+		return
+	}
+	clientID := ctx.Param("client_id")
+
+	err = c.in(ctx).RevokeConsent(id, clientID)
+	switch {
+	case err == nil:
+		ctx.Status(http.StatusNoContent)
+	case stdErrors.Is(err, errors.ErrConsentNotFound):
+		ctx.JSON(http.StatusNotFound, gin.H{
+			"error":   "Not found",
+			"code":    errors.CodeConsentNotFound,
+			"message": fmt.Sprintf("user '%s' has not consented to client '%s'", id, clientID),
+		})
+	default:
+		ctx.JSON(http.StatusInternalServerError, gin.H{
+			"error":   "Internal server error",
+			"message": "failed to revoke consent",
+		})
+	}
+}
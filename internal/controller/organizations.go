@@ -0,0 +1,97 @@
+package controller
+
+import (
+	"cruder/internal/errors"
+	"cruder/internal/model"
+	"cruder/internal/service"
+	"fmt"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+
+	stdErrors "errors"
+)
+
+// OrganizationController serves the admin API for the tenants users belong to.
+type OrganizationController struct {
+	service service.OrganizationService
+}
+
+func NewOrganizationController(service service.OrganizationService) *OrganizationController {
+	return &OrganizationController{service: service}
+}
+
+// CreateOrganization adds an organization.
+func (c *OrganizationController) CreateOrganization(ctx *gin.Context) {
+	var req model.CreateOrganizationRequest
+	if !bindJSON(ctx, &req) {
+		return
+	}
+
+	org, err := c.service.Create(&req)
+	switch {
+	case err == nil:
+		ctx.JSON(http.StatusCreated, org)
+	case stdErrors.Is(err, errors.ErrInvalidInput):
+		ctx.JSON(http.StatusBadRequest, gin.H{
+			"error":   "Invalid input",
+			"code":    errors.CodeInvalidInput,
+			"message": err.Error(),
+		})
+	case stdErrors.Is(err, errors.ErrOrganizationExists):
+		ctx.JSON(http.StatusConflict, gin.H{
+			"error":   "Conflict",
+			"code":    errors.CodeOrganizationExists,
+			"message": fmt.Sprintf("organization '%s' already exists", req.Slug),
+		})
+	default:
+		ctx.JSON(http.StatusInternalServerError, gin.H{
+			"error":   "Internal server error",
+			"message": "failed to create organization",
+		})
+	}
+}
+
+// ListOrganizations returns every organization.
+func (c *OrganizationController) ListOrganizations(ctx *gin.Context) {
+	orgs, err := c.service.List()
+	if err != nil {
+		ctx.JSON(http.StatusInternalServerError, gin.H{
+			"error":   "Internal server error",
+			"message": "failed to list organizations",
+		})
+		return
+	}
+	ctx.JSON(http.StatusOK, orgs)
+}
+
+// GetOrganization returns one organization.
+func (c *OrganizationController) GetOrganization(ctx *gin.Context) {
+	id, err := uuid.Parse(ctx.Param("id"))
+	if err != nil {
+		ctx.JSON(http.StatusBadRequest, gin.H{
+			"error":   "Invalid input",
+			"code":    errors.CodeInvalidInput,
+			"message": "ID must be a valid UUID",
+		})
+		return
+	}
+
+	org, err := c.service.Get(id)
+	switch {
+	case err == nil:
+		ctx.JSON(http.StatusOK, org)
+	case stdErrors.Is(err, errors.ErrOrganizationNotFound):
+		ctx.JSON(http.StatusNotFound, gin.H{
+			"error":   "Not found",
+			"code":    errors.CodeOrganizationNotFound,
+			"message": fmt.Sprintf("organization with id '%s' not found", id),
+		})
+	default:
+		ctx.JSON(http.StatusInternalServerError, gin.H{
+			"error":   "Internal server error",
+			"message": "failed to get organization",
+		})
+	}
+}
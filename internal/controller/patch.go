@@ -0,0 +1,170 @@
+package controller
+
+import (
+	"bytes"
+	"cruder/internal/auth"
+	"cruder/internal/errors"
+	"cruder/internal/model"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"time"
+
+	jsonpatch "github.com/evanphx/json-patch/v5"
+	"github.com/gin-gonic/gin"
+	"github.com/gin-gonic/gin/binding"
+	"github.com/go-playground/validator/v10"
+	"github.com/google/uuid"
+
+	stdErrors "errors"
+)
+
+// Patch media types accepted by PATCH besides plain JSON
+const (
+	MIMEMergePatch = "application/merge-patch+json" // RFC 7396
+	MIMEJSONPatch  = "application/json-patch+json"  // RFC 6902
+)
+
+// acceptPatch is advertised in the Accept-Patch header (RFC 5789) when a type is rejected
+const acceptPatch = binding.MIMEJSON + ", " + MIMEMergePatch + ", " + MIMEJSONPatch
+
+// Patch documents are a handful of operations on a small resource
+const maxPatchSize = 64 << 10
+
+// errPatchMalformed marks a patch document that could not be parsed at all,
+// as opposed to one that parsed but could not be applied to this user.
+var errPatchMalformed = stdErrors.New("malformed patch document")
+
+type patchFunc func(doc, patch []byte) ([]byte, error)
+
+func applyMergePatch(doc, patch []byte) ([]byte, error) {
+	if !json.Valid(patch) {
+		return nil, errPatchMalformed
+	}
+	return jsonpatch.MergePatch(doc, patch)
+}
+
+func applyJSONPatch(doc, patch []byte) ([]byte, error) {
+	ops, err := jsonpatch.DecodePatch(patch)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", errPatchMalformed, err)
+	}
+	return ops.Apply(doc)
+}
+
+// patchedUser is a user document after patching. Unknown members are rejected when decoding it.
+type patchedUser struct {
+	ID             uuid.UUID `json:"id"`
+	OrganizationID uuid.UUID `json:"organization_id"`
+	Username       string    `json:"username"`
+	Email          string    `json:"email"`
+	FullName       string    `json:"full_name"`
+	// Status is read-only: it only changes through the transition endpoints
+	Status    model.UserStatus `json:"status"`
+	CreatedAt time.Time        `json:"created_at"`
+	UpdatedAt time.Time        `json:"updated_at"`
+	// EmailVerifiedAt is read-only too; an email change resets it
+	EmailVerifiedAt *time.Time `json:"email_verified_at"`
+}
+
+// patchUser applies a patch document to the user's current JSON representation (as GET returns it)
+// and stores the result as a full replacement, so the outcome obeys the same rules as PUT.
+// id, organization_id, status, created_at, updated_at and email_verified_at may be tested but not changed.
+func (c *UserController) patchUser(ctx *gin.Context, id uuid.UUID, apply patchFunc) {
+	patch, err := io.ReadAll(io.LimitReader(ctx.Request.Body, maxPatchSize+1))
+	if err != nil || len(patch) > maxPatchSize {
+		ctx.JSON(http.StatusBadRequest, gin.H{
+			"error":   "Invalid request",
+			"code":    errors.CodeInvalidInput,
+			"message": fmt.Sprintf("Patch document must be readable and at most %d bytes", maxPatchSize),
+		})
+		return
+	}
+
+	current, err := c.in(ctx).GetByID(id, nil)
+	if err != nil {
+		writeUpdateError(ctx, id, err)
+		return
+	}
+	doc, err := json.Marshal(current)
+	if err != nil {
+		writeUpdateError(ctx, id, err)
+		return
+	}
+
+	patched, err := apply(doc, patch)
+	if err != nil {
+		switch {
+		case stdErrors.Is(err, errPatchMalformed):
+			ctx.JSON(http.StatusBadRequest, gin.H{
+				"error":   "Invalid request",
+				"code":    errors.CodeInvalidInput,
+				"message": fmt.Sprintf("Failed to parse patch document: %v", err),
+			})
+		case stdErrors.Is(err, jsonpatch.ErrTestFailed):
+			ctx.JSON(http.StatusConflict, gin.H{
+				"error":   "Conflict",
+				"message": err.Error(),
+			})
+		default:
+			ctx.JSON(http.StatusUnprocessableEntity, gin.H{
+				"error":   "Unprocessable entity",
+				"message": fmt.Sprintf("Patch cannot be applied: %v", err),
+			})
+		}
+		return
+	}
+
+	var result patchedUser
+	dec := json.NewDecoder(bytes.NewReader(patched))
+	dec.DisallowUnknownFields()
+	if err := dec.Decode(&result); err != nil {
+		ctx.JSON(http.StatusUnprocessableEntity, gin.H{
+			"error":   "Unprocessable entity",
+			"message": fmt.Sprintf("Patched user is not a valid user: %v", err),
+		})
+		return
+	}
+	if result.ID != current.ID || result.OrganizationID != current.OrganizationID || result.Status != current.Status ||
+		!result.CreatedAt.Equal(current.CreatedAt) || !result.UpdatedAt.Equal(current.UpdatedAt) || !sameTime(result.EmailVerifiedAt, current.EmailVerifiedAt) {
+		ctx.JSON(http.StatusUnprocessableEntity, gin.H{
+			"error":   "Unprocessable entity",
+			"message": "id, organization_id, status, created_at, updated_at and email_verified_at are read-only",
+		})
+		return
+	}
+
+	req := model.ReplaceUserRequest{Username: result.Username, Email: result.Email, FullName: result.FullName,
+		Actor: auth.ActorFrom(ctx.Request.Context())}
+	if err := binding.Validator.ValidateStruct(&req); err != nil {
+		var ve validator.ValidationErrors
+		if stdErrors.As(err, &ve) {
+			ctx.JSON(http.StatusBadRequest, gin.H{
+				"error":   "Validation failed",
+				"code":    errors.CodeInvalidInput,
+				"message": "Invalid input data",
+				"details": formatValidationErrors(ve),
+			})
+			return
+		}
+		writeUpdateError(ctx, id, fmt.Errorf("%w: %v", errors.ErrInvalidInput, err))
+		return
+	}
+
+	user, err := c.in(ctx).Replace(id, &req)
+	if err != nil {
+		writeUpdateError(ctx, id, err)
+		return
+	}
+
+	ctx.JSON(http.StatusOK, user)
+}
+
+// sameTime reports whether two optional timestamps are both unset or the same instant.
+func sameTime(a, b *time.Time) bool {
+	if a == nil || b == nil {
+		return a == b
+	}
+	return a.Equal(*b)
+}
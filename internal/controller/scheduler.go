@@ -0,0 +1,56 @@
+package controller
+
+import (
+	"cruder/internal/errors"
+	"cruder/internal/service"
+	"fmt"
+	"net/http"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+
+	stdErrors "errors"
+)
+
+type SchedulerController struct {
+	service service.TaskRunService
+}
+
+func NewSchedulerController(service service.TaskRunService) *SchedulerController {
+	return &SchedulerController{service: service}
+}
+
+// ListRuns returns the latest scheduled task runs across all replicas, newest first,
+// optionally of one `task` only.
+func (c *SchedulerController) ListRuns(ctx *gin.Context) {
+	limit := 0
+	if limitStr, ok := ctx.GetQuery("limit"); ok {
+		var err error
+		if limit, err = strconv.Atoi(limitStr); err != nil {
+			ctx.JSON(http.StatusBadRequest, gin.H{
+				"error":   "Invalid input",
+				"code":    errors.CodeInvalidInput,
+				"message": "limit must be an integer",
+			})
+			return
+		}
+	}
+
+	runs, err := c.service.List(ctx.Query("task"), limit)
+	if err != nil {
+		if stdErrors.Is(err, errors.ErrInvalidInput) {
+			ctx.JSON(http.StatusBadRequest, gin.H{
+				"error":   "Invalid input",
+				"code":    errors.CodeInvalidInput,
+				"message": err.Error(),
+			})
+			return
+		}
+		ctx.JSON(http.StatusInternalServerError, gin.H{
+			"error":   "Internal server error",
+			"message": fmt.Sprintf("failed to list task runs: %v", err),
+		})
+		return
+	}
+	ctx.JSON(http.StatusOK, runs)
+}
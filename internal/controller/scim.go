@@ -0,0 +1,337 @@
+package controller
+
+import (
+	"cruder/internal/auth"
+	"cruder/internal/errors"
+	"cruder/internal/model"
+	"cruder/internal/scim"
+	"cruder/internal/service"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+	"github.com/gin-gonic/gin/binding"
+	"github.com/go-playground/validator/v10"
+	"github.com/google/uuid"
+
+	stdErrors "errors"
+)
+
+// SCIMController serves the SCIM 2.0 endpoint (RFC 7644) for identity providers that provision
+// users. Users are stored through the UserService, so SCIM writes obey the same validation,
+// uniqueness and email confirmation rules as the REST API.
+type SCIMController struct {
+	service service.UserService
+}
+
+func NewSCIMController(service service.UserService) *SCIMController {
+	return &SCIMController{service: service}
+}
+
+// in returns the users of the organization the caller acts in.
+func (c *SCIMController) in(ctx *gin.Context) service.UserService {
+	return c.service.InOrganization(organization(ctx))
+}
+
+// respondSCIM writes v as application/scim+json.
+func respondSCIM(ctx *gin.Context, status int, v any) {
+	ctx.Header("Content-Type", scim.MediaType)
+	ctx.JSON(status, v)
+}
+
+// writeSCIMError maps errors to SCIM error responses (RFC 7644 §3.12).
+func writeSCIMError(ctx *gin.Context, err error) {
+	var se *scim.Error
+	switch {
+	case stdErrors.As(err, &se):
+	case stdErrors.Is(err, errors.ErrUserNotFound):
+		se = scim.Errorf(http.StatusNotFound, "", "user not found")
+	case stdErrors.Is(err, errors.ErrUsernameExists):
+		se = scim.Errorf(http.StatusConflict, scim.Uniqueness, "userName already exists")
+	case stdErrors.Is(err, errors.ErrUsernameReserved):
+		se = scim.Errorf(http.StatusConflict, scim.Uniqueness, "userName is reserved: its previous owner gave it up recently")
+	case stdErrors.Is(err, errors.ErrEmailExists):
+		se = scim.Errorf(http.StatusConflict, scim.Uniqueness, "email already exists")
+	case stdErrors.Is(err, errors.ErrInvalidInput):
+		se = scim.Errorf(http.StatusBadRequest, scim.InvalidValue, "%v", err)
+	default:
+		logError(ctx, "SCIM request failed", err)
+		se = scim.Errorf(http.StatusInternalServerError, "", "internal server error")
+	}
+	respondSCIM(ctx, se.StatusCode(), se)
+}
+
+// scimFields names the SCIM attribute behind each field of model.CreateUserRequest in
+// validation errors.
+var scimFields = map[string]string{
+	"Username": "userName",
+	"Email":    "emails.value",
+	"FullName": "name.formatted",
+}
+
+// validateSCIM checks req under the binding rules of the REST API, reporting failures as invalidValue.
+func validateSCIM(req *model.CreateUserRequest) error {
+	err := binding.Validator.ValidateStruct(req)
+	if err == nil {
+		return nil
+	}
+	var ve validator.ValidationErrors
+	if !stdErrors.As(err, &ve) {
+		return scim.Errorf(http.StatusBadRequest, scim.InvalidValue, "%v", err)
+	}
+	details := formatValidationErrors(ve)
+	msgs := make([]string, 0, len(details))
+	for _, fe := range ve {
+		msgs = append(msgs, scimFields[fe.Field()]+": "+details[fe.Field()])
+	}
+	return scim.Errorf(http.StatusBadRequest, scim.InvalidValue, "%s", strings.Join(msgs, "; "))
+}
+
+// bindSCIM decodes the request body into v.
+func bindSCIM(ctx *gin.Context, v any) bool {
+	if err := ctx.ShouldBindJSON(v); err != nil {
+		writeSCIMError(ctx, scim.Errorf(http.StatusBadRequest, scim.InvalidSyntax, "failed to parse request body: %v", err))
+		return false
+	}
+	return true
+}
+
+// scimUserID parses the id path parameter; an id that is not a UUID names no user.
+func scimUserID(ctx *gin.Context) (uuid.UUID, bool) {
+	id, err := uuid.Parse(ctx.Param("id"))
+	if err != nil {
+		writeSCIMError(ctx, errors.ErrUserNotFound)
+		return uuid.Nil, false
+	}
+	return id, true
+}
+
+// scimQueryInt reads an integer query parameter, def when absent.
+func scimQueryInt(ctx *gin.Context, name string, def int) (int, error) {
+	s := ctx.Query(name)
+	if s == "" {
+		return def, nil
+	}
+	n, err := strconv.Atoi(s)
+	if err != nil {
+		return 0, scim.Errorf(http.StatusBadRequest, scim.InvalidValue, "%s must be an integer", name)
+	}
+	return n, nil
+}
+
+// ListUsers handles GET /scim/v2/Users: an optional filter and a page given by the 1-based
+// startIndex and count (RFC 7644 §3.4.2.4). A startIndex below 1 counts as 1 and count is capped
+// at scim.MaxResults. userName eq "..." is a lookup; any other filter scans all users.
+func (c *SCIMController) ListUsers(ctx *gin.Context) {
+	startIndex, err := scimQueryInt(ctx, "startIndex", 1)
+	if err != nil {
+		writeSCIMError(ctx, err)
+		return
+	}
+	count, err := scimQueryInt(ctx, "count", scim.DefaultCount)
+	if err != nil {
+		writeSCIMError(ctx, err)
+		return
+	}
+	startIndex = max(startIndex, 1)
+	count = min(max(count, 0), scim.MaxResults)
+
+	var filter *scim.Filter
+	if s := ctx.Query("filter"); s != "" {
+		if filter, err = scim.ParseFilter(s); err != nil {
+			writeSCIMError(ctx, err)
+			return
+		}
+	}
+
+	total := 0
+	page := []*scim.User{}
+	add := func(user *model.User) error {
+		resource := scim.FromUser(user)
+		if filter != nil && !filter.Matches(resource) {
+			return nil
+		}
+		total++
+		if total >= startIndex && len(page) < count {
+			page = append(page, resource)
+		}
+		return nil
+	}
+
+	if username, ok := scimFilterUserName(filter); ok {
+		user, err := c.in(ctx).GetByUsername(username, nil)
+		switch {
+		case err == nil:
+			err = add(user)
+		case stdErrors.Is(err, errors.ErrUserNotFound):
+			err = nil
+		}
+		if err != nil {
+			writeSCIMError(ctx, err)
+			return
+		}
+	} else if err := c.in(ctx).StreamAll(nil, add); err != nil {
+		writeSCIMError(ctx, err)
+		return
+	}
+
+	respondSCIM(ctx, http.StatusOK, scim.NewListResponse(total, startIndex, page))
+}
+
+func scimFilterUserName(filter *scim.Filter) (string, bool) {
+	if filter == nil {
+		return "", false
+	}
+	return filter.UserName()
+}
+
+// GetUser handles GET /scim/v2/Users/:id.
+func (c *SCIMController) GetUser(ctx *gin.Context) {
+	id, ok := scimUserID(ctx)
+	if !ok {
+		return
+	}
+	user, err := c.in(ctx).GetByID(id, nil)
+	if err != nil {
+		writeSCIMError(ctx, err)
+		return
+	}
+	respondSCIM(ctx, http.StatusOK, scim.FromUser(user))
+}
+
+// CreateUser handles POST /scim/v2/Users.
+func (c *SCIMController) CreateUser(ctx *gin.Context) {
+	var resource scim.User
+	if !bindSCIM(ctx, &resource) {
+		return
+	}
+	req, err := resource.ToRequest()
+	if err != nil {
+		writeSCIMError(ctx, err)
+		return
+	}
+	if err := validateSCIM(req); err != nil {
+		writeSCIMError(ctx, err)
+		return
+	}
+
+	user, err := c.in(ctx).Create(req)
+	if err != nil {
+		writeSCIMError(ctx, err)
+		return
+	}
+	created := scim.FromUser(user)
+	ctx.Header("Location", created.Meta.Location)
+	respondSCIM(ctx, http.StatusCreated, created)
+}
+
+// ReplaceUser handles PUT /scim/v2/Users/:id. As with PUT /api/v1/users/id/:id, a new email
+// address waits for the user to confirm it, so the response still shows the old one.
+func (c *SCIMController) ReplaceUser(ctx *gin.Context) {
+	id, ok := scimUserID(ctx)
+	if !ok {
+		return
+	}
+	var resource scim.User
+	if !bindSCIM(ctx, &resource) {
+		return
+	}
+	if resource.ID != "" && resource.ID != id.String() {
+		writeSCIMError(ctx, scim.Errorf(http.StatusBadRequest, scim.Mutability, "id is read-only"))
+		return
+	}
+	c.replace(ctx, id, &resource)
+}
+
+// PatchUser handles PATCH /scim/v2/Users/:id (RFC 7644 §3.5.2). The operations apply to the
+// user's current resource, and the result is stored like a PUT.
+func (c *SCIMController) PatchUser(ctx *gin.Context) {
+	id, ok := scimUserID(ctx)
+	if !ok {
+		return
+	}
+	var req scim.PatchRequest
+	if !bindSCIM(ctx, &req) {
+		return
+	}
+
+	current, err := c.in(ctx).GetByID(id, nil)
+	if err != nil {
+		writeSCIMError(ctx, err)
+		return
+	}
+	patched, err := req.Apply(scim.FromUser(current))
+	if err != nil {
+		writeSCIMError(ctx, err)
+		return
+	}
+	c.replace(ctx, id, patched)
+}
+
+func (c *SCIMController) replace(ctx *gin.Context, id uuid.UUID, resource *scim.User) {
+	req, err := resource.ToRequest()
+	if err != nil {
+		writeSCIMError(ctx, err)
+		return
+	}
+	req.Actor = auth.ActorFrom(ctx.Request.Context())
+	if err := validateSCIM(req); err != nil {
+		writeSCIMError(ctx, err)
+		return
+	}
+
+	user, err := c.in(ctx).Replace(id, req)
+	if err != nil {
+		writeSCIMError(ctx, err)
+		return
+	}
+	respondSCIM(ctx, http.StatusOK, scim.FromUser(user))
+}
+
+// DeleteUser handles DELETE /scim/v2/Users/:id. Unlike DELETE /api/v1/users/id/:id it is not
+// idempotent: RFC 7644 §3.6 asks for 404 when the user does not exist.
+func (c *SCIMController) DeleteUser(ctx *gin.Context) {
+	id, ok := scimUserID(ctx)
+	if !ok {
+		return
+	}
+	if err := c.in(ctx).Delete(id, auth.ActorFrom(ctx.Request.Context())); err != nil {
+		writeSCIMError(ctx, err)
+		return
+	}
+	ctx.Status(http.StatusNoContent)
+}
+
+// ServiceProviderConfig handles GET /scim/v2/ServiceProviderConfig.
+func (c *SCIMController) ServiceProviderConfig(ctx *gin.Context) {
+	respondSCIM(ctx, http.StatusOK, scim.Config())
+}
+
+// ListSchemas handles GET /scim/v2/Schemas.
+func (c *SCIMController) ListSchemas(ctx *gin.Context) {
+	respondSCIM(ctx, http.StatusOK, scim.NewListResponse(1, 1, []*scim.Schema{scim.UserSchemaDefinition()}))
+}
+
+// GetSchema handles GET /scim/v2/Schemas/:id.
+func (c *SCIMController) GetSchema(ctx *gin.Context) {
+	if ctx.Param("id") != scim.UserSchema {
+		writeSCIMError(ctx, scim.Errorf(http.StatusNotFound, "", "schema %s not found", ctx.Param("id")))
+		return
+	}
+	respondSCIM(ctx, http.StatusOK, scim.UserSchemaDefinition())
+}
+
+// ListResourceTypes handles GET /scim/v2/ResourceTypes.
+func (c *SCIMController) ListResourceTypes(ctx *gin.Context) {
+	respondSCIM(ctx, http.StatusOK, scim.NewListResponse(1, 1, []*scim.ResourceType{scim.UserResourceType()}))
+}
+
+// GetResourceType handles GET /scim/v2/ResourceTypes/:id.
+func (c *SCIMController) GetResourceType(ctx *gin.Context) {
+	if ctx.Param("id") != "User" {
+		writeSCIMError(ctx, scim.Errorf(http.StatusNotFound, "", "resource type %s not found", ctx.Param("id")))
+		return
+	}
+	respondSCIM(ctx, http.StatusOK, scim.UserResourceType())
+}
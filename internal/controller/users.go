@@ -1,14 +1,20 @@
 package controller
 
 import (
+	"cruder/internal/auth"
 	"cruder/internal/errors"
 	"cruder/internal/model"
 	"cruder/internal/service"
 	"fmt"
 	"log/slog"
 	"net/http"
+	"net/url"
+	"slices"
+	"strconv"
+	"strings"
 
 	"github.com/gin-gonic/gin"
+	"github.com/gin-gonic/gin/binding"
 	"github.com/go-playground/validator/v10"
 	"github.com/google/uuid"
 
@@ -17,10 +23,19 @@ import (
 
 type UserController struct {
 	service service.UserService
+	// jobs runs the asynchronous variants of exports and imports
+	jobs service.JobService
+	// emailChanges reports pending email changes on GetUserByID; nil leaves them out
+	emailChanges service.EmailChangeService
 }
 
-func NewUserController(service service.UserService) *UserController {
-	return &UserController{service: service}
+func NewUserController(service service.UserService, jobs service.JobService, emailChanges service.EmailChangeService) *UserController {
+	return &UserController{service: service, jobs: jobs, emailChanges: emailChanges}
+}
+
+// in returns the users of the organization the caller acts in.
+func (c *UserController) in(ctx *gin.Context) service.UserService {
+	return c.service.InOrganization(organization(ctx))
 }
 
 // formatValidationErrors converts validator.ValidationErrors to a map of user-friendly error messages
@@ -45,24 +60,235 @@ func formatValidationErrors(ve validator.ValidationErrors) map[string]string {
 	return validationErrors
 }
 
+// userFields parses the optional `fields` query parameter (a sparse fieldset).
+// On an unknown field name it writes a 400 and returns false.
+func userFields(ctx *gin.Context) (model.UserFields, bool) {
+	fields, err := model.ParseUserFields(ctx.Query("fields"))
+	if err != nil {
+		ctx.JSON(http.StatusBadRequest, gin.H{
+			"error":   "Invalid input",
+			"code":    errors.CodeInvalidInput,
+			"message": err.Error(),
+		})
+		return nil, false
+	}
+	return fields, true
+}
+
+// shapeUser returns what to encode for u: the whole user, or only the selected fields.
+func shapeUser(u *model.User, fields model.UserFields) any {
+	if fields == nil {
+		return u
+	}
+	return fields.Project(u)
+}
+
+func shapeUsers(users []model.User, fields model.UserFields) any {
+	if fields == nil {
+		return users
+	}
+	shaped := make([]map[string]any, len(users))
+	for i := range users {
+		shaped[i] = fields.Project(&users[i])
+	}
+	return shaped
+}
+
+// GetAllUsers returns every user, or a single page when `limit`, `after` and/or `status` are given.
+// Pagination keeps the plain array body for compatibility; the next page is advertised
+// in a `Link: <...>; rel="next"` header whenever the page is full.
+// With an `Accept` of one of the export formats the users are streamed in that format instead.
 func (c *UserController) GetAllUsers(ctx *gin.Context) {
-	users, err := c.service.GetAll()
+	limitStr, hasLimit := ctx.GetQuery("limit")
+	afterStr, hasAfter := ctx.GetQuery("after")
+	statusStr, hasStatus := ctx.GetQuery("status")
+	fields, ok := userFields(ctx)
+	if !ok {
+		return
+	}
+	format := exportFormat(ctx)
+
+	if !hasLimit && !hasAfter && !hasStatus {
+		if format != "" {
+			writeExport(ctx, format, fields, "users", func(fn func(*model.User) error) error {
+				return c.in(ctx).StreamAll(fields, fn)
+			})
+			return
+		}
+
+		users, err := c.in(ctx).GetAll(fields)
+		if err != nil {
+			ctx.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+			return
+		}
+		if users == nil {
+			users = []model.User{}
+		}
+
+		ctx.JSON(http.StatusOK, shapeUsers(users, fields))
+		return
+	}
+
+	params := model.ListUsersParams{Fields: fields}
+	if hasLimit {
+		limit, err := strconv.Atoi(limitStr)
+		if err != nil {
+			ctx.JSON(http.StatusBadRequest, gin.H{
+				"error":   "Invalid input",
+				"code":    errors.CodeInvalidInput,
+				"message": "limit must be an integer",
+			})
+			return
+		}
+		params.Limit = limit
+	}
+	if hasAfter {
+		after, err := uuid.Parse(afterStr)
+		if err != nil {
+			ctx.JSON(http.StatusBadRequest, gin.H{
+				"error":   "Invalid input",
+				"code":    errors.CodeInvalidInput,
+				"message": "after must be a valid UUID",
+			})
+			return
+		}
+		params.After = &after
+	}
+	if hasStatus {
+		status, err := model.ParseUserStatus(statusStr)
+		if err != nil {
+			ctx.JSON(http.StatusBadRequest, gin.H{
+				"error":   "Invalid input",
+				"code":    errors.CodeInvalidInput,
+				"message": err.Error(),
+			})
+			return
+		}
+		params.Status = status
+	}
+
+	users, err := c.in(ctx).List(params)
+	if err != nil {
+		if stdErrors.Is(err, errors.ErrInvalidInput) {
+			ctx.JSON(http.StatusBadRequest, gin.H{
+				"error":   "Invalid input",
+				"code":    errors.CodeInvalidInput,
+				"message": err.Error(),
+			})
+			return
+		}
+		ctx.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	if users == nil {
+		users = []model.User{}
+	}
+
+	pageSize := params.Limit
+	if pageSize == 0 {
+		pageSize = service.DefaultPageSize
+	}
+	if len(users) == pageSize {
+		next := url.Values{}
+		next.Set("limit", strconv.Itoa(pageSize))
+		next.Set("after", users[len(users)-1].ID.String())
+		if fields != nil {
+			next.Set("fields", strings.Join(fields, ","))
+		}
+		if params.Status != "" {
+			next.Set("status", string(params.Status))
+		}
+		ctx.Header("Link", fmt.Sprintf(`<%s?%s>; rel="next"`, ctx.Request.URL.Path, next.Encode()))
+	}
+
+	if format != "" {
+		writeUsers(ctx, format, fields, "users", users)
+		return
+	}
+	ctx.JSON(http.StatusOK, shapeUsers(users, fields))
+}
+
+// SearchUsers returns the users whose username, email or full name contains `q`.
+// Like GetAllUsers it honors `fields` and the export formats in `Accept`.
+func (c *UserController) SearchUsers(ctx *gin.Context) {
+	query := ctx.Query("q")
+	fields, ok := userFields(ctx)
+	if !ok {
+		return
+	}
+
+	if format := exportFormat(ctx); format != "" {
+		// Let a bad query be a 400 rather than an aborted export
+		if strings.TrimSpace(query) == "" {
+			ctx.JSON(http.StatusBadRequest, gin.H{
+				"error":   "Invalid input",
+				"code":    errors.CodeInvalidInput,
+				"message": "q must not be empty",
+			})
+			return
+		}
+		writeExport(ctx, format, fields, "users-search", func(fn func(*model.User) error) error {
+			return c.in(ctx).StreamSearch(query, fields, fn)
+		})
+		return
+	}
+
+	users, err := c.in(ctx).Search(query, fields)
 	if err != nil {
+		if stdErrors.Is(err, errors.ErrInvalidInput) {
+			ctx.JSON(http.StatusBadRequest, gin.H{
+				"error":   "Invalid input",
+				"code":    errors.CodeInvalidInput,
+				"message": err.Error(),
+			})
+			return
+		}
 		ctx.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
 		return
 	}
+	if users == nil {
+		users = []model.User{}
+	}
 
-	ctx.JSON(http.StatusOK, users)
+	ctx.JSON(http.StatusOK, shapeUsers(users, fields))
 }
 
+// GetUserByUsername looks a user up by current username. With `include_former=true`, a username
+// nobody holds now resolves to the user who last gave it up; the response then carries a
+// `Link: <...>; rel="canonical"` header with the user's current URL, for clients to update their links.
 func (c *UserController) GetUserByUsername(ctx *gin.Context) {
 	username := ctx.Param("username")
+	fields, ok := userFields(ctx)
+	if !ok {
+		return
+	}
+	includeFormer, err := strconv.ParseBool(ctx.DefaultQuery("include_former", "false"))
+	if err != nil {
+		ctx.JSON(http.StatusBadRequest, gin.H{
+			"error":   "Invalid input",
+			"code":    errors.CodeInvalidInput,
+			"message": "include_former must be true or false",
+		})
+		return
+	}
 
-	user, err := c.service.GetByUsername(username)
+	user, err := c.in(ctx).GetByUsername(username, fields)
+	if includeFormer && stdErrors.Is(err, errors.ErrUserNotFound) {
+		// The current username is needed for the link even if fields leaves it out
+		lookup := fields
+		if !lookup.Has("username") {
+			lookup = append(slices.Clone(fields), "username")
+		}
+		user, err = c.in(ctx).GetByFormerUsername(username, lookup)
+		if err == nil {
+			ctx.Header("Link", fmt.Sprintf(`</api/v1/users/username/%s>; rel="canonical"`, url.PathEscape(user.Username)))
+		}
+	}
 	if err != nil {
 		if stdErrors.Is(err, errors.ErrUserNotFound) {
 			ctx.JSON(http.StatusNotFound, gin.H{
 				"error":   "Not found",
+				"code":    errors.CodeUserNotFound,
 				"message": fmt.Sprintf("user with username '%s' not found", username),
 			})
 			return
@@ -74,25 +300,143 @@ func (c *UserController) GetUserByUsername(ctx *gin.Context) {
 		return
 	}
 
+	ctx.JSON(http.StatusOK, shapeUser(user, fields))
+}
+
+// GetUsernameHistory lists the user's renames, newest first.
+func (c *UserController) GetUsernameHistory(ctx *gin.Context) {
+	id, err := uuid.Parse(ctx.Param("id"))
+	if err != nil {
+		ctx.JSON(http.StatusBadRequest, gin.H{
+			"error":   "Invalid input",
+			"code":    errors.CodeInvalidInput,
+			"message": "ID must be a valid UUID",
+		})
+		return
+	}
+
+	history, err := c.in(ctx).UsernameHistory(id)
+	if err != nil {
+		if stdErrors.Is(err, errors.ErrUserNotFound) {
+			ctx.JSON(http.StatusNotFound, gin.H{
+				"error":   "Not found",
+				"code":    errors.CodeUserNotFound,
+				"message": fmt.Sprintf("user with id '%s' not found", id),
+			})
+			return
+		}
+		ctx.JSON(http.StatusInternalServerError, gin.H{
+			"error":   "Internal server error",
+			"message": fmt.Sprintf("failed to retrieve username history: %v", err),
+		})
+		return
+	}
+	ctx.JSON(http.StatusOK, history)
+}
+
+// ActivateUser makes an invited or suspended user active.
+func (c *UserController) ActivateUser(ctx *gin.Context) {
+	c.changeStatus(ctx, model.UserActive)
+}
+
+// SuspendUser blocks an active user from signing in until they are activated again.
+func (c *UserController) SuspendUser(ctx *gin.Context) {
+	c.changeStatus(ctx, model.UserSuspended)
+}
+
+// DeactivateUser retires a user for good, keeping the account and its history.
+func (c *UserController) DeactivateUser(ctx *gin.Context) {
+	c.changeStatus(ctx, model.UserDeactivated)
+}
+
+// changeStatus moves the user to status to. The body, with the reason, may be left out for
+// transitions that do not require one.
+func (c *UserController) changeStatus(ctx *gin.Context, to model.UserStatus) {
+	id, err := uuid.Parse(ctx.Param("id"))
+	if err != nil {
+		ctx.JSON(http.StatusBadRequest, gin.H{
+			"error":   "Invalid input",
+			"code":    errors.CodeInvalidInput,
+			"message": "ID must be a valid UUID",
+		})
+		return
+	}
+	var req model.ChangeUserStatusRequest
+	if ctx.Request.ContentLength != 0 && !bindJSON(ctx, &req) {
+		return
+	}
+	req.Actor = auth.ActorFrom(ctx.Request.Context())
+
+	user, err := c.in(ctx).ChangeStatus(id, to, &req)
+	if err != nil {
+		if stdErrors.Is(err, errors.ErrStatusTransition) {
+			ctx.JSON(http.StatusConflict, gin.H{
+				"error":   "Conflict",
+				"code":    errors.CodeStatusTransition,
+				"message": err.Error(),
+			})
+			return
+		}
+		writeUpdateError(ctx, id, err)
+		return
+	}
 	ctx.JSON(http.StatusOK, user)
 }
 
+// GetStatusHistory lists the user's status changes, newest first.
+func (c *UserController) GetStatusHistory(ctx *gin.Context) {
+	id, err := uuid.Parse(ctx.Param("id"))
+	if err != nil {
+		ctx.JSON(http.StatusBadRequest, gin.H{
+			"error":   "Invalid input",
+			"code":    errors.CodeInvalidInput,
+			"message": "ID must be a valid UUID",
+		})
+		return
+	}
+
+	history, err := c.in(ctx).StatusHistory(id)
+	if err != nil {
+		if stdErrors.Is(err, errors.ErrUserNotFound) {
+			ctx.JSON(http.StatusNotFound, gin.H{
+				"error":   "Not found",
+				"code":    errors.CodeUserNotFound,
+				"message": fmt.Sprintf("user with id '%s' not found", id),
+			})
+			return
+		}
+		ctx.JSON(http.StatusInternalServerError, gin.H{
+			"error":   "Internal server error",
+			"message": fmt.Sprintf("failed to retrieve status history: %v", err),
+		})
+		return
+	}
+	ctx.JSON(http.StatusOK, history)
+}
+
 func (c *UserController) GetUserByID(ctx *gin.Context) {
 	idStr := ctx.Param("id")
 	id, err := uuid.Parse(idStr)
 	if err != nil {
 		ctx.JSON(http.StatusBadRequest, gin.H{
 			"error":   "Invalid input",
+			"code":    errors.CodeInvalidInput,
 			"message": "ID must be a valid UUID",
 		})
 		return
 	}
 
-	user, err := c.service.GetByID(id)
+	fields, ok := userFields(ctx)
+	if !ok {
+		return
+	}
+
+	user, err := c.in(ctx).GetByID(id, fields)
 	if err != nil {
 		if stdErrors.Is(err, errors.ErrUserNotFound) {
 			ctx.JSON(http.StatusNotFound, gin.H{
 				"error":   "Not found",
+				"code":    errors.CodeUserNotFound,
 				"message": fmt.Sprintf("user with id '%s' not found", id),
 			})
 			return
@@ -104,7 +448,19 @@ func (c *UserController) GetUserByID(ctx *gin.Context) {
 		return
 	}
 
-	ctx.JSON(http.StatusOK, user)
+	// A sparse fieldset asks for stored attributes only
+	if c.emailChanges != nil && fields == nil {
+		user.PendingEmailChange, err = c.emailChanges.InOrganization(organization(ctx)).Pending(id)
+		if err != nil {
+			ctx.JSON(http.StatusInternalServerError, gin.H{
+				"error":   "Internal server error",
+				"message": fmt.Sprintf("failed to retrieve pending email change of user '%s': %v", id, err),
+			})
+			return
+		}
+	}
+
+	ctx.JSON(http.StatusOK, shapeUser(user, fields))
 }
 
 func (c *UserController) CreateUser(ctx *gin.Context) {
@@ -117,6 +473,7 @@ func (c *UserController) CreateUser(ctx *gin.Context) {
 		if stdErrors.As(err, &ve) {
 			ctx.JSON(http.StatusBadRequest, gin.H{
 				"error":   "Validation failed",
+				"code":    errors.CodeInvalidInput,
 				"message": "Invalid input data",
 				"details": formatValidationErrors(ve),
 			})
@@ -126,25 +483,37 @@ func (c *UserController) CreateUser(ctx *gin.Context) {
 		// Handle JSON parsing errors
 		ctx.JSON(http.StatusBadRequest, gin.H{
 			"error":   "Invalid request",
+			"code":    errors.CodeInvalidInput,
 			"message": fmt.Sprintf("Failed to parse request body: %v", err.Error()),
 		})
 		return
 	}
 
-	user, err := c.service.Create(&req)
+	user, err := c.in(ctx).Create(&req)
 	if err != nil {
 		// Handle specific business logic errors
 		if stdErrors.Is(err, errors.ErrUsernameExists) {
 			ctx.JSON(http.StatusConflict, gin.H{
 				"error":   "Conflict",
+				"code":    errors.CodeUsernameExists,
 				"message": "Username already exists",
 			})
 			return
 		}
 
+		if stdErrors.Is(err, errors.ErrUsernameReserved) {
+			ctx.JSON(http.StatusConflict, gin.H{
+				"error":   "Conflict",
+				"code":    errors.CodeUsernameReserved,
+				"message": "Username is reserved: its previous owner gave it up recently",
+			})
+			return
+		}
+
 		if stdErrors.Is(err, errors.ErrEmailExists) {
 			ctx.JSON(http.StatusConflict, gin.H{
 				"error":   "Conflict",
+				"code":    errors.CodeEmailExists,
 				"message": "Email already exists",
 			})
 			return
@@ -153,6 +522,7 @@ func (c *UserController) CreateUser(ctx *gin.Context) {
 		if stdErrors.Is(err, errors.ErrInvalidInput) {
 			ctx.JSON(http.StatusBadRequest, gin.H{
 				"error":   "Invalid input",
+				"code":    errors.CodeInvalidInput,
 				"message": err.Error(),
 			})
 			return
@@ -170,17 +540,38 @@ func (c *UserController) CreateUser(ctx *gin.Context) {
 	ctx.JSON(http.StatusCreated, user)
 }
 
+// UpdateUser handles PATCH. The Content-Type picks the patch format: plain JSON is the
+// partial UpdateUserRequest, and the RFC 7396 / RFC 6902 formats go through patchUser.
 func (c *UserController) UpdateUser(ctx *gin.Context) {
 	idStr := ctx.Param("id")
 	id, err := uuid.Parse(idStr)
 	if err != nil {
 		ctx.JSON(http.StatusBadRequest, gin.H{
 			"error":   "Invalid input",
+			"code":    errors.CodeInvalidInput,
 			"message": "ID must be a valid UUID",
 		})
 		return
 	}
 
+	switch ctx.ContentType() {
+	case "", binding.MIMEJSON:
+		// Bespoke partial update, handled below
+	case MIMEMergePatch:
+		c.patchUser(ctx, id, applyMergePatch)
+		return
+	case MIMEJSONPatch:
+		c.patchUser(ctx, id, applyJSONPatch)
+		return
+	default:
+		ctx.Header("Accept-Patch", acceptPatch)
+		ctx.JSON(http.StatusUnsupportedMediaType, gin.H{
+			"error":   "Unsupported media type",
+			"message": fmt.Sprintf("PATCH accepts %s", acceptPatch),
+		})
+		return
+	}
+
 	var req model.UpdateUserRequest
 
 	if err := ctx.ShouldBindJSON(&req); err != nil {
@@ -188,6 +579,7 @@ func (c *UserController) UpdateUser(ctx *gin.Context) {
 		if stdErrors.As(err, &ve) {
 			ctx.JSON(http.StatusBadRequest, gin.H{
 				"error":   "Validation failed",
+				"code":    errors.CodeInvalidInput,
 				"message": "Invalid input data",
 				"details": formatValidationErrors(ve),
 			})
@@ -196,56 +588,120 @@ func (c *UserController) UpdateUser(ctx *gin.Context) {
 
 		ctx.JSON(http.StatusBadRequest, gin.H{
 			"error":   "Invalid request",
+			"code":    errors.CodeInvalidInput,
 			"message": fmt.Sprintf("Failed to parse request body: %v", err.Error()),
 		})
 		return
 	}
 
-	user, err := c.service.Update(id, &req)
+	req.Actor = auth.ActorFrom(ctx.Request.Context())
+	user, err := c.in(ctx).Update(id, &req)
 	if err != nil {
-		if stdErrors.Is(err, errors.ErrUserNotFound) {
-			ctx.JSON(http.StatusNotFound, gin.H{
-				"error":   "Not found",
-				"message": fmt.Sprintf("user with id '%s' not found", id),
-			})
-			return
-		}
+		writeUpdateError(ctx, id, err)
+		return
+	}
 
-		if stdErrors.Is(err, errors.ErrUsernameExists) {
-			ctx.JSON(http.StatusConflict, gin.H{
-				"error":   "Conflict",
-				"message": "Username already exists",
-			})
-			return
-		}
+	// Return updated user with 200 status
+	ctx.JSON(http.StatusOK, user)
+}
 
-		if stdErrors.Is(err, errors.ErrEmailExists) {
-			ctx.JSON(http.StatusConflict, gin.H{
-				"error":   "Conflict",
-				"message": "Email already exists",
-			})
-			return
-		}
+// ReplaceUser handles PUT: the body is the complete representation, validated like creation.
+func (c *UserController) ReplaceUser(ctx *gin.Context) {
+	idStr := ctx.Param("id")
+	id, err := uuid.Parse(idStr)
+	if err != nil {
+		ctx.JSON(http.StatusBadRequest, gin.H{
+			"error":   "Invalid input",
+			"code":    errors.CodeInvalidInput,
+			"message": "ID must be a valid UUID",
+		})
+		return
+	}
 
-		if stdErrors.Is(err, errors.ErrInvalidInput) {
+	var req model.ReplaceUserRequest
+	if err := ctx.ShouldBindJSON(&req); err != nil {
+		var ve validator.ValidationErrors
+		if stdErrors.As(err, &ve) {
 			ctx.JSON(http.StatusBadRequest, gin.H{
-				"error":   "Invalid input",
-				"message": err.Error(),
+				"error":   "Validation failed",
+				"code":    errors.CodeInvalidInput,
+				"message": "Invalid input data",
+				"details": formatValidationErrors(ve),
 			})
 			return
 		}
 
-		ctx.JSON(http.StatusInternalServerError, gin.H{
-			"error":   "Internal server error",
-			"message": fmt.Sprintf("failed to update user: %v", err.Error()),
+		ctx.JSON(http.StatusBadRequest, gin.H{
+			"error":   "Invalid request",
+			"code":    errors.CodeInvalidInput,
+			"message": fmt.Sprintf("Failed to parse request body: %v", err.Error()),
 		})
 		return
 	}
 
-	// Return updated user with 200 status
+	req.Actor = auth.ActorFrom(ctx.Request.Context())
+	user, err := c.in(ctx).Replace(id, &req)
+	if err != nil {
+		writeUpdateError(ctx, id, err)
+		return
+	}
+
 	ctx.JSON(http.StatusOK, user)
 }
 
+// writeUpdateError maps errors from Update and Replace to responses.
+func writeUpdateError(ctx *gin.Context, id uuid.UUID, err error) {
+	if stdErrors.Is(err, errors.ErrUserNotFound) {
+		ctx.JSON(http.StatusNotFound, gin.H{
+			"error":   "Not found",
+			"code":    errors.CodeUserNotFound,
+			"message": fmt.Sprintf("user with id '%s' not found", id),
+		})
+		return
+	}
+
+	if stdErrors.Is(err, errors.ErrUsernameExists) {
+		ctx.JSON(http.StatusConflict, gin.H{
+			"error":   "Conflict",
+			"code":    errors.CodeUsernameExists,
+			"message": "Username already exists",
+		})
+		return
+	}
+
+	if stdErrors.Is(err, errors.ErrUsernameReserved) {
+		ctx.JSON(http.StatusConflict, gin.H{
+			"error":   "Conflict",
+			"code":    errors.CodeUsernameReserved,
+			"message": "Username is reserved: its previous owner gave it up recently",
+		})
+		return
+	}
+
+	if stdErrors.Is(err, errors.ErrEmailExists) {
+		ctx.JSON(http.StatusConflict, gin.H{
+			"error":   "Conflict",
+			"code":    errors.CodeEmailExists,
+			"message": "Email already exists",
+		})
+		return
+	}
+
+	if stdErrors.Is(err, errors.ErrInvalidInput) {
+		ctx.JSON(http.StatusBadRequest, gin.H{
+			"error":   "Invalid input",
+			"code":    errors.CodeInvalidInput,
+			"message": err.Error(),
+		})
+		return
+	}
+
+	ctx.JSON(http.StatusInternalServerError, gin.H{
+		"error":   "Internal server error",
+		"message": fmt.Sprintf("failed to update user: %v", err.Error()),
+	})
+}
+
 // DeleteUser handles DELETE requests to remove a user by ID.
 // Policy: Idempotent at HTTP level - returns 204 whether user existed or not.
 // Why: Client's goal is to "ensure user is absent".
@@ -256,12 +712,13 @@ func (c *UserController) DeleteUser(ctx *gin.Context) {
 	if err != nil {
 		ctx.JSON(http.StatusBadRequest, gin.H{
 			"error":   "Invalid input",
+			"code":    errors.CodeInvalidInput,
 			"message": "ID must be a valid UUID",
 		})
 		return
 	}
 
-	err = c.service.Delete(id)
+	err = c.in(ctx).Delete(id, auth.ActorFrom(ctx.Request.Context()))
 	if err != nil {
 		// User didn't exist - still return success (idempotent behavior)
 		if stdErrors.Is(err, errors.ErrUserNotFound) {
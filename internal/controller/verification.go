@@ -0,0 +1,127 @@
+package controller
+
+import (
+	"cruder/internal/errors"
+	"cruder/internal/model"
+	"cruder/internal/service"
+	"fmt"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+
+	stdErrors "errors"
+)
+
+type VerificationController struct {
+	service service.EmailVerificationService
+	changes service.EmailChangeService
+}
+
+func NewVerificationController(service service.EmailVerificationService, changes service.EmailChangeService) *VerificationController {
+	return &VerificationController{service: service, changes: changes}
+}
+
+// VerifyEmail redeems a token from a verification email and returns the now verified user.
+func (c *VerificationController) VerifyEmail(ctx *gin.Context) {
+	var req model.VerifyEmailRequest
+	if !bindJSON(ctx, &req) {
+		return
+	}
+
+	user, err := c.service.Verify(req.Token)
+	if err != nil {
+		if stdErrors.Is(err, errors.ErrVerificationTokenInvalid) {
+			ctx.JSON(http.StatusBadRequest, gin.H{
+				"error":   "Invalid input",
+				"code":    errors.CodeVerificationTokenInvalid,
+				"message": err.Error(),
+			})
+			return
+		}
+		ctx.JSON(http.StatusInternalServerError, gin.H{
+			"error":   "Internal server error",
+			"message": "failed to verify email address",
+		})
+		return
+	}
+	ctx.JSON(http.StatusOK, user)
+}
+
+// ResendVerification mails a user a new verification token, invalidating the previous one.
+func (c *VerificationController) ResendVerification(ctx *gin.Context) {
+	id, err := uuid.Parse(ctx.Param("id"))
+	if err != nil {
+		ctx.JSON(http.StatusBadRequest, gin.H{
+			"error":   "Invalid input",
+			"code":    errors.CodeInvalidInput,
+			"message": "ID must be a valid UUID",
+		})
+		return
+	}
+
+	err = c.service.InOrganization(organization(ctx)).Resend(id)
+	switch {
+	case err == nil:
+		ctx.Status(http.StatusAccepted)
+	case stdErrors.Is(err, errors.ErrUserNotFound):
+		ctx.JSON(http.StatusNotFound, gin.H{
+			"error":   "Not found",
+			"code":    errors.CodeUserNotFound,
+			"message": fmt.Sprintf("user with id '%s' not found", id),
+		})
+	case stdErrors.Is(err, errors.ErrEmailAlreadyVerified):
+		ctx.JSON(http.StatusConflict, gin.H{
+			"error":   "Conflict",
+			"code":    errors.CodeEmailAlreadyVerified,
+			"message": err.Error(),
+		})
+	default:
+		ctx.JSON(http.StatusInternalServerError, gin.H{
+			"error":   "Internal server error",
+			"message": "failed to send verification email",
+		})
+	}
+}
+
+// ConfirmEmailChange redeems the token mailed to a requested new address and returns the user,
+// now with that address.
+func (c *VerificationController) ConfirmEmailChange(ctx *gin.Context) {
+	c.redeemEmailChange(ctx, c.changes.Confirm, "failed to confirm email change")
+}
+
+// RevertEmailChange redeems the "this wasn't me" token mailed to the previous address, cancelling
+// or undoing the change, and returns the user.
+func (c *VerificationController) RevertEmailChange(ctx *gin.Context) {
+	c.redeemEmailChange(ctx, c.changes.Revert, "failed to revert email change")
+}
+
+func (c *VerificationController) redeemEmailChange(ctx *gin.Context, redeem func(token string) (*model.User, error), failure string) {
+	var req model.EmailChangeTokenRequest
+	if !bindJSON(ctx, &req) {
+		return
+	}
+
+	user, err := redeem(req.Token)
+	switch {
+	case err == nil:
+		ctx.JSON(http.StatusOK, user)
+	case stdErrors.Is(err, errors.ErrEmailChangeTokenInvalid):
+		ctx.JSON(http.StatusBadRequest, gin.H{
+			"error":   "Invalid input",
+			"code":    errors.CodeEmailChangeTokenInvalid,
+			"message": err.Error(),
+		})
+	case stdErrors.Is(err, errors.ErrEmailExists):
+		ctx.JSON(http.StatusConflict, gin.H{
+			"error":   "Conflict",
+			"code":    errors.CodeEmailExists,
+			"message": "Email already exists",
+		})
+	default:
+		ctx.JSON(http.StatusInternalServerError, gin.H{
+			"error":   "Internal server error",
+			"message": failure,
+		})
+	}
+}
@@ -0,0 +1,94 @@
+package errors
+
+import "errors"
+
+// Error codes sent as the "code" field of error responses, one per sentinel clients tell apart.
+// They are part of the API: add new ones, never change existing ones.
+const (
+	CodeUserNotFound     = "user_not_found"
+	CodeInvalidInput     = "invalid_input"
+	CodeUsernameExists   = "username_exists"
+	CodeEmailExists      = "email_exists"
+	CodeUsernameReserved = "username_reserved"
+	CodeStatusTransition = "status_transition"
+	CodeUserNotActive    = "user_not_active"
+
+	CodeOrganizationNotFound = "organization_not_found"
+	CodeOrganizationExists   = "organization_exists"
+
+	CodeGroupNotFound       = "group_not_found"
+	CodeGroupExists         = "group_exists"
+	CodeGroupMemberExists   = "group_member_exists"
+	CodeGroupMemberNotFound = "group_member_not_found"
+	CodeGroupCycle          = "group_cycle"
+
+	CodeJobNotFound = "job_not_found"
+	CodeJobFinished = "job_finished"
+	CodeJobNotReady = "job_not_ready"
+
+	CodeVerificationTokenInvalid = "verification_token_invalid"
+	CodeEmailAlreadyVerified     = "email_already_verified"
+	CodeEmailChangeTokenInvalid  = "email_change_token_invalid"
+
+	CodeInvitationNotFound     = "invitation_not_found"
+	CodeInvitationExists       = "invitation_exists"
+	CodeInvitationNotPending   = "invitation_not_pending"
+	CodeInvitationTokenInvalid = "invitation_token_invalid"
+
+	CodeInvalidCredentials = "invalid_credentials"
+	CodeWrongPassword      = "wrong_password"
+	CodeScopeNotGranted    = "scope_not_granted"
+	CodeAuthThrottled      = "auth_throttled"
+	CodeBanNotFound        = "ban_not_found"
+
+	CodeOAuthClientNotFound = "oauth_client_not_found"
+	CodeConsentNotFound     = "consent_not_found"
+)
+
+var codes = []struct {
+	err  error
+	code string
+}{
+	{ErrUserNotFound, CodeUserNotFound},
+	{ErrUsernameExists, CodeUsernameExists},
+	{ErrEmailExists, CodeEmailExists},
+	{ErrUsernameReserved, CodeUsernameReserved},
+	{ErrStatusTransition, CodeStatusTransition},
+	{ErrUserNotActive, CodeUserNotActive},
+	{ErrOrganizationNotFound, CodeOrganizationNotFound},
+	{ErrOrganizationExists, CodeOrganizationExists},
+	{ErrGroupNotFound, CodeGroupNotFound},
+	{ErrGroupExists, CodeGroupExists},
+	{ErrGroupMemberExists, CodeGroupMemberExists},
+	{ErrGroupMemberNotFound, CodeGroupMemberNotFound},
+	{ErrGroupCycle, CodeGroupCycle},
+	{ErrJobNotFound, CodeJobNotFound},
+	{ErrJobFinished, CodeJobFinished},
+	{ErrJobNotReady, CodeJobNotReady},
+	{ErrVerificationTokenInvalid, CodeVerificationTokenInvalid},
+	{ErrEmailAlreadyVerified, CodeEmailAlreadyVerified},
+	{ErrEmailChangeTokenInvalid, CodeEmailChangeTokenInvalid},
+	{ErrInvitationNotFound, CodeInvitationNotFound},
+	{ErrInvitationExists, CodeInvitationExists},
+	{ErrInvitationNotPending, CodeInvitationNotPending},
+	{ErrInvitationTokenInvalid, CodeInvitationTokenInvalid},
+	{ErrInvalidCredentials, CodeInvalidCredentials},
+	{ErrWrongPassword, CodeWrongPassword},
+	{ErrScopeNotGranted, CodeScopeNotGranted},
+	{ErrAuthThrottled, CodeAuthThrottled},
+	{ErrBanNotFound, CodeBanNotFound},
+	{ErrOAuthClientNotFound, CodeOAuthClientNotFound},
+	{ErrConsentNotFound, CodeConsentNotFound},
+	// Last, as errors wrapping a more specific sentinel often wrap this one too
+	{ErrInvalidInput, CodeInvalidInput},
+}
+
+// Code returns the code of the sentinel err wraps, or "" if it wraps none of them.
+func Code(err error) string {
+	for _, c := range codes {
+		if errors.Is(err, c.err) {
+			return c.code
+		}
+	}
+	return ""
+}
@@ -11,7 +11,89 @@ var (
 	// Uniqueness constraint errors (from repository/database layer)
 	ErrUsernameExists = errors.New("username already exists")
 	ErrEmailExists    = errors.New("email already exists")
+	// ErrUsernameReserved: another user gave up the username recently and it is still quarantined
+	ErrUsernameReserved = errors.New("username is reserved")
+	// ErrStatusTransition: the lifecycle does not allow the user's status change; see StatusTransitionError
+	ErrStatusTransition = errors.New("status transition not allowed")
+	// ErrUserNotActive: the user is invited, suspended or deactivated and may not sign in
+	ErrUserNotActive = errors.New("user account is not active")
+
+	// ErrOrganizationNotFound: no organization has the ID or slug
+	ErrOrganizationNotFound = errors.New("organization not found")
+	ErrOrganizationExists   = errors.New("organization slug already exists")
+
+	// Group-related errors
+	ErrGroupNotFound = errors.New("group not found")
+	// ErrGroupExists: the organization has a group with that name
+	ErrGroupExists         = errors.New("group name already exists")
+	ErrGroupMemberExists   = errors.New("already a member of the group")
+	ErrGroupMemberNotFound = errors.New("not a member of the group")
+	// ErrGroupCycle: adding the group as a member would make it (indirectly) a member of itself
+	ErrGroupCycle = errors.New("group membership would create a cycle")
+
+	// Job-related errors
+	ErrJobNotFound = errors.New("job not found")
+	// ErrJobFinished: the job already succeeded, failed or was cancelled
+	ErrJobFinished = errors.New("job already finished")
+	// ErrJobNotReady: the job has no result to download (yet)
+	ErrJobNotReady = errors.New("job result not available")
+	// ErrJobLost: the worker no longer owns the job, e.g. it was reclaimed after a missed heartbeat
+	ErrJobLost = errors.New("job lock lost")
+
+	// Email verification errors
+	// ErrVerificationTokenInvalid: unknown, already used or expired token, or the user's email changed since
+	ErrVerificationTokenInvalid = errors.New("verification token is invalid or expired")
+	ErrEmailAlreadyVerified     = errors.New("email address is already verified")
+
+	// Email change errors
+	// ErrEmailChangeTokenInvalid: unknown, already used or expired confirm or revert token,
+	// or the user's email changed again since
+	ErrEmailChangeTokenInvalid = errors.New("email change token is invalid or expired")
+	ErrEmailChangeNotFound     = errors.New("no pending email change")
+
+	// Invitation errors
+	ErrInvitationNotFound = errors.New("invitation not found")
+	// ErrInvitationExists: the address has a pending invitation already, which can be resent instead
+	ErrInvitationExists = errors.New("a pending invitation for this email already exists")
+	// ErrInvitationNotPending: the invitation was redeemed or revoked, so it can no longer be resent or revoked
+	ErrInvitationNotPending = errors.New("invitation has already been redeemed or revoked")
+	// ErrInvitationTokenInvalid: unknown invitation token, or one whose invitation was redeemed, revoked or has expired
+	ErrInvitationTokenInvalid = errors.New("invitation token is invalid or expired")
+
+	// Authentication errors
+	// ErrInvalidCredentials: unknown user, no password set or wrong password; deliberately not told apart
+	ErrInvalidCredentials = errors.New("invalid username or password")
+	// ErrWrongPassword: the current password given to authorize a change is wrong
+	ErrWrongPassword = errors.New("current password is incorrect")
+	// ErrTokenRevoked: a correctly signed access token issued before a revocation of its subject
+	ErrTokenRevoked = errors.New("access token has been revoked")
+	// ErrScopeNotGranted: a scope was requested that the caller does not hold
+	ErrScopeNotGranted = errors.New("scope not granted")
+	// ErrAuthThrottled: the client presented too many wrong API keys recently and must wait
+	ErrAuthThrottled = errors.New("too many failed authentication attempts")
+	// ErrBanNotFound: the client IP is not banned
+	ErrBanNotFound = errors.New("no ban for this client")
+
+	// OpenID Connect provider errors
+	ErrOAuthClientNotFound = errors.New("OAuth client not found")
+	ErrConsentNotFound     = errors.New("consent not found")
+	// ErrAuthorizationCodeInvalid: unknown or already used authorization code
+	ErrAuthorizationCodeInvalid = errors.New("authorization code is invalid")
 
 	// Database errors
 	ErrDatabaseOperation = errors.New("database operation failed")
 )
+
+// StatusTransitionError is the ErrStatusTransition returned for a user in status From who was to be
+// moved to status To. errors.Is(err, ErrStatusTransition) matches it.
+type StatusTransitionError struct {
+	From, To string
+}
+
+func (e *StatusTransitionError) Error() string {
+	return "cannot change status from " + e.From + " to " + e.To
+}
+
+func (e *StatusTransitionError) Is(target error) bool {
+	return target == ErrStatusTransition
+}
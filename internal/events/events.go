@@ -0,0 +1,108 @@
+// Package events distributes user and group membership change notifications.
+//
+// Mutations are published through a Publisher (Postgres NOTIFY in production, so every
+// replica hears about every change) and fanned out in-process by a Broker to
+// subscribers such as the gRPC WatchUsers stream.
+package events
+
+import (
+	"context"
+	"cruder/internal/model"
+	"sync"
+	"time"
+)
+
+type Type string
+
+const (
+	UserCreated Type = "user.created"
+	UserUpdated Type = "user.updated"
+	UserDeleted Type = "user.deleted"
+
+	// Group membership changes, including those that end when a user or group is deleted
+	GroupMemberAdded   Type = "group.member_added"
+	GroupMemberRemoved Type = "group.member_removed"
+)
+
+// Event describes a committed change. For deletions only User.ID and User.OrganizationID are set.
+// Membership events carry the change in Membership and only User.OrganizationID, plus User.ID
+// when the member is a user.
+type Event struct {
+	Type       Type                         `json:"type"`
+	User       model.User                   `json:"user"`
+	Membership *model.GroupMembershipChange `json:"membership,omitempty"`
+	OccurredAt time.Time                    `json:"occurred_at"`
+}
+
+// Publisher announces events after the change has been committed.
+type Publisher interface {
+	Publish(ctx context.Context, e Event) error
+}
+
+// subscriberBuffer is how many events a subscriber may lag behind before it is dropped.
+const subscriberBuffer = 256
+
+// Broker fans events out to in-process subscribers. It also implements Publisher,
+// which is enough for a single replica and for tests.
+type Broker struct {
+	mu     sync.Mutex
+	subs   map[chan Event]struct{}
+	closed bool
+}
+
+func NewBroker() *Broker {
+	return &Broker{subs: make(map[chan Event]struct{})}
+}
+
+// Subscribe returns a channel of future events and a func to unsubscribe.
+// The channel is closed when the subscriber falls too far behind or the broker closes;
+// consumers should treat that as "events may have been missed".
+func (b *Broker) Subscribe() (<-chan Event, func()) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	ch := make(chan Event, subscriberBuffer)
+	if b.closed {
+		close(ch)
+		return ch, func() {}
+	}
+	b.subs[ch] = struct{}{}
+
+	return ch, func() {
+		b.mu.Lock()
+		defer b.mu.Unlock()
+		if _, ok := b.subs[ch]; ok {
+			delete(b.subs, ch)
+			close(ch)
+		}
+	}
+}
+
+// Publish delivers e to every subscriber without blocking the publisher.
+func (b *Broker) Publish(_ context.Context, e Event) error {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	for ch := range b.subs {
+		select {
+		case ch <- e:
+		default:
+			// Slow consumer: drop it rather than block every writer
+			delete(b.subs, ch)
+			close(ch)
+		}
+	}
+	return nil
+}
+
+// Close ends every subscription, e.g. so long-lived streams return during shutdown.
+func (b *Broker) Close() {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	b.closed = true
+	for ch := range b.subs {
+		delete(b.subs, ch)
+		close(ch)
+	}
+}
@@ -0,0 +1,74 @@
+package events
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"time"
+
+	"github.com/lib/pq"
+)
+
+// Channel is the Postgres NOTIFY channel carrying user events.
+const Channel = "user_events"
+
+// PostgresNotifier publishes events with pg_notify so every replica's listener receives them.
+type PostgresNotifier struct {
+	db *sql.DB
+}
+
+func NewPostgresNotifier(db *sql.DB) *PostgresNotifier {
+	return &PostgresNotifier{db: db}
+}
+
+func (n *PostgresNotifier) Publish(ctx context.Context, e Event) error {
+	payload, err := json.Marshal(e)
+	if err != nil {
+		return fmt.Errorf("failed to encode event: %w", err)
+	}
+	if _, err := n.db.ExecContext(ctx, `SELECT pg_notify($1, $2)`, Channel, string(payload)); err != nil {
+		return fmt.Errorf("failed to publish event: %w", err)
+	}
+	return nil
+}
+
+// ListenPostgres relays NOTIFY messages on Channel into broker until ctx is cancelled.
+// lib/pq's Listener reconnects on its own; events sent while disconnected are lost,
+// which subscribers already have to tolerate (see Broker.Subscribe).
+func ListenPostgres(ctx context.Context, dsn string, broker *Broker, logger *slog.Logger) error {
+	listener := pq.NewListener(dsn, time.Second, time.Minute, func(ev pq.ListenerEventType, err error) {
+		if err != nil {
+			logger.Warn("Event listener connection problem",
+				slog.Int("event", int(ev)),
+				slog.String("error", err.Error()))
+		}
+	})
+	defer func() { _ = listener.Close() }()
+
+	if err := listener.Listen(Channel); err != nil {
+		return fmt.Errorf("failed to listen on %s: %w", Channel, err)
+	}
+
+	for {
+		select {
+		case <-ctx.Done():
+			return nil
+		case n := <-listener.Notify:
+			// nil after a reconnect
+			if n == nil {
+				continue
+			}
+			var e Event
+			if err := json.Unmarshal([]byte(n.Extra), &e); err != nil {
+				logger.Warn("Ignoring malformed event", slog.String("error", err.Error()))
+				continue
+			}
+			_ = broker.Publish(ctx, e)
+		case <-time.After(90 * time.Second):
+			// Detect dead connections that never error on their own
+			go func() { _ = listener.Ping() }()
+		}
+	}
+}
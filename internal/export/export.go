@@ -0,0 +1,127 @@
+// Package export encodes users one at a time in formats meant for bulk consumption,
+// so a whole table can be streamed without holding it in memory.
+package export
+
+import (
+	"cruder/internal/model"
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"io"
+	"time"
+
+	"github.com/ugorji/go/codec"
+)
+
+// Media types of the supported formats
+const (
+	MIMECSV     = "text/csv"
+	MIMENDJSON  = "application/x-ndjson"
+	MIMEMsgPack = "application/msgpack"
+)
+
+// MediaTypes lists every supported format, for content negotiation.
+var MediaTypes = []string{MIMECSV, MIMENDJSON, MIMEMsgPack}
+
+// Writer encodes users one by one. Close must be called to flush buffered output.
+type Writer interface {
+	Write(u *model.User) error
+	Close() error
+}
+
+// NewWriter returns a Writer for mediaType that emits only fields (all of them if nil).
+func NewWriter(mediaType string, w io.Writer, fields model.UserFields) (Writer, error) {
+	if fields == nil {
+		fields = model.UserFieldNames
+	}
+	switch mediaType {
+	case MIMECSV:
+		return newCSVWriter(w, fields)
+	case MIMENDJSON:
+		return &ndjsonWriter{enc: json.NewEncoder(w), fields: fields}, nil
+	case MIMEMsgPack:
+		h := &codec.MsgpackHandle{WriteExt: true}
+		h.Canonical = true
+		return &msgpackWriter{enc: codec.NewEncoder(w, h), fields: fields}, nil
+	default:
+		return nil, fmt.Errorf("export: unsupported media type %q", mediaType)
+	}
+}
+
+// csvWriter writes a header row of field names, then one row per user.
+// Timestamps are RFC 3339 in UTC, as in the admin CLI's CSV output.
+type csvWriter struct {
+	w      *csv.Writer
+	fields model.UserFields
+	row    []string
+}
+
+func newCSVWriter(w io.Writer, fields model.UserFields) (*csvWriter, error) {
+	cw := &csvWriter{w: csv.NewWriter(w), fields: fields, row: make([]string, len(fields))}
+	if err := cw.w.Write(fields); err != nil {
+		return nil, err
+	}
+	return cw, nil
+}
+
+func (c *csvWriter) Write(u *model.User) error {
+	for i, name := range c.fields {
+		switch name {
+		case "id":
+			c.row[i] = u.ID.String()
+		case "username":
+			c.row[i] = u.Username
+		case "email":
+			c.row[i] = u.Email
+		case "full_name":
+			c.row[i] = u.FullName
+		case "status":
+			c.row[i] = string(u.Status)
+		case "created_at":
+			c.row[i] = u.CreatedAt.UTC().Format(time.RFC3339)
+		case "updated_at":
+			c.row[i] = u.UpdatedAt.UTC().Format(time.RFC3339)
+		case "email_verified_at":
+			c.row[i] = ""
+			if u.EmailVerifiedAt != nil {
+				c.row[i] = u.EmailVerifiedAt.UTC().Format(time.RFC3339)
+			}
+		}
+	}
+	return c.w.Write(c.row)
+}
+
+func (c *csvWriter) Close() error {
+	c.w.Flush()
+	return c.w.Error()
+}
+
+// ndjsonWriter writes one JSON object per line, shaped like the JSON API's users.
+type ndjsonWriter struct {
+	enc    *json.Encoder
+	fields model.UserFields
+}
+
+func (n *ndjsonWriter) Write(u *model.User) error {
+	return n.enc.Encode(n.fields.Project(u))
+}
+
+func (n *ndjsonWriter) Close() error { return nil }
+
+// msgpackWriter writes a sequence of MessagePack maps, one per user, with no enclosing array:
+// an array header needs the count up front, which a stream does not know. IDs are strings
+// and timestamps use the MessagePack timestamp extension.
+type msgpackWriter struct {
+	enc    *codec.Encoder
+	fields model.UserFields
+}
+
+func (m *msgpackWriter) Write(u *model.User) error {
+	row := m.fields.Project(u)
+	if _, ok := row["id"]; ok {
+		row["id"] = u.ID.String()
+	}
+	return m.enc.Encode(row)
+}
+
+func (m *msgpackWriter) Close() error { return nil }
@@ -0,0 +1,80 @@
+package export
+
+import (
+	"bytes"
+	"cruder/internal/model"
+	"encoding/csv"
+	"encoding/json"
+	"io"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/ugorji/go/codec"
+)
+
+var testUsers = []model.User{
+	{ID: uuid.New(), Username: "jdoe", Email: "jdoe@example.com", FullName: "Doe, John", CreatedAt: time.Date(2025, 1, 2, 3, 4, 5, 0, time.UTC)},
+	{ID: uuid.New(), Username: "asmith", Email: "asmith@example.com", FullName: "Alice Smith"},
+}
+
+func encode(t *testing.T, mediaType string, fields model.UserFields) []byte {
+	t.Helper()
+	var buf bytes.Buffer
+	w, err := NewWriter(mediaType, &buf, fields)
+	require.NoError(t, err)
+	for i := range testUsers {
+		require.NoError(t, w.Write(&testUsers[i]))
+	}
+	require.NoError(t, w.Close())
+	return buf.Bytes()
+}
+
+func TestCSV(t *testing.T) {
+	records, err := csv.NewReader(bytes.NewReader(encode(t, MIMECSV, nil))).ReadAll()
+	require.NoError(t, err)
+
+	require.Len(t, records, 3)
+	assert.Equal(t, model.UserFieldNames, records[0])
+	assert.Equal(t, "Doe, John", records[1][3])
+	assert.Equal(t, "2025-01-02T03:04:05Z", records[1][4])
+
+	records, err = csv.NewReader(bytes.NewReader(encode(t, MIMECSV, model.UserFields{"id", "username"}))).ReadAll()
+	require.NoError(t, err)
+	assert.Equal(t, []string{"id", "username"}, records[0])
+	assert.Equal(t, []string{testUsers[1].ID.String(), "asmith"}, records[2])
+}
+
+func TestNDJSON(t *testing.T) {
+	lines := strings.Split(strings.TrimSpace(string(encode(t, MIMENDJSON, model.UserFields{"username", "email"}))), "\n")
+	require.Len(t, lines, 2)
+
+	var first map[string]any
+	require.NoError(t, json.Unmarshal([]byte(lines[0]), &first))
+	assert.Equal(t, map[string]any{"username": "jdoe", "email": "jdoe@example.com"}, first)
+}
+
+func TestMsgPack(t *testing.T) {
+	h := &codec.MsgpackHandle{}
+	h.RawToString = true
+	dec := codec.NewDecoderBytes(encode(t, MIMEMsgPack, nil), h)
+
+	rows := make([]map[string]any, len(testUsers))
+	for i := range rows {
+		require.NoError(t, dec.Decode(&rows[i]))
+	}
+	var extra map[string]any
+	assert.Error(t, dec.Decode(&extra), "one map per user and nothing else")
+
+	assert.Equal(t, testUsers[0].ID.String(), rows[0]["id"])
+	assert.Equal(t, "jdoe", rows[0]["username"])
+	assert.True(t, testUsers[0].CreatedAt.Equal(rows[0]["created_at"].(time.Time)))
+}
+
+func TestNewWriter_UnsupportedType(t *testing.T) {
+	_, err := NewWriter("application/xml", io.Discard, nil)
+	assert.Error(t, err)
+}
@@ -0,0 +1,106 @@
+package graph
+
+import (
+	"cruder/internal/errors"
+	"strings"
+
+	"github.com/go-playground/validator/v10"
+	"github.com/graphql-go/graphql/gqlerrors"
+
+	stdErrors "errors"
+)
+
+// Error codes reported in extensions.code, following the names common GraphQL clients expect.
+const (
+	CodeParseFailed      = "GRAPHQL_PARSE_FAILED"
+	CodeValidationFailed = "GRAPHQL_VALIDATION_FAILED"
+	CodeQueryTooComplex  = "QUERY_TOO_COMPLEX"
+	CodeForbidden        = "FORBIDDEN"
+	CodeBadUserInput     = "BAD_USER_INPUT"
+	CodeNotFound         = "NOT_FOUND"
+	CodeConflict         = "CONFLICT"
+	CodeInternal         = "INTERNAL_SERVER_ERROR"
+)
+
+// extensionsFor maps an error returned by a resolver to the extensions of its GraphQL error,
+// the way the REST controllers map domain errors to HTTP status codes.
+func extensionsFor(err error) map[string]interface{} {
+	var ve validator.ValidationErrors
+	switch {
+	case stdErrors.As(err, &ve):
+		return map[string]interface{}{"code": CodeBadUserInput, "fields": fieldErrors(ve)}
+	case stdErrors.Is(err, errors.ErrInvalidInput):
+		return map[string]interface{}{"code": CodeBadUserInput}
+	case stdErrors.Is(err, errors.ErrUserNotFound):
+		return map[string]interface{}{"code": CodeNotFound}
+	case stdErrors.Is(err, errors.ErrUsernameExists), stdErrors.Is(err, errors.ErrUsernameReserved):
+		return map[string]interface{}{"code": CodeConflict, "field": "username"}
+	case stdErrors.Is(err, errors.ErrEmailExists):
+		return map[string]interface{}{"code": CodeConflict, "field": "email"}
+	default:
+		return map[string]interface{}{"code": CodeInternal}
+	}
+}
+
+// fieldErrors keys validation failures by input field name (fullName, not FullName).
+func fieldErrors(ve validator.ValidationErrors) map[string]string {
+	fields := make(map[string]string, len(ve))
+	for _, fe := range ve {
+		name := strings.ToLower(fe.Field()[:1]) + fe.Field()[1:]
+		switch fe.Tag() {
+		case "required":
+			fields[name] = "This field is required"
+		case "email":
+			fields[name] = "Invalid email format"
+		case "min":
+			fields[name] = "Value is too short (minimum " + fe.Param() + " characters)"
+		case "max":
+			fields[name] = "Value is too long (maximum " + fe.Param() + " characters)"
+		case "alphanum":
+			fields[name] = "Must contain only alphanumeric characters"
+		default:
+			fields[name] = "Invalid value"
+		}
+	}
+	return fields
+}
+
+// resolverError digs the error a resolver returned out of the wrappers the executor adds.
+// Errors raised by the executor itself (e.g. a non-null violation) have no resolver error.
+func resolverError(fe gqlerrors.FormattedError) error {
+	var err error = fe
+	for {
+		switch e := err.(type) {
+		case gqlerrors.FormattedError:
+			if e.OriginalError() == nil {
+				return nil
+			}
+			err = e.OriginalError()
+		case *gqlerrors.Error:
+			if e.OriginalError == nil {
+				return nil
+			}
+			err = e.OriginalError
+		default:
+			return err
+		}
+	}
+}
+
+// annotate sets extensions on every error that came from a resolver.
+func annotate(errs []gqlerrors.FormattedError) []gqlerrors.FormattedError {
+	for i := range errs {
+		if err := resolverError(errs[i]); err != nil {
+			errs[i].Extensions = extensionsFor(err)
+		}
+	}
+	return errs
+}
+
+// withCode sets the same extensions.code on every error, for failures before execution.
+func withCode(code string, errs ...gqlerrors.FormattedError) []gqlerrors.FormattedError {
+	for i := range errs {
+		errs[i].Extensions = map[string]interface{}{"code": code}
+	}
+	return errs
+}
@@ -0,0 +1,120 @@
+package graph
+
+import (
+	"cruder/internal/service"
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/graphql-go/graphql/language/ast"
+)
+
+// Limits bound how much work a single query can ask for. They are checked on the parsed
+// query before any resolver runs, so an expensive query costs nothing but the parse.
+type Limits struct {
+	// MaxDepth is the deepest allowed field nesting; `{ user { id } }` has depth 2.
+	MaxDepth int
+	// MaxComplexity caps the estimated number of resolved fields. Each field costs 1,
+	// and the selection under a paginated field is multiplied by its page size.
+	MaxComplexity int
+}
+
+// DefaultLimits admit any reasonable client query, including full introspection.
+var DefaultLimits = Limits{MaxDepth: 8, MaxComplexity: 5000}
+
+// cost is the measured size of a selection set.
+type cost struct {
+	depth      int
+	complexity int
+}
+
+type measurer struct {
+	fragments map[string]*ast.FragmentDefinition
+	variables map[string]interface{}
+}
+
+// checkLimits measures the operation that will execute and rejects it if it exceeds limits.
+// The document must already have passed validation, which rules out fragment cycles.
+func checkLimits(doc *ast.Document, operationName string, variables map[string]interface{}, limits Limits) error {
+	m := measurer{fragments: make(map[string]*ast.FragmentDefinition), variables: variables}
+	var operation *ast.OperationDefinition
+	for _, def := range doc.Definitions {
+		switch def := def.(type) {
+		case *ast.FragmentDefinition:
+			m.fragments[def.Name.Value] = def
+		case *ast.OperationDefinition:
+			if operation == nil && (operationName == "" || (def.Name != nil && def.Name.Value == operationName)) {
+				operation = def
+			}
+		}
+	}
+	if operation == nil {
+		// The executor reports the missing operation
+		return nil
+	}
+
+	c := m.selectionSet(operation.SelectionSet)
+	if c.depth > limits.MaxDepth {
+		return fmt.Errorf("query depth %d exceeds the maximum of %d", c.depth, limits.MaxDepth)
+	}
+	if c.complexity > limits.MaxComplexity {
+		return fmt.Errorf("query complexity %d exceeds the maximum of %d", c.complexity, limits.MaxComplexity)
+	}
+	return nil
+}
+
+func (m measurer) selectionSet(set *ast.SelectionSet) cost {
+	var total cost
+	if set == nil {
+		return total
+	}
+	for _, sel := range set.Selections {
+		var c cost
+		switch sel := sel.(type) {
+		case *ast.Field:
+			// Introspection is bounded by the schema, not by the data
+			if strings.HasPrefix(sel.Name.Value, "__") {
+				continue
+			}
+			child := m.selectionSet(sel.SelectionSet)
+			c = cost{depth: child.depth + 1, complexity: 1 + m.pageSize(sel)*child.complexity}
+		case *ast.InlineFragment:
+			c = m.selectionSet(sel.SelectionSet)
+		case *ast.FragmentSpread:
+			if frag, ok := m.fragments[sel.Name.Value]; ok {
+				c = m.selectionSet(frag.SelectionSet)
+			}
+		}
+		total.depth = max(total.depth, c.depth)
+		total.complexity += c.complexity
+	}
+	return total
+}
+
+// pageSize is the value of a field's `first` argument, or 1 for fields that do not paginate.
+func (m measurer) pageSize(field *ast.Field) int {
+	for _, arg := range field.Arguments {
+		if arg.Name.Value != "first" {
+			continue
+		}
+		switch v := arg.Value.(type) {
+		case *ast.IntValue:
+			if n, err := strconv.Atoi(v.Value); err == nil {
+				return max(n, 1)
+			}
+		case *ast.Variable:
+			// JSON numbers decode to float64
+			switch n := m.variables[v.Name.Value].(type) {
+			case float64:
+				return max(int(n), 1)
+			case int:
+				return max(n, 1)
+			}
+		}
+		return service.DefaultPageSize
+	}
+	if paginatedFields[field.Name.Value] {
+		return service.DefaultPageSize
+	}
+	return 1
+}
@@ -0,0 +1,81 @@
+package graph
+
+import (
+	"context"
+	"cruder/internal/model"
+	"cruder/internal/service"
+	"sync"
+
+	"github.com/google/uuid"
+)
+
+// userLoader batches GetByID lookups made while resolving one request.
+// Resolvers call load, which only queues the ID and returns a thunk; the executor
+// resolves every sibling field before it runs the thunks, so the first thunk fetches
+// all queued IDs with a single GetByIDs call and the rest are served from the cache.
+type userLoader struct {
+	users service.UserService
+
+	mu      sync.Mutex
+	pending []uuid.UUID
+	cache   map[uuid.UUID]*model.User
+	err     error
+}
+
+func newUserLoader(users service.UserService) *userLoader {
+	return &userLoader{users: users, cache: make(map[uuid.UUID]*model.User)}
+}
+
+type loaderKey struct{}
+
+func withLoader(ctx context.Context, l *userLoader) context.Context {
+	return context.WithValue(ctx, loaderKey{}, l)
+}
+
+func loaderFrom(ctx context.Context) *userLoader {
+	return ctx.Value(loaderKey{}).(*userLoader)
+}
+
+// load returns a thunk resolving to the user with id, or nil if there is none.
+func (l *userLoader) load(id uuid.UUID) func() (interface{}, error) {
+	l.mu.Lock()
+	if _, cached := l.cache[id]; !cached {
+		l.pending = append(l.pending, id)
+	}
+	l.mu.Unlock()
+
+	return func() (interface{}, error) {
+		l.mu.Lock()
+		defer l.mu.Unlock()
+
+		if len(l.pending) > 0 {
+			l.dispatch()
+		}
+		if l.err != nil {
+			return nil, l.err
+		}
+		if u := l.cache[id]; u != nil {
+			return u, nil
+		}
+		return nil, nil
+	}
+}
+
+// dispatch fetches every pending ID. Callers hold l.mu.
+func (l *userLoader) dispatch() {
+	ids := l.pending
+	l.pending = nil
+
+	users, err := l.users.GetByIDs(ids)
+	if err != nil {
+		l.err = err
+		return
+	}
+	// Record misses too so they are not fetched again
+	for _, id := range ids {
+		l.cache[id] = nil
+	}
+	for i := range users {
+		l.cache[users[i].ID] = &users[i]
+	}
+}
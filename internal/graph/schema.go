@@ -0,0 +1,356 @@
+// Package graph serves the users API over GraphQL, on top of the same service.UserService as REST and gRPC.
+package graph
+
+import (
+	"context"
+	"cruder/internal/auth"
+	"cruder/internal/errors"
+	"cruder/internal/model"
+	"cruder/internal/service"
+	"encoding/base64"
+	"fmt"
+
+	"github.com/gin-gonic/gin/binding"
+	"github.com/google/uuid"
+	"github.com/graphql-go/graphql"
+	"github.com/graphql-go/graphql/gqlerrors"
+	"github.com/graphql-go/graphql/language/parser"
+	"github.com/graphql-go/graphql/language/source"
+
+	stdErrors "errors"
+)
+
+// Request is a GraphQL-over-HTTP request body.
+type Request struct {
+	Query         string                 `json:"query" binding:"required"`
+	OperationName string                 `json:"operationName"`
+	Variables     map[string]interface{} `json:"variables"`
+}
+
+// Schema executes GraphQL requests against a UserService.
+type Schema struct {
+	schema graphql.Schema
+	users  service.UserService
+	limits Limits
+}
+
+// paginatedFields are the connection fields whose selections repeat once per item.
+var paginatedFields = map[string]bool{"users": true}
+
+// NewSchema builds the schema. users is used for every request; limits bound each query.
+func NewSchema(users service.UserService, limits Limits) (*Schema, error) {
+	r := &resolver{users: users}
+
+	userType := graphql.NewObject(graphql.ObjectConfig{
+		Name: "User",
+		Fields: graphql.Fields{
+			"id":       userField(graphql.ID, func(u *model.User) interface{} { return u.ID.String() }),
+			"username": userField(graphql.String, func(u *model.User) interface{} { return u.Username }),
+			"email":    userField(graphql.String, func(u *model.User) interface{} { return u.Email }),
+			"fullName": userField(graphql.String, func(u *model.User) interface{} { return u.FullName }),
+			// invited, active, suspended or deactivated
+			"status":    userField(graphql.String, func(u *model.User) interface{} { return string(u.Status) }),
+			"createdAt": userField(graphql.DateTime, func(u *model.User) interface{} { return u.CreatedAt }),
+			"updatedAt": userField(graphql.DateTime, func(u *model.User) interface{} { return u.UpdatedAt }),
+			// Null until the user confirms their address
+			"emailVerifiedAt": &graphql.Field{
+				Type: graphql.DateTime,
+				Resolve: func(p graphql.ResolveParams) (interface{}, error) {
+					if at := p.Source.(*model.User).EmailVerifiedAt; at != nil {
+						return *at, nil
+					}
+					return nil, nil
+				},
+			},
+		},
+	})
+
+	edgeType := graphql.NewObject(graphql.ObjectConfig{
+		Name: "UserEdge",
+		Fields: graphql.Fields{
+			"cursor": &graphql.Field{Type: graphql.NewNonNull(graphql.String)},
+			"node":   &graphql.Field{Type: graphql.NewNonNull(userType)},
+		},
+	})
+	pageInfoType := graphql.NewObject(graphql.ObjectConfig{
+		Name: "PageInfo",
+		Fields: graphql.Fields{
+			"hasNextPage": &graphql.Field{Type: graphql.NewNonNull(graphql.Boolean)},
+			"endCursor":   &graphql.Field{Type: graphql.String},
+		},
+	})
+	connectionType := graphql.NewObject(graphql.ObjectConfig{
+		Name: "UserConnection",
+		Fields: graphql.Fields{
+			"edges":    &graphql.Field{Type: graphql.NewNonNull(graphql.NewList(graphql.NewNonNull(edgeType)))},
+			"pageInfo": &graphql.Field{Type: graphql.NewNonNull(pageInfoType)},
+		},
+	})
+
+	createInput := graphql.NewInputObject(graphql.InputObjectConfig{
+		Name: "CreateUserInput",
+		Fields: graphql.InputObjectConfigFieldMap{
+			"username": &graphql.InputObjectFieldConfig{Type: graphql.NewNonNull(graphql.String)},
+			"email":    &graphql.InputObjectFieldConfig{Type: graphql.NewNonNull(graphql.String)},
+			"fullName": &graphql.InputObjectFieldConfig{Type: graphql.NewNonNull(graphql.String)},
+		},
+	})
+	updateInput := graphql.NewInputObject(graphql.InputObjectConfig{
+		Name:        "UpdateUserInput",
+		Description: "Fields left out are not changed.",
+		Fields: graphql.InputObjectConfigFieldMap{
+			"username": &graphql.InputObjectFieldConfig{Type: graphql.String},
+			"email":    &graphql.InputObjectFieldConfig{Type: graphql.String},
+			"fullName": &graphql.InputObjectFieldConfig{Type: graphql.String},
+		},
+	})
+
+	query := graphql.NewObject(graphql.ObjectConfig{
+		Name: "Query",
+		Fields: graphql.Fields{
+			"user": &graphql.Field{
+				Type:        userType,
+				Description: "The user with this ID, or null.",
+				Args:        graphql.FieldConfigArgument{"id": {Type: graphql.NewNonNull(graphql.ID)}},
+				Resolve:     r.user,
+			},
+			"userByUsername": &graphql.Field{
+				Type:        userType,
+				Description: "The user with this username (case-insensitive), or null.",
+				Args:        graphql.FieldConfigArgument{"username": {Type: graphql.NewNonNull(graphql.String)}},
+				Resolve:     r.userByUsername,
+			},
+			"users": &graphql.Field{
+				Type:        graphql.NewNonNull(connectionType),
+				Description: fmt.Sprintf("Users ordered by ID, %d per page unless `first` says otherwise (at most %d).", service.DefaultPageSize, service.MaxPageSize),
+				Args: graphql.FieldConfigArgument{
+					"first": {Type: graphql.Int},
+					"after": {Type: graphql.String, Description: "endCursor of the previous page"},
+				},
+				Resolve: r.userConnection,
+			},
+		},
+	})
+
+	mutation := graphql.NewObject(graphql.ObjectConfig{
+		Name: "Mutation",
+		Fields: graphql.Fields{
+			"createUser": &graphql.Field{
+				Type:    graphql.NewNonNull(userType),
+				Args:    graphql.FieldConfigArgument{"input": {Type: graphql.NewNonNull(createInput)}},
+				Resolve: r.createUser,
+			},
+			"updateUser": &graphql.Field{
+				Type: graphql.NewNonNull(userType),
+				Args: graphql.FieldConfigArgument{
+					"id":    {Type: graphql.NewNonNull(graphql.ID)},
+					"input": {Type: graphql.NewNonNull(updateInput)},
+				},
+				Resolve: r.updateUser,
+			},
+			"deleteUser": &graphql.Field{
+				Type:        graphql.NewNonNull(graphql.ID),
+				Description: "Deletes the user and returns its ID. Deleting a missing user succeeds, like DELETE on the REST API.",
+				Args:        graphql.FieldConfigArgument{"id": {Type: graphql.NewNonNull(graphql.ID)}},
+				Resolve:     r.deleteUser,
+			},
+		},
+	})
+
+	schema, err := graphql.NewSchema(graphql.SchemaConfig{Query: query, Mutation: mutation})
+	if err != nil {
+		return nil, err
+	}
+	return &Schema{schema: schema, users: users, limits: limits}, nil
+}
+
+// MustNewSchema is NewSchema for static configuration, where an error is a programming mistake.
+func MustNewSchema(users service.UserService, limits Limits) *Schema {
+	s, err := NewSchema(users, limits)
+	if err != nil {
+		panic(fmt.Sprintf("graph: invalid schema: %v", err))
+	}
+	return s
+}
+
+func userField(t graphql.Type, get func(u *model.User) interface{}) *graphql.Field {
+	return &graphql.Field{
+		Type: graphql.NewNonNull(t),
+		Resolve: func(p graphql.ResolveParams) (interface{}, error) {
+			return get(p.Source.(*model.User)), nil
+		},
+	}
+}
+
+// Execute parses, validates and limit-checks req, then runs it. Errors are reported in the
+// result, never returned, as GraphQL responses carry them alongside any partial data.
+func (s *Schema) Execute(ctx context.Context, req Request) *graphql.Result {
+	doc, err := parser.Parse(parser.ParseParams{Source: source.NewSource(&source.Source{Body: []byte(req.Query), Name: "GraphQL request"})})
+	if err != nil {
+		return &graphql.Result{Errors: withCode(CodeParseFailed, gqlerrors.FormatError(err))}
+	}
+	if validation := graphql.ValidateDocument(&s.schema, doc, nil); !validation.IsValid {
+		return &graphql.Result{Errors: withCode(CodeValidationFailed, validation.Errors...)}
+	}
+	if err := checkLimits(doc, req.OperationName, req.Variables, s.limits); err != nil {
+		return &graphql.Result{Errors: withCode(CodeQueryTooComplex, gqlerrors.FormatError(err))}
+	}
+	if err := checkScope(ctx, doc, req.OperationName); err != nil {
+		return &graphql.Result{Errors: withCode(CodeForbidden, gqlerrors.FormatError(err))}
+	}
+
+	result := graphql.Execute(graphql.ExecuteParams{
+		Schema:        s.schema,
+		AST:           doc,
+		OperationName: req.OperationName,
+		Args:          req.Variables,
+		Context:       withLoader(ctx, newUserLoader(s.users.InOrganization(auth.OrganizationFrom(ctx)))),
+	})
+	result.Errors = annotate(result.Errors)
+	return result
+}
+
+type resolver struct {
+	users service.UserService
+}
+
+// in returns the users of the organization the caller acts in.
+func (r *resolver) in(p graphql.ResolveParams) service.UserService {
+	return r.users.InOrganization(auth.OrganizationFrom(p.Context))
+}
+
+func parseID(v interface{}) (uuid.UUID, error) {
+	id, err := uuid.Parse(v.(string))
+	if err != nil {
+		return uuid.Nil, fmt.Errorf("%w: id must be a valid UUID", errors.ErrInvalidInput)
+	}
+	return id, nil
+}
+
+// user goes through the request's loader, so `{ a: user(id: ...) b: user(id: ...) }` is one query.
+func (r *resolver) user(p graphql.ResolveParams) (interface{}, error) {
+	id, err := parseID(p.Args["id"])
+	if err != nil {
+		return nil, err
+	}
+	return loaderFrom(p.Context).load(id), nil
+}
+
+func (r *resolver) userByUsername(p graphql.ResolveParams) (interface{}, error) {
+	user, err := r.in(p).GetByUsername(p.Args["username"].(string), nil)
+	if stdErrors.Is(err, errors.ErrUserNotFound) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return user, nil
+}
+
+// Cursors are opaque to clients; internally they carry the ID of the edge's user.
+func encodeCursor(id uuid.UUID) string {
+	return base64.RawURLEncoding.EncodeToString([]byte(id.String()))
+}
+
+func decodeCursor(cursor string) (*uuid.UUID, error) {
+	raw, err := base64.RawURLEncoding.DecodeString(cursor)
+	if err != nil {
+		return nil, fmt.Errorf("%w: invalid cursor", errors.ErrInvalidInput)
+	}
+	id, err := uuid.ParseBytes(raw)
+	if err != nil {
+		return nil, fmt.Errorf("%w: invalid cursor", errors.ErrInvalidInput)
+	}
+	return &id, nil
+}
+
+func (r *resolver) userConnection(p graphql.ResolveParams) (interface{}, error) {
+	params := model.ListUsersParams{Limit: service.DefaultPageSize}
+	if first, ok := p.Args["first"].(int); ok {
+		if first < 1 || first > service.MaxPageSize {
+			return nil, fmt.Errorf("%w: first must be between 1 and %d", errors.ErrInvalidInput, service.MaxPageSize)
+		}
+		params.Limit = first
+	}
+	if after, ok := p.Args["after"].(string); ok {
+		id, err := decodeCursor(after)
+		if err != nil {
+			return nil, err
+		}
+		params.After = id
+	}
+
+	users, err := r.in(p).List(params)
+	if err != nil {
+		return nil, err
+	}
+	// A full page may be the last one; look for a user after it
+	hasNextPage := false
+	if len(users) == params.Limit {
+		next, err := r.in(p).List(model.ListUsersParams{Limit: 1, After: &users[len(users)-1].ID})
+		if err != nil {
+			return nil, err
+		}
+		hasNextPage = len(next) > 0
+	}
+
+	edges := make([]map[string]interface{}, 0, len(users))
+	pageInfo := map[string]interface{}{"hasNextPage": hasNextPage, "endCursor": nil}
+	for i := range users {
+		cursor := encodeCursor(users[i].ID)
+		edges = append(edges, map[string]interface{}{"cursor": cursor, "node": &users[i]})
+		pageInfo["endCursor"] = cursor
+	}
+	return map[string]interface{}{"edges": edges, "pageInfo": pageInfo}, nil
+}
+
+// optionalString returns a pointer to input[key], or nil if the field was left out.
+func optionalString(input map[string]interface{}, key string) *string {
+	if v, ok := input[key].(string); ok {
+		return &v
+	}
+	return nil
+}
+
+func (r *resolver) createUser(p graphql.ResolveParams) (interface{}, error) {
+	input := p.Args["input"].(map[string]interface{})
+	req := model.CreateUserRequest{
+		Username: input["username"].(string),
+		Email:    input["email"].(string),
+		FullName: input["fullName"].(string),
+	}
+	// Same binding rules gin enforces on the REST request body
+	if err := binding.Validator.ValidateStruct(&req); err != nil {
+		return nil, err
+	}
+	return r.in(p).Create(&req)
+}
+
+func (r *resolver) updateUser(p graphql.ResolveParams) (interface{}, error) {
+	id, err := parseID(p.Args["id"])
+	if err != nil {
+		return nil, err
+	}
+	input := p.Args["input"].(map[string]interface{})
+	req := model.UpdateUserRequest{
+		Username: optionalString(input, "username"),
+		Email:    optionalString(input, "email"),
+		FullName: optionalString(input, "fullName"),
+		Actor:    auth.ActorFrom(p.Context),
+	}
+	if err := binding.Validator.ValidateStruct(&req); err != nil {
+		return nil, err
+	}
+	return r.in(p).Update(id, &req)
+}
+
+func (r *resolver) deleteUser(p graphql.ResolveParams) (interface{}, error) {
+	id, err := parseID(p.Args["id"])
+	if err != nil {
+		return nil, err
+	}
+	if err := r.in(p).Delete(id, auth.ActorFrom(p.Context)); err != nil && !stdErrors.Is(err, errors.ErrUserNotFound) {
+		return nil, err
+	}
+	return id.String(), nil
+}
@@ -0,0 +1,231 @@
+package graph
+
+import (
+	"context"
+	"cruder/internal/auth"
+	"cruder/internal/model"
+	"cruder/internal/repository/memory"
+	"cruder/internal/service"
+	"encoding/json"
+	"testing"
+
+	"github.com/google/uuid"
+	"github.com/graphql-go/graphql"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// countingUsers records how the resolvers reach the service.
+type countingUsers struct {
+	service.UserService
+	getByIDCalls  int
+	getByIDsCalls int
+}
+
+// InOrganization keeps counting; the tests only use the default organization.
+func (c *countingUsers) InOrganization(uuid.UUID) service.UserService {
+	return c
+}
+
+func (c *countingUsers) GetByID(id uuid.UUID, fields model.UserFields) (*model.User, error) {
+	c.getByIDCalls++
+	return c.UserService.GetByID(id, fields)
+}
+
+func (c *countingUsers) GetByIDs(ids []uuid.UUID) ([]model.User, error) {
+	c.getByIDsCalls++
+	return c.UserService.GetByIDs(ids)
+}
+
+func newTestSchema(t *testing.T, limits Limits) (*Schema, *countingUsers) {
+	t.Helper()
+	users := &countingUsers{UserService: service.NewUserService(memory.NewUserRepository())}
+	s, err := NewSchema(users, limits)
+	require.NoError(t, err)
+	return s, users
+}
+
+func createUser(t *testing.T, users service.UserService, username string) *model.User {
+	t.Helper()
+	u, err := users.Create(&model.CreateUserRequest{Username: username, Email: username + "@example.com", FullName: "Test User"})
+	require.NoError(t, err)
+	return u
+}
+
+// data round-trips the result through JSON so tests can index it like a client would.
+func data(t *testing.T, result *graphql.Result) map[string]any {
+	t.Helper()
+	require.Empty(t, result.Errors)
+	raw, err := json.Marshal(result.Data)
+	require.NoError(t, err)
+	var out map[string]any
+	require.NoError(t, json.Unmarshal(raw, &out))
+	return out
+}
+
+func TestExecute_BatchesUserLookups(t *testing.T) {
+	s, users := newTestSchema(t, DefaultLimits)
+	a := createUser(t, users, "alice")
+	b := createUser(t, users, "bob")
+
+	result := s.Execute(context.Background(), Request{
+		Query:     `query($a: ID!, $b: ID!, $missing: ID!) { a: user(id: $a) { username } b: user(id: $b) { username } again: user(id: $a) { email } none: user(id: $missing) { id } }`,
+		Variables: map[string]any{"a": a.ID.String(), "b": b.ID.String(), "missing": uuid.NewString()},
+	})
+
+	got := data(t, result)
+	assert.Equal(t, "alice", got["a"].(map[string]any)["username"])
+	assert.Equal(t, "bob", got["b"].(map[string]any)["username"])
+	assert.Equal(t, "alice@example.com", got["again"].(map[string]any)["email"])
+	assert.Nil(t, got["none"])
+	assert.Equal(t, 1, users.getByIDsCalls)
+	assert.Zero(t, users.getByIDCalls)
+}
+
+func TestExecute_UsersConnection(t *testing.T) {
+	s, users := newTestSchema(t, DefaultLimits)
+	for _, name := range []string{"alice", "bob", "carol"} {
+		createUser(t, users, name)
+	}
+
+	query := `query($after: String) { users(first: 2, after: $after) { edges { node { username } } pageInfo { hasNextPage endCursor } } }`
+	seen := 0
+	var after any
+	for {
+		conn := data(t, s.Execute(context.Background(), Request{Query: query, Variables: map[string]any{"after": after}}))["users"].(map[string]any)
+		seen += len(conn["edges"].([]any))
+		pageInfo := conn["pageInfo"].(map[string]any)
+		if !pageInfo["hasNextPage"].(bool) {
+			break
+		}
+		after = pageInfo["endCursor"]
+	}
+	assert.Equal(t, 3, seen)
+}
+
+func TestExecute_UsersConnectionPageBounds(t *testing.T) {
+	s, users := newTestSchema(t, DefaultLimits)
+	ctx := context.Background()
+	for _, name := range []string{"alice", "bob", "carol", "dave"} {
+		createUser(t, users, name)
+	}
+
+	query := `query($first: Int, $after: String) { users(first: $first, after: $after) { edges { cursor } pageInfo { hasNextPage endCursor } } }`
+	result := s.Execute(ctx, Request{Query: query, Variables: map[string]any{"first": float64(0)}})
+	require.Len(t, result.Errors, 1)
+	assert.Equal(t, CodeBadUserInput, result.Errors[0].Extensions["code"])
+
+	// Two full pages; the second is the last
+	first := data(t, s.Execute(ctx, Request{Query: query, Variables: map[string]any{"first": float64(2)}}))["users"].(map[string]any)
+	assert.True(t, first["pageInfo"].(map[string]any)["hasNextPage"].(bool))
+	last := data(t, s.Execute(ctx, Request{Query: query, Variables: map[string]any{"first": float64(2), "after": first["pageInfo"].(map[string]any)["endCursor"]}}))["users"].(map[string]any)
+	assert.Len(t, last["edges"], 2)
+	assert.False(t, last["pageInfo"].(map[string]any)["hasNextPage"].(bool))
+}
+
+func TestExecute_Mutations(t *testing.T) {
+	s, _ := newTestSchema(t, DefaultLimits)
+	ctx := context.Background()
+
+	created := data(t, s.Execute(ctx, Request{
+		Query: `mutation { createUser(input: {username: "JDoe", email: "jdoe@example.com", fullName: "John Doe"}) { id username } }`,
+	}))["createUser"].(map[string]any)
+	assert.Equal(t, "jdoe", created["username"])
+
+	updated := data(t, s.Execute(ctx, Request{
+		Query:     `mutation($id: ID!) { updateUser(id: $id, input: {fullName: "Johnny Doe"}) { fullName email } }`,
+		Variables: map[string]any{"id": created["id"]},
+	}))["updateUser"].(map[string]any)
+	assert.Equal(t, "Johnny Doe", updated["fullName"])
+	assert.Equal(t, "jdoe@example.com", updated["email"])
+
+	deleted := data(t, s.Execute(ctx, Request{
+		Query:     `mutation($id: ID!) { deleteUser(id: $id) }`,
+		Variables: map[string]any{"id": created["id"]},
+	}))
+	assert.Equal(t, created["id"], deleted["deleteUser"])
+
+	lookup := data(t, s.Execute(ctx, Request{Query: `{ userByUsername(username: "jdoe") { id } }`}))
+	assert.Nil(t, lookup["userByUsername"])
+}
+
+func TestExecute_ErrorExtensions(t *testing.T) {
+	s, users := newTestSchema(t, DefaultLimits)
+	createUser(t, users, "jdoe")
+	ctx := context.Background()
+
+	result := s.Execute(ctx, Request{
+		Query: `mutation { createUser(input: {username: "x", email: "not-an-email", fullName: "John Doe"}) { id } }`,
+	})
+	require.Len(t, result.Errors, 1)
+	assert.Equal(t, CodeBadUserInput, result.Errors[0].Extensions["code"])
+	fields := result.Errors[0].Extensions["fields"].(map[string]string)
+	assert.Contains(t, fields, "username")
+	assert.Contains(t, fields, "email")
+
+	result = s.Execute(ctx, Request{
+		Query: `mutation { createUser(input: {username: "jdoe", email: "other@example.com", fullName: "John Doe"}) { id } }`,
+	})
+	require.Len(t, result.Errors, 1)
+	assert.Equal(t, CodeConflict, result.Errors[0].Extensions["code"])
+	assert.Equal(t, "username", result.Errors[0].Extensions["field"])
+
+	result = s.Execute(ctx, Request{Query: `{ user(id: "not-a-uuid") { id } }`})
+	require.Len(t, result.Errors, 1)
+	assert.Equal(t, CodeBadUserInput, result.Errors[0].Extensions["code"])
+
+	result = s.Execute(ctx, Request{Query: `{ nope }`})
+	require.Len(t, result.Errors, 1)
+	assert.Equal(t, CodeValidationFailed, result.Errors[0].Extensions["code"])
+
+	result = s.Execute(ctx, Request{Query: `{ user(`})
+	require.Len(t, result.Errors, 1)
+	assert.Equal(t, CodeParseFailed, result.Errors[0].Extensions["code"])
+}
+
+func TestExecute_Limits(t *testing.T) {
+	s, _ := newTestSchema(t, Limits{MaxDepth: 3, MaxComplexity: 50})
+	ctx := context.Background()
+
+	// users > edges > node > id is four levels deep
+	result := s.Execute(ctx, Request{Query: `{ users(first: 1) { edges { node { id } } } }`})
+	require.Len(t, result.Errors, 1)
+	assert.Equal(t, CodeQueryTooComplex, result.Errors[0].Extensions["code"])
+
+	// Fragments count towards depth too
+	result = s.Execute(ctx, Request{Query: `{ users(first: 1) { ...E } } fragment E on UserConnection { edges { node { id } } }`})
+	require.Len(t, result.Errors, 1)
+	assert.Equal(t, CodeQueryTooComplex, result.Errors[0].Extensions["code"])
+
+	// 1 + 30 * (1 + 1) = 61 fields
+	result = s.Execute(ctx, Request{Query: `query($n: Int) { users(first: $n) { pageInfo { hasNextPage } } }`, Variables: map[string]any{"n": float64(30)}})
+	require.Len(t, result.Errors, 1)
+	assert.Equal(t, CodeQueryTooComplex, result.Errors[0].Extensions["code"])
+
+	result = s.Execute(ctx, Request{Query: `{ users(first: 10) { pageInfo { hasNextPage } } }`})
+	assert.Empty(t, result.Errors)
+
+	// Introspection is exempt
+	result = s.Execute(ctx, Request{Query: `{ __schema { types { name fields { name type { name ofType { name ofType { name } } } } } } }`})
+	assert.Empty(t, result.Errors)
+}
+
+func TestExecute_MutationsRequireWriteScope(t *testing.T) {
+	s, users := newTestSchema(t, DefaultLimits)
+	u := createUser(t, users, "jdoe")
+	reader := auth.WithPrincipal(context.Background(), &model.Principal{Subject: "reader", Scopes: []string{model.ScopeUsersRead}})
+
+	result := s.Execute(reader, Request{Query: `mutation($id: ID!) { deleteUser(id: $id) }`, Variables: map[string]any{"id": u.ID.String()}})
+	require.Len(t, result.Errors, 1)
+	assert.Equal(t, CodeForbidden, result.Errors[0].Extensions["code"])
+	_, err := users.GetByID(u.ID, nil)
+	require.NoError(t, err, "the mutation did not run")
+
+	// Queries in the same document stay allowed
+	doc := `query Read { user(id: "` + u.ID.String() + `") { username } } mutation Drop { deleteUser(id: "` + u.ID.String() + `") }`
+	out := data(t, s.Execute(reader, Request{Query: doc, OperationName: "Read"}))
+	assert.Equal(t, "jdoe", out["user"].(map[string]any)["username"])
+	result = s.Execute(reader, Request{Query: doc, OperationName: "Drop"})
+	require.Len(t, result.Errors, 1)
+	assert.Equal(t, CodeForbidden, result.Errors[0].Extensions["code"])
+}
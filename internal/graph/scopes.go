@@ -0,0 +1,31 @@
+package graph
+
+import (
+	"context"
+	"cruder/internal/auth"
+	"cruder/internal/model"
+
+	"github.com/graphql-go/graphql/language/ast"
+
+	stdErrors "errors"
+)
+
+// checkScope rejects mutations from callers without the users:write scope. Queries are POSTed
+// as well, so the route itself only requires users:read.
+func checkScope(ctx context.Context, doc *ast.Document, operationName string) error {
+	principal, ok := auth.PrincipalFrom(ctx)
+	if !ok || principal.HasScope(model.ScopeUsersWrite) {
+		return nil
+	}
+	for _, def := range doc.Definitions {
+		op, ok := def.(*ast.OperationDefinition)
+		if !ok || (operationName != "" && (op.Name == nil || op.Name.Value != operationName)) {
+			continue
+		}
+		if op.Operation == ast.OperationTypeMutation {
+			return stdErrors.New("mutations require the " + model.ScopeUsersWrite + " scope")
+		}
+		return nil
+	}
+	return nil
+}
@@ -2,26 +2,167 @@ package handler
 
 import (
 	"cruder/internal/controller"
+	"cruder/internal/middleware"
+	"cruder/internal/model"
 
 	"github.com/gin-gonic/gin"
 )
 
-func New(router *gin.Engine, userController *controller.UserController, healthController *controller.HealthController) *gin.Engine {
+// Controllers are the controllers New routes requests to.
+type Controllers struct {
+	Organizations *controller.OrganizationController
+	Users         *controller.UserController
+	Groups        *controller.GroupController
+	Invitations   *controller.InvitationController
+	Jobs          *controller.JobController
+	Scheduler     *controller.SchedulerController
+	Auth          *controller.AuthController
+	Verification  *controller.VerificationController
+	Health        *controller.HealthController
+	Docs          *controller.DocsController
+	GraphQL       *controller.GraphQLController
+	OAuth         *controller.OAuthController
+	SCIM          *controller.SCIMController
+}
+
+// New registers every route. Each route must also be described in internal/openapi (enforced by router_test.go).
+func New(router *gin.Engine, controllers Controllers) *gin.Engine {
+	// Scopes the caller's API key or access token must hold
+	read := middleware.RequireScope(model.ScopeUsersRead)
+	write := middleware.RequireScope(model.ScopeUsersWrite)
+	admin := middleware.RequireScope(model.ScopeAdmin)
+
 	// Health endpoints for Kubernetes probes and NO authentication required
-	router.GET("/health", healthController.LivenessProbe)
-	router.GET("/ready", healthController.ReadinessProbe)
+	router.GET("/health", controllers.Health.LivenessProbe)
+	router.GET("/ready", controllers.Health.ReadinessProbe)
+
+	// Prometheus metrics, scraped without authentication
+	router.GET("/metrics", controllers.Health.Metrics)
+
+	// API contract and documentation
+	router.GET("/openapi.json", controllers.Docs.OpenAPISpec)
+	router.GET("/docs", controllers.Docs.DocsUI)
+
+	// Public keys for verifying access tokens, served without authentication
+	router.GET("/.well-known/jwks.json", controllers.Auth.JWKS)
+
+	// OpenID Connect provider. Client applications and browsers reach these without API keys:
+	// the sign-in page takes the user's password, the token endpoint the client's credentials
+	router.GET("/.well-known/openid-configuration", controllers.OAuth.Discovery)
+	router.GET("/oauth/authorize", controllers.OAuth.AuthorizePage)
+	router.POST("/oauth/authorize", controllers.OAuth.Authorize)
+	router.POST("/oauth/token", controllers.OAuth.Token)
+	router.GET("/oauth/userinfo", controllers.OAuth.UserInfo)
+	router.POST("/oauth/userinfo", controllers.OAuth.UserInfo)
+
+	// GraphQL shares the authentication and the user service with the REST API; mutations need users:write
+	router.POST("/graphql", read, controllers.GraphQL.Query)
+
+	// SCIM 2.0 provisioning for identity providers, over the same users and API keys as the REST API
+	scimGroup := router.Group("/scim/v2")
+	{
+		scimGroup.GET("/ServiceProviderConfig", read, controllers.SCIM.ServiceProviderConfig)
+		scimGroup.GET("/Schemas", read, controllers.SCIM.ListSchemas)
+		scimGroup.GET("/Schemas/:id", read, controllers.SCIM.GetSchema)
+		scimGroup.GET("/ResourceTypes", read, controllers.SCIM.ListResourceTypes)
+		scimGroup.GET("/ResourceTypes/:id", read, controllers.SCIM.GetResourceType)
+		scimGroup.GET("/Users", read, controllers.SCIM.ListUsers)
+		scimGroup.GET("/Users/:id", read, controllers.SCIM.GetUser)
+		scimGroup.POST("/Users", write, controllers.SCIM.CreateUser)
+		scimGroup.PUT("/Users/:id", write, controllers.SCIM.ReplaceUser)
+		scimGroup.PATCH("/Users/:id", write, controllers.SCIM.PatchUser)
+		scimGroup.DELETE("/Users/:id", write, controllers.SCIM.DeleteUser)
+	}
 
 	v1 := router.Group("/api/v1")
 	{
 		userGroup := v1.Group("/users")
 		{
-			userGroup.GET("", userController.GetAllUsers)
-			userGroup.GET("/username/:username", userController.GetUserByUsername)
-			userGroup.GET("/id/:id", userController.GetUserByID)
-			userGroup.POST("", userController.CreateUser)
-			userGroup.PATCH("/id/:id", userController.UpdateUser)
-			userGroup.DELETE("/id/:id", userController.DeleteUser)
+			userGroup.GET("", read, controllers.Users.GetAllUsers)
+			userGroup.GET("/search", read, controllers.Users.SearchUsers)
+			userGroup.GET("/username/:username", read, controllers.Users.GetUserByUsername)
+			userGroup.GET("/id/:id", read, controllers.Users.GetUserByID)
+			userGroup.GET("/id/:id/username-history", read, controllers.Users.GetUsernameHistory)
+			userGroup.GET("/id/:id/status-history", read, controllers.Users.GetStatusHistory)
+			userGroup.GET("/id/:id/groups", read, controllers.Groups.ListUserGroups)
+			userGroup.GET("/id/:id/oauth-consents", read, controllers.OAuth.ListConsents)
+			userGroup.DELETE("/id/:id/oauth-consents/:client_id", write, controllers.OAuth.RevokeConsent)
+			userGroup.POST("", write, controllers.Users.CreateUser)
+			userGroup.POST("/import", write, controllers.Users.ImportUsers)
+			userGroup.POST("/export", read, controllers.Users.ExportUsers)
+			userGroup.PUT("/id/:id", write, controllers.Users.ReplaceUser)
+			userGroup.PATCH("/id/:id", write, controllers.Users.UpdateUser)
+			userGroup.DELETE("/id/:id", write, controllers.Users.DeleteUser)
+			// Lifecycle transitions; leavers are deactivated rather than deleted so their history stays
+			userGroup.POST("/id/:id/activate", write, controllers.Users.ActivateUser)
+			userGroup.POST("/id/:id/suspend", write, controllers.Users.SuspendUser)
+			userGroup.POST("/id/:id/deactivate", write, controllers.Users.DeactivateUser)
+			userGroup.PUT("/id/:id/password", write, controllers.Auth.ChangePassword)
+			userGroup.POST("/verify-email", write, controllers.Verification.VerifyEmail)
+			userGroup.POST("/id/:id/verification-email", write, controllers.Verification.ResendVerification)
+			userGroup.POST("/confirm-email", write, controllers.Verification.ConfirmEmailChange)
+			userGroup.POST("/revert-email-change", write, controllers.Verification.RevertEmailChange)
+		}
+
+		// Groups of users, which may nest; membership changes are audited and published like user changes
+		groupGroup := v1.Group("/groups")
+		{
+			groupGroup.GET("", read, controllers.Groups.ListGroups)
+			groupGroup.GET("/:id", read, controllers.Groups.GetGroup)
+			groupGroup.GET("/:id/members", read, controllers.Groups.ListGroupMembers)
+			groupGroup.GET("/:id/effective-members", read, controllers.Groups.ListEffectiveMembers)
+			groupGroup.GET("/:id/history", read, controllers.Groups.GetGroupHistory)
+			groupGroup.POST("", write, controllers.Groups.CreateGroup)
+			groupGroup.PATCH("/:id", write, controllers.Groups.UpdateGroup)
+			groupGroup.DELETE("/:id", write, controllers.Groups.DeleteGroup)
+			groupGroup.POST("/:id/members", write, controllers.Groups.AddGroupMember)
+			groupGroup.DELETE("/:id/members/users/:user_id", write, controllers.Groups.RemoveGroupUser)
+			groupGroup.DELETE("/:id/members/groups/:group_id", write, controllers.Groups.RemoveGroupSubgroup)
+		}
+
+		// Invitations to create an account; the invitee redeems the mailed token with their chosen username
+		invitationGroup := v1.Group("/invitations")
+		{
+			invitationGroup.GET("", read, controllers.Invitations.ListInvitations)
+			invitationGroup.GET("/:id", read, controllers.Invitations.GetInvitation)
+			invitationGroup.POST("", write, controllers.Invitations.CreateInvitation)
+			invitationGroup.POST("/redeem", write, controllers.Invitations.RedeemInvitation)
+			invitationGroup.POST("/:id/resend", write, controllers.Invitations.ResendInvitation)
+			invitationGroup.POST("/:id/revoke", write, controllers.Invitations.RevokeInvitation)
+		}
+
+		// Long-running operations started by the endpoints above
+		jobGroup := v1.Group("/jobs")
+		{
+			jobGroup.GET("/:id", read, controllers.Jobs.GetJob)
+			jobGroup.GET("/:id/result", read, controllers.Jobs.GetJobResult)
+			jobGroup.POST("/:id/cancel", write, controllers.Jobs.CancelJob)
 		}
+
+		// Password login for the users this service stores
+		v1.POST("/auth/login", read, controllers.Auth.Login)
+
+		// Access tokens: any API key may be exchanged, for at most its own scopes
+		v1.POST("/auth/token", controllers.Auth.IssueToken)
+		v1.POST("/auth/revoke", admin, controllers.Auth.RevokeTokens)
+
+		// Client IPs banned for presenting too many wrong API keys
+		v1.GET("/auth/bans", admin, controllers.Auth.ListBans)
+		v1.DELETE("/auth/bans/:ip", admin, controllers.Auth.LiftBan)
+
+		// Applications that sign users in through the OpenID Connect provider
+		v1.POST("/oauth/clients", admin, controllers.OAuth.RegisterClient)
+		v1.GET("/oauth/clients", admin, controllers.OAuth.ListClients)
+		v1.GET("/oauth/clients/:id", admin, controllers.OAuth.GetClient)
+		v1.DELETE("/oauth/clients/:id", admin, controllers.OAuth.DeleteClient)
+
+		// Tenants: every caller works in one organization, see middleware.Authenticate
+		v1.POST("/organizations", admin, controllers.Organizations.CreateOrganization)
+		v1.GET("/organizations", admin, controllers.Organizations.ListOrganizations)
+		v1.GET("/organizations/:id", admin, controllers.Organizations.GetOrganization)
+
+		// History of the periodic maintenance tasks run by the scheduler
+		v1.GET("/scheduler/runs", admin, controllers.Scheduler.ListRuns)
 	}
 	return router
 }
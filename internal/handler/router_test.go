@@ -0,0 +1,111 @@
+package handler
+
+import (
+	"cruder/internal/auth"
+	"cruder/internal/controller"
+	"cruder/internal/middleware"
+	"cruder/internal/openapi"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// testControllers have no services; enough to register and match routes.
+func testControllers() Controllers {
+	return Controllers{
+		Organizations: controller.NewOrganizationController(nil),
+		Users:         controller.NewUserController(nil, nil, nil),
+		Groups:        controller.NewGroupController(nil),
+		Invitations:   controller.NewInvitationController(nil),
+		Jobs:          controller.NewJobController(nil),
+		Scheduler:     controller.NewSchedulerController(nil),
+		Auth:          controller.NewAuthController(nil, nil, nil),
+		Verification:  controller.NewVerificationController(nil, nil),
+		Health:        controller.NewHealthController(nil, nil),
+		Docs:          controller.NewDocsController(),
+		GraphQL:       controller.NewGraphQLController(nil),
+		OAuth:         controller.NewOAuthController(nil, nil),
+		SCIM:          controller.NewSCIMController(nil),
+	}
+}
+
+func newTestRouter() *gin.Engine {
+	gin.SetMode(gin.TestMode)
+	r := gin.New()
+	New(r, testControllers())
+	return r
+}
+
+// Every registered route must be documented; add new routes to internal/openapi/spec.go.
+func TestOpenAPISpec_CoversEveryRoute(t *testing.T) {
+	r := newTestRouter()
+	spec := openapi.Spec()
+
+	for _, route := range r.Routes() {
+		assert.True(t, spec.HasOperation(route.Method, route.Path),
+			"route %s %s is not described in the OpenAPI spec", route.Method, route.Path)
+	}
+}
+
+// The reverse: the spec must not advertise operations the router does not serve.
+func TestOpenAPISpec_HasNoStaleOperations(t *testing.T) {
+	r := newTestRouter()
+	registered := map[string]bool{}
+	for _, route := range r.Routes() {
+		registered[route.Method+" "+route.Path] = true
+	}
+
+	count := 0
+	for _, ops := range openapi.Spec().Paths {
+		count += len(ops)
+	}
+	assert.Equal(t, len(registered), count, "spec and router disagree on the number of operations")
+}
+
+// Operations the spec documents without security must be reachable without credentials once an API key is set.
+func TestOpenAPISpec_PublicOperationsNeedNoCredentials(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	r := gin.New()
+	// The controllers have no services; a panic past authentication still shows the request got through
+	r.Use(gin.Recovery())
+	r.Use(middleware.Authenticate(auth.NewAuthenticator([]auth.Client{{ID: auth.DefaultClientID, Key: "secret"}}, nil, nil, nil)))
+	New(r, testControllers())
+
+	public := 0
+	for path, ops := range openapi.Spec().Paths {
+		for method, op := range ops {
+			if op.Security == nil || len(*op.Security) > 0 || strings.Contains(path, "{") {
+				continue
+			}
+			public++
+			w := httptest.NewRecorder()
+			r.ServeHTTP(w, httptest.NewRequest(strings.ToUpper(method), path, nil))
+			assert.NotEqual(t, http.StatusUnauthorized, w.Code, "%s %s is documented as public", method, path)
+		}
+	}
+	assert.Positive(t, public)
+
+	for _, path := range []string{"/openapi.json", "/docs"} {
+		w := httptest.NewRecorder()
+		r.ServeHTTP(w, httptest.NewRequest(http.MethodGet, path, nil))
+		assert.Equal(t, http.StatusOK, w.Code, path)
+	}
+}
+
+func TestOpenAPISpec_ServedAsJSON(t *testing.T) {
+	r := newTestRouter()
+
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/openapi.json", nil))
+
+	require.Equal(t, http.StatusOK, w.Code)
+	var doc map[string]any
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &doc))
+	assert.Equal(t, "3.1.0", doc["openapi"])
+}
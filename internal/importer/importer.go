@@ -0,0 +1,313 @@
+// Package importer creates users in bulk from CSV files, such as the new-joiner lists HR sends,
+// and reports the outcome of every row.
+package importer
+
+import (
+	"context"
+	"cruder/internal/errors"
+	"cruder/internal/model"
+	"cruder/internal/service"
+	"encoding/csv"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+
+	"github.com/gin-gonic/gin/binding"
+	"github.com/go-playground/validator/v10"
+
+	stdErrors "errors"
+)
+
+// Columns are the CSV columns an import needs. Header names are matched case-insensitively,
+// with spaces and hyphens read as underscores ("Full Name" is full_name); other columns are ignored.
+var Columns = []string{"username", "email", "full_name"}
+
+// Options control an import.
+type Options struct {
+	Mode model.ImportMode
+	// DryRun validates every row and reports what would happen without writing anything.
+	DryRun bool
+	// Progress, if set, is called with the number of rows processed so far after each row.
+	Progress func(rows int)
+	// Actor is recorded in the username history for rows that rename a user.
+	Actor string
+}
+
+// Importer runs rows through UserService, so they get the same normalization and
+// business rules as API requests, and change events are published as usual.
+type Importer struct {
+	users service.UserService
+}
+
+func New(users service.UserService) *Importer {
+	return &Importer{users: users}
+}
+
+// columns maps each of Columns to its position in the file.
+type columns struct {
+	username, email, fullName int
+	width                     int
+}
+
+func parseHeader(header []string) (columns, error) {
+	pos := map[string]int{}
+	for i, name := range header {
+		name = strings.TrimPrefix(name, "\ufeff") // spreadsheet exports often start with a BOM
+		name = strings.NewReplacer(" ", "_", "-", "_").Replace(strings.ToLower(strings.TrimSpace(name)))
+		if _, dup := pos[name]; dup && name != "" {
+			return columns{}, fmt.Errorf("%w: column %q appears twice in the header", errors.ErrInvalidInput, name)
+		}
+		pos[name] = i
+	}
+
+	var missing []string
+	for _, name := range Columns {
+		if _, ok := pos[name]; !ok {
+			missing = append(missing, name)
+		}
+	}
+	if len(missing) > 0 {
+		return columns{}, fmt.Errorf("%w: CSV header is missing column(s) %s", errors.ErrInvalidInput, strings.Join(missing, ", "))
+	}
+	return columns{username: pos["username"], email: pos["email"], fullName: pos["full_name"], width: len(header)}, nil
+}
+
+// Import reads a CSV file with a header row and imports it row by row. A bad row is reported and
+// the import carries on; only an unusable header (or a read error) fails the whole call.
+// CSV syntax errors end the import at that row, since the rest of the file cannot be trusted.
+// Cancelling ctx stops the import between rows; rows already imported stay.
+func (im *Importer) Import(ctx context.Context, r io.Reader, opts Options) (*model.ImportReport, error) {
+	if opts.Mode == "" {
+		opts.Mode = model.ImportSkipExisting
+	}
+
+	cr := csv.NewReader(r)
+	cr.FieldsPerRecord = -1 // short and long rows are reported per row below
+
+	header, err := cr.Read()
+	if err == io.EOF {
+		return nil, fmt.Errorf("%w: CSV file is empty", errors.ErrInvalidInput)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("%w: failed to read CSV header: %v", errors.ErrInvalidInput, err)
+	}
+	cols, err := parseHeader(header)
+	if err != nil {
+		return nil, err
+	}
+
+	report := &model.ImportReport{Mode: opts.Mode, DryRun: opts.DryRun, Rows: []model.ImportRow{}}
+	b := &batch{opts: opts, usernames: map[string]int{}, emails: map[string]int{}}
+	for {
+		if err := ctx.Err(); err != nil {
+			return nil, err
+		}
+		record, err := cr.Read()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			var pe *csv.ParseError
+			if !stdErrors.As(err, &pe) {
+				return nil, err
+			}
+			add(report, model.ImportRow{Line: pe.StartLine, Status: model.ImportFailed, Reason: "malformed CSV: " + pe.Err.Error()})
+			break
+		}
+		line, _ := cr.FieldPos(0)
+		add(report, im.importRow(b, line, record, cols))
+		if opts.Progress != nil {
+			opts.Progress(len(report.Rows))
+		}
+	}
+	return report, nil
+}
+
+func add(report *model.ImportReport, row model.ImportRow) {
+	switch row.Status {
+	case model.ImportCreated:
+		report.Created++
+	case model.ImportUpdated:
+		report.Updated++
+	case model.ImportSkipped:
+		report.Skipped++
+	case model.ImportFailed:
+		report.Failed++
+	}
+	report.Rows = append(report.Rows, row)
+}
+
+// batch is the state of one Import call. usernames and emails hold the line each was first seen on,
+// so a file that names the same user twice fails the repeat identically with and without DryRun.
+type batch struct {
+	opts      Options
+	usernames map[string]int
+	emails    map[string]int
+}
+
+func (im *Importer) importRow(b *batch, line int, record []string, cols columns) model.ImportRow {
+	row := model.ImportRow{Line: line}
+	fail := func(reason string) model.ImportRow {
+		row.Status = model.ImportFailed
+		row.Reason = reason
+		return row
+	}
+	if len(record) != cols.width {
+		return fail(fmt.Sprintf("expected %d fields, got %d", cols.width, len(record)))
+	}
+
+	req := model.CreateUserRequest{
+		Username: strings.TrimSpace(record[cols.username]),
+		Email:    strings.TrimSpace(record[cols.email]),
+		FullName: strings.TrimSpace(record[cols.fullName]),
+	}
+	row.Username, row.Email = req.Username, req.Email
+
+	// Same struct-tag rules the HTTP layer enforces via ShouldBindJSON, then the service's own
+	if err := binding.Validator.ValidateStruct(&req); err != nil {
+		return fail(describe(err))
+	}
+	if err := im.users.ValidateCreate(&req); err != nil {
+		return fail(err.Error())
+	}
+	row.Username, row.Email = req.Username, req.Email
+
+	if first, ok := b.emails[req.Email]; ok {
+		return fail(fmt.Sprintf("email already used on line %d", first))
+	}
+	if first, ok := b.usernames[req.Username]; ok {
+		return fail(fmt.Sprintf("username already used on line %d", first))
+	}
+	b.emails[req.Email] = line
+	b.usernames[req.Username] = line
+
+	existing, err := im.users.GetByEmail(req.Email, nil)
+	switch {
+	case err == nil:
+		row.ID = &existing.ID
+		if b.opts.Mode == model.ImportSkipExisting {
+			row.Status, row.Reason = model.ImportSkipped, "email already exists"
+			return row
+		}
+		return im.update(b, row, existing, &req)
+	case !stdErrors.Is(err, errors.ErrUserNotFound):
+		return fail(err.Error())
+	}
+	return im.create(b, row, &req)
+}
+
+func (im *Importer) create(b *batch, row model.ImportRow, req *model.CreateUserRequest) model.ImportRow {
+	// A taken username is an existing user in skip mode, but a real clash when upserting by email
+	taken := func(err error) model.ImportRow {
+		if b.opts.Mode == model.ImportSkipExisting {
+			row.Status = model.ImportSkipped
+		} else {
+			row.Status = model.ImportFailed
+		}
+		row.Reason = err.Error()
+		return row
+	}
+
+	if b.opts.DryRun {
+		if _, err := im.users.GetByUsername(req.Username, nil); err == nil {
+			return taken(errors.ErrUsernameExists)
+		} else if !stdErrors.Is(err, errors.ErrUserNotFound) {
+			row.Status, row.Reason = model.ImportFailed, err.Error()
+			return row
+		}
+		row.Status = model.ImportCreated
+		return row
+	}
+
+	user, err := im.users.Create(req)
+	if err != nil {
+		if stdErrors.Is(err, errors.ErrUsernameExists) || stdErrors.Is(err, errors.ErrUsernameReserved) || stdErrors.Is(err, errors.ErrEmailExists) {
+			return taken(err)
+		}
+		row.Status, row.Reason = model.ImportFailed, err.Error()
+		return row
+	}
+	row.Status, row.ID = model.ImportCreated, &user.ID
+	return row
+}
+
+func (im *Importer) update(b *batch, row model.ImportRow, existing *model.User, req *model.CreateUserRequest) model.ImportRow {
+	if existing.Username == req.Username && existing.FullName == req.FullName {
+		row.Status, row.Reason = model.ImportSkipped, "no changes"
+		return row
+	}
+	upd := model.UpdateUserRequest{Username: &req.Username, FullName: &req.FullName, Actor: b.opts.Actor}
+
+	if b.opts.DryRun {
+		if existing.Username != req.Username {
+			if _, err := im.users.GetByUsername(req.Username, nil); err == nil {
+				row.Status, row.Reason = model.ImportFailed, errors.ErrUsernameExists.Error()
+				return row
+			} else if !stdErrors.Is(err, errors.ErrUserNotFound) {
+				row.Status, row.Reason = model.ImportFailed, err.Error()
+				return row
+			}
+		}
+		row.Status = model.ImportUpdated
+		return row
+	}
+
+	if _, err := im.users.Update(existing.ID, &upd); err != nil {
+		row.Status, row.Reason = model.ImportFailed, err.Error()
+		return row
+	}
+	row.Status = model.ImportUpdated
+	return row
+}
+
+// describe turns binding errors into a reason naming the CSV columns.
+func describe(err error) string {
+	var ve validator.ValidationErrors
+	if !stdErrors.As(err, &ve) {
+		return err.Error()
+	}
+	column := map[string]string{"Username": "username", "Email": "email", "FullName": "full_name"}
+	reasons := make([]string, 0, len(ve))
+	for _, fe := range ve {
+		var rule string
+		switch fe.Tag() {
+		case "required":
+			rule = "is required"
+		case "email":
+			rule = "is not a valid email address"
+		case "min":
+			rule = "must be at least " + fe.Param() + " characters"
+		case "max":
+			rule = "must be at most " + fe.Param() + " characters"
+		case "alphanum":
+			rule = "must contain only letters and digits"
+		default:
+			rule = "is invalid"
+		}
+		reasons = append(reasons, column[fe.Field()]+" "+rule)
+	}
+	return strings.Join(reasons, "; ")
+}
+
+// ReportColumns is the header of WriteCSV's output.
+var ReportColumns = []string{"line", "status", "id", "username", "email", "reason"}
+
+// WriteCSV writes the report's rows as CSV, one per imported row.
+func WriteCSV(w io.Writer, report *model.ImportReport) error {
+	cw := csv.NewWriter(w)
+	if err := cw.Write(ReportColumns); err != nil {
+		return err
+	}
+	for _, row := range report.Rows {
+		id := ""
+		if row.ID != nil {
+			id = row.ID.String()
+		}
+		if err := cw.Write([]string{strconv.Itoa(row.Line), row.Status, id, row.Username, row.Email, row.Reason}); err != nil {
+			return err
+		}
+	}
+	cw.Flush()
+	return cw.Error()
+}
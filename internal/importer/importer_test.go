@@ -0,0 +1,137 @@
+package importer
+
+import (
+	"bytes"
+	"context"
+	"cruder/internal/errors"
+	"cruder/internal/model"
+	"cruder/internal/repository/memory"
+	"cruder/internal/service"
+	"encoding/csv"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+const joiners = "\ufeffUsername,Email,Full Name,Department\n" +
+	"jdoe,JDoe@Example.com,John Doe,Sales\n" +
+	"asmith,asmith@example.com,Alice Smith,HR\n" +
+	"x,not-an-email,Bob,IT\n" +
+	"bjones,bjones@example.com,B0b Jones,IT\n" +
+	"jdoe2,jdoe@example.com,Johnny Doe,Sales\n" +
+	"short,row\n"
+
+func newImporter(t *testing.T) (*Importer, service.UserService) {
+	t.Helper()
+	users := service.NewUserService(memory.NewUserRepository())
+	return New(users), users
+}
+
+func statuses(report *model.ImportReport) []string {
+	out := make([]string, len(report.Rows))
+	for i, row := range report.Rows {
+		out[i] = row.Status
+	}
+	return out
+}
+
+func TestImport_ReportsEveryRow(t *testing.T) {
+	im, users := newImporter(t)
+
+	report, err := im.Import(context.Background(), strings.NewReader(joiners), Options{})
+	require.NoError(t, err)
+
+	assert.Equal(t, []string{model.ImportCreated, model.ImportCreated, model.ImportFailed, model.ImportFailed, model.ImportFailed, model.ImportFailed}, statuses(report))
+	assert.Equal(t, 2, report.Created)
+	assert.Equal(t, 4, report.Failed)
+	assert.Equal(t, 2, report.Rows[0].Line)
+	assert.Equal(t, "jdoe@example.com", report.Rows[0].Email, "rows report the normalized values")
+	assert.Contains(t, report.Rows[2].Reason, "username must be at least 3 characters")
+	assert.Contains(t, report.Rows[2].Reason, "email is not a valid email address")
+	assert.Contains(t, report.Rows[3].Reason, "full name")
+	assert.Equal(t, "email already used on line 2", report.Rows[4].Reason)
+	assert.Equal(t, "expected 4 fields, got 2", report.Rows[5].Reason)
+
+	all, err := users.GetAll(nil)
+	require.NoError(t, err)
+	assert.Len(t, all, 2)
+}
+
+func TestImport_DryRunWritesNothing(t *testing.T) {
+	im, users := newImporter(t)
+
+	dry, err := im.Import(context.Background(), strings.NewReader(joiners), Options{DryRun: true})
+	require.NoError(t, err)
+	all, err := users.GetAll(nil)
+	require.NoError(t, err)
+	assert.Empty(t, all)
+
+	real, err := im.Import(context.Background(), strings.NewReader(joiners), Options{})
+	require.NoError(t, err)
+	assert.Equal(t, statuses(real), statuses(dry))
+	assert.Nil(t, dry.Rows[0].ID)
+	assert.NotNil(t, real.Rows[0].ID)
+}
+
+func TestImport_Modes(t *testing.T) {
+	im, users := newImporter(t)
+	_, err := users.Create(&model.CreateUserRequest{Username: "jdoe", Email: "jdoe@example.com", FullName: "John Doe"})
+	require.NoError(t, err)
+	_, err = users.Create(&model.CreateUserRequest{Username: "taken", Email: "taken@example.com", FullName: "Taken User"})
+	require.NoError(t, err)
+
+	file := "username,email,full_name\n" +
+		"johnny,jdoe@example.com,Johnny Doe\n" +
+		"taken,new@example.com,New User\n" +
+		"fresh,fresh@example.com,Fresh User\n"
+
+	skip, err := im.Import(context.Background(), strings.NewReader(file), Options{Mode: model.ImportSkipExisting, DryRun: true})
+	require.NoError(t, err)
+	assert.Equal(t, []string{model.ImportSkipped, model.ImportSkipped, model.ImportCreated}, statuses(skip))
+
+	upsert, err := im.Import(context.Background(), strings.NewReader(file), Options{Mode: model.ImportUpsertByEmail})
+	require.NoError(t, err)
+	assert.Equal(t, []string{model.ImportUpdated, model.ImportFailed, model.ImportCreated}, statuses(upsert))
+	assert.Equal(t, errors.ErrUsernameExists.Error(), upsert.Rows[1].Reason)
+
+	updated, err := users.GetByEmail("jdoe@example.com", nil)
+	require.NoError(t, err)
+	assert.Equal(t, "johnny", updated.Username)
+	assert.Equal(t, "Johnny Doe", updated.FullName)
+
+	again, err := im.Import(context.Background(), strings.NewReader(file), Options{Mode: model.ImportUpsertByEmail})
+	require.NoError(t, err)
+	assert.Equal(t, "no changes", again.Rows[0].Reason)
+}
+
+func TestImport_BadFiles(t *testing.T) {
+	im, _ := newImporter(t)
+
+	_, err := im.Import(context.Background(), strings.NewReader(""), Options{})
+	assert.ErrorIs(t, err, errors.ErrInvalidInput)
+
+	_, err = im.Import(context.Background(), strings.NewReader("username,email\njdoe,jdoe@example.com\n"), Options{})
+	assert.ErrorIs(t, err, errors.ErrInvalidInput)
+	assert.Contains(t, err.Error(), "full_name")
+
+	report, err := im.Import(context.Background(), strings.NewReader("username,email,full_name\njdoe,jdoe@example.com,\"John\" Doe\nasmith,asmith@example.com,Alice Smith\n"), Options{})
+	require.NoError(t, err)
+	require.Len(t, report.Rows, 1, "a syntax error stops the import")
+	assert.Contains(t, report.Rows[0].Reason, "malformed CSV")
+}
+
+func TestWriteCSV(t *testing.T) {
+	im, _ := newImporter(t)
+	report, err := im.Import(context.Background(), strings.NewReader(joiners), Options{})
+	require.NoError(t, err)
+
+	var buf bytes.Buffer
+	require.NoError(t, WriteCSV(&buf, report))
+	records, err := csv.NewReader(&buf).ReadAll()
+	require.NoError(t, err)
+	require.Len(t, records, len(report.Rows)+1)
+	assert.Equal(t, ReportColumns, records[0])
+	assert.Equal(t, []string{"2", model.ImportCreated, report.Rows[0].ID.String(), "jdoe", "jdoe@example.com", ""}, records[1])
+}
@@ -0,0 +1,78 @@
+package jobs
+
+import (
+	"bytes"
+	"context"
+	"cruder/internal/export"
+	"cruder/internal/importer"
+	"cruder/internal/model"
+	"cruder/internal/service"
+	"encoding/json"
+	"fmt"
+)
+
+// RegisterUserHandlers registers the handlers of the users.* job kinds.
+func RegisterUserHandlers(w *Worker, users service.UserService) {
+	w.Handle(model.JobUserExport, UserExport(users))
+	w.Handle(model.JobUserImport, UserImport(users))
+}
+
+// UserExport streams users straight from the database into the job's result, like GET /users
+// with an export Accept header but without a request deadline.
+func UserExport(users service.UserService) Handler {
+	return func(ctx context.Context, job *model.Job, out *Output) error {
+		var params model.UserExportParams
+		if err := json.Unmarshal(job.Params, &params); err != nil {
+			return fmt.Errorf("invalid job parameters: %w", err)
+		}
+		users := users.InOrganization(params.OrganizationID)
+		w, err := export.NewWriter(params.Format, out, params.Fields)
+		if err != nil {
+			return err
+		}
+		out.SetContentType(params.Format)
+
+		var done int64
+		write := func(u *model.User) error {
+			if err := ctx.Err(); err != nil {
+				return err
+			}
+			if err := w.Write(u); err != nil {
+				return err
+			}
+			done++
+			out.SetProgress(done)
+			return nil
+		}
+		if params.Query != "" {
+			err = users.StreamSearch(params.Query, params.Fields, write)
+		} else {
+			err = users.StreamAll(params.Fields, write)
+		}
+		if err != nil {
+			return err
+		}
+		return w.Close()
+	}
+}
+
+// UserImport imports the job's CSV input; the result is the import report as JSON.
+func UserImport(users service.UserService) Handler {
+	return func(ctx context.Context, job *model.Job, out *Output) error {
+		var params model.UserImportParams
+		if err := json.Unmarshal(job.Params, &params); err != nil {
+			return fmt.Errorf("invalid job parameters: %w", err)
+		}
+		report, err := importer.New(users.InOrganization(params.OrganizationID)).Import(ctx, bytes.NewReader(job.Input), importer.Options{
+			Mode:     params.Mode,
+			DryRun:   params.DryRun,
+			Progress: func(rows int) { out.SetProgress(int64(rows)) },
+			Actor:    params.Actor,
+		})
+		if err != nil {
+			return err
+		}
+		out.SetContentType("application/json")
+		return json.NewEncoder(out).Encode(report)
+	}
+}
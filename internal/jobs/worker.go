@@ -0,0 +1,313 @@
+// Package jobs runs background jobs queued through service.JobService.
+//
+// Every replica runs a Worker. Workers claim jobs from the shared table with FOR UPDATE SKIP LOCKED,
+// heartbeat while a job runs and write its result in chunks, so a job survives the pod that started
+// it: if a worker dies mid-job, another claims it once the heartbeat goes stale and starts it over.
+package jobs
+
+import (
+	"context"
+	"cruder/internal/errors"
+	"cruder/internal/model"
+	"cruder/internal/repository"
+	"fmt"
+	"log/slog"
+	"os"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/google/uuid"
+
+	stdErrors "errors"
+)
+
+// Handler does the work of one job kind. It writes the job's result, if any, to out and reports
+// progress through it. It must return soon after ctx is cancelled, which happens when the job is
+// cancelled, when the worker shuts down, or when the worker loses the job to another one.
+// A job may run more than once (after a crash), so handlers should be safe to repeat.
+type Handler func(ctx context.Context, job *model.Job, out *Output) error
+
+// Config tunes a Worker. Zero fields take their DefaultConfig value.
+type Config struct {
+	// Concurrency is how many jobs this worker runs at once
+	Concurrency int
+	// PollInterval is how long an idle worker waits before looking for work again
+	PollInterval time.Duration
+	// HeartbeatInterval is how often a running job's progress and liveness are recorded
+	HeartbeatInterval time.Duration
+	// StaleAfter is how old a running job's heartbeat must be before another worker takes it over.
+	// It should be several heartbeat intervals.
+	StaleAfter time.Duration
+	// MaxAttempts bounds how often a job is started before it is failed for good
+	MaxAttempts int
+}
+
+var DefaultConfig = Config{
+	Concurrency:       2,
+	PollInterval:      time.Second,
+	HeartbeatInterval: 5 * time.Second,
+	StaleAfter:        30 * time.Second,
+	MaxAttempts:       3,
+}
+
+// Worker claims and runs jobs until its context is cancelled.
+type Worker struct {
+	repo     repository.JobRepository
+	cfg      Config
+	logger   *slog.Logger
+	id       string
+	handlers map[string]Handler
+}
+
+// errCancelRequested is the cancellation cause when a user cancels a running job.
+var errCancelRequested = stdErrors.New("job cancelled")
+
+func NewWorker(repo repository.JobRepository, cfg Config, logger *slog.Logger) *Worker {
+	if cfg.Concurrency <= 0 {
+		cfg.Concurrency = DefaultConfig.Concurrency
+	}
+	if cfg.PollInterval <= 0 {
+		cfg.PollInterval = DefaultConfig.PollInterval
+	}
+	if cfg.HeartbeatInterval <= 0 {
+		cfg.HeartbeatInterval = DefaultConfig.HeartbeatInterval
+	}
+	if cfg.StaleAfter <= 0 {
+		cfg.StaleAfter = DefaultConfig.StaleAfter
+	}
+	if cfg.MaxAttempts <= 0 {
+		cfg.MaxAttempts = DefaultConfig.MaxAttempts
+	}
+
+	host, _ := os.Hostname()
+	return &Worker{
+		repo:     repo,
+		cfg:      cfg,
+		logger:   logger,
+		id:       fmt.Sprintf("%s-%d-%s", host, os.Getpid(), uuid.NewString()[:8]),
+		handlers: make(map[string]Handler),
+	}
+}
+
+// Handle registers the handler for a job kind. It must be called before Run.
+func (w *Worker) Handle(kind string, h Handler) {
+	w.handlers[kind] = h
+}
+
+// Run processes jobs until ctx is cancelled. Jobs still running then are put back in the queue
+// for another worker (or this one, after a restart) and Run returns once they have stopped.
+func (w *Worker) Run(ctx context.Context) {
+	w.logger.Info("Job worker started",
+		slog.String("worker", w.id),
+		slog.Int("concurrency", w.cfg.Concurrency))
+
+	var wg sync.WaitGroup
+	for i := 0; i < w.cfg.Concurrency; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			w.loop(ctx)
+		}()
+	}
+	wg.Wait()
+}
+
+func (w *Worker) loop(ctx context.Context) {
+	for ctx.Err() == nil {
+		job, err := w.repo.Claim(w.id, w.cfg.StaleAfter)
+		if err != nil {
+			w.logger.Error("Failed to claim job",
+				slog.String("worker", w.id),
+				slog.String("error", err.Error()))
+		}
+		if job != nil {
+			w.process(ctx, job)
+			continue
+		}
+		select {
+		case <-ctx.Done():
+		case <-time.After(w.cfg.PollInterval):
+		}
+	}
+}
+
+func (w *Worker) process(ctx context.Context, job *model.Job) {
+	logger := w.logger.With(
+		slog.String("worker", w.id),
+		slog.String("job_id", job.ID.String()),
+		slog.String("kind", job.Kind),
+		slog.Int("attempt", job.Attempts))
+	out := newOutput(w.repo, job.ID, w.id)
+
+	handler, ok := w.handlers[job.Kind]
+	switch {
+	case job.CancelRequested:
+		// Cancelled while its previous worker was dying
+		w.finish(logger, job, out, model.JobCancelled, "")
+		return
+	case !ok:
+		w.finish(logger, job, out, model.JobFailed, fmt.Sprintf("unknown job kind %q", job.Kind))
+		return
+	case job.Attempts > w.cfg.MaxAttempts:
+		w.finish(logger, job, out, model.JobFailed, fmt.Sprintf("gave up after %d attempts", w.cfg.MaxAttempts))
+		return
+	}
+
+	logger.Info("Job started")
+	jobCtx, cancel := context.WithCancelCause(ctx)
+	defer cancel(nil)
+
+	stopHeartbeat := make(chan struct{})
+	heartbeatDone := make(chan struct{})
+	go func() {
+		defer close(heartbeatDone)
+		ticker := time.NewTicker(w.cfg.HeartbeatInterval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-stopHeartbeat:
+				return
+			case <-ticker.C:
+			}
+			cancelRequested, err := w.repo.Heartbeat(job.ID, w.id, out.progress())
+			switch {
+			case stdErrors.Is(err, errors.ErrJobLost):
+				cancel(errors.ErrJobLost)
+				return
+			case err != nil:
+				logger.Warn("Job heartbeat failed", slog.String("error", err.Error()))
+			case cancelRequested:
+				cancel(errCancelRequested)
+			}
+		}
+	}()
+
+	err := w.run(jobCtx, handler, job, out)
+	if err == nil {
+		err = out.flush()
+	}
+	close(stopHeartbeat)
+	<-heartbeatDone
+
+	switch cause := context.Cause(jobCtx); {
+	case err == nil:
+		w.finish(logger, job, out, model.JobSucceeded, "")
+	case stdErrors.Is(cause, errors.ErrJobLost), stdErrors.Is(err, errors.ErrJobLost):
+		logger.Warn("Job taken over by another worker; abandoning it")
+	case ctx.Err() != nil:
+		if err := w.repo.Release(job.ID, w.id); err != nil {
+			logger.Error("Failed to release job on shutdown", slog.String("error", err.Error()))
+			return
+		}
+		logger.Info("Job released for another worker on shutdown")
+	case stdErrors.Is(cause, errCancelRequested):
+		w.finish(logger, job, out, model.JobCancelled, "")
+	default:
+		w.finish(logger, job, out, model.JobFailed, err.Error())
+	}
+}
+
+// run calls the handler, turning a panic into a failed job rather than a crashed server.
+func (w *Worker) run(ctx context.Context, h Handler, job *model.Job, out *Output) (err error) {
+	defer func() {
+		if r := recover(); r != nil {
+			err = fmt.Errorf("job panicked: %v", r)
+		}
+	}()
+	return h(ctx, job, out)
+}
+
+func (w *Worker) finish(logger *slog.Logger, job *model.Job, out *Output, status model.JobStatus, errMsg string) {
+	resultType := ""
+	if status == model.JobSucceeded {
+		resultType = out.contentType
+	}
+	if err := w.repo.Finish(job.ID, w.id, status, out.progress(), resultType, errMsg); err != nil {
+		logger.Error("Failed to record job outcome",
+			slog.String("status", string(status)),
+			slog.String("error", err.Error()))
+		return
+	}
+	if status == model.JobFailed {
+		logger.Warn("Job failed", slog.String("error", errMsg))
+		return
+	}
+	logger.Info("Job finished", slog.String("status", string(status)))
+}
+
+// resultChunkSize is how much result data is buffered before it is stored as one chunk.
+const resultChunkSize = 1 << 20
+
+// Output is where a Handler writes its result. Writes are stored in chunks as they fill up,
+// so a handler can produce a result of any size in constant memory.
+type Output struct {
+	repo        repository.JobRepository
+	job         uuid.UUID
+	worker      string
+	buf         []byte
+	seq         int
+	contentType string
+	done        atomic.Int64
+	total       atomic.Int64 // negative while unknown
+}
+
+func newOutput(repo repository.JobRepository, job uuid.UUID, worker string) *Output {
+	o := &Output{repo: repo, job: job, worker: worker}
+	o.total.Store(-1)
+	return o
+}
+
+// SetContentType sets the media type the result is downloaded as. A job without one has no result.
+func (o *Output) SetContentType(mediaType string) {
+	o.contentType = mediaType
+}
+
+// SetProgress records how many items are done; it is saved with the next heartbeat.
+func (o *Output) SetProgress(done int64) {
+	o.done.Store(done)
+}
+
+// SetTotal records how many items there are in all, once known.
+func (o *Output) SetTotal(total int64) {
+	o.total.Store(total)
+}
+
+func (o *Output) progress() model.JobProgress {
+	p := model.JobProgress{Done: o.done.Load()}
+	if total := o.total.Load(); total >= 0 {
+		p.Total = &total
+	}
+	return p
+}
+
+func (o *Output) Write(p []byte) (int, error) {
+	o.buf = append(o.buf, p...)
+	for len(o.buf) >= resultChunkSize {
+		if err := o.store(o.buf[:resultChunkSize]); err != nil {
+			return 0, err
+		}
+		o.buf = append(o.buf[:0], o.buf[resultChunkSize:]...)
+	}
+	return len(p), nil
+}
+
+// flush stores whatever is left in the buffer.
+func (o *Output) flush() error {
+	if len(o.buf) == 0 {
+		return nil
+	}
+	if err := o.store(o.buf); err != nil {
+		return err
+	}
+	o.buf = o.buf[:0]
+	return nil
+}
+
+func (o *Output) store(chunk []byte) error {
+	if err := o.repo.AppendResult(o.job, o.worker, o.seq, chunk); err != nil {
+		return err
+	}
+	o.seq++
+	return nil
+}
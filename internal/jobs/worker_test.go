@@ -0,0 +1,223 @@
+package jobs
+
+import (
+	"bytes"
+	"context"
+	"cruder/internal/errors"
+	"cruder/internal/export"
+	"cruder/internal/model"
+	"cruder/internal/repository/memory"
+	"cruder/internal/service"
+	"encoding/csv"
+	"encoding/json"
+	"io"
+	"log/slog"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+var testConfig = Config{
+	Concurrency:       2,
+	PollInterval:      5 * time.Millisecond,
+	HeartbeatInterval: 5 * time.Millisecond,
+	StaleAfter:        50 * time.Millisecond,
+	MaxAttempts:       3,
+}
+
+type fixture struct {
+	repo  *memory.JobRepository
+	jobs  service.JobService
+	users service.UserService
+}
+
+func newFixture() *fixture {
+	repo := memory.NewJobRepository()
+	return &fixture{
+		repo:  repo,
+		jobs:  service.NewJobService(repo),
+		users: service.NewUserService(memory.NewUserRepository()),
+	}
+}
+
+// start runs a worker until the test ends (or the returned func is called).
+func (f *fixture) start(t *testing.T, register func(w *Worker)) context.CancelFunc {
+	t.Helper()
+	w := NewWorker(f.repo, testConfig, slog.New(slog.NewTextHandler(io.Discard, nil)))
+	RegisterUserHandlers(w, f.users)
+	if register != nil {
+		register(w)
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		w.Run(ctx)
+	}()
+	stop := func() {
+		cancel()
+		<-done
+	}
+	t.Cleanup(stop)
+	return stop
+}
+
+func (f *fixture) waitFor(t *testing.T, id uuid.UUID, status model.JobStatus) *model.Job {
+	t.Helper()
+	var job *model.Job
+	require.Eventually(t, func() bool {
+		var err error
+		job, err = f.jobs.Get(id)
+		require.NoError(t, err)
+		return job.Status == status
+	}, 2*time.Second, 5*time.Millisecond, "job never became %s", status)
+	return job
+}
+
+func (f *fixture) result(t *testing.T, id uuid.UUID) []byte {
+	t.Helper()
+	_, err := f.jobs.Result(id)
+	require.NoError(t, err)
+	var buf bytes.Buffer
+	require.NoError(t, f.jobs.StreamResult(id, func(chunk []byte) error {
+		_, err := buf.Write(chunk)
+		return err
+	}))
+	return buf.Bytes()
+}
+
+func TestWorker_UserExport(t *testing.T) {
+	f := newFixture()
+	for _, name := range []string{"alice", "bob", "carol"} {
+		_, err := f.users.Create(&model.CreateUserRequest{Username: name, Email: name + "@example.com", FullName: "Test User"})
+		require.NoError(t, err)
+	}
+	f.start(t, nil)
+
+	queued, err := f.jobs.EnqueueUserExport(model.UserExportParams{Format: export.MIMECSV, Fields: model.UserFields{"username"}})
+	require.NoError(t, err)
+	_, err = f.jobs.Result(queued.ID)
+	assert.ErrorIs(t, err, errors.ErrJobNotReady)
+
+	job := f.waitFor(t, queued.ID, model.JobSucceeded)
+	assert.Equal(t, export.MIMECSV, job.ResultType)
+	assert.Equal(t, int64(3), job.Progress.Done)
+	assert.Equal(t, 1, job.Attempts)
+
+	records, err := csv.NewReader(bytes.NewReader(f.result(t, job.ID))).ReadAll()
+	require.NoError(t, err)
+	assert.Equal(t, []string{"username"}, records[0])
+	assert.ElementsMatch(t, [][]string{{"alice"}, {"bob"}, {"carol"}}, records[1:])
+}
+
+func TestWorker_UserImport(t *testing.T) {
+	f := newFixture()
+	f.start(t, nil)
+
+	queued, err := f.jobs.EnqueueUserImport(model.UserImportParams{}, []byte("username,email,full_name\njdoe,jdoe@example.com,John Doe\n"))
+	require.NoError(t, err)
+
+	f.waitFor(t, queued.ID, model.JobSucceeded)
+	var report model.ImportReport
+	require.NoError(t, json.Unmarshal(f.result(t, queued.ID), &report))
+	assert.Equal(t, model.ImportSkipExisting, report.Mode)
+	assert.Equal(t, 1, report.Created)
+
+	_, err = f.users.GetByUsername("jdoe", nil)
+	assert.NoError(t, err)
+}
+
+// blocking registers a job kind whose handler runs until its context ends.
+func blocking(started chan<- struct{}) func(w *Worker) {
+	return func(w *Worker) {
+		w.Handle("test.block", func(ctx context.Context, _ *model.Job, _ *Output) error {
+			started <- struct{}{}
+			<-ctx.Done()
+			return ctx.Err()
+		})
+	}
+}
+
+func TestWorker_Cancel(t *testing.T) {
+	f := newFixture()
+	started := make(chan struct{}, 1)
+	f.start(t, blocking(started))
+
+	job, err := f.repo.Create("test.block", nil, nil)
+	require.NoError(t, err)
+	<-started
+
+	running, err := f.jobs.Cancel(job.ID)
+	require.NoError(t, err)
+	assert.Equal(t, model.JobRunning, running.Status, "a running job stops at its next heartbeat")
+	assert.True(t, running.CancelRequested)
+
+	f.waitFor(t, job.ID, model.JobCancelled)
+	_, err = f.jobs.Cancel(job.ID)
+	assert.ErrorIs(t, err, errors.ErrJobFinished)
+}
+
+func TestWorker_CancelQueued(t *testing.T) {
+	f := newFixture()
+	job, err := f.repo.Create("test.block", nil, nil)
+	require.NoError(t, err)
+
+	cancelled, err := f.jobs.Cancel(job.ID)
+	require.NoError(t, err)
+	assert.Equal(t, model.JobCancelled, cancelled.Status)
+	assert.NotNil(t, cancelled.FinishedAt)
+}
+
+func TestWorker_ReleasesJobsOnShutdown(t *testing.T) {
+	f := newFixture()
+	started := make(chan struct{}, 1)
+	stop := f.start(t, blocking(started))
+
+	job, err := f.repo.Create("test.block", nil, nil)
+	require.NoError(t, err)
+	<-started
+	stop()
+
+	released, err := f.jobs.Get(job.ID)
+	require.NoError(t, err)
+	assert.Equal(t, model.JobQueued, released.Status)
+	assert.Zero(t, released.Attempts)
+}
+
+func TestWorker_TakesOverStaleJobs(t *testing.T) {
+	f := newFixture()
+	job, err := f.repo.Create("test.done", nil, nil)
+	require.NoError(t, err)
+
+	// A worker claims the job and dies without heartbeating
+	claimed, err := f.repo.Claim("dead-worker", testConfig.StaleAfter)
+	require.NoError(t, err)
+	require.Equal(t, job.ID, claimed.ID)
+
+	f.start(t, func(w *Worker) {
+		w.Handle("test.done", func(context.Context, *model.Job, *Output) error { return nil })
+	})
+
+	done := f.waitFor(t, job.ID, model.JobSucceeded)
+	assert.Equal(t, 2, done.Attempts)
+	_, err = f.repo.Heartbeat(job.ID, "dead-worker", model.JobProgress{})
+	assert.ErrorIs(t, err, errors.ErrJobLost)
+}
+
+func TestWorker_Failures(t *testing.T) {
+	f := newFixture()
+	f.start(t, func(w *Worker) {
+		w.Handle("test.panic", func(context.Context, *model.Job, *Output) error { panic("boom") })
+	})
+
+	panicked, err := f.repo.Create("test.panic", nil, nil)
+	require.NoError(t, err)
+	unknown, err := f.repo.Create("test.unknown", nil, nil)
+	require.NoError(t, err)
+
+	assert.Contains(t, f.waitFor(t, panicked.ID, model.JobFailed).Error, "boom")
+	assert.Contains(t, f.waitFor(t, unknown.ID, model.JobFailed).Error, "unknown job kind")
+}
@@ -0,0 +1,138 @@
+package mail
+
+import (
+	"bufio"
+	"context"
+	"io"
+	"mime"
+	"net"
+	"net/mail"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+var welcome = Message{To: "jdoe@example.com", Subject: "Bienvenue à bord", Text: "Hello,\nplease confirm.\n"}
+
+// readMessage parses an RFC 5322 message and returns it with its raw body.
+func readMessage(t *testing.T, r io.Reader) (*mail.Message, string) {
+	t.Helper()
+	m, err := mail.ReadMessage(r)
+	require.NoError(t, err)
+	body, err := io.ReadAll(m.Body)
+	require.NoError(t, err)
+	return m, string(body)
+}
+
+func TestFileMailer(t *testing.T) {
+	dir := filepath.Join(t.TempDir(), "outbox")
+	m, err := New(Config{Transport: "file", Dir: dir, From: "Cruder <no-reply@cruder.example>"})
+	require.NoError(t, err)
+
+	require.NoError(t, m.Send(context.Background(), welcome))
+	files, err := filepath.Glob(filepath.Join(dir, "*.eml"))
+	require.NoError(t, err)
+	require.Len(t, files, 1)
+
+	f, err := os.Open(files[0])
+	require.NoError(t, err)
+	defer func() { _ = f.Close() }()
+	msg, _ := readMessage(t, f)
+	assert.Equal(t, "jdoe@example.com", msg.Header.Get("To"))
+	subject, err := new(mime.WordDecoder).DecodeHeader(msg.Header.Get("Subject"))
+	require.NoError(t, err)
+	assert.Equal(t, "Bienvenue à bord", subject)
+	assert.Contains(t, msg.Header.Get("Message-ID"), "@cruder.example>")
+}
+
+func TestMemoryMailer(t *testing.T) {
+	m := &MemoryMailer{}
+	require.NoError(t, m.Send(context.Background(), welcome))
+	require.NoError(t, m.Send(context.Background(), Message{To: "jdoe@example.com", Subject: "Second"}))
+	assert.Error(t, m.Send(context.Background(), Message{To: "not an address"}))
+
+	assert.Len(t, m.Messages(), 2)
+	last, ok := m.Last("jdoe@example.com")
+	require.True(t, ok)
+	assert.Equal(t, "Second", last.Subject)
+	_, ok = m.Last("other@example.com")
+	assert.False(t, ok)
+}
+
+func TestCompose_RejectsHeaderInjection(t *testing.T) {
+	_, err := compose("no-reply@cruder.example", Message{To: "jdoe@example.com", Subject: "Hi\r\nBcc: everyone@example.com"}, time.Now())
+	assert.Error(t, err)
+	_, err = compose("no-reply@cruder.example", Message{To: "jdoe@example.com\r\nBcc: x@example.com", Subject: "Hi"}, time.Now())
+	assert.Error(t, err)
+}
+
+// fakeSMTP accepts one message and returns what it received on the channel.
+func fakeSMTP(t *testing.T) (string, <-chan string) {
+	t.Helper()
+	lis, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+	t.Cleanup(func() { _ = lis.Close() })
+
+	received := make(chan string, 1)
+	go func() {
+		conn, err := lis.Accept()
+		if err != nil {
+			return
+		}
+		defer func() { _ = conn.Close() }()
+		r := bufio.NewReader(conn)
+		reply := func(line string) { _, _ = io.WriteString(conn, line+"\r\n") }
+
+		reply("220 fake ESMTP")
+		var envelope, data strings.Builder
+		for {
+			line, err := r.ReadString('\n')
+			if err != nil {
+				return
+			}
+			cmd := strings.ToUpper(strings.TrimSpace(line))
+			switch {
+			case strings.HasPrefix(cmd, "EHLO"), strings.HasPrefix(cmd, "HELO"):
+				reply("250 fake")
+			case strings.HasPrefix(cmd, "MAIL FROM"), strings.HasPrefix(cmd, "RCPT TO"):
+				envelope.WriteString(strings.TrimSpace(line) + "\n")
+				reply("250 OK")
+			case cmd == "DATA":
+				reply("354 go ahead")
+				for {
+					l, err := r.ReadString('\n')
+					if err != nil || l == ".\r\n" {
+						break
+					}
+					data.WriteString(l)
+				}
+				reply("250 queued")
+			case cmd == "QUIT":
+				reply("221 bye")
+				received <- envelope.String() + "\n" + data.String()
+				return
+			default:
+				reply("502 not implemented")
+			}
+		}
+	}()
+	return lis.Addr().String(), received
+}
+
+func TestSMTPMailer(t *testing.T) {
+	addr, received := fakeSMTP(t)
+	m := &SMTPMailer{Addr: addr, From: "Cruder <no-reply@cruder.example>"}
+	require.NoError(t, m.Send(context.Background(), welcome))
+
+	got := <-received
+	envelope, data, _ := strings.Cut(got, "\n\n")
+	assert.Contains(t, envelope, "MAIL FROM:<no-reply@cruder.example>")
+	assert.Contains(t, envelope, "RCPT TO:<jdoe@example.com>")
+	_, body := readMessage(t, strings.NewReader(data))
+	assert.Contains(t, body, "please confirm.")
+}
@@ -0,0 +1,119 @@
+// Package metrics keeps the counters served at /metrics in the Prometheus text exposition format.
+// It implements only what this service exports, so it does not pull in the Prometheus client library.
+package metrics
+
+import (
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+	"sync"
+)
+
+// Counters exported by the service
+var (
+	// AuthFailures counts rejected credentials by reason: invalid_api_key, invalid_token,
+	// invalid_password at the OpenID Connect sign-in page, or throttled for attempts refused
+	// while the client had to wait
+	AuthFailures = NewCounterVec("cruder_auth_failures_total", "Authentication attempts rejected, by reason.", "reason")
+	// AuthBans counts clients banned for presenting too many wrong API keys or passwords
+	AuthBans = NewCounterVec("cruder_auth_bans_total", "Clients banned for presenting too many wrong credentials.", "")
+)
+
+// Auth failure reasons
+const (
+	ReasonInvalidAPIKey   = "invalid_api_key"
+	ReasonInvalidToken    = "invalid_token"
+	ReasonInvalidPassword = "invalid_password"
+	ReasonThrottled       = "throttled"
+)
+
+var (
+	registryMu sync.Mutex
+	registry   []*CounterVec
+)
+
+// CounterVec is a counter partitioned by the value of one label, or a plain counter when the
+// label name is empty.
+type CounterVec struct {
+	name, help, label string
+
+	mu     sync.Mutex
+	values map[string]float64
+}
+
+// NewCounterVec returns a counter served by WriteText. name must be unique.
+func NewCounterVec(name, help, label string) *CounterVec {
+	c := &CounterVec{name: name, help: help, label: label, values: map[string]float64{}}
+	registryMu.Lock()
+	defer registryMu.Unlock()
+	registry = append(registry, c)
+	return c
+}
+
+// Inc adds one to the counter for labelValue, which is ignored for a plain counter.
+func (c *CounterVec) Inc(labelValue string) {
+	if c.label == "" {
+		labelValue = ""
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.values[labelValue]++
+}
+
+// Value returns the count for labelValue.
+func (c *CounterVec) Value(labelValue string) float64 {
+	if c.label == "" {
+		labelValue = ""
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.values[labelValue]
+}
+
+func (c *CounterVec) write(w io.Writer) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if _, err := fmt.Fprintf(w, "# HELP %s %s\n# TYPE %s counter\n", c.name, c.help, c.name); err != nil {
+		return err
+	}
+	if c.label == "" {
+		_, err := fmt.Fprintf(w, "%s %g\n", c.name, c.values[""])
+		return err
+	}
+	labels := make([]string, 0, len(c.values))
+	for v := range c.values {
+		labels = append(labels, v)
+	}
+	sort.Strings(labels)
+	for _, v := range labels {
+		if _, err := fmt.Fprintf(w, "%s{%s=\"%s\"} %g\n", c.name, c.label, escapeLabel(v), c.values[v]); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+var labelEscaper = strings.NewReplacer(`\`, `\\`, `"`, `\"`, "\n", `\n`)
+
+func escapeLabel(v string) string {
+	return labelEscaper.Replace(v)
+}
+
+// ContentType is the media type of what WriteText writes.
+const ContentType = "text/plain; version=0.0.4; charset=utf-8"
+
+// WriteText writes every counter to w in the Prometheus text exposition format.
+func WriteText(w io.Writer) error {
+	registryMu.Lock()
+	counters := append([]*CounterVec(nil), registry...)
+	registryMu.Unlock()
+
+	for _, c := range counters {
+		if err := c.write(w); err != nil {
+			return err
+		}
+	}
+	return nil
+}
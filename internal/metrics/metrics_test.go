@@ -0,0 +1,32 @@
+package metrics
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestWriteText(t *testing.T) {
+	requests := NewCounterVec("test_requests_total", "Requests served.", "code")
+	restarts := NewCounterVec("test_restarts_total", "Restarts.", "")
+	requests.Inc("500")
+	requests.Inc("200")
+	requests.Inc("200")
+	requests.Inc(`odd"label`)
+	restarts.Inc("ignored")
+
+	assert.Equal(t, 2.0, requests.Value("200"))
+	assert.Equal(t, 1.0, restarts.Value(""))
+
+	var out strings.Builder
+	require.NoError(t, WriteText(&out))
+	assert.Contains(t, out.String(), "# HELP test_requests_total Requests served.\n"+
+		"# TYPE test_requests_total counter\n"+
+		"test_requests_total{code=\"200\"} 2\n"+
+		"test_requests_total{code=\"500\"} 1\n"+
+		"test_requests_total{code=\"odd\\\"label\"} 1\n")
+	assert.Contains(t, out.String(), "# TYPE test_restarts_total counter\ntest_restarts_total 1\n")
+	assert.Contains(t, out.String(), "# TYPE cruder_auth_failures_total counter\n")
+}
@@ -1,44 +1,171 @@
 package middleware
 
 import (
+	"cruder/internal/auth"
+	"cruder/internal/errors"
+	"cruder/internal/model"
+	"fmt"
+	"math"
 	"net/http"
-	"os"
+	"strconv"
+	"strings"
+	"time"
 
 	"github.com/gin-gonic/gin"
+
+	stdErrors "errors"
 )
 
-// APIKeyAuth is a middleware that validates X-API-Key header
-// Returns 401 if header is missing, 403 if key is wrong
-func APIKeyAuth() gin.HandlerFunc {
-	return func(c *gin.Context) {
-		expectedKey := os.Getenv("API_KEY")
+// publicPaths are served without credentials: verifiers fetch the signing keys before they hold any
+var publicPaths = map[string]bool{
+	"/.well-known/jwks.json": true,
+	// Prometheus scrapes without credentials, as the deployment annotations set it up
+	"/metrics": true,
+	// Kubernetes probes send no credentials
+	"/health": true,
+	"/ready":  true,
+	// The docs page is opened in a browser and loads the document it renders
+	"/openapi.json": true,
+	"/docs":         true,
+	// The OpenID Connect endpoints authenticate users and client applications themselves
+	"/.well-known/openid-configuration": true,
+	"/oauth/authorize":                  true,
+	"/oauth/token":                      true,
+	"/oauth/userinfo":                   true,
+}
 
-		// If API_KEY is not set, skip authentication (dev mode)
-		if expectedKey == "" {
+// Authenticate is a middleware that identifies the caller by an `Authorization: Bearer` access token
+// or an X-API-Key header and stores it in the request context (see auth.PrincipalFrom).
+// Returns 401 if both are missing or the token is invalid, expired or revoked, 403 if the key is wrong,
+// and 429 with Retry-After while the client IP must wait after too many wrong keys (see auth.APIKeyFrom).
+// Without any API key configured every request passes as auth.Anonymous (dev mode).
+// The organization the caller acts in goes in the context too (see admit).
+func Authenticate(authenticator *auth.Authenticator) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if publicPaths[c.Request.URL.Path] {
 			c.Next()
 			return
 		}
+		if !authenticator.Enabled() {
+			admit(c, authenticator, auth.Anonymous)
+			return
+		}
+
+		if bearer, ok := strings.CutPrefix(c.GetHeader("Authorization"), "Bearer "); ok {
+			principal, err := authenticator.Bearer(strings.TrimSpace(bearer))
+			if err != nil {
+				if auth.IsTokenRejected(err) {
+					c.Header("WWW-Authenticate", `Bearer error="invalid_token"`)
+					c.JSON(http.StatusUnauthorized, gin.H{
+						"error":   "Unauthorized",
+						"message": err.Error(),
+					})
+					c.Abort()
+					return
+				}
+				c.JSON(http.StatusInternalServerError, gin.H{
+					"error":   "Internal server error",
+					"message": "failed to verify access token",
+				})
+				c.Abort()
+				return
+			}
+			admit(c, authenticator, principal)
+			return
+		}
 
 		providedKey := c.GetHeader("X-API-Key")
 
 		if providedKey == "" {
 			c.JSON(http.StatusUnauthorized, gin.H{
 				"error":   "Unauthorized",
-				"message": "X-API-Key header is required",
+				"message": "X-API-Key header or Bearer token is required",
 			})
 			c.Abort()
 			return
 		}
 
-		if providedKey != expectedKey {
+		principal, err := authenticator.APIKeyFrom(c.ClientIP(), providedKey)
+		var throttled *auth.ThrottledError
+		switch {
+		case stdErrors.As(err, &throttled):
+			wait := math.Ceil(time.Until(throttled.RetryAt).Seconds())
+			c.Header("Retry-After", strconv.Itoa(max(int(wait), 1)))
+			c.JSON(http.StatusTooManyRequests, gin.H{
+				"error":   "Too many requests",
+				"code":    errors.CodeAuthThrottled,
+				"message": "Too many failed authentication attempts, try again later",
+			})
+			c.Abort()
+			return
+		case stdErrors.Is(err, auth.ErrUnknownAPIKey):
 			c.JSON(http.StatusForbidden, gin.H{
 				"error":   "Forbidden",
 				"message": "Invalid API key",
 			})
 			c.Abort()
 			return
+		case err != nil:
+			c.JSON(http.StatusInternalServerError, gin.H{
+				"error":   "Internal server error",
+				"message": "failed to check API key",
+			})
+			c.Abort()
+			return
 		}
 
+		admit(c, authenticator, principal)
+	}
+}
+
+// admit stores principal and the organization it acts in (see auth.OrganizationFrom) in the request
+// context and passes the request on. Returns 403 if the caller names an organization in the
+// X-Organization header that it may not act in and 400 if there is no such organization.
+func admit(c *gin.Context, authenticator *auth.Authenticator, principal *model.Principal) {
+	requested := c.GetHeader(auth.OrganizationHeader)
+	org, err := authenticator.Organization(principal, requested)
+	switch {
+	case stdErrors.Is(err, auth.ErrOrganizationNotAllowed):
+		c.JSON(http.StatusForbidden, gin.H{
+			"error":   "Forbidden",
+			"message": err.Error(),
+		})
+		c.Abort()
+		return
+	case stdErrors.Is(err, errors.ErrOrganizationNotFound) && requested != "":
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error":   "Invalid input",
+			"code":    errors.CodeInvalidInput,
+			"message": fmt.Sprintf("organization '%s' not found", requested),
+		})
+		c.Abort()
+		return
+	case err != nil:
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"error":   "Internal server error",
+			"message": "failed to resolve organization",
+		})
+		c.Abort()
+		return
+	}
+
+	ctx := auth.WithOrganization(auth.WithPrincipal(c.Request.Context(), principal), org)
+	c.Request = c.Request.WithContext(ctx)
+	c.Next()
+}
+
+// RequireScope is a middleware that returns 403 unless the caller identified by Authenticate
+// holds scope. Requests that did not go through Authenticate are let through.
+func RequireScope(scope string) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if principal, ok := auth.PrincipalFrom(c.Request.Context()); ok && !principal.HasScope(scope) {
+			c.JSON(http.StatusForbidden, gin.H{
+				"error":   "Forbidden",
+				"message": "the " + scope + " scope is required",
+			})
+			c.Abort()
+			return
+		}
 		c.Next()
 	}
 }
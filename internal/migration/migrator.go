@@ -0,0 +1,67 @@
+package migration
+
+import (
+	"context"
+	"cruder/migrations"
+	"database/sql"
+	"fmt"
+
+	"github.com/pressly/goose/v3"
+)
+
+// LockKey is the Postgres advisory lock key taken while migrating on startup,
+// so that only one replica applies migrations at a time.
+const LockKey int64 = 0x637275646572 // "cruder" in hex
+
+// Migrator applies the SQL migrations embedded in the binary.
+// It wraps goose's Provider so the rest of the app does not depend on goose directly.
+type Migrator struct {
+	provider *goose.Provider
+}
+
+func NewMigrator(db *sql.DB) (*Migrator, error) {
+	provider, err := goose.NewProvider(goose.DialectPostgres, db, migrations.FS)
+	if err != nil {
+		return nil, fmt.Errorf("failed to load embedded migrations: %w", err)
+	}
+	return &Migrator{provider: provider}, nil
+}
+
+// Up applies all pending migrations.
+func (m *Migrator) Up(ctx context.Context) ([]*goose.MigrationResult, error) {
+	return m.provider.Up(ctx)
+}
+
+// Down rolls back the most recently applied migration.
+func (m *Migrator) Down(ctx context.Context) (*goose.MigrationResult, error) {
+	return m.provider.Down(ctx)
+}
+
+// Redo rolls back the most recently applied migration and applies it again.
+func (m *Migrator) Redo(ctx context.Context) ([]*goose.MigrationResult, error) {
+	down, err := m.provider.Down(ctx)
+	if err != nil {
+		return nil, err
+	}
+	up, err := m.provider.UpByOne(ctx)
+	if err != nil {
+		return []*goose.MigrationResult{down}, err
+	}
+	return []*goose.MigrationResult{down, up}, nil
+}
+
+// Reset rolls back every applied migration, newest first, leaving an empty schema.
+func (m *Migrator) Reset(ctx context.Context) ([]*goose.MigrationResult, error) {
+	return m.provider.DownTo(ctx, 0)
+}
+
+// Status reports every known migration and whether it has been applied.
+func (m *Migrator) Status(ctx context.Context) ([]*goose.MigrationStatus, error) {
+	return m.provider.Status(ctx)
+}
+
+// Versions returns the schema version currently applied in the database and
+// the latest version embedded in this binary.
+func (m *Migrator) Versions(ctx context.Context) (current, expected int64, err error) {
+	return m.provider.GetVersions(ctx)
+}
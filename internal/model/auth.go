@@ -0,0 +1,84 @@
+package model
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// LoginRequest is the body of POST /auth/login. Login is a username or an email address.
+type LoginRequest struct {
+	Login    string `json:"login" binding:"required,max=100"`
+	Password string `json:"password" binding:"required,max=1024"`
+}
+
+// ChangePasswordRequest is the body of PUT /users/id/:id/password. CurrentPassword is required
+// once the user has a password; the first one is set without it.
+type ChangePasswordRequest struct {
+	CurrentPassword string `json:"current_password,omitempty" binding:"max=1024"`
+	NewPassword     string `json:"new_password" binding:"required,max=1024"`
+}
+
+// Credentials are a user with their stored password hash, which is empty when none is set.
+// They never leave the service layer.
+type Credentials struct {
+	User         User
+	PasswordHash string
+}
+
+// VerifyEmailRequest is the body of POST /users/verify-email, with the token from the verification email.
+type VerifyEmailRequest struct {
+	Token string `json:"token" binding:"required,max=100"`
+}
+
+// EmailVerification is an outstanding verification token, known only by its hash, for the
+// address it was sent to. It never leaves the service layer.
+type EmailVerification struct {
+	TokenHash      []byte
+	UserID         uuid.UUID
+	OrganizationID uuid.UUID
+	Email          string
+	ExpiresAt      time.Time
+	CreatedAt      time.Time
+}
+
+// EmailChangeTokenRequest is the body of POST /users/confirm-email and POST /users/revert-email-change,
+// with the token from the confirmation or notification email.
+type EmailChangeTokenRequest struct {
+	Token string `json:"token" binding:"required,max=100"`
+}
+
+// PendingEmailChange is a requested email address awaiting confirmation; until then the user
+// keeps their current one.
+type PendingEmailChange struct {
+	NewEmail    string    `json:"new_email"`
+	RequestedAt time.Time `json:"requested_at"`
+	ExpiresAt   time.Time `json:"expires_at"`
+}
+
+// EmailChange is a requested change of address with its confirm and revert tokens, known only
+// by their hashes. It never leaves the service layer.
+type EmailChange struct {
+	ID             uuid.UUID
+	UserID         uuid.UUID
+	OrganizationID uuid.UUID
+	OldEmail       string
+	// OldEmailVerifiedAt is restored when the change is reverted
+	OldEmailVerifiedAt *time.Time
+	NewEmail           string
+	ConfirmTokenHash   []byte
+	RevertTokenHash    []byte
+	ConfirmExpiresAt   time.Time
+	RevertExpiresAt    time.Time
+	// ConfirmedAt is nil while the change is pending
+	ConfirmedAt *time.Time
+	CreatedAt   time.Time
+}
+
+// Pending returns the change as shown on the user, or nil once it is confirmed.
+func (c *EmailChange) Pending() *PendingEmailChange {
+	if c == nil || c.ConfirmedAt != nil {
+		return nil
+	}
+	return &PendingEmailChange{NewEmail: c.NewEmail, RequestedAt: c.CreatedAt, ExpiresAt: c.ConfirmExpiresAt}
+}
@@ -0,0 +1,76 @@
+package model
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// Group is a named set of users within an organization. Groups nest: the members of a member
+// group are effective members of the groups it belongs to.
+type Group struct {
+	ID             uuid.UUID `json:"id"`
+	OrganizationID uuid.UUID `json:"organization_id"`
+	Name           string    `json:"name"`
+	Description    string    `json:"description"`
+	CreatedAt      time.Time `json:"created_at"`
+	UpdatedAt      time.Time `json:"updated_at"`
+}
+
+// CreateGroupRequest is the body of POST /api/v1/groups.
+type CreateGroupRequest struct {
+	Name        string `json:"name" binding:"required,min=2,max=100"`
+	Description string `json:"description" binding:"max=500"`
+}
+
+// UpdateGroupRequest is the body of PATCH /api/v1/groups/:id; absent fields are left unchanged.
+type UpdateGroupRequest struct {
+	Name        *string `json:"name,omitempty" binding:"omitempty,min=2,max=100"`
+	Description *string `json:"description,omitempty" binding:"omitempty,max=500"`
+}
+
+// The kinds of group member.
+const (
+	MemberTypeUser  = "user"
+	MemberTypeGroup = "group"
+)
+
+// GroupMember is a direct member of a group.
+type GroupMember struct {
+	// Type is MemberTypeUser or MemberTypeGroup
+	Type string    `json:"type"`
+	ID   uuid.UUID `json:"id"`
+	// Name is the user's username or the group's name
+	Name    string    `json:"name"`
+	AddedBy string    `json:"added_by"`
+	AddedAt time.Time `json:"added_at"`
+}
+
+// AddGroupMemberRequest is the body of POST /api/v1/groups/:id/members. Exactly one of UserID
+// and GroupID is set.
+type AddGroupMemberRequest struct {
+	UserID  *uuid.UUID `json:"user_id,omitempty"`
+	GroupID *uuid.UUID `json:"group_id,omitempty"`
+	// Actor is who adds the member, recorded in the membership history
+	Actor string `json:"-"`
+}
+
+// The actions of a GroupMembershipChange.
+const (
+	MembershipAdded   = "added"
+	MembershipRemoved = "removed"
+)
+
+// GroupMembershipChange is an entry of a group's membership history: a member was added or removed.
+type GroupMembershipChange struct {
+	GroupID uuid.UUID `json:"group_id"`
+	// Action is MembershipAdded or MembershipRemoved
+	Action     string    `json:"action"`
+	MemberType string    `json:"member_type"`
+	MemberID   uuid.UUID `json:"member_id"`
+	// MemberName is the member's username or group name at the time of the change
+	MemberName string `json:"member_name"`
+	// Actor is the subject of the API key or access token that made the change, "cli" or "system"
+	Actor     string    `json:"actor"`
+	ChangedAt time.Time `json:"changed_at"`
+}
@@ -0,0 +1,61 @@
+package model
+
+import (
+	"cruder/internal/errors"
+	"fmt"
+
+	"github.com/google/uuid"
+)
+
+// ImportMode decides what a bulk import does with a row whose user already exists.
+type ImportMode string
+
+const (
+	// ImportSkipExisting leaves existing users alone: a row whose email or username is taken is skipped.
+	ImportSkipExisting ImportMode = "skip-existing"
+	// ImportUpsertByEmail updates the username and full name of the user with the row's email
+	// and creates users for the other rows.
+	ImportUpsertByEmail ImportMode = "upsert-by-email"
+)
+
+// ParseImportMode parses a `mode` parameter; an empty string is ImportSkipExisting.
+func ParseImportMode(s string) (ImportMode, error) {
+	switch mode := ImportMode(s); mode {
+	case "":
+		return ImportSkipExisting, nil
+	case ImportSkipExisting, ImportUpsertByEmail:
+		return mode, nil
+	default:
+		return "", fmt.Errorf("%w: unknown import mode %q (valid: %s, %s)", errors.ErrInvalidInput, s, ImportSkipExisting, ImportUpsertByEmail)
+	}
+}
+
+// Outcomes of an imported row
+const (
+	ImportCreated = "created"
+	ImportUpdated = "updated"
+	ImportSkipped = "skipped"
+	ImportFailed  = "error"
+)
+
+// ImportRow is the outcome of one CSV row. Line is the row's line number in the file (the header is line 1).
+// ID is set once the user exists; a dry run that would create a user has none.
+type ImportRow struct {
+	Line     int        `json:"line"`
+	Status   string     `json:"status"`
+	ID       *uuid.UUID `json:"id,omitempty"`
+	Username string     `json:"username"`
+	Email    string     `json:"email"`
+	Reason   string     `json:"reason,omitempty"`
+}
+
+// ImportReport is the result of a bulk import, row by row, in file order.
+type ImportReport struct {
+	Mode    ImportMode  `json:"mode"`
+	DryRun  bool        `json:"dry_run"`
+	Created int         `json:"created"`
+	Updated int         `json:"updated"`
+	Skipped int         `json:"skipped"`
+	Failed  int         `json:"failed"`
+	Rows    []ImportRow `json:"rows"`
+}
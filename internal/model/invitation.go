@@ -0,0 +1,69 @@
+package model
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// Invitation asks someone to create an account with the address it was mailed to. The invitee
+// picks their username and full name when redeeming it.
+type Invitation struct {
+	ID             uuid.UUID `json:"id"`
+	OrganizationID uuid.UUID `json:"organization_id"`
+	Email          string    `json:"email"`
+	// InvitedBy is the subject of the API key or access token that created the invitation, "cli" or "system"
+	InvitedBy string `json:"invited_by"`
+	// Status is derived from the timestamps when the invitation is read
+	Status    InvitationStatus `json:"status"`
+	ExpiresAt time.Time        `json:"expires_at"`
+	// SentAt is when the invitation was last mailed; every resend renews ExpiresAt
+	SentAt    time.Time `json:"sent_at"`
+	CreatedAt time.Time `json:"created_at"`
+	// RedeemedAt and UserID are set once the invitee has created their account; UserID is
+	// cleared again if that user is deleted
+	RedeemedAt *time.Time `json:"redeemed_at,omitempty"`
+	UserID     *uuid.UUID `json:"user_id,omitempty"`
+	RevokedAt  *time.Time `json:"revoked_at,omitempty"`
+	// TokenHash is all that is stored of the mailed token; it never leaves the service layer
+	TokenHash []byte `json:"-"`
+}
+
+// InvitationStatus is where an invitation stands. Only pending invitations can be redeemed.
+type InvitationStatus string
+
+const (
+	InvitationPending  InvitationStatus = "pending"
+	InvitationExpired  InvitationStatus = "expired"
+	InvitationRedeemed InvitationStatus = "redeemed"
+	InvitationRevoked  InvitationStatus = "revoked"
+)
+
+// StatusAt returns the invitation's status at now.
+func (i *Invitation) StatusAt(now time.Time) InvitationStatus {
+	switch {
+	case i.RedeemedAt != nil:
+		return InvitationRedeemed
+	case i.RevokedAt != nil:
+		return InvitationRevoked
+	case !now.Before(i.ExpiresAt):
+		return InvitationExpired
+	default:
+		return InvitationPending
+	}
+}
+
+// CreateInvitationRequest is the body of POST /api/v1/invitations.
+type CreateInvitationRequest struct {
+	Email string `json:"email" binding:"required,email,max=100"`
+	// Actor is who sends the invitation, recorded as InvitedBy
+	Actor string `json:"-"`
+}
+
+// RedeemInvitationRequest is the body of POST /api/v1/invitations/redeem: the token from the
+// invitation email and the new user's details. The email address is the invited one.
+type RedeemInvitationRequest struct {
+	Token    string `json:"token" binding:"required,max=100"`
+	Username string `json:"username" binding:"required,min=3,max=50,alphanum"`
+	FullName string `json:"full_name" binding:"required,min=2,max=100"`
+}
@@ -0,0 +1,89 @@
+package model
+
+import (
+	"encoding/json"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// JobStatus is the lifecycle state of a background job.
+// queued -> running -> succeeded | failed | cancelled; a queued job may also be cancelled directly.
+type JobStatus string
+
+const (
+	JobQueued    JobStatus = "queued"
+	JobRunning   JobStatus = "running"
+	JobSucceeded JobStatus = "succeeded"
+	JobFailed    JobStatus = "failed"
+	JobCancelled JobStatus = "cancelled"
+)
+
+// Finished reports whether the job has reached a final state.
+func (s JobStatus) Finished() bool {
+	return s == JobSucceeded || s == JobFailed || s == JobCancelled
+}
+
+// Job kinds
+const (
+	JobUserExport = "users.export"
+	JobUserImport = "users.import"
+)
+
+// Job is a long-running operation processed by a worker outside the request that started it.
+type Job struct {
+	ID       uuid.UUID       `json:"id"`
+	Kind     string          `json:"kind"`
+	Status   JobStatus       `json:"status"`
+	Params   json.RawMessage `json:"params"`
+	Progress JobProgress     `json:"progress"`
+	Error    string          `json:"error,omitempty"`
+	// ResultType is the media type of the downloadable result, once there is one
+	ResultType      string     `json:"result_type,omitempty"`
+	Attempts        int        `json:"attempts"`
+	CancelRequested bool       `json:"cancel_requested"`
+	CreatedAt       time.Time  `json:"created_at"`
+	StartedAt       *time.Time `json:"started_at,omitempty"`
+	FinishedAt      *time.Time `json:"finished_at,omitempty"`
+	UpdatedAt       time.Time  `json:"updated_at"`
+
+	// Input is an uploaded file the job works on (e.g. the CSV of an import). Only workers load it.
+	Input []byte `json:"-"`
+}
+
+// JobProgress counts work items (rows, users); Total is nil while unknown.
+type JobProgress struct {
+	Done  int64  `json:"done"`
+	Total *int64 `json:"total,omitempty"`
+}
+
+// UserExportParams are the parameters of a users.export job.
+type UserExportParams struct {
+	// Format is one of the export media types (text/csv, application/x-ndjson, application/msgpack)
+	Format string     `json:"format" binding:"required"`
+	Fields UserFields `json:"fields,omitempty"`
+	// Query, if set, exports only the users a search for it returns
+	Query string `json:"q,omitempty"`
+	// OrganizationID is whose users are exported; set by the service, not the caller
+	OrganizationID uuid.UUID `json:"organization_id"`
+}
+
+// UserImportParams are the parameters of a users.import job; the CSV file is the job's Input.
+type UserImportParams struct {
+	Mode   ImportMode `json:"mode"`
+	DryRun bool       `json:"dry_run"`
+	// Actor started the import; it is recorded in the username history for rows that rename a user
+	Actor string `json:"actor,omitempty"`
+	// OrganizationID is the organization the users are imported into
+	OrganizationID uuid.UUID `json:"organization_id"`
+}
+
+// JobOrganization is the organization a job works in, read from its params. Jobs queued before
+// there were organizations have none and belong to the default one.
+func JobOrganization(job *Job) uuid.UUID {
+	var params struct {
+		OrganizationID uuid.UUID `json:"organization_id"`
+	}
+	_ = json.Unmarshal(job.Params, &params)
+	return OrganizationOrDefault(params.OrganizationID)
+}
@@ -0,0 +1,110 @@
+package model
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// OAuthClient is an application that signs users in through the built-in OpenID Connect provider.
+type OAuthClient struct {
+	ID   string `json:"client_id"`
+	Name string `json:"name"`
+	// OrganizationID is the organization that registered the client; only its users can sign in
+	OrganizationID uuid.UUID `json:"organization_id"`
+	// Public clients, such as single-page and native apps, cannot keep a secret and have none;
+	// like every client they must use PKCE
+	Public bool `json:"public"`
+	// RedirectURIs are the only URIs users are sent back to, compared exactly
+	RedirectURIs []string  `json:"redirect_uris"`
+	CreatedAt    time.Time `json:"created_at"`
+	// SecretHash is the SHA-256 of a confidential client's secret; the secret itself is not kept
+	SecretHash []byte `json:"-"`
+}
+
+// RegisterOAuthClientRequest is the body of POST /oauth/clients.
+type RegisterOAuthClientRequest struct {
+	Name         string   `json:"name" binding:"required,min=2,max=100"`
+	RedirectURIs []string `json:"redirect_uris" binding:"required,min=1,max=10,dive,required,max=500"`
+	Public       bool     `json:"public"`
+}
+
+// RegisteredOAuthClient is the response to a registration: the client, with its secret if it is
+// confidential. The secret is shown this once.
+type RegisteredOAuthClient struct {
+	OAuthClient
+	ClientSecret string `json:"client_secret,omitempty"`
+}
+
+// OAuthConsent records that a user allowed a client the scopes in Scope. Deleting it revokes
+// the client's access to the user.
+type OAuthConsent struct {
+	UserID   uuid.UUID `json:"user_id"`
+	ClientID string    `json:"client_id"`
+	// Scope is the space-separated union of every scope the user allowed the client
+	Scope     string    `json:"scope"`
+	GrantedAt time.Time `json:"granted_at"`
+	UpdatedAt time.Time `json:"updated_at"`
+}
+
+// AuthorizationRequest holds the parameters of an OAuth 2.0 authorization request (RFC 6749 §4.1.1),
+// with PKCE (RFC 7636) and the OpenID Connect additions.
+type AuthorizationRequest struct {
+	ResponseType        string `form:"response_type"`
+	ClientID            string `form:"client_id"`
+	RedirectURI         string `form:"redirect_uri"`
+	Scope               string `form:"scope"`
+	State               string `form:"state"`
+	Nonce               string `form:"nonce"`
+	CodeChallenge       string `form:"code_challenge"`
+	CodeChallengeMethod string `form:"code_challenge_method"`
+	Prompt              string `form:"prompt"`
+}
+
+// AuthorizationCode is an issued code, waiting to be exchanged at the token endpoint.
+type AuthorizationCode struct {
+	// CodeHash is the SHA-256 of the code; the code itself only goes to the client
+	CodeHash      []byte
+	ClientID      string
+	UserID        uuid.UUID
+	RedirectURI   string
+	Scope         string
+	Nonce         string
+	CodeChallenge string
+	// AuthTime is when the user signed in, for the auth_time claim
+	AuthTime  time.Time
+	ExpiresAt time.Time
+}
+
+// TokenRequest holds the parameters of a token request (RFC 6749 §4.1.3). The client credentials
+// come from HTTP Basic authentication or, failing that, from the form.
+type TokenRequest struct {
+	GrantType    string `form:"grant_type"`
+	Code         string `form:"code"`
+	RedirectURI  string `form:"redirect_uri"`
+	ClientID     string `form:"client_id"`
+	ClientSecret string `form:"client_secret"`
+	CodeVerifier string `form:"code_verifier"`
+}
+
+// OIDCToken is the token endpoint's response (RFC 6749 §5.1, OpenID Connect Core §3.1.3.3).
+type OIDCToken struct {
+	AccessToken string `json:"access_token"`
+	TokenType   string `json:"token_type"`
+	// ExpiresIn is the lifetime in seconds
+	ExpiresIn int64  `json:"expires_in"`
+	Scope     string `json:"scope"`
+	IDToken   string `json:"id_token"`
+}
+
+// UserInfo holds the standard claims about a user, as returned by the userinfo endpoint and
+// carried by ID tokens. Only the claims of the granted scopes are set.
+type UserInfo struct {
+	// Subject is the user's ID
+	Subject           string `json:"sub"`
+	PreferredUsername string `json:"preferred_username,omitempty"`
+	// Name is the user's full name
+	Name          string `json:"name,omitempty"`
+	Email         string `json:"email,omitempty"`
+	EmailVerified *bool  `json:"email_verified,omitempty"`
+}
@@ -0,0 +1,38 @@
+package model
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// The default organization holds the users created before there were organizations, and those
+// of callers that are not assigned one. It is created by the migration that added organizations.
+var DefaultOrganizationID = uuid.MustParse("00000000-0000-0000-0000-000000000001")
+
+const DefaultOrganizationSlug = "default"
+
+// Organization is a tenant: every user belongs to one, and usernames and email addresses are
+// unique within it.
+type Organization struct {
+	ID uuid.UUID `json:"id"`
+	// Slug names the organization in API client configuration and the X-Organization header
+	Slug      string    `json:"slug"`
+	Name      string    `json:"name"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+// CreateOrganizationRequest is the body of POST /api/v1/organizations.
+type CreateOrganizationRequest struct {
+	Slug string `json:"slug" binding:"required,min=2,max=50"`
+	Name string `json:"name" binding:"required,min=2,max=100"`
+}
+
+// OrganizationOrDefault is org, or DefaultOrganizationID if org is unset, as it is for jobs and
+// tokens made before there were organizations.
+func OrganizationOrDefault(org uuid.UUID) uuid.UUID {
+	if org == uuid.Nil {
+		return DefaultOrganizationID
+	}
+	return org
+}
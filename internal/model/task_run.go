@@ -0,0 +1,30 @@
+package model
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// TaskRunStatus is the outcome of a scheduled task run; running until it finishes.
+type TaskRunStatus string
+
+const (
+	TaskRunning   TaskRunStatus = "running"
+	TaskSucceeded TaskRunStatus = "succeeded"
+	TaskFailed    TaskRunStatus = "failed"
+)
+
+// TaskRun is one run of a scheduled maintenance task, on whichever replica claimed it.
+type TaskRun struct {
+	ID   uuid.UUID `json:"id"`
+	Task string    `json:"task"`
+	// ScheduledAt is the slot the run was for; StartedAt is when it actually began
+	ScheduledAt time.Time     `json:"scheduled_at"`
+	Node        string        `json:"node"`
+	Status      TaskRunStatus `json:"status"`
+	Error       string        `json:"error,omitempty"`
+	StartedAt   time.Time     `json:"started_at"`
+	FinishedAt  *time.Time    `json:"finished_at,omitempty"`
+	DurationMS  *int64        `json:"duration_ms,omitempty"`
+}
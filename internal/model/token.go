@@ -0,0 +1,89 @@
+package model
+
+import (
+	"slices"
+	"time"
+)
+
+// Scopes granted to API clients and carried by their access tokens
+const (
+	ScopeUsersRead  = "users:read"
+	ScopeUsersWrite = "users:write"
+	// ScopeAdmin covers token revocation and operational endpoints, and implies every other scope
+	ScopeAdmin = "admin"
+)
+
+// Scopes lists every scope, in the order they are reported.
+var Scopes = []string{ScopeUsersRead, ScopeUsersWrite, ScopeAdmin}
+
+// How a Principal authenticated
+const (
+	AuthAPIKey = "api_key"
+	AuthToken  = "token"
+	// AuthNone is development mode, with no API keys configured
+	AuthNone = "none"
+)
+
+// Principal is the authenticated caller of a request.
+type Principal struct {
+	// Subject is the API client ID, also the sub of the tokens issued to it
+	Subject string
+	Scopes  []string
+	Method  string
+	// IssuedAt is when the access token was issued; zero for API keys
+	IssuedAt time.Time
+	// Organization is the slug of the organization the client works in; empty for the default one
+	Organization string
+}
+
+// HasScope reports whether p holds scope, directly or through ScopeAdmin.
+func (p *Principal) HasScope(scope string) bool {
+	return slices.Contains(p.Scopes, ScopeAdmin) || slices.Contains(p.Scopes, scope)
+}
+
+// AccessTokenRequest is the optional body of POST /auth/token. Scope narrows the token to a
+// space-separated subset of the caller's scopes; empty means all of them.
+type AccessTokenRequest struct {
+	Scope string `json:"scope" binding:"max=200"`
+}
+
+// AccessToken is the response of POST /auth/token, shaped like an OAuth 2.0 token response (RFC 6749 §5.1).
+type AccessToken struct {
+	AccessToken string `json:"access_token"`
+	TokenType   string `json:"token_type"`
+	// ExpiresIn is the lifetime in seconds
+	ExpiresIn int64  `json:"expires_in"`
+	Scope     string `json:"scope"`
+}
+
+// RevokeTokensRequest is the body of POST /auth/revoke. Subject limits the revocation to one
+// client's tokens, empty revokes everyone's; IssuedBefore defaults to now.
+type RevokeTokensRequest struct {
+	Subject      string     `json:"subject" binding:"max=100"`
+	IssuedBefore *time.Time `json:"issued_before"`
+}
+
+// TokenRevocation rejects the tokens of Subject, or of everyone when empty, issued before IssuedBefore.
+type TokenRevocation struct {
+	Subject      string    `json:"subject"`
+	IssuedBefore time.Time `json:"issued_before"`
+	UpdatedAt    time.Time `json:"updated_at"`
+}
+
+// AuthFailure is the record of wrong API keys presented from one client IP.
+type AuthFailure struct {
+	IP string `json:"ip"`
+	// Failures counts the wrong keys since FirstFailedAt
+	Failures      int       `json:"failures"`
+	FirstFailedAt time.Time `json:"first_failed_at"`
+	LastFailedAt  time.Time `json:"last_failed_at"`
+	// BlockedUntil is when the client may try again; nil if it never had to wait
+	BlockedUntil *time.Time `json:"blocked_until"`
+	// Banned is set once the client failed so often that it is blocked for the ban duration
+	Banned bool `json:"banned"`
+}
+
+// BlockedAt reports whether the client may not try an API key at now.
+func (f *AuthFailure) BlockedAt(now time.Time) bool {
+	return f.BlockedUntil != nil && now.Before(*f.BlockedUntil)
+}
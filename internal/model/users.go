@@ -1,29 +1,193 @@
 package model
 
 import (
+	"cruder/internal/errors"
+	"fmt"
+	"slices"
+	"strings"
 	"time"
 
 	"github.com/google/uuid"
 )
 
 type User struct {
-	ID        uuid.UUID `json:"id"`
-	Username  string    `json:"username"`
-	Email     string    `json:"email"`
-	FullName  string    `json:"full_name"`
-	CreatedAt time.Time `json:"created_at"`
-	UpdatedAt time.Time `json:"updated_at"`
+	ID uuid.UUID `json:"id"`
+	// OrganizationID is the tenant the user belongs to. Sparse fieldsets leave it out of responses,
+	// which are all within the caller's organization anyway
+	OrganizationID uuid.UUID `json:"organization_id"`
+	Username       string    `json:"username"`
+	Email          string    `json:"email"`
+	FullName       string    `json:"full_name"`
+	// Status is where the user is in the account lifecycle; only active users may sign in
+	Status    UserStatus `json:"status"`
+	CreatedAt time.Time  `json:"created_at"`
+	UpdatedAt time.Time  `json:"updated_at"`
+	// EmailVerifiedAt is when the user confirmed Email; null until then and after every change of address
+	EmailVerifiedAt *time.Time `json:"email_verified_at"`
+	// PendingEmailChange is only filled in by GET /users/id/:id and by updates that request a change
+	PendingEmailChange *PendingEmailChange `json:"pending_email_change,omitempty"`
 }
 
 type CreateUserRequest struct {
 	Username string `json:"username" binding:"required,min=3,max=50,alphanum"`
 	Email    string `json:"email" binding:"required,email,max=100"`
 	FullName string `json:"full_name" binding:"required,min=2,max=100"`
+	// Status is the initial status, active if empty. Replace ignores it: status only changes through transitions
+	Status UserStatus `json:"status,omitempty" binding:"omitempty,oneof=invited active"`
+	// Actor is who makes the change, recorded in the username history when a replace renames the user
+	Actor string `json:"-"`
 }
 
+// ReplaceUserRequest is the body of PUT: the whole mutable representation, under the same rules as creation
+type ReplaceUserRequest = CreateUserRequest
+
 // All fields are optional (pointers) to support partial updates (PATCH)
 type UpdateUserRequest struct {
 	Username *string `json:"username,omitempty" binding:"omitempty,min=3,max=50,alphanum"`
 	Email    *string `json:"email,omitempty" binding:"omitempty,email,max=100"`
 	FullName *string `json:"full_name,omitempty" binding:"omitempty,min=2,max=100"`
+	// Actor is who makes the change, recorded in the username history if it renames the user
+	Actor string `json:"-"`
+}
+
+// ActorSystem is the actor recorded for changes made without a known caller, such as background jobs.
+const ActorSystem = "system"
+
+// UsernameChange is one rename in a user's username history.
+type UsernameChange struct {
+	UserID      uuid.UUID `json:"user_id"`
+	OldUsername string    `json:"old_username"`
+	NewUsername string    `json:"new_username"`
+	// Actor is the subject of the API key or access token that made the change, "cli" or "system"
+	Actor     string    `json:"actor"`
+	ChangedAt time.Time `json:"changed_at"`
+}
+
+// UserStatus is a state of the account lifecycle. Users start invited or active; active and
+// suspended users may be switched back and forth; any of them may be deactivated, which is final.
+type UserStatus string
+
+const (
+	UserInvited     UserStatus = "invited"
+	UserActive      UserStatus = "active"
+	UserSuspended   UserStatus = "suspended"
+	UserDeactivated UserStatus = "deactivated"
+)
+
+// UserStatuses are the valid statuses, in lifecycle order.
+var UserStatuses = []UserStatus{UserInvited, UserActive, UserSuspended, UserDeactivated}
+
+// userTransitions lists the statuses each status may change to.
+var userTransitions = map[UserStatus][]UserStatus{
+	UserInvited:   {UserActive, UserDeactivated},
+	UserActive:    {UserSuspended, UserDeactivated},
+	UserSuspended: {UserActive, UserDeactivated},
+}
+
+// CanBecome reports whether a user with status s may be moved to status to.
+func (s UserStatus) CanBecome(to UserStatus) bool {
+	return slices.Contains(userTransitions[s], to)
+}
+
+// ParseUserStatus parses a status filter, returning ErrInvalidInput for anything but a valid status.
+func ParseUserStatus(s string) (UserStatus, error) {
+	status := UserStatus(strings.TrimSpace(s))
+	if !slices.Contains(UserStatuses, status) {
+		names := make([]string, len(UserStatuses))
+		for i, v := range UserStatuses {
+			names[i] = string(v)
+		}
+		return "", fmt.Errorf("%w: unknown status %q (valid: %s)", errors.ErrInvalidInput, s, strings.Join(names, ", "))
+	}
+	return status, nil
+}
+
+// ChangeUserStatusRequest is the body of a status transition. Reason is required to suspend or deactivate.
+type ChangeUserStatusRequest struct {
+	Reason string `json:"reason" binding:"max=500"`
+	// Actor is who makes the change, recorded in the status history
+	Actor string `json:"-"`
+}
+
+// UserStatusChange is one transition in a user's status history.
+type UserStatusChange struct {
+	UserID    uuid.UUID  `json:"user_id"`
+	OldStatus UserStatus `json:"old_status"`
+	NewStatus UserStatus `json:"new_status"`
+	Reason    string     `json:"reason"`
+	// Actor is the subject of the API key or access token that made the change, "cli" or "system"
+	Actor     string    `json:"actor"`
+	ChangedAt time.Time `json:"changed_at"`
+}
+
+// UserFieldNames are the attributes of User a sparse fieldset may name, in output order.
+// They are the JSON names and, not by accident, the users table's column names. New attributes
+// go last, so the columns of existing CSV exports keep their positions.
+var UserFieldNames = []string{"id", "username", "email", "full_name", "created_at", "updated_at", "email_verified_at", "status"}
+
+// UserFields is a sparse fieldset: the User attributes to read and return. Nil means all of them.
+type UserFields []string
+
+// ParseUserFields parses a comma-separated `fields` parameter. Duplicates are dropped and the
+// result follows UserFieldNames order; an empty string selects everything.
+func ParseUserFields(s string) (UserFields, error) {
+	if strings.TrimSpace(s) == "" {
+		return nil, nil
+	}
+	requested := make(map[string]bool)
+	for _, name := range strings.Split(s, ",") {
+		name = strings.TrimSpace(name)
+		if name == "" {
+			continue
+		}
+		if !slices.Contains(UserFieldNames, name) {
+			return nil, fmt.Errorf("%w: unknown field %q in fields (valid: %s)", errors.ErrInvalidInput, name, strings.Join(UserFieldNames, ", "))
+		}
+		requested[name] = true
+	}
+
+	var fields UserFields
+	for _, name := range UserFieldNames {
+		if requested[name] {
+			fields = append(fields, name)
+		}
+	}
+	return fields, nil
+}
+
+// Has reports whether name is selected.
+func (f UserFields) Has(name string) bool {
+	return f == nil || slices.Contains(f, name)
+}
+
+// Project returns the selected attributes of u keyed by JSON name, for encoding a sparse response.
+func (f UserFields) Project(u *User) map[string]any {
+	all := map[string]any{
+		"id":                u.ID,
+		"username":          u.Username,
+		"email":             u.Email,
+		"full_name":         u.FullName,
+		"status":            u.Status,
+		"created_at":        u.CreatedAt,
+		"updated_at":        u.UpdatedAt,
+		"email_verified_at": u.EmailVerifiedAt,
+	}
+	if f == nil {
+		return all
+	}
+	out := make(map[string]any, len(f))
+	for _, name := range f {
+		out[name] = all[name]
+	}
+	return out
+}
+
+// ListUsersParams selects one page of users using keyset pagination ordered by ID.
+// After is the ID of the last user on the previous page; nil starts from the beginning.
+// A non-empty Status lists only the users in that status.
+type ListUsersParams struct {
+	Limit  int
+	After  *uuid.UUID
+	Fields UserFields
+	Status UserStatus
 }
@@ -0,0 +1,49 @@
+package model
+
+import (
+	"cruder/internal/errors"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestParseUserFields(t *testing.T) {
+	fields, err := ParseUserFields("")
+	assert.NoError(t, err)
+	assert.Nil(t, fields)
+
+	// Canonical order, duplicates and blanks dropped
+	fields, err = ParseUserFields(" email,id,,email ")
+	assert.NoError(t, err)
+	assert.Equal(t, UserFields{"id", "email"}, fields)
+
+	_, err = ParseUserFields("id,password")
+	assert.ErrorIs(t, err, errors.ErrInvalidInput)
+	assert.ErrorContains(t, err, `"password"`)
+}
+
+func TestUserFields_Project(t *testing.T) {
+	u := &User{Username: "jdoe", Email: "jdoe@example.com"}
+
+	assert.Equal(t, map[string]any{"username": "jdoe"}, UserFields{"username"}.Project(u))
+	assert.Len(t, UserFields(nil).Project(u), len(UserFieldNames))
+}
+
+func TestUserStatus_CanBecome(t *testing.T) {
+	assert.True(t, UserInvited.CanBecome(UserActive))
+	assert.True(t, UserActive.CanBecome(UserSuspended))
+	assert.True(t, UserSuspended.CanBecome(UserActive))
+	assert.True(t, UserSuspended.CanBecome(UserDeactivated))
+	assert.False(t, UserInvited.CanBecome(UserSuspended), "only active users can be suspended")
+	assert.False(t, UserActive.CanBecome(UserActive))
+	assert.False(t, UserActive.CanBecome(UserInvited))
+	for _, to := range UserStatuses {
+		assert.False(t, UserDeactivated.CanBecome(to), "deactivation is final")
+	}
+
+	status, err := ParseUserStatus(" suspended ")
+	assert.NoError(t, err)
+	assert.Equal(t, UserSuspended, status)
+	_, err = ParseUserStatus("banned")
+	assert.ErrorIs(t, err, errors.ErrInvalidInput)
+}
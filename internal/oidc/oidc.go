@@ -0,0 +1,211 @@
+// Package oidc holds the protocol side of the built-in OpenID Connect provider: scopes and the
+// claims they release, PKCE (RFC 7636), redirect URI rules, OAuth 2.0 error codes (RFC 6749 §4.1.2.1
+// and §5.2), the discovery document, and the sign-in page. The flow itself is service.OAuthService.
+//
+// Only the authorization code flow is supported, and every client must use PKCE with S256.
+package oidc
+
+import (
+	"cruder/internal/model"
+	"cruder/internal/token"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/base64"
+	"fmt"
+	"net/url"
+	"slices"
+	"strings"
+)
+
+// Scopes a client may request. openid is required; the others release claims.
+const (
+	ScopeOpenID  = "openid"
+	ScopeProfile = "profile"
+	ScopeEmail   = "email"
+)
+
+// Scopes lists every supported scope, in the order they are reported.
+var Scopes = []string{ScopeOpenID, ScopeProfile, ScopeEmail}
+
+// Paths of the provider's endpoints, relative to the issuer.
+const (
+	DiscoveryPath = "/.well-known/openid-configuration"
+	AuthorizePath = "/oauth/authorize"
+	TokenPath     = "/oauth/token"
+	UserInfoPath  = "/oauth/userinfo"
+	JWKSPath      = "/.well-known/jwks.json"
+)
+
+// Protocol parameter values this provider supports
+const (
+	ResponseTypeCode  = "code"
+	GrantTypeCode     = "authorization_code"
+	ChallengeS256     = "S256"
+	PromptNone        = "none"
+	TokenTypeBearer   = "Bearer"
+	AuthMethodBasic   = "client_secret_basic"
+	AuthMethodPost    = "client_secret_post"
+	AuthMethodNone    = "none"
+	SubjectTypePublic = "public"
+)
+
+// OAuth 2.0 and OpenID Connect error codes
+const (
+	InvalidRequest          = "invalid_request"
+	InvalidClient           = "invalid_client"
+	InvalidGrant            = "invalid_grant"
+	UnauthorizedClient      = "unauthorized_client"
+	UnsupportedGrantType    = "unsupported_grant_type"
+	UnsupportedResponseType = "unsupported_response_type"
+	InvalidScope            = "invalid_scope"
+	AccessDenied            = "access_denied"
+	LoginRequired           = "login_required"
+	InvalidToken            = "invalid_token"
+)
+
+// Error is an OAuth 2.0 error, reported to the client as error and error_description.
+type Error struct {
+	Code        string `json:"error"`
+	Description string `json:"error_description,omitempty"`
+	// Redirect is false for errors about the client or redirect URI of an authorization request:
+	// those are shown to the user, since the redirect URI cannot be trusted
+	Redirect bool `json:"-"`
+}
+
+func (e *Error) Error() string {
+	if e.Description == "" {
+		return e.Code
+	}
+	return e.Code + ": " + e.Description
+}
+
+// Errorf returns an Error with code and a formatted description.
+func Errorf(code, format string, args ...any) *Error {
+	return &Error{Code: code, Description: fmt.Sprintf(format, args...)}
+}
+
+// ParseScope splits a requested scope, drops the unknown scopes as OpenID Connect Core §3.1.2.1
+// allows, and requires openid.
+func ParseScope(scope string) ([]string, error) {
+	var granted []string
+	for _, sc := range strings.Fields(scope) {
+		if slices.Contains(Scopes, sc) && !slices.Contains(granted, sc) {
+			granted = append(granted, sc)
+		}
+	}
+	if !slices.Contains(granted, ScopeOpenID) {
+		return nil, Errorf(InvalidScope, "the openid scope is required")
+	}
+	// Report them in a stable order, whatever order they came in
+	slices.SortFunc(granted, func(a, b string) int { return slices.Index(Scopes, a) - slices.Index(Scopes, b) })
+	return granted, nil
+}
+
+// ValidateRedirectURI checks a redirect URI at registration: absolute, without a fragment
+// (RFC 6749 §3.1.2), and https unless it points at the client's own machine.
+func ValidateRedirectURI(raw string) error {
+	u, err := url.Parse(raw)
+	if err != nil || !u.IsAbs() || u.Host == "" {
+		return fmt.Errorf("redirect URI %q must be an absolute URL", raw)
+	}
+	if u.Fragment != "" || strings.Contains(raw, "#") {
+		return fmt.Errorf("redirect URI %q must not have a fragment", raw)
+	}
+	switch u.Scheme {
+	case "https":
+	case "http":
+		if host := u.Hostname(); host != "localhost" && host != "127.0.0.1" && host != "::1" {
+			return fmt.Errorf("redirect URI %q must use https unless it is on localhost", raw)
+		}
+	default:
+		return fmt.Errorf("redirect URI %q must use https", raw)
+	}
+	return nil
+}
+
+// ValidCodeChallenge reports whether challenge can be an S256 code challenge: the unpadded
+// base64url encoding of a SHA-256 hash.
+func ValidCodeChallenge(challenge string) bool {
+	b, err := base64.RawURLEncoding.DecodeString(challenge)
+	return err == nil && len(b) == sha256.Size
+}
+
+// VerifyPKCE reports whether verifier matches the S256 challenge. The verifier must be 43 to 128
+// characters from the RFC 7636 §4.1 alphabet.
+func VerifyPKCE(verifier, challenge string) bool {
+	if len(verifier) < 43 || len(verifier) > 128 {
+		return false
+	}
+	for _, c := range verifier {
+		if !(c >= 'A' && c <= 'Z' || c >= 'a' && c <= 'z' || c >= '0' && c <= '9' || strings.ContainsRune("-._~", c)) {
+			return false
+		}
+	}
+	sum := sha256.Sum256([]byte(verifier))
+	return subtle.ConstantTimeCompare([]byte(base64.RawURLEncoding.EncodeToString(sum[:])), []byte(challenge)) == 1
+}
+
+// UserClaims returns the claims about user that scopes release: sub always, preferred_username
+// and name for profile, email and email_verified for email.
+func UserClaims(user *model.User, scopes []string) model.UserInfo {
+	info := model.UserInfo{Subject: user.ID.String()}
+	if slices.Contains(scopes, ScopeProfile) {
+		info.PreferredUsername = user.Username
+		info.Name = user.FullName
+	}
+	if slices.Contains(scopes, ScopeEmail) {
+		verified := user.EmailVerifiedAt != nil
+		info.Email = user.Email
+		info.EmailVerified = &verified
+	}
+	return info
+}
+
+// IDToken is the claims set of an ID token (OpenID Connect Core §2).
+type IDToken struct {
+	Issuer    string `json:"iss"`
+	Audience  string `json:"aud"`
+	ExpiresAt int64  `json:"exp"`
+	IssuedAt  int64  `json:"iat"`
+	AuthTime  int64  `json:"auth_time"`
+	Nonce     string `json:"nonce,omitempty"`
+	model.UserInfo
+}
+
+// Discovery is the provider metadata served at DiscoveryPath (OpenID Connect Discovery §3).
+type Discovery struct {
+	Issuer                            string   `json:"issuer"`
+	AuthorizationEndpoint             string   `json:"authorization_endpoint"`
+	TokenEndpoint                     string   `json:"token_endpoint"`
+	UserInfoEndpoint                  string   `json:"userinfo_endpoint"`
+	JWKSURI                           string   `json:"jwks_uri"`
+	ScopesSupported                   []string `json:"scopes_supported"`
+	ResponseTypesSupported            []string `json:"response_types_supported"`
+	GrantTypesSupported               []string `json:"grant_types_supported"`
+	SubjectTypesSupported             []string `json:"subject_types_supported"`
+	IDTokenSigningAlgValuesSupported  []string `json:"id_token_signing_alg_values_supported"`
+	TokenEndpointAuthMethodsSupported []string `json:"token_endpoint_auth_methods_supported"`
+	CodeChallengeMethodsSupported     []string `json:"code_challenge_methods_supported"`
+	ClaimsSupported                   []string `json:"claims_supported"`
+}
+
+// NewDiscovery returns the metadata of the provider at issuer.
+func NewDiscovery(issuer string) *Discovery {
+	issuer = strings.TrimSuffix(issuer, "/")
+	return &Discovery{
+		Issuer:                            issuer,
+		AuthorizationEndpoint:             issuer + AuthorizePath,
+		TokenEndpoint:                     issuer + TokenPath,
+		UserInfoEndpoint:                  issuer + UserInfoPath,
+		JWKSURI:                           issuer + JWKSPath,
+		ScopesSupported:                   Scopes,
+		ResponseTypesSupported:            []string{ResponseTypeCode},
+		GrantTypesSupported:               []string{GrantTypeCode},
+		SubjectTypesSupported:             []string{SubjectTypePublic},
+		IDTokenSigningAlgValuesSupported:  []string{token.Algorithm},
+		TokenEndpointAuthMethodsSupported: []string{AuthMethodBasic, AuthMethodPost, AuthMethodNone},
+		CodeChallengeMethodsSupported:     []string{ChallengeS256},
+		ClaimsSupported: []string{"sub", "iss", "aud", "exp", "iat", "auth_time", "nonce",
+			"preferred_username", "name", "email", "email_verified"},
+	}
+}
@@ -0,0 +1,59 @@
+package oidc
+
+import (
+	"cruder/internal/model"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestVerifyPKCE(t *testing.T) {
+	// RFC 7636 Appendix B
+	const verifier = "dBjftJeZ4CVP-mB92K27uhbUJU1p1r_wW1gFWFOEjXk"
+	const challenge = "E9Melhoa2OwvFrEMTJguCHaoeK1t8URWbuGJSstw-cM"
+
+	assert.True(t, ValidCodeChallenge(challenge))
+	assert.True(t, VerifyPKCE(verifier, challenge))
+	assert.False(t, VerifyPKCE(verifier+"x", challenge))
+	assert.False(t, VerifyPKCE(verifier[:42], challenge), "too short")
+	assert.False(t, VerifyPKCE(verifier[:42]+"+", challenge), "outside the alphabet")
+	assert.False(t, ValidCodeChallenge("not-a-hash"))
+}
+
+func TestParseScope(t *testing.T) {
+	scopes, err := ParseScope("email  offline_access openid email")
+	require.NoError(t, err)
+	assert.Equal(t, []string{ScopeOpenID, ScopeEmail}, scopes)
+
+	_, err = ParseScope("profile email")
+	var oe *Error
+	require.ErrorAs(t, err, &oe)
+	assert.Equal(t, InvalidScope, oe.Code)
+}
+
+func TestValidateRedirectURI(t *testing.T) {
+	for _, ok := range []string{"https://app.example.com/cb", "http://localhost:3000/cb", "http://127.0.0.1/cb", "http://[::1]:8080/cb"} {
+		assert.NoError(t, ValidateRedirectURI(ok), ok)
+	}
+	for _, bad := range []string{"", "/cb", "app.example.com/cb", "http://app.example.com/cb", "https://app.example.com/cb#x", "custom:cb"} {
+		assert.Error(t, ValidateRedirectURI(bad), bad)
+	}
+}
+
+func TestUserClaims(t *testing.T) {
+	verified := time.Now()
+	user := &model.User{ID: uuid.New(), Username: "jdoe", Email: "jdoe@example.com", FullName: "John Doe", EmailVerifiedAt: &verified}
+
+	info := UserClaims(user, []string{ScopeOpenID})
+	assert.Equal(t, model.UserInfo{Subject: user.ID.String()}, info)
+
+	info = UserClaims(user, []string{ScopeOpenID, ScopeProfile, ScopeEmail})
+	assert.Equal(t, "jdoe", info.PreferredUsername)
+	assert.Equal(t, "John Doe", info.Name)
+	assert.Equal(t, "jdoe@example.com", info.Email)
+	require.NotNil(t, info.EmailVerified)
+	assert.True(t, *info.EmailVerified)
+}
@@ -0,0 +1,50 @@
+package oidc
+
+import (
+	"cruder/internal/model"
+	_ "embed"
+	"html/template"
+	"io"
+)
+
+//go:embed authorize.html
+var authorizeHTML string
+
+var authorizeTemplate = template.Must(template.New("authorize").Parse(authorizeHTML))
+
+// scopePermissions describes each scope on the sign-in page.
+var scopePermissions = map[string]string{
+	ScopeOpenID:  "Know who you are",
+	ScopeProfile: "See your username and full name",
+	ScopeEmail:   "See your email address",
+}
+
+// AuthorizePage is what the sign-in page shows. Without a Request it only shows Error, for
+// authorization requests that cannot be sent back to the client.
+type AuthorizePage struct {
+	// Action is where the form posts, the authorization endpoint
+	Action     string
+	ClientName string
+	// Scopes are the granted scopes, listed as permissions
+	Scopes []string
+	// Request is carried through the form in hidden fields
+	Request *model.AuthorizationRequest
+	// Login is filled back in after a failed attempt
+	Login string
+	Error string
+}
+
+// Permissions describes Scopes for the user.
+func (p *AuthorizePage) Permissions() []string {
+	out := make([]string, 0, len(p.Scopes))
+	for _, sc := range p.Scopes {
+		out = append(out, scopePermissions[sc])
+	}
+	return out
+}
+
+// RenderAuthorizePage writes the sign-in page to w. html/template escapes every value, so the
+// request parameters cannot inject markup.
+func RenderAuthorizePage(w io.Writer, page *AuthorizePage) error {
+	return authorizeTemplate.Execute(w, page)
+}
@@ -0,0 +1,151 @@
+package openapi
+
+import (
+	"reflect"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// Schema is the subset of JSON Schema (2020-12, as used by OpenAPI 3.1) this API needs.
+type Schema struct {
+	Ref                  string             `json:"$ref,omitempty"`
+	Type                 string             `json:"type,omitempty"`
+	Format               string             `json:"format,omitempty"`
+	Description          string             `json:"description,omitempty"`
+	Pattern              string             `json:"pattern,omitempty"`
+	MinLength            *int               `json:"minLength,omitempty"`
+	MaxLength            *int               `json:"maxLength,omitempty"`
+	Minimum              *int               `json:"minimum,omitempty"`
+	Maximum              *int               `json:"maximum,omitempty"`
+	Enum                 []string           `json:"enum,omitempty"`
+	Items                *Schema            `json:"items,omitempty"`
+	Properties           map[string]*Schema `json:"properties,omitempty"`
+	AdditionalProperties *Schema            `json:"additionalProperties,omitempty"`
+	Required             []string           `json:"required,omitempty"`
+	ReadOnly             bool               `json:"readOnly,omitempty"`
+}
+
+var (
+	uuidType = reflect.TypeOf(uuid.UUID{})
+	timeType = reflect.TypeOf(time.Time{})
+)
+
+// SchemaFor derives a schema from a Go struct using its `json` tags for property names and
+// its gin `binding` tags (required, min, max, email, alphanum) for constraints,
+// so the documented contract is the one the controllers actually enforce.
+func SchemaFor(v any) *Schema {
+	return schemaForType(reflect.TypeOf(v), map[reflect.Type]bool{})
+}
+
+// schemaForType derives the schema of t; visiting holds the structs being derived, so a struct
+// that contains itself, such as a tree node, ends in an untyped object instead of recursing forever.
+func schemaForType(t reflect.Type, visiting map[reflect.Type]bool) *Schema {
+	for t.Kind() == reflect.Pointer {
+		t = t.Elem()
+	}
+
+	switch t {
+	case uuidType:
+		return &Schema{Type: "string", Format: "uuid"}
+	case timeType:
+		return &Schema{Type: "string", Format: "date-time"}
+	}
+
+	switch t.Kind() {
+	case reflect.String:
+		return &Schema{Type: "string"}
+	case reflect.Bool:
+		return &Schema{Type: "boolean"}
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return &Schema{Type: "integer"}
+	case reflect.Float32, reflect.Float64:
+		return &Schema{Type: "number"}
+	case reflect.Slice, reflect.Array:
+		return &Schema{Type: "array", Items: schemaForType(t.Elem(), visiting)}
+	case reflect.Map:
+		return &Schema{Type: "object", AdditionalProperties: schemaForType(t.Elem(), visiting)}
+	case reflect.Struct:
+		if visiting[t] {
+			return &Schema{Type: "object", Description: "A nested " + t.Name()}
+		}
+		visiting[t] = true
+		defer delete(visiting, t)
+		return schemaForStruct(t, visiting)
+	}
+	return &Schema{}
+}
+
+func schemaForStruct(t reflect.Type, visiting map[reflect.Type]bool) *Schema {
+	s := &Schema{Type: "object", Properties: map[string]*Schema{}}
+	for i := 0; i < t.NumField(); i++ {
+		f := t.Field(i)
+		if !f.IsExported() {
+			continue
+		}
+		name, _, _ := strings.Cut(f.Tag.Get("json"), ",")
+		if name == "-" {
+			continue
+		}
+		// Embedded structs without a name are flattened, as encoding/json does
+		if ft := f.Type; name == "" && f.Anonymous && ft.Kind() == reflect.Struct {
+			embedded := schemaForStruct(ft, visiting)
+			for n, p := range embedded.Properties {
+				s.Properties[n] = p
+			}
+			s.Required = append(s.Required, embedded.Required...)
+			continue
+		}
+		if name == "" {
+			name = f.Name
+		}
+
+		prop := schemaForType(f.Type, visiting)
+		if applyBinding(prop, f.Tag.Get("binding")) {
+			s.Required = append(s.Required, name)
+		}
+		s.Properties[name] = prop
+	}
+	return s
+}
+
+// applyBinding translates validator tags into schema constraints and reports whether the field is required.
+func applyBinding(s *Schema, tag string) (required bool) {
+	if tag == "" {
+		return false
+	}
+	for _, rule := range strings.Split(tag, ",") {
+		name, param, _ := strings.Cut(rule, "=")
+		switch name {
+		case "required":
+			required = true
+		case "email":
+			s.Format = "email"
+		case "alphanum":
+			s.Pattern = "^[a-zA-Z0-9]+$"
+		case "uuid":
+			s.Format = "uuid"
+		case "oneof":
+			s.Enum = strings.Fields(param)
+		case "min", "max":
+			n, err := strconv.Atoi(param)
+			if err != nil {
+				continue
+			}
+			switch {
+			case s.Type == "string" && name == "min":
+				s.MinLength = &n
+			case s.Type == "string" && name == "max":
+				s.MaxLength = &n
+			case s.Type == "integer" && name == "min":
+				s.Minimum = &n
+			case s.Type == "integer" && name == "max":
+				s.Maximum = &n
+			}
+		}
+	}
+	return required
+}
@@ -0,0 +1,60 @@
+package openapi
+
+import (
+	"cruder/internal/model"
+	"cruder/internal/scim"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestSchemaFor_CreateUserRequestBindingTags(t *testing.T) {
+	s := SchemaFor(model.CreateUserRequest{})
+
+	assert.ElementsMatch(t, []string{"username", "email", "full_name"}, s.Required)
+
+	username := s.Properties["username"]
+	require.NotNil(t, username)
+	assert.Equal(t, 3, *username.MinLength)
+	assert.Equal(t, 50, *username.MaxLength)
+	assert.Equal(t, "^[a-zA-Z0-9]+$", username.Pattern)
+
+	email := s.Properties["email"]
+	assert.Equal(t, "email", email.Format)
+	assert.Equal(t, 100, *email.MaxLength)
+}
+
+func TestSchemaFor_UpdateUserRequestIsOptional(t *testing.T) {
+	s := SchemaFor(model.UpdateUserRequest{})
+
+	assert.Empty(t, s.Required)
+	assert.Equal(t, "string", s.Properties["full_name"].Type)
+	assert.Equal(t, 2, *s.Properties["full_name"].MinLength)
+}
+
+func TestSchemaFor_UserTypes(t *testing.T) {
+	s := SchemaFor(model.User{})
+
+	assert.Equal(t, "uuid", s.Properties["id"].Format)
+	assert.Equal(t, "date-time", s.Properties["created_at"].Format)
+}
+
+func TestSchemaFor_FlattensEmbeddedStructs(t *testing.T) {
+	s := SchemaFor(model.RegisteredOAuthClient{})
+
+	assert.Contains(t, s.Properties, "client_id")
+	assert.Contains(t, s.Properties, "redirect_uris")
+	assert.Contains(t, s.Properties, "client_secret")
+	assert.NotContains(t, s.Properties, "OAuthClient")
+	assert.NotContains(t, s.Properties, "SecretHash")
+}
+
+func TestSchemaFor_StopsAtRecursiveStructs(t *testing.T) {
+	s := SchemaFor(scim.Schema{})
+
+	attr := s.Properties["attributes"].Items
+	require.Contains(t, attr.Properties, "subAttributes")
+	assert.Equal(t, "object", attr.Properties["subAttributes"].Items.Type)
+	assert.Empty(t, attr.Properties["subAttributes"].Items.Properties)
+}
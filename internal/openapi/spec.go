@@ -0,0 +1,1322 @@
+// Package openapi builds the OpenAPI 3.1 contract of the HTTP API.
+//
+// Routes are described in operations below; request/response schemas are derived
+// from the model structs so binding rules and documentation cannot drift apart.
+// The handler package has a test that fails if a registered gin route is missing here.
+package openapi
+
+import (
+	"cruder/internal/export"
+	"cruder/internal/model"
+	"cruder/internal/oidc"
+	"cruder/internal/scim"
+	"cruder/internal/token"
+	_ "embed"
+	"strings"
+	"sync"
+)
+
+type Document struct {
+	OpenAPI    string                           `json:"openapi"`
+	Info       Info                             `json:"info"`
+	Paths      map[string]map[string]*Operation `json:"paths"`
+	Components Components                       `json:"components"`
+	Security   []map[string][]string            `json:"security,omitempty"`
+}
+
+type Info struct {
+	Title       string `json:"title"`
+	Version     string `json:"version"`
+	Description string `json:"description,omitempty"`
+}
+
+type Components struct {
+	Schemas         map[string]*Schema         `json:"schemas"`
+	SecuritySchemes map[string]*SecurityScheme `json:"securitySchemes,omitempty"`
+}
+
+type SecurityScheme struct {
+	Type         string `json:"type"`
+	In           string `json:"in,omitempty"`
+	Name         string `json:"name,omitempty"`
+	Scheme       string `json:"scheme,omitempty"`
+	BearerFormat string `json:"bearerFormat,omitempty"`
+}
+
+type Operation struct {
+	OperationID string               `json:"operationId"`
+	Summary     string               `json:"summary"`
+	Description string               `json:"description,omitempty"`
+	Tags        []string             `json:"tags,omitempty"`
+	Parameters  []Parameter          `json:"parameters,omitempty"`
+	RequestBody *RequestBody         `json:"requestBody,omitempty"`
+	Responses   map[string]*Response `json:"responses"`
+	// Security overrides the document-level requirement; an empty list means no authentication
+	Security *[]map[string][]string `json:"security,omitempty"`
+}
+
+type Parameter struct {
+	Name        string  `json:"name"`
+	In          string  `json:"in"`
+	Description string  `json:"description,omitempty"`
+	Required    bool    `json:"required,omitempty"`
+	Schema      *Schema `json:"schema"`
+}
+
+type RequestBody struct {
+	Required bool                  `json:"required"`
+	Content  map[string]*MediaType `json:"content"`
+}
+
+type Response struct {
+	Description string                `json:"description"`
+	Headers     map[string]*Header    `json:"headers,omitempty"`
+	Content     map[string]*MediaType `json:"content,omitempty"`
+}
+
+type Header struct {
+	Description string  `json:"description,omitempty"`
+	Schema      *Schema `json:"schema"`
+}
+
+type MediaType struct {
+	Schema *Schema `json:"schema"`
+}
+
+// ErrorResponse documents the error body every controller returns.
+type ErrorResponse struct {
+	Error string `json:"error"`
+	// Code tells domain errors apart, e.g. user_not_found or username_exists; see internal/errors
+	Code    string            `json:"code,omitempty"`
+	Message string            `json:"message,omitempty"`
+	Details map[string]string `json:"details,omitempty"`
+}
+
+// HealthResponse mirrors controller.HealthResponse (redeclared to avoid an import cycle).
+type HealthResponse struct {
+	Status    string            `json:"status"`
+	Timestamp string            `json:"timestamp"`
+	Checks    map[string]string `json:"checks,omitempty"`
+}
+
+// Version is the API contract version reported in info.version.
+const Version = "1.0.0"
+
+var (
+	buildOnce sync.Once
+	document  *Document
+)
+
+// Spec returns the OpenAPI document. It is built once and must not be modified.
+func Spec() *Document {
+	buildOnce.Do(func() { document = build() })
+	return document
+}
+
+func ref(name string) *Schema { return &Schema{Ref: "#/components/schemas/" + name} }
+
+func jsonContent(s *Schema) map[string]*MediaType {
+	return map[string]*MediaType{"application/json": {Schema: s}}
+}
+
+func jsonResponse(description string, s *Schema) *Response {
+	return &Response{Description: description, Content: jsonContent(s)}
+}
+
+func errorResponse(description string) *Response {
+	return jsonResponse(description, ref("Error"))
+}
+
+// scimResponse is a response in the SCIM media type.
+func scimResponse(description string, s *Schema) *Response {
+	return &Response{Description: description, Content: map[string]*MediaType{scim.MediaType: {Schema: s}}}
+}
+
+func scimErrorResponse(description string) *Response {
+	return scimResponse(description, ref("SCIMError"))
+}
+
+// scimBody is a request body in the SCIM media type or plain JSON, which identity providers send too.
+func scimBody(s *Schema) *RequestBody {
+	return &RequestBody{Required: true, Content: map[string]*MediaType{scim.MediaType: {Schema: s}, "application/json": {Schema: s}}}
+}
+
+// scimList is a SCIM ListResponse of items.
+func scimList(items *Schema) *Schema {
+	list := SchemaFor(scim.ListResponse{})
+	list.Properties["Resources"] = &Schema{Type: "array", Items: items}
+	return list
+}
+
+// usersContent is a list of users as JSON or as one of the streamed export formats picked by Accept.
+func usersContent() map[string]*MediaType {
+	return map[string]*MediaType{
+		"application/json": {Schema: &Schema{Type: "array", Items: ref("User")}},
+		export.MIMECSV:     {Schema: &Schema{Type: "string", Description: "Header row of field names, then one row per user"}},
+		export.MIMENDJSON:  {Schema: &Schema{Type: "string", Description: "One User object per line"}},
+		export.MIMEMsgPack: {Schema: &Schema{Type: "string", Format: "binary", Description: "A sequence of MessagePack maps, one per user"}},
+	}
+}
+
+// acceptedResponse is the 202 of an endpoint that queued a job.
+func acceptedResponse() *Response {
+	return &Response{
+		Description: "Job queued",
+		Headers:     map[string]*Header{"Location": {Description: "The job, at /api/v1/jobs/{id}", Schema: &Schema{Type: "string"}}},
+		Content:     jsonContent(ref("Job")),
+	}
+}
+
+func pathParam(name, description string, s *Schema) Parameter {
+	return Parameter{Name: name, In: "path", Required: true, Description: description, Schema: s}
+}
+
+var (
+	noAuth     = &[]map[string][]string{}
+	userIDPath = pathParam("id", "User ID", &Schema{Type: "string", Format: "uuid"})
+	jobIDPath  = pathParam("id", "Job ID", &Schema{Type: "string", Format: "uuid"})
+	clientPath = pathParam("id", "OAuth client ID", &Schema{Type: "string"})
+	orgIDPath  = pathParam("id", "Organization ID", &Schema{Type: "string", Format: "uuid"})
+	groupPath  = pathParam("id", "Group ID", &Schema{Type: "string", Format: "uuid"})
+	invitePath = pathParam("id", "Invitation ID", &Schema{Type: "string", Format: "uuid"})
+	scimIDPath = pathParam("id", "User ID", &Schema{Type: "string"})
+
+	fieldNames  = strings.Join(model.UserFieldNames, "|")
+	fieldsQuery = Parameter{
+		Name: "fields", In: "query",
+		Description: "Sparse fieldset: comma-separated User attributes to return (" + strings.Join(model.UserFieldNames, ", ") + "). Defaults to all.",
+		Schema:      &Schema{Type: "string", Pattern: "^(" + fieldNames + ")(,(" + fieldNames + "))*$"},
+	}
+)
+
+// userStatuses are the values of model.UserStatus, for enums.
+func userStatuses() []string {
+	statuses := make([]string, len(model.UserStatuses))
+	for i, s := range model.UserStatuses {
+		statuses[i] = string(s)
+	}
+	return statuses
+}
+
+// statusOperation documents POST /api/v1/users/id/:id/{action}, a lifecycle transition.
+func statusOperation(action, id, summary, description string) route {
+	return route{"POST", "/api/v1/users/id/:id/" + action, &Operation{
+		OperationID: id, Summary: summary, Tags: []string{"users"},
+		Description: description + " The transition is recorded in the status history.",
+		Parameters:  []Parameter{userIDPath},
+		RequestBody: &RequestBody{Content: jsonContent(ref("ChangeUserStatusRequest"))},
+		Responses: map[string]*Response{
+			"200": jsonResponse("The user in the new status", ref("User")),
+			"400": errorResponse("Invalid UUID or missing reason"),
+			"404": errorResponse("User not found"),
+			"409": errorResponse("The user's status does not allow the transition"),
+		},
+	}}
+}
+
+func build() *Document {
+	userSchema := SchemaFor(model.User{})
+	userSchema.Properties["id"].ReadOnly = true
+	userSchema.Properties["created_at"].ReadOnly = true
+	userSchema.Properties["updated_at"].ReadOnly = true
+	userSchema.Properties["email_verified_at"].ReadOnly = true
+	userSchema.Properties["email_verified_at"].Description = "When the user confirmed email; null until then and after every change of address"
+	userSchema.Properties["status"].ReadOnly = true
+	userSchema.Properties["status"].Enum = userStatuses()
+	userSchema.Properties["status"].Description = "Lifecycle state; only active users may sign in. Changed by activateUser, suspendUser and deactivateUser"
+	userSchema.Properties["pending_email_change"].ReadOnly = true
+	userSchema.Properties["pending_email_change"].Description = "A requested new email awaiting confirmation; only on GET /users/id/{id} " +
+		"without `fields`, and on the update that requested it"
+
+	createUserSchema := SchemaFor(model.CreateUserRequest{})
+	createUserSchema.Properties["status"].Description = "invited or active (the default); ignored by PUT"
+	statusRequest := SchemaFor(model.ChangeUserStatusRequest{})
+	statusRequest.Properties["reason"].Description = "Why; required to suspend or deactivate, and kept in the status history"
+	statusChange := SchemaFor(model.UserStatusChange{})
+	statusChange.Properties["old_status"].Enum = userStatuses()
+	statusChange.Properties["new_status"].Enum = userStatuses()
+
+	invitationSchema := SchemaFor(model.Invitation{})
+	invitationSchema.Properties["status"].Enum = []string{
+		string(model.InvitationPending), string(model.InvitationExpired), string(model.InvitationRedeemed), string(model.InvitationRevoked),
+	}
+	invitationSchema.Properties["invited_by"].Description = "API client or token subject that created the invitation"
+	invitationSchema.Properties["user_id"].Description = "The user created by redeeming the invitation, until that user is deleted"
+	redeemSchema := SchemaFor(model.RedeemInvitationRequest{})
+	redeemSchema.Properties["token"].Description = "The code from the invitation email"
+
+	jobSchema := SchemaFor(model.Job{})
+	jobSchema.Properties["params"] = &Schema{Type: "object", Description: "The parameters the job was started with"}
+	jobSchema.Properties["status"].Enum = []string{
+		string(model.JobQueued), string(model.JobRunning), string(model.JobSucceeded), string(model.JobFailed), string(model.JobCancelled),
+	}
+	loginSchema := SchemaFor(model.LoginRequest{})
+	loginSchema.Properties["login"].Description = "Username or email address"
+	loginSchema.Properties["password"].Format = "password"
+	changePasswordSchema := SchemaFor(model.ChangePasswordRequest{})
+	changePasswordSchema.Properties["current_password"].Format = "password"
+	changePasswordSchema.Properties["new_password"].Format = "password"
+	revokeSchema := SchemaFor(model.RevokeTokensRequest{})
+	revokeSchema.Properties["subject"].Description = "API client ID; empty or omitted revokes every client's tokens"
+	revokeSchema.Properties["issued_before"].Description = "Defaults to now; cannot be in the future"
+	taskRunSchema := SchemaFor(model.TaskRun{})
+	taskRunSchema.Properties["status"].Enum = []string{string(model.TaskRunning), string(model.TaskSucceeded), string(model.TaskFailed)}
+	exportParams := SchemaFor(model.UserExportParams{})
+	exportParams.Properties["format"].Enum = export.MediaTypes
+	exportParams.Properties["fields"].Items.Enum = model.UserFieldNames
+	registeredClient := SchemaFor(model.RegisteredOAuthClient{})
+	registeredClient.Properties["client_secret"].Description = "Only for confidential clients, and only in this response"
+	scimUser := SchemaFor(scim.User{})
+	scimUser.Properties["id"].ReadOnly = true
+	scimUser.Properties["meta"].ReadOnly = true
+	scimUser.Properties["active"].ReadOnly = true
+	scimUser.Properties["active"].Description = "Whether the user may sign in: false while invited, suspended or deactivated"
+	scimUser.Properties["displayName"].Description = "The full name; name.formatted wins when both are sent"
+	scimUser.Properties["emails"].Description = "One address is kept: the primary one, else the first. A new address waits for the user to confirm it."
+	scimUser.Required = []string{"userName", "emails"}
+	scimError := SchemaFor(scim.Error{})
+	scimError.Properties["scimType"].Enum = []string{
+		scim.InvalidFilter, scim.TooMany, scim.Uniqueness, scim.Mutability, scim.InvalidSyntax, scim.InvalidPath, scim.NoTarget, scim.InvalidValue,
+	}
+	scimPatch := SchemaFor(scim.PatchRequest{})
+	scimPatch.Properties["Operations"].Items.Properties["op"].Enum = []string{"add", "replace", "remove"}
+	scimPatch.Properties["Operations"].Items.Properties["path"].Description = `attr, attr.sub, attr[filter] or attr[filter].sub, e.g. emails[type eq "work"].value`
+	oauthError := SchemaFor(oidc.Error{})
+	oauthError.Properties["error"].Enum = []string{
+		oidc.InvalidRequest, oidc.InvalidClient, oidc.InvalidGrant, oidc.UnsupportedGrantType, oidc.InvalidToken, "server_error",
+	}
+
+	doc := &Document{
+		OpenAPI: "3.1.0",
+		Info: Info{
+			Title:   "Cruder Users API",
+			Version: Version,
+			Description: "User management microservice. Callers authenticate with an API key or an access token " +
+				"obtained for one, and need the users:read scope to read, users:write to change users, " +
+				"or admin, which implies both, for token revocation, bans, OAuth clients and the scheduler history; otherwise 403. " +
+				"A client IP that presents too many wrong API keys gets 429 with Retry-After, even for a right key, " +
+				"until its delay or ban is over. " +
+				"The service is also an OpenID Connect provider for other applications: see the oidc endpoints, " +
+				"which follow the OAuth 2.0 formats rather than this API's. " +
+				"Identity providers can provision users over SCIM 2.0 at /scim/v2 (the scim endpoints), " +
+				"which answers in the SCIM formats except for authentication and scope errors. " +
+				"Users belong to organizations: every call works in the organization of the API client, and callers " +
+				"with the admin scope may name another in the X-Organization header (slug or ID); " +
+				"others get 403 for naming one that is not theirs, and anyone 400 for one that does not exist.",
+		},
+		Paths: map[string]map[string]*Operation{},
+		Components: Components{
+			Schemas: map[string]*Schema{
+				"User":              userSchema,
+				"CreateUserRequest": createUserSchema,
+				"UpdateUserRequest": SchemaFor(model.UpdateUserRequest{}),
+				"JSONPatch": {
+					Type:        "array",
+					Description: "RFC 6902 JSON Patch applied to the User representation",
+					Items: &Schema{
+						Type:     "object",
+						Required: []string{"op", "path"},
+						Properties: map[string]*Schema{
+							"op":    {Type: "string", Enum: []string{"add", "remove", "replace", "move", "copy", "test"}},
+							"path":  {Type: "string", Description: "JSON Pointer, e.g. /full_name"},
+							"from":  {Type: "string"},
+							"value": {},
+						},
+					},
+				},
+				"ImportReport":               SchemaFor(model.ImportReport{}),
+				"Job":                        jobSchema,
+				"UserExportParams":           exportParams,
+				"TaskRun":                    taskRunSchema,
+				"LoginRequest":               loginSchema,
+				"ChangePasswordRequest":      changePasswordSchema,
+				"VerifyEmailRequest":         SchemaFor(model.VerifyEmailRequest{}),
+				"EmailChangeTokenRequest":    SchemaFor(model.EmailChangeTokenRequest{}),
+				"AccessTokenRequest":         SchemaFor(model.AccessTokenRequest{}),
+				"AccessToken":                SchemaFor(model.AccessToken{}),
+				"RevokeTokensRequest":        revokeSchema,
+				"TokenRevocation":            SchemaFor(model.TokenRevocation{}),
+				"AuthFailure":                SchemaFor(model.AuthFailure{}),
+				"UsernameChange":             SchemaFor(model.UsernameChange{}),
+				"ChangeUserStatusRequest":    statusRequest,
+				"UserStatusChange":           statusChange,
+				"JWKS":                       SchemaFor(token.JWKS{}),
+				"Organization":               SchemaFor(model.Organization{}),
+				"CreateOrganizationRequest":  SchemaFor(model.CreateOrganizationRequest{}),
+				"Group":                      SchemaFor(model.Group{}),
+				"CreateGroupRequest":         SchemaFor(model.CreateGroupRequest{}),
+				"UpdateGroupRequest":         SchemaFor(model.UpdateGroupRequest{}),
+				"GroupMember":                SchemaFor(model.GroupMember{}),
+				"AddGroupMemberRequest":      SchemaFor(model.AddGroupMemberRequest{}),
+				"GroupMembershipChange":      SchemaFor(model.GroupMembershipChange{}),
+				"Invitation":                 invitationSchema,
+				"CreateInvitationRequest":    SchemaFor(model.CreateInvitationRequest{}),
+				"RedeemInvitationRequest":    redeemSchema,
+				"OAuthClient":                SchemaFor(model.OAuthClient{}),
+				"RegisterOAuthClientRequest": SchemaFor(model.RegisterOAuthClientRequest{}),
+				"RegisteredOAuthClient":      registeredClient,
+				"OAuthConsent":               SchemaFor(model.OAuthConsent{}),
+				"OIDCToken":                  SchemaFor(model.OIDCToken{}),
+				"UserInfo":                   SchemaFor(model.UserInfo{}),
+				"OIDCDiscovery":              SchemaFor(oidc.Discovery{}),
+				"OAuthError":                 oauthError,
+				"SCIMUser":                   scimUser,
+				"SCIMUserList":               scimList(ref("SCIMUser")),
+				"SCIMPatchRequest":           scimPatch,
+				"SCIMServiceProviderConfig":  SchemaFor(scim.ServiceProviderConfig{}),
+				"SCIMSchema":                 SchemaFor(scim.Schema{}),
+				"SCIMResourceType":           SchemaFor(scim.ResourceType{}),
+				"SCIMError":                  scimError,
+				"Error":                      SchemaFor(ErrorResponse{}),
+				"Health":                     SchemaFor(HealthResponse{}),
+			},
+			SecuritySchemes: map[string]*SecurityScheme{
+				"apiKey":     {Type: "apiKey", In: "header", Name: "X-API-Key"},
+				"bearerAuth": {Type: "http", Scheme: "bearer", BearerFormat: "JWT"},
+			},
+		},
+		Security: []map[string][]string{{"apiKey": {}}, {"bearerAuth": {}}},
+	}
+
+	for _, op := range operations() {
+		path := ginToOpenAPIPath(op.path)
+		if doc.Paths[path] == nil {
+			doc.Paths[path] = map[string]*Operation{}
+		}
+		doc.Paths[path][strings.ToLower(op.method)] = op.Operation
+	}
+	return doc
+}
+
+type route struct {
+	method string
+	path   string // gin syntax, e.g. /api/v1/users/id/:id
+	*Operation
+}
+
+func operations() []route {
+	return []route{
+		{"GET", "/health", &Operation{
+			OperationID: "liveness", Summary: "Liveness probe", Tags: []string{"health"}, Security: noAuth,
+			Responses: map[string]*Response{"200": jsonResponse("Process is alive", ref("Health"))},
+		}},
+		{"GET", "/ready", &Operation{
+			OperationID: "readiness", Summary: "Readiness probe", Tags: []string{"health"}, Security: noAuth,
+			Description: "Checks database connectivity and that the schema is at the version this binary expects.",
+			Responses: map[string]*Response{
+				"200": jsonResponse("Ready for traffic", ref("Health")),
+				"503": jsonResponse("Not ready", ref("Health")),
+			},
+		}},
+		{"GET", "/metrics", &Operation{
+			OperationID: "metrics", Summary: "Prometheus metrics", Tags: []string{"health"}, Security: noAuth,
+			Description: "Counters in the Prometheus text format, such as `cruder_auth_failures_total` by `reason` " +
+				"(invalid_api_key, invalid_token, invalid_password, throttled) and `cruder_auth_bans_total`.",
+			Responses: map[string]*Response{"200": {Description: "Metrics", Content: map[string]*MediaType{"text/plain": {Schema: &Schema{Type: "string"}}}}},
+		}},
+		{"GET", "/openapi.json", &Operation{
+			OperationID: "getOpenAPI", Summary: "This OpenAPI document", Tags: []string{"docs"}, Security: noAuth,
+			Responses: map[string]*Response{"200": jsonResponse("OpenAPI 3.1 document", &Schema{Type: "object"})},
+		}},
+		{"GET", "/docs", &Operation{
+			OperationID: "getDocs", Summary: "Interactive API documentation", Tags: []string{"docs"}, Security: noAuth,
+			Responses: map[string]*Response{"200": {Description: "HTML page", Content: map[string]*MediaType{"text/html": {Schema: &Schema{Type: "string"}}}}},
+		}},
+		{"POST", "/graphql", &Operation{
+			OperationID: "graphql", Summary: "GraphQL endpoint", Tags: []string{"graphql"},
+			Description: "Queries `user`, `userByUsername` and `users` (a cursor connection); mutations `createUser`, " +
+				"`updateUser` and `deleteUser`. Once the body parses the status is 200 and failures are listed in `errors`, " +
+				"each with an `extensions.code` such as BAD_USER_INPUT, CONFLICT or QUERY_TOO_COMPLEX.",
+			RequestBody: &RequestBody{Required: true, Content: jsonContent(&Schema{
+				Type:     "object",
+				Required: []string{"query"},
+				Properties: map[string]*Schema{
+					"query":         {Type: "string"},
+					"operationName": {Type: "string"},
+					"variables":     {Type: "object"},
+				},
+			})},
+			Responses: map[string]*Response{
+				"200": jsonResponse("GraphQL result", &Schema{
+					Type: "object",
+					Properties: map[string]*Schema{
+						"data":   {Type: "object"},
+						"errors": {Type: "array", Items: &Schema{Type: "object"}},
+					},
+				}),
+				"400": errorResponse("Malformed request body"),
+			},
+		}},
+		{"GET", "/api/v1/users", &Operation{
+			OperationID: "listUsers", Summary: "List users", Tags: []string{"users"},
+			Description: "Without parameters returns every user. With `limit`, `after` and/or `status` returns one page ordered by ID; " +
+				"a `Link: <...>; rel=\"next\"` header points to the next page when the page is full. " +
+				"`Accept: text/csv`, `application/x-ndjson` or `application/msgpack` streams the users in that format.",
+			Parameters: []Parameter{
+				{Name: "limit", In: "query", Description: "Page size (default 100)", Schema: &Schema{Type: "integer", Minimum: intPtr(1), Maximum: intPtr(1000)}},
+				{Name: "after", In: "query", Description: "ID of the last user of the previous page", Schema: &Schema{Type: "string", Format: "uuid"}},
+				{Name: "status", In: "query", Description: "Only users in this lifecycle state", Schema: &Schema{Type: "string", Enum: userStatuses()}},
+				fieldsQuery,
+			},
+			Responses: map[string]*Response{
+				"200": {
+					Description: "Users",
+					Headers:     map[string]*Header{"Link": {Description: "RFC 8288 link to the next page", Schema: &Schema{Type: "string"}}},
+					Content:     usersContent(),
+				},
+				"400": errorResponse("Invalid pagination parameters, unknown status or unknown field"),
+			},
+		}},
+		{"GET", "/api/v1/users/search", &Operation{
+			OperationID: "searchUsers", Summary: "Search users", Tags: []string{"users"},
+			Description: "Users whose username, email or full name contains `q` (case-insensitive). " +
+				"Accepts the same export formats as listUsers.",
+			Parameters: []Parameter{
+				{Name: "q", In: "query", Required: true, Description: "Text to look for", Schema: &Schema{Type: "string", MinLength: intPtr(1)}},
+				fieldsQuery,
+			},
+			Responses: map[string]*Response{
+				"200": {Description: "Matching users", Content: usersContent()},
+				"400": errorResponse("Empty query or unknown field"),
+			},
+		}},
+		{"POST", "/api/v1/users", &Operation{
+			OperationID: "createUser", Summary: "Create a user", Tags: []string{"users"},
+			RequestBody: &RequestBody{Required: true, Content: jsonContent(ref("CreateUserRequest"))},
+			Responses: map[string]*Response{
+				"201": jsonResponse("Created", ref("User")),
+				"400": errorResponse("Validation failed"),
+				"409": errorResponse("Username or email already exists, or the username is reserved after a recent rename"),
+			},
+		}},
+		{"POST", "/api/v1/users/import", &Operation{
+			OperationID: "importUsers", Summary: "Import users from CSV", Tags: []string{"users"},
+			Description: "Imports a CSV file with `username`, `email` and `full_name` columns (other columns are ignored), " +
+				"sent as `text/csv` or as the `file` field of a multipart form, at most 10 MiB. Each row is validated like " +
+				"createUser. Failed rows are listed in the report and do not fail the request; `Accept: text/csv` returns the report as CSV. " +
+				"With `Prefer: respond-async` the file is imported by a users.import job instead, whose result is the JSON report.",
+			Parameters: []Parameter{
+				{Name: "Prefer", In: "header", Description: "respond-async to run the import as a job", Schema: &Schema{Type: "string"}},
+				{Name: "mode", In: "query", Description: "What to do with rows whose email (or, when skipping, username) already exists; default skip-existing",
+					Schema: &Schema{Type: "string", Enum: []string{string(model.ImportSkipExisting), string(model.ImportUpsertByEmail)}}},
+				{Name: "dry_run", In: "query", Description: "Validate and report without writing", Schema: &Schema{Type: "boolean"}},
+			},
+			RequestBody: &RequestBody{Required: true, Content: map[string]*MediaType{
+				export.MIMECSV: {Schema: &Schema{Type: "string"}},
+				"multipart/form-data": {Schema: &Schema{
+					Type:       "object",
+					Required:   []string{"file"},
+					Properties: map[string]*Schema{"file": {Type: "string", Format: "binary"}},
+				}},
+			}},
+			Responses: map[string]*Response{
+				"200": {Description: "Per-row report", Content: map[string]*MediaType{
+					"application/json": {Schema: ref("ImportReport")},
+					export.MIMECSV:     {Schema: &Schema{Type: "string", Description: "Columns line, status, id, username, email, reason"}},
+				}},
+				"202": acceptedResponse(),
+				"400": errorResponse("Unknown mode, missing columns or unreadable file"),
+				"413": errorResponse("File too large"),
+				"415": errorResponse("Neither text/csv nor multipart/form-data"),
+			},
+		}},
+		{"POST", "/api/v1/users/export", &Operation{
+			OperationID: "exportUsers", Summary: "Export users in the background", Tags: []string{"users", "jobs"},
+			Description: "Queues a users.export job for exports too large for one request. The job's result is the " +
+				"file listUsers (or, with `q`, searchUsers) would stream in `format`.",
+			RequestBody: &RequestBody{Required: true, Content: jsonContent(ref("UserExportParams"))},
+			Responses: map[string]*Response{
+				"202": acceptedResponse(),
+				"400": errorResponse("Unknown format or field"),
+			},
+		}},
+		{"GET", "/api/v1/jobs/:id", &Operation{
+			OperationID: "getJob", Summary: "Get a job", Tags: []string{"jobs"},
+			Description: "Poll until `status` is succeeded, failed or cancelled. `progress.done` counts the rows handled so far.",
+			Parameters:  []Parameter{jobIDPath},
+			Responses: map[string]*Response{
+				"200": jsonResponse("Job", ref("Job")),
+				"400": errorResponse("Invalid UUID"),
+				"404": errorResponse("Job not found"),
+			},
+		}},
+		{"GET", "/api/v1/jobs/:id/result", &Operation{
+			OperationID: "getJobResult", Summary: "Download a job's result", Tags: []string{"jobs"},
+			Description: "The result of a succeeded job, in the job's `result_type`.",
+			Parameters:  []Parameter{jobIDPath},
+			Responses: map[string]*Response{
+				"200": {Description: "Result", Content: map[string]*MediaType{"*/*": {Schema: &Schema{Type: "string", Format: "binary"}}}},
+				"400": errorResponse("Invalid UUID"),
+				"404": errorResponse("Job not found"),
+				"409": errorResponse("Job has not succeeded or has no result"),
+			},
+		}},
+		{"POST", "/api/v1/jobs/:id/cancel", &Operation{
+			OperationID: "cancelJob", Summary: "Cancel a job", Tags: []string{"jobs"},
+			Description: "A queued job is cancelled at once (200). A running job is asked to stop (202) and becomes " +
+				"cancelled within a few seconds.",
+			Parameters: []Parameter{jobIDPath},
+			Responses: map[string]*Response{
+				"200": jsonResponse("Cancelled", ref("Job")),
+				"202": jsonResponse("Cancellation requested", ref("Job")),
+				"400": errorResponse("Invalid UUID"),
+				"404": errorResponse("Job not found"),
+				"409": errorResponse("Job already finished"),
+			},
+		}},
+		{"POST", "/api/v1/auth/login", &Operation{
+			OperationID: "login", Summary: "Log in with a password", Tags: []string{"auth"},
+			Description: "`login` is a username or email address. Unknown users, users without a password and wrong " +
+				"passwords all get the same 401 after about the same time. A user who is not active gets a 403, " +
+				"but only with the right password.",
+			RequestBody: &RequestBody{Required: true, Content: jsonContent(ref("LoginRequest"))},
+			Responses: map[string]*Response{
+				"200": jsonResponse("The authenticated user", ref("User")),
+				"400": errorResponse("Validation failed"),
+				"401": errorResponse("Invalid username or password"),
+				"403": errorResponse("The user is invited, suspended or deactivated"),
+			},
+		}},
+		{"POST", "/api/v1/auth/token", &Operation{
+			OperationID: "issueToken", Summary: "Exchange an API key for an access token", Tags: []string{"auth"},
+			Description: "Returns a short-lived ES256 JWT carrying the API client as `sub` and its scopes, to send as " +
+				"`Authorization: Bearer` instead of the API key. `scope` narrows the token to some of the client's " +
+				"scopes. Access tokens cannot be exchanged for new ones.",
+			Security:    &[]map[string][]string{{"apiKey": {}}},
+			RequestBody: &RequestBody{Content: jsonContent(ref("AccessTokenRequest"))},
+			Responses: map[string]*Response{
+				"200": jsonResponse("Access token", ref("AccessToken")),
+				"400": errorResponse("Unknown scope"),
+				"403": errorResponse("Scope not held by the client, or called with an access token"),
+			},
+		}},
+		{"POST", "/api/v1/auth/revoke", &Operation{
+			OperationID: "revokeTokens", Summary: "Revoke access tokens", Tags: []string{"auth"},
+			Description: "Rejects the access tokens of one API client, or of all of them, issued before `issued_before`. " +
+				"Takes effect on every replica within 10 seconds. Requires the admin scope.",
+			RequestBody: &RequestBody{Required: true, Content: jsonContent(ref("RevokeTokensRequest"))},
+			Responses: map[string]*Response{
+				"200": jsonResponse("The revocation now in effect for the subject", ref("TokenRevocation")),
+				"400": errorResponse("Validation failed or issued_before in the future"),
+				"403": errorResponse("Missing admin scope"),
+			},
+		}},
+		{"GET", "/api/v1/auth/bans", &Operation{
+			OperationID: "listBans", Summary: "List banned clients", Tags: []string{"auth"},
+			Description: "Client IPs banned for presenting too many wrong API keys, latest failure first, on every replica. " +
+				"Requires the admin scope.",
+			Responses: map[string]*Response{
+				"200": jsonResponse("Banned clients", &Schema{Type: "array", Items: ref("AuthFailure")}),
+				"403": errorResponse("Missing admin scope"),
+			},
+		}},
+		{"DELETE", "/api/v1/auth/bans/:ip", &Operation{
+			OperationID: "liftBan", Summary: "Lift a ban", Tags: []string{"auth"},
+			Description: "Lets a banned client IP authenticate again at once and forgets its failures. Requires the admin scope.",
+			Parameters:  []Parameter{pathParam("ip", "Client IPv4 or IPv6 address", &Schema{Type: "string"})},
+			Responses: map[string]*Response{
+				"204": {Description: "Ban lifted"},
+				"400": errorResponse("Invalid IP address"),
+				"403": errorResponse("Missing admin scope"),
+				"404": errorResponse("Client not banned"),
+			},
+		}},
+		{"GET", "/.well-known/jwks.json", &Operation{
+			OperationID: "getJWKS", Summary: "Access token signing keys", Tags: []string{"auth"}, Security: noAuth,
+			Description: "The public keys access tokens are signed with, as a JSON Web Key Set; a token's `kid` header names its key.",
+			Responses:   map[string]*Response{"200": jsonResponse("JSON Web Key Set", ref("JWKS"))},
+		}},
+		{"GET", "/.well-known/openid-configuration", &Operation{
+			OperationID: "getOIDCDiscovery", Summary: "OpenID Connect discovery", Tags: []string{"oidc"}, Security: noAuth,
+			Description: "Provider metadata: the endpoints below under OIDC_ISSUER, the supported scopes (openid, profile, email) " +
+				"and claims. ID tokens are signed with ES256 by the keys at /.well-known/jwks.json.",
+			Responses: map[string]*Response{"200": jsonResponse("Provider metadata", ref("OIDCDiscovery"))},
+		}},
+		{"GET", "/oauth/authorize", &Operation{
+			OperationID: "oidcAuthorizePage", Summary: "Sign-in page of the authorization code flow", Tags: []string{"oidc"}, Security: noAuth,
+			Description: "Where client applications send users. Shows a sign-in and consent page for a valid request. " +
+				"An unknown client or unregistered `redirect_uri` is shown on the page; any other error, such as a missing " +
+				"PKCE challenge or `prompt=none`, goes back to the redirect URI as `error`, `error_description` and `state`.",
+			Parameters: []Parameter{
+				{Name: "response_type", In: "query", Required: true, Schema: &Schema{Type: "string", Enum: []string{oidc.ResponseTypeCode}}},
+				{Name: "client_id", In: "query", Required: true, Schema: &Schema{Type: "string"}},
+				{Name: "redirect_uri", In: "query", Required: true, Description: "One of the client's redirect URIs, exactly", Schema: &Schema{Type: "string"}},
+				{Name: "scope", In: "query", Required: true, Description: "Must include openid; unknown scopes are ignored", Schema: &Schema{Type: "string"}},
+				{Name: "state", In: "query", Schema: &Schema{Type: "string"}},
+				{Name: "nonce", In: "query", Description: "Copied into the ID token", Schema: &Schema{Type: "string"}},
+				{Name: "code_challenge", In: "query", Required: true, Description: "BASE64URL(SHA256(code_verifier))", Schema: &Schema{Type: "string"}},
+				{Name: "code_challenge_method", In: "query", Required: true, Schema: &Schema{Type: "string", Enum: []string{oidc.ChallengeS256}}},
+				{Name: "prompt", In: "query", Description: "none always fails with login_required", Schema: &Schema{Type: "string"}},
+			},
+			Responses: map[string]*Response{
+				"200": {Description: "Sign-in page", Content: map[string]*MediaType{"text/html": {Schema: &Schema{Type: "string"}}}},
+				"302": {Description: "Error sent back to the client", Headers: map[string]*Header{"Location": {Schema: &Schema{Type: "string"}}}},
+				"400": {Description: "Unknown client or redirect URI", Content: map[string]*MediaType{"text/html": {Schema: &Schema{Type: "string"}}}},
+			},
+		}},
+		{"POST", "/oauth/authorize", &Operation{
+			OperationID: "oidcAuthorize", Summary: "Sign in and consent", Tags: []string{"oidc"}, Security: noAuth,
+			Description: "Posted by the sign-in page with the request's parameters, `login`, `password` and `decision`. " +
+				"On allow, records the user's consent and redirects with `code` and `state`; the code works once, for a minute by default. " +
+				"On deny, redirects with access_denied. Wrong passwords count towards the same per-IP delays and bans as wrong API keys.",
+			RequestBody: &RequestBody{Required: true, Content: map[string]*MediaType{"application/x-www-form-urlencoded": {Schema: &Schema{
+				Type: "object",
+				Properties: map[string]*Schema{
+					"login":    {Type: "string", Description: "Username or email address"},
+					"password": {Type: "string", Format: "password"},
+					"decision": {Type: "string", Enum: []string{"allow", "deny"}},
+				},
+			}}}},
+			Responses: map[string]*Response{
+				"302": {Description: "Back to the client with a code or an error", Headers: map[string]*Header{"Location": {Schema: &Schema{Type: "string"}}}},
+				"400": {Description: "Unknown client or redirect URI", Content: map[string]*MediaType{"text/html": {Schema: &Schema{Type: "string"}}}},
+				"401": {Description: "Wrong login or password; the page is shown again", Content: map[string]*MediaType{"text/html": {Schema: &Schema{Type: "string"}}}},
+				"403": {Description: "The user is not active; the page is shown again", Content: map[string]*MediaType{"text/html": {Schema: &Schema{Type: "string"}}}},
+				"429": {
+					Description: "Too many failed attempts from this IP",
+					Headers:     map[string]*Header{"Retry-After": {Description: "Seconds to wait", Schema: &Schema{Type: "integer"}}},
+					Content:     map[string]*MediaType{"text/html": {Schema: &Schema{Type: "string"}}},
+				},
+			},
+		}},
+		{"POST", "/oauth/token", &Operation{
+			OperationID: "oidcToken", Summary: "Exchange an authorization code for tokens", Tags: []string{"oidc"}, Security: noAuth,
+			Description: "Confidential clients authenticate with HTTP Basic or `client_id` and `client_secret` in the form; " +
+				"public clients send `client_id` only. `code_verifier` must match the authorization's `code_challenge`. " +
+				"The access token is accepted only by the userinfo endpoint, not by this API.",
+			RequestBody: &RequestBody{Required: true, Content: map[string]*MediaType{"application/x-www-form-urlencoded": {Schema: &Schema{
+				Type:     "object",
+				Required: []string{"grant_type", "code", "redirect_uri", "code_verifier"},
+				Properties: map[string]*Schema{
+					"grant_type":    {Type: "string", Enum: []string{oidc.GrantTypeCode}},
+					"code":          {Type: "string"},
+					"redirect_uri":  {Type: "string"},
+					"code_verifier": {Type: "string"},
+					"client_id":     {Type: "string"},
+					"client_secret": {Type: "string"},
+				},
+			}}}},
+			Responses: map[string]*Response{
+				"200": jsonResponse("Access token and ID token", ref("OIDCToken")),
+				"400": jsonResponse("Invalid request or grant", ref("OAuthError")),
+				"401": jsonResponse("Client authentication failed", ref("OAuthError")),
+			},
+		}},
+		{"GET", "/oauth/userinfo", &Operation{
+			OperationID: "oidcUserInfo", Summary: "Claims about the signed-in user", Tags: []string{"oidc"},
+			Description: "Takes an access token from the token endpoint. Returns `sub` and the claims of the granted scopes; " +
+				"fails with invalid_token once the user revoked their consent.",
+			Security: &[]map[string][]string{{"bearerAuth": {}}},
+			Responses: map[string]*Response{
+				"200": jsonResponse("User claims", ref("UserInfo")),
+				"401": jsonResponse("Missing, invalid or revoked access token", ref("OAuthError")),
+			},
+		}},
+		{"POST", "/oauth/userinfo", &Operation{
+			OperationID: "oidcUserInfoPost", Summary: "Claims about the signed-in user", Tags: []string{"oidc"},
+			Description: "The same as GET, for clients that POST.",
+			Security:    &[]map[string][]string{{"bearerAuth": {}}},
+			Responses: map[string]*Response{
+				"200": jsonResponse("User claims", ref("UserInfo")),
+				"401": jsonResponse("Missing, invalid or revoked access token", ref("OAuthError")),
+			},
+		}},
+		{"POST", "/api/v1/oauth/clients", &Operation{
+			OperationID: "registerOAuthClient", Summary: "Register an OAuth client", Tags: []string{"oidc"},
+			Description: "Registers an application that signs users in through this service. Redirect URIs must be absolute, " +
+				"without a fragment, and https unless on localhost. Confidential clients get a secret, shown only in this " +
+				"response; public clients have none. Requires the admin scope.",
+			RequestBody: &RequestBody{Required: true, Content: jsonContent(ref("RegisterOAuthClientRequest"))},
+			Responses: map[string]*Response{
+				"201": jsonResponse("Registered client", ref("RegisteredOAuthClient")),
+				"400": errorResponse("Validation failed or invalid redirect URI"),
+				"403": errorResponse("Missing admin scope"),
+			},
+		}},
+		{"GET", "/api/v1/oauth/clients", &Operation{
+			OperationID: "listOAuthClients", Summary: "List OAuth clients", Tags: []string{"oidc"},
+			Description: "Every registered client, oldest first. Requires the admin scope.",
+			Responses: map[string]*Response{
+				"200": jsonResponse("Clients", &Schema{Type: "array", Items: ref("OAuthClient")}),
+				"403": errorResponse("Missing admin scope"),
+			},
+		}},
+		{"GET", "/api/v1/oauth/clients/:id", &Operation{
+			OperationID: "getOAuthClient", Summary: "Get an OAuth client", Tags: []string{"oidc"},
+			Description: "Requires the admin scope.",
+			Parameters:  []Parameter{clientPath},
+			Responses: map[string]*Response{
+				"200": jsonResponse("Client", ref("OAuthClient")),
+				"403": errorResponse("Missing admin scope"),
+				"404": errorResponse("Client not found"),
+			},
+		}},
+		{"DELETE", "/api/v1/oauth/clients/:id", &Operation{
+			OperationID: "deleteOAuthClient", Summary: "Delete an OAuth client", Tags: []string{"oidc"},
+			Description: "Deletes the client with its users' consents and pending codes; its access tokens stop working " +
+				"at the userinfo endpoint. Requires the admin scope.",
+			Parameters: []Parameter{clientPath},
+			Responses: map[string]*Response{
+				"204": {Description: "Client deleted"},
+				"403": errorResponse("Missing admin scope"),
+				"404": errorResponse("Client not found"),
+			},
+		}},
+		{"GET", "/scim/v2/ServiceProviderConfig", &Operation{
+			OperationID: "scimServiceProviderConfig", Summary: "SCIM features supported", Tags: []string{"scim"},
+			Description: "PATCH and filtering are supported; bulk, sorting, ETags and password changes are not.",
+			Responses: map[string]*Response{
+				"200": scimResponse("Service provider configuration", ref("SCIMServiceProviderConfig")),
+			},
+		}},
+		{"GET", "/scim/v2/Schemas", &Operation{
+			OperationID: "scimListSchemas", Summary: "SCIM schemas", Tags: []string{"scim"},
+			Description: "The User schema as this service implements it: which attributes exist, their types and which are read-only.",
+			Responses: map[string]*Response{
+				"200": scimResponse("Schemas", scimList(ref("SCIMSchema"))),
+			},
+		}},
+		{"GET", "/scim/v2/Schemas/:id", &Operation{
+			OperationID: "scimGetSchema", Summary: "Get a SCIM schema", Tags: []string{"scim"},
+			Parameters: []Parameter{pathParam("id", "Schema URN", &Schema{Type: "string", Enum: []string{scim.UserSchema}})},
+			Responses: map[string]*Response{
+				"200": scimResponse("Schema", ref("SCIMSchema")),
+				"404": scimErrorResponse("Unknown schema"),
+			},
+		}},
+		{"GET", "/scim/v2/ResourceTypes", &Operation{
+			OperationID: "scimListResourceTypes", Summary: "SCIM resource types", Tags: []string{"scim"},
+			Description: "User is the only resource type.",
+			Responses: map[string]*Response{
+				"200": scimResponse("Resource types", scimList(ref("SCIMResourceType"))),
+			},
+		}},
+		{"GET", "/scim/v2/ResourceTypes/:id", &Operation{
+			OperationID: "scimGetResourceType", Summary: "Get a SCIM resource type", Tags: []string{"scim"},
+			Parameters: []Parameter{pathParam("id", "Resource type", &Schema{Type: "string", Enum: []string{"User"}})},
+			Responses: map[string]*Response{
+				"200": scimResponse("Resource type", ref("SCIMResourceType")),
+				"404": scimErrorResponse("Unknown resource type"),
+			},
+		}},
+		{"GET", "/scim/v2/Users", &Operation{
+			OperationID: "scimListUsers", Summary: "Query users over SCIM", Tags: []string{"scim"},
+			Description: "Users in ID order, optionally filtered (RFC 7644 §3.4.2.2), e.g. `userName eq \"jdoe\"`, " +
+				"`emails.value co \"@example.com\"` or `meta.lastModified gt \"2026-01-01T00:00:00Z\"`. " +
+				"`userName eq` is a lookup; other filters scan every user.",
+			Parameters: []Parameter{
+				{Name: "filter", In: "query", Description: "SCIM filter expression", Schema: &Schema{Type: "string"}},
+				{Name: "startIndex", In: "query", Description: "1-based index of the first result (default 1)", Schema: &Schema{Type: "integer"}},
+				{Name: "count", In: "query", Description: "At most this many results (default 100, at most 1000)", Schema: &Schema{Type: "integer", Minimum: intPtr(0), Maximum: intPtr(scim.MaxResults)}},
+			},
+			Responses: map[string]*Response{
+				"200": scimResponse("A page of matching users", ref("SCIMUserList")),
+				"400": scimErrorResponse("Invalid filter, startIndex or count"),
+			},
+		}},
+		{"POST", "/scim/v2/Users", &Operation{
+			OperationID: "scimCreateUser", Summary: "Provision a user over SCIM", Tags: []string{"scim"},
+			Description: "Validated like createUser. The full name is name.formatted, else displayName, else name.givenName and name.familyName.",
+			RequestBody: scimBody(ref("SCIMUser")),
+			Responses: map[string]*Response{
+				"201": {
+					Description: "Created user",
+					Headers:     map[string]*Header{"Location": {Description: "The user, at /scim/v2/Users/{id}", Schema: &Schema{Type: "string"}}},
+					Content:     map[string]*MediaType{scim.MediaType: {Schema: ref("SCIMUser")}},
+				},
+				"400": scimErrorResponse("Invalid user"),
+				"409": scimErrorResponse("userName or email already exists, or the username is reserved"),
+			},
+		}},
+		{"GET", "/scim/v2/Users/:id", &Operation{
+			OperationID: "scimGetUser", Summary: "Get a user over SCIM", Tags: []string{"scim"},
+			Parameters: []Parameter{scimIDPath},
+			Responses: map[string]*Response{
+				"200": scimResponse("User", ref("SCIMUser")),
+				"404": scimErrorResponse("User not found"),
+			},
+		}},
+		{"PUT", "/scim/v2/Users/:id", &Operation{
+			OperationID: "scimReplaceUser", Summary: "Replace a user over SCIM", Tags: []string{"scim"},
+			Description: "Like replaceUser: a new email is held until the user confirms it, so the response still shows the old one.",
+			Parameters:  []Parameter{scimIDPath},
+			RequestBody: scimBody(ref("SCIMUser")),
+			Responses: map[string]*Response{
+				"200": scimResponse("Replaced user", ref("SCIMUser")),
+				"400": scimErrorResponse("Invalid user, or a different id"),
+				"404": scimErrorResponse("User not found"),
+				"409": scimErrorResponse("userName or email already exists, or the username is reserved"),
+			},
+		}},
+		{"PATCH", "/scim/v2/Users/:id", &Operation{
+			OperationID: "scimPatchUser", Summary: "Patch a user over SCIM", Tags: []string{"scim"},
+			Description: "add, replace and remove operations (RFC 7644 §3.5.2) on the user's SCIM representation; " +
+				"the result is stored as with scimReplaceUser. Read-only attributes can only be set to their current value.",
+			Parameters:  []Parameter{scimIDPath},
+			RequestBody: scimBody(ref("SCIMPatchRequest")),
+			Responses: map[string]*Response{
+				"200": scimResponse("Patched user", ref("SCIMUser")),
+				"400": scimErrorResponse("Invalid operation, path or value, or a read-only attribute changed"),
+				"404": scimErrorResponse("User not found"),
+				"409": scimErrorResponse("userName or email already exists, or the username is reserved"),
+			},
+		}},
+		{"DELETE", "/scim/v2/Users/:id", &Operation{
+			OperationID: "scimDeleteUser", Summary: "Deprovision a user over SCIM", Tags: []string{"scim"},
+			Description: "Deletes the user. Unlike deleteUser, a user that does not exist is a 404.",
+			Parameters:  []Parameter{scimIDPath},
+			Responses: map[string]*Response{
+				"204": {Description: "User deleted"},
+				"404": scimErrorResponse("User not found"),
+			},
+		}},
+		{"POST", "/api/v1/organizations", &Operation{
+			OperationID: "createOrganization", Summary: "Create an organization", Tags: []string{"organizations"},
+			Description: "Adds a tenant. The slug is lowercased and may only contain letters, digits and inner hyphens; " +
+				"organizations cannot be renamed or deleted. Requires the admin scope.",
+			RequestBody: &RequestBody{Required: true, Content: jsonContent(ref("CreateOrganizationRequest"))},
+			Responses: map[string]*Response{
+				"201": jsonResponse("Created organization", ref("Organization")),
+				"400": errorResponse("Validation failed"),
+				"403": errorResponse("Missing admin scope"),
+				"409": errorResponse("Slug already taken"),
+			},
+		}},
+		{"GET", "/api/v1/organizations", &Operation{
+			OperationID: "listOrganizations", Summary: "List organizations", Tags: []string{"organizations"},
+			Description: "Every organization, by slug. Requires the admin scope.",
+			Responses: map[string]*Response{
+				"200": jsonResponse("Organizations", &Schema{Type: "array", Items: ref("Organization")}),
+				"403": errorResponse("Missing admin scope"),
+			},
+		}},
+		{"GET", "/api/v1/organizations/:id", &Operation{
+			OperationID: "getOrganization", Summary: "Get an organization", Tags: []string{"organizations"},
+			Description: "Requires the admin scope.",
+			Parameters:  []Parameter{orgIDPath},
+			Responses: map[string]*Response{
+				"200": jsonResponse("Organization", ref("Organization")),
+				"400": errorResponse("Invalid ID"),
+				"403": errorResponse("Missing admin scope"),
+				"404": errorResponse("Organization not found"),
+			},
+		}},
+		{"POST", "/api/v1/groups", &Operation{
+			OperationID: "createGroup", Summary: "Create a group", Tags: []string{"groups"},
+			Description: "Adds a group to the caller's organization. Group names are unique within it.",
+			RequestBody: &RequestBody{Required: true, Content: jsonContent(ref("CreateGroupRequest"))},
+			Responses: map[string]*Response{
+				"201": jsonResponse("Created group", ref("Group")),
+				"400": errorResponse("Validation failed"),
+				"409": errorResponse("Name already taken"),
+			},
+		}},
+		{"GET", "/api/v1/groups", &Operation{
+			OperationID: "listGroups", Summary: "List groups", Tags: []string{"groups"},
+			Description: "Every group of the caller's organization, by name.",
+			Responses: map[string]*Response{
+				"200": jsonResponse("Groups", &Schema{Type: "array", Items: ref("Group")}),
+			},
+		}},
+		{"GET", "/api/v1/groups/:id", &Operation{
+			OperationID: "getGroup", Summary: "Get a group", Tags: []string{"groups"},
+			Parameters: []Parameter{groupPath},
+			Responses: map[string]*Response{
+				"200": jsonResponse("Group", ref("Group")),
+				"400": errorResponse("Invalid UUID"),
+				"404": errorResponse("Group not found"),
+			},
+		}},
+		{"PATCH", "/api/v1/groups/:id", &Operation{
+			OperationID: "updateGroup", Summary: "Update a group", Tags: []string{"groups"},
+			Description: "Renames a group or changes its description; absent fields are left unchanged.",
+			Parameters:  []Parameter{groupPath},
+			RequestBody: &RequestBody{Required: true, Content: jsonContent(ref("UpdateGroupRequest"))},
+			Responses: map[string]*Response{
+				"200": jsonResponse("Updated group", ref("Group")),
+				"400": errorResponse("Validation failed"),
+				"404": errorResponse("Group not found"),
+				"409": errorResponse("Name already taken"),
+			},
+		}},
+		{"DELETE", "/api/v1/groups/:id", &Operation{
+			OperationID: "deleteGroup", Summary: "Delete a group", Tags: []string{"groups"},
+			Description: "Removes the group and its memberships, both its own members and those in groups it was nested in. " +
+				"Its members are not deleted. Each membership that ends is published as a group.member_removed event and, " +
+				"in the groups it was nested in, recorded in their history.",
+			Parameters: []Parameter{groupPath},
+			Responses: map[string]*Response{
+				"204": {Description: "Deleted"},
+				"400": errorResponse("Invalid UUID"),
+				"404": errorResponse("Group not found"),
+			},
+		}},
+		{"GET", "/api/v1/groups/:id/members", &Operation{
+			OperationID: "listGroupMembers", Summary: "List a group's members", Tags: []string{"groups"},
+			Description: "The direct members: nested groups, then users, each by name.",
+			Parameters:  []Parameter{groupPath},
+			Responses: map[string]*Response{
+				"200": jsonResponse("Members", &Schema{Type: "array", Items: ref("GroupMember")}),
+				"400": errorResponse("Invalid UUID"),
+				"404": errorResponse("Group not found"),
+			},
+		}},
+		{"POST", "/api/v1/groups/:id/members", &Operation{
+			OperationID: "addGroupMember", Summary: "Add a member to a group", Tags: []string{"groups"},
+			Description: "Adds the user or the group named by exactly one of `user_id` and `group_id`. Nesting a group " +
+				"that already contains this one is refused. The change is recorded in the group's history and published " +
+				"as a group.member_added event.",
+			Parameters:  []Parameter{groupPath},
+			RequestBody: &RequestBody{Required: true, Content: jsonContent(ref("AddGroupMemberRequest"))},
+			Responses: map[string]*Response{
+				"201": jsonResponse("Added member", ref("GroupMember")),
+				"400": errorResponse("Not exactly one member, or no such user or group"),
+				"404": errorResponse("Group not found"),
+				"409": errorResponse("Already a member, or nesting would create a cycle"),
+			},
+		}},
+		{"DELETE", "/api/v1/groups/:id/members/users/:user_id", &Operation{
+			OperationID: "removeGroupUser", Summary: "Remove a user from a group", Tags: []string{"groups"},
+			Description: "Recorded in the group's history and published as a group.member_removed event. " +
+				"The user stays an effective member if a nested group contains them.",
+			Parameters: []Parameter{groupPath, pathParam("user_id", "User ID", &Schema{Type: "string", Format: "uuid"})},
+			Responses: map[string]*Response{
+				"204": {Description: "Removed"},
+				"400": errorResponse("Invalid UUID"),
+				"404": errorResponse("Group not found, or the user is not a direct member"),
+			},
+		}},
+		{"DELETE", "/api/v1/groups/:id/members/groups/:group_id", &Operation{
+			OperationID: "removeGroupSubgroup", Summary: "Remove a nested group from a group", Tags: []string{"groups"},
+			Description: "Recorded in the group's history and published as a group.member_removed event.",
+			Parameters:  []Parameter{groupPath, pathParam("group_id", "ID of the nested group", &Schema{Type: "string", Format: "uuid"})},
+			Responses: map[string]*Response{
+				"204": {Description: "Removed"},
+				"400": errorResponse("Invalid UUID"),
+				"404": errorResponse("Group not found, or the group is not a direct member"),
+			},
+		}},
+		{"GET", "/api/v1/groups/:id/effective-members", &Operation{
+			OperationID: "listEffectiveGroupMembers", Summary: "List every user in a group", Tags: []string{"groups"},
+			Description: "The users that are members of the group or of any group nested in it, at any depth, by username.",
+			Parameters:  []Parameter{groupPath},
+			Responses: map[string]*Response{
+				"200": jsonResponse("Users", &Schema{Type: "array", Items: ref("User")}),
+				"400": errorResponse("Invalid UUID"),
+				"404": errorResponse("Group not found"),
+			},
+		}},
+		{"GET", "/api/v1/groups/:id/history", &Operation{
+			OperationID: "getGroupHistory", Summary: "List a group's membership changes", Tags: []string{"groups"},
+			Description: "Every member added and removed, newest first, with who made the change.",
+			Parameters:  []Parameter{groupPath},
+			Responses: map[string]*Response{
+				"200": jsonResponse("Membership changes", &Schema{Type: "array", Items: ref("GroupMembershipChange")}),
+				"400": errorResponse("Invalid UUID"),
+				"404": errorResponse("Group not found"),
+			},
+		}},
+		{"GET", "/api/v1/users/id/:id/groups", &Operation{
+			OperationID: "listUserGroups", Summary: "List a user's groups", Tags: []string{"users", "groups"},
+			Description: "The groups the user is a direct member of, by name.",
+			Parameters: []Parameter{userIDPath,
+				{Name: "effective", In: "query", Description: "Also the groups that contain the user through nested groups", Schema: &Schema{Type: "boolean"}},
+			},
+			Responses: map[string]*Response{
+				"200": jsonResponse("Groups", &Schema{Type: "array", Items: ref("Group")}),
+				"400": errorResponse("Invalid UUID or effective"),
+				"404": errorResponse("User not found"),
+			},
+		}},
+		{"POST", "/api/v1/invitations", &Operation{
+			OperationID: "createInvitation", Summary: "Invite someone to create an account", Tags: []string{"invitations"},
+			Description: "Mails the address a token to redeem with redeemInvitation, valid for 7 days by default. " +
+				"The invitation is recorded even if the email cannot be sent; resend it then.",
+			RequestBody: &RequestBody{Required: true, Content: jsonContent(ref("CreateInvitationRequest"))},
+			Responses: map[string]*Response{
+				"201": jsonResponse("Pending invitation", ref("Invitation")),
+				"400": errorResponse("Validation failed"),
+				"409": errorResponse("A user has the address or awaits confirmation of it, or it has a pending invitation"),
+			},
+		}},
+		{"GET", "/api/v1/invitations", &Operation{
+			OperationID: "listInvitations", Summary: "List pending invitations", Tags: []string{"invitations"},
+			Description: "The invitations of the caller's organization that can still be redeemed, newest first.",
+			Responses: map[string]*Response{
+				"200": jsonResponse("Pending invitations", &Schema{Type: "array", Items: ref("Invitation")}),
+			},
+		}},
+		{"GET", "/api/v1/invitations/:id", &Operation{
+			OperationID: "getInvitation", Summary: "Get an invitation", Tags: []string{"invitations"},
+			Description: "Any invitation, whatever its status.",
+			Parameters:  []Parameter{invitePath},
+			Responses: map[string]*Response{
+				"200": jsonResponse("Invitation", ref("Invitation")),
+				"400": errorResponse("Invalid UUID"),
+				"404": errorResponse("Invitation not found"),
+			},
+		}},
+		{"POST", "/api/v1/invitations/:id/resend", &Operation{
+			OperationID: "resendInvitation", Summary: "Resend an invitation", Tags: []string{"invitations"},
+			Description: "Mails a new token, the previous one stops working, and renews the expiry, " +
+				"which also revives an expired invitation.",
+			Parameters: []Parameter{invitePath},
+			Responses: map[string]*Response{
+				"200": jsonResponse("Pending invitation", ref("Invitation")),
+				"400": errorResponse("Invalid UUID"),
+				"404": errorResponse("Invitation not found"),
+				"409": errorResponse("Already redeemed or revoked, or the address was invited again since"),
+			},
+		}},
+		{"POST", "/api/v1/invitations/:id/revoke", &Operation{
+			OperationID: "revokeInvitation", Summary: "Revoke an invitation", Tags: []string{"invitations"},
+			Description: "Its token stops working; the invitation is kept with its `revoked_at`.",
+			Parameters:  []Parameter{invitePath},
+			Responses: map[string]*Response{
+				"200": jsonResponse("Revoked invitation", ref("Invitation")),
+				"400": errorResponse("Invalid UUID"),
+				"404": errorResponse("Invitation not found"),
+				"409": errorResponse("Already redeemed or revoked"),
+			},
+		}},
+		{"POST", "/api/v1/invitations/redeem", &Operation{
+			OperationID: "redeemInvitation", Summary: "Create an account from an invitation", Tags: []string{"invitations", "users"},
+			Description: "Creates the invited user with the chosen username and full name. The email address is the invited one, " +
+				"already verified. The user is created invited and activated by the redemption, as its status history shows. Each token works once; if the user cannot be created, say because " +
+				"the username is taken, it can be used again.",
+			RequestBody: &RequestBody{Required: true, Content: jsonContent(ref("RedeemInvitationRequest"))},
+			Responses: map[string]*Response{
+				"201": jsonResponse("Created user", ref("User")),
+				"400": errorResponse("Validation failed, or the token is unknown, redeemed, revoked or expired"),
+				"409": errorResponse("Username already exists or is reserved, or a user has the address by now"),
+			},
+		}},
+		{"GET", "/api/v1/scheduler/runs", &Operation{
+			OperationID: "listTaskRuns", Summary: "List scheduled task runs", Tags: []string{"scheduler"},
+			Description: "Runs of the periodic maintenance tasks on any replica, newest first. " +
+				"Each scheduled slot runs on one replica only; `duration_ms` and `error` are set once it finishes. " +
+				"Requires the admin scope.",
+			Parameters: []Parameter{
+				{Name: "task", In: "query", Description: "Only runs of this task, e.g. jobs.purge", Schema: &Schema{Type: "string"}},
+				{Name: "limit", In: "query", Description: "At most this many runs (default 100)", Schema: &Schema{Type: "integer", Minimum: intPtr(1), Maximum: intPtr(1000)}},
+			},
+			Responses: map[string]*Response{
+				"200": jsonResponse("Task runs", &Schema{Type: "array", Items: ref("TaskRun")}),
+				"400": errorResponse("Invalid limit"),
+				"403": errorResponse("Missing admin scope"),
+			},
+		}},
+		{"GET", "/api/v1/users/username/:username", &Operation{
+			OperationID: "getUserByUsername", Summary: "Get a user by username", Tags: []string{"users"},
+			Description: "With `include_former=true`, a username nobody holds now resolves to the user who last gave it up " +
+				"in a rename, and a `Link: <...>; rel=\"canonical\"` header gives the URL under the current username.",
+			Parameters: []Parameter{
+				pathParam("username", "Username (case-insensitive)", &Schema{Type: "string"}),
+				fieldsQuery,
+				{Name: "include_former", In: "query", Description: "Also resolve former usernames (default false)", Schema: &Schema{Type: "boolean"}},
+			},
+			Responses: map[string]*Response{
+				"200": {
+					Description: "User",
+					Headers:     map[string]*Header{"Link": {Description: "RFC 8288 canonical link, when a former username was resolved", Schema: &Schema{Type: "string"}}},
+					Content:     jsonContent(ref("User")),
+				},
+				"400": errorResponse("Unknown field or invalid include_former"),
+				"404": errorResponse("User not found"),
+			},
+		}},
+		{"GET", "/api/v1/users/id/:id", &Operation{
+			OperationID: "getUserByID", Summary: "Get a user by ID", Tags: []string{"users"},
+			Description: "Without `fields`, includes `pending_email_change` while an email change awaits confirmation.",
+			Parameters:  []Parameter{userIDPath, fieldsQuery},
+			Responses: map[string]*Response{
+				"200": jsonResponse("User", ref("User")),
+				"400": errorResponse("Invalid UUID or unknown field"),
+				"404": errorResponse("User not found"),
+			},
+		}},
+		{"GET", "/api/v1/users/id/:id/username-history", &Operation{
+			OperationID: "getUsernameHistory", Summary: "List a user's renames", Tags: []string{"users"},
+			Description: "Every change of username, newest first, with who made it. A former username stays reserved for " +
+				"its previous owner for 30 days by default.",
+			Parameters: []Parameter{userIDPath},
+			Responses: map[string]*Response{
+				"200": jsonResponse("Renames", &Schema{Type: "array", Items: ref("UsernameChange")}),
+				"400": errorResponse("Invalid UUID"),
+				"404": errorResponse("User not found"),
+			},
+		}},
+		{"GET", "/api/v1/users/id/:id/status-history", &Operation{
+			OperationID: "getStatusHistory", Summary: "List a user's status changes", Tags: []string{"users"},
+			Description: "Every lifecycle transition, newest first, with the reason given and who made it.",
+			Parameters:  []Parameter{userIDPath},
+			Responses: map[string]*Response{
+				"200": jsonResponse("Status changes", &Schema{Type: "array", Items: ref("UserStatusChange")}),
+				"400": errorResponse("Invalid UUID"),
+				"404": errorResponse("User not found"),
+			},
+		}},
+		statusOperation("activate", "activateUser", "Activate a user",
+			"Makes an invited or suspended user active, so they can sign in. The reason is optional."),
+		statusOperation("suspend", "suspendUser", "Suspend a user",
+			"Blocks an active user from every way of signing in until they are activated again. A reason is required."),
+		statusOperation("deactivate", "deactivateUser", "Deactivate a user",
+			"Retires a user for good instead of deleting them, so their history stays. Deactivation cannot be undone; "+
+				"a reason is required."),
+		{"GET", "/api/v1/users/id/:id/oauth-consents", &Operation{
+			OperationID: "listOAuthConsents", Summary: "List a user's OAuth consents", Tags: []string{"users", "oidc"},
+			Description: "The client applications the user allowed to sign them in, with the scopes allowed, most recently updated first.",
+			Parameters:  []Parameter{userIDPath},
+			Responses: map[string]*Response{
+				"200": jsonResponse("Consents", &Schema{Type: "array", Items: ref("OAuthConsent")}),
+				"400": errorResponse("Invalid UUID"),
+				"404": errorResponse("User not found"),
+			},
+		}},
+		{"DELETE", "/api/v1/users/id/:id/oauth-consents/:client_id", &Operation{
+			OperationID: "revokeOAuthConsent", Summary: "Revoke a user's OAuth consent", Tags: []string{"users", "oidc"},
+			Description: "The client's access tokens for the user stop working at the userinfo endpoint, and its unexchanged " +
+				"codes fail.",
+			Parameters: []Parameter{userIDPath, pathParam("client_id", "OAuth client ID", &Schema{Type: "string"})},
+			Responses: map[string]*Response{
+				"204": {Description: "Consent revoked"},
+				"400": errorResponse("Invalid UUID"),
+				"404": errorResponse("No consent of the user to the client"),
+			},
+		}},
+		{"PUT", "/api/v1/users/id/:id", &Operation{
+			OperationID: "replaceUser", Summary: "Replace a user", Tags: []string{"users"},
+			Description: "Full replacement: every mutable field must be present and is validated like creation. " +
+				"A new email is not applied but held as `pending_email_change` until confirmed (see confirmEmailChange).",
+			Parameters:  []Parameter{userIDPath},
+			RequestBody: &RequestBody{Required: true, Content: jsonContent(ref("CreateUserRequest"))},
+			Responses: map[string]*Response{
+				"200": jsonResponse("Replaced user", ref("User")),
+				"400": errorResponse("Validation failed"),
+				"404": errorResponse("User not found"),
+				"409": errorResponse("Username or email already exists, the username is reserved, or the email is pending for another user"),
+			},
+		}},
+		{"PATCH", "/api/v1/users/id/:id", &Operation{
+			OperationID: "updateUser", Summary: "Partially update a user", Tags: []string{"users"},
+			Description: "`application/json` takes UpdateUserRequest (omitted fields unchanged). " +
+				"`application/merge-patch+json` (RFC 7396) and `application/json-patch+json` (RFC 6902, including `test`) " +
+				"apply to the User representation; the result must pass the same rules as PUT, and id, organization_id, status, created_at, updated_at and email_verified_at cannot change. " +
+				"A new email is held as `pending_email_change` until confirmed, as with PUT.",
+			Parameters: []Parameter{userIDPath},
+			RequestBody: &RequestBody{Required: true, Content: map[string]*MediaType{
+				"application/json":             {Schema: ref("UpdateUserRequest")},
+				"application/merge-patch+json": {Schema: &Schema{Type: "object"}},
+				"application/json-patch+json":  {Schema: ref("JSONPatch")},
+			}},
+			Responses: map[string]*Response{
+				"200": jsonResponse("Updated user", ref("User")),
+				"400": errorResponse("Validation failed or malformed patch"),
+				"404": errorResponse("User not found"),
+				"409": errorResponse("Username or email already exists, the username is reserved, or a JSON Patch test failed"),
+				"415": {
+					Description: "Unsupported patch type",
+					Headers:     map[string]*Header{"Accept-Patch": {Description: "Supported patch media types", Schema: &Schema{Type: "string"}}},
+					Content:     jsonContent(ref("Error")),
+				},
+				"422": errorResponse("Patch cannot be applied, or changes a read-only field"),
+			},
+		}},
+		{"PUT", "/api/v1/users/id/:id/password", &Operation{
+			OperationID: "changePassword", Summary: "Set or change a user's password", Tags: []string{"users", "auth"},
+			Description: "`current_password` is required once the user has a password. The new password must be " +
+				"12 to 128 characters with at least 5 different ones, not a common password, and must not contain " +
+				"the username or the local part of the email.",
+			Parameters:  []Parameter{userIDPath},
+			RequestBody: &RequestBody{Required: true, Content: jsonContent(ref("ChangePasswordRequest"))},
+			Responses: map[string]*Response{
+				"204": {Description: "Password changed"},
+				"400": errorResponse("Invalid UUID or the new password breaks a rule"),
+				"403": errorResponse("Current password missing or incorrect"),
+				"404": errorResponse("User not found"),
+			},
+		}},
+		{"POST", "/api/v1/users/verify-email", &Operation{
+			OperationID: "verifyEmail", Summary: "Confirm a user's email address", Tags: []string{"users"},
+			Description: "Redeems the token mailed to a user on creation, on every change of email and on resend, " +
+				"and sets `email_verified_at`. Tokens work once, expire after 48 hours by default, and stop working " +
+				"once the user changes address again.",
+			RequestBody: &RequestBody{Required: true, Content: jsonContent(ref("VerifyEmailRequest"))},
+			Responses: map[string]*Response{
+				"200": jsonResponse("The verified user", ref("User")),
+				"400": errorResponse("Validation failed, or the token is unknown, used or expired"),
+			},
+		}},
+		{"POST", "/api/v1/users/id/:id/verification-email", &Operation{
+			OperationID: "resendVerificationEmail", Summary: "Resend the verification email", Tags: []string{"users"},
+			Description: "Mails the user a new verification token; the previous one stops working.",
+			Parameters:  []Parameter{userIDPath},
+			Responses: map[string]*Response{
+				"202": {Description: "Email sent"},
+				"400": errorResponse("Invalid UUID"),
+				"404": errorResponse("User not found"),
+				"409": errorResponse("Email address already verified"),
+			},
+		}},
+		{"POST", "/api/v1/users/confirm-email", &Operation{
+			OperationID: "confirmEmailChange", Summary: "Confirm an email change", Tags: []string{"users"},
+			Description: "Redeems the token mailed to the new address when an update asked for it, switches the user to " +
+				"that address and marks it verified. Tokens work once and expire after 24 hours by default.",
+			RequestBody: &RequestBody{Required: true, Content: jsonContent(ref("EmailChangeTokenRequest"))},
+			Responses: map[string]*Response{
+				"200": jsonResponse("The user with the new email", ref("User")),
+				"400": errorResponse("Validation failed, or the token is unknown, used or expired"),
+				"409": errorResponse("The address was taken by another user meanwhile"),
+			},
+		}},
+		{"POST", "/api/v1/users/revert-email-change", &Operation{
+			OperationID: "revertEmailChange", Summary: "Revert an email change", Tags: []string{"users"},
+			Description: "Redeems the \"this wasn't me\" token mailed to the previous address: cancels the change if it is " +
+				"still pending, or restores the previous address, and its verification, if it was confirmed. " +
+				"Tokens work once, for 7 days by default, and not after the user changed email again.",
+			RequestBody: &RequestBody{Required: true, Content: jsonContent(ref("EmailChangeTokenRequest"))},
+			Responses: map[string]*Response{
+				"200": jsonResponse("The user", ref("User")),
+				"400": errorResponse("Validation failed, or the token is unknown, used or expired"),
+				"409": errorResponse("The previous address was taken by another user meanwhile"),
+			},
+		}},
+		{"DELETE", "/api/v1/users/id/:id", &Operation{
+			OperationID: "deleteUser", Summary: "Delete a user", Tags: []string{"users"},
+			Description: "Idempotent: returns 204 whether or not the user existed.",
+			Parameters:  []Parameter{userIDPath},
+			Responses: map[string]*Response{
+				"204": {Description: "Deleted (or did not exist)"},
+				"400": errorResponse("Invalid UUID"),
+			},
+		}},
+	}
+}
+
+func intPtr(n int) *int { return &n }
+
+// ginToOpenAPIPath converts gin path parameters (:id, *path) to OpenAPI templates ({id}).
+func ginToOpenAPIPath(path string) string {
+	segments := strings.Split(path, "/")
+	for i, s := range segments {
+		if strings.HasPrefix(s, ":") || strings.HasPrefix(s, "*") {
+			segments[i] = "{" + s[1:] + "}"
+		}
+	}
+	return strings.Join(segments, "/")
+}
+
+// HasOperation reports whether the document describes method on a gin-style path.
+func (d *Document) HasOperation(method, ginPath string) bool {
+	ops, ok := d.Paths[ginToOpenAPIPath(ginPath)]
+	if !ok {
+		return false
+	}
+	_, ok = ops[strings.ToLower(method)]
+	return ok
+}
+
+// DocsHTML is a self-contained documentation page that renders /openapi.json.
+//
+//go:embed docs.html
+var DocsHTML []byte
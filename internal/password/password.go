@@ -0,0 +1,136 @@
+// Package password hashes and verifies passwords with Argon2id.
+//
+// Hashes are stored in the PHC string format, $argon2id$v=19$m=<KiB>,t=<iterations>,p=<lanes>$<salt>$<key>,
+// so each hash carries the parameters it was made with and old hashes keep verifying after the
+// configured parameters change. Verify reports when a hash is due for an upgrade.
+package password
+
+import (
+	"crypto/rand"
+	"crypto/subtle"
+	"encoding/base64"
+	"fmt"
+	"strings"
+	"sync"
+
+	"golang.org/x/crypto/argon2"
+
+	stdErrors "errors"
+)
+
+// Params are the Argon2id cost parameters.
+type Params struct {
+	// Memory is in KiB
+	Memory      uint32
+	Iterations  uint32
+	Parallelism uint8
+	SaltLength  uint32
+	KeyLength   uint32
+}
+
+// DefaultParams follow the RFC 9106 recommendation for memory-constrained environments
+// (64 MiB, 3 passes), with 2 lanes.
+var DefaultParams = Params{
+	Memory:      64 * 1024,
+	Iterations:  3,
+	Parallelism: 2,
+	SaltLength:  16,
+	KeyLength:   32,
+}
+
+// ErrMalformedHash is returned for a stored hash Verify cannot read.
+var ErrMalformedHash = stdErrors.New("malformed password hash")
+
+// Hasher hashes new passwords with its parameters and verifies hashes made with any.
+type Hasher struct {
+	params Params
+
+	dummyOnce sync.Once
+	dummy     string
+}
+
+// NewHasher returns a Hasher for p. Zero fields take their DefaultParams value.
+func NewHasher(p Params) *Hasher {
+	if p.Memory == 0 {
+		p.Memory = DefaultParams.Memory
+	}
+	if p.Iterations == 0 {
+		p.Iterations = DefaultParams.Iterations
+	}
+	if p.Parallelism == 0 {
+		p.Parallelism = DefaultParams.Parallelism
+	}
+	if p.SaltLength == 0 {
+		p.SaltLength = DefaultParams.SaltLength
+	}
+	if p.KeyLength == 0 {
+		p.KeyLength = DefaultParams.KeyLength
+	}
+	return &Hasher{params: p}
+}
+
+// Hash returns the encoded Argon2id hash of password with a fresh random salt.
+func (h *Hasher) Hash(password string) (string, error) {
+	salt := make([]byte, h.params.SaltLength)
+	if _, err := rand.Read(salt); err != nil {
+		return "", fmt.Errorf("failed to generate salt: %w", err)
+	}
+	key := argon2.IDKey([]byte(password), salt, h.params.Iterations, h.params.Memory, h.params.Parallelism, h.params.KeyLength)
+	return encode(h.params, salt, key), nil
+}
+
+// Verify reports whether password matches encoded, in constant time. rehash is true when the
+// password matches but encoded was made with other parameters than h's, so the caller should
+// store a fresh Hash while it has the plaintext at hand.
+func (h *Hasher) Verify(password, encoded string) (ok, rehash bool, err error) {
+	p, salt, key, err := decode(encoded)
+	if err != nil {
+		return false, false, err
+	}
+	computed := argon2.IDKey([]byte(password), salt, p.Iterations, p.Memory, p.Parallelism, p.KeyLength)
+	if subtle.ConstantTimeCompare(computed, key) != 1 {
+		return false, false, nil
+	}
+	return true, p != h.params, nil
+}
+
+// VerifyDummy does the work of a failed Verify against a hash made with h's parameters. Call it
+// when there is no hash to check (unknown user, no password set) so the response takes as long
+// as for a wrong password and does not reveal which accounts exist.
+func (h *Hasher) VerifyDummy(password string) {
+	h.dummyOnce.Do(func() {
+		h.dummy, _ = h.Hash("dummy password for timing equalization")
+	})
+	_, _, _ = h.Verify(password, h.dummy)
+}
+
+func encode(p Params, salt, key []byte) string {
+	return fmt.Sprintf("$argon2id$v=%d$m=%d,t=%d,p=%d$%s$%s", argon2.Version, p.Memory, p.Iterations, p.Parallelism,
+		base64.RawStdEncoding.EncodeToString(salt), base64.RawStdEncoding.EncodeToString(key))
+}
+
+func decode(encoded string) (p Params, salt, key []byte, err error) {
+	parts := strings.Split(encoded, "$")
+	if len(parts) != 6 || parts[0] != "" || parts[1] != "argon2id" {
+		return p, nil, nil, ErrMalformedHash
+	}
+	var version int
+	if _, err := fmt.Sscanf(parts[2], "v=%d", &version); err != nil || version != argon2.Version {
+		return p, nil, nil, fmt.Errorf("%w: unsupported version %q", ErrMalformedHash, parts[2])
+	}
+	if _, err := fmt.Sscanf(parts[3], "m=%d,t=%d,p=%d", &p.Memory, &p.Iterations, &p.Parallelism); err != nil {
+		return p, nil, nil, fmt.Errorf("%w: %v", ErrMalformedHash, err)
+	}
+	if p.Memory == 0 || p.Iterations == 0 || p.Parallelism == 0 {
+		return p, nil, nil, fmt.Errorf("%w: zero parameter", ErrMalformedHash)
+	}
+	if salt, err = base64.RawStdEncoding.DecodeString(parts[4]); err != nil {
+		return p, nil, nil, fmt.Errorf("%w: salt: %v", ErrMalformedHash, err)
+	}
+	if key, err = base64.RawStdEncoding.DecodeString(parts[5]); err != nil || len(key) == 0 {
+		return p, nil, nil, fmt.Errorf("%w: key", ErrMalformedHash)
+	}
+	p.SaltLength = uint32(len(salt))
+	p.KeyLength = uint32(len(key))
+	return p, salt, key, nil
+}
@@ -0,0 +1,65 @@
+package password
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// cheap keeps the tests fast; production parameters only change the cost.
+var cheap = Params{Memory: 64, Iterations: 1, Parallelism: 1}
+
+func TestHasher_HashAndVerify(t *testing.T) {
+	h := NewHasher(cheap)
+
+	encoded, err := h.Hash("correct horse battery staple")
+	require.NoError(t, err)
+	assert.True(t, strings.HasPrefix(encoded, "$argon2id$v=19$m=64,t=1,p=1$"), encoded)
+
+	ok, rehash, err := h.Verify("correct horse battery staple", encoded)
+	require.NoError(t, err)
+	assert.True(t, ok)
+	assert.False(t, rehash)
+
+	ok, _, err = h.Verify("correct horse battery stapler", encoded)
+	require.NoError(t, err)
+	assert.False(t, ok)
+
+	again, err := h.Hash("correct horse battery staple")
+	require.NoError(t, err)
+	assert.NotEqual(t, encoded, again, "every hash gets its own salt")
+}
+
+func TestHasher_RehashWhenParamsChange(t *testing.T) {
+	old, err := NewHasher(cheap).Hash("correct horse battery staple")
+	require.NoError(t, err)
+
+	stronger := NewHasher(Params{Memory: 128, Iterations: 2, Parallelism: 1})
+	ok, rehash, err := stronger.Verify("correct horse battery staple", old)
+	require.NoError(t, err)
+	assert.True(t, ok, "old hashes keep verifying")
+	assert.True(t, rehash)
+
+	ok, rehash, err = stronger.Verify("wrong", old)
+	require.NoError(t, err)
+	assert.False(t, ok)
+	assert.False(t, rehash, "only a correct password is worth rehashing")
+}
+
+func TestHasher_MalformedHash(t *testing.T) {
+	h := NewHasher(cheap)
+	for _, encoded := range []string{
+		"",
+		"plaintext",
+		"$2a$10$abcdefghijklmnopqrstuu",
+		"$argon2i$v=19$m=64,t=1,p=1$c2FsdHNhbHQ$a2V5",
+		"$argon2id$v=16$m=64,t=1,p=1$c2FsdHNhbHQ$a2V5",
+		"$argon2id$v=19$m=0,t=1,p=1$c2FsdHNhbHQ$a2V5",
+		"$argon2id$v=19$m=64,t=1,p=1$!!!$a2V5",
+	} {
+		_, _, err := h.Verify("password", encoded)
+		assert.ErrorIs(t, err, ErrMalformedHash, "hash %q", encoded)
+	}
+}
@@ -0,0 +1,126 @@
+package repository
+
+import (
+	"context"
+	"cruder/internal/errors"
+	"cruder/internal/model"
+	"database/sql"
+	"time"
+)
+
+type AuthFailureRepository interface {
+	// Get returns the record of ip, or nil if it has none.
+	Get(ip string) (*model.AuthFailure, error)
+	// RecordFailure counts a wrong key from ip at at and returns the updated record. A record whose
+	// last failure came before since starts over at one failure, unblocked.
+	RecordFailure(ip string, at, since time.Time) (*model.AuthFailure, error)
+	// Block makes ip wait until until, banned or not. A block already lasting longer is kept, and
+	// so is a ban.
+	Block(ip string, until time.Time, banned bool) (*model.AuthFailure, error)
+	// ListBanned returns the clients banned at now, latest failure first.
+	ListBanned(now time.Time) ([]model.AuthFailure, error)
+	// LiftBan forgets the record of ip if it is banned at now, and returns ErrBanNotFound otherwise.
+	LiftBan(ip string, now time.Time) error
+	// PruneExpired deletes the records whose last failure and block both ended before before.
+	PruneExpired(before time.Time) (int64, error)
+}
+
+type authFailureRepository struct {
+	db *sql.DB
+}
+
+func NewAuthFailureRepository(db *sql.DB) AuthFailureRepository {
+	return &authFailureRepository{db: db}
+}
+
+const authFailureColumns = `ip, failures, first_failed_at, last_failed_at, blocked_until, banned`
+
+func scanAuthFailure(row interface{ Scan(...any) error }) (*model.AuthFailure, error) {
+	var f model.AuthFailure
+	if err := row.Scan(&f.IP, &f.Failures, &f.FirstFailedAt, &f.LastFailedAt, &f.BlockedUntil, &f.Banned); err != nil {
+		return nil, err
+	}
+	return &f, nil
+}
+
+func (r *authFailureRepository) Get(ip string) (*model.AuthFailure, error) {
+	f, err := scanAuthFailure(r.db.QueryRowContext(context.Background(),
+		`SELECT `+authFailureColumns+` FROM auth_failures WHERE ip = $1`, ip))
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	return f, err
+}
+
+func (r *authFailureRepository) RecordFailure(ip string, at, since time.Time) (*model.AuthFailure, error) {
+	return scanAuthFailure(r.db.QueryRowContext(context.Background(), `
+		INSERT INTO auth_failures (ip, failures, first_failed_at, last_failed_at)
+		VALUES ($1, 1, $2, $2)
+		ON CONFLICT (ip) DO UPDATE SET
+			failures = CASE WHEN auth_failures.last_failed_at < $3 THEN 1 ELSE auth_failures.failures + 1 END,
+			first_failed_at = CASE WHEN auth_failures.last_failed_at < $3 THEN $2 ELSE auth_failures.first_failed_at END,
+			blocked_until = CASE WHEN auth_failures.last_failed_at < $3 THEN NULL ELSE auth_failures.blocked_until END,
+			banned = auth_failures.banned AND auth_failures.last_failed_at >= $3,
+			last_failed_at = $2
+		RETURNING `+authFailureColumns, ip, at.UTC(), since.UTC()))
+}
+
+func (r *authFailureRepository) Block(ip string, until time.Time, banned bool) (*model.AuthFailure, error) {
+	f, err := scanAuthFailure(r.db.QueryRowContext(context.Background(), `
+		UPDATE auth_failures
+		SET blocked_until = GREATEST(blocked_until, $2), banned = banned OR $3
+		WHERE ip = $1
+		RETURNING `+authFailureColumns, ip, until.UTC(), banned))
+	if err == sql.ErrNoRows {
+		return nil, errors.ErrBanNotFound
+	}
+	return f, err
+}
+
+func (r *authFailureRepository) ListBanned(now time.Time) ([]model.AuthFailure, error) {
+	rows, err := r.db.QueryContext(context.Background(), `
+		SELECT `+authFailureColumns+`
+		FROM auth_failures
+		WHERE banned AND blocked_until > $1
+		ORDER BY last_failed_at DESC`, now.UTC())
+	if err != nil {
+		return nil, err
+	}
+	defer func() { _ = rows.Close() }()
+
+	bans := []model.AuthFailure{}
+	for rows.Next() {
+		f, err := scanAuthFailure(rows)
+		if err != nil {
+			return nil, err
+		}
+		bans = append(bans, *f)
+	}
+	return bans, rows.Err()
+}
+
+func (r *authFailureRepository) LiftBan(ip string, now time.Time) error {
+	res, err := r.db.ExecContext(context.Background(),
+		`DELETE FROM auth_failures WHERE ip = $1 AND banned AND blocked_until > $2`, ip, now.UTC())
+	if err != nil {
+		return err
+	}
+	n, err := res.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if n == 0 {
+		return errors.ErrBanNotFound
+	}
+	return nil
+}
+
+func (r *authFailureRepository) PruneExpired(before time.Time) (int64, error) {
+	res, err := r.db.ExecContext(context.Background(), `
+		DELETE FROM auth_failures
+		WHERE last_failed_at < $1 AND (blocked_until IS NULL OR blocked_until < $1)`, before.UTC())
+	if err != nil {
+		return 0, err
+	}
+	return res.RowsAffected()
+}
@@ -0,0 +1,107 @@
+package repository
+
+import (
+	"cruder/internal/errors"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestAuthFailureRepository_RecordFailure(t *testing.T) {
+	failures := NewAuthFailureRepository(testDB(t))
+	now := time.Now().UTC().Truncate(time.Microsecond)
+	window := time.Hour
+
+	none, err := failures.Get("203.0.113.7")
+	require.NoError(t, err)
+	assert.Nil(t, none)
+
+	_, err = failures.RecordFailure("203.0.113.7", now, now.Add(-window))
+	require.NoError(t, err)
+	f, err := failures.RecordFailure("203.0.113.7", now.Add(time.Minute), now.Add(time.Minute-window))
+	require.NoError(t, err)
+	assert.Equal(t, 2, f.Failures)
+	assert.True(t, f.FirstFailedAt.Equal(now))
+	_, err = failures.Block("203.0.113.7", now.Add(time.Hour), true)
+	require.NoError(t, err)
+
+	// A failure after a quiet window starts over, unblocked
+	later := now.Add(3 * window)
+	f, err = failures.RecordFailure("203.0.113.7", later, later.Add(-window))
+	require.NoError(t, err)
+	assert.Equal(t, 1, f.Failures)
+	assert.True(t, f.FirstFailedAt.Equal(later))
+	assert.Nil(t, f.BlockedUntil)
+	assert.False(t, f.Banned)
+}
+
+func TestAuthFailureRepository_Block(t *testing.T) {
+	failures := NewAuthFailureRepository(testDB(t))
+	now := time.Now().UTC().Truncate(time.Microsecond)
+	_, err := failures.RecordFailure("203.0.113.7", now, now.Add(-time.Hour))
+	require.NoError(t, err)
+
+	_, err = failures.Block("203.0.113.7", now.Add(time.Hour), true)
+	require.NoError(t, err)
+	f, err := failures.Block("203.0.113.7", now.Add(time.Minute), false)
+	require.NoError(t, err)
+	require.NotNil(t, f.BlockedUntil)
+	assert.True(t, f.BlockedUntil.Equal(now.Add(time.Hour)), "a longer block is kept")
+	assert.True(t, f.Banned, "and so is a ban")
+
+	_, err = failures.Block("198.51.100.1", now.Add(time.Hour), true)
+	assert.ErrorIs(t, err, errors.ErrBanNotFound, "no failures recorded")
+}
+
+func TestAuthFailureRepository_Bans(t *testing.T) {
+	failures := NewAuthFailureRepository(testDB(t))
+	now := time.Now().UTC().Truncate(time.Microsecond)
+	for i, ip := range []string{"203.0.113.1", "203.0.113.2", "203.0.113.3"} {
+		_, err := failures.RecordFailure(ip, now.Add(time.Duration(i)*time.Minute), now.Add(-time.Hour))
+		require.NoError(t, err)
+	}
+	_, err := failures.Block("203.0.113.1", now.Add(time.Hour), true)
+	require.NoError(t, err)
+	_, err = failures.Block("203.0.113.2", now.Add(time.Hour), true)
+	require.NoError(t, err)
+	_, err = failures.Block("203.0.113.3", now.Add(time.Hour), false)
+	require.NoError(t, err)
+
+	bans, err := failures.ListBanned(now)
+	require.NoError(t, err)
+	require.Len(t, bans, 2)
+	assert.Equal(t, "203.0.113.2", bans[0].IP, "latest failure first")
+	assert.Equal(t, "203.0.113.1", bans[1].IP)
+	bans, err = failures.ListBanned(now.Add(time.Hour))
+	require.NoError(t, err)
+	assert.Empty(t, bans, "over at the instant")
+
+	require.NoError(t, failures.LiftBan("203.0.113.1", now))
+	gone, err := failures.Get("203.0.113.1")
+	require.NoError(t, err)
+	assert.Nil(t, gone, "the record is forgotten")
+	assert.ErrorIs(t, failures.LiftBan("203.0.113.1", now), errors.ErrBanNotFound)
+	assert.ErrorIs(t, failures.LiftBan("203.0.113.3", now), errors.ErrBanNotFound, "blocked, not banned")
+}
+
+func TestAuthFailureRepository_PruneExpired(t *testing.T) {
+	failures := NewAuthFailureRepository(testDB(t))
+	now := time.Now().UTC().Truncate(time.Microsecond)
+	_, err := failures.RecordFailure("203.0.113.1", now.Add(-2*time.Hour), now.Add(-3*time.Hour))
+	require.NoError(t, err)
+	_, err = failures.RecordFailure("203.0.113.2", now.Add(-2*time.Hour), now.Add(-3*time.Hour))
+	require.NoError(t, err)
+	_, err = failures.Block("203.0.113.2", now.Add(time.Hour), true)
+	require.NoError(t, err)
+	_, err = failures.RecordFailure("203.0.113.3", now, now.Add(-time.Hour))
+	require.NoError(t, err)
+
+	n, err := failures.PruneExpired(now.Add(-time.Hour))
+	require.NoError(t, err)
+	assert.Equal(t, int64(1), n, "only the record whose failures and block are both over")
+	banned, err := failures.Get("203.0.113.2")
+	require.NoError(t, err)
+	assert.NotNil(t, banned)
+}
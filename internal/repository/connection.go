@@ -1,6 +1,7 @@
 package repository
 
 import (
+	"context"
 	"database/sql"
 	"fmt"
 
@@ -28,6 +29,54 @@ func (p *PostgresConnection) Close() error {
 	return nil
 }
 
+// WithAdvisoryLock runs fn while holding a session-level Postgres advisory lock.
+// It blocks until the lock is available, so callers across replicas run fn one at a time.
+// The lock lives on a dedicated connection and is released when fn returns.
+func (p *PostgresConnection) WithAdvisoryLock(ctx context.Context, key int64, fn func() error) (err error) {
+	conn, err := p.db.Conn(ctx)
+	if err != nil {
+		return fmt.Errorf("failed to acquire connection for advisory lock: %w", err)
+	}
+	defer func() { _ = conn.Close() }()
+
+	if _, err := conn.ExecContext(ctx, `SELECT pg_advisory_lock($1)`, key); err != nil {
+		return fmt.Errorf("failed to acquire advisory lock %d: %w", key, err)
+	}
+	defer func() {
+		// Use a fresh context: the caller's may already be cancelled, and the lock must still be released.
+		if _, unlockErr := conn.ExecContext(context.Background(), `SELECT pg_advisory_unlock($1)`, key); unlockErr != nil && err == nil {
+			err = fmt.Errorf("failed to release advisory lock %d: %w", key, unlockErr)
+		}
+	}()
+
+	return fn()
+}
+
+// TryAdvisoryLock runs fn if the session-level Postgres advisory lock key is free, and reports
+// whether it did. Unlike WithAdvisoryLock it never waits: when another replica holds the lock,
+// it returns false at once.
+func (p *PostgresConnection) TryAdvisoryLock(ctx context.Context, key int64, fn func() error) (acquired bool, err error) {
+	conn, err := p.db.Conn(ctx)
+	if err != nil {
+		return false, fmt.Errorf("failed to acquire connection for advisory lock: %w", err)
+	}
+	defer func() { _ = conn.Close() }()
+
+	if err := conn.QueryRowContext(ctx, `SELECT pg_try_advisory_lock($1)`, key).Scan(&acquired); err != nil {
+		return false, fmt.Errorf("failed to try advisory lock %d: %w", key, err)
+	}
+	if !acquired {
+		return false, nil
+	}
+	defer func() {
+		if _, unlockErr := conn.ExecContext(context.Background(), `SELECT pg_advisory_unlock($1)`, key); unlockErr != nil && err == nil {
+			err = fmt.Errorf("failed to release advisory lock %d: %w", key, unlockErr)
+		}
+	}()
+
+	return true, fn()
+}
+
 func NewPostgresConnection(dsn string) (*PostgresConnection, error) {
 	db, err := sql.Open("postgres", dsn)
 	if err != nil {
@@ -0,0 +1,163 @@
+package repository
+
+import (
+	"context"
+	"cruder/internal/errors"
+	"cruder/internal/model"
+	"database/sql"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+type EmailChangeRepository interface {
+	// Create stores c as the user's pending change, replacing any unconfirmed one, and sets c.ID.
+	// It returns ErrEmailExists if c.NewEmail is already pending for another user as of c.CreatedAt.
+	Create(c *model.EmailChange) error
+	// GetPending returns the user's unconfirmed change, expired or not, or ErrEmailChangeNotFound.
+	GetPending(userID uuid.UUID) (*model.EmailChange, error)
+	// IsPending reports whether email awaits confirmation, unexpired at now, for a user of org other than except.
+	IsPending(org uuid.UUID, email string, except uuid.UUID, now time.Time) (bool, error)
+	// GetByConfirmToken returns the unconfirmed change with confirmTokenHash, or ErrEmailChangeTokenInvalid.
+	GetByConfirmToken(confirmTokenHash []byte) (*model.EmailChange, error)
+	// MarkConfirmed sets confirmed_at, once: a change that is already confirmed returns
+	// ErrEmailChangeTokenInvalid, so each confirm token works once, and one that is gone
+	// (reverted or replaced by a newer request) ErrEmailChangeNotFound.
+	MarkConfirmed(id uuid.UUID, at time.Time) error
+	// TakeByRevertToken deletes the change with revertTokenHash and returns it, expired or not,
+	// so each revert token works once. It returns ErrEmailChangeTokenInvalid if there is none.
+	TakeByRevertToken(revertTokenHash []byte) (*model.EmailChange, error)
+	Delete(id uuid.UUID) error
+	// PruneExpired deletes changes whose revert link expired before before and returns how many.
+	PruneExpired(before time.Time) (int64, error)
+}
+
+type emailChangeRepository struct {
+	db *sql.DB
+}
+
+func NewEmailChangeRepository(db *sql.DB) EmailChangeRepository {
+	return &emailChangeRepository{db: db}
+}
+
+const emailChangeColumns = `id, user_id, organization_id, old_email, old_email_verified_at, new_email, confirm_token_hash, revert_token_hash,
+	confirm_expires_at, revert_expires_at, confirmed_at, created_at`
+
+func scanEmailChange(row interface{ Scan(...any) error }) (*model.EmailChange, error) {
+	var c model.EmailChange
+	err := row.Scan(&c.ID, &c.UserID, &c.OrganizationID, &c.OldEmail, &c.OldEmailVerifiedAt, &c.NewEmail, &c.ConfirmTokenHash, &c.RevertTokenHash,
+		&c.ConfirmExpiresAt, &c.RevertExpiresAt, &c.ConfirmedAt, &c.CreatedAt)
+	if err != nil {
+		return nil, err
+	}
+	return &c, nil
+}
+
+func (r *emailChangeRepository) Create(c *model.EmailChange) error {
+	ctx := context.Background()
+	tx, err := r.db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer func() { _ = tx.Rollback() }()
+
+	if _, err := tx.ExecContext(ctx, `DELETE FROM email_changes WHERE user_id = $1 AND confirmed_at IS NULL`, c.UserID); err != nil {
+		return err
+	}
+	var oldVerifiedAt any
+	if c.OldEmailVerifiedAt != nil {
+		oldVerifiedAt = c.OldEmailVerifiedAt.UTC()
+	}
+	err = tx.QueryRowContext(ctx, `
+		INSERT INTO email_changes (user_id, old_email, old_email_verified_at, new_email, confirm_token_hash, revert_token_hash,
+			confirm_expires_at, revert_expires_at, created_at, organization_id)
+		SELECT $1::uuid, $2::varchar, $3::timestamp, $4::varchar, $5::bytea, $6::bytea, $7::timestamp, $8::timestamp, $9::timestamp, $10::uuid
+		WHERE NOT EXISTS (
+			SELECT 1 FROM email_changes
+			WHERE organization_id = $10 AND new_email = $4 AND user_id <> $1 AND confirmed_at IS NULL AND confirm_expires_at > $9
+		)
+		RETURNING id`,
+		c.UserID, c.OldEmail, oldVerifiedAt, c.NewEmail, c.ConfirmTokenHash, c.RevertTokenHash,
+		c.ConfirmExpiresAt.UTC(), c.RevertExpiresAt.UTC(), c.CreatedAt.UTC(), model.OrganizationOrDefault(c.OrganizationID)).Scan(&c.ID)
+	if err == sql.ErrNoRows {
+		return errors.ErrEmailExists
+	}
+	if err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+func (r *emailChangeRepository) GetPending(userID uuid.UUID) (*model.EmailChange, error) {
+	c, err := scanEmailChange(r.db.QueryRowContext(context.Background(),
+		`SELECT `+emailChangeColumns+` FROM email_changes WHERE user_id = $1 AND confirmed_at IS NULL`, userID))
+	if err == sql.ErrNoRows {
+		return nil, errors.ErrEmailChangeNotFound
+	}
+	return c, err
+}
+
+func (r *emailChangeRepository) IsPending(org uuid.UUID, email string, except uuid.UUID, now time.Time) (bool, error) {
+	var pending bool
+	err := r.db.QueryRowContext(context.Background(), `
+		SELECT EXISTS (
+			SELECT 1 FROM email_changes
+			WHERE organization_id = $4 AND new_email = $1 AND user_id <> $2 AND confirmed_at IS NULL AND confirm_expires_at > $3
+		)`, email, except, now.UTC(), model.OrganizationOrDefault(org)).Scan(&pending)
+	return pending, err
+}
+
+func (r *emailChangeRepository) GetByConfirmToken(confirmTokenHash []byte) (*model.EmailChange, error) {
+	c, err := scanEmailChange(r.db.QueryRowContext(context.Background(),
+		`SELECT `+emailChangeColumns+` FROM email_changes WHERE confirm_token_hash = $1 AND confirmed_at IS NULL`, confirmTokenHash))
+	if err == sql.ErrNoRows {
+		return nil, errors.ErrEmailChangeTokenInvalid
+	}
+	return c, err
+}
+
+func (r *emailChangeRepository) MarkConfirmed(id uuid.UUID, at time.Time) error {
+	res, err := r.db.ExecContext(context.Background(),
+		`UPDATE email_changes SET confirmed_at = $2 WHERE id = $1 AND confirmed_at IS NULL`, id, at.UTC())
+	if err != nil {
+		return err
+	}
+	n, err := res.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if n == 0 {
+		var exists bool
+		if err := r.db.QueryRowContext(context.Background(),
+			`SELECT EXISTS (SELECT 1 FROM email_changes WHERE id = $1)`, id).Scan(&exists); err != nil {
+			return err
+		}
+		if !exists {
+			return errors.ErrEmailChangeNotFound
+		}
+		return errors.ErrEmailChangeTokenInvalid
+	}
+	return nil
+}
+
+func (r *emailChangeRepository) TakeByRevertToken(revertTokenHash []byte) (*model.EmailChange, error) {
+	c, err := scanEmailChange(r.db.QueryRowContext(context.Background(),
+		`DELETE FROM email_changes WHERE revert_token_hash = $1 RETURNING `+emailChangeColumns, revertTokenHash))
+	if err == sql.ErrNoRows {
+		return nil, errors.ErrEmailChangeTokenInvalid
+	}
+	return c, err
+}
+
+func (r *emailChangeRepository) Delete(id uuid.UUID) error {
+	_, err := r.db.ExecContext(context.Background(), `DELETE FROM email_changes WHERE id = $1`, id)
+	return err
+}
+
+func (r *emailChangeRepository) PruneExpired(before time.Time) (int64, error) {
+	res, err := r.db.ExecContext(context.Background(), `DELETE FROM email_changes WHERE revert_expires_at < $1`, before.UTC())
+	if err != nil {
+		return 0, err
+	}
+	return res.RowsAffected()
+}
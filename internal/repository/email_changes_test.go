@@ -0,0 +1,134 @@
+package repository
+
+import (
+	"cruder/internal/errors"
+	"cruder/internal/model"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// newTestEmailChange is user's change to newEmail requested at now, with tokens named after both.
+func newTestEmailChange(user *model.User, newEmail string, now time.Time) *model.EmailChange {
+	return &model.EmailChange{
+		UserID: user.ID, OrganizationID: user.OrganizationID, OldEmail: user.Email, NewEmail: newEmail,
+		ConfirmTokenHash: []byte("confirm " + user.Username + " " + newEmail), RevertTokenHash: []byte("revert " + user.Username + " " + newEmail),
+		ConfirmExpiresAt: now.Add(time.Hour), RevertExpiresAt: now.Add(24 * time.Hour), CreatedAt: now,
+	}
+}
+
+func TestEmailChangeRepository_CreateReplacesPending(t *testing.T) {
+	db := testDB(t)
+	changes := NewEmailChangeRepository(db)
+	alice := createTestUser(t, NewUserRepository(db), "alice")
+	now := time.Now().UTC().Truncate(time.Microsecond)
+
+	first := newTestEmailChange(alice, "first@example.com", now)
+	require.NoError(t, changes.Create(first))
+	second := newTestEmailChange(alice, "second@example.com", now)
+	require.NoError(t, changes.Create(second))
+
+	pending, err := changes.GetPending(alice.ID)
+	require.NoError(t, err)
+	assert.Equal(t, second.ID, pending.ID)
+	assert.Equal(t, "second@example.com", pending.NewEmail)
+	_, err = changes.GetByConfirmToken(first.ConfirmTokenHash)
+	assert.ErrorIs(t, err, errors.ErrEmailChangeTokenInvalid, "the older request is gone")
+	assert.ErrorIs(t, changes.MarkConfirmed(first.ID, now), errors.ErrEmailChangeNotFound)
+
+	// A confirmed change stays, for its revert link, and is no longer pending
+	require.NoError(t, changes.MarkConfirmed(second.ID, now))
+	_, err = changes.GetPending(alice.ID)
+	assert.ErrorIs(t, err, errors.ErrEmailChangeNotFound)
+	third := newTestEmailChange(alice, "third@example.com", now)
+	require.NoError(t, changes.Create(third))
+	_, err = changes.TakeByRevertToken(second.RevertTokenHash)
+	assert.NoError(t, err)
+}
+
+func TestEmailChangeRepository_PendingAddressesAreTaken(t *testing.T) {
+	db := testDB(t)
+	changes := NewEmailChangeRepository(db)
+	users := NewUserRepository(db)
+	alice, bob := createTestUser(t, users, "alice"), createTestUser(t, users, "bob")
+	stranger := createTestUser(t, users.InOrganization(otherOrganization(t, db)), "carol")
+	now := time.Now().UTC().Truncate(time.Microsecond)
+	require.NoError(t, changes.Create(newTestEmailChange(alice, "shared@example.com", now)))
+
+	pending, err := changes.IsPending(model.DefaultOrganizationID, "shared@example.com", bob.ID, now)
+	require.NoError(t, err)
+	assert.True(t, pending)
+	pending, err = changes.IsPending(model.DefaultOrganizationID, "shared@example.com", alice.ID, now)
+	require.NoError(t, err)
+	assert.False(t, pending, "not for the user who asked")
+	pending, err = changes.IsPending(stranger.OrganizationID, "shared@example.com", stranger.ID, now)
+	require.NoError(t, err)
+	assert.False(t, pending, "not in another organization")
+	pending, err = changes.IsPending(model.DefaultOrganizationID, "shared@example.com", bob.ID, now.Add(time.Hour))
+	require.NoError(t, err)
+	assert.False(t, pending, "expired at the instant")
+
+	assert.ErrorIs(t, changes.Create(newTestEmailChange(bob, "shared@example.com", now)), errors.ErrEmailExists)
+	assert.NoError(t, changes.Create(newTestEmailChange(stranger, "shared@example.com", now)))
+	assert.NoError(t, changes.Create(newTestEmailChange(bob, "shared@example.com", now.Add(time.Hour))), "once it expired")
+}
+
+func TestEmailChangeRepository_TakeByRevertToken(t *testing.T) {
+	db := testDB(t)
+	changes := NewEmailChangeRepository(db)
+	alice := createTestUser(t, NewUserRepository(db), "alice")
+	now := time.Now().UTC().Truncate(time.Microsecond)
+	verifiedAt := now.Add(-time.Hour)
+	change := newTestEmailChange(alice, "new@example.com", now)
+	change.OldEmailVerifiedAt = &verifiedAt
+	require.NoError(t, changes.Create(change))
+
+	taken, err := changes.TakeByRevertToken(change.RevertTokenHash)
+	require.NoError(t, err)
+	assert.Equal(t, change.ID, taken.ID)
+	require.NotNil(t, taken.OldEmailVerifiedAt)
+	assert.True(t, taken.OldEmailVerifiedAt.Equal(verifiedAt))
+	_, err = changes.TakeByRevertToken(change.RevertTokenHash)
+	assert.ErrorIs(t, err, errors.ErrEmailChangeTokenInvalid, "revert tokens are single-use")
+}
+
+func TestEmailChangeRepository_PruneExpired(t *testing.T) {
+	db := testDB(t)
+	changes := NewEmailChangeRepository(db)
+	users := NewUserRepository(db)
+	alice, bob := createTestUser(t, users, "alice"), createTestUser(t, users, "bob")
+	now := time.Now().UTC().Truncate(time.Microsecond)
+	require.NoError(t, changes.Create(newTestEmailChange(alice, "old@example.com", now.Add(-48*time.Hour))))
+	require.NoError(t, changes.Create(newTestEmailChange(bob, "new@example.com", now)))
+
+	n, err := changes.PruneExpired(now)
+	require.NoError(t, err)
+	assert.Equal(t, int64(1), n, "the change whose revert link expired")
+	_, err = changes.GetPending(bob.ID)
+	assert.NoError(t, err)
+}
+
+func TestEmailChangeRepository_MarkConfirmed(t *testing.T) {
+	db := testDB(t)
+	changes := NewEmailChangeRepository(db)
+	alice := createTestUser(t, NewUserRepository(db), "alice")
+	now := time.Now().UTC().Truncate(time.Microsecond)
+
+	change := &model.EmailChange{
+		UserID: alice.ID, OrganizationID: alice.OrganizationID, OldEmail: alice.Email, NewEmail: "new@example.com",
+		ConfirmTokenHash: []byte("confirm"), RevertTokenHash: []byte("revert"),
+		ConfirmExpiresAt: now.Add(time.Hour), RevertExpiresAt: now.Add(24 * time.Hour), CreatedAt: now,
+	}
+	require.NoError(t, changes.Create(change))
+
+	require.NoError(t, changes.MarkConfirmed(change.ID, now))
+	assert.ErrorIs(t, changes.MarkConfirmed(change.ID, now), errors.ErrEmailChangeTokenInvalid, "confirmed once")
+
+	_, err := changes.TakeByRevertToken([]byte("revert"))
+	require.NoError(t, err)
+	assert.ErrorIs(t, changes.MarkConfirmed(change.ID, now), errors.ErrEmailChangeNotFound, "reverted")
+	assert.ErrorIs(t, changes.MarkConfirmed(uuid.New(), now), errors.ErrEmailChangeNotFound)
+}
@@ -0,0 +1,60 @@
+package repository
+
+import (
+	"context"
+	"cruder/internal/errors"
+	"cruder/internal/model"
+	"database/sql"
+	"time"
+)
+
+type EmailVerificationRepository interface {
+	// Create stores v, replacing any token the user still had.
+	Create(v *model.EmailVerification) error
+	// Consume deletes the token with tokenHash and returns it, expired or not, so each token works once.
+	// It returns ErrVerificationTokenInvalid if there is none.
+	Consume(tokenHash []byte) (*model.EmailVerification, error)
+	// PruneExpired deletes tokens that expired before before and returns how many.
+	PruneExpired(before time.Time) (int64, error)
+}
+
+type emailVerificationRepository struct {
+	db *sql.DB
+}
+
+func NewEmailVerificationRepository(db *sql.DB) EmailVerificationRepository {
+	return &emailVerificationRepository{db: db}
+}
+
+func (r *emailVerificationRepository) Create(v *model.EmailVerification) error {
+	_, err := r.db.ExecContext(context.Background(), `
+		INSERT INTO email_verifications (token_hash, user_id, organization_id, email, expires_at, created_at) VALUES ($1, $2, $3, $4, $5, $6)
+		ON CONFLICT (user_id) DO UPDATE SET
+			token_hash = EXCLUDED.token_hash, email = EXCLUDED.email,
+			expires_at = EXCLUDED.expires_at, created_at = EXCLUDED.created_at`,
+		v.TokenHash, v.UserID, model.OrganizationOrDefault(v.OrganizationID), v.Email, v.ExpiresAt.UTC(), v.CreatedAt.UTC())
+	return err
+}
+
+func (r *emailVerificationRepository) Consume(tokenHash []byte) (*model.EmailVerification, error) {
+	var v model.EmailVerification
+	err := r.db.QueryRowContext(context.Background(), `
+		DELETE FROM email_verifications WHERE token_hash = $1
+		RETURNING token_hash, user_id, organization_id, email, expires_at, created_at`, tokenHash).
+		Scan(&v.TokenHash, &v.UserID, &v.OrganizationID, &v.Email, &v.ExpiresAt, &v.CreatedAt)
+	if err == sql.ErrNoRows {
+		return nil, errors.ErrVerificationTokenInvalid
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &v, nil
+}
+
+func (r *emailVerificationRepository) PruneExpired(before time.Time) (int64, error) {
+	res, err := r.db.ExecContext(context.Background(), `DELETE FROM email_verifications WHERE expires_at < $1`, before.UTC())
+	if err != nil {
+		return 0, err
+	}
+	return res.RowsAffected()
+}
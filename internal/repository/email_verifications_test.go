@@ -0,0 +1,53 @@
+package repository
+
+import (
+	"cruder/internal/errors"
+	"cruder/internal/model"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestEmailVerificationRepository_Consume(t *testing.T) {
+	db := testDB(t)
+	verifications := NewEmailVerificationRepository(db)
+	alice := createTestUser(t, NewUserRepository(db), "alice")
+	now := time.Now().UTC().Truncate(time.Microsecond)
+	verification := func(hash string) *model.EmailVerification {
+		return &model.EmailVerification{TokenHash: []byte(hash), UserID: alice.ID, OrganizationID: alice.OrganizationID,
+			Email: alice.Email, ExpiresAt: now.Add(time.Hour), CreatedAt: now}
+	}
+
+	require.NoError(t, verifications.Create(verification("first")))
+	require.NoError(t, verifications.Create(verification("second")))
+
+	_, err := verifications.Consume([]byte("first"))
+	assert.ErrorIs(t, err, errors.ErrVerificationTokenInvalid, "replaced by the newer token")
+	v, err := verifications.Consume([]byte("second"))
+	require.NoError(t, err)
+	assert.Equal(t, alice.ID, v.UserID)
+	assert.Equal(t, alice.Email, v.Email)
+	assert.True(t, v.ExpiresAt.Equal(now.Add(time.Hour)))
+	_, err = verifications.Consume([]byte("second"))
+	assert.ErrorIs(t, err, errors.ErrVerificationTokenInvalid, "tokens are single-use")
+}
+
+func TestEmailVerificationRepository_PruneExpired(t *testing.T) {
+	db := testDB(t)
+	verifications := NewEmailVerificationRepository(db)
+	users := NewUserRepository(db)
+	now := time.Now().UTC().Truncate(time.Microsecond)
+	for i, username := range []string{"alice", "bob"} {
+		u := createTestUser(t, users, username)
+		require.NoError(t, verifications.Create(&model.EmailVerification{TokenHash: []byte(username), UserID: u.ID,
+			OrganizationID: u.OrganizationID, Email: u.Email, ExpiresAt: now.Add(time.Duration(i) * time.Hour), CreatedAt: now}))
+	}
+
+	n, err := verifications.PruneExpired(now.Add(time.Minute))
+	require.NoError(t, err)
+	assert.Equal(t, int64(1), n)
+	_, err = verifications.Consume([]byte("bob"))
+	assert.NoError(t, err)
+}
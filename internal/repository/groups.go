@@ -0,0 +1,463 @@
+package repository
+
+import (
+	"context"
+	"cruder/internal/errors"
+	"cruder/internal/model"
+	"database/sql"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/lib/pq"
+
+	stdErrors "errors"
+)
+
+// GroupRepository stores groups and their members. Like UserRepository it sees one organization,
+// the default one unless InOrganization picks another.
+type GroupRepository interface {
+	// InOrganization returns a repository that sees the groups of org instead.
+	InOrganization(org uuid.UUID) GroupRepository
+
+	// Create stores a new group, or returns ErrGroupExists if its name is taken.
+	Create(req *model.CreateGroupRequest) (*model.Group, error)
+	// GetByID returns ErrGroupNotFound if the organization has no such group.
+	GetByID(id uuid.UUID) (*model.Group, error)
+	// List returns the organization's groups, by name.
+	List() ([]model.Group, error)
+	Update(id uuid.UUID, req *model.UpdateGroupRequest) (*model.Group, error)
+	// Delete removes a group along with its memberships, both its members and where it is one,
+	// and returns those as removals by actor at at. The removals from the groups it was a member
+	// of are recorded in their history; its own history goes with it.
+	Delete(id uuid.UUID, actor string, at time.Time) ([]model.GroupMembershipChange, error)
+
+	// AddMember adds the member change describes to its group and records change in the
+	// membership history, in one transaction. The member must be in the organization, which is
+	// the caller's to check. It returns ErrGroupMemberExists if it is a member already, and
+	// ErrGroupCycle if a group member contains the group.
+	AddMember(change *model.GroupMembershipChange) error
+	// RemoveMember is AddMember's counterpart; it returns ErrGroupMemberNotFound if the member
+	// is not a direct member of the group.
+	RemoveMember(change *model.GroupMembershipChange) error
+	// RemoveUser removes the user from every group they are a direct member of, records the
+	// removals by actor at at in the history and returns them.
+	RemoveUser(userID uuid.UUID, actor string, at time.Time) ([]model.GroupMembershipChange, error)
+	// Members returns the group's direct members: groups, then users, each by name.
+	Members(id uuid.UUID) ([]model.GroupMember, error)
+	// EffectiveUserIDs returns the users that are members of the group or of any group nested in it.
+	EffectiveUserIDs(id uuid.UUID) ([]uuid.UUID, error)
+	// GroupsOfUser returns the groups the user is a direct member of, or with effective also
+	// those that contain them through nesting, by name.
+	GroupsOfUser(userID uuid.UUID, effective bool) ([]model.Group, error)
+	// History returns the group's membership changes, newest first.
+	History(id uuid.UUID) ([]model.GroupMembershipChange, error)
+}
+
+type groupRepository struct {
+	db  *sql.DB
+	org uuid.UUID
+}
+
+func NewGroupRepository(db *sql.DB) GroupRepository {
+	return &groupRepository{db: db, org: model.DefaultOrganizationID}
+}
+
+func (r *groupRepository) InOrganization(org uuid.UUID) GroupRepository {
+	return &groupRepository{db: r.db, org: model.OrganizationOrDefault(org)}
+}
+
+// groupColumns are the columns of a group, with the groups table aliased as g.
+const groupColumns = `g.id, g.organization_id, g.name, g.description, g.created_at, g.updated_at`
+
+func scanGroup(row rowScanner) (*model.Group, error) {
+	var g model.Group
+	if err := row.Scan(&g.ID, &g.OrganizationID, &g.Name, &g.Description, &g.CreatedAt, &g.UpdatedAt); err != nil {
+		if err == sql.ErrNoRows {
+			return nil, errors.ErrGroupNotFound
+		}
+		return nil, err
+	}
+	return &g, nil
+}
+
+func scanGroups(rows *sql.Rows, err error) ([]model.Group, error) {
+	if err != nil {
+		return nil, err
+	}
+	defer func() { _ = rows.Close() }()
+
+	groups := []model.Group{}
+	for rows.Next() {
+		g, err := scanGroup(rows)
+		if err != nil {
+			return nil, err
+		}
+		groups = append(groups, *g)
+	}
+	return groups, rows.Err()
+}
+
+// groupWriteError maps a taken name to ErrGroupExists.
+func groupWriteError(err error) error {
+	var pqErr *pq.Error
+	if stdErrors.As(err, &pqErr) && pqErr.Code == "23505" {
+		return errors.ErrGroupExists
+	}
+	return err
+}
+
+func (r *groupRepository) Create(req *model.CreateGroupRequest) (*model.Group, error) {
+	g, err := scanGroup(r.db.QueryRowContext(context.Background(), `
+		INSERT INTO groups AS g (organization_id, name, description) VALUES ($1, $2, $3)
+		RETURNING `+groupColumns, r.org, req.Name, req.Description))
+	if err != nil {
+		return nil, groupWriteError(err)
+	}
+	return g, nil
+}
+
+func (r *groupRepository) GetByID(id uuid.UUID) (*model.Group, error) {
+	return scanGroup(r.db.QueryRowContext(context.Background(),
+		`SELECT `+groupColumns+` FROM groups g WHERE g.id = $1 AND g.organization_id = $2`, id, r.org))
+}
+
+func (r *groupRepository) List() ([]model.Group, error) {
+	return scanGroups(r.db.QueryContext(context.Background(),
+		`SELECT `+groupColumns+` FROM groups g WHERE g.organization_id = $1 ORDER BY g.name`, r.org))
+}
+
+func (r *groupRepository) Update(id uuid.UUID, req *model.UpdateGroupRequest) (*model.Group, error) {
+	updates := []string{}
+	args := []interface{}{}
+	if req.Name != nil {
+		args = append(args, *req.Name)
+		updates = append(updates, fmt.Sprintf("name = $%d", len(args)))
+	}
+	if req.Description != nil {
+		args = append(args, *req.Description)
+		updates = append(updates, fmt.Sprintf("description = $%d", len(args)))
+	}
+	if len(updates) == 0 {
+		return r.GetByID(id)
+	}
+	updates = append(updates, "updated_at = CURRENT_TIMESTAMP")
+	args = append(args, id, r.org)
+
+	// Safe: Using parameterized queries - values go through args array
+	query := fmt.Sprintf(`UPDATE groups AS g SET %s WHERE g.id = $%d AND g.organization_id = $%d RETURNING %s`,
+		strings.Join(updates, ", "), len(args)-1, len(args), groupColumns) // #nosec G201
+	g, err := scanGroup(r.db.QueryRowContext(context.Background(), query, args...))
+	if err != nil {
+		return nil, groupWriteError(err)
+	}
+	return g, nil
+}
+
+func (r *groupRepository) Delete(id uuid.UUID, actor string, at time.Time) ([]model.GroupMembershipChange, error) {
+	ctx := context.Background()
+	tx, err := r.db.BeginTx(ctx, nil)
+	if err != nil {
+		return nil, err
+	}
+	defer func() { _ = tx.Rollback() }()
+
+	// Locking the group holds off new memberships, whose foreign keys need it, until it is gone
+	var locked uuid.UUID
+	err = tx.QueryRowContext(ctx, `SELECT id FROM groups WHERE id = $1 AND organization_id = $2 FOR UPDATE`, id, r.org).Scan(&locked)
+	if err == sql.ErrNoRows {
+		return nil, errors.ErrGroupNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	// The memberships the cascade drops: the group's members, then the groups it is a member of
+	rows, err := tx.QueryContext(ctx, `
+		SELECT m.group_id, 'group', c.id, c.name FROM group_members m JOIN groups c ON c.id = m.member_group_id
+		WHERE m.group_id = $1
+		UNION ALL
+		SELECT m.group_id, 'user', u.id, u.username FROM group_members m JOIN users u ON u.id = m.user_id
+		WHERE m.group_id = $1
+		UNION ALL
+		SELECT m.group_id, 'group', g.id, g.name FROM group_members m JOIN groups g ON g.id = m.member_group_id
+		WHERE m.member_group_id = $1
+	`, id)
+	if err != nil {
+		return nil, err
+	}
+	changes, err := scanRemovals(rows, actor, at)
+	if err != nil {
+		return nil, err
+	}
+	for i := range changes {
+		if changes[i].GroupID != id {
+			if err := recordMembershipChange(ctx, tx, &changes[i]); err != nil {
+				return nil, err
+			}
+		}
+	}
+
+	if _, err := tx.ExecContext(ctx, `DELETE FROM groups WHERE id = $1`, id); err != nil {
+		return nil, err
+	}
+	if err := tx.Commit(); err != nil {
+		return nil, err
+	}
+	return changes, nil
+}
+
+// scanRemovals reads rows of group ID, member type, member ID and member name as removals by
+// actor at at, and closes rows.
+func scanRemovals(rows *sql.Rows, actor string, at time.Time) ([]model.GroupMembershipChange, error) {
+	defer func() { _ = rows.Close() }()
+
+	changes := []model.GroupMembershipChange{}
+	for rows.Next() {
+		c := model.GroupMembershipChange{Action: model.MembershipRemoved, Actor: actor, ChangedAt: at}
+		if err := rows.Scan(&c.GroupID, &c.MemberType, &c.MemberID, &c.MemberName); err != nil {
+			return nil, err
+		}
+		changes = append(changes, c)
+	}
+	return changes, rows.Err()
+}
+
+// memberColumn is the group_members column that holds a member of memberType.
+func memberColumn(memberType string) string {
+	if memberType == model.MemberTypeGroup {
+		return "member_group_id"
+	}
+	return "user_id"
+}
+
+// recordMembershipChange adds change to the membership history within tx.
+func recordMembershipChange(ctx context.Context, tx *sql.Tx, change *model.GroupMembershipChange) error {
+	_, err := tx.ExecContext(ctx, `
+		INSERT INTO group_membership_history (group_id, action, member_type, member_id, member_name, actor, changed_at)
+		VALUES ($1, $2, $3, $4, $5, $6, $7)
+	`, change.GroupID, change.Action, change.MemberType, change.MemberID, change.MemberName, change.Actor, change.ChangedAt)
+	return err
+}
+
+func (r *groupRepository) AddMember(change *model.GroupMembershipChange) error {
+	ctx := context.Background()
+	tx, err := r.db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer func() { _ = tx.Rollback() }()
+
+	if change.MemberType == model.MemberTypeGroup {
+		// Two concurrent nestings could each pass the cycle check and together close a cycle, so
+		// nestings within an organization take turns
+		if _, err := tx.ExecContext(ctx, `SELECT pg_advisory_xact_lock(hashtext('group_members:' || $1::text))`, r.org); err != nil {
+			return err
+		}
+		var cycle bool
+		err := tx.QueryRowContext(ctx, `
+			WITH RECURSIVE nested(id) AS (
+				SELECT $1::uuid
+				UNION
+				SELECT m.member_group_id FROM group_members m JOIN nested n ON m.group_id = n.id
+				WHERE m.member_group_id IS NOT NULL
+			)
+			SELECT EXISTS (SELECT 1 FROM nested WHERE id = $2)
+		`, change.MemberID, change.GroupID).Scan(&cycle)
+		if err != nil {
+			return err
+		}
+		if cycle {
+			return errors.ErrGroupCycle
+		}
+	}
+
+	// Safe: the column name comes from memberColumn, values go through parameters
+	result, err := tx.ExecContext(ctx, fmt.Sprintf(`
+		INSERT INTO group_members (group_id, %s, added_by, added_at)
+		SELECT g.id, $2::uuid, $3::varchar, $4::timestamp FROM groups g WHERE g.id = $1 AND g.organization_id = $5
+	`, memberColumn(change.MemberType)), change.GroupID, change.MemberID, change.Actor, change.ChangedAt, r.org) // #nosec G201
+	if err != nil {
+		var pqErr *pq.Error
+		if stdErrors.As(err, &pqErr) && pqErr.Code == "23505" {
+			return errors.ErrGroupMemberExists
+		}
+		return err
+	}
+	n, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if n == 0 {
+		return errors.ErrGroupNotFound
+	}
+
+	if err := recordMembershipChange(ctx, tx, change); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+func (r *groupRepository) RemoveMember(change *model.GroupMembershipChange) error {
+	ctx := context.Background()
+	tx, err := r.db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer func() { _ = tx.Rollback() }()
+
+	// Safe: the column name comes from memberColumn, values go through parameters
+	result, err := tx.ExecContext(ctx, fmt.Sprintf(`
+		DELETE FROM group_members m USING groups g
+		WHERE m.group_id = g.id AND g.id = $1 AND g.organization_id = $2 AND m.%s = $3
+	`, memberColumn(change.MemberType)), change.GroupID, r.org, change.MemberID) // #nosec G201
+	if err != nil {
+		return err
+	}
+	n, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if n == 0 {
+		return errors.ErrGroupMemberNotFound
+	}
+
+	if err := recordMembershipChange(ctx, tx, change); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+func (r *groupRepository) RemoveUser(userID uuid.UUID, actor string, at time.Time) ([]model.GroupMembershipChange, error) {
+	ctx := context.Background()
+	tx, err := r.db.BeginTx(ctx, nil)
+	if err != nil {
+		return nil, err
+	}
+	defer func() { _ = tx.Rollback() }()
+
+	rows, err := tx.QueryContext(ctx, `
+		DELETE FROM group_members m USING groups g, users u
+		WHERE m.group_id = g.id AND g.organization_id = $2 AND m.user_id = $1 AND u.id = m.user_id
+		RETURNING m.group_id, 'user', u.id, u.username
+	`, userID, r.org)
+	if err != nil {
+		return nil, err
+	}
+	changes, err := scanRemovals(rows, actor, at)
+	if err != nil {
+		return nil, err
+	}
+	for i := range changes {
+		if err := recordMembershipChange(ctx, tx, &changes[i]); err != nil {
+			return nil, err
+		}
+	}
+	if err := tx.Commit(); err != nil {
+		return nil, err
+	}
+	return changes, nil
+}
+
+func (r *groupRepository) Members(id uuid.UUID) ([]model.GroupMember, error) {
+	rows, err := r.db.QueryContext(context.Background(), `
+		SELECT 'group', c.id, c.name, m.added_by, m.added_at
+		FROM group_members m
+		JOIN groups g ON g.id = m.group_id
+		JOIN groups c ON c.id = m.member_group_id
+		WHERE m.group_id = $1 AND g.organization_id = $2
+		UNION ALL
+		SELECT 'user', u.id, u.username, m.added_by, m.added_at
+		FROM group_members m
+		JOIN groups g ON g.id = m.group_id
+		JOIN users u ON u.id = m.user_id
+		WHERE m.group_id = $1 AND g.organization_id = $2
+		ORDER BY 1, 3
+	`, id, r.org)
+	if err != nil {
+		return nil, err
+	}
+	defer func() { _ = rows.Close() }()
+
+	members := []model.GroupMember{}
+	for rows.Next() {
+		var m model.GroupMember
+		if err := rows.Scan(&m.Type, &m.ID, &m.Name, &m.AddedBy, &m.AddedAt); err != nil {
+			return nil, err
+		}
+		members = append(members, m)
+	}
+	return members, rows.Err()
+}
+
+func (r *groupRepository) EffectiveUserIDs(id uuid.UUID) ([]uuid.UUID, error) {
+	rows, err := r.db.QueryContext(context.Background(), `
+		WITH RECURSIVE nested(id) AS (
+			SELECT g.id FROM groups g WHERE g.id = $1 AND g.organization_id = $2
+			UNION
+			SELECT m.member_group_id FROM group_members m JOIN nested n ON m.group_id = n.id
+			WHERE m.member_group_id IS NOT NULL
+		)
+		SELECT DISTINCT m.user_id FROM group_members m JOIN nested n ON m.group_id = n.id
+		WHERE m.user_id IS NOT NULL
+	`, id, r.org)
+	if err != nil {
+		return nil, err
+	}
+	defer func() { _ = rows.Close() }()
+
+	ids := []uuid.UUID{}
+	for rows.Next() {
+		var id uuid.UUID
+		if err := rows.Scan(&id); err != nil {
+			return nil, err
+		}
+		ids = append(ids, id)
+	}
+	return ids, rows.Err()
+}
+
+func (r *groupRepository) GroupsOfUser(userID uuid.UUID, effective bool) ([]model.Group, error) {
+	if !effective {
+		return scanGroups(r.db.QueryContext(context.Background(), `
+			SELECT `+groupColumns+` FROM groups g JOIN group_members m ON m.group_id = g.id
+			WHERE m.user_id = $1 AND g.organization_id = $2
+			ORDER BY g.name
+		`, userID, r.org))
+	}
+	return scanGroups(r.db.QueryContext(context.Background(), `
+		WITH RECURSIVE containing(id) AS (
+			SELECT group_id FROM group_members WHERE user_id = $1
+			UNION
+			SELECT m.group_id FROM group_members m JOIN containing c ON m.member_group_id = c.id
+		)
+		SELECT `+groupColumns+` FROM groups g JOIN containing c ON c.id = g.id
+		WHERE g.organization_id = $2
+		ORDER BY g.name
+	`, userID, r.org))
+}
+
+func (r *groupRepository) History(id uuid.UUID) ([]model.GroupMembershipChange, error) {
+	rows, err := r.db.QueryContext(context.Background(), `
+		SELECT h.group_id, h.action, h.member_type, h.member_id, h.member_name, h.actor, h.changed_at
+		FROM group_membership_history h
+		JOIN groups g ON g.id = h.group_id
+		WHERE h.group_id = $1 AND g.organization_id = $2
+		ORDER BY h.changed_at DESC, h.id
+	`, id, r.org)
+	if err != nil {
+		return nil, err
+	}
+	defer func() { _ = rows.Close() }()
+
+	changes := []model.GroupMembershipChange{}
+	for rows.Next() {
+		var c model.GroupMembershipChange
+		if err := rows.Scan(&c.GroupID, &c.Action, &c.MemberType, &c.MemberID, &c.MemberName, &c.Actor, &c.ChangedAt); err != nil {
+			return nil, err
+		}
+		changes = append(changes, c)
+	}
+	return changes, rows.Err()
+}
@@ -0,0 +1,216 @@
+package repository
+
+import (
+	"cruder/internal/errors"
+	"cruder/internal/model"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func createTestGroup(t *testing.T, groups GroupRepository, name string) *model.Group {
+	t.Helper()
+	group, err := groups.Create(&model.CreateGroupRequest{Name: name})
+	require.NoError(t, err)
+	return group
+}
+
+func addTestMember(t *testing.T, groups GroupRepository, groupID uuid.UUID, memberType string, memberID uuid.UUID, at time.Time) {
+	t.Helper()
+	require.NoError(t, groups.AddMember(&model.GroupMembershipChange{
+		GroupID: groupID, Action: model.MembershipAdded, MemberType: memberType, MemberID: memberID, Actor: "admin", ChangedAt: at,
+	}))
+}
+
+func TestGroupRepository_DeleteReturnsMemberships(t *testing.T) {
+	db := testDB(t)
+	groups := NewGroupRepository(db)
+	now := time.Now().UTC().Truncate(time.Microsecond)
+	alice := createTestUser(t, NewUserRepository(db), "alice")
+	parent := createTestGroup(t, groups, "parent")
+	team := createTestGroup(t, groups, "team")
+	child := createTestGroup(t, groups, "child")
+	addTestMember(t, groups, parent.ID, model.MemberTypeGroup, team.ID, now)
+	addTestMember(t, groups, team.ID, model.MemberTypeGroup, child.ID, now)
+	addTestMember(t, groups, team.ID, model.MemberTypeUser, alice.ID, now)
+
+	later := now.Add(time.Minute)
+	removed, err := groups.Delete(team.ID, "admin", later)
+	require.NoError(t, err)
+	removal := func(groupID uuid.UUID, memberType string, memberID uuid.UUID, name string) model.GroupMembershipChange {
+		return model.GroupMembershipChange{GroupID: groupID, Action: model.MembershipRemoved, MemberType: memberType,
+			MemberID: memberID, MemberName: name, Actor: "admin", ChangedAt: later}
+	}
+	assert.ElementsMatch(t, []model.GroupMembershipChange{
+		removal(team.ID, model.MemberTypeGroup, child.ID, "child"),
+		removal(team.ID, model.MemberTypeUser, alice.ID, "alice"),
+		removal(parent.ID, model.MemberTypeGroup, team.ID, "team"),
+	}, removed)
+
+	history, err := groups.History(parent.ID)
+	require.NoError(t, err)
+	require.Len(t, history, 2)
+	assert.True(t, later.Equal(history[0].ChangedAt), "newest first")
+	history[0].ChangedAt = later
+	assert.Equal(t, removal(parent.ID, model.MemberTypeGroup, team.ID, "team"), history[0])
+
+	_, err = groups.GetByID(team.ID)
+	assert.ErrorIs(t, err, errors.ErrGroupNotFound)
+	_, err = groups.Delete(team.ID, "admin", later)
+	assert.ErrorIs(t, err, errors.ErrGroupNotFound)
+}
+
+func TestGroupRepository_RemoveUser(t *testing.T) {
+	db := testDB(t)
+	groups := NewGroupRepository(db)
+	users := NewUserRepository(db)
+	now := time.Now().UTC().Truncate(time.Microsecond)
+	alice := createTestUser(t, users, "alice")
+	bob := createTestUser(t, users, "bob")
+	team := createTestGroup(t, groups, "team")
+	admins := createTestGroup(t, groups, "admins")
+	addTestMember(t, groups, team.ID, model.MemberTypeUser, alice.ID, now)
+	addTestMember(t, groups, admins.ID, model.MemberTypeUser, alice.ID, now)
+	addTestMember(t, groups, team.ID, model.MemberTypeUser, bob.ID, now)
+
+	removed, err := groups.RemoveUser(alice.ID, model.ActorSystem, now)
+	require.NoError(t, err)
+	require.Len(t, removed, 2)
+	for _, change := range removed {
+		assert.Equal(t, alice.ID, change.MemberID)
+		assert.Equal(t, "alice", change.MemberName)
+		assert.Equal(t, model.MembershipRemoved, change.Action)
+	}
+
+	members, err := groups.Members(team.ID)
+	require.NoError(t, err)
+	require.Len(t, members, 1, "other members stay")
+	history, err := groups.History(admins.ID)
+	require.NoError(t, err)
+	require.Len(t, history, 2)
+	assert.Equal(t, model.MembershipRemoved, history[0].Action)
+
+	removed, err = groups.RemoveUser(alice.ID, model.ActorSystem, now)
+	require.NoError(t, err)
+	assert.Empty(t, removed)
+}
+
+func TestGroupRepository_Names(t *testing.T) {
+	db := testDB(t)
+	groups := NewGroupRepository(db)
+	team := createTestGroup(t, groups, "team")
+	sales := createTestGroup(t, groups, "sales")
+
+	_, err := groups.Create(&model.CreateGroupRequest{Name: "team"})
+	assert.ErrorIs(t, err, errors.ErrGroupExists)
+	name := "team"
+	_, err = groups.Update(sales.ID, &model.UpdateGroupRequest{Name: &name})
+	assert.ErrorIs(t, err, errors.ErrGroupExists)
+
+	// Names are per organization, and so are lookups
+	other := groups.InOrganization(otherOrganization(t, db))
+	theirs := createTestGroup(t, other, "team")
+	_, err = groups.GetByID(theirs.ID)
+	assert.ErrorIs(t, err, errors.ErrGroupNotFound)
+	listed, err := groups.List()
+	require.NoError(t, err)
+	require.Len(t, listed, 2)
+	assert.Equal(t, sales.ID, listed[0].ID, "by name")
+	assert.Equal(t, team.ID, listed[1].ID)
+}
+
+func TestGroupRepository_NestedMembership(t *testing.T) {
+	db := testDB(t)
+	groups := NewGroupRepository(db)
+	users := NewUserRepository(db)
+	now := time.Now().UTC().Truncate(time.Microsecond)
+	alice, bob, carol := createTestUser(t, users, "alice"), createTestUser(t, users, "bob"), createTestUser(t, users, "carol")
+	company, engineering, backend := createTestGroup(t, groups, "company"), createTestGroup(t, groups, "engineering"), createTestGroup(t, groups, "backend")
+
+	// company > engineering > backend, with bob in two of them
+	addTestMember(t, groups, company.ID, model.MemberTypeGroup, engineering.ID, now)
+	addTestMember(t, groups, engineering.ID, model.MemberTypeGroup, backend.ID, now)
+	addTestMember(t, groups, company.ID, model.MemberTypeUser, carol.ID, now)
+	addTestMember(t, groups, engineering.ID, model.MemberTypeUser, bob.ID, now)
+	addTestMember(t, groups, backend.ID, model.MemberTypeUser, alice.ID, now)
+	addTestMember(t, groups, backend.ID, model.MemberTypeUser, bob.ID, now)
+
+	ids, err := groups.EffectiveUserIDs(company.ID)
+	require.NoError(t, err)
+	assert.ElementsMatch(t, []uuid.UUID{alice.ID, bob.ID, carol.ID}, ids, "each user once")
+	ids, err = groups.EffectiveUserIDs(engineering.ID)
+	require.NoError(t, err)
+	assert.ElementsMatch(t, []uuid.UUID{alice.ID, bob.ID}, ids)
+
+	members, err := groups.Members(engineering.ID)
+	require.NoError(t, err)
+	require.Len(t, members, 2)
+	assert.Equal(t, model.GroupMember{Type: model.MemberTypeGroup, ID: backend.ID, Name: "backend", AddedBy: "admin", AddedAt: members[0].AddedAt}, members[0],
+		"groups, then users")
+	assert.True(t, now.Equal(members[0].AddedAt))
+	assert.Equal(t, model.MemberTypeUser, members[1].Type)
+	assert.Equal(t, "bob", members[1].Name)
+
+	direct, err := groups.GroupsOfUser(alice.ID, false)
+	require.NoError(t, err)
+	require.Len(t, direct, 1)
+	assert.Equal(t, backend.ID, direct[0].ID)
+	all, err := groups.GroupsOfUser(alice.ID, true)
+	require.NoError(t, err)
+	require.Len(t, all, 3)
+	assert.Equal(t, []string{"backend", "company", "engineering"}, []string{all[0].Name, all[1].Name, all[2].Name}, "by name")
+}
+
+func TestGroupRepository_AddMemberRejectsCyclesAndDuplicates(t *testing.T) {
+	db := testDB(t)
+	groups := NewGroupRepository(db)
+	now := time.Now().UTC().Truncate(time.Microsecond)
+	a, b, c := createTestGroup(t, groups, "a"), createTestGroup(t, groups, "b"), createTestGroup(t, groups, "c")
+	addTestMember(t, groups, a.ID, model.MemberTypeGroup, b.ID, now)
+	addTestMember(t, groups, b.ID, model.MemberTypeGroup, c.ID, now)
+	nest := func(parent, child uuid.UUID) error {
+		return groups.AddMember(&model.GroupMembershipChange{GroupID: parent, Action: model.MembershipAdded, MemberType: model.MemberTypeGroup,
+			MemberID: child, Actor: "admin", ChangedAt: now})
+	}
+
+	assert.ErrorIs(t, nest(b.ID, a.ID), errors.ErrGroupCycle)
+	assert.ErrorIs(t, nest(c.ID, a.ID), errors.ErrGroupCycle, "a contains c through b")
+	assert.ErrorIs(t, nest(a.ID, b.ID), errors.ErrGroupMemberExists)
+	assert.NoError(t, nest(a.ID, c.ID), "a diamond is not a cycle")
+	assert.ErrorIs(t, nest(uuid.New(), c.ID), errors.ErrGroupNotFound)
+	assert.ErrorIs(t, groups.InOrganization(otherOrganization(t, db)).AddMember(&model.GroupMembershipChange{GroupID: a.ID,
+		Action: model.MembershipAdded, MemberType: model.MemberTypeGroup, MemberID: uuid.New(), Actor: "admin", ChangedAt: now}),
+		errors.ErrGroupNotFound, "another organization's group")
+
+	history, err := groups.History(a.ID)
+	require.NoError(t, err)
+	assert.Len(t, history, 2, "rejected changes are not recorded")
+}
+
+func TestGroupRepository_RemoveMember(t *testing.T) {
+	db := testDB(t)
+	groups := NewGroupRepository(db)
+	now := time.Now().UTC().Truncate(time.Microsecond)
+	alice := createTestUser(t, NewUserRepository(db), "alice")
+	team := createTestGroup(t, groups, "team")
+	addTestMember(t, groups, team.ID, model.MemberTypeUser, alice.ID, now)
+	removal := &model.GroupMembershipChange{GroupID: team.ID, Action: model.MembershipRemoved, MemberType: model.MemberTypeUser,
+		MemberID: alice.ID, MemberName: "alice", Actor: model.ActorSystem, ChangedAt: now.Add(time.Minute)}
+
+	require.NoError(t, groups.RemoveMember(removal))
+	assert.ErrorIs(t, groups.RemoveMember(removal), errors.ErrGroupMemberNotFound)
+	removal.MemberType = model.MemberTypeGroup
+	assert.ErrorIs(t, groups.RemoveMember(removal), errors.ErrGroupMemberNotFound, "alice is no group")
+
+	history, err := groups.History(team.ID)
+	require.NoError(t, err)
+	require.Len(t, history, 2)
+	assert.Equal(t, model.MembershipRemoved, history[0].Action)
+	assert.Equal(t, model.ActorSystem, history[0].Actor)
+	assert.Equal(t, model.MembershipAdded, history[1].Action)
+	assert.Equal(t, "admin", history[1].Actor)
+	assert.Equal(t, alice.ID, history[1].MemberID)
+}
@@ -0,0 +1,202 @@
+package repository
+
+import (
+	"context"
+	"cruder/internal/errors"
+	"cruder/internal/model"
+	"database/sql"
+	"time"
+
+	"github.com/google/uuid"
+
+	stdErrors "errors"
+)
+
+// InvitationRepository stores invitations. Like UserRepository it sees one organization, the
+// default one unless InOrganization picks another; only GetByToken looks across organizations.
+// An invitation is open while it is neither redeemed nor revoked, and pending while it is open
+// and unexpired.
+type InvitationRepository interface {
+	// InOrganization returns a repository that sees the invitations of org instead.
+	InOrganization(org uuid.UUID) InvitationRepository
+
+	// Create stores inv in the organization and sets its ID. It returns ErrInvitationExists if
+	// inv.Email has an invitation pending as of inv.CreatedAt.
+	Create(inv *model.Invitation) error
+	// GetByID returns ErrInvitationNotFound if the organization has no such invitation.
+	GetByID(id uuid.UUID) (*model.Invitation, error)
+	// GetByToken returns the invitation with tokenHash, in whichever organization and whatever its
+	// status, or ErrInvitationTokenInvalid.
+	GetByToken(tokenHash []byte) (*model.Invitation, error)
+	// ListPending returns the invitations pending at now, newest first.
+	ListPending(now time.Time) ([]model.Invitation, error)
+	// Renew gives an open invitation a new token and expiry, as of sentAt. It returns
+	// ErrInvitationNotPending if the invitation is no longer open, and ErrInvitationExists if its
+	// address has been invited again since and that invitation is still pending.
+	Renew(id uuid.UUID, tokenHash []byte, expiresAt, sentAt time.Time) (*model.Invitation, error)
+	// Revoke closes an open invitation, or returns ErrInvitationNotPending.
+	Revoke(id uuid.UUID, at time.Time) (*model.Invitation, error)
+	// Claim marks a pending invitation redeemed at at, once: concurrent redemptions of one token
+	// see ErrInvitationTokenInvalid, as do invitations that are closed or expired by then.
+	Claim(id uuid.UUID, at time.Time) error
+	// Release undoes Claim for an invitation whose account could not be created.
+	Release(id uuid.UUID) error
+	// SetUser records the user created by redeeming a claimed invitation.
+	SetUser(id, userID uuid.UUID) error
+}
+
+type invitationRepository struct {
+	db  *sql.DB
+	org uuid.UUID
+}
+
+func NewInvitationRepository(db *sql.DB) InvitationRepository {
+	return &invitationRepository{db: db, org: model.DefaultOrganizationID}
+}
+
+func (r *invitationRepository) InOrganization(org uuid.UUID) InvitationRepository {
+	return &invitationRepository{db: r.db, org: model.OrganizationOrDefault(org)}
+}
+
+const invitationColumns = `id, organization_id, email, token_hash, invited_by, expires_at, sent_at, created_at,
+	redeemed_at, user_id, revoked_at`
+
+// openInvitation is the condition for an invitation that is neither redeemed nor revoked.
+const openInvitation = `redeemed_at IS NULL AND revoked_at IS NULL`
+
+func scanInvitation(row rowScanner) (*model.Invitation, error) {
+	var inv model.Invitation
+	err := row.Scan(&inv.ID, &inv.OrganizationID, &inv.Email, &inv.TokenHash, &inv.InvitedBy, &inv.ExpiresAt, &inv.SentAt, &inv.CreatedAt,
+		&inv.RedeemedAt, &inv.UserID, &inv.RevokedAt)
+	if err == sql.ErrNoRows {
+		return nil, errors.ErrInvitationNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &inv, nil
+}
+
+func (r *invitationRepository) Create(inv *model.Invitation) error {
+	inv.OrganizationID = r.org
+	err := r.db.QueryRowContext(context.Background(), `
+		INSERT INTO invitations (organization_id, email, token_hash, invited_by, expires_at, sent_at, created_at)
+		SELECT $1::uuid, $2::varchar, $3::bytea, $4::varchar, $5::timestamp, $6::timestamp, $6::timestamp
+		WHERE NOT EXISTS (
+			SELECT 1 FROM invitations
+			WHERE organization_id = $1 AND email = $2 AND `+openInvitation+` AND expires_at > $6
+		)
+		RETURNING id`,
+		r.org, inv.Email, inv.TokenHash, inv.InvitedBy, inv.ExpiresAt.UTC(), inv.CreatedAt.UTC()).Scan(&inv.ID)
+	if err == sql.ErrNoRows {
+		return errors.ErrInvitationExists
+	}
+	if err != nil {
+		return err
+	}
+	inv.SentAt = inv.CreatedAt
+	return nil
+}
+
+func (r *invitationRepository) GetByID(id uuid.UUID) (*model.Invitation, error) {
+	return scanInvitation(r.db.QueryRowContext(context.Background(),
+		`SELECT `+invitationColumns+` FROM invitations WHERE id = $1 AND organization_id = $2`, id, r.org))
+}
+
+func (r *invitationRepository) GetByToken(tokenHash []byte) (*model.Invitation, error) {
+	inv, err := scanInvitation(r.db.QueryRowContext(context.Background(),
+		`SELECT `+invitationColumns+` FROM invitations WHERE token_hash = $1`, tokenHash))
+	if stdErrors.Is(err, errors.ErrInvitationNotFound) {
+		return nil, errors.ErrInvitationTokenInvalid
+	}
+	return inv, err
+}
+
+func (r *invitationRepository) ListPending(now time.Time) ([]model.Invitation, error) {
+	rows, err := r.db.QueryContext(context.Background(), `
+		SELECT `+invitationColumns+` FROM invitations
+		WHERE organization_id = $1 AND `+openInvitation+` AND expires_at > $2
+		ORDER BY created_at DESC, id`, r.org, now.UTC())
+	if err != nil {
+		return nil, err
+	}
+	defer func() { _ = rows.Close() }()
+
+	invitations := []model.Invitation{}
+	for rows.Next() {
+		inv, err := scanInvitation(rows)
+		if err != nil {
+			return nil, err
+		}
+		invitations = append(invitations, *inv)
+	}
+	return invitations, rows.Err()
+}
+
+// whyNotOpen explains why an update of the open invitation id matched no row.
+func (r *invitationRepository) whyNotOpen(id uuid.UUID) error {
+	inv, err := r.GetByID(id)
+	if err != nil {
+		return err
+	}
+	if inv.RedeemedAt != nil || inv.RevokedAt != nil {
+		return errors.ErrInvitationNotPending
+	}
+	return errors.ErrInvitationExists
+}
+
+func (r *invitationRepository) Renew(id uuid.UUID, tokenHash []byte, expiresAt, sentAt time.Time) (*model.Invitation, error) {
+	inv, err := scanInvitation(r.db.QueryRowContext(context.Background(), `
+		UPDATE invitations i SET token_hash = $3, expires_at = $4, sent_at = $5
+		WHERE i.id = $1 AND i.organization_id = $2 AND i.redeemed_at IS NULL AND i.revoked_at IS NULL
+			AND NOT EXISTS (
+				SELECT 1 FROM invitations o
+				WHERE o.organization_id = i.organization_id AND o.email = i.email AND o.id <> i.id
+					AND o.redeemed_at IS NULL AND o.revoked_at IS NULL AND o.expires_at > $5
+			)
+		RETURNING `+invitationColumns, id, r.org, tokenHash, expiresAt.UTC(), sentAt.UTC()))
+	if stdErrors.Is(err, errors.ErrInvitationNotFound) {
+		return nil, r.whyNotOpen(id)
+	}
+	return inv, err
+}
+
+func (r *invitationRepository) Revoke(id uuid.UUID, at time.Time) (*model.Invitation, error) {
+	inv, err := scanInvitation(r.db.QueryRowContext(context.Background(), `
+		UPDATE invitations SET revoked_at = $3
+		WHERE id = $1 AND organization_id = $2 AND `+openInvitation+`
+		RETURNING `+invitationColumns, id, r.org, at.UTC()))
+	if stdErrors.Is(err, errors.ErrInvitationNotFound) {
+		return nil, r.whyNotOpen(id)
+	}
+	return inv, err
+}
+
+func (r *invitationRepository) Claim(id uuid.UUID, at time.Time) error {
+	res, err := r.db.ExecContext(context.Background(), `
+		UPDATE invitations SET redeemed_at = $3
+		WHERE id = $1 AND organization_id = $2 AND `+openInvitation+` AND expires_at > $3`, id, r.org, at.UTC())
+	if err != nil {
+		return err
+	}
+	n, err := res.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if n == 0 {
+		return errors.ErrInvitationTokenInvalid
+	}
+	return nil
+}
+
+func (r *invitationRepository) Release(id uuid.UUID) error {
+	_, err := r.db.ExecContext(context.Background(),
+		`UPDATE invitations SET redeemed_at = NULL WHERE id = $1 AND organization_id = $2 AND user_id IS NULL`, id, r.org)
+	return err
+}
+
+func (r *invitationRepository) SetUser(id, userID uuid.UUID) error {
+	_, err := r.db.ExecContext(context.Background(),
+		`UPDATE invitations SET user_id = $3 WHERE id = $1 AND organization_id = $2`, id, r.org, userID)
+	return err
+}
@@ -0,0 +1,181 @@
+package repository
+
+import (
+	"cruder/internal/errors"
+	"cruder/internal/model"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func createTestInvitation(t *testing.T, invitations InvitationRepository, email string, now time.Time) *model.Invitation {
+	t.Helper()
+	inv := &model.Invitation{
+		Email: email, TokenHash: []byte("token-" + uuid.NewString()), InvitedBy: "admin",
+		ExpiresAt: now.Add(time.Hour), CreatedAt: now,
+	}
+	require.NoError(t, invitations.Create(inv))
+	return inv
+}
+
+func TestInvitationRepository_CreateOncePerAddress(t *testing.T) {
+	db := testDB(t)
+	invitations := NewInvitationRepository(db)
+	now := time.Now().UTC().Truncate(time.Microsecond)
+	first := createTestInvitation(t, invitations, "new@example.com", now)
+	assert.Equal(t, model.DefaultOrganizationID, first.OrganizationID)
+	assert.True(t, first.SentAt.Equal(now))
+
+	again := &model.Invitation{Email: "new@example.com", TokenHash: []byte("again"), InvitedBy: "admin", ExpiresAt: now.Add(2 * time.Hour), CreatedAt: now}
+	assert.ErrorIs(t, invitations.Create(again), errors.ErrInvitationExists, "pending")
+	createTestInvitation(t, invitations.InOrganization(otherOrganization(t, db)), "new@example.com", now)
+
+	// Once the first one expires the address can be invited again
+	again.CreatedAt = first.ExpiresAt
+	require.NoError(t, invitations.Create(again))
+	_, err := invitations.Renew(first.ID, []byte("renewed"), now.Add(3*time.Hour), first.ExpiresAt)
+	assert.ErrorIs(t, err, errors.ErrInvitationExists, "the newer invitation is the one to renew")
+}
+
+func TestInvitationRepository_Renew(t *testing.T) {
+	invitations := NewInvitationRepository(testDB(t))
+	now := time.Now().UTC().Truncate(time.Microsecond)
+	inv := createTestInvitation(t, invitations, "new@example.com", now)
+
+	// Renewing revives an expired invitation with a new token
+	later := inv.ExpiresAt.Add(time.Hour)
+	renewed, err := invitations.Renew(inv.ID, []byte("renewed"), later.Add(time.Hour), later)
+	require.NoError(t, err)
+	assert.True(t, renewed.SentAt.Equal(later))
+	assert.True(t, renewed.CreatedAt.Equal(now))
+	assert.Equal(t, model.InvitationPending, renewed.StatusAt(later))
+	_, err = invitations.GetByToken(inv.TokenHash)
+	assert.ErrorIs(t, err, errors.ErrInvitationTokenInvalid, "the old token no longer works")
+	byToken, err := invitations.GetByToken([]byte("renewed"))
+	require.NoError(t, err)
+	assert.Equal(t, inv.ID, byToken.ID)
+}
+
+func TestInvitationRepository_Revoke(t *testing.T) {
+	db := testDB(t)
+	invitations := NewInvitationRepository(db)
+	now := time.Now().UTC().Truncate(time.Microsecond)
+	inv := createTestInvitation(t, invitations, "new@example.com", now)
+
+	revoked, err := invitations.Revoke(inv.ID, now)
+	require.NoError(t, err)
+	require.NotNil(t, revoked.RevokedAt)
+	assert.True(t, revoked.RevokedAt.Equal(now))
+	_, err = invitations.Revoke(inv.ID, now)
+	assert.ErrorIs(t, err, errors.ErrInvitationNotPending)
+	_, err = invitations.Renew(inv.ID, []byte("renewed"), now.Add(time.Hour), now)
+	assert.ErrorIs(t, err, errors.ErrInvitationNotPending)
+	_, err = invitations.InOrganization(otherOrganization(t, db)).Revoke(inv.ID, now)
+	assert.ErrorIs(t, err, errors.ErrInvitationNotFound)
+
+	// A revoked invitation does not hold the address
+	createTestInvitation(t, invitations, "new@example.com", now)
+}
+
+func TestInvitationRepository_ListPending(t *testing.T) {
+	db := testDB(t)
+	invitations := NewInvitationRepository(db)
+	now := time.Now().UTC().Truncate(time.Microsecond)
+	older := createTestInvitation(t, invitations, "older@example.com", now.Add(-time.Minute))
+	newer := createTestInvitation(t, invitations, "newer@example.com", now)
+	revoked := createTestInvitation(t, invitations, "revoked@example.com", now)
+	_, err := invitations.Revoke(revoked.ID, now)
+	require.NoError(t, err)
+	createTestInvitation(t, invitations.InOrganization(otherOrganization(t, db)), "other@example.com", now)
+
+	pending, err := invitations.ListPending(now)
+	require.NoError(t, err)
+	require.Len(t, pending, 2)
+	assert.Equal(t, newer.ID, pending[0].ID, "newest first")
+	assert.Equal(t, older.ID, pending[1].ID)
+
+	pending, err = invitations.ListPending(older.ExpiresAt)
+	require.NoError(t, err)
+	require.Len(t, pending, 1, "expired at the instant")
+	assert.Equal(t, newer.ID, pending[0].ID)
+}
+
+func TestInvitationRepository_ClaimAndRelease(t *testing.T) {
+	db := testDB(t)
+	invitations := NewInvitationRepository(db)
+	now := time.Now().UTC().Truncate(time.Microsecond)
+	inv := createTestInvitation(t, invitations, "new@example.com", now)
+
+	require.NoError(t, invitations.Claim(inv.ID, now))
+	assert.ErrorIs(t, invitations.Claim(inv.ID, now), errors.ErrInvitationTokenInvalid, "claimed once")
+
+	// A released invitation can be redeemed again
+	require.NoError(t, invitations.Release(inv.ID))
+	got, err := invitations.GetByID(inv.ID)
+	require.NoError(t, err)
+	assert.Nil(t, got.RedeemedAt)
+	require.NoError(t, invitations.Claim(inv.ID, now))
+
+	// but not once the account exists
+	user := createTestUser(t, NewUserRepository(db), "newcomer")
+	require.NoError(t, invitations.SetUser(inv.ID, user.ID))
+	require.NoError(t, invitations.Release(inv.ID))
+	got, err = invitations.GetByID(inv.ID)
+	require.NoError(t, err)
+	require.NotNil(t, got.RedeemedAt)
+	assert.Equal(t, &user.ID, got.UserID)
+}
+
+func TestInvitationRepository_ClaimClosedOrExpired(t *testing.T) {
+	db := testDB(t)
+	invitations := NewInvitationRepository(db)
+	now := time.Now().UTC().Truncate(time.Microsecond)
+
+	expiring := createTestInvitation(t, invitations, "expiring@example.com", now)
+	assert.ErrorIs(t, invitations.Claim(expiring.ID, expiring.ExpiresAt), errors.ErrInvitationTokenInvalid, "expired at the instant")
+
+	revoked := createTestInvitation(t, invitations, "revoked@example.com", now)
+	_, err := invitations.Revoke(revoked.ID, now)
+	require.NoError(t, err)
+	assert.ErrorIs(t, invitations.Claim(revoked.ID, now), errors.ErrInvitationTokenInvalid)
+	require.NoError(t, invitations.Release(revoked.ID))
+	got, err := invitations.GetByID(revoked.ID)
+	require.NoError(t, err)
+	assert.NotNil(t, got.RevokedAt, "releasing does not reopen a revoked invitation")
+
+	other := createTestInvitation(t, invitations.InOrganization(otherOrganization(t, db)), "other@example.com", now)
+	assert.ErrorIs(t, invitations.Claim(other.ID, now), errors.ErrInvitationTokenInvalid, "other organizations' invitations are not seen")
+}
+
+func TestInvitationRepository_ConcurrentClaims(t *testing.T) {
+	invitations := NewInvitationRepository(testDB(t))
+	now := time.Now().UTC().Truncate(time.Microsecond)
+	inv := createTestInvitation(t, invitations, "race@example.com", now)
+
+	const claimers = 8
+	errs := make(chan error, claimers)
+	var wg sync.WaitGroup
+	for range claimers {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			errs <- invitations.Claim(inv.ID, now)
+		}()
+	}
+	wg.Wait()
+	close(errs)
+
+	claimed := 0
+	for err := range errs {
+		if err == nil {
+			claimed++
+			continue
+		}
+		assert.ErrorIs(t, err, errors.ErrInvitationTokenInvalid)
+	}
+	assert.Equal(t, 1, claimed)
+}
@@ -0,0 +1,246 @@
+package repository
+
+import (
+	"context"
+	"cruder/internal/errors"
+	"cruder/internal/model"
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"time"
+
+	"github.com/google/uuid"
+
+	stdErrors "errors"
+)
+
+type JobRepository interface {
+	Create(kind string, params json.RawMessage, input []byte) (*model.Job, error)
+	GetByID(id uuid.UUID) (*model.Job, error)
+	// RequestCancel cancels a queued job outright and flags a running one for its worker to stop.
+	// A finished job is returned unchanged with ErrJobFinished.
+	RequestCancel(id uuid.UUID) (*model.Job, error)
+	// StreamResult calls fn with each stored result chunk, in order.
+	StreamResult(id uuid.UUID, fn func(chunk []byte) error) error
+
+	// Claim locks the oldest queued job, or a running one whose heartbeat is older than staleAfter
+	// (its worker died), for worker. Its previous partial result is discarded. Nil when there is none.
+	Claim(worker string, staleAfter time.Duration) (*model.Job, error)
+	// The calls below succeed only while worker holds the job, and return ErrJobLost otherwise.
+
+	// Heartbeat records progress and reports whether cancellation was requested.
+	Heartbeat(id uuid.UUID, worker string, progress model.JobProgress) (cancelRequested bool, err error)
+	AppendResult(id uuid.UUID, worker string, seq int, chunk []byte) error
+	Finish(id uuid.UUID, worker string, status model.JobStatus, progress model.JobProgress, resultType, errMsg string) error
+	// Release puts a running job back in the queue, e.g. when its worker shuts down.
+	Release(id uuid.UUID, worker string) error
+
+	// PurgeFinished deletes jobs, with their results, that finished before before and returns how many.
+	PurgeFinished(before time.Time) (int64, error)
+}
+
+type jobRepository struct {
+	db *sql.DB
+}
+
+func NewJobRepository(db *sql.DB) JobRepository {
+	return &jobRepository{db: db}
+}
+
+const jobColumns = `id, kind, status, params, progress_done, progress_total, COALESCE(error, ''), COALESCE(result_type, ''),
+	attempts, cancel_requested, created_at, started_at, finished_at, updated_at`
+
+type rowScanner interface {
+	Scan(dest ...any) error
+}
+
+// scanJob reads jobColumns, followed by extra targets if the query selects more.
+func scanJob(row rowScanner, extra ...any) (*model.Job, error) {
+	var (
+		j                    model.Job
+		params               []byte
+		total                sql.NullInt64
+		started, finished    sql.NullTime
+		createdAt, updatedAt sql.NullTime
+	)
+	dest := append([]any{&j.ID, &j.Kind, &j.Status, &params, &j.Progress.Done, &total, &j.Error, &j.ResultType,
+		&j.Attempts, &j.CancelRequested, &createdAt, &started, &finished, &updatedAt}, extra...)
+	if err := row.Scan(dest...); err != nil {
+		if err == sql.ErrNoRows {
+			return nil, errors.ErrJobNotFound
+		}
+		return nil, err
+	}
+	j.Params = params
+	if total.Valid {
+		j.Progress.Total = &total.Int64
+	}
+	if started.Valid {
+		j.StartedAt = &started.Time
+	}
+	if finished.Valid {
+		j.FinishedAt = &finished.Time
+	}
+	j.CreatedAt, j.UpdatedAt = createdAt.Time, updatedAt.Time
+	return &j, nil
+}
+
+func (r *jobRepository) Create(kind string, params json.RawMessage, input []byte) (*model.Job, error) {
+	if params == nil {
+		params = json.RawMessage(`{}`)
+	}
+	return scanJob(r.db.QueryRowContext(context.Background(),
+		`INSERT INTO jobs (kind, params, input) VALUES ($1, $2, $3) RETURNING `+jobColumns,
+		kind, []byte(params), input))
+}
+
+func (r *jobRepository) GetByID(id uuid.UUID) (*model.Job, error) {
+	return scanJob(r.db.QueryRowContext(context.Background(), `SELECT `+jobColumns+` FROM jobs WHERE id = $1`, id))
+}
+
+func (r *jobRepository) RequestCancel(id uuid.UUID) (*model.Job, error) {
+	job, err := scanJob(r.db.QueryRowContext(context.Background(), `
+		UPDATE jobs SET
+			status = CASE WHEN status = 'queued' THEN 'cancelled' ELSE status END,
+			finished_at = CASE WHEN status = 'queued' THEN CURRENT_TIMESTAMP ELSE finished_at END,
+			cancel_requested = TRUE,
+			updated_at = CURRENT_TIMESTAMP
+		WHERE id = $1 AND status IN ('queued', 'running')
+		RETURNING `+jobColumns, id))
+	if stdErrors.Is(err, errors.ErrJobNotFound) {
+		// Either there is no such job or it already finished
+		job, err = r.GetByID(id)
+		if err != nil {
+			return nil, err
+		}
+		return job, errors.ErrJobFinished
+	}
+	return job, err
+}
+
+func (r *jobRepository) StreamResult(id uuid.UUID, fn func(chunk []byte) error) error {
+	rows, err := r.db.QueryContext(context.Background(), `SELECT data FROM job_results WHERE job_id = $1 ORDER BY seq`, id)
+	if err != nil {
+		return err
+	}
+	defer func() { _ = rows.Close() }()
+
+	var chunk []byte
+	for rows.Next() {
+		if err := rows.Scan(&chunk); err != nil {
+			return err
+		}
+		if err := fn(chunk); err != nil {
+			return err
+		}
+	}
+	return rows.Err()
+}
+
+func (r *jobRepository) Claim(worker string, staleAfter time.Duration) (*model.Job, error) {
+	ctx := context.Background()
+	tx, err := r.db.BeginTx(ctx, nil)
+	if err != nil {
+		return nil, err
+	}
+	defer func() { _ = tx.Rollback() }()
+
+	// SKIP LOCKED lets every worker on every replica poll at once without blocking on, or double-claiming, a row
+	var input []byte
+	job, err := scanJob(tx.QueryRowContext(ctx, `
+		UPDATE jobs SET
+			status = 'running',
+			locked_by = $1,
+			heartbeat_at = CURRENT_TIMESTAMP,
+			started_at = COALESCE(started_at, CURRENT_TIMESTAMP),
+			attempts = attempts + 1,
+			updated_at = CURRENT_TIMESTAMP
+		WHERE id = (
+			SELECT id FROM jobs
+			WHERE status = 'queued'
+			   OR (status = 'running' AND heartbeat_at < CURRENT_TIMESTAMP - $2::float8 * INTERVAL '1 millisecond')
+			ORDER BY created_at
+			LIMIT 1
+			FOR UPDATE SKIP LOCKED
+		)
+		RETURNING `+jobColumns+`, input`, worker, staleAfter.Milliseconds()), &input)
+	if stdErrors.Is(err, errors.ErrJobNotFound) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	job.Input = input
+
+	if _, err := tx.ExecContext(ctx, `DELETE FROM job_results WHERE job_id = $1`, job.ID); err != nil {
+		return nil, err
+	}
+	if err := tx.Commit(); err != nil {
+		return nil, err
+	}
+	return job, nil
+}
+
+// owned turns "no row updated" into ErrJobLost.
+func owned(res sql.Result, err error) error {
+	if err != nil {
+		return err
+	}
+	n, err := res.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if n == 0 {
+		return errors.ErrJobLost
+	}
+	return nil
+}
+
+func (r *jobRepository) Heartbeat(id uuid.UUID, worker string, progress model.JobProgress) (bool, error) {
+	var cancelRequested bool
+	err := r.db.QueryRowContext(context.Background(), `
+		UPDATE jobs SET heartbeat_at = CURRENT_TIMESTAMP, progress_done = $3, progress_total = $4, updated_at = CURRENT_TIMESTAMP
+		WHERE id = $1 AND locked_by = $2 AND status = 'running'
+		RETURNING cancel_requested`, id, worker, progress.Done, progress.Total).Scan(&cancelRequested)
+	if err == sql.ErrNoRows {
+		return false, errors.ErrJobLost
+	}
+	return cancelRequested, err
+}
+
+func (r *jobRepository) AppendResult(id uuid.UUID, worker string, seq int, chunk []byte) error {
+	return owned(r.db.ExecContext(context.Background(), `
+		INSERT INTO job_results (job_id, seq, data)
+		SELECT $1, $3, $4 WHERE EXISTS (SELECT 1 FROM jobs WHERE id = $1 AND locked_by = $2 AND status = 'running')`,
+		id, worker, seq, chunk))
+}
+
+func (r *jobRepository) Finish(id uuid.UUID, worker string, status model.JobStatus, progress model.JobProgress, resultType, errMsg string) error {
+	if !status.Finished() {
+		return fmt.Errorf("%w: %q is not a final job status", errors.ErrInvalidInput, status)
+	}
+	return owned(r.db.ExecContext(context.Background(), `
+		UPDATE jobs SET
+			status = $3, progress_done = $4, progress_total = $5,
+			result_type = NULLIF($6, ''), error = NULLIF($7, ''),
+			locked_by = NULL, finished_at = CURRENT_TIMESTAMP, updated_at = CURRENT_TIMESTAMP
+		WHERE id = $1 AND locked_by = $2 AND status = 'running'`,
+		id, worker, status, progress.Done, progress.Total, resultType, errMsg))
+}
+
+func (r *jobRepository) Release(id uuid.UUID, worker string) error {
+	// Not the job's fault, so the attempt does not count
+	return owned(r.db.ExecContext(context.Background(), `
+		UPDATE jobs SET status = 'queued', locked_by = NULL, heartbeat_at = NULL,
+			attempts = GREATEST(attempts - 1, 0), updated_at = CURRENT_TIMESTAMP
+		WHERE id = $1 AND locked_by = $2 AND status = 'running'`, id, worker))
+}
+
+func (r *jobRepository) PurgeFinished(before time.Time) (int64, error) {
+	res, err := r.db.ExecContext(context.Background(), `
+		DELETE FROM jobs WHERE status IN ('succeeded', 'failed', 'cancelled') AND finished_at < $1`, before.UTC())
+	if err != nil {
+		return 0, err
+	}
+	return res.RowsAffected()
+}
@@ -0,0 +1,86 @@
+package repository
+
+import (
+	"context"
+	"cruder/internal/model"
+	"fmt"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestJobRepository_ClaimSkipsLockedJobs(t *testing.T) {
+	db := testDB(t)
+	jobs := NewJobRepository(db)
+	first, err := jobs.Create("export", nil, nil)
+	require.NoError(t, err)
+	second, err := jobs.Create("export", nil, []byte("input"))
+	require.NoError(t, err)
+	_, err = db.Exec(`UPDATE jobs SET created_at = created_at - INTERVAL '1 minute' WHERE id = $1`, first.ID)
+	require.NoError(t, err)
+
+	// Another worker's transaction holds the oldest job
+	ctx := context.Background()
+	tx, err := db.BeginTx(ctx, nil)
+	require.NoError(t, err)
+	defer func() { _ = tx.Rollback() }()
+	var locked uuid.UUID
+	require.NoError(t, tx.QueryRowContext(ctx, `SELECT id FROM jobs WHERE id = $1 FOR UPDATE`, first.ID).Scan(&locked))
+
+	claimed, err := jobs.Claim("worker-1", time.Minute)
+	require.NoError(t, err)
+	require.NotNil(t, claimed, "claims the next job instead of waiting")
+	assert.Equal(t, second.ID, claimed.ID)
+	assert.Equal(t, model.JobRunning, claimed.Status)
+	assert.Equal(t, 1, claimed.Attempts)
+	assert.Equal(t, []byte("input"), claimed.Input)
+
+	claimed, err = jobs.Claim("worker-2", time.Minute)
+	require.NoError(t, err)
+	assert.Nil(t, claimed, "nothing left that is not locked")
+
+	require.NoError(t, tx.Rollback())
+	claimed, err = jobs.Claim("worker-2", time.Minute)
+	require.NoError(t, err)
+	require.NotNil(t, claimed)
+	assert.Equal(t, first.ID, claimed.ID)
+}
+
+func TestJobRepository_ConcurrentClaims(t *testing.T) {
+	jobs := NewJobRepository(testDB(t))
+	const workers = 8
+	for range workers {
+		_, err := jobs.Create("export", nil, nil)
+		require.NoError(t, err)
+	}
+
+	claimed := make(chan uuid.UUID, workers)
+	var wg sync.WaitGroup
+	for i := range workers {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			job, err := jobs.Claim(fmt.Sprintf("worker-%d", i), time.Minute)
+			if assert.NoError(t, err) && assert.NotNil(t, job) {
+				claimed <- job.ID
+			}
+		}()
+	}
+	wg.Wait()
+	close(claimed)
+
+	seen := map[uuid.UUID]bool{}
+	for id := range claimed {
+		assert.False(t, seen[id], "job %s claimed twice", id)
+		seen[id] = true
+	}
+	assert.Len(t, seen, workers)
+
+	job, err := jobs.Claim("late", time.Minute)
+	require.NoError(t, err)
+	assert.Nil(t, job)
+}
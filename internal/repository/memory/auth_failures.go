@@ -0,0 +1,114 @@
+package memory
+
+import (
+	"cruder/internal/errors"
+	"cruder/internal/model"
+	"cruder/internal/repository"
+	"sort"
+	"sync"
+	"time"
+)
+
+// AuthFailureRepository is an in-memory repository.AuthFailureRepository.
+type AuthFailureRepository struct {
+	mu       sync.Mutex
+	failures map[string]*model.AuthFailure
+}
+
+var _ repository.AuthFailureRepository = (*AuthFailureRepository)(nil)
+
+func NewAuthFailureRepository() *AuthFailureRepository {
+	return &AuthFailureRepository{failures: make(map[string]*model.AuthFailure)}
+}
+
+// copyOf returns a copy of f that shares nothing with the stored record.
+func copyOf(f *model.AuthFailure) *model.AuthFailure {
+	out := *f
+	if f.BlockedUntil != nil {
+		until := *f.BlockedUntil
+		out.BlockedUntil = &until
+	}
+	return &out
+}
+
+func (r *AuthFailureRepository) Get(ip string) (*model.AuthFailure, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	f, ok := r.failures[ip]
+	if !ok {
+		return nil, nil
+	}
+	return copyOf(f), nil
+}
+
+func (r *AuthFailureRepository) RecordFailure(ip string, at, since time.Time) (*model.AuthFailure, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	at = at.UTC()
+	f, ok := r.failures[ip]
+	if !ok || f.LastFailedAt.Before(since) {
+		f = &model.AuthFailure{IP: ip, FirstFailedAt: at}
+		r.failures[ip] = f
+	}
+	f.Failures++
+	f.LastFailedAt = at
+	return copyOf(f), nil
+}
+
+func (r *AuthFailureRepository) Block(ip string, until time.Time, banned bool) (*model.AuthFailure, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	f, ok := r.failures[ip]
+	if !ok {
+		return nil, errors.ErrBanNotFound
+	}
+	until = until.UTC()
+	if f.BlockedUntil == nil || until.After(*f.BlockedUntil) {
+		f.BlockedUntil = &until
+	}
+	f.Banned = f.Banned || banned
+	return copyOf(f), nil
+}
+
+func (r *AuthFailureRepository) ListBanned(now time.Time) ([]model.AuthFailure, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	bans := []model.AuthFailure{}
+	for _, f := range r.failures {
+		if f.Banned && f.BlockedAt(now) {
+			bans = append(bans, *copyOf(f))
+		}
+	}
+	sort.Slice(bans, func(i, j int) bool { return bans[i].LastFailedAt.After(bans[j].LastFailedAt) })
+	return bans, nil
+}
+
+func (r *AuthFailureRepository) LiftBan(ip string, now time.Time) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	f, ok := r.failures[ip]
+	if !ok || !f.Banned || !f.BlockedAt(now) {
+		return errors.ErrBanNotFound
+	}
+	delete(r.failures, ip)
+	return nil
+}
+
+func (r *AuthFailureRepository) PruneExpired(before time.Time) (int64, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	var n int64
+	for ip, f := range r.failures {
+		if f.LastFailedAt.Before(before) && (f.BlockedUntil == nil || f.BlockedUntil.Before(before)) {
+			delete(r.failures, ip)
+			n++
+		}
+	}
+	return n, nil
+}
@@ -0,0 +1,131 @@
+package memory
+
+import (
+	"cruder/internal/errors"
+	"cruder/internal/model"
+	"cruder/internal/repository"
+	"sync"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// EmailChangeRepository is an in-memory repository.EmailChangeRepository.
+// Unlike the table, it does not drop the changes of deleted users.
+type EmailChangeRepository struct {
+	mu      sync.Mutex
+	changes map[uuid.UUID]model.EmailChange
+}
+
+var _ repository.EmailChangeRepository = (*EmailChangeRepository)(nil)
+
+func NewEmailChangeRepository() *EmailChangeRepository {
+	return &EmailChangeRepository{changes: make(map[uuid.UUID]model.EmailChange)}
+}
+
+func (r *EmailChangeRepository) Create(c *model.EmailChange) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	c.OrganizationID = model.OrganizationOrDefault(c.OrganizationID)
+	for id, other := range r.changes {
+		if other.ConfirmedAt != nil {
+			continue
+		}
+		if other.UserID == c.UserID {
+			delete(r.changes, id)
+		} else if other.NewEmail == c.NewEmail && other.OrganizationID == c.OrganizationID && other.ConfirmExpiresAt.After(c.CreatedAt) {
+			return errors.ErrEmailExists
+		}
+	}
+	c.ID = uuid.New()
+	r.changes[c.ID] = *c
+	return nil
+}
+
+func (r *EmailChangeRepository) GetPending(userID uuid.UUID) (*model.EmailChange, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	for _, c := range r.changes {
+		if c.UserID == userID && c.ConfirmedAt == nil {
+			return &c, nil
+		}
+	}
+	return nil, errors.ErrEmailChangeNotFound
+}
+
+func (r *EmailChangeRepository) IsPending(org uuid.UUID, email string, except uuid.UUID, now time.Time) (bool, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	for _, c := range r.changes {
+		if c.NewEmail == email && c.OrganizationID == model.OrganizationOrDefault(org) && c.UserID != except && c.ConfirmedAt == nil && c.ConfirmExpiresAt.After(now) {
+			return true, nil
+		}
+	}
+	return false, nil
+}
+
+func (r *EmailChangeRepository) GetByConfirmToken(confirmTokenHash []byte) (*model.EmailChange, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	for _, c := range r.changes {
+		if string(c.ConfirmTokenHash) == string(confirmTokenHash) && c.ConfirmedAt == nil {
+			return &c, nil
+		}
+	}
+	return nil, errors.ErrEmailChangeTokenInvalid
+}
+
+func (r *EmailChangeRepository) MarkConfirmed(id uuid.UUID, at time.Time) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	c, ok := r.changes[id]
+	if !ok {
+		return errors.ErrEmailChangeNotFound
+	}
+	if c.ConfirmedAt != nil {
+		return errors.ErrEmailChangeTokenInvalid
+	}
+	at = at.UTC()
+	c.ConfirmedAt = &at
+	r.changes[id] = c
+	return nil
+}
+
+func (r *EmailChangeRepository) TakeByRevertToken(revertTokenHash []byte) (*model.EmailChange, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	for id, c := range r.changes {
+		if string(c.RevertTokenHash) == string(revertTokenHash) {
+			delete(r.changes, id)
+			return &c, nil
+		}
+	}
+	return nil, errors.ErrEmailChangeTokenInvalid
+}
+
+func (r *EmailChangeRepository) Delete(id uuid.UUID) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	delete(r.changes, id)
+	return nil
+}
+
+func (r *EmailChangeRepository) PruneExpired(before time.Time) (int64, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	var n int64
+	for id, c := range r.changes {
+		if c.RevertExpiresAt.Before(before) {
+			delete(r.changes, id)
+			n++
+		}
+	}
+	return n, nil
+}
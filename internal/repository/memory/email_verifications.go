@@ -0,0 +1,60 @@
+package memory
+
+import (
+	"cruder/internal/errors"
+	"cruder/internal/model"
+	"cruder/internal/repository"
+	"sync"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// EmailVerificationRepository is an in-memory repository.EmailVerificationRepository.
+// Unlike the table, it does not drop the tokens of deleted users; they simply fail to verify.
+type EmailVerificationRepository struct {
+	mu     sync.Mutex
+	byUser map[uuid.UUID]model.EmailVerification
+}
+
+var _ repository.EmailVerificationRepository = (*EmailVerificationRepository)(nil)
+
+func NewEmailVerificationRepository() *EmailVerificationRepository {
+	return &EmailVerificationRepository{byUser: make(map[uuid.UUID]model.EmailVerification)}
+}
+
+func (r *EmailVerificationRepository) Create(v *model.EmailVerification) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	stored := *v
+	stored.OrganizationID = model.OrganizationOrDefault(v.OrganizationID)
+	r.byUser[v.UserID] = stored
+	return nil
+}
+
+func (r *EmailVerificationRepository) Consume(tokenHash []byte) (*model.EmailVerification, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	for id, v := range r.byUser {
+		if string(v.TokenHash) == string(tokenHash) {
+			delete(r.byUser, id)
+			return &v, nil
+		}
+	}
+	return nil, errors.ErrVerificationTokenInvalid
+}
+
+func (r *EmailVerificationRepository) PruneExpired(before time.Time) (int64, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	var n int64
+	for id, v := range r.byUser {
+		if v.ExpiresAt.Before(before) {
+			delete(r.byUser, id)
+			n++
+		}
+	}
+	return n, nil
+}
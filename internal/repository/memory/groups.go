@@ -0,0 +1,384 @@
+package memory
+
+import (
+	"cruder/internal/errors"
+	"cruder/internal/model"
+	"cruder/internal/repository"
+	"slices"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// GroupRepository is an in-memory repository.GroupRepository. It reads usernames from the
+// UserRepository it was made with, where Postgres joins the users table, and like ON DELETE
+// CASCADE it forgets the memberships of deleted users.
+type GroupRepository struct {
+	*groupStore
+	org uuid.UUID
+}
+
+// groupStore holds the groups of every organization, shared by the repositories InOrganization returns.
+type groupStore struct {
+	mu     sync.Mutex
+	groups map[uuid.UUID]model.Group
+	// members are the direct members by group ID; their Name is looked up when read
+	members map[uuid.UUID][]model.GroupMember
+	// history is the membership history, oldest first
+	history []model.GroupMembershipChange
+	users   *userStore
+}
+
+var _ repository.GroupRepository = (*GroupRepository)(nil)
+
+func NewGroupRepository(users *UserRepository) *GroupRepository {
+	return &GroupRepository{
+		groupStore: &groupStore{
+			groups:  make(map[uuid.UUID]model.Group),
+			members: make(map[uuid.UUID][]model.GroupMember),
+			users:   users.userStore,
+		},
+		org: model.DefaultOrganizationID,
+	}
+}
+
+func (r *GroupRepository) InOrganization(org uuid.UUID) repository.GroupRepository {
+	return &GroupRepository{groupStore: r.groupStore, org: model.OrganizationOrDefault(org)}
+}
+
+// get returns the group with id if it belongs to the repository's organization.
+func (r *GroupRepository) get(id uuid.UUID) (model.Group, bool) {
+	g, ok := r.groups[id]
+	if !ok || g.OrganizationID != r.org {
+		return model.Group{}, false
+	}
+	return g, true
+}
+
+// nameTaken reports whether a group other than id is called name.
+func (r *GroupRepository) nameTaken(id uuid.UUID, name string) bool {
+	for _, g := range r.groups {
+		if g.ID != id && g.OrganizationID == r.org && g.Name == name {
+			return true
+		}
+	}
+	return false
+}
+
+// username returns the username of the user with id, if they still exist.
+func (r *GroupRepository) username(id uuid.UUID) (string, bool) {
+	r.users.mu.RLock()
+	defer r.users.mu.RUnlock()
+	u, ok := r.users.users[id]
+	return u.Username, ok
+}
+
+func sortGroups(groups []model.Group) {
+	sort.Slice(groups, func(i, j int) bool { return groups[i].Name < groups[j].Name })
+}
+
+func (r *GroupRepository) Create(req *model.CreateGroupRequest) (*model.Group, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if r.nameTaken(uuid.Nil, req.Name) {
+		return nil, errors.ErrGroupExists
+	}
+	now := time.Now().UTC()
+	g := model.Group{ID: uuid.New(), OrganizationID: r.org, Name: req.Name, Description: req.Description, CreatedAt: now, UpdatedAt: now}
+	r.groups[g.ID] = g
+	return &g, nil
+}
+
+func (r *GroupRepository) GetByID(id uuid.UUID) (*model.Group, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	g, ok := r.get(id)
+	if !ok {
+		return nil, errors.ErrGroupNotFound
+	}
+	return &g, nil
+}
+
+func (r *GroupRepository) List() ([]model.Group, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	groups := []model.Group{}
+	for _, g := range r.groups {
+		if g.OrganizationID == r.org {
+			groups = append(groups, g)
+		}
+	}
+	sortGroups(groups)
+	return groups, nil
+}
+
+func (r *GroupRepository) Update(id uuid.UUID, req *model.UpdateGroupRequest) (*model.Group, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	g, ok := r.get(id)
+	if !ok {
+		return nil, errors.ErrGroupNotFound
+	}
+	if req.Name == nil && req.Description == nil {
+		return &g, nil
+	}
+	if req.Name != nil {
+		if r.nameTaken(id, *req.Name) {
+			return nil, errors.ErrGroupExists
+		}
+		g.Name = *req.Name
+	}
+	if req.Description != nil {
+		g.Description = *req.Description
+	}
+	g.UpdatedAt = time.Now().UTC()
+	r.groups[id] = g
+	return &g, nil
+}
+
+func (r *GroupRepository) Delete(id uuid.UUID, actor string, at time.Time) ([]model.GroupMembershipChange, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	group, ok := r.get(id)
+	if !ok {
+		return nil, errors.ErrGroupNotFound
+	}
+	changes := []model.GroupMembershipChange{}
+	removal := func(group uuid.UUID, m model.GroupMember, name string) model.GroupMembershipChange {
+		return model.GroupMembershipChange{GroupID: group, Action: model.MembershipRemoved, MemberType: m.Type, MemberID: m.ID,
+			MemberName: name, Actor: actor, ChangedAt: at}
+	}
+	for _, m := range r.members[id] {
+		if m.Type == model.MemberTypeGroup {
+			changes = append(changes, removal(id, m, r.groups[m.ID].Name))
+		} else if name, ok := r.username(m.ID); ok {
+			changes = append(changes, removal(id, m, name))
+		}
+	}
+
+	delete(r.groups, id)
+	delete(r.members, id)
+	r.history = slices.DeleteFunc(r.history, func(c model.GroupMembershipChange) bool { return c.GroupID == id })
+	for parent, members := range r.members {
+		r.members[parent] = slices.DeleteFunc(members, func(m model.GroupMember) bool {
+			if m.Type != model.MemberTypeGroup || m.ID != id {
+				return false
+			}
+			change := removal(parent, m, group.Name)
+			changes = append(changes, change)
+			r.history = append(r.history, change)
+			return true
+		})
+	}
+	return changes, nil
+}
+
+// contains reports whether target is group or nested in it, at any depth.
+func (r *GroupRepository) contains(group, target uuid.UUID) bool {
+	seen := map[uuid.UUID]bool{}
+	queue := []uuid.UUID{group}
+	for len(queue) > 0 {
+		id := queue[0]
+		queue = queue[1:]
+		if id == target {
+			return true
+		}
+		if seen[id] {
+			continue
+		}
+		seen[id] = true
+		for _, m := range r.members[id] {
+			if m.Type == model.MemberTypeGroup {
+				queue = append(queue, m.ID)
+			}
+		}
+	}
+	return false
+}
+
+func (r *GroupRepository) AddMember(change *model.GroupMembershipChange) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if _, ok := r.get(change.GroupID); !ok {
+		return errors.ErrGroupNotFound
+	}
+	if change.MemberType == model.MemberTypeGroup && r.contains(change.MemberID, change.GroupID) {
+		return errors.ErrGroupCycle
+	}
+	for _, m := range r.members[change.GroupID] {
+		if m.Type == change.MemberType && m.ID == change.MemberID {
+			return errors.ErrGroupMemberExists
+		}
+	}
+	r.members[change.GroupID] = append(r.members[change.GroupID], model.GroupMember{
+		Type: change.MemberType, ID: change.MemberID, AddedBy: change.Actor, AddedAt: change.ChangedAt,
+	})
+	r.history = append(r.history, *change)
+	return nil
+}
+
+func (r *GroupRepository) RemoveMember(change *model.GroupMembershipChange) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if _, ok := r.get(change.GroupID); !ok {
+		return errors.ErrGroupMemberNotFound
+	}
+	members := r.members[change.GroupID]
+	i := slices.IndexFunc(members, func(m model.GroupMember) bool {
+		return m.Type == change.MemberType && m.ID == change.MemberID
+	})
+	if i < 0 {
+		return errors.ErrGroupMemberNotFound
+	}
+	r.members[change.GroupID] = slices.Delete(members, i, i+1)
+	r.history = append(r.history, *change)
+	return nil
+}
+
+func (r *GroupRepository) RemoveUser(userID uuid.UUID, actor string, at time.Time) ([]model.GroupMembershipChange, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	changes := []model.GroupMembershipChange{}
+	name, ok := r.username(userID)
+	if !ok {
+		return changes, nil
+	}
+	for id, members := range r.members {
+		if _, ok := r.get(id); !ok {
+			continue
+		}
+		r.members[id] = slices.DeleteFunc(members, func(m model.GroupMember) bool {
+			if m.Type != model.MemberTypeUser || m.ID != userID {
+				return false
+			}
+			change := model.GroupMembershipChange{GroupID: id, Action: model.MembershipRemoved, MemberType: m.Type, MemberID: m.ID,
+				MemberName: name, Actor: actor, ChangedAt: at}
+			changes = append(changes, change)
+			r.history = append(r.history, change)
+			return true
+		})
+	}
+	return changes, nil
+}
+
+func (r *GroupRepository) Members(id uuid.UUID) ([]model.GroupMember, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	members := []model.GroupMember{}
+	if _, ok := r.get(id); !ok {
+		return members, nil
+	}
+	for _, m := range r.members[id] {
+		if m.Type == model.MemberTypeGroup {
+			m.Name = r.groups[m.ID].Name
+		} else {
+			name, ok := r.username(m.ID)
+			if !ok {
+				continue
+			}
+			m.Name = name
+		}
+		members = append(members, m)
+	}
+	sort.Slice(members, func(i, j int) bool {
+		if members[i].Type != members[j].Type {
+			return members[i].Type < members[j].Type
+		}
+		return members[i].Name < members[j].Name
+	})
+	return members, nil
+}
+
+func (r *GroupRepository) EffectiveUserIDs(id uuid.UUID) ([]uuid.UUID, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	ids := []uuid.UUID{}
+	if _, ok := r.get(id); !ok {
+		return ids, nil
+	}
+	seenGroups, seenUsers := map[uuid.UUID]bool{}, map[uuid.UUID]bool{}
+	queue := []uuid.UUID{id}
+	for len(queue) > 0 {
+		g := queue[0]
+		queue = queue[1:]
+		if seenGroups[g] {
+			continue
+		}
+		seenGroups[g] = true
+		for _, m := range r.members[g] {
+			switch {
+			case m.Type == model.MemberTypeGroup:
+				queue = append(queue, m.ID)
+			case !seenUsers[m.ID]:
+				if _, ok := r.username(m.ID); ok {
+					seenUsers[m.ID] = true
+					ids = append(ids, m.ID)
+				}
+			}
+		}
+	}
+	return ids, nil
+}
+
+func (r *GroupRepository) GroupsOfUser(userID uuid.UUID, effective bool) ([]model.Group, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	// found are the groups containing the user so far; with effective, the groups containing
+	// any of those are added until there are no more
+	found := map[uuid.UUID]bool{}
+	for id, members := range r.members {
+		if slices.ContainsFunc(members, func(m model.GroupMember) bool { return m.Type == model.MemberTypeUser && m.ID == userID }) {
+			found[id] = true
+		}
+	}
+	for grew := effective; grew; {
+		grew = false
+		for id, members := range r.members {
+			if found[id] {
+				continue
+			}
+			if slices.ContainsFunc(members, func(m model.GroupMember) bool { return m.Type == model.MemberTypeGroup && found[m.ID] }) {
+				found[id] = true
+				grew = true
+			}
+		}
+	}
+
+	groups := []model.Group{}
+	for id := range found {
+		if g, ok := r.get(id); ok {
+			groups = append(groups, g)
+		}
+	}
+	sortGroups(groups)
+	return groups, nil
+}
+
+func (r *GroupRepository) History(id uuid.UUID) ([]model.GroupMembershipChange, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	changes := []model.GroupMembershipChange{}
+	if _, ok := r.get(id); !ok {
+		return changes, nil
+	}
+	for i := len(r.history) - 1; i >= 0; i-- {
+		if r.history[i].GroupID == id {
+			changes = append(changes, r.history[i])
+		}
+	}
+	return changes, nil
+}
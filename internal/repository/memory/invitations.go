@@ -0,0 +1,190 @@
+package memory
+
+import (
+	"cruder/internal/errors"
+	"cruder/internal/model"
+	"cruder/internal/repository"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// InvitationRepository is an in-memory repository.InvitationRepository. Unlike the table, it
+// keeps the user ID of redeemed invitations after that user is deleted.
+type InvitationRepository struct {
+	*invitationStore
+	org uuid.UUID
+}
+
+// invitationStore holds the invitations of every organization, shared by the repositories InOrganization returns.
+type invitationStore struct {
+	mu          sync.Mutex
+	invitations map[uuid.UUID]model.Invitation
+}
+
+var _ repository.InvitationRepository = (*InvitationRepository)(nil)
+
+func NewInvitationRepository() *InvitationRepository {
+	return &InvitationRepository{
+		invitationStore: &invitationStore{invitations: make(map[uuid.UUID]model.Invitation)},
+		org:             model.DefaultOrganizationID,
+	}
+}
+
+func (r *InvitationRepository) InOrganization(org uuid.UUID) repository.InvitationRepository {
+	return &InvitationRepository{invitationStore: r.invitationStore, org: model.OrganizationOrDefault(org)}
+}
+
+// invitationOpen reports whether inv is neither redeemed nor revoked.
+func invitationOpen(inv model.Invitation) bool {
+	return inv.RedeemedAt == nil && inv.RevokedAt == nil
+}
+
+// pendingFor reports whether email has a pending invitation at now other than except.
+func (r *InvitationRepository) pendingFor(email string, except uuid.UUID, now time.Time) bool {
+	for _, inv := range r.invitations {
+		if inv.OrganizationID == r.org && inv.Email == email && inv.ID != except && invitationOpen(inv) && inv.ExpiresAt.After(now) {
+			return true
+		}
+	}
+	return false
+}
+
+// get returns the invitation with id if it belongs to the repository's organization.
+func (r *InvitationRepository) get(id uuid.UUID) (model.Invitation, error) {
+	inv, ok := r.invitations[id]
+	if !ok || inv.OrganizationID != r.org {
+		return model.Invitation{}, errors.ErrInvitationNotFound
+	}
+	return inv, nil
+}
+
+func (r *InvitationRepository) Create(inv *model.Invitation) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if r.pendingFor(inv.Email, uuid.Nil, inv.CreatedAt) {
+		return errors.ErrInvitationExists
+	}
+	inv.ID = uuid.New()
+	inv.OrganizationID = r.org
+	inv.SentAt = inv.CreatedAt
+	r.invitations[inv.ID] = *inv
+	return nil
+}
+
+func (r *InvitationRepository) GetByID(id uuid.UUID) (*model.Invitation, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	inv, err := r.get(id)
+	if err != nil {
+		return nil, err
+	}
+	return &inv, nil
+}
+
+func (r *InvitationRepository) GetByToken(tokenHash []byte) (*model.Invitation, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	for _, inv := range r.invitations {
+		if string(inv.TokenHash) == string(tokenHash) {
+			return &inv, nil
+		}
+	}
+	return nil, errors.ErrInvitationTokenInvalid
+}
+
+func (r *InvitationRepository) ListPending(now time.Time) ([]model.Invitation, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	invitations := []model.Invitation{}
+	for _, inv := range r.invitations {
+		if inv.OrganizationID == r.org && invitationOpen(inv) && inv.ExpiresAt.After(now) {
+			invitations = append(invitations, inv)
+		}
+	}
+	sort.Slice(invitations, func(i, j int) bool {
+		if !invitations[i].CreatedAt.Equal(invitations[j].CreatedAt) {
+			return invitations[i].CreatedAt.After(invitations[j].CreatedAt)
+		}
+		return invitations[i].ID.String() < invitations[j].ID.String()
+	})
+	return invitations, nil
+}
+
+func (r *InvitationRepository) Renew(id uuid.UUID, tokenHash []byte, expiresAt, sentAt time.Time) (*model.Invitation, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	inv, err := r.get(id)
+	if err != nil {
+		return nil, err
+	}
+	if !invitationOpen(inv) {
+		return nil, errors.ErrInvitationNotPending
+	}
+	if r.pendingFor(inv.Email, id, sentAt) {
+		return nil, errors.ErrInvitationExists
+	}
+	inv.TokenHash, inv.ExpiresAt, inv.SentAt = tokenHash, expiresAt, sentAt
+	r.invitations[id] = inv
+	return &inv, nil
+}
+
+func (r *InvitationRepository) Revoke(id uuid.UUID, at time.Time) (*model.Invitation, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	inv, err := r.get(id)
+	if err != nil {
+		return nil, err
+	}
+	if !invitationOpen(inv) {
+		return nil, errors.ErrInvitationNotPending
+	}
+	at = at.UTC()
+	inv.RevokedAt = &at
+	r.invitations[id] = inv
+	return &inv, nil
+}
+
+func (r *InvitationRepository) Claim(id uuid.UUID, at time.Time) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	inv, err := r.get(id)
+	if err != nil || !invitationOpen(inv) || !inv.ExpiresAt.After(at) {
+		return errors.ErrInvitationTokenInvalid
+	}
+	at = at.UTC()
+	inv.RedeemedAt = &at
+	r.invitations[id] = inv
+	return nil
+}
+
+func (r *InvitationRepository) Release(id uuid.UUID) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if inv, err := r.get(id); err == nil && inv.UserID == nil {
+		inv.RedeemedAt = nil
+		r.invitations[id] = inv
+	}
+	return nil
+}
+
+func (r *InvitationRepository) SetUser(id, userID uuid.UUID) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if inv, err := r.get(id); err == nil {
+		inv.UserID = &userID
+		r.invitations[id] = inv
+	}
+	return nil
+}
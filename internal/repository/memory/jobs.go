@@ -0,0 +1,234 @@
+package memory
+
+import (
+	"cruder/internal/errors"
+	"cruder/internal/model"
+	"cruder/internal/repository"
+	"encoding/json"
+	"fmt"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// JobRepository is an in-memory repository.JobRepository with the same claiming and ownership rules
+// as the jobs table. The mutex stands in for row locks.
+type JobRepository struct {
+	mu   sync.Mutex
+	jobs map[uuid.UUID]*memoryJob
+}
+
+type memoryJob struct {
+	job       model.Job
+	lockedBy  string
+	heartbeat time.Time
+	result    [][]byte
+}
+
+var _ repository.JobRepository = (*JobRepository)(nil)
+
+func NewJobRepository() *JobRepository {
+	return &JobRepository{jobs: make(map[uuid.UUID]*memoryJob)}
+}
+
+// snapshot copies a job so callers cannot modify the stored one.
+func (m *memoryJob) snapshot() *model.Job {
+	j := m.job
+	j.Input = nil
+	return &j
+}
+
+func (r *JobRepository) Create(kind string, params json.RawMessage, input []byte) (*model.Job, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if params == nil {
+		params = json.RawMessage(`{}`)
+	}
+	now := time.Now().UTC()
+	m := &memoryJob{job: model.Job{
+		ID:        uuid.New(),
+		Kind:      kind,
+		Status:    model.JobQueued,
+		Params:    params,
+		Input:     input,
+		CreatedAt: now,
+		UpdatedAt: now,
+	}}
+	r.jobs[m.job.ID] = m
+	return m.snapshot(), nil
+}
+
+func (r *JobRepository) GetByID(id uuid.UUID) (*model.Job, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	m, ok := r.jobs[id]
+	if !ok {
+		return nil, errors.ErrJobNotFound
+	}
+	return m.snapshot(), nil
+}
+
+func (r *JobRepository) RequestCancel(id uuid.UUID) (*model.Job, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	m, ok := r.jobs[id]
+	if !ok {
+		return nil, errors.ErrJobNotFound
+	}
+	if m.job.Status.Finished() {
+		return m.snapshot(), errors.ErrJobFinished
+	}
+	now := time.Now().UTC()
+	if m.job.Status == model.JobQueued {
+		m.job.Status = model.JobCancelled
+		m.job.FinishedAt = &now
+	}
+	m.job.CancelRequested = true
+	m.job.UpdatedAt = now
+	return m.snapshot(), nil
+}
+
+func (r *JobRepository) StreamResult(id uuid.UUID, fn func(chunk []byte) error) error {
+	r.mu.Lock()
+	m, ok := r.jobs[id]
+	var chunks [][]byte
+	if ok {
+		chunks = m.result
+	}
+	r.mu.Unlock()
+
+	for _, chunk := range chunks {
+		if err := fn(chunk); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (r *JobRepository) Claim(worker string, staleAfter time.Duration) (*model.Job, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	now := time.Now().UTC()
+	var candidates []*memoryJob
+	for _, m := range r.jobs {
+		if m.job.Status == model.JobQueued || (m.job.Status == model.JobRunning && now.Sub(m.heartbeat) > staleAfter) {
+			candidates = append(candidates, m)
+		}
+	}
+	if len(candidates) == 0 {
+		return nil, nil
+	}
+	sort.Slice(candidates, func(i, j int) bool { return candidates[i].job.CreatedAt.Before(candidates[j].job.CreatedAt) })
+
+	m := candidates[0]
+	m.job.Status = model.JobRunning
+	m.lockedBy = worker
+	m.heartbeat = now
+	if m.job.StartedAt == nil {
+		m.job.StartedAt = &now
+	}
+	m.job.Attempts++
+	m.job.UpdatedAt = now
+	m.result = nil
+
+	job := m.snapshot()
+	job.Input = m.job.Input
+	return job, nil
+}
+
+// owned returns the job if worker holds it.
+func (r *JobRepository) owned(id uuid.UUID, worker string) (*memoryJob, error) {
+	m, ok := r.jobs[id]
+	if !ok || m.lockedBy != worker || m.job.Status != model.JobRunning {
+		return nil, errors.ErrJobLost
+	}
+	return m, nil
+}
+
+func (r *JobRepository) Heartbeat(id uuid.UUID, worker string, progress model.JobProgress) (bool, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	m, err := r.owned(id, worker)
+	if err != nil {
+		return false, err
+	}
+	m.heartbeat = time.Now().UTC()
+	m.job.Progress = progress
+	m.job.UpdatedAt = m.heartbeat
+	return m.job.CancelRequested, nil
+}
+
+func (r *JobRepository) AppendResult(id uuid.UUID, worker string, seq int, chunk []byte) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	m, err := r.owned(id, worker)
+	if err != nil {
+		return err
+	}
+	if seq != len(m.result) {
+		return fmt.Errorf("result chunk %d out of order, expected %d", seq, len(m.result))
+	}
+	m.result = append(m.result, append([]byte(nil), chunk...))
+	return nil
+}
+
+func (r *JobRepository) Finish(id uuid.UUID, worker string, status model.JobStatus, progress model.JobProgress, resultType, errMsg string) error {
+	if !status.Finished() {
+		return fmt.Errorf("%w: %q is not a final job status", errors.ErrInvalidInput, status)
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	m, err := r.owned(id, worker)
+	if err != nil {
+		return err
+	}
+	now := time.Now().UTC()
+	m.job.Status = status
+	m.job.Progress = progress
+	m.job.ResultType = resultType
+	m.job.Error = errMsg
+	m.job.FinishedAt = &now
+	m.job.UpdatedAt = now
+	m.lockedBy = ""
+	return nil
+}
+
+func (r *JobRepository) Release(id uuid.UUID, worker string) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	m, err := r.owned(id, worker)
+	if err != nil {
+		return err
+	}
+	m.job.Status = model.JobQueued
+	m.lockedBy = ""
+	if m.job.Attempts > 0 {
+		m.job.Attempts--
+	}
+	m.job.UpdatedAt = time.Now().UTC()
+	return nil
+}
+
+func (r *JobRepository) PurgeFinished(before time.Time) (int64, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	var purged int64
+	for id, m := range r.jobs {
+		if m.job.Status.Finished() && m.job.FinishedAt != nil && m.job.FinishedAt.Before(before) {
+			delete(r.jobs, id)
+			purged++
+		}
+	}
+	return purged, nil
+}
@@ -0,0 +1,192 @@
+package memory
+
+import (
+	"cruder/internal/errors"
+	"cruder/internal/model"
+	"cruder/internal/repository"
+	"slices"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// OAuthRepository is an in-memory repository.OAuthRepository.
+// Unlike the tables, it does not drop the consents and codes of deleted users.
+type OAuthRepository struct {
+	mu       sync.Mutex
+	clients  map[string]model.OAuthClient
+	consents map[consentKey]model.OAuthConsent
+	codes    map[string]model.AuthorizationCode
+}
+
+type consentKey struct {
+	userID   uuid.UUID
+	clientID string
+}
+
+var _ repository.OAuthRepository = (*OAuthRepository)(nil)
+
+func NewOAuthRepository() *OAuthRepository {
+	return &OAuthRepository{
+		clients:  make(map[string]model.OAuthClient),
+		consents: make(map[consentKey]model.OAuthConsent),
+		codes:    make(map[string]model.AuthorizationCode),
+	}
+}
+
+func (r *OAuthRepository) CreateClient(c *model.OAuthClient) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	c.CreatedAt = time.Now().UTC()
+	c.OrganizationID = model.OrganizationOrDefault(c.OrganizationID)
+	stored := *c
+	stored.RedirectURIs = slices.Clone(c.RedirectURIs)
+	r.clients[c.ID] = stored
+	return nil
+}
+
+func (r *OAuthRepository) GetClient(id string) (*model.OAuthClient, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	c, ok := r.clients[id]
+	if !ok {
+		return nil, errors.ErrOAuthClientNotFound
+	}
+	c.RedirectURIs = slices.Clone(c.RedirectURIs)
+	return &c, nil
+}
+
+func (r *OAuthRepository) ListClients() ([]model.OAuthClient, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	clients := []model.OAuthClient{}
+	for _, c := range r.clients {
+		c.RedirectURIs = slices.Clone(c.RedirectURIs)
+		clients = append(clients, c)
+	}
+	sort.Slice(clients, func(i, j int) bool {
+		if !clients[i].CreatedAt.Equal(clients[j].CreatedAt) {
+			return clients[i].CreatedAt.Before(clients[j].CreatedAt)
+		}
+		return clients[i].ID < clients[j].ID
+	})
+	return clients, nil
+}
+
+func (r *OAuthRepository) DeleteClient(id string) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if _, ok := r.clients[id]; !ok {
+		return errors.ErrOAuthClientNotFound
+	}
+	delete(r.clients, id)
+	for key := range r.consents {
+		if key.clientID == id {
+			delete(r.consents, key)
+		}
+	}
+	for hash, code := range r.codes {
+		if code.ClientID == id {
+			delete(r.codes, hash)
+		}
+	}
+	return nil
+}
+
+func (r *OAuthRepository) SaveConsent(userID uuid.UUID, clientID, scope string, at time.Time) (*model.OAuthConsent, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	at = at.UTC()
+	key := consentKey{userID, clientID}
+	c, ok := r.consents[key]
+	if !ok {
+		c = model.OAuthConsent{UserID: userID, ClientID: clientID, GrantedAt: at}
+	}
+	c.Scope = scope
+	c.UpdatedAt = at
+	r.consents[key] = c
+	return &c, nil
+}
+
+func (r *OAuthRepository) GetConsent(userID uuid.UUID, clientID string) (*model.OAuthConsent, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	c, ok := r.consents[consentKey{userID, clientID}]
+	if !ok {
+		return nil, errors.ErrConsentNotFound
+	}
+	return &c, nil
+}
+
+func (r *OAuthRepository) ListConsents(userID uuid.UUID) ([]model.OAuthConsent, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	consents := []model.OAuthConsent{}
+	for key, c := range r.consents {
+		if key.userID == userID {
+			consents = append(consents, c)
+		}
+	}
+	sort.Slice(consents, func(i, j int) bool {
+		if !consents[i].UpdatedAt.Equal(consents[j].UpdatedAt) {
+			return consents[i].UpdatedAt.After(consents[j].UpdatedAt)
+		}
+		return consents[i].ClientID < consents[j].ClientID
+	})
+	return consents, nil
+}
+
+func (r *OAuthRepository) DeleteConsent(userID uuid.UUID, clientID string) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	key := consentKey{userID, clientID}
+	if _, ok := r.consents[key]; !ok {
+		return errors.ErrConsentNotFound
+	}
+	delete(r.consents, key)
+	return nil
+}
+
+func (r *OAuthRepository) CreateCode(code *model.AuthorizationCode) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	r.codes[string(code.CodeHash)] = *code
+	return nil
+}
+
+func (r *OAuthRepository) TakeCode(codeHash []byte) (*model.AuthorizationCode, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	code, ok := r.codes[string(codeHash)]
+	if !ok {
+		return nil, errors.ErrAuthorizationCodeInvalid
+	}
+	delete(r.codes, string(codeHash))
+	return &code, nil
+}
+
+func (r *OAuthRepository) PruneExpiredCodes(before time.Time) (int64, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	var n int64
+	for hash, code := range r.codes {
+		if code.ExpiresAt.Before(before) {
+			delete(r.codes, hash)
+			n++
+		}
+	}
+	return n, nil
+}
@@ -0,0 +1,75 @@
+package memory
+
+import (
+	"cruder/internal/errors"
+	"cruder/internal/model"
+	"cruder/internal/repository"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// OrganizationRepository is an in-memory repository.OrganizationRepository. Like the table after
+// its migration, it starts out with the default organization.
+type OrganizationRepository struct {
+	mu   sync.Mutex
+	orgs map[uuid.UUID]model.Organization
+}
+
+var _ repository.OrganizationRepository = (*OrganizationRepository)(nil)
+
+func NewOrganizationRepository() *OrganizationRepository {
+	def := model.Organization{ID: model.DefaultOrganizationID, Slug: model.DefaultOrganizationSlug, Name: "Default", CreatedAt: time.Now().UTC()}
+	return &OrganizationRepository{orgs: map[uuid.UUID]model.Organization{def.ID: def}}
+}
+
+func (r *OrganizationRepository) Create(req *model.CreateOrganizationRequest) (*model.Organization, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	for _, o := range r.orgs {
+		if o.Slug == req.Slug {
+			return nil, errors.ErrOrganizationExists
+		}
+	}
+	o := model.Organization{ID: uuid.New(), Slug: req.Slug, Name: req.Name, CreatedAt: time.Now().UTC()}
+	r.orgs[o.ID] = o
+	return &o, nil
+}
+
+func (r *OrganizationRepository) GetByID(id uuid.UUID) (*model.Organization, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	o, ok := r.orgs[id]
+	if !ok {
+		return nil, errors.ErrOrganizationNotFound
+	}
+	return &o, nil
+}
+
+func (r *OrganizationRepository) GetBySlug(slug string) (*model.Organization, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	for _, o := range r.orgs {
+		if o.Slug == slug {
+			return &o, nil
+		}
+	}
+	return nil, errors.ErrOrganizationNotFound
+}
+
+func (r *OrganizationRepository) List() ([]model.Organization, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	orgs := make([]model.Organization, 0, len(r.orgs))
+	for _, o := range r.orgs {
+		orgs = append(orgs, o)
+	}
+	sort.Slice(orgs, func(i, j int) bool { return orgs[i].Slug < orgs[j].Slug })
+	return orgs, nil
+}
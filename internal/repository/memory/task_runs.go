@@ -0,0 +1,98 @@
+package memory
+
+import (
+	"cruder/internal/model"
+	"cruder/internal/repository"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// TaskRunRepository is an in-memory repository.TaskRunRepository.
+type TaskRunRepository struct {
+	mu   sync.Mutex
+	runs []*model.TaskRun
+}
+
+var _ repository.TaskRunRepository = (*TaskRunRepository)(nil)
+
+func NewTaskRunRepository() *TaskRunRepository {
+	return &TaskRunRepository{}
+}
+
+func (r *TaskRunRepository) Start(task string, scheduledAt time.Time, node string) (*model.TaskRun, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	for _, run := range r.runs {
+		if run.Task == task && run.ScheduledAt.Equal(scheduledAt) {
+			return nil, nil
+		}
+	}
+	run := &model.TaskRun{
+		ID:          uuid.New(),
+		Task:        task,
+		ScheduledAt: scheduledAt.UTC(),
+		Node:        node,
+		Status:      model.TaskRunning,
+		StartedAt:   time.Now().UTC(),
+	}
+	r.runs = append(r.runs, run)
+	copied := *run
+	return &copied, nil
+}
+
+func (r *TaskRunRepository) Finish(id uuid.UUID, errMsg string) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	for _, run := range r.runs {
+		if run.ID != id {
+			continue
+		}
+		now := time.Now().UTC()
+		duration := now.Sub(run.StartedAt).Milliseconds()
+		run.Status = model.TaskSucceeded
+		if errMsg != "" {
+			run.Status = model.TaskFailed
+		}
+		run.Error = errMsg
+		run.FinishedAt = &now
+		run.DurationMS = &duration
+	}
+	return nil
+}
+
+func (r *TaskRunRepository) List(task string, limit int) ([]model.TaskRun, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	runs := []model.TaskRun{}
+	for _, run := range r.runs {
+		if task == "" || run.Task == task {
+			runs = append(runs, *run)
+		}
+	}
+	sort.SliceStable(runs, func(i, j int) bool { return runs[i].StartedAt.After(runs[j].StartedAt) })
+	if len(runs) > limit {
+		runs = runs[:limit]
+	}
+	return runs, nil
+}
+
+func (r *TaskRunRepository) Prune(before time.Time) (int64, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	kept := r.runs[:0]
+	for _, run := range r.runs {
+		if !run.StartedAt.Before(before) {
+			kept = append(kept, run)
+		}
+	}
+	pruned := int64(len(r.runs) - len(kept))
+	r.runs = kept
+	return pruned, nil
+}
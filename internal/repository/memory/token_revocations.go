@@ -0,0 +1,50 @@
+package memory
+
+import (
+	"cruder/internal/model"
+	"cruder/internal/repository"
+	"sort"
+	"sync"
+	"time"
+)
+
+// TokenRevocationRepository is an in-memory repository.TokenRevocationRepository.
+type TokenRevocationRepository struct {
+	mu          sync.Mutex
+	revocations map[string]*model.TokenRevocation
+}
+
+var _ repository.TokenRevocationRepository = (*TokenRevocationRepository)(nil)
+
+func NewTokenRevocationRepository() *TokenRevocationRepository {
+	return &TokenRevocationRepository{revocations: make(map[string]*model.TokenRevocation)}
+}
+
+func (r *TokenRevocationRepository) Revoke(subject string, before time.Time) (*model.TokenRevocation, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	rev, ok := r.revocations[subject]
+	if !ok {
+		rev = &model.TokenRevocation{Subject: subject}
+		r.revocations[subject] = rev
+	}
+	if before.After(rev.IssuedBefore) {
+		rev.IssuedBefore = before.UTC()
+	}
+	rev.UpdatedAt = time.Now().UTC()
+	out := *rev
+	return &out, nil
+}
+
+func (r *TokenRevocationRepository) List() ([]model.TokenRevocation, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	revocations := make([]model.TokenRevocation, 0, len(r.revocations))
+	for _, rev := range r.revocations {
+		revocations = append(revocations, *rev)
+	}
+	sort.Slice(revocations, func(i, j int) bool { return revocations[i].Subject < revocations[j].Subject })
+	return revocations, nil
+}
@@ -0,0 +1,392 @@
+// Package memory provides in-memory repository implementations for tests
+// that need the real service, controller and router stack without Postgres.
+package memory
+
+import (
+	"cruder/internal/errors"
+	"cruder/internal/model"
+	"cruder/internal/repository"
+	"slices"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// UserRepository is a concurrency-safe, in-memory repository.UserRepository
+// that enforces the same uniqueness rules as the users table.
+type UserRepository struct {
+	*userStore
+	// org is the organization whose users the repository sees
+	org uuid.UUID
+}
+
+// userStore holds the users of every organization, shared by the repositories InOrganization returns.
+type userStore struct {
+	mu    sync.RWMutex
+	users map[uuid.UUID]model.User
+	// passwords holds the hashes of users that have one
+	passwords map[uuid.UUID]string
+	// renames is the username history, oldest first
+	renames []model.UsernameChange
+	// statusChanges is the status history, oldest first
+	statusChanges []model.UserStatusChange
+}
+
+var _ repository.UserRepository = (*UserRepository)(nil)
+
+func NewUserRepository() *UserRepository {
+	return &UserRepository{
+		userStore: &userStore{users: make(map[uuid.UUID]model.User), passwords: make(map[uuid.UUID]string)},
+		org:       model.DefaultOrganizationID,
+	}
+}
+
+func (r *UserRepository) InOrganization(org uuid.UUID) repository.UserRepository {
+	return &UserRepository{userStore: r.userStore, org: model.OrganizationOrDefault(org)}
+}
+
+// get returns the user with id if it belongs to the repository's organization.
+func (r *UserRepository) get(id uuid.UUID) (model.User, bool) {
+	u, ok := r.users[id]
+	if !ok || u.OrganizationID != r.org {
+		return model.User{}, false
+	}
+	return u, true
+}
+
+// sorted returns the organization's users ordered by ID, matching Postgres' UUID ordering.
+func (r *UserRepository) sorted() []model.User {
+	users := make([]model.User, 0, len(r.users))
+	for _, u := range r.users {
+		if u.OrganizationID == r.org {
+			users = append(users, u)
+		}
+	}
+	sort.Slice(users, func(i, j int) bool { return users[i].ID.String() < users[j].ID.String() })
+	return users
+}
+
+// Field selections are ignored: every read returns whole users, which callers may then project.
+func (r *UserRepository) GetAll(_ model.UserFields) ([]model.User, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	return r.sorted(), nil
+}
+
+func (r *UserRepository) Search(query string, _ model.UserFields) ([]model.User, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	q := strings.ToLower(query)
+	var found []model.User
+	for _, u := range r.sorted() {
+		if strings.Contains(strings.ToLower(u.Username), q) ||
+			strings.Contains(strings.ToLower(u.Email), q) ||
+			strings.Contains(strings.ToLower(u.FullName), q) {
+			found = append(found, u)
+		}
+	}
+	sort.Slice(found, func(i, j int) bool { return found[i].Username < found[j].Username })
+	return found, nil
+}
+
+// StreamAll iterates over a snapshot, so fn may call back into the repository.
+func (r *UserRepository) StreamAll(fields model.UserFields, fn func(*model.User) error) error {
+	users, _ := r.GetAll(fields)
+	for i := range users {
+		if err := fn(&users[i]); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (r *UserRepository) StreamSearch(query string, _ model.UserFields, fn func(*model.User) error) error {
+	users, _ := r.Search(query, nil)
+	for i := range users {
+		if err := fn(&users[i]); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (r *UserRepository) List(params model.ListUsersParams) ([]model.User, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	var page []model.User
+	for _, u := range r.sorted() {
+		if params.After != nil && u.ID.String() <= params.After.String() {
+			continue
+		}
+		if params.Status != "" && u.Status != params.Status {
+			continue
+		}
+		if len(page) == params.Limit {
+			break
+		}
+		page = append(page, u)
+	}
+	return page, nil
+}
+
+func (r *UserRepository) GetByUsername(username string, _ model.UserFields) (*model.User, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	for _, u := range r.users {
+		if u.OrganizationID == r.org && u.Username == username {
+			return &u, nil
+		}
+	}
+	return nil, errors.ErrUserNotFound
+}
+
+func (r *UserRepository) GetByEmail(email string, _ model.UserFields) (*model.User, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	for _, u := range r.users {
+		if u.OrganizationID == r.org && u.Email == email {
+			return &u, nil
+		}
+	}
+	return nil, errors.ErrUserNotFound
+}
+
+func (r *UserRepository) GetByID(id uuid.UUID, _ model.UserFields) (*model.User, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	u, ok := r.get(id)
+	if !ok {
+		return nil, errors.ErrUserNotFound
+	}
+	return &u, nil
+}
+
+func (r *UserRepository) GetByIDs(ids []uuid.UUID) ([]model.User, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	var found []model.User
+	for _, id := range ids {
+		if u, ok := r.get(id); ok {
+			found = append(found, u)
+		}
+	}
+	return found, nil
+}
+
+func (r *UserRepository) Create(req *model.CreateUserRequest) (*model.User, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if err := r.checkUnique(uuid.Nil, req.Username, req.Email); err != nil {
+		return nil, err
+	}
+
+	now := time.Now().UTC()
+	u := model.User{
+		ID:             uuid.New(),
+		OrganizationID: r.org,
+		Username:       req.Username,
+		Email:          req.Email,
+		FullName:       req.FullName,
+		Status:         req.Status,
+		CreatedAt:      now,
+		UpdatedAt:      now,
+	}
+	if u.Status == "" {
+		u.Status = model.UserActive
+	}
+	r.users[u.ID] = u
+	return &u, nil
+}
+
+func (r *UserRepository) Update(id uuid.UUID, req *model.UpdateUserRequest) (*model.User, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	u, ok := r.get(id)
+	if !ok {
+		return nil, errors.ErrUserNotFound
+	}
+	if req.Username == nil && req.Email == nil && req.FullName == nil {
+		return &u, nil
+	}
+
+	username, email := u.Username, u.Email
+	if req.Username != nil {
+		username = *req.Username
+	}
+	if req.Email != nil {
+		email = *req.Email
+	}
+	if err := r.checkUnique(id, username, email); err != nil {
+		return nil, err
+	}
+
+	if email != u.Email {
+		u.EmailVerifiedAt = nil
+	}
+	now := time.Now().UTC()
+	if username != u.Username {
+		r.renames = append(r.renames, model.UsernameChange{UserID: id, OldUsername: u.Username, NewUsername: username, Actor: req.Actor, ChangedAt: now})
+	}
+	u.Username, u.Email = username, email
+	if req.FullName != nil {
+		u.FullName = *req.FullName
+	}
+	u.UpdatedAt = now
+	r.users[id] = u
+	return &u, nil
+}
+
+func (r *UserRepository) UsernameHistory(id uuid.UUID) ([]model.UsernameChange, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	changes := []model.UsernameChange{}
+	if _, ok := r.get(id); !ok {
+		return changes, nil
+	}
+	for i := len(r.renames) - 1; i >= 0; i-- {
+		if r.renames[i].UserID == id {
+			changes = append(changes, r.renames[i])
+		}
+	}
+	return changes, nil
+}
+
+func (r *UserRepository) GetFormerUsername(username string, since time.Time) (*model.UsernameChange, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	for i := len(r.renames) - 1; i >= 0; i-- {
+		c := r.renames[i]
+		if _, ok := r.get(c.UserID); ok && c.OldUsername == username && !c.ChangedAt.Before(since) {
+			return &c, nil
+		}
+	}
+	return nil, errors.ErrUserNotFound
+}
+
+func (r *UserRepository) SetStatus(id uuid.UUID, change *model.UserStatusChange) (*model.User, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	u, ok := r.get(id)
+	if !ok {
+		return nil, errors.ErrUserNotFound
+	}
+	if u.Status != change.OldStatus {
+		return nil, &errors.StatusTransitionError{From: string(u.Status), To: string(change.NewStatus)}
+	}
+	u.Status = change.NewStatus
+	u.UpdatedAt = time.Now().UTC()
+	r.users[id] = u
+	change.ChangedAt = u.UpdatedAt
+	r.statusChanges = append(r.statusChanges, *change)
+	return &u, nil
+}
+
+func (r *UserRepository) StatusHistory(id uuid.UUID) ([]model.UserStatusChange, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	changes := []model.UserStatusChange{}
+	if _, ok := r.get(id); !ok {
+		return changes, nil
+	}
+	for i := len(r.statusChanges) - 1; i >= 0; i-- {
+		if r.statusChanges[i].UserID == id {
+			changes = append(changes, r.statusChanges[i])
+		}
+	}
+	return changes, nil
+}
+
+func (r *UserRepository) Delete(id uuid.UUID) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if _, ok := r.get(id); !ok {
+		return errors.ErrUserNotFound
+	}
+	delete(r.users, id)
+	delete(r.passwords, id)
+	// Like ON DELETE CASCADE on username_history and user_status_history
+	r.renames = slices.DeleteFunc(r.renames, func(c model.UsernameChange) bool { return c.UserID == id })
+	r.statusChanges = slices.DeleteFunc(r.statusChanges, func(c model.UserStatusChange) bool { return c.UserID == id })
+	return nil
+}
+
+func (r *UserRepository) GetCredentials(login string) (*model.Credentials, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	for id, u := range r.users {
+		if u.OrganizationID == r.org && (u.Username == login || u.Email == login) {
+			return &model.Credentials{User: u, PasswordHash: r.passwords[id]}, nil
+		}
+	}
+	return nil, errors.ErrUserNotFound
+}
+
+func (r *UserRepository) GetCredentialsByID(id uuid.UUID) (*model.Credentials, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	u, ok := r.get(id)
+	if !ok {
+		return nil, errors.ErrUserNotFound
+	}
+	return &model.Credentials{User: u, PasswordHash: r.passwords[id]}, nil
+}
+
+func (r *UserRepository) SetPasswordHash(id uuid.UUID, hash string) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if _, ok := r.get(id); !ok {
+		return errors.ErrUserNotFound
+	}
+	r.passwords[id] = hash
+	return nil
+}
+
+func (r *UserRepository) MarkEmailVerified(id uuid.UUID, email string, at time.Time) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	u, ok := r.get(id)
+	if !ok || u.Email != email {
+		return errors.ErrUserNotFound
+	}
+	at = at.UTC()
+	u.EmailVerifiedAt = &at
+	r.users[id] = u
+	return nil
+}
+
+// checkUnique mirrors the UNIQUE constraints on username and email within the organization,
+// ignoring the user being updated.
+func (r *UserRepository) checkUnique(self uuid.UUID, username, email string) error {
+	for id, u := range r.users {
+		if id == self || u.OrganizationID != r.org {
+			continue
+		}
+		if u.Username == username {
+			return errors.ErrUsernameExists
+		}
+		if u.Email == email {
+			return errors.ErrEmailExists
+		}
+	}
+	return nil
+}
@@ -0,0 +1,206 @@
+package repository
+
+import (
+	"context"
+	"cruder/internal/errors"
+	"cruder/internal/model"
+	"database/sql"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/lib/pq"
+)
+
+// OAuthRepository stores the OpenID Connect provider's clients, consents and authorization codes.
+type OAuthRepository interface {
+	CreateClient(c *model.OAuthClient) error
+	// GetClient returns the client with id, or ErrOAuthClientNotFound.
+	GetClient(id string) (*model.OAuthClient, error)
+	// ListClients returns every client, oldest first.
+	ListClients() ([]model.OAuthClient, error)
+	// DeleteClient deletes the client with its consents and codes, or returns ErrOAuthClientNotFound.
+	DeleteClient(id string) error
+
+	// SaveConsent records that the user allowed the client scope, replacing the scope of an
+	// earlier consent but keeping when it was first granted.
+	SaveConsent(userID uuid.UUID, clientID, scope string, at time.Time) (*model.OAuthConsent, error)
+	// GetConsent returns the user's consent to the client, or ErrConsentNotFound.
+	GetConsent(userID uuid.UUID, clientID string) (*model.OAuthConsent, error)
+	// ListConsents returns the user's consents, most recently updated first.
+	ListConsents(userID uuid.UUID) ([]model.OAuthConsent, error)
+	// DeleteConsent deletes the user's consent to the client, or returns ErrConsentNotFound.
+	DeleteConsent(userID uuid.UUID, clientID string) error
+
+	CreateCode(code *model.AuthorizationCode) error
+	// TakeCode deletes the code with codeHash and returns it, expired or not, so each code works
+	// once. It returns ErrAuthorizationCodeInvalid if there is none.
+	TakeCode(codeHash []byte) (*model.AuthorizationCode, error)
+	// PruneExpiredCodes deletes the codes that expired before before and returns how many.
+	PruneExpiredCodes(before time.Time) (int64, error)
+}
+
+type oauthRepository struct {
+	db *sql.DB
+}
+
+func NewOAuthRepository(db *sql.DB) OAuthRepository {
+	return &oauthRepository{db: db}
+}
+
+const oauthClientColumns = `id, name, organization_id, public, redirect_uris, secret_hash, created_at`
+
+func scanOAuthClient(row interface{ Scan(...any) error }) (*model.OAuthClient, error) {
+	var c model.OAuthClient
+	if err := row.Scan(&c.ID, &c.Name, &c.OrganizationID, &c.Public, pq.Array(&c.RedirectURIs), &c.SecretHash, &c.CreatedAt); err != nil {
+		return nil, err
+	}
+	return &c, nil
+}
+
+func (r *oauthRepository) CreateClient(c *model.OAuthClient) error {
+	c.OrganizationID = model.OrganizationOrDefault(c.OrganizationID)
+	return r.db.QueryRowContext(context.Background(), `
+		INSERT INTO oauth_clients (id, name, organization_id, public, redirect_uris, secret_hash)
+		VALUES ($1, $2, $3, $4, $5, $6)
+		RETURNING created_at`,
+		c.ID, c.Name, c.OrganizationID, c.Public, pq.Array(c.RedirectURIs), c.SecretHash,
+	).Scan(&c.CreatedAt)
+}
+
+func (r *oauthRepository) GetClient(id string) (*model.OAuthClient, error) {
+	c, err := scanOAuthClient(r.db.QueryRowContext(context.Background(),
+		`SELECT `+oauthClientColumns+` FROM oauth_clients WHERE id = $1`, id))
+	if err == sql.ErrNoRows {
+		return nil, errors.ErrOAuthClientNotFound
+	}
+	return c, err
+}
+
+func (r *oauthRepository) ListClients() ([]model.OAuthClient, error) {
+	rows, err := r.db.QueryContext(context.Background(),
+		`SELECT `+oauthClientColumns+` FROM oauth_clients ORDER BY created_at, id`)
+	if err != nil {
+		return nil, err
+	}
+	defer func() { _ = rows.Close() }()
+
+	clients := []model.OAuthClient{}
+	for rows.Next() {
+		c, err := scanOAuthClient(rows)
+		if err != nil {
+			return nil, err
+		}
+		clients = append(clients, *c)
+	}
+	return clients, rows.Err()
+}
+
+func (r *oauthRepository) DeleteClient(id string) error {
+	return deleteOne(r.db, errors.ErrOAuthClientNotFound, `DELETE FROM oauth_clients WHERE id = $1`, id)
+}
+
+// deleteOne runs a DELETE and returns notFound if it deleted nothing.
+func deleteOne(db *sql.DB, notFound error, query string, args ...any) error {
+	res, err := db.ExecContext(context.Background(), query, args...)
+	if err != nil {
+		return err
+	}
+	n, err := res.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if n == 0 {
+		return notFound
+	}
+	return nil
+}
+
+const oauthConsentColumns = `user_id, client_id, scope, granted_at, updated_at`
+
+func scanOAuthConsent(row interface{ Scan(...any) error }) (*model.OAuthConsent, error) {
+	var c model.OAuthConsent
+	if err := row.Scan(&c.UserID, &c.ClientID, &c.Scope, &c.GrantedAt, &c.UpdatedAt); err != nil {
+		return nil, err
+	}
+	return &c, nil
+}
+
+func (r *oauthRepository) SaveConsent(userID uuid.UUID, clientID, scope string, at time.Time) (*model.OAuthConsent, error) {
+	return scanOAuthConsent(r.db.QueryRowContext(context.Background(), `
+		INSERT INTO oauth_consents (user_id, client_id, scope, granted_at, updated_at)
+		VALUES ($1, $2, $3, $4, $4)
+		ON CONFLICT (user_id, client_id) DO UPDATE SET scope = EXCLUDED.scope, updated_at = EXCLUDED.updated_at
+		RETURNING `+oauthConsentColumns, userID, clientID, scope, at.UTC()))
+}
+
+func (r *oauthRepository) GetConsent(userID uuid.UUID, clientID string) (*model.OAuthConsent, error) {
+	c, err := scanOAuthConsent(r.db.QueryRowContext(context.Background(),
+		`SELECT `+oauthConsentColumns+` FROM oauth_consents WHERE user_id = $1 AND client_id = $2`, userID, clientID))
+	if err == sql.ErrNoRows {
+		return nil, errors.ErrConsentNotFound
+	}
+	return c, err
+}
+
+func (r *oauthRepository) ListConsents(userID uuid.UUID) ([]model.OAuthConsent, error) {
+	rows, err := r.db.QueryContext(context.Background(), `
+		SELECT `+oauthConsentColumns+`
+		FROM oauth_consents
+		WHERE user_id = $1
+		ORDER BY updated_at DESC, client_id`, userID)
+	if err != nil {
+		return nil, err
+	}
+	defer func() { _ = rows.Close() }()
+
+	consents := []model.OAuthConsent{}
+	for rows.Next() {
+		c, err := scanOAuthConsent(rows)
+		if err != nil {
+			return nil, err
+		}
+		consents = append(consents, *c)
+	}
+	return consents, rows.Err()
+}
+
+func (r *oauthRepository) DeleteConsent(userID uuid.UUID, clientID string) error {
+	return deleteOne(r.db, errors.ErrConsentNotFound,
+		`DELETE FROM oauth_consents WHERE user_id = $1 AND client_id = $2`, userID, clientID)
+}
+
+func (r *oauthRepository) CreateCode(code *model.AuthorizationCode) error {
+	_, err := r.db.ExecContext(context.Background(), `
+		INSERT INTO oauth_authorization_codes
+			(code_hash, client_id, user_id, redirect_uri, scope, nonce, code_challenge, auth_time, expires_at)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9)`,
+		code.CodeHash, code.ClientID, code.UserID, code.RedirectURI, code.Scope, code.Nonce, code.CodeChallenge,
+		code.AuthTime.UTC(), code.ExpiresAt.UTC())
+	return err
+}
+
+func (r *oauthRepository) TakeCode(codeHash []byte) (*model.AuthorizationCode, error) {
+	var c model.AuthorizationCode
+	err := r.db.QueryRowContext(context.Background(), `
+		DELETE FROM oauth_authorization_codes
+		WHERE code_hash = $1
+		RETURNING code_hash, client_id, user_id, redirect_uri, scope, nonce, code_challenge, auth_time, expires_at`,
+		codeHash,
+	).Scan(&c.CodeHash, &c.ClientID, &c.UserID, &c.RedirectURI, &c.Scope, &c.Nonce, &c.CodeChallenge, &c.AuthTime, &c.ExpiresAt)
+	if err == sql.ErrNoRows {
+		return nil, errors.ErrAuthorizationCodeInvalid
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &c, nil
+}
+
+func (r *oauthRepository) PruneExpiredCodes(before time.Time) (int64, error) {
+	res, err := r.db.ExecContext(context.Background(),
+		`DELETE FROM oauth_authorization_codes WHERE expires_at < $1`, before.UTC())
+	if err != nil {
+		return 0, err
+	}
+	return res.RowsAffected()
+}
@@ -0,0 +1,83 @@
+package repository
+
+import (
+	"context"
+	"cruder/internal/errors"
+	"cruder/internal/model"
+	"database/sql"
+
+	"github.com/google/uuid"
+	"github.com/lib/pq"
+
+	stdErrors "errors"
+)
+
+// OrganizationRepository stores the tenants users belong to.
+type OrganizationRepository interface {
+	// Create stores a new organization, or returns ErrOrganizationExists if its slug is taken.
+	Create(req *model.CreateOrganizationRequest) (*model.Organization, error)
+	// GetByID and GetBySlug return ErrOrganizationNotFound if there is no such organization.
+	GetByID(id uuid.UUID) (*model.Organization, error)
+	GetBySlug(slug string) (*model.Organization, error)
+	// List returns every organization, by slug.
+	List() ([]model.Organization, error)
+}
+
+type organizationRepository struct {
+	db *sql.DB
+}
+
+func NewOrganizationRepository(db *sql.DB) OrganizationRepository {
+	return &organizationRepository{db: db}
+}
+
+const organizationColumns = `id, slug, name, created_at`
+
+func scanOrganization(row rowScanner) (*model.Organization, error) {
+	var o model.Organization
+	if err := row.Scan(&o.ID, &o.Slug, &o.Name, &o.CreatedAt); err != nil {
+		if err == sql.ErrNoRows {
+			return nil, errors.ErrOrganizationNotFound
+		}
+		return nil, err
+	}
+	return &o, nil
+}
+
+func (r *organizationRepository) Create(req *model.CreateOrganizationRequest) (*model.Organization, error) {
+	o, err := scanOrganization(r.db.QueryRowContext(context.Background(),
+		`INSERT INTO organizations (slug, name) VALUES ($1, $2) RETURNING `+organizationColumns, req.Slug, req.Name))
+	var pqErr *pq.Error
+	if stdErrors.As(err, &pqErr) && pqErr.Code == "23505" {
+		return nil, errors.ErrOrganizationExists
+	}
+	return o, err
+}
+
+func (r *organizationRepository) GetByID(id uuid.UUID) (*model.Organization, error) {
+	return scanOrganization(r.db.QueryRowContext(context.Background(),
+		`SELECT `+organizationColumns+` FROM organizations WHERE id = $1`, id))
+}
+
+func (r *organizationRepository) GetBySlug(slug string) (*model.Organization, error) {
+	return scanOrganization(r.db.QueryRowContext(context.Background(),
+		`SELECT `+organizationColumns+` FROM organizations WHERE slug = $1`, slug))
+}
+
+func (r *organizationRepository) List() ([]model.Organization, error) {
+	rows, err := r.db.QueryContext(context.Background(), `SELECT `+organizationColumns+` FROM organizations ORDER BY slug`)
+	if err != nil {
+		return nil, err
+	}
+	defer func() { _ = rows.Close() }()
+
+	orgs := []model.Organization{}
+	for rows.Next() {
+		o, err := scanOrganization(rows)
+		if err != nil {
+			return nil, err
+		}
+		orgs = append(orgs, *o)
+	}
+	return orgs, rows.Err()
+}
@@ -3,11 +3,31 @@ package repository
 import "database/sql"
 
 type Repository struct {
-	Users UserRepository
+	Users              UserRepository
+	Jobs               JobRepository
+	TaskRuns           TaskRunRepository
+	TokenRevocations   TokenRevocationRepository
+	EmailVerifications EmailVerificationRepository
+	EmailChanges       EmailChangeRepository
+	AuthFailures       AuthFailureRepository
+	OAuth              OAuthRepository
+	Organizations      OrganizationRepository
+	Groups             GroupRepository
+	Invitations        InvitationRepository
 }
 
 func NewRepository(db *sql.DB) *Repository {
 	return &Repository{
-		Users: NewUserRepository(db),
+		Users:              NewUserRepository(db),
+		Jobs:               NewJobRepository(db),
+		TaskRuns:           NewTaskRunRepository(db),
+		TokenRevocations:   NewTokenRevocationRepository(db),
+		EmailVerifications: NewEmailVerificationRepository(db),
+		EmailChanges:       NewEmailChangeRepository(db),
+		AuthFailures:       NewAuthFailureRepository(db),
+		OAuth:              NewOAuthRepository(db),
+		Organizations:      NewOrganizationRepository(db),
+		Groups:             NewGroupRepository(db),
+		Invitations:        NewInvitationRepository(db),
 	}
 }
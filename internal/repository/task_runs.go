@@ -0,0 +1,105 @@
+package repository
+
+import (
+	"context"
+	"cruder/internal/model"
+	"database/sql"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+type TaskRunRepository interface {
+	// Start records that node is running task for the slot scheduledAt. It returns nil when the slot
+	// was already claimed, by this or another replica.
+	Start(task string, scheduledAt time.Time, node string) (*model.TaskRun, error)
+	// Finish records the outcome of a run; errMsg is empty on success.
+	Finish(id uuid.UUID, errMsg string) error
+	// List returns the latest runs first, of one task or, if task is empty, of all of them.
+	List(task string, limit int) ([]model.TaskRun, error)
+	// Prune deletes runs started before before and returns how many.
+	Prune(before time.Time) (int64, error)
+}
+
+type taskRunRepository struct {
+	db *sql.DB
+}
+
+func NewTaskRunRepository(db *sql.DB) TaskRunRepository {
+	return &taskRunRepository{db: db}
+}
+
+const taskRunColumns = `id, task, scheduled_at, node, status, COALESCE(error, ''), started_at, finished_at, duration_ms`
+
+func scanTaskRun(row rowScanner) (*model.TaskRun, error) {
+	var (
+		run      model.TaskRun
+		finished sql.NullTime
+		duration sql.NullInt64
+	)
+	if err := row.Scan(&run.ID, &run.Task, &run.ScheduledAt, &run.Node, &run.Status, &run.Error,
+		&run.StartedAt, &finished, &duration); err != nil {
+		return nil, err
+	}
+	if finished.Valid {
+		run.FinishedAt = &finished.Time
+	}
+	if duration.Valid {
+		run.DurationMS = &duration.Int64
+	}
+	return &run, nil
+}
+
+func (r *taskRunRepository) Start(task string, scheduledAt time.Time, node string) (*model.TaskRun, error) {
+	run, err := scanTaskRun(r.db.QueryRowContext(context.Background(), `
+		INSERT INTO task_runs (task, scheduled_at, node, started_at) VALUES ($1, $2, $3, $4)
+		ON CONFLICT (task, scheduled_at) DO NOTHING
+		RETURNING `+taskRunColumns, task, scheduledAt.UTC(), node, time.Now().UTC()))
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	return run, err
+}
+
+func (r *taskRunRepository) Finish(id uuid.UUID, errMsg string) error {
+	status := model.TaskSucceeded
+	if errMsg != "" {
+		status = model.TaskFailed
+	}
+	// started_at is stored in UTC, so the duration is computed against UTC as well
+	_, err := r.db.ExecContext(context.Background(), `
+		UPDATE task_runs SET status = $2, error = NULLIF($3, ''), finished_at = $4,
+			duration_ms = (EXTRACT(EPOCH FROM ($4 - started_at)) * 1000)::BIGINT
+		WHERE id = $1`, id, status, errMsg, time.Now().UTC())
+	return err
+}
+
+func (r *taskRunRepository) List(task string, limit int) ([]model.TaskRun, error) {
+	rows, err := r.db.QueryContext(context.Background(), `
+		SELECT `+taskRunColumns+` FROM task_runs
+		WHERE $1 = '' OR task = $1
+		ORDER BY started_at DESC
+		LIMIT $2`, task, limit)
+	if err != nil {
+		return nil, err
+	}
+	defer func() { _ = rows.Close() }()
+
+	runs := []model.TaskRun{}
+	for rows.Next() {
+		run, err := scanTaskRun(rows)
+		if err != nil {
+			return nil, err
+		}
+		runs = append(runs, *run)
+	}
+	return runs, rows.Err()
+}
+
+func (r *taskRunRepository) Prune(before time.Time) (int64, error) {
+	res, err := r.db.ExecContext(context.Background(), `DELETE FROM task_runs WHERE started_at < $1`, before.UTC())
+	if err != nil {
+		return 0, err
+	}
+	return res.RowsAffected()
+}
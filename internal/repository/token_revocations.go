@@ -0,0 +1,58 @@
+package repository
+
+import (
+	"context"
+	"cruder/internal/model"
+	"database/sql"
+	"time"
+)
+
+type TokenRevocationRepository interface {
+	// Revoke rejects the tokens of subject, or of everyone if it is empty, issued before before.
+	// An earlier time than already recorded leaves the revocation as it is.
+	Revoke(subject string, before time.Time) (*model.TokenRevocation, error)
+	// List returns every revocation.
+	List() ([]model.TokenRevocation, error)
+}
+
+type tokenRevocationRepository struct {
+	db *sql.DB
+}
+
+func NewTokenRevocationRepository(db *sql.DB) TokenRevocationRepository {
+	return &tokenRevocationRepository{db: db}
+}
+
+func (r *tokenRevocationRepository) Revoke(subject string, before time.Time) (*model.TokenRevocation, error) {
+	var rev model.TokenRevocation
+	err := r.db.QueryRowContext(context.Background(), `
+		INSERT INTO token_revocations (subject, issued_before, updated_at) VALUES ($1, $2, $3)
+		ON CONFLICT (subject) DO UPDATE SET
+			issued_before = GREATEST(token_revocations.issued_before, EXCLUDED.issued_before),
+			updated_at = EXCLUDED.updated_at
+		RETURNING subject, issued_before, updated_at`, subject, before.UTC(), time.Now().UTC()).
+		Scan(&rev.Subject, &rev.IssuedBefore, &rev.UpdatedAt)
+	if err != nil {
+		return nil, err
+	}
+	return &rev, nil
+}
+
+func (r *tokenRevocationRepository) List() ([]model.TokenRevocation, error) {
+	rows, err := r.db.QueryContext(context.Background(),
+		`SELECT subject, issued_before, updated_at FROM token_revocations ORDER BY subject`)
+	if err != nil {
+		return nil, err
+	}
+	defer func() { _ = rows.Close() }()
+
+	revocations := []model.TokenRevocation{}
+	for rows.Next() {
+		var rev model.TokenRevocation
+		if err := rows.Scan(&rev.Subject, &rev.IssuedBefore, &rev.UpdatedAt); err != nil {
+			return nil, err
+		}
+		revocations = append(revocations, rev)
+	}
+	return revocations, rows.Err()
+}
@@ -0,0 +1,29 @@
+package repository
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestTokenRevocationRepository_Revoke(t *testing.T) {
+	revocations := NewTokenRevocationRepository(testDB(t))
+	now := time.Now().UTC().Truncate(time.Microsecond)
+
+	_, err := revocations.Revoke("ci", now)
+	require.NoError(t, err)
+	rev, err := revocations.Revoke("ci", now.Add(-time.Hour))
+	require.NoError(t, err)
+	assert.True(t, rev.IssuedBefore.Equal(now), "an earlier time leaves the revocation as it is")
+	rev, err = revocations.Revoke("", now.Add(time.Minute))
+	require.NoError(t, err)
+	assert.True(t, rev.IssuedBefore.Equal(now.Add(time.Minute)))
+
+	all, err := revocations.List()
+	require.NoError(t, err)
+	require.Len(t, all, 2)
+	assert.Equal(t, "", all[0].Subject, "everyone's")
+	assert.Equal(t, "ci", all[1].Subject)
+}
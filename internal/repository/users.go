@@ -7,6 +7,7 @@ import (
 	"database/sql"
 	"fmt"
 	"strings"
+	"time"
 
 	"github.com/google/uuid"
 	"github.com/lib/pq"
@@ -14,50 +15,200 @@ import (
 	stdErrors "errors"
 )
 
+// UserRepository stores the users of one organization: every query is filtered by it, and
+// usernames and email addresses are unique within it. The repository returned by
+// NewUserRepository works in model.DefaultOrganizationID; InOrganization switches.
 type UserRepository interface {
-	GetAll() ([]model.User, error)
-	GetByUsername(username string) (*model.User, error)
-	GetByID(id uuid.UUID) (*model.User, error)
+	// InOrganization returns the repository for the users of org, on the same storage.
+	InOrganization(org uuid.UUID) UserRepository
+
+	// fields limits the columns read; attributes outside it are left zero. Nil reads everything.
+	GetAll(fields model.UserFields) ([]model.User, error)
+	Search(query string, fields model.UserFields) ([]model.User, error)
+	// StreamAll and StreamSearch call fn for each row as it is read instead of collecting
+	// a slice, so exports run in constant memory. They stop at the first error fn returns.
+	StreamAll(fields model.UserFields, fn func(*model.User) error) error
+	StreamSearch(query string, fields model.UserFields, fn func(*model.User) error) error
+	List(params model.ListUsersParams) ([]model.User, error)
+	GetByUsername(username string, fields model.UserFields) (*model.User, error)
+	GetByEmail(email string, fields model.UserFields) (*model.User, error)
+	GetByID(id uuid.UUID, fields model.UserFields) (*model.User, error)
+	GetByIDs(ids []uuid.UUID) ([]model.User, error)
 	Create(req *model.CreateUserRequest) (*model.User, error)
+	// Update records a change of username in the username history, with req.Actor, in the same transaction.
 	Update(id uuid.UUID, req *model.UpdateUserRequest) (*model.User, error)
 	Delete(id uuid.UUID) error
+
+	// UsernameHistory returns the user's renames, newest first.
+	UsernameHistory(id uuid.UUID) ([]model.UsernameChange, error)
+	// GetFormerUsername returns the latest rename away from username made at or after since,
+	// or ErrUserNotFound if there is none.
+	GetFormerUsername(username string, since time.Time) (*model.UsernameChange, error)
+
+	// SetStatus moves the user from change.OldStatus to change.NewStatus and records change in the
+	// status history, with ChangedAt set to the new updated_at, in the same transaction. If the
+	// user's status is no longer OldStatus, it returns a StatusTransitionError from the current one.
+	SetStatus(id uuid.UUID, change *model.UserStatusChange) (*model.User, error)
+	// StatusHistory returns the user's status changes, newest first.
+	StatusHistory(id uuid.UUID) ([]model.UserStatusChange, error)
+
+	// GetCredentials finds the user whose username or email is login, with their password hash.
+	GetCredentials(login string) (*model.Credentials, error)
+	GetCredentialsByID(id uuid.UUID) (*model.Credentials, error)
+	// SetPasswordHash stores a new password hash; the user's representation (and updated_at) is unchanged.
+	SetPasswordHash(id uuid.UUID, hash string) error
+	// MarkEmailVerified sets email_verified_at if the user's address is still email, and returns
+	// ErrUserNotFound otherwise. updated_at is unchanged.
+	MarkEmailVerified(id uuid.UUID, email string, at time.Time) error
 }
 
 type userRepository struct {
-	db *sql.DB
+	db  *sql.DB
+	org uuid.UUID
 }
 
 func NewUserRepository(db *sql.DB) UserRepository {
-	return &userRepository{db: db}
+	return &userRepository{db: db, org: model.DefaultOrganizationID}
+}
+
+func (r *userRepository) InOrganization(org uuid.UUID) UserRepository {
+	return &userRepository{db: r.db, org: model.OrganizationOrDefault(org)}
 }
 
-func (r *userRepository) GetAll() ([]model.User, error) {
-	rows, err := r.db.QueryContext(context.Background(), `SELECT id, username, email, full_name, created_at, updated_at FROM users`)
+func (r *userRepository) GetAll(fields model.UserFields) ([]model.User, error) {
+	// Safe: column names come from the model.UserFieldNames allow-list
+	rows, err := r.db.QueryContext(context.Background(), `SELECT `+userColumns(fields)+` FROM users WHERE organization_id = $1`, r.org)
 	if err != nil {
 		return nil, err
 	}
 	defer func() { _ = rows.Close() }() // Ignore error in defer - rows will be closed automatically
 
+	return scanUsers(rows, fields)
+}
+
+// Search returns users whose username, email or full name contains query (case-insensitive).
+// LIKE wildcards in query are escaped so they match literally.
+func (r *userRepository) Search(query string, fields model.UserFields) ([]model.User, error) {
 	var users []model.User
-	for rows.Next() {
-		var u model.User
-		if err := rows.Scan(&u.ID, &u.Username, &u.Email, &u.FullName, &u.CreatedAt, &u.UpdatedAt); err != nil {
-			return nil, err
-		}
-		users = append(users, u)
+	err := r.StreamSearch(query, fields, func(u *model.User) error {
+		users = append(users, *u)
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return users, nil
+}
+
+func (r *userRepository) StreamAll(fields model.UserFields, fn func(*model.User) error) error {
+	rows, err := r.db.QueryContext(context.Background(), `SELECT `+userColumns(fields)+` FROM users WHERE organization_id = $1 ORDER BY id`, r.org)
+	if err != nil {
+		return err
+	}
+	defer func() { _ = rows.Close() }()
+
+	return streamUsers(rows, fields, fn)
+}
+
+func (r *userRepository) StreamSearch(query string, fields model.UserFields, fn func(*model.User) error) error {
+	pattern := "%" + likeEscaper.Replace(query) + "%"
+
+	rows, err := r.db.QueryContext(context.Background(), `
+		SELECT `+userColumns(fields)+`
+		FROM users
+		WHERE organization_id = $2 AND (username ILIKE $1 OR email ILIKE $1 OR full_name ILIKE $1)
+		ORDER BY username
+	`, pattern, r.org)
+	if err != nil {
+		return err
 	}
+	defer func() { _ = rows.Close() }()
+
+	return streamUsers(rows, fields, fn)
+}
 
-	if err := rows.Err(); err != nil {
+// List returns up to params.Limit users with IDs greater than params.After, ordered by ID.
+// Keyset pagination keeps every page an index range scan, regardless of how deep the caller pages.
+func (r *userRepository) List(params model.ListUsersParams) ([]model.User, error) {
+	var after interface{}
+	if params.After != nil {
+		after = *params.After
+	}
+
+	rows, err := r.db.QueryContext(context.Background(), `
+		SELECT `+userColumns(params.Fields)+`
+		FROM users
+		WHERE organization_id = $3 AND ($1::uuid IS NULL OR id > $1::uuid) AND ($4 = '' OR status = $4)
+		ORDER BY id
+		LIMIT $2
+	`, after, params.Limit, r.org, string(params.Status))
+	if err != nil {
 		return nil, err
 	}
+	defer func() { _ = rows.Close() }()
+
+	return scanUsers(rows, params.Fields)
+}
+
+var likeEscaper = strings.NewReplacer(`\`, `\\`, `%`, `\%`, `_`, `\_`)
+
+// userColumns is the select list for fields. id is always read because callers page and key on it,
+// and organization_id so the user says where it belongs.
+func userColumns(fields model.UserFields) string {
+	columns := []string{"id", "organization_id"}
+	for _, name := range model.UserFieldNames[1:] {
+		if fields.Has(name) {
+			columns = append(columns, name)
+		}
+	}
+	return strings.Join(columns, ", ")
+}
 
+// userScanTargets returns the destinations for a row selected with userColumns(fields).
+func userScanTargets(u *model.User, fields model.UserFields) []any {
+	targets := []any{&u.ID, &u.OrganizationID}
+	all := map[string]any{"username": &u.Username, "email": &u.Email, "full_name": &u.FullName, "status": &u.Status, "created_at": &u.CreatedAt, "updated_at": &u.UpdatedAt,
+		"email_verified_at": &u.EmailVerifiedAt}
+	for _, name := range model.UserFieldNames[1:] {
+		if fields.Has(name) {
+			targets = append(targets, all[name])
+		}
+	}
+	return targets
+}
+
+func scanUsers(rows *sql.Rows, fields model.UserFields) ([]model.User, error) {
+	var users []model.User
+	err := streamUsers(rows, fields, func(u *model.User) error {
+		users = append(users, *u)
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
 	return users, nil
 }
 
-func (r *userRepository) GetByUsername(username string) (*model.User, error) {
+// streamUsers scans rows one at a time into a single reused User and hands it to fn.
+// fn must copy the user if it keeps it.
+func streamUsers(rows *sql.Rows, fields model.UserFields, fn func(*model.User) error) error {
 	var u model.User
-	if err := r.db.QueryRowContext(context.Background(), `SELECT id, username, email, full_name, created_at, updated_at FROM users WHERE username = $1`, username).
-		Scan(&u.ID, &u.Username, &u.Email, &u.FullName, &u.CreatedAt, &u.UpdatedAt); err != nil {
+	targets := userScanTargets(&u, fields)
+	for rows.Next() {
+		if err := rows.Scan(targets...); err != nil {
+			return err
+		}
+		if err := fn(&u); err != nil {
+			return err
+		}
+	}
+	return rows.Err()
+}
+
+func (r *userRepository) GetByUsername(username string, fields model.UserFields) (*model.User, error) {
+	var u model.User
+	if err := r.db.QueryRowContext(context.Background(), `SELECT `+userColumns(fields)+` FROM users WHERE organization_id = $1 AND username = $2`, r.org, username).
+		Scan(userScanTargets(&u, fields)...); err != nil {
 		if err == sql.ErrNoRows {
 			// translate storage errors to domain errors
 			return nil, errors.ErrUserNotFound
@@ -67,10 +218,22 @@ func (r *userRepository) GetByUsername(username string) (*model.User, error) {
 	return &u, nil
 }
 
-func (r *userRepository) GetByID(id uuid.UUID) (*model.User, error) {
+func (r *userRepository) GetByEmail(email string, fields model.UserFields) (*model.User, error) {
+	var u model.User
+	if err := r.db.QueryRowContext(context.Background(), `SELECT `+userColumns(fields)+` FROM users WHERE organization_id = $1 AND email = $2`, r.org, email).
+		Scan(userScanTargets(&u, fields)...); err != nil {
+		if err == sql.ErrNoRows {
+			return nil, errors.ErrUserNotFound
+		}
+		return nil, err
+	}
+	return &u, nil
+}
+
+func (r *userRepository) GetByID(id uuid.UUID, fields model.UserFields) (*model.User, error) {
 	var u model.User
-	if err := r.db.QueryRowContext(context.Background(), `SELECT id, username, email, full_name, created_at, updated_at FROM users WHERE id = $1`, id).
-		Scan(&u.ID, &u.Username, &u.Email, &u.FullName, &u.CreatedAt, &u.UpdatedAt); err != nil {
+	if err := r.db.QueryRowContext(context.Background(), `SELECT `+userColumns(fields)+` FROM users WHERE organization_id = $1 AND id = $2`, r.org, id).
+		Scan(userScanTargets(&u, fields)...); err != nil {
 		if err == sql.ErrNoRows {
 			// translate storage errors to domain errors
 			return nil, errors.ErrUserNotFound
@@ -80,43 +243,65 @@ func (r *userRepository) GetByID(id uuid.UUID) (*model.User, error) {
 	return &u, nil
 }
 
+// GetByIDs returns the users among ids that exist, in no particular order. Missing IDs are simply absent.
+func (r *userRepository) GetByIDs(ids []uuid.UUID) ([]model.User, error) {
+	rows, err := r.db.QueryContext(context.Background(), `SELECT `+userColumns(nil)+` FROM users WHERE organization_id = $1 AND id = ANY($2)`, r.org, pq.Array(ids))
+	if err != nil {
+		return nil, err
+	}
+	defer func() { _ = rows.Close() }()
+
+	return scanUsers(rows, nil)
+}
+
 func (r *userRepository) Create(req *model.CreateUserRequest) (*model.User, error) {
 	var user model.User
 
 	query := `
-		INSERT INTO users (username, email, full_name, created_at, updated_at)
-		VALUES ($1, $2, $3, CURRENT_TIMESTAMP, CURRENT_TIMESTAMP)
-		RETURNING id, username, email, full_name, created_at, updated_at
+		INSERT INTO users (organization_id, username, email, full_name, status, created_at, updated_at)
+		VALUES ($1, $2, $3, $4, COALESCE(NULLIF($5, ''), 'active'), CURRENT_TIMESTAMP, CURRENT_TIMESTAMP)
+		RETURNING ` + userColumns(nil) + `
 	`
 
 	err := r.db.QueryRowContext(
 		context.Background(),
 		query,
+		r.org,
 		req.Username,
 		req.Email,
 		req.FullName,
-	).Scan(&user.ID, &user.Username, &user.Email, &user.FullName, &user.CreatedAt, &user.UpdatedAt)
+		string(req.Status),
+	).Scan(userScanTargets(&user, nil)...)
 
 	if err != nil {
-		// map PostgreSQL unique constraint violations to domain errors ErrUsernameExists or ErrEmailExists
-		var pqErr *pq.Error
-		if stdErrors.As(err, &pqErr) {
-			// 23505 is the PostgreSQL error code for unique_violation
-			if pqErr.Code == "23505" {
-				if strings.Contains(pqErr.Message, "username") {
-					return nil, errors.ErrUsernameExists
-				}
-				if strings.Contains(pqErr.Message, "email") {
-					return nil, errors.ErrEmailExists
-				}
-			}
-		}
-		return nil, err
+		return nil, uniqueViolation(err)
 	}
 
 	return &user, nil
 }
 
+// Unique constraints of the users table (see migrations/20260209000000_create_organizations_table.sql)
+const (
+	usernameConstraint = "users_organization_username_key"
+	emailConstraint    = "users_organization_email_key"
+)
+
+// uniqueViolation maps PostgreSQL unique constraint violations to domain errors ErrUsernameExists
+// or ErrEmailExists by constraint name, and returns any other error unchanged.
+func uniqueViolation(err error) error {
+	var pqErr *pq.Error
+	// 23505 is the PostgreSQL error code for unique_violation
+	if stdErrors.As(err, &pqErr) && pqErr.Code == "23505" {
+		switch pqErr.Constraint {
+		case usernameConstraint:
+			return errors.ErrUsernameExists
+		case emailConstraint:
+			return errors.ErrEmailExists
+		}
+	}
+	return err
+}
+
 func (r *userRepository) Update(id uuid.UUID, req *model.UpdateUserRequest) (*model.User, error) {
 	// Build dynamic UPDATE query based on provided fields
 	updates := []string{}
@@ -129,7 +314,8 @@ func (r *userRepository) Update(id uuid.UUID, req *model.UpdateUserRequest) (*mo
 		argPosition++
 	}
 	if req.Email != nil {
-		updates = append(updates, fmt.Sprintf("email = $%d", argPosition))
+		// A new address is unverified; setting the same one again keeps the verification
+		updates = append(updates, fmt.Sprintf("email_verified_at = CASE WHEN email = $%[1]d THEN email_verified_at END, email = $%[1]d", argPosition))
 		args = append(args, *req.Email)
 		argPosition++
 	}
@@ -141,57 +327,244 @@ func (r *userRepository) Update(id uuid.UUID, req *model.UpdateUserRequest) (*mo
 
 	// Empty update (no fields provided) - fetch and return existing user
 	if len(updates) == 0 {
-		return r.GetByID(id)
+		return r.GetByID(id, nil)
 	}
 
 	updates = append(updates, "updated_at = CURRENT_TIMESTAMP")
-	args = append(args, id)
+	args = append(args, id, r.org)
+
+	ctx := context.Background()
+	tx, err := r.db.BeginTx(ctx, nil)
+	if err != nil {
+		return nil, err
+	}
+	defer func() { _ = tx.Rollback() }()
+
+	var oldUsername string
+	if req.Username != nil {
+		// Locks the row, so concurrent renames each record the name they actually replaced
+		err := tx.QueryRowContext(ctx, `SELECT username FROM users WHERE organization_id = $1 AND id = $2 FOR UPDATE`, r.org, id).Scan(&oldUsername)
+		if err == sql.ErrNoRows {
+			return nil, errors.ErrUserNotFound
+		}
+		if err != nil {
+			return nil, err
+		}
+	}
 
 	// Safe: Using parameterized queries ($1, $2) - values go through args array
 	query := fmt.Sprintf(`
 		UPDATE users
 		SET %s
-		WHERE id = $%d
-		RETURNING id, username, email, full_name, created_at, updated_at
-	`, strings.Join(updates, ", "), argPosition) // #nosec G201
+		WHERE id = $%d AND organization_id = $%d
+		RETURNING %s
+	`, strings.Join(updates, ", "), argPosition, argPosition+1, userColumns(nil)) // #nosec G201
 
 	var user model.User
-	err := r.db.QueryRowContext(
-		context.Background(),
+	err = tx.QueryRowContext(
+		ctx,
 		query,
 		args...,
-	).Scan(&user.ID, &user.Username, &user.Email, &user.FullName, &user.CreatedAt, &user.UpdatedAt)
+	).Scan(userScanTargets(&user, nil)...)
 
 	if err != nil {
 		// User doesn't exist - UPDATE affected 0 rows
 		if err == sql.ErrNoRows {
 			return nil, errors.ErrUserNotFound
 		}
+		return nil, uniqueViolation(err)
+	}
+
+	if req.Username != nil && user.Username != oldUsername {
+		_, err := tx.ExecContext(ctx, `
+			INSERT INTO username_history (user_id, old_username, new_username, actor, changed_at)
+			VALUES ($1, $2, $3, $4, $5)
+		`, id, oldUsername, user.Username, req.Actor, user.UpdatedAt)
+		if err != nil {
+			return nil, err
+		}
+	}
+	if err := tx.Commit(); err != nil {
+		return nil, err
+	}
+
+	return &user, nil
+}
+
+func (r *userRepository) UsernameHistory(id uuid.UUID) ([]model.UsernameChange, error) {
+	rows, err := r.db.QueryContext(context.Background(), `
+		SELECT h.user_id, h.old_username, h.new_username, h.actor, h.changed_at
+		FROM username_history h
+		JOIN users u ON u.id = h.user_id
+		WHERE h.user_id = $1 AND u.organization_id = $2
+		ORDER BY h.changed_at DESC, h.id
+	`, id, r.org)
+	if err != nil {
+		return nil, err
+	}
+	defer func() { _ = rows.Close() }()
+
+	changes := []model.UsernameChange{}
+	for rows.Next() {
+		var c model.UsernameChange
+		if err := rows.Scan(&c.UserID, &c.OldUsername, &c.NewUsername, &c.Actor, &c.ChangedAt); err != nil {
+			return nil, err
+		}
+		changes = append(changes, c)
+	}
+	return changes, rows.Err()
+}
+
+func (r *userRepository) GetFormerUsername(username string, since time.Time) (*model.UsernameChange, error) {
+	var c model.UsernameChange
+	err := r.db.QueryRowContext(context.Background(), `
+		SELECT h.user_id, h.old_username, h.new_username, h.actor, h.changed_at
+		FROM username_history h
+		JOIN users u ON u.id = h.user_id
+		WHERE h.old_username = $1 AND h.changed_at >= $2 AND u.organization_id = $3
+		ORDER BY h.changed_at DESC
+		LIMIT 1
+	`, username, since.UTC(), r.org).Scan(&c.UserID, &c.OldUsername, &c.NewUsername, &c.Actor, &c.ChangedAt)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return nil, errors.ErrUserNotFound
+		}
+		return nil, err
+	}
+	return &c, nil
+}
+
+func (r *userRepository) SetStatus(id uuid.UUID, change *model.UserStatusChange) (*model.User, error) {
+	ctx := context.Background()
+	tx, err := r.db.BeginTx(ctx, nil)
+	if err != nil {
+		return nil, err
+	}
+	defer func() { _ = tx.Rollback() }()
 
-		var pqErr *pq.Error
-		if stdErrors.As(err, &pqErr) {
-			if pqErr.Code == "23505" {
-				if strings.Contains(pqErr.Message, "username") {
-					return nil, errors.ErrUsernameExists
-				}
-				if strings.Contains(pqErr.Message, "email") {
-					return nil, errors.ErrEmailExists
-				}
-			}
+	// Only moves the user if nobody changed their status since the caller checked the transition
+	var user model.User
+	err = tx.QueryRowContext(ctx, `
+		UPDATE users SET status = $3, updated_at = CURRENT_TIMESTAMP
+		WHERE id = $1 AND organization_id = $2 AND status = $4
+		RETURNING `+userColumns(nil), id, r.org, string(change.NewStatus), string(change.OldStatus)).
+		Scan(userScanTargets(&user, nil)...)
+	if err == sql.ErrNoRows {
+		var current string
+		err := tx.QueryRowContext(ctx, `SELECT status FROM users WHERE id = $1 AND organization_id = $2`, id, r.org).Scan(&current)
+		if err == sql.ErrNoRows {
+			return nil, errors.ErrUserNotFound
+		}
+		if err != nil {
+			return nil, err
 		}
+		return nil, &errors.StatusTransitionError{From: current, To: string(change.NewStatus)}
+	}
+	if err != nil {
 		return nil, err
 	}
 
+	change.ChangedAt = user.UpdatedAt
+	_, err = tx.ExecContext(ctx, `
+		INSERT INTO user_status_history (user_id, old_status, new_status, reason, actor, changed_at)
+		VALUES ($1, $2, $3, $4, $5, $6)
+	`, id, string(change.OldStatus), string(change.NewStatus), change.Reason, change.Actor, change.ChangedAt)
+	if err != nil {
+		return nil, err
+	}
+	if err := tx.Commit(); err != nil {
+		return nil, err
+	}
 	return &user, nil
 }
 
+func (r *userRepository) StatusHistory(id uuid.UUID) ([]model.UserStatusChange, error) {
+	rows, err := r.db.QueryContext(context.Background(), `
+		SELECT h.user_id, h.old_status, h.new_status, h.reason, h.actor, h.changed_at
+		FROM user_status_history h
+		JOIN users u ON u.id = h.user_id
+		WHERE h.user_id = $1 AND u.organization_id = $2
+		ORDER BY h.changed_at DESC, h.id
+	`, id, r.org)
+	if err != nil {
+		return nil, err
+	}
+	defer func() { _ = rows.Close() }()
+
+	changes := []model.UserStatusChange{}
+	for rows.Next() {
+		var c model.UserStatusChange
+		if err := rows.Scan(&c.UserID, &c.OldStatus, &c.NewStatus, &c.Reason, &c.Actor, &c.ChangedAt); err != nil {
+			return nil, err
+		}
+		changes = append(changes, c)
+	}
+	return changes, rows.Err()
+}
+
+func (r *userRepository) GetCredentials(login string) (*model.Credentials, error) {
+	// A username cannot contain "@", so login matches at most one user of the organization
+	return r.getCredentials(`(username = $2 OR email = $2)`, login)
+}
+
+func (r *userRepository) GetCredentialsByID(id uuid.UUID) (*model.Credentials, error) {
+	return r.getCredentials(`id = $2`, id)
+}
+
+func (r *userRepository) getCredentials(where string, arg any) (*model.Credentials, error) {
+	var c model.Credentials
+	err := r.db.QueryRowContext(context.Background(), `
+		SELECT `+userColumns(nil)+`, COALESCE(password_hash, '')
+		FROM users WHERE organization_id = $1 AND `+where, r.org, arg).
+		Scan(append(userScanTargets(&c.User, nil), &c.PasswordHash)...)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return nil, errors.ErrUserNotFound
+		}
+		return nil, err
+	}
+	return &c, nil
+}
+
+func (r *userRepository) SetPasswordHash(id uuid.UUID, hash string) error {
+	res, err := r.db.ExecContext(context.Background(),
+		`UPDATE users SET password_hash = $2, password_changed_at = CURRENT_TIMESTAMP WHERE id = $1 AND organization_id = $3`, id, hash, r.org)
+	if err != nil {
+		return err
+	}
+	n, err := res.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if n == 0 {
+		return errors.ErrUserNotFound
+	}
+	return nil
+}
+
+func (r *userRepository) MarkEmailVerified(id uuid.UUID, email string, at time.Time) error {
+	res, err := r.db.ExecContext(context.Background(),
+		`UPDATE users SET email_verified_at = $3 WHERE id = $1 AND email = $2 AND organization_id = $4`, id, email, at.UTC(), r.org)
+	if err != nil {
+		return err
+	}
+	n, err := res.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if n == 0 {
+		return errors.ErrUserNotFound
+	}
+	return nil
+}
+
 // Delete removes a user by ID. Returns ErrUserNotFound if the user doesn't exist.
 // This is the "informative" approach - the repository reports facts, not policy.
 // The controller layer decides whether to treat non-existence as idempotent or not.
 func (r *userRepository) Delete(id uuid.UUID) error {
-	query := `DELETE FROM users WHERE id = $1`
+	query := `DELETE FROM users WHERE id = $1 AND organization_id = $2`
 
-	result, err := r.db.ExecContext(context.Background(), query, id)
+	result, err := r.db.ExecContext(context.Background(), query, id, r.org)
 	if err != nil {
 		return err
 	}
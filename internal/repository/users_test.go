@@ -0,0 +1,117 @@
+package repository
+
+import (
+	"cruder/internal/errors"
+	"cruder/internal/model"
+	"fmt"
+	"testing"
+
+	"github.com/google/uuid"
+	"github.com/lib/pq"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestUniqueViolation(t *testing.T) {
+	violation := func(constraint string) error {
+		// The message names the constraint and the key, which may contain anything
+		return fmt.Errorf("insert: %w", &pq.Error{Code: "23505", Constraint: constraint,
+			Message: `duplicate key value violates unique constraint "` + constraint + `"`})
+	}
+
+	assert.ErrorIs(t, uniqueViolation(violation(usernameConstraint)), errors.ErrUsernameExists)
+	assert.ErrorIs(t, uniqueViolation(violation(emailConstraint)), errors.ErrEmailExists)
+
+	other := violation("users_pkey")
+	assert.Equal(t, other, uniqueViolation(other), "other constraints are not user conflicts")
+	notUnique := &pq.Error{Code: "23503", Constraint: usernameConstraint}
+	assert.Equal(t, error(notUnique), uniqueViolation(notUnique))
+}
+
+func TestUserRepository_UniqueConstraints(t *testing.T) {
+	users := NewUserRepository(testDB(t))
+	alice := createTestUser(t, users, "alice")
+	bob := createTestUser(t, users, "bob")
+
+	_, err := users.Create(&model.CreateUserRequest{Username: "alice", Email: "other@example.com", FullName: "Other"})
+	assert.ErrorIs(t, err, errors.ErrUsernameExists)
+	_, err = users.Create(&model.CreateUserRequest{Username: "other", Email: alice.Email, FullName: "Other"})
+	assert.ErrorIs(t, err, errors.ErrEmailExists)
+
+	_, err = users.Update(bob.ID, &model.UpdateUserRequest{Email: &alice.Email})
+	assert.ErrorIs(t, err, errors.ErrEmailExists)
+}
+
+func TestUserRepository_UpdateRecordsRename(t *testing.T) {
+	users := NewUserRepository(testDB(t))
+	alice := createTestUser(t, users, "alice")
+	createTestUser(t, users, "bob")
+
+	renamed, err := users.Update(alice.ID, &model.UpdateUserRequest{Username: ptr("alicia"), Actor: "admin"})
+	require.NoError(t, err)
+	assert.Equal(t, "alicia", renamed.Username)
+
+	history, err := users.UsernameHistory(alice.ID)
+	require.NoError(t, err)
+	require.Len(t, history, 1)
+	assert.Equal(t, "alice", history[0].OldUsername)
+	assert.Equal(t, "alicia", history[0].NewUsername)
+	assert.Equal(t, "admin", history[0].Actor)
+	assert.True(t, renamed.UpdatedAt.Equal(history[0].ChangedAt), "recorded as of the update")
+
+	former, err := users.GetFormerUsername("alice", history[0].ChangedAt)
+	require.NoError(t, err)
+	assert.Equal(t, alice.ID, former.UserID)
+
+	// Neither keeping the name nor a rename that fails is recorded
+	_, err = users.Update(alice.ID, &model.UpdateUserRequest{Username: ptr("alicia"), FullName: ptr("Alicia"), Actor: "admin"})
+	require.NoError(t, err)
+	_, err = users.Update(alice.ID, &model.UpdateUserRequest{Username: ptr("bob"), Actor: "admin"})
+	assert.ErrorIs(t, err, errors.ErrUsernameExists)
+
+	history, err = users.UsernameHistory(alice.ID)
+	require.NoError(t, err)
+	assert.Len(t, history, 1)
+
+	_, err = users.Update(uuid.New(), &model.UpdateUserRequest{Username: ptr("carol"), Actor: "admin"})
+	assert.ErrorIs(t, err, errors.ErrUserNotFound)
+}
+
+func TestUserRepository_SetStatus(t *testing.T) {
+	users := NewUserRepository(testDB(t))
+	alice := createTestUser(t, users, "alice")
+
+	suspended, err := users.SetStatus(alice.ID, &model.UserStatusChange{
+		UserID: alice.ID, OldStatus: model.UserActive, NewStatus: model.UserSuspended, Reason: "abuse", Actor: "admin",
+	})
+	require.NoError(t, err)
+	assert.Equal(t, model.UserSuspended, suspended.Status)
+
+	// A caller that checked the transition against the old status loses the race
+	_, err = users.SetStatus(alice.ID, &model.UserStatusChange{
+		UserID: alice.ID, OldStatus: model.UserActive, NewStatus: model.UserDeactivated, Actor: "admin",
+	})
+	var transitionErr *errors.StatusTransitionError
+	require.ErrorAs(t, err, &transitionErr)
+	assert.Equal(t, string(model.UserSuspended), transitionErr.From)
+	assert.Equal(t, string(model.UserDeactivated), transitionErr.To)
+
+	current, err := users.GetByID(alice.ID, nil)
+	require.NoError(t, err)
+	assert.Equal(t, model.UserSuspended, current.Status)
+
+	history, err := users.StatusHistory(alice.ID)
+	require.NoError(t, err)
+	require.Len(t, history, 1, "only the change that happened is recorded")
+	assert.Equal(t, model.UserActive, history[0].OldStatus)
+	assert.Equal(t, model.UserSuspended, history[0].NewStatus)
+	assert.Equal(t, "abuse", history[0].Reason)
+	assert.True(t, suspended.UpdatedAt.Equal(history[0].ChangedAt))
+
+	_, err = users.SetStatus(uuid.New(), &model.UserStatusChange{OldStatus: model.UserActive, NewStatus: model.UserSuspended})
+	assert.ErrorIs(t, err, errors.ErrUserNotFound)
+}
+
+func ptr(s string) *string {
+	return &s
+}
@@ -0,0 +1,155 @@
+package rpc
+
+import (
+	"context"
+	"cruder/internal/auth"
+	"cruder/internal/errors"
+	"cruder/internal/model"
+	usersv1 "cruder/pkg/api/users/v1"
+	"net"
+	"strings"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/peer"
+	"google.golang.org/grpc/status"
+
+	stdErrors "errors"
+)
+
+// APIKeyMetadata is the metadata key carrying the API key, the gRPC counterpart of X-API-Key.
+const APIKeyMetadata = "x-api-key"
+
+// OrganizationMetadata names the organization an admin caller acts in, as auth.OrganizationHeader does over HTTP.
+const OrganizationMetadata = "x-organization"
+
+// AuthorizationMetadata carries "Bearer <access token>", as the Authorization header does over HTTP.
+const AuthorizationMetadata = "authorization"
+
+// Health checks and reflection stay open, like /health and /ready on the HTTP side.
+var unauthenticatedServices = []string{
+	"/grpc.health.v1.Health/",
+	"/grpc.reflection.v1.ServerReflection/",
+	"/grpc.reflection.v1alpha.ServerReflection/",
+}
+
+// writeMethods need the users:write scope; every other UserService method needs users:read.
+var writeMethods = map[string]bool{
+	usersv1.UserService_CreateUser_FullMethodName: true,
+	usersv1.UserService_UpdateUser_FullMethodName: true,
+	usersv1.UserService_DeleteUser_FullMethodName: true,
+}
+
+// authorize mirrors middleware.Authenticate and the route scopes: Unauthenticated if credentials are
+// missing or the access token is not valid, PermissionDenied if the API key is wrong or lacks the
+// method's scope, ResourceExhausted if the client sent too many wrong keys and must wait. Without
+// any API key configured nothing is checked (dev mode). It returns ctx carrying the caller and its
+// organization (see admit).
+func authorize(ctx context.Context, authenticator *auth.Authenticator, fullMethod string) (context.Context, error) {
+	for _, prefix := range unauthenticatedServices {
+		if strings.HasPrefix(fullMethod, prefix) {
+			return ctx, nil
+		}
+	}
+	md, _ := metadata.FromIncomingContext(ctx)
+	if !authenticator.Enabled() {
+		return admit(ctx, authenticator, auth.Anonymous, md)
+	}
+
+	var principal *model.Principal
+	if values := md.Get(AuthorizationMetadata); len(values) > 0 && strings.HasPrefix(values[0], "Bearer ") {
+		var err error
+		principal, err = authenticator.Bearer(strings.TrimSpace(strings.TrimPrefix(values[0], "Bearer ")))
+		if err != nil {
+			if auth.IsTokenRejected(err) {
+				return nil, status.Error(codes.Unauthenticated, err.Error())
+			}
+			return nil, status.Error(codes.Internal, "failed to verify access token")
+		}
+	} else {
+		values := md.Get(APIKeyMetadata)
+		if len(values) == 0 || values[0] == "" {
+			return nil, status.Error(codes.Unauthenticated, APIKeyMetadata+" or "+AuthorizationMetadata+" metadata is required")
+		}
+		var err error
+		principal, err = authenticator.APIKeyFrom(peerIP(ctx), values[0])
+		switch {
+		case stdErrors.Is(err, errors.ErrAuthThrottled):
+			return nil, status.Error(codes.ResourceExhausted, err.Error())
+		case stdErrors.Is(err, auth.ErrUnknownAPIKey):
+			return nil, status.Error(codes.PermissionDenied, "invalid API key")
+		case err != nil:
+			return nil, status.Error(codes.Internal, "failed to check API key")
+		}
+	}
+
+	scope := model.ScopeUsersRead
+	if writeMethods[fullMethod] {
+		scope = model.ScopeUsersWrite
+	}
+	if !principal.HasScope(scope) {
+		return nil, status.Error(codes.PermissionDenied, "the "+scope+" scope is required")
+	}
+	return admit(ctx, authenticator, principal, md)
+}
+
+// admit returns ctx carrying principal and the organization it acts in, like its HTTP counterpart
+// in the middleware package: PermissionDenied if the caller may not act in the organization named
+// in OrganizationMetadata and InvalidArgument if there is no such organization.
+func admit(ctx context.Context, authenticator *auth.Authenticator, principal *model.Principal, md metadata.MD) (context.Context, error) {
+	var requested string
+	if values := md.Get(OrganizationMetadata); len(values) > 0 {
+		requested = values[0]
+	}
+	org, err := authenticator.Organization(principal, requested)
+	switch {
+	case stdErrors.Is(err, auth.ErrOrganizationNotAllowed):
+		return nil, status.Error(codes.PermissionDenied, err.Error())
+	case stdErrors.Is(err, errors.ErrOrganizationNotFound) && requested != "":
+		return nil, status.Errorf(codes.InvalidArgument, "organization '%s' not found", requested)
+	case err != nil:
+		return nil, status.Error(codes.Internal, "failed to resolve organization")
+	}
+	return auth.WithOrganization(auth.WithPrincipal(ctx, principal), org), nil
+}
+
+// peerIP is the address the call came from, without the port; failed keys are counted against it.
+func peerIP(ctx context.Context) string {
+	p, ok := peer.FromContext(ctx)
+	if !ok || p.Addr == nil {
+		return ""
+	}
+	if host, _, err := net.SplitHostPort(p.Addr.String()); err == nil {
+		return host
+	}
+	return p.Addr.String()
+}
+
+func unaryAuthInterceptor(authenticator *auth.Authenticator) grpc.UnaryServerInterceptor {
+	return func(ctx context.Context, req any, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (any, error) {
+		ctx, err := authorize(ctx, authenticator, info.FullMethod)
+		if err != nil {
+			return nil, err
+		}
+		return handler(ctx, req)
+	}
+}
+
+// authorizedStream hands the handler the context carrying the caller.
+type authorizedStream struct {
+	grpc.ServerStream
+	ctx context.Context
+}
+
+func (s *authorizedStream) Context() context.Context { return s.ctx }
+
+func streamAuthInterceptor(authenticator *auth.Authenticator) grpc.StreamServerInterceptor {
+	return func(srv any, ss grpc.ServerStream, info *grpc.StreamServerInfo, handler grpc.StreamHandler) error {
+		ctx, err := authorize(ss.Context(), authenticator, info.FullMethod)
+		if err != nil {
+			return err
+		}
+		return handler(srv, &authorizedStream{ServerStream: ss, ctx: ctx})
+	}
+}
@@ -0,0 +1,28 @@
+package rpc
+
+import (
+	"cruder/internal/errors"
+
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+
+	stdErrors "errors"
+)
+
+// toStatus maps domain errors to gRPC status codes, the way the REST controllers map them to HTTP codes.
+func toStatus(err error) error {
+	switch {
+	case err == nil:
+		return nil
+	case stdErrors.Is(err, errors.ErrUserNotFound):
+		return status.Error(codes.NotFound, err.Error())
+	case stdErrors.Is(err, errors.ErrUsernameExists), stdErrors.Is(err, errors.ErrUsernameReserved), stdErrors.Is(err, errors.ErrEmailExists):
+		return status.Error(codes.AlreadyExists, err.Error())
+	case stdErrors.Is(err, errors.ErrStatusTransition):
+		return status.Error(codes.FailedPrecondition, err.Error())
+	case stdErrors.Is(err, errors.ErrInvalidInput):
+		return status.Error(codes.InvalidArgument, err.Error())
+	default:
+		return status.Error(codes.Internal, err.Error())
+	}
+}
@@ -0,0 +1,84 @@
+// Package rpc serves the users API over gRPC (see proto/users/v1/users.proto),
+// backed by the same service layer as the REST controllers.
+package rpc
+
+import (
+	"context"
+	"cruder/internal/auth"
+	"cruder/internal/events"
+	"cruder/internal/service"
+	usersv1 "cruder/pkg/api/users/v1"
+	"log/slog"
+	"time"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/health"
+	healthpb "google.golang.org/grpc/health/grpc_health_v1"
+	"google.golang.org/grpc/reflection"
+	"google.golang.org/grpc/status"
+)
+
+// Server bundles the gRPC server with its health service so shutdown can flip health first.
+type Server struct {
+	*grpc.Server
+	health *health.Server
+}
+
+// NewServer registers the UserService, the standard health checking protocol and server reflection.
+// Callers are identified by authenticator, like on the HTTP side. emailChanges may be nil.
+func NewServer(users service.UserService, emailChanges service.EmailChangeService, broker *events.Broker, authenticator *auth.Authenticator, logger *slog.Logger) *Server {
+	srv := grpc.NewServer(
+		grpc.ChainUnaryInterceptor(unaryLoggingInterceptor(logger), unaryAuthInterceptor(authenticator)),
+		grpc.ChainStreamInterceptor(streamAuthInterceptor(authenticator)),
+	)
+
+	usersv1.RegisterUserServiceServer(srv, &userServer{users: users, emailChanges: emailChanges, broker: broker})
+
+	healthServer := health.NewServer()
+	healthServer.SetServingStatus("", healthpb.HealthCheckResponse_SERVING)
+	healthServer.SetServingStatus(usersv1.UserService_ServiceDesc.ServiceName, healthpb.HealthCheckResponse_SERVING)
+	healthpb.RegisterHealthServer(srv, healthServer)
+
+	reflection.Register(srv)
+
+	return &Server{Server: srv, health: healthServer}
+}
+
+// Shutdown reports NOT_SERVING, then waits for in-flight RPCs until ctx expires.
+// Watch streams must already be ended (events.Broker.Close) or they hold GracefulStop open.
+func (s *Server) Shutdown(ctx context.Context) {
+	s.health.Shutdown()
+
+	done := make(chan struct{})
+	go func() {
+		s.GracefulStop()
+		close(done)
+	}()
+
+	select {
+	case <-done:
+	case <-ctx.Done():
+		s.Stop()
+	}
+}
+
+// unaryLoggingInterceptor logs each call in the same shape as middleware.RequestLogger.
+func unaryLoggingInterceptor(logger *slog.Logger) grpc.UnaryServerInterceptor {
+	return func(ctx context.Context, req any, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (any, error) {
+		start := time.Now()
+		resp, err := handler(ctx, req)
+
+		code := status.Code(err)
+		attrs := []any{
+			slog.String("method", info.FullMethod),
+			slog.String("code", code.String()),
+			slog.Duration("latency", time.Since(start)),
+		}
+		if err != nil {
+			logger.Warn("RPC completed", append(attrs, slog.String("error", err.Error()))...)
+		} else {
+			logger.Info("RPC completed", attrs...)
+		}
+		return resp, err
+	}
+}
@@ -0,0 +1,294 @@
+package rpc
+
+import (
+	"context"
+	"cruder/internal/auth"
+	"cruder/internal/errors"
+	"cruder/internal/events"
+	"cruder/internal/mail"
+	"cruder/internal/model"
+	"cruder/internal/repository/memory"
+	"cruder/internal/service"
+	"cruder/internal/token"
+	usersv1 "cruder/pkg/api/users/v1"
+	"io"
+	"log/slog"
+	"net"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials/insecure"
+	healthpb "google.golang.org/grpc/health/grpc_health_v1"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+	"google.golang.org/grpc/test/bufconn"
+	"google.golang.org/protobuf/types/known/fieldmaskpb"
+)
+
+const (
+	testAPIKey   = "test-key"
+	readerAPIKey = "reader-key"
+	// acmeAPIKey belongs to a read-write client of the acme organization
+	acmeAPIKey = "acme-key"
+)
+
+func newTestConn(t *testing.T) *grpc.ClientConn {
+	conn, _ := newTestServer(t)
+	return conn
+}
+
+// newTestServer serves the API to testAPIKey and to the read-only readerAPIKey, and returns the
+// token service that issues their access tokens.
+func newTestServer(t *testing.T) (*grpc.ClientConn, service.TokenService) {
+	t.Helper()
+	keys, err := token.GenerateKeySet()
+	require.NoError(t, err)
+	tokens := service.NewTokenService(memory.NewTokenRevocationRepository(), keys, service.TokenOptions{Issuer: "test"})
+	orgs := service.NewOrganizationService(memory.NewOrganizationRepository())
+	_, err = orgs.Create(&model.CreateOrganizationRequest{Slug: "acme", Name: "Acme"})
+	require.NoError(t, err)
+	authenticator := auth.NewAuthenticator([]auth.Client{
+		{ID: auth.DefaultClientID, Key: testAPIKey, Scopes: []string{model.ScopeAdmin}},
+		{ID: "reader", Key: readerAPIKey, Scopes: []string{model.ScopeUsersRead}},
+		{ID: "acme", Key: acmeAPIKey, Scopes: []string{model.ScopeUsersRead, model.ScopeUsersWrite}, Organization: "acme"},
+	}, tokens, nil, orgs)
+
+	logger := slog.New(slog.NewTextHandler(io.Discard, nil))
+	broker := events.NewBroker()
+	repo := memory.NewUserRepository()
+	users := service.WithEvents(service.NewUserService(repo), broker, logger)
+	emailChanges := service.NewEmailChangeService(memory.NewEmailChangeRepository(), users, repo, &mail.MemoryMailer{}, service.EmailChangeOptions{})
+	users = service.WithEmailChangeConfirmation(users, emailChanges, logger)
+	srv := NewServer(users, emailChanges, broker, authenticator, logger)
+
+	lis := bufconn.Listen(1 << 20)
+	go func() { _ = srv.Serve(lis) }()
+	t.Cleanup(func() {
+		broker.Close()
+		srv.Stop()
+	})
+
+	conn, err := grpc.NewClient("passthrough:///bufnet",
+		grpc.WithContextDialer(func(ctx context.Context, _ string) (net.Conn, error) { return lis.DialContext(ctx) }),
+		grpc.WithTransportCredentials(insecure.NewCredentials()))
+	require.NoError(t, err)
+	t.Cleanup(func() { _ = conn.Close() })
+	return conn, tokens
+}
+
+func authed() context.Context {
+	return metadata.AppendToOutgoingContext(context.Background(), APIKeyMetadata, testAPIKey)
+}
+
+func TestUserService_CRUDAndFieldMask(t *testing.T) {
+	client := usersv1.NewUserServiceClient(newTestConn(t))
+	ctx := authed()
+
+	created, err := client.CreateUser(ctx, &usersv1.CreateUserRequest{Username: "JDoe", Email: "jdoe@example.com", FullName: "John Doe"})
+	require.NoError(t, err)
+	assert.Equal(t, "jdoe", created.GetUsername())
+	assert.Equal(t, usersv1.User_STATUS_ACTIVE, created.GetStatus())
+	assert.Nil(t, created.GetEmailVerifyTime(), "not verified until the user follows the mailed link")
+
+	// Only full_name is in the mask, so the blank email must be ignored
+	updated, err := client.UpdateUser(ctx, &usersv1.UpdateUserRequest{
+		User:       &usersv1.User{Id: created.GetId(), FullName: "Johnny Doe"},
+		UpdateMask: &fieldmaskpb.FieldMask{Paths: []string{"full_name"}},
+	})
+	require.NoError(t, err)
+	assert.Equal(t, "Johnny Doe", updated.GetFullName())
+	assert.Equal(t, "jdoe@example.com", updated.GetEmail())
+
+	// A new address waits for confirmation, and GetUser shows it as pending
+	updated, err = client.UpdateUser(ctx, &usersv1.UpdateUserRequest{
+		User:       &usersv1.User{Id: created.GetId(), Email: "johnny@example.com"},
+		UpdateMask: &fieldmaskpb.FieldMask{Paths: []string{"email"}},
+	})
+	require.NoError(t, err)
+	assert.Equal(t, "jdoe@example.com", updated.GetEmail())
+	assert.Equal(t, "johnny@example.com", updated.GetPendingEmailChange().GetNewEmail())
+	fetched, err := client.GetUser(ctx, &usersv1.GetUserRequest{Id: created.GetId()})
+	require.NoError(t, err)
+	assert.Equal(t, "johnny@example.com", fetched.GetPendingEmailChange().GetNewEmail())
+
+	got, err := client.GetUserByUsername(ctx, &usersv1.GetUserByUsernameRequest{Username: "jdoe"})
+	require.NoError(t, err)
+	assert.Equal(t, created.GetId(), got.GetId())
+
+	_, err = client.DeleteUser(ctx, &usersv1.DeleteUserRequest{Id: created.GetId()})
+	require.NoError(t, err)
+	_, err = client.GetUser(ctx, &usersv1.GetUserRequest{Id: created.GetId()})
+	assert.Equal(t, codes.NotFound, status.Code(err))
+}
+
+func TestUserService_ErrorCodes(t *testing.T) {
+	client := usersv1.NewUserServiceClient(newTestConn(t))
+	ctx := authed()
+
+	_, err := client.CreateUser(ctx, &usersv1.CreateUserRequest{Username: "jdoe", Email: "jdoe@example.com", FullName: "John Doe"})
+	require.NoError(t, err)
+
+	_, err = client.CreateUser(ctx, &usersv1.CreateUserRequest{Username: "jdoe", Email: "x@example.com", FullName: "John Doe"})
+	assert.Equal(t, codes.AlreadyExists, status.Code(err))
+
+	_, err = client.CreateUser(ctx, &usersv1.CreateUserRequest{Username: "x", Email: "bad", FullName: "John Doe"})
+	assert.Equal(t, codes.InvalidArgument, status.Code(err))
+
+	_, err = client.GetUser(ctx, &usersv1.GetUserRequest{Id: "not-a-uuid"})
+	assert.Equal(t, codes.InvalidArgument, status.Code(err))
+
+	_, err = client.UpdateUser(ctx, &usersv1.UpdateUserRequest{
+		User:       &usersv1.User{Id: "00000000-0000-0000-0000-000000000001"},
+		UpdateMask: &fieldmaskpb.FieldMask{Paths: []string{"id"}},
+	})
+	assert.Equal(t, codes.InvalidArgument, status.Code(err))
+
+	// Status changes go through REST, but a disallowed one must not surface as Internal
+	err = toStatus(&errors.StatusTransitionError{From: "deactivated", To: "active"})
+	assert.Equal(t, codes.FailedPrecondition, status.Code(err))
+}
+
+func TestUserService_ListPagination(t *testing.T) {
+	client := usersv1.NewUserServiceClient(newTestConn(t))
+	ctx := authed()
+
+	for _, name := range []string{"alice", "bob", "carol"} {
+		_, err := client.CreateUser(ctx, &usersv1.CreateUserRequest{Username: name, Email: name + "@example.com", FullName: "Test User"})
+		require.NoError(t, err)
+	}
+
+	seen := 0
+	token := ""
+	for {
+		resp, err := client.ListUsers(ctx, &usersv1.ListUsersRequest{PageSize: 2, PageToken: token})
+		require.NoError(t, err)
+		seen += len(resp.GetUsers())
+		if resp.GetNextPageToken() == "" {
+			break
+		}
+		token = resp.GetNextPageToken()
+	}
+	assert.Equal(t, 3, seen)
+}
+
+func TestUserService_Auth(t *testing.T) {
+	conn, tokens := newTestServer(t)
+	client := usersv1.NewUserServiceClient(conn)
+
+	_, err := client.ListUsers(context.Background(), &usersv1.ListUsersRequest{})
+	assert.Equal(t, codes.Unauthenticated, status.Code(err))
+
+	wrong := metadata.AppendToOutgoingContext(context.Background(), APIKeyMetadata, "wrong")
+	_, err = client.ListUsers(wrong, &usersv1.ListUsersRequest{})
+	assert.Equal(t, codes.PermissionDenied, status.Code(err))
+
+	// A read-only key can list but not create
+	reader := metadata.AppendToOutgoingContext(context.Background(), APIKeyMetadata, readerAPIKey)
+	_, err = client.ListUsers(reader, &usersv1.ListUsersRequest{})
+	require.NoError(t, err)
+	_, err = client.CreateUser(reader, &usersv1.CreateUserRequest{Username: "jdoe", Email: "jdoe@example.com", FullName: "John Doe"})
+	assert.Equal(t, codes.PermissionDenied, status.Code(err))
+
+	// Access tokens go in the authorization metadata and carry their scopes
+	issued, err := tokens.Issue(&model.Principal{Subject: auth.DefaultClientID, Scopes: []string{model.ScopeAdmin}}, "users:write")
+	require.NoError(t, err)
+	bearer := metadata.AppendToOutgoingContext(context.Background(), AuthorizationMetadata, "Bearer "+issued.AccessToken)
+	_, err = client.CreateUser(bearer, &usersv1.CreateUserRequest{Username: "jdoe", Email: "jdoe@example.com", FullName: "John Doe"})
+	require.NoError(t, err)
+	_, err = client.ListUsers(bearer, &usersv1.ListUsersRequest{})
+	assert.Equal(t, codes.PermissionDenied, status.Code(err), "the token was narrowed to users:write")
+
+	invalid := metadata.AppendToOutgoingContext(context.Background(), AuthorizationMetadata, "Bearer not-a-token")
+	_, err = client.ListUsers(invalid, &usersv1.ListUsersRequest{})
+	assert.Equal(t, codes.Unauthenticated, status.Code(err))
+
+	// Health checks never require a key
+	resp, err := healthpb.NewHealthClient(conn).Check(context.Background(), &healthpb.HealthCheckRequest{})
+	require.NoError(t, err)
+	assert.Equal(t, healthpb.HealthCheckResponse_SERVING, resp.GetStatus())
+}
+
+func TestUserService_WatchUsers(t *testing.T) {
+	client := usersv1.NewUserServiceClient(newTestConn(t))
+	ctx, cancel := context.WithTimeout(authed(), 5*time.Second)
+	defer cancel()
+
+	stream, err := client.WatchUsers(ctx, &usersv1.WatchUsersRequest{})
+	require.NoError(t, err)
+
+	// The subscription is registered asynchronously; create until the first event arrives
+	received := make(chan *usersv1.UserEvent, 1)
+	go func() {
+		e, err := stream.Recv()
+		if err == nil {
+			received <- e
+		}
+	}()
+
+	var event *usersv1.UserEvent
+	for i := 0; event == nil; i++ {
+		name := "watcher" + string(rune('a'+i))
+		_, err := client.CreateUser(ctx, &usersv1.CreateUserRequest{Username: name, Email: name + "@example.com", FullName: "Watch Er"})
+		require.NoError(t, err)
+		select {
+		case event = <-received:
+		case <-time.After(50 * time.Millisecond):
+		}
+	}
+
+	assert.Equal(t, usersv1.UserEvent_TYPE_CREATED, event.GetType())
+	assert.Contains(t, event.GetUser().GetUsername(), "watcher")
+}
+
+func TestUserService_Organizations(t *testing.T) {
+	client := usersv1.NewUserServiceClient(newTestConn(t))
+	base, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	ctx := metadata.AppendToOutgoingContext(base, APIKeyMetadata, testAPIKey)
+	acme := metadata.AppendToOutgoingContext(base, APIKeyMetadata, acmeAPIKey)
+
+	theirs, err := client.CreateUser(acme, &usersv1.CreateUserRequest{Username: "jdoe", Email: "jdoe@example.com", FullName: "John Doe"})
+	require.NoError(t, err)
+	_, err = client.GetUser(ctx, &usersv1.GetUserRequest{Id: theirs.GetId()})
+	assert.Equal(t, codes.NotFound, status.Code(err))
+	got, err := client.GetUser(metadata.AppendToOutgoingContext(ctx, OrganizationMetadata, "acme"), &usersv1.GetUserRequest{Id: theirs.GetId()})
+	require.NoError(t, err)
+	assert.Equal(t, "jdoe", got.GetUsername())
+
+	reader := metadata.AppendToOutgoingContext(context.Background(), APIKeyMetadata, readerAPIKey, OrganizationMetadata, "acme")
+	_, err = client.ListUsers(reader, &usersv1.ListUsersRequest{})
+	assert.Equal(t, codes.PermissionDenied, status.Code(err))
+	_, err = client.ListUsers(metadata.AppendToOutgoingContext(ctx, OrganizationMetadata, "nowhere"), &usersv1.ListUsersRequest{})
+	assert.Equal(t, codes.InvalidArgument, status.Code(err))
+
+	// Watchers only hear about the users of their organization
+	stream, err := client.WatchUsers(acme, &usersv1.WatchUsersRequest{})
+	require.NoError(t, err)
+	received := make(chan *usersv1.UserEvent, 1)
+	go func() {
+		e, err := stream.Recv()
+		if err == nil {
+			received <- e
+		}
+	}()
+
+	var event *usersv1.UserEvent
+	for i := 0; event == nil; i++ {
+		for _, c := range []context.Context{ctx, acme} {
+			name := "watcher" + string(rune('a'+i))
+			_, err := client.CreateUser(c, &usersv1.CreateUserRequest{Username: name, Email: name + "@example.com", FullName: "Watch Er"})
+			require.NoError(t, err)
+		}
+		select {
+		case event = <-received:
+		case <-time.After(50 * time.Millisecond):
+		}
+	}
+	got, err = client.GetUser(acme, &usersv1.GetUserRequest{Id: event.GetUser().GetId()})
+	require.NoError(t, err, "the event is about a user of acme")
+	assert.Equal(t, event.GetUser().GetUsername(), got.GetUsername())
+}
@@ -0,0 +1,265 @@
+package rpc
+
+import (
+	"context"
+	"cruder/internal/auth"
+	"cruder/internal/errors"
+	"cruder/internal/events"
+	"cruder/internal/model"
+	"cruder/internal/service"
+	usersv1 "cruder/pkg/api/users/v1"
+	"encoding/base64"
+	"fmt"
+
+	"github.com/gin-gonic/gin/binding"
+	"github.com/google/uuid"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/types/known/emptypb"
+	"google.golang.org/protobuf/types/known/timestamppb"
+
+	stdErrors "errors"
+)
+
+// userServer implements usersv1.UserServiceServer on top of the same service.UserService as the REST API.
+type userServer struct {
+	usersv1.UnimplementedUserServiceServer
+	users service.UserService
+	// emailChanges fills in pending email changes on GetUser; nil leaves them out
+	emailChanges service.EmailChangeService
+	broker       *events.Broker
+}
+
+// in returns the users of the organization the caller acts in.
+func (s *userServer) in(ctx context.Context) service.UserService {
+	return s.users.InOrganization(auth.OrganizationFrom(ctx))
+}
+
+var userStatuses = map[model.UserStatus]usersv1.User_Status{
+	model.UserInvited:     usersv1.User_STATUS_INVITED,
+	model.UserActive:      usersv1.User_STATUS_ACTIVE,
+	model.UserSuspended:   usersv1.User_STATUS_SUSPENDED,
+	model.UserDeactivated: usersv1.User_STATUS_DEACTIVATED,
+}
+
+func toProtoUser(u *model.User) *usersv1.User {
+	user := &usersv1.User{
+		Id:         u.ID.String(),
+		Username:   u.Username,
+		Email:      u.Email,
+		FullName:   u.FullName,
+		CreateTime: timestamppb.New(u.CreatedAt),
+		UpdateTime: timestamppb.New(u.UpdatedAt),
+		Status:     userStatuses[u.Status],
+	}
+	if u.EmailVerifiedAt != nil {
+		user.EmailVerifyTime = timestamppb.New(*u.EmailVerifiedAt)
+	}
+	if p := u.PendingEmailChange; p != nil {
+		user.PendingEmailChange = &usersv1.PendingEmailChange{
+			NewEmail:    p.NewEmail,
+			RequestTime: timestamppb.New(p.RequestedAt),
+			ExpireTime:  timestamppb.New(p.ExpiresAt),
+		}
+	}
+	return user
+}
+
+func parseID(id string) (uuid.UUID, error) {
+	parsed, err := uuid.Parse(id)
+	if err != nil {
+		return uuid.Nil, status.Error(codes.InvalidArgument, "id must be a valid UUID")
+	}
+	return parsed, nil
+}
+
+// validate applies the same binding rules gin enforces on REST request bodies.
+func validate(req any) error {
+	if err := binding.Validator.ValidateStruct(req); err != nil {
+		return status.Error(codes.InvalidArgument, fmt.Sprintf("%v: %v", errors.ErrInvalidInput, err))
+	}
+	return nil
+}
+
+func (s *userServer) GetUser(ctx context.Context, req *usersv1.GetUserRequest) (*usersv1.User, error) {
+	id, err := parseID(req.GetId())
+	if err != nil {
+		return nil, err
+	}
+	user, err := s.in(ctx).GetByID(id, nil)
+	if err != nil {
+		return nil, toStatus(err)
+	}
+	if s.emailChanges != nil {
+		user.PendingEmailChange, err = s.emailChanges.InOrganization(auth.OrganizationFrom(ctx)).Pending(id)
+		if err != nil {
+			return nil, toStatus(err)
+		}
+	}
+	return toProtoUser(user), nil
+}
+
+func (s *userServer) GetUserByUsername(ctx context.Context, req *usersv1.GetUserByUsernameRequest) (*usersv1.User, error) {
+	user, err := s.in(ctx).GetByUsername(req.GetUsername(), nil)
+	if err != nil {
+		return nil, toStatus(err)
+	}
+	return toProtoUser(user), nil
+}
+
+// Page tokens are opaque to clients; internally they carry the last ID of the previous page.
+func encodePageToken(id uuid.UUID) string {
+	return base64.RawURLEncoding.EncodeToString([]byte(id.String()))
+}
+
+func decodePageToken(token string) (*uuid.UUID, error) {
+	if token == "" {
+		return nil, nil
+	}
+	raw, err := base64.RawURLEncoding.DecodeString(token)
+	if err != nil {
+		return nil, status.Error(codes.InvalidArgument, "invalid page_token")
+	}
+	id, err := uuid.ParseBytes(raw)
+	if err != nil {
+		return nil, status.Error(codes.InvalidArgument, "invalid page_token")
+	}
+	return &id, nil
+}
+
+func (s *userServer) ListUsers(ctx context.Context, req *usersv1.ListUsersRequest) (*usersv1.ListUsersResponse, error) {
+	after, err := decodePageToken(req.GetPageToken())
+	if err != nil {
+		return nil, err
+	}
+
+	pageSize := int(req.GetPageSize())
+	if pageSize == 0 {
+		pageSize = service.DefaultPageSize
+	}
+	users, err := s.in(ctx).List(model.ListUsersParams{Limit: pageSize, After: after})
+	if err != nil {
+		return nil, toStatus(err)
+	}
+
+	resp := &usersv1.ListUsersResponse{Users: make([]*usersv1.User, 0, len(users))}
+	for i := range users {
+		resp.Users = append(resp.Users, toProtoUser(&users[i]))
+	}
+	if len(users) == pageSize {
+		resp.NextPageToken = encodePageToken(users[len(users)-1].ID)
+	}
+	return resp, nil
+}
+
+func (s *userServer) CreateUser(ctx context.Context, req *usersv1.CreateUserRequest) (*usersv1.User, error) {
+	createReq := model.CreateUserRequest{
+		Username: req.GetUsername(),
+		Email:    req.GetEmail(),
+		FullName: req.GetFullName(),
+	}
+	if err := validate(&createReq); err != nil {
+		return nil, err
+	}
+
+	user, err := s.in(ctx).Create(&createReq)
+	if err != nil {
+		return nil, toStatus(err)
+	}
+	return toProtoUser(user), nil
+}
+
+// UpdateUser applies only the fields named in update_mask (all mutable fields if the mask is empty).
+func (s *userServer) UpdateUser(ctx context.Context, req *usersv1.UpdateUserRequest) (*usersv1.User, error) {
+	if req.GetUser() == nil {
+		return nil, status.Error(codes.InvalidArgument, "user is required")
+	}
+	id, err := parseID(req.GetUser().GetId())
+	if err != nil {
+		return nil, err
+	}
+
+	paths := req.GetUpdateMask().GetPaths()
+	if len(paths) == 0 {
+		paths = []string{"username", "email", "full_name"}
+	}
+
+	updateReq := model.UpdateUserRequest{Actor: auth.ActorFrom(ctx)}
+	for _, path := range paths {
+		switch path {
+		case "username":
+			v := req.GetUser().GetUsername()
+			updateReq.Username = &v
+		case "email":
+			v := req.GetUser().GetEmail()
+			updateReq.Email = &v
+		case "full_name":
+			v := req.GetUser().GetFullName()
+			updateReq.FullName = &v
+		default:
+			return nil, status.Errorf(codes.InvalidArgument, "update_mask path %q is not updatable", path)
+		}
+	}
+	if err := validate(&updateReq); err != nil {
+		return nil, err
+	}
+
+	user, err := s.in(ctx).Update(id, &updateReq)
+	if err != nil {
+		return nil, toStatus(err)
+	}
+	return toProtoUser(user), nil
+}
+
+// DeleteUser is idempotent like its REST counterpart: deleting a missing user succeeds.
+func (s *userServer) DeleteUser(ctx context.Context, req *usersv1.DeleteUserRequest) (*emptypb.Empty, error) {
+	id, err := parseID(req.GetId())
+	if err != nil {
+		return nil, err
+	}
+	if err := s.in(ctx).Delete(id, auth.ActorFrom(ctx)); err != nil && !stdErrors.Is(err, errors.ErrUserNotFound) {
+		return nil, toStatus(err)
+	}
+	return &emptypb.Empty{}, nil
+}
+
+var eventTypes = map[events.Type]usersv1.UserEvent_Type{
+	events.UserCreated: usersv1.UserEvent_TYPE_CREATED,
+	events.UserUpdated: usersv1.UserEvent_TYPE_UPDATED,
+	events.UserDeleted: usersv1.UserEvent_TYPE_DELETED,
+}
+
+// WatchUsers streams the events of the caller's organization until the client goes away or the
+// server shuts down. If the client cannot keep up the stream ends with Unavailable and it should re-list and re-watch.
+func (s *userServer) WatchUsers(_ *usersv1.WatchUsersRequest, stream usersv1.UserService_WatchUsersServer) error {
+	org := auth.OrganizationFrom(stream.Context())
+	ch, unsubscribe := s.broker.Subscribe()
+	defer unsubscribe()
+
+	for {
+		select {
+		case <-stream.Context().Done():
+			return nil
+		case e, ok := <-ch:
+			if !ok {
+				return status.Error(codes.Unavailable, "event stream interrupted; re-list and watch again")
+			}
+			eventType, isUserEvent := eventTypes[e.Type]
+			if !isUserEvent || e.User.OrganizationID != org {
+				// Group membership events are not part of this stream
+				continue
+			}
+			user := &usersv1.User{Id: e.User.ID.String()}
+			if e.Type != events.UserDeleted {
+				user = toProtoUser(&e.User)
+			}
+			if err := stream.Send(&usersv1.UserEvent{
+				Type:      eventType,
+				User:      user,
+				OccurTime: timestamppb.New(e.OccurredAt),
+			}); err != nil {
+				return err
+			}
+		}
+	}
+}
@@ -0,0 +1,63 @@
+package scheduler
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestParse_Next(t *testing.T) {
+	// A Wednesday
+	from := time.Date(2025, time.December, 3, 10, 17, 30, 0, time.UTC)
+
+	tests := []struct {
+		spec string
+		want time.Time
+	}{
+		{"* * * * *", time.Date(2025, 12, 3, 10, 18, 0, 0, time.UTC)},
+		{"*/15 * * * *", time.Date(2025, 12, 3, 10, 30, 0, 0, time.UTC)},
+		{"17 * * * *", time.Date(2025, 12, 3, 11, 17, 0, 0, time.UTC)},
+		{"5,50 9-11 * * *", time.Date(2025, 12, 3, 10, 50, 0, 0, time.UTC)},
+		{"30 3 * * *", time.Date(2025, 12, 4, 3, 30, 0, 0, time.UTC)},
+		{"0 0 * * 0", time.Date(2025, 12, 7, 0, 0, 0, 0, time.UTC)},
+		{"0 0 * * 7", time.Date(2025, 12, 7, 0, 0, 0, 0, time.UTC)},
+		{"0 0 1 * *", time.Date(2026, 1, 1, 0, 0, 0, 0, time.UTC)},
+		{"0 0 29 2 *", time.Date(2028, 2, 29, 0, 0, 0, 0, time.UTC)},
+		// Both day fields restricted: the 1st or any Friday
+		{"0 12 1 * 5", time.Date(2025, 12, 5, 12, 0, 0, 0, time.UTC)},
+		{"@hourly", time.Date(2025, 12, 3, 11, 0, 0, 0, time.UTC)},
+		{"@daily", time.Date(2025, 12, 4, 0, 0, 0, 0, time.UTC)},
+		{"@every 10m", time.Date(2025, 12, 3, 10, 20, 0, 0, time.UTC)},
+		{"0 0 30 2 *", time.Time{}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.spec, func(t *testing.T) {
+			s, err := Parse(tt.spec)
+			require.NoError(t, err)
+			assert.Equal(t, tt.want, s.Next(from))
+		})
+	}
+}
+
+func TestParse_Invalid(t *testing.T) {
+	for _, spec := range []string{
+		"",
+		"* * * *",
+		"60 * * * *",
+		"* 24 * * *",
+		"* * 0 * *",
+		"* * * 13 *",
+		"* * * * 8",
+		"*/0 * * * *",
+		"10-5 * * * *",
+		"a * * * *",
+		"@every 10ms",
+		"@every soon",
+		"@fortnightly",
+	} {
+		_, err := Parse(spec)
+		assert.Error(t, err, "spec %q", spec)
+	}
+}
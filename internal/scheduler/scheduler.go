@@ -0,0 +1,170 @@
+// Package scheduler runs periodic maintenance tasks in-process on every replica, with each
+// scheduled slot running on only one of them.
+//
+// Two things keep replicas apart. A task runs only while its replica holds the task's Postgres
+// advisory lock (pg_try_advisory_lock), so a slow run never overlaps the next one elsewhere.
+// And the run is recorded in task_runs under a unique (task, slot) key before it starts, so a
+// replica whose clock is a little behind finds the slot taken and skips it.
+package scheduler
+
+import (
+	"context"
+	"cruder/internal/repository"
+	"fmt"
+	"hash/fnv"
+	"log/slog"
+	"os"
+	"sync"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// Func is the work of a task. It should return soon after ctx is cancelled, which happens
+// when the server shuts down. A slot that failed is not retried; the next slot runs as usual.
+type Func func(ctx context.Context) error
+
+// Locker takes a cluster-wide lock without waiting; repository.PostgresConnection implements it.
+type Locker interface {
+	TryAdvisoryLock(ctx context.Context, key int64, fn func() error) (bool, error)
+}
+
+type task struct {
+	name     string
+	schedule Schedule
+	fn       Func
+}
+
+// Scheduler runs registered tasks on their schedules until its context is cancelled.
+type Scheduler struct {
+	locker Locker
+	runs   repository.TaskRunRepository
+	logger *slog.Logger
+	node   string
+	tasks  []*task
+}
+
+func New(locker Locker, runs repository.TaskRunRepository, logger *slog.Logger) *Scheduler {
+	host, _ := os.Hostname()
+	return &Scheduler{
+		locker: locker,
+		runs:   runs,
+		logger: logger,
+		node:   fmt.Sprintf("%s-%d-%s", host, os.Getpid(), uuid.NewString()[:8]),
+	}
+}
+
+// Register adds a task with a schedule spec as understood by Parse. Task names must be unique;
+// they identify the task in the run history and its advisory lock. It must be called before Run.
+func (s *Scheduler) Register(name, spec string, fn Func) error {
+	schedule, err := Parse(spec)
+	if err != nil {
+		return fmt.Errorf("task %s: %w", name, err)
+	}
+	return s.Add(name, schedule, fn)
+}
+
+// Add is Register with an already built Schedule.
+func (s *Scheduler) Add(name string, schedule Schedule, fn Func) error {
+	for _, t := range s.tasks {
+		if t.name == name {
+			return fmt.Errorf("task %s is already registered", name)
+		}
+	}
+	s.tasks = append(s.tasks, &task{name: name, schedule: schedule, fn: fn})
+	return nil
+}
+
+// Run schedules the tasks until ctx is cancelled. Running tasks see the cancellation too,
+// and Run returns once they have stopped.
+func (s *Scheduler) Run(ctx context.Context) {
+	s.logger.Info("Scheduler started",
+		slog.String("node", s.node),
+		slog.Int("tasks", len(s.tasks)))
+
+	var wg sync.WaitGroup
+	for _, t := range s.tasks {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			s.loop(ctx, t)
+		}()
+	}
+	wg.Wait()
+}
+
+func (s *Scheduler) loop(ctx context.Context, t *task) {
+	next := t.schedule.Next(time.Now())
+	for !next.IsZero() {
+		timer := time.NewTimer(time.Until(next))
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			return
+		case <-timer.C:
+		}
+
+		s.runSlot(ctx, t, next)
+		// Slots missed while the run took longer than the interval are skipped, not caught up
+		next = t.schedule.Next(time.Now())
+	}
+	s.logger.Warn("Task has no further runs scheduled", slog.String("task", t.name))
+}
+
+// runSlot runs t for slot unless another replica is running it or already ran the slot.
+func (s *Scheduler) runSlot(ctx context.Context, t *task, slot time.Time) {
+	logger := s.logger.With(
+		slog.String("task", t.name),
+		slog.String("node", s.node),
+		slog.Time("slot", slot))
+
+	acquired, err := s.locker.TryAdvisoryLock(ctx, lockKey(t.name), func() error {
+		run, err := s.runs.Start(t.name, slot, s.node)
+		if err != nil {
+			return fmt.Errorf("failed to record task run: %w", err)
+		}
+		if run == nil {
+			logger.Debug("Task slot already ran on another node")
+			return nil
+		}
+
+		start := time.Now()
+		runErr := s.call(ctx, t)
+		errMsg := ""
+		if runErr != nil {
+			errMsg = runErr.Error()
+			logger.Error("Task failed",
+				slog.Duration("duration", time.Since(start)),
+				slog.String("error", errMsg))
+		} else {
+			logger.Info("Task finished", slog.Duration("duration", time.Since(start)))
+		}
+		if err := s.runs.Finish(run.ID, errMsg); err != nil {
+			return fmt.Errorf("failed to record task outcome: %w", err)
+		}
+		return nil
+	})
+	switch {
+	case err != nil:
+		logger.Error("Task run not scheduled", slog.String("error", err.Error()))
+	case !acquired:
+		logger.Debug("Task is running on another node")
+	}
+}
+
+// call runs the task, turning a panic into a failed run rather than a crashed server.
+func (s *Scheduler) call(ctx context.Context, t *task) (err error) {
+	defer func() {
+		if r := recover(); r != nil {
+			err = fmt.Errorf("task panicked: %v", r)
+		}
+	}()
+	return t.fn(ctx)
+}
+
+// lockKey derives a task's advisory lock key from its name.
+func lockKey(name string) int64 {
+	h := fnv.New64a()
+	_, _ = h.Write([]byte("cruder/scheduler/" + name))
+	return int64(h.Sum64())
+}
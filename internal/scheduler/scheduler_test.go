@@ -0,0 +1,152 @@
+package scheduler
+
+import (
+	"context"
+	"cruder/internal/model"
+	"cruder/internal/repository/memory"
+	"io"
+	"log/slog"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	stdErrors "errors"
+)
+
+// localLocker stands in for Postgres advisory locks shared by every replica.
+type localLocker struct {
+	mu   sync.Mutex
+	held map[int64]bool
+}
+
+func (l *localLocker) TryAdvisoryLock(_ context.Context, key int64, fn func() error) (bool, error) {
+	l.mu.Lock()
+	if l.held[key] {
+		l.mu.Unlock()
+		return false, nil
+	}
+	l.held[key] = true
+	l.mu.Unlock()
+
+	defer func() {
+		l.mu.Lock()
+		delete(l.held, key)
+		l.mu.Unlock()
+	}()
+	return true, fn()
+}
+
+// tick fires every d, far more often than Parse allows.
+type tick time.Duration
+
+func (d tick) Next(t time.Time) time.Time {
+	return t.Truncate(time.Duration(d)).Add(time.Duration(d))
+}
+
+var discard = slog.New(slog.NewTextHandler(io.Discard, nil))
+
+// start runs s until the returned func is called (or the test ends).
+func start(t *testing.T, s *Scheduler) context.CancelFunc {
+	t.Helper()
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		s.Run(ctx)
+	}()
+	stop := func() {
+		cancel()
+		<-done
+	}
+	t.Cleanup(stop)
+	return stop
+}
+
+func TestScheduler_RunsEachSlotOnce(t *testing.T) {
+	locker := &localLocker{held: map[int64]bool{}}
+	runs := memory.NewTaskRunRepository()
+
+	var calls atomic.Int64
+	var stops []context.CancelFunc
+	for range 3 {
+		s := New(locker, runs, discard)
+		require.NoError(t, s.Add("count", tick(20*time.Millisecond), func(context.Context) error {
+			calls.Add(1)
+			return nil
+		}))
+		stops = append(stops, start(t, s))
+	}
+
+	require.Eventually(t, func() bool { return calls.Load() >= 5 }, 2*time.Second, 5*time.Millisecond)
+	for _, stop := range stops {
+		stop()
+	}
+
+	history, err := runs.List("count", 100)
+	require.NoError(t, err)
+	assert.Equal(t, calls.Load(), int64(len(history)), "every call is one recorded run")
+	slots := map[time.Time]bool{}
+	for _, run := range history {
+		assert.False(t, slots[run.ScheduledAt], "slot %s ran twice", run.ScheduledAt)
+		slots[run.ScheduledAt] = true
+		assert.Equal(t, model.TaskSucceeded, run.Status)
+		assert.NotNil(t, run.DurationMS)
+	}
+}
+
+func TestScheduler_RecordsFailures(t *testing.T) {
+	runs := memory.NewTaskRunRepository()
+	s := New(&localLocker{held: map[int64]bool{}}, runs, discard)
+	require.NoError(t, s.Add("fail", tick(10*time.Millisecond), func(context.Context) error {
+		return stdErrors.New("disk full")
+	}))
+	require.NoError(t, s.Add("panic", tick(10*time.Millisecond), func(context.Context) error {
+		panic("boom")
+	}))
+	start(t, s)
+
+	for task, msg := range map[string]string{"fail": "disk full", "panic": "task panicked: boom"} {
+		require.Eventually(t, func() bool {
+			history, err := runs.List(task, 1)
+			require.NoError(t, err)
+			return len(history) == 1 && history[0].Status == model.TaskFailed
+		}, 2*time.Second, 5*time.Millisecond)
+		history, err := runs.List(task, 1)
+		require.NoError(t, err)
+		assert.Equal(t, msg, history[0].Error)
+	}
+}
+
+func TestScheduler_StopCancelsRunningTasks(t *testing.T) {
+	runs := memory.NewTaskRunRepository()
+	s := New(&localLocker{held: map[int64]bool{}}, runs, discard)
+	started := make(chan struct{}, 1)
+	require.NoError(t, s.Add("slow", tick(10*time.Millisecond), func(ctx context.Context) error {
+		started <- struct{}{}
+		<-ctx.Done()
+		return ctx.Err()
+	}))
+	stop := start(t, s)
+
+	<-started
+	stop()
+
+	history, err := runs.List("slow", 10)
+	require.NoError(t, err)
+	require.Len(t, history, 1)
+	assert.Equal(t, model.TaskFailed, history[0].Status)
+	assert.Equal(t, context.Canceled.Error(), history[0].Error)
+}
+
+func TestScheduler_Register(t *testing.T) {
+	s := New(&localLocker{held: map[int64]bool{}}, memory.NewTaskRunRepository(), discard)
+	noop := func(context.Context) error { return nil }
+
+	require.NoError(t, s.Register("purge", "@hourly", noop))
+	assert.Error(t, s.Register("purge", "@daily", noop), "duplicate name")
+	assert.Error(t, s.Register("broken", "every hour", noop))
+}
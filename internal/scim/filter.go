@@ -0,0 +1,524 @@
+package scim
+
+import (
+	"encoding/json"
+	"net/http"
+	"strings"
+	"time"
+	"unicode"
+)
+
+// Filter is a parsed filter expression (RFC 7644 §3.4.2.2): comparisons with eq, ne, co, sw, ew,
+// gt, ge, lt, le and pr, combined with and, or, not and parentheses, and value filters such as
+// emails[type eq "work" and value co "@example.com"]. Attributes are typed by the User schema, so a
+// filter on an unknown attribute or comparing a value of the wrong type fails to parse.
+type Filter struct {
+	expr expr
+}
+
+// ParseFilter parses a filter expression; errors are SCIM invalidFilter errors.
+func ParseFilter(s string) (*Filter, error) {
+	e, err := parseExpr(s, nil)
+	if err != nil {
+		return nil, err
+	}
+	return &Filter{expr: e}, nil
+}
+
+// Matches reports whether user matches the filter.
+func (f *Filter) Matches(user *User) bool {
+	return f.expr.eval(user.toMap())
+}
+
+// UserName returns the username of a filter that is just userName eq "...", the one query
+// identity providers run before every provisioning call, so it can be answered with a lookup
+// instead of a scan.
+func (f *Filter) UserName() (string, bool) {
+	c, ok := f.expr.(*comparison)
+	if !ok || c.op != "eq" || len(c.keys) != 1 || c.keys[0] != "userName" {
+		return "", false
+	}
+	s, ok := c.value.(string)
+	return s, ok
+}
+
+func invalidFilter(format string, args ...any) error {
+	return Errorf(http.StatusBadRequest, InvalidFilter, format, args...)
+}
+
+// parseExpr parses s; within a value filter parent is the multi-valued attribute whose
+// sub-attributes the expression names.
+func parseExpr(s string, parent *Attribute) (expr, error) {
+	tokens, err := lex(s)
+	if err != nil {
+		return nil, err
+	}
+	p := &parser{tokens: tokens}
+	e, err := p.or(parent)
+	if err != nil {
+		return nil, err
+	}
+	if t := p.peek(); t.kind != tokEOF {
+		return nil, invalidFilter("unexpected %q at position %d", t.text, t.pos+1)
+	}
+	return e, nil
+}
+
+type tokenKind int
+
+const (
+	tokEOF tokenKind = iota
+	tokWord
+	tokString
+	tokLParen
+	tokRParen
+	tokLBracket
+	tokRBracket
+)
+
+type token struct {
+	kind tokenKind
+	text string
+	pos  int
+}
+
+func lex(s string) ([]token, error) {
+	var tokens []token
+	for i := 0; i < len(s); {
+		c := s[i]
+		switch {
+		case c == ' ' || c == '\t' || c == '\n' || c == '\r':
+			i++
+		case c == '(' || c == ')' || c == '[' || c == ']':
+			kind := map[byte]tokenKind{'(': tokLParen, ')': tokRParen, '[': tokLBracket, ']': tokRBracket}[c]
+			tokens = append(tokens, token{kind: kind, text: string(c), pos: i})
+			i++
+		case c == '"':
+			end := i + 1
+			for ; end < len(s) && s[end] != '"'; end++ {
+				if s[end] == '\\' {
+					end++
+				}
+			}
+			if end >= len(s) {
+				return nil, invalidFilter("unterminated string at position %d", i+1)
+			}
+			var v string
+			if err := json.Unmarshal([]byte(s[i:end+1]), &v); err != nil {
+				return nil, invalidFilter("invalid string at position %d", i+1)
+			}
+			tokens = append(tokens, token{kind: tokString, text: v, pos: i})
+			i = end + 1
+		default:
+			end := i
+			for end < len(s) && !strings.ContainsRune(" \t\n\r()[]\"", rune(s[end])) {
+				end++
+			}
+			tokens = append(tokens, token{kind: tokWord, text: s[i:end], pos: i})
+			i = end
+		}
+	}
+	return append(tokens, token{kind: tokEOF, pos: len(s)}), nil
+}
+
+type parser struct {
+	tokens []token
+	pos    int
+}
+
+func (p *parser) peek() token {
+	return p.tokens[p.pos]
+}
+
+func (p *parser) next() token {
+	t := p.tokens[p.pos]
+	if t.kind != tokEOF {
+		p.pos++
+	}
+	return t
+}
+
+func (p *parser) keyword(word string) bool {
+	t := p.peek()
+	if t.kind == tokWord && strings.EqualFold(t.text, word) {
+		p.pos++
+		return true
+	}
+	return false
+}
+
+func (p *parser) expect(kind tokenKind, text string) error {
+	if t := p.next(); t.kind != kind {
+		if t.kind == tokEOF {
+			return invalidFilter("expected %q at the end of the filter", text)
+		}
+		return invalidFilter("expected %q at position %d, got %q", text, t.pos+1, t.text)
+	}
+	return nil
+}
+
+func (p *parser) or(parent *Attribute) (expr, error) {
+	left, err := p.and(parent)
+	if err != nil {
+		return nil, err
+	}
+	for p.keyword("or") {
+		right, err := p.and(parent)
+		if err != nil {
+			return nil, err
+		}
+		left = &logical{and: false, left: left, right: right}
+	}
+	return left, nil
+}
+
+func (p *parser) and(parent *Attribute) (expr, error) {
+	left, err := p.unary(parent)
+	if err != nil {
+		return nil, err
+	}
+	for p.keyword("and") {
+		right, err := p.unary(parent)
+		if err != nil {
+			return nil, err
+		}
+		left = &logical{and: true, left: left, right: right}
+	}
+	return left, nil
+}
+
+func (p *parser) unary(parent *Attribute) (expr, error) {
+	if p.keyword("not") {
+		if err := p.expect(tokLParen, "("); err != nil {
+			return nil, err
+		}
+		e, err := p.or(parent)
+		if err != nil {
+			return nil, err
+		}
+		if err := p.expect(tokRParen, ")"); err != nil {
+			return nil, err
+		}
+		return &negation{e}, nil
+	}
+	if p.peek().kind == tokLParen {
+		p.next()
+		e, err := p.or(parent)
+		if err != nil {
+			return nil, err
+		}
+		if err := p.expect(tokRParen, ")"); err != nil {
+			return nil, err
+		}
+		return e, nil
+	}
+	return p.attrExpr(parent)
+}
+
+func (p *parser) attrExpr(parent *Attribute) (expr, error) {
+	t := p.next()
+	if t.kind != tokWord {
+		if t.kind == tokEOF {
+			return nil, invalidFilter("expected an attribute at the end of the filter")
+		}
+		return nil, invalidFilter("expected an attribute at position %d, got %q", t.pos+1, t.text)
+	}
+
+	if p.peek().kind == tokLBracket {
+		if parent != nil {
+			return nil, invalidFilter("value filters cannot be nested")
+		}
+		attr, sub, err := resolve(t.text, nil)
+		if err != nil {
+			return nil, err
+		}
+		if sub != nil || !attr.MultiValued || attr.Type != TypeComplex {
+			return nil, invalidFilter("%s is not a multi-valued complex attribute", t.text)
+		}
+		p.next()
+		inner, err := p.or(attr)
+		if err != nil {
+			return nil, err
+		}
+		if err := p.expect(tokRBracket, "]"); err != nil {
+			return nil, err
+		}
+		return &valueFilter{key: attr.Name, filter: inner}, nil
+	}
+
+	attr, sub, err := resolve(t.text, parent)
+	if err != nil {
+		return nil, err
+	}
+	c := &comparison{path: t.text}
+	switch {
+	case parent != nil:
+		c.keys, c.attr = []string{sub.Name}, sub
+	case sub != nil:
+		c.keys, c.attr = []string{attr.Name, sub.Name}, sub
+	default:
+		c.keys, c.attr = []string{attr.Name}, attr
+	}
+	if c.attr.Type == TypeComplex {
+		// a multi-valued attribute compares by its value sub-attribute (RFC 7644 §3.4.2.2)
+		for i := range c.attr.SubAttributes {
+			if c.attr.SubAttributes[i].Name == "value" {
+				c.keys = append(c.keys, "value")
+				c.attr = &c.attr.SubAttributes[i]
+				break
+			}
+		}
+	}
+
+	op := p.next()
+	if op.kind != tokWord {
+		return nil, invalidFilter("expected an operator after %s", t.text)
+	}
+	c.op = strings.ToLower(op.text)
+	if c.op == "pr" {
+		return c, nil
+	}
+	if !validOperator(c.op) {
+		return nil, invalidFilter("unknown operator %q", op.text)
+	}
+	if c.attr.Type == TypeComplex {
+		return nil, invalidFilter("%s is a complex attribute; only pr applies to it", t.text)
+	}
+	if err := c.parseValue(p.next()); err != nil {
+		return nil, err
+	}
+	return c, nil
+}
+
+func validOperator(op string) bool {
+	switch op {
+	case "eq", "ne", "co", "sw", "ew", "gt", "ge", "lt", "le":
+		return true
+	}
+	return false
+}
+
+// resolve looks up an attribute path: name or name.sub, or within a value filter of parent a
+// sub-attribute of it.
+func resolve(path string, parent *Attribute) (*Attribute, *Attribute, error) {
+	if parent != nil {
+		_, sub, ok := lookup(parent.Name, path)
+		if !ok {
+			return nil, nil, invalidFilter("unknown attribute %s.%s", parent.Name, path)
+		}
+		return parent, sub, nil
+	}
+	name, _ := cutPrefixFold(path, UserSchema+":")
+	name, subName, _ := strings.Cut(name, ".")
+	attr, sub, ok := lookup(name, subName)
+	if !ok {
+		return nil, nil, invalidFilter("unknown attribute %s", path)
+	}
+	return attr, sub, nil
+}
+
+type expr interface {
+	eval(resource map[string]any) bool
+}
+
+type logical struct {
+	and         bool
+	left, right expr
+}
+
+func (l *logical) eval(resource map[string]any) bool {
+	if l.and {
+		return l.left.eval(resource) && l.right.eval(resource)
+	}
+	return l.left.eval(resource) || l.right.eval(resource)
+}
+
+type negation struct {
+	expr expr
+}
+
+func (n *negation) eval(resource map[string]any) bool {
+	return !n.expr.eval(resource)
+}
+
+// valueFilter matches when one value of a multi-valued attribute matches filter.
+type valueFilter struct {
+	key    string
+	filter expr
+}
+
+func (v *valueFilter) eval(resource map[string]any) bool {
+	values, _ := resource[v.key].([]any)
+	for _, value := range values {
+		if m, ok := value.(map[string]any); ok && v.filter.eval(m) {
+			return true
+		}
+	}
+	return false
+}
+
+// comparison compares the values at keys, typed as attr, with value. It matches when any of the
+// values of a multi-valued attribute does, except ne, which matches when none is equal.
+type comparison struct {
+	path  string
+	keys  []string
+	attr  *Attribute
+	op    string
+	value any
+	time  time.Time
+}
+
+func (c *comparison) parseValue(t token) error {
+	switch {
+	case t.kind == tokString:
+		c.value = t.text
+	case t.kind == tokWord && (t.text == "true" || t.text == "false"):
+		c.value = t.text == "true"
+	case t.kind == tokWord && t.text == "null":
+		if c.op != "eq" && c.op != "ne" {
+			return invalidFilter("%s cannot compare with null", c.op)
+		}
+		return nil
+	case t.kind == tokWord && t.text != "" && (unicode.IsDigit(rune(t.text[0])) || t.text[0] == '-'):
+		return invalidFilter("%s is not a number", c.path)
+	default:
+		return invalidFilter("expected a value after %s %s", c.path, c.op)
+	}
+
+	switch c.attr.Type {
+	case TypeBoolean:
+		if _, ok := c.value.(bool); !ok {
+			return invalidFilter("%s is a boolean", c.path)
+		}
+		if c.op != "eq" && c.op != "ne" {
+			return invalidFilter("%s is a boolean; only eq, ne and pr apply to it", c.path)
+		}
+	case TypeDateTime:
+		s, ok := c.value.(string)
+		if !ok {
+			return invalidFilter("%s is a dateTime", c.path)
+		}
+		ts, err := time.Parse(time.RFC3339Nano, s)
+		if err != nil {
+			return invalidFilter("%s is a dateTime; %q is not an RFC 3339 time", c.path, s)
+		}
+		if c.op == "co" || c.op == "sw" || c.op == "ew" {
+			return invalidFilter("%s is a dateTime; %s does not apply to it", c.path, c.op)
+		}
+		c.time = ts
+	default:
+		if _, ok := c.value.(string); !ok {
+			return invalidFilter("%s is a string", c.path)
+		}
+	}
+	return nil
+}
+
+func (c *comparison) eval(resource map[string]any) bool {
+	values := collect(resource, c.keys)
+	if c.op == "pr" || c.value == nil {
+		present := false
+		for _, v := range values {
+			if v != nil && v != "" {
+				present = true
+				break
+			}
+		}
+		if c.op == "eq" {
+			return !present
+		}
+		return present
+	}
+	if c.op == "ne" {
+		for _, v := range values {
+			if c.compare("eq", v) {
+				return false
+			}
+		}
+		return true
+	}
+	for _, v := range values {
+		if c.compare(c.op, v) {
+			return true
+		}
+	}
+	return false
+}
+
+func (c *comparison) compare(op string, v any) bool {
+	switch c.attr.Type {
+	case TypeBoolean:
+		b, ok := v.(bool)
+		return ok && b == c.value.(bool)
+	case TypeDateTime:
+		s, _ := v.(string)
+		ts, err := time.Parse(time.RFC3339Nano, s)
+		if err != nil {
+			return false
+		}
+		switch op {
+		case "eq":
+			return ts.Equal(c.time)
+		case "gt":
+			return ts.After(c.time)
+		case "ge":
+			return !ts.Before(c.time)
+		case "lt":
+			return ts.Before(c.time)
+		case "le":
+			return !ts.After(c.time)
+		}
+		return false
+	}
+
+	s, ok := v.(string)
+	if !ok {
+		return false
+	}
+	want := c.value.(string)
+	if !c.attr.CaseExact {
+		s, want = strings.ToLower(s), strings.ToLower(want)
+	}
+	switch op {
+	case "eq":
+		return s == want
+	case "co":
+		return strings.Contains(s, want)
+	case "sw":
+		return strings.HasPrefix(s, want)
+	case "ew":
+		return strings.HasSuffix(s, want)
+	case "gt":
+		return s > want
+	case "ge":
+		return s >= want
+	case "lt":
+		return s < want
+	case "le":
+		return s <= want
+	}
+	return false
+}
+
+// collect returns the values at keys, descending into every value of multi-valued attributes.
+func collect(v any, keys []string) []any {
+	if list, ok := v.([]any); ok {
+		var values []any
+		for _, item := range list {
+			values = append(values, collect(item, keys)...)
+		}
+		return values
+	}
+	if len(keys) == 0 {
+		return []any{v}
+	}
+	m, ok := v.(map[string]any)
+	if !ok {
+		return nil
+	}
+	next, ok := m[keys[0]]
+	if !ok {
+		return nil
+	}
+	return collect(next, keys[1:])
+}
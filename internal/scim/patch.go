@@ -0,0 +1,353 @@
+package scim
+
+import (
+	"encoding/json"
+	"net/http"
+	"reflect"
+	"strings"
+)
+
+// PatchRequest is the body of a PATCH request (RFC 7644 §3.5.2).
+type PatchRequest struct {
+	Schemas    []string         `json:"schemas"`
+	Operations []PatchOperation `json:"Operations"`
+}
+
+// PatchOperation is one add, replace or remove. Path is attr, attr.sub, attr[filter] or
+// attr[filter].sub; add and replace without a path take an object of attributes as Value.
+type PatchOperation struct {
+	Op    string `json:"op"`
+	Path  string `json:"path,omitempty"`
+	Value any    `json:"value,omitempty"`
+}
+
+// Apply returns user with the operations of req applied in order. Read-only attributes can only
+// be "changed" to the value they already have, which some identity providers send along.
+func (req *PatchRequest) Apply(user *User) (*User, error) {
+	if !contains(req.Schemas, PatchOpSchema) {
+		return nil, Errorf(http.StatusBadRequest, InvalidSyntax, "schemas must be [%q]", PatchOpSchema)
+	}
+	if len(req.Operations) == 0 {
+		return nil, Errorf(http.StatusBadRequest, InvalidSyntax, "Operations must not be empty")
+	}
+
+	p := &patcher{resource: user.toMap(), touched: map[string]bool{}}
+	for _, op := range req.Operations {
+		if err := p.apply(op); err != nil {
+			return nil, err
+		}
+	}
+
+	// name.formatted and displayName win over givenName and familyName in ToRequest, so a patch
+	// that only changes the latter must drop the stale full name they would otherwise hide behind
+	if p.touched["name.givenName"] || p.touched["name.familyName"] {
+		if name, ok := p.resource["name"].(map[string]any); ok && !p.touched["name.formatted"] {
+			delete(name, "formatted")
+		}
+		if !p.touched["displayName"] {
+			delete(p.resource, "displayName")
+		}
+	}
+	return userFromMap(p.resource)
+}
+
+func contains(values []string, want string) bool {
+	for _, v := range values {
+		if v == want {
+			return true
+		}
+	}
+	return false
+}
+
+type patcher struct {
+	resource map[string]any
+	// touched records the paths written, as attr or attr.sub
+	touched map[string]bool
+}
+
+// target is a parsed patch path.
+type target struct {
+	attr   *Attribute
+	sub    *Attribute
+	filter expr
+}
+
+func (p *patcher) apply(op PatchOperation) error {
+	kind := strings.ToLower(op.Op)
+	switch kind {
+	case "add", "replace", "remove":
+	default:
+		return Errorf(http.StatusBadRequest, InvalidSyntax, "unknown op %q", op.Op)
+	}
+
+	if op.Path == "" {
+		if kind == "remove" {
+			return Errorf(http.StatusBadRequest, NoTarget, "remove needs a path")
+		}
+		values, ok := op.Value.(map[string]any)
+		if !ok {
+			return Errorf(http.StatusBadRequest, InvalidValue, "%s without a path needs an object of attributes as its value", kind)
+		}
+		for name, value := range values {
+			if strings.EqualFold(name, "schemas") {
+				continue
+			}
+			if err := p.apply(PatchOperation{Op: kind, Path: name, Value: value}); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+
+	t, err := parsePath(op.Path)
+	if err != nil {
+		return err
+	}
+	if kind != "remove" {
+		if op.Value == nil {
+			return Errorf(http.StatusBadRequest, InvalidValue, "%s %s needs a value", kind, op.Path)
+		}
+	}
+
+	leaf := t.attr
+	if t.sub != nil {
+		leaf = t.sub
+	}
+	if t.attr.Mutability == ReadOnly || leaf.Mutability == ReadOnly {
+		if kind != "remove" && t.filter == nil && reflect.DeepEqual(p.current(t), normalize(leaf, op.Value)) {
+			return nil
+		}
+		return Errorf(http.StatusBadRequest, Mutability, "%s is read-only", op.Path)
+	}
+
+	p.touched[t.attr.Name] = true
+	if t.sub != nil {
+		p.touched[t.attr.Name+"."+t.sub.Name] = true
+	} else if m, ok := op.Value.(map[string]any); ok {
+		for name := range normalize(t.attr, m).(map[string]any) {
+			p.touched[t.attr.Name+"."+name] = true
+		}
+	}
+
+	switch {
+	case t.filter != nil:
+		return p.applyFiltered(kind, t, op)
+	case t.attr.MultiValued:
+		return p.applyMultiValued(kind, t, op.Value)
+	case t.sub != nil:
+		parent, _ := p.resource[t.attr.Name].(map[string]any)
+		if parent == nil {
+			if kind == "remove" {
+				return nil
+			}
+			parent = map[string]any{}
+			p.resource[t.attr.Name] = parent
+		}
+		if kind == "remove" {
+			delete(parent, t.sub.Name)
+		} else {
+			parent[t.sub.Name] = normalize(t.sub, op.Value)
+		}
+	default:
+		value := normalize(t.attr, op.Value)
+		switch {
+		case kind == "remove":
+			delete(p.resource, t.attr.Name)
+		case t.attr.Type == TypeComplex:
+			// sub-attributes missing from the value are left as they are (RFC 7644 §3.5.2.1, §3.5.2.3)
+			values, ok := value.(map[string]any)
+			if !ok {
+				return Errorf(http.StatusBadRequest, InvalidValue, "%s needs an object", op.Path)
+			}
+			parent, _ := p.resource[t.attr.Name].(map[string]any)
+			if parent == nil {
+				parent = map[string]any{}
+				p.resource[t.attr.Name] = parent
+			}
+			for k, v := range values {
+				parent[k] = v
+			}
+		default:
+			p.resource[t.attr.Name] = value
+		}
+	}
+	return nil
+}
+
+// applyMultiValued applies an operation on a whole multi-valued attribute, or on one
+// sub-attribute of all its values.
+func (p *patcher) applyMultiValued(kind string, t target, value any) error {
+	list, _ := p.resource[t.attr.Name].([]any)
+	if t.sub != nil {
+		if kind != "remove" && len(list) == 0 {
+			list = []any{map[string]any{}}
+		}
+		for _, item := range list {
+			if m, ok := item.(map[string]any); ok {
+				if kind == "remove" {
+					delete(m, t.sub.Name)
+				} else {
+					m[t.sub.Name] = normalize(t.sub, value)
+				}
+			}
+		}
+		p.resource[t.attr.Name] = list
+		return nil
+	}
+
+	var values []any
+	if kind != "remove" {
+		switch v := normalize(t.attr, value).(type) {
+		case []any:
+			values = v
+		default:
+			values = []any{v}
+		}
+	}
+	switch kind {
+	case "remove":
+		delete(p.resource, t.attr.Name)
+	case "replace":
+		p.resource[t.attr.Name] = values
+	case "add":
+		// a new primary value takes over from the old one (RFC 7643 §2.4)
+		if hasPrimary(values) {
+			for _, item := range list {
+				if m, ok := item.(map[string]any); ok {
+					delete(m, "primary")
+				}
+			}
+		}
+		p.resource[t.attr.Name] = append(list, values...)
+	}
+	return nil
+}
+
+// applyFiltered applies an operation on the values of a multi-valued attribute that match the
+// path's filter, or on a sub-attribute of them.
+func (p *patcher) applyFiltered(kind string, t target, op PatchOperation) error {
+	list, _ := p.resource[t.attr.Name].([]any)
+	var kept []any
+	matched := false
+	for _, item := range list {
+		m, ok := item.(map[string]any)
+		if !ok || !t.filter.eval(m) {
+			kept = append(kept, item)
+			continue
+		}
+		matched = true
+		switch {
+		case kind == "remove" && t.sub == nil:
+			continue
+		case kind == "remove":
+			delete(m, t.sub.Name)
+		case t.sub != nil:
+			m[t.sub.Name] = normalize(t.sub, op.Value)
+		default:
+			values, ok := normalize(t.attr, op.Value).(map[string]any)
+			if !ok {
+				return Errorf(http.StatusBadRequest, InvalidValue, "%s needs an object", op.Path)
+			}
+			for k, v := range values {
+				m[k] = v
+			}
+		}
+		kept = append(kept, m)
+	}
+	if !matched {
+		return Errorf(http.StatusBadRequest, NoTarget, "no value matches %s", op.Path)
+	}
+	p.resource[t.attr.Name] = kept
+	return nil
+}
+
+// current returns the value at a path without a filter, for comparing with the value of an
+// operation on a read-only attribute.
+func (p *patcher) current(t target) any {
+	v := p.resource[t.attr.Name]
+	if t.sub == nil {
+		return v
+	}
+	m, _ := v.(map[string]any)
+	return m[t.sub.Name]
+}
+
+func hasPrimary(values []any) bool {
+	for _, v := range values {
+		if m, ok := v.(map[string]any); ok && m["primary"] == true {
+			return true
+		}
+	}
+	return false
+}
+
+// parsePath parses a patch path (RFC 7644 §3.5.2).
+func parsePath(path string) (target, error) {
+	var t target
+	name, rest := path, ""
+	filter := ""
+	if i := strings.IndexByte(path, '['); i >= 0 {
+		j := strings.LastIndexByte(path, ']')
+		if j < i {
+			return t, Errorf(http.StatusBadRequest, InvalidPath, "unbalanced brackets in %s", path)
+		}
+		name, filter, rest = path[:i], path[i+1:j], path[j+1:]
+		if rest != "" && !strings.HasPrefix(rest, ".") {
+			return t, Errorf(http.StatusBadRequest, InvalidPath, "unexpected %q in %s", rest, path)
+		}
+		rest = strings.TrimPrefix(rest, ".")
+	} else {
+		name, _ = cutPrefixFold(name, UserSchema+":")
+		name, rest, _ = strings.Cut(name, ".")
+	}
+
+	attr, sub, ok := lookup(name, rest)
+	if !ok {
+		return t, Errorf(http.StatusBadRequest, InvalidPath, "unknown attribute %s", path)
+	}
+	t.attr, t.sub = attr, sub
+	if filter != "" || strings.Contains(path, "[") {
+		if !attr.MultiValued {
+			return t, Errorf(http.StatusBadRequest, InvalidPath, "%s is not multi-valued", name)
+		}
+		e, err := parseExpr(filter, attr)
+		if err != nil {
+			return t, Errorf(http.StatusBadRequest, InvalidPath, "%s: %s", path, err.(*Error).Detail)
+		}
+		t.filter = e
+	}
+	return t, nil
+}
+
+// normalize renames the keys of a value to the canonical names of attr's sub-attributes and
+// round-trips it through JSON, so it compares and serializes like the resource's own values.
+func normalize(attr *Attribute, value any) any {
+	switch v := value.(type) {
+	case []any:
+		out := make([]any, len(v))
+		for i, item := range v {
+			out[i] = normalize(attr, item)
+		}
+		return out
+	case map[string]any:
+		out := make(map[string]any, len(v))
+		for k, item := range v {
+			for _, sub := range attr.SubAttributes {
+				if strings.EqualFold(sub.Name, k) {
+					k = sub.Name
+					break
+				}
+			}
+			out[k] = item
+		}
+		return out
+	}
+	data, err := json.Marshal(value)
+	if err != nil {
+		return value
+	}
+	var out any
+	_ = json.Unmarshal(data, &out)
+	return out
+}
@@ -0,0 +1,219 @@
+package scim
+
+import (
+	"strings"
+)
+
+// Attribute describes an attribute of a resource (RFC 7643 §7). The attributes of the User schema
+// drive discovery, the typing of filters and which paths PATCH may change.
+type Attribute struct {
+	Name          string      `json:"name"`
+	Type          string      `json:"type"`
+	MultiValued   bool        `json:"multiValued"`
+	Description   string      `json:"description,omitempty"`
+	Required      bool        `json:"required"`
+	CaseExact     bool        `json:"caseExact"`
+	Mutability    string      `json:"mutability"`
+	Returned      string      `json:"returned"`
+	Uniqueness    string      `json:"uniqueness"`
+	SubAttributes []Attribute `json:"subAttributes,omitempty"`
+}
+
+// Attribute types
+const (
+	TypeString   = "string"
+	TypeBoolean  = "boolean"
+	TypeDateTime = "dateTime"
+	TypeComplex  = "complex"
+	TypeRef      = "reference"
+)
+
+// Mutability values
+const (
+	ReadOnly  = "readOnly"
+	ReadWrite = "readWrite"
+	WriteOnly = "writeOnly"
+)
+
+func str(name, description string, required, caseExact bool, mutability, uniqueness string) Attribute {
+	returned := "default"
+	switch mutability {
+	case WriteOnly:
+		returned = "never"
+	case ReadOnly:
+		returned = "always"
+	}
+	return Attribute{Name: name, Type: TypeString, Description: description, Required: required,
+		CaseExact: caseExact, Mutability: mutability, Returned: returned, Uniqueness: uniqueness}
+}
+
+// userAttributes is the User schema as this service implements it.
+var userAttributes = []Attribute{
+	str("id", "The user's ID", false, true, ReadOnly, "server"),
+	str("userName", "Unique username: 3 to 50 letters and digits, stored in lower case", true, false, ReadWrite, "server"),
+	{
+		Name: "name", Type: TypeComplex, Description: "The user's name", Mutability: ReadWrite, Returned: "default", Uniqueness: "none",
+		SubAttributes: []Attribute{
+			str("formatted", "Full name: letters, spaces, hyphens and apostrophes", false, false, ReadWrite, "none"),
+			str("givenName", "Used for the full name when formatted is not given", false, false, WriteOnly, "none"),
+			str("familyName", "Used for the full name when formatted is not given", false, false, WriteOnly, "none"),
+		},
+	},
+	str("displayName", "The full name again", false, false, ReadWrite, "none"),
+	{
+		Name: "emails", Type: TypeComplex, MultiValued: true, Required: true, Mutability: ReadWrite, Returned: "default", Uniqueness: "none",
+		Description: "Exactly one address is kept: the primary one, else the first. A new address waits for the user to confirm it.",
+		SubAttributes: []Attribute{
+			str("value", "Email address", true, false, ReadWrite, "server"),
+			str("type", "Always work", false, false, ReadWrite, "none"),
+			{Name: "primary", Type: TypeBoolean, Mutability: ReadWrite, Returned: "default", Uniqueness: "none"},
+		},
+	},
+	{
+		Name: "active", Type: TypeBoolean, Mutability: ReadOnly, Returned: "default", Uniqueness: "none",
+		Description: "Whether the user may sign in: false while invited, suspended or deactivated",
+	},
+	{
+		Name: "meta", Type: TypeComplex, Mutability: ReadOnly, Returned: "default", Uniqueness: "none",
+		SubAttributes: []Attribute{
+			str("resourceType", "", false, true, ReadOnly, "none"),
+			{Name: "created", Type: TypeDateTime, Mutability: ReadOnly, Returned: "default", Uniqueness: "none"},
+			{Name: "lastModified", Type: TypeDateTime, Mutability: ReadOnly, Returned: "default", Uniqueness: "none"},
+			{Name: "location", Type: TypeRef, CaseExact: true, Mutability: ReadOnly, Returned: "default", Uniqueness: "none"},
+		},
+	},
+}
+
+// lookup returns the attribute at name, with sub for a sub-attribute, matching names case-insensitively
+// as RFC 7643 §2.1 requires. A name may carry the schema URN as a prefix.
+func lookup(name, sub string) (*Attribute, *Attribute, bool) {
+	if rest, ok := cutPrefixFold(name, UserSchema+":"); ok {
+		name = rest
+	}
+	for i := range userAttributes {
+		attr := &userAttributes[i]
+		if !strings.EqualFold(attr.Name, name) {
+			continue
+		}
+		if sub == "" {
+			return attr, nil, true
+		}
+		for j := range attr.SubAttributes {
+			if strings.EqualFold(attr.SubAttributes[j].Name, sub) {
+				return attr, &attr.SubAttributes[j], true
+			}
+		}
+		return nil, nil, false
+	}
+	return nil, nil, false
+}
+
+func cutPrefixFold(s, prefix string) (string, bool) {
+	if len(s) >= len(prefix) && strings.EqualFold(s[:len(prefix)], prefix) {
+		return s[len(prefix):], true
+	}
+	return s, false
+}
+
+// Schema is a schema definition served at /Schemas (RFC 7643 §7).
+type Schema struct {
+	Schemas     []string    `json:"schemas"`
+	ID          string      `json:"id"`
+	Name        string      `json:"name"`
+	Description string      `json:"description"`
+	Attributes  []Attribute `json:"attributes"`
+	Meta        DocMeta     `json:"meta"`
+}
+
+// DocMeta is the meta of a discovery document.
+type DocMeta struct {
+	ResourceType string `json:"resourceType"`
+	Location     string `json:"location"`
+}
+
+// UserSchemaDefinition returns the User schema.
+func UserSchemaDefinition() *Schema {
+	return &Schema{
+		Schemas:     []string{SchemaSchema},
+		ID:          UserSchema,
+		Name:        "User",
+		Description: "User Account",
+		Attributes:  userAttributes,
+		Meta:        DocMeta{ResourceType: "Schema", Location: BasePath + "/Schemas/" + UserSchema},
+	}
+}
+
+// ResourceType is a resource type served at /ResourceTypes (RFC 7643 §6).
+type ResourceType struct {
+	Schemas     []string `json:"schemas"`
+	ID          string   `json:"id"`
+	Name        string   `json:"name"`
+	Endpoint    string   `json:"endpoint"`
+	Description string   `json:"description"`
+	Schema      string   `json:"schema"`
+	Meta        DocMeta  `json:"meta"`
+}
+
+// UserResourceType returns the User resource type, the only one.
+func UserResourceType() *ResourceType {
+	return &ResourceType{
+		Schemas:     []string{ResourceTypeSchema},
+		ID:          "User",
+		Name:        "User",
+		Endpoint:    "/Users",
+		Description: "User Account",
+		Schema:      UserSchema,
+		Meta:        DocMeta{ResourceType: "ResourceType", Location: BasePath + "/ResourceTypes/User"},
+	}
+}
+
+// Supported marks a feature as supported or not in ServiceProviderConfig.
+type Supported struct {
+	Supported bool `json:"supported"`
+}
+
+// ServiceProviderConfig describes what this endpoint supports (RFC 7643 §5).
+type ServiceProviderConfig struct {
+	Schemas          []string  `json:"schemas"`
+	DocumentationURI string    `json:"documentationUri,omitempty"`
+	Patch            Supported `json:"patch"`
+	Bulk             struct {
+		Supported      bool `json:"supported"`
+		MaxOperations  int  `json:"maxOperations"`
+		MaxPayloadSize int  `json:"maxPayloadSize"`
+	} `json:"bulk"`
+	Filter struct {
+		Supported  bool `json:"supported"`
+		MaxResults int  `json:"maxResults"`
+	} `json:"filter"`
+	ChangePassword        Supported              `json:"changePassword"`
+	Sort                  Supported              `json:"sort"`
+	ETag                  Supported              `json:"etag"`
+	AuthenticationSchemes []AuthenticationScheme `json:"authenticationSchemes"`
+	Meta                  DocMeta                `json:"meta"`
+}
+
+type AuthenticationScheme struct {
+	Type        string `json:"type"`
+	Name        string `json:"name"`
+	Description string `json:"description"`
+	Primary     bool   `json:"primary,omitempty"`
+}
+
+// Config returns the ServiceProviderConfig: PATCH and filtering, without bulk, sorting, ETags
+// or password changes.
+func Config() *ServiceProviderConfig {
+	c := &ServiceProviderConfig{
+		Schemas:          []string{ServiceProviderConfigSchema},
+		DocumentationURI: "/docs",
+		Patch:            Supported{Supported: true},
+		AuthenticationSchemes: []AuthenticationScheme{
+			{Type: "oauthbearertoken", Name: "Bearer token", Description: "An access token from POST /api/v1/auth/token", Primary: true},
+			{Type: "httpheader", Name: "API key", Description: "An API key in the X-API-Key header"},
+		},
+		Meta: DocMeta{ResourceType: "ServiceProviderConfig", Location: BasePath + "/ServiceProviderConfig"},
+	}
+	c.Filter.Supported = true
+	c.Filter.MaxResults = MaxResults
+	return c
+}
@@ -0,0 +1,214 @@
+// Package scim holds the protocol side of the SCIM 2.0 provisioning endpoint (RFC 7643 and 7644):
+// the User resource and its mapping from model.User, filter expressions, PATCH operations, the
+// discovery documents and SCIM errors. The endpoint itself is controller.SCIMController, which
+// stores users through service.UserService like the rest of the API.
+package scim
+
+import (
+	"cruder/internal/model"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// MediaType is the content type of SCIM requests and responses.
+const MediaType = "application/scim+json"
+
+// Schema URNs
+const (
+	UserSchema                  = "urn:ietf:params:scim:schemas:core:2.0:User"
+	ListResponseSchema          = "urn:ietf:params:scim:api:messages:2.0:ListResponse"
+	PatchOpSchema               = "urn:ietf:params:scim:api:messages:2.0:PatchOp"
+	ErrorSchema                 = "urn:ietf:params:scim:api:messages:2.0:Error"
+	ServiceProviderConfigSchema = "urn:ietf:params:scim:schemas:core:2.0:ServiceProviderConfig"
+	ResourceTypeSchema          = "urn:ietf:params:scim:schemas:core:2.0:ResourceType"
+	SchemaSchema                = "urn:ietf:params:scim:schemas:core:2.0:Schema"
+)
+
+// BasePath is where the endpoint is served.
+const BasePath = "/scim/v2"
+
+// UsersPath is the Users resource endpoint.
+const UsersPath = BasePath + "/Users"
+
+// Page size bounds of list requests
+const (
+	DefaultCount = 100
+	MaxResults   = 1000
+)
+
+// scimType values of errors (RFC 7644 §3.12)
+const (
+	InvalidFilter = "invalidFilter"
+	TooMany       = "tooMany"
+	Uniqueness    = "uniqueness"
+	Mutability    = "mutability"
+	InvalidSyntax = "invalidSyntax"
+	InvalidPath   = "invalidPath"
+	NoTarget      = "noTarget"
+	InvalidValue  = "invalidValue"
+)
+
+// Error is a SCIM error response.
+type Error struct {
+	Schemas  []string `json:"schemas"`
+	Status   string   `json:"status"`
+	ScimType string   `json:"scimType,omitempty"`
+	Detail   string   `json:"detail,omitempty"`
+}
+
+func (e *Error) Error() string {
+	if e.ScimType == "" {
+		return e.Detail
+	}
+	return e.ScimType + ": " + e.Detail
+}
+
+// StatusCode is the HTTP status of the error.
+func (e *Error) StatusCode() int {
+	n, err := strconv.Atoi(e.Status)
+	if err != nil {
+		return http.StatusInternalServerError
+	}
+	return n
+}
+
+// Errorf returns an Error with status, scimType and a formatted detail.
+func Errorf(status int, scimType, format string, args ...any) *Error {
+	return &Error{
+		Schemas:  []string{ErrorSchema},
+		Status:   strconv.Itoa(status),
+		ScimType: scimType,
+		Detail:   fmt.Sprintf(format, args...),
+	}
+}
+
+// User is the SCIM User resource (RFC 7643 §4.1) for a model.User.
+type User struct {
+	Schemas  []string `json:"schemas"`
+	ID       string   `json:"id,omitempty"`
+	UserName string   `json:"userName"`
+	Name     *Name    `json:"name,omitempty"`
+	// DisplayName is the full name too; name.formatted wins when a request sets both
+	DisplayName string  `json:"displayName,omitempty"`
+	Emails      []Email `json:"emails,omitempty"`
+	// Active is true for active users and false for invited, suspended and deactivated ones.
+	// It is read-only: status changes go through the REST API's transition endpoints
+	Active *bool `json:"active,omitempty"`
+	Meta   *Meta `json:"meta,omitempty"`
+}
+
+// Name holds the user's full name in Formatted. GivenName and FamilyName are accepted from
+// identity providers that do not send formatted, and never returned.
+type Name struct {
+	Formatted  string `json:"formatted,omitempty"`
+	GivenName  string `json:"givenName,omitempty"`
+	FamilyName string `json:"familyName,omitempty"`
+}
+
+type Email struct {
+	Value   string `json:"value"`
+	Type    string `json:"type,omitempty"`
+	Primary bool   `json:"primary,omitempty"`
+}
+
+type Meta struct {
+	ResourceType string    `json:"resourceType"`
+	Created      time.Time `json:"created"`
+	LastModified time.Time `json:"lastModified"`
+	Location     string    `json:"location"`
+}
+
+// ListResponse is the response to a query (RFC 7644 §3.4.2).
+type ListResponse struct {
+	Schemas      []string `json:"schemas"`
+	TotalResults int      `json:"totalResults"`
+	StartIndex   int      `json:"startIndex"`
+	ItemsPerPage int      `json:"itemsPerPage"`
+	Resources    any      `json:"Resources"`
+}
+
+// NewListResponse returns a page of resources starting at the 1-based startIndex.
+func NewListResponse[T any](total, startIndex int, resources []T) *ListResponse {
+	if resources == nil {
+		resources = []T{}
+	}
+	return &ListResponse{
+		Schemas:      []string{ListResponseSchema},
+		TotalResults: total,
+		StartIndex:   startIndex,
+		ItemsPerPage: len(resources),
+		Resources:    resources,
+	}
+}
+
+// FromUser returns the resource of user.
+func FromUser(user *model.User) *User {
+	active := user.Status == model.UserActive
+	return &User{
+		Schemas:     []string{UserSchema},
+		ID:          user.ID.String(),
+		UserName:    user.Username,
+		Name:        &Name{Formatted: user.FullName},
+		DisplayName: user.FullName,
+		Emails:      []Email{{Value: user.Email, Type: "work", Primary: true}},
+		Active:      &active,
+		Meta: &Meta{
+			ResourceType: "User",
+			Created:      user.CreatedAt.UTC(),
+			LastModified: user.UpdatedAt.UTC(),
+			Location:     UsersPath + "/" + user.ID.String(),
+		},
+	}
+}
+
+// ToRequest maps a resource sent by a client to the user it describes. Only the writable
+// attributes count: the email is the primary one, else the first, and the full name comes from
+// name.formatted, displayName or name.givenName and familyName, in that order. Read-only
+// attributes are ignored, as RFC 7644 §3.5.1 asks of PUT.
+func (u *User) ToRequest() (*model.CreateUserRequest, error) {
+	req := &model.CreateUserRequest{Username: u.UserName}
+	switch {
+	case u.Name != nil && strings.TrimSpace(u.Name.Formatted) != "":
+		req.FullName = u.Name.Formatted
+	case strings.TrimSpace(u.DisplayName) != "":
+		req.FullName = u.DisplayName
+	case u.Name != nil:
+		req.FullName = strings.TrimSpace(u.Name.GivenName + " " + u.Name.FamilyName)
+	}
+	if len(u.Emails) == 0 {
+		return nil, Errorf(http.StatusBadRequest, InvalidValue, "emails must hold the user's email address")
+	}
+	req.Email = u.Emails[0].Value
+	for _, e := range u.Emails {
+		if e.Primary {
+			req.Email = e.Value
+			break
+		}
+	}
+	return req, nil
+}
+
+// toMap returns the resource as the generic JSON object filters and patches work on.
+func (u *User) toMap() map[string]any {
+	data, _ := json.Marshal(u)
+	var m map[string]any
+	_ = json.Unmarshal(data, &m)
+	return m
+}
+
+// userFromMap converts a patched JSON object back into a resource.
+func userFromMap(m map[string]any) (*User, error) {
+	data, err := json.Marshal(m)
+	if err != nil {
+		return nil, Errorf(http.StatusBadRequest, InvalidValue, "%v", err)
+	}
+	var u User
+	if err := json.Unmarshal(data, &u); err != nil {
+		return nil, Errorf(http.StatusBadRequest, InvalidValue, "the patched user is not valid: %v", err)
+	}
+	return &u, nil
+}
@@ -0,0 +1,189 @@
+package scim
+
+import (
+	"cruder/internal/model"
+	"encoding/json"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func testUser() *User {
+	created := time.Date(2026, 3, 1, 12, 0, 0, 0, time.UTC)
+	return FromUser(&model.User{
+		ID:        uuid.New(),
+		Username:  "jdoe",
+		Email:     "jdoe@example.com",
+		FullName:  "John Doe",
+		Status:    model.UserActive,
+		CreatedAt: created,
+		UpdatedAt: created.Add(24 * time.Hour),
+	})
+}
+
+func TestFilter(t *testing.T) {
+	user := testUser()
+	for filter, want := range map[string]bool{
+		`userName eq "JDOE"`:                                               true,
+		`userName ne "jdoe"`:                                               false,
+		`emails.value co "@example.com"`:                                   true,
+		`emails co "@example.com"`:                                         true,
+		`emails[type eq "work" and value ew ".com"]`:                       true,
+		`emails[type eq "home"]`:                                           false,
+		`meta.lastModified gt "2026-03-02T12:00:00Z"`:                      false,
+		`meta.lastModified ge "2026-03-02T12:00:00Z"`:                      true,
+		`name.formatted sw "john" and not (displayName pr)`:                false,
+		`(userName eq "x" or name.formatted sw "john") and active eq true`: true,
+		`urn:ietf:params:scim:schemas:core:2.0:User:userName sw "j"`:       true,
+		`name.givenName pr`:                                                false,
+		`displayName eq null`:                                              false,
+	} {
+		f, err := ParseFilter(filter)
+		require.NoError(t, err, filter)
+		assert.Equal(t, want, f.Matches(user), filter)
+	}
+}
+
+func TestFromUser_Active(t *testing.T) {
+	assert.True(t, *testUser().Active)
+	for _, status := range []model.UserStatus{model.UserInvited, model.UserSuspended, model.UserDeactivated} {
+		assert.False(t, *FromUser(&model.User{Status: status}).Active, status)
+	}
+}
+
+func TestFilter_Invalid(t *testing.T) {
+	for _, filter := range []string{
+		``,
+		`nickName eq "x"`,
+		`userName eq`,
+		`userName like "x"`,
+		`userName eq 5`,
+		`active gt true`,
+		`meta.created gt "yesterday"`,
+		`name eq "x"`,
+		`userName[value eq "x"]`,
+		`(userName eq "x"`,
+		`userName eq "x" extra`,
+		`userName eq "unterminated`,
+	} {
+		_, err := ParseFilter(filter)
+		var se *Error
+		require.ErrorAs(t, err, &se, filter)
+		assert.Equal(t, InvalidFilter, se.ScimType, filter)
+		assert.Equal(t, 400, se.StatusCode())
+	}
+}
+
+func TestFilter_UserName(t *testing.T) {
+	f, err := ParseFilter(`userName eq "jdoe"`)
+	require.NoError(t, err)
+	name, ok := f.UserName()
+	assert.True(t, ok)
+	assert.Equal(t, "jdoe", name)
+
+	f, err = ParseFilter(`userName eq "jdoe" and active eq true`)
+	require.NoError(t, err)
+	_, ok = f.UserName()
+	assert.False(t, ok)
+}
+
+func patch(t *testing.T, body string) *PatchRequest {
+	t.Helper()
+	var req PatchRequest
+	require.NoError(t, json.Unmarshal([]byte(body), &req))
+	return &req
+}
+
+func TestPatch(t *testing.T) {
+	user := testUser()
+	req := patch(t, `{
+		"schemas": ["urn:ietf:params:scim:api:messages:2.0:PatchOp"],
+		"Operations": [
+			{"op": "Replace", "path": "userName", "value": "jsmith"},
+			{"op": "replace", "path": "emails[type eq \"work\"].value", "value": "jsmith@example.com"},
+			{"op": "add", "value": {"displayName": "Jane Smith", "Active": true}}
+		]
+	}`)
+
+	patched, err := req.Apply(user)
+	require.NoError(t, err)
+	create, err := patched.ToRequest()
+	require.NoError(t, err)
+	assert.Equal(t, "jsmith", create.Username)
+	assert.Equal(t, "jsmith@example.com", create.Email)
+	// name.formatted still holds the old name and wins over displayName
+	assert.Equal(t, "John Doe", create.FullName)
+}
+
+func TestPatch_GivenAndFamilyName(t *testing.T) {
+	req := patch(t, `{
+		"schemas": ["urn:ietf:params:scim:api:messages:2.0:PatchOp"],
+		"Operations": [
+			{"op": "replace", "path": "name.givenName", "value": "Jane"},
+			{"op": "replace", "path": "name.familyName", "value": "Smith"}
+		]
+	}`)
+
+	patched, err := req.Apply(testUser())
+	require.NoError(t, err)
+	create, err := patched.ToRequest()
+	require.NoError(t, err)
+	assert.Equal(t, "Jane Smith", create.FullName)
+}
+
+func TestPatch_AddPrimaryEmail(t *testing.T) {
+	req := patch(t, `{
+		"schemas": ["urn:ietf:params:scim:api:messages:2.0:PatchOp"],
+		"Operations": [{"op": "add", "path": "emails", "value": [{"value": "new@example.com", "primary": true}]}]
+	}`)
+
+	patched, err := req.Apply(testUser())
+	require.NoError(t, err)
+	require.Len(t, patched.Emails, 2)
+	create, err := patched.ToRequest()
+	require.NoError(t, err)
+	assert.Equal(t, "new@example.com", create.Email)
+}
+
+func TestPatch_Errors(t *testing.T) {
+	for name, tc := range map[string]struct {
+		body     string
+		scimType string
+	}{
+		"missing schema": {`{"Operations": [{"op": "remove", "path": "displayName"}]}`, InvalidSyntax},
+		"no operations":  {`{"schemas": ["urn:ietf:params:scim:api:messages:2.0:PatchOp"]}`, InvalidSyntax},
+		"unknown op":     {`{"schemas": ["urn:ietf:params:scim:api:messages:2.0:PatchOp"], "Operations": [{"op": "move", "path": "userName"}]}`, InvalidSyntax},
+		"unknown path":   {`{"schemas": ["urn:ietf:params:scim:api:messages:2.0:PatchOp"], "Operations": [{"op": "remove", "path": "nickName"}]}`, InvalidPath},
+		"bad filter":     {`{"schemas": ["urn:ietf:params:scim:api:messages:2.0:PatchOp"], "Operations": [{"op": "remove", "path": "emails[kind eq \"x\"]"}]}`, InvalidPath},
+		"remove no path": {`{"schemas": ["urn:ietf:params:scim:api:messages:2.0:PatchOp"], "Operations": [{"op": "remove"}]}`, NoTarget},
+		"no match":       {`{"schemas": ["urn:ietf:params:scim:api:messages:2.0:PatchOp"], "Operations": [{"op": "replace", "path": "emails[type eq \"home\"].value", "value": "x@example.com"}]}`, NoTarget},
+		"read-only":      {`{"schemas": ["urn:ietf:params:scim:api:messages:2.0:PatchOp"], "Operations": [{"op": "replace", "path": "id", "value": "other"}]}`, Mutability},
+		"deactivate":     {`{"schemas": ["urn:ietf:params:scim:api:messages:2.0:PatchOp"], "Operations": [{"op": "replace", "path": "active", "value": false}]}`, Mutability},
+		"missing value":  {`{"schemas": ["urn:ietf:params:scim:api:messages:2.0:PatchOp"], "Operations": [{"op": "replace", "path": "userName"}]}`, InvalidValue},
+	} {
+		_, err := patch(t, tc.body).Apply(testUser())
+		var se *Error
+		require.ErrorAs(t, err, &se, name)
+		assert.Equal(t, tc.scimType, se.ScimType, name)
+	}
+}
+
+func TestToRequest(t *testing.T) {
+	u := &User{
+		UserName: "jdoe",
+		Name:     &Name{GivenName: "John", FamilyName: "Doe"},
+		Emails:   []Email{{Value: "home@example.com"}, {Value: "work@example.com", Primary: true}},
+	}
+	req, err := u.ToRequest()
+	require.NoError(t, err)
+	assert.Equal(t, &model.CreateUserRequest{Username: "jdoe", Email: "work@example.com", FullName: "John Doe"}, req)
+
+	u.Emails = nil
+	_, err = u.ToRequest()
+	var se *Error
+	require.ErrorAs(t, err, &se)
+	assert.Equal(t, InvalidValue, se.ScimType)
+}
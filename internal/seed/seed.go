@@ -0,0 +1,190 @@
+package seed
+
+import (
+	"cruder/internal/errors"
+	"cruder/internal/model"
+	"cruder/internal/service"
+	"encoding/json"
+	"fmt"
+	"io/fs"
+	"math/rand/v2"
+	"path"
+	"sort"
+	"strings"
+
+	"github.com/gin-gonic/gin/binding"
+	"gopkg.in/yaml.v3"
+
+	stdErrors "errors"
+)
+
+// Fixture is the on-disk format of a seed file (YAML or JSON).
+type Fixture struct {
+	Users []UserFixture `json:"users" yaml:"users"`
+}
+
+// UserFixture mirrors model.CreateUserRequest with YAML tags.
+type UserFixture struct {
+	Username string `json:"username" yaml:"username"`
+	Email    string `json:"email" yaml:"email"`
+	FullName string `json:"full_name" yaml:"full_name"`
+}
+
+// Report summarizes a seeding run.
+type Report struct {
+	Created int
+	Skipped int // already existed (username or email taken)
+	Failed  []error
+}
+
+// Seeder loads users through UserService.Create so seeded rows go through
+// the same binding rules, normalization and business validation as API requests.
+type Seeder struct {
+	users service.UserService
+}
+
+func NewSeeder(users service.UserService) *Seeder {
+	return &Seeder{users: users}
+}
+
+// LoadDir reads every *.yaml, *.yml and *.json file in dir (non-recursive, sorted by name).
+// A missing directory yields no users rather than an error: not every environment has fixtures.
+func LoadDir(fsys fs.FS, dir string) ([]model.CreateUserRequest, error) {
+	entries, err := fs.ReadDir(fsys, dir)
+	if err != nil {
+		if stdErrors.Is(err, fs.ErrNotExist) {
+			return nil, nil
+		}
+		return nil, fmt.Errorf("failed to read seed directory %q: %w", dir, err)
+	}
+
+	names := make([]string, 0, len(entries))
+	for _, e := range entries {
+		if !e.IsDir() && isFixtureFile(e.Name()) {
+			names = append(names, e.Name())
+		}
+	}
+	sort.Strings(names)
+
+	var reqs []model.CreateUserRequest
+	for _, name := range names {
+		fileReqs, err := LoadFile(fsys, path.Join(dir, name))
+		if err != nil {
+			return nil, err
+		}
+		reqs = append(reqs, fileReqs...)
+	}
+	return reqs, nil
+}
+
+// LoadFile parses a single fixture file; the format is chosen by extension.
+func LoadFile(fsys fs.FS, name string) ([]model.CreateUserRequest, error) {
+	data, err := fs.ReadFile(fsys, name)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read seed file %q: %w", name, err)
+	}
+
+	var fixture Fixture
+	switch strings.ToLower(path.Ext(name)) {
+	case ".yaml", ".yml":
+		err = yaml.Unmarshal(data, &fixture)
+	case ".json":
+		err = json.Unmarshal(data, &fixture)
+	default:
+		return nil, fmt.Errorf("unsupported seed file %q: expected .yaml, .yml or .json", name)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse seed file %q: %w", name, err)
+	}
+
+	reqs := make([]model.CreateUserRequest, 0, len(fixture.Users))
+	for _, u := range fixture.Users {
+		reqs = append(reqs, model.CreateUserRequest{
+			Username: u.Username,
+			Email:    u.Email,
+			FullName: u.FullName,
+		})
+	}
+	return reqs, nil
+}
+
+func isFixtureFile(name string) bool {
+	switch strings.ToLower(path.Ext(name)) {
+	case ".yaml", ".yml", ".json":
+		return true
+	}
+	return false
+}
+
+// Run creates every user, treating already-existing users as skipped so seeding is re-runnable.
+func (s *Seeder) Run(reqs []model.CreateUserRequest) Report {
+	var report Report
+	for i := range reqs {
+		req := reqs[i]
+
+		// Same struct-tag rules the HTTP layer enforces via ShouldBindJSON
+		if err := binding.Validator.ValidateStruct(&req); err != nil {
+			report.Failed = append(report.Failed, fmt.Errorf("user %q: %w: %v", req.Username, errors.ErrInvalidInput, err))
+			continue
+		}
+
+		if _, err := s.users.Create(&req); err != nil {
+			if stdErrors.Is(err, errors.ErrUsernameExists) || stdErrors.Is(err, errors.ErrEmailExists) {
+				report.Skipped++
+				continue
+			}
+			report.Failed = append(report.Failed, fmt.Errorf("user %q: %w", req.Username, err))
+			continue
+		}
+		report.Created++
+	}
+	return report
+}
+
+var (
+	firstNames = []string{
+		"Olivia", "Liam", "Emma", "Noah", "Amelia", "Oliver", "Sophia", "Elijah", "Mia", "James",
+		"Isabella", "William", "Ava", "Lucas", "Charlotte", "Henry", "Harper", "Mateo", "Evelyn", "Ethan",
+		"Aisha", "Omar", "Fatima", "Yusuf", "Mei", "Hiroshi", "Priya", "Arjun", "Ingrid", "Lars",
+	}
+	lastNames = []string{
+		"Smith", "Johnson", "Williams", "Brown", "Jones", "Garcia", "Miller", "Davis", "Rodriguez", "Martinez",
+		"Anderson", "Taylor", "Thomas", "Moore", "Jackson", "Martin", "Lee", "Thompson", "White", "Harris",
+		"Khan", "Ahmed", "Tanaka", "Chen", "Patel", "Singh", "Larsen", "Nielsen", "O'Brien", "Smith-Jones",
+	}
+	emailDomains = []string{"example.com", "example.org", "example.net"}
+)
+
+// FakeUsers generates n realistic-looking users for load testing.
+// The same seed always produces the same users, so runs are reproducible.
+func FakeUsers(n int, seed uint64) []model.CreateUserRequest {
+	rng := rand.New(rand.NewPCG(seed, seed^0x9e3779b97f4a7c15)) // #nosec G404 -- test data, not security sensitive
+
+	reqs := make([]model.CreateUserRequest, 0, n)
+	for i := 0; i < n; i++ {
+		first := firstNames[rng.IntN(len(firstNames))]
+		last := lastNames[rng.IntN(len(lastNames))]
+
+		// Index suffix keeps usernames/emails unique within a run; alphanum only to satisfy binding rules
+		username := fmt.Sprintf("%s%s%d", alphanumOnly(first), alphanumOnly(last), i)
+		if len(username) > 50 {
+			username = username[len(username)-50:]
+		}
+
+		reqs = append(reqs, model.CreateUserRequest{
+			Username: strings.ToLower(username),
+			Email:    fmt.Sprintf("%s.%s%d@%s", strings.ToLower(alphanumOnly(first)), strings.ToLower(alphanumOnly(last)), i, emailDomains[rng.IntN(len(emailDomains))]),
+			FullName: first + " " + last,
+		})
+	}
+	return reqs
+}
+
+func alphanumOnly(s string) string {
+	return strings.Map(func(r rune) rune {
+		if (r >= 'a' && r <= 'z') || (r >= 'A' && r <= 'Z') || (r >= '0' && r <= '9') {
+			return r
+		}
+		return -1
+	}, s)
+}
@@ -0,0 +1,83 @@
+package seed
+
+import (
+	"cruder/internal/errors"
+	"cruder/internal/model"
+	"cruder/internal/service"
+	"cruder/seeds"
+	"testing"
+	"testing/fstest"
+
+	"github.com/gin-gonic/gin/binding"
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// stubUserService records created users and rejects duplicates by username.
+type stubUserService struct {
+	service.UserService
+	created map[string]bool
+}
+
+func (s *stubUserService) Create(req *model.CreateUserRequest) (*model.User, error) {
+	if s.created[req.Username] {
+		return nil, errors.ErrUsernameExists
+	}
+	s.created[req.Username] = true
+	return &model.User{ID: uuid.New(), Username: req.Username}, nil
+}
+
+func TestLoadDir_EmbeddedEnvironments(t *testing.T) {
+	dev, err := LoadDir(seeds.FS, "development")
+	require.NoError(t, err)
+	assert.Len(t, dev, 3)
+	assert.Equal(t, "jdoe", dev[0].Username)
+
+	staging, err := LoadDir(seeds.FS, "staging")
+	require.NoError(t, err)
+	assert.NotEmpty(t, staging)
+
+	// Production has no fixtures on purpose
+	prod, err := LoadDir(seeds.FS, "production")
+	require.NoError(t, err)
+	assert.Empty(t, prod)
+}
+
+func TestLoadFile_UnsupportedExtension(t *testing.T) {
+	fsys := fstest.MapFS{"users.txt": {Data: []byte("users: []")}}
+
+	_, err := LoadFile(fsys, "users.txt")
+
+	assert.Error(t, err)
+}
+
+func TestFakeUsers_ValidAndReproducible(t *testing.T) {
+	users := FakeUsers(200, 42)
+
+	require.Len(t, users, 200)
+	assert.Equal(t, users, FakeUsers(200, 42))
+
+	usernames := map[string]bool{}
+	for _, u := range users {
+		assert.NoError(t, binding.Validator.ValidateStruct(&u), u.Username)
+		assert.False(t, usernames[u.Username], "duplicate username %s", u.Username)
+		usernames[u.Username] = true
+	}
+}
+
+func TestRun_SkipsExistingAndReportsInvalid(t *testing.T) {
+	svc := &stubUserService{created: map[string]bool{"jdoe": true}}
+	seeder := NewSeeder(svc)
+
+	report := seeder.Run([]model.CreateUserRequest{
+		{Username: "jdoe", Email: "jdoe@example.com", FullName: "John Doe"},
+		{Username: "asmith", Email: "asmith@example.com", FullName: "Alice Smith"},
+		{Username: "x", Email: "not-an-email", FullName: "Bad Row"},
+	})
+
+	assert.Equal(t, 1, report.Created)
+	assert.Equal(t, 1, report.Skipped)
+	require.Len(t, report.Failed, 1)
+	assert.ErrorIs(t, report.Failed[0], errors.ErrInvalidInput)
+}
@@ -0,0 +1,156 @@
+package service
+
+import (
+	"cruder/internal/errors"
+	"cruder/internal/model"
+	"cruder/internal/password"
+	"cruder/internal/repository"
+	"fmt"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/google/uuid"
+
+	stdErrors "errors"
+)
+
+// Password rules, in the spirit of NIST SP 800-63B: length over composition, and no
+// passwords that are common or derived from the account itself.
+const (
+	MinPasswordLength = 12
+	MaxPasswordLength = 128
+	// minDistinctChars rejects passwords like "aaaaaaaaaaaa" or "abababababab"
+	minDistinctChars = 5
+)
+
+// commonPasswords are rejected outright (compared case-insensitively). Not a breach corpus,
+// just the ones that pass the length rule and turn up at the top of every leaked list.
+var commonPasswords = map[string]bool{
+	"123456789012": true, "1234567890123": true, "12345678901234": true,
+	"qwertyuiopasdf": true, "qwertyuiop123": true, "qwerty123456": true, "1q2w3e4r5t6y": true,
+	"password1234": true, "password12345": true, "password123456": true, "passwordpassword": true,
+	"iloveyou1234": true, "letmein12345": true, "welcome12345": true, "welcome123456": true,
+	"administrator": true, "changeme1234": true, "trustno1trustno1": true, "football1234": true,
+	"baseball1234": true, "sunshine1234": true, "princess1234": true, "superman1234": true,
+	"correcthorsebatterystaple": true, "correct horse battery staple": true,
+}
+
+// AuthService verifies and manages the passwords of the users of one organization.
+type AuthService interface {
+	// InOrganization returns the service for the users of org.
+	InOrganization(org uuid.UUID) AuthService
+	// Login returns the user whose username or email is login if password is theirs, and
+	// ErrInvalidCredentials otherwise, taking about as long whether or not the user exists.
+	// A user who is not active is ErrUserNotActive, which is only told once the password matched.
+	// A hash made with outdated parameters is transparently replaced.
+	Login(login, password string) (*model.User, error)
+	// ChangePassword sets a user's password. Once one is set, the current password must be given
+	// (ErrWrongPassword if it does not match). The new one must pass ValidatePassword.
+	ChangePassword(id uuid.UUID, req *model.ChangePasswordRequest) error
+	// ValidatePassword applies the password rules for user, returning ErrInvalidInput on a breach.
+	ValidatePassword(password string, user *model.User) error
+}
+
+type authService struct {
+	repo   repository.UserRepository
+	hasher *password.Hasher
+}
+
+func NewAuthService(repo repository.UserRepository, hasher *password.Hasher) AuthService {
+	return &authService{repo: repo, hasher: hasher}
+}
+
+func (s *authService) InOrganization(org uuid.UUID) AuthService {
+	return &authService{repo: s.repo.InOrganization(org), hasher: s.hasher}
+}
+
+func (s *authService) Login(login, pw string) (*model.User, error) {
+	creds, err := s.repo.GetCredentials(strings.TrimSpace(strings.ToLower(login)))
+	if err != nil && !stdErrors.Is(err, errors.ErrUserNotFound) {
+		return nil, err
+	}
+	if err != nil || creds.PasswordHash == "" {
+		// Same work as a wrong password, so response times don't tell which accounts exist
+		s.hasher.VerifyDummy(pw)
+		return nil, errors.ErrInvalidCredentials
+	}
+
+	ok, rehash, err := s.hasher.Verify(pw, creds.PasswordHash)
+	if err != nil {
+		return nil, fmt.Errorf("failed to verify password of user %s: %w", creds.User.ID, err)
+	}
+	if !ok {
+		return nil, errors.ErrInvalidCredentials
+	}
+	if creds.User.Status != model.UserActive {
+		return nil, errors.ErrUserNotActive
+	}
+	if rehash {
+		// Best effort: the login itself succeeded, and the next one will try again
+		if hash, err := s.hasher.Hash(pw); err == nil {
+			_ = s.repo.SetPasswordHash(creds.User.ID, hash)
+		}
+	}
+	return &creds.User, nil
+}
+
+func (s *authService) ChangePassword(id uuid.UUID, req *model.ChangePasswordRequest) error {
+	creds, err := s.repo.GetCredentialsByID(id)
+	if err != nil {
+		return err
+	}
+
+	if creds.PasswordHash != "" {
+		if req.CurrentPassword == "" {
+			return errors.ErrWrongPassword
+		}
+		ok, _, err := s.hasher.Verify(req.CurrentPassword, creds.PasswordHash)
+		if err != nil {
+			return fmt.Errorf("failed to verify password of user %s: %w", id, err)
+		}
+		if !ok {
+			return errors.ErrWrongPassword
+		}
+		if req.NewPassword == req.CurrentPassword {
+			return fmt.Errorf("%w: new password must differ from the current one", errors.ErrInvalidInput)
+		}
+	}
+
+	if err := s.ValidatePassword(req.NewPassword, &creds.User); err != nil {
+		return err
+	}
+	hash, err := s.hasher.Hash(req.NewPassword)
+	if err != nil {
+		return err
+	}
+	return s.repo.SetPasswordHash(id, hash)
+}
+
+func (s *authService) ValidatePassword(pw string, user *model.User) error {
+	length := utf8.RuneCountInString(pw)
+	if length < MinPasswordLength || length > MaxPasswordLength {
+		return fmt.Errorf("%w: password must be between %d and %d characters", errors.ErrInvalidInput, MinPasswordLength, MaxPasswordLength)
+	}
+
+	distinct := make(map[rune]bool)
+	for _, r := range pw {
+		distinct[r] = true
+	}
+	if len(distinct) < minDistinctChars {
+		return fmt.Errorf("%w: password must use at least %d different characters", errors.ErrInvalidInput, minDistinctChars)
+	}
+
+	lower := strings.ToLower(pw)
+	if commonPasswords[lower] {
+		return fmt.Errorf("%w: password is too common", errors.ErrInvalidInput)
+	}
+	if user != nil {
+		localPart, _, _ := strings.Cut(user.Email, "@")
+		for _, personal := range []string{user.Username, localPart} {
+			if len(personal) >= 3 && strings.Contains(lower, strings.ToLower(personal)) {
+				return fmt.Errorf("%w: password must not contain the username or email", errors.ErrInvalidInput)
+			}
+		}
+	}
+	return nil
+}
@@ -0,0 +1,154 @@
+package service
+
+import (
+	"cruder/internal/metrics"
+	"cruder/internal/model"
+	"cruder/internal/repository"
+	"log/slog"
+	"time"
+)
+
+// DefaultAuthFailureWindow is how long failures are remembered when AuthFailureOptions.Window is zero.
+const DefaultAuthFailureWindow = 15 * time.Minute
+
+// AuthFailureService tracks wrong API keys and passwords per client IP and makes clients that keep presenting
+// them wait, longer after each failure, and eventually bans them for a while. The records live
+// in Postgres, so every replica enforces the same delays and bans.
+//
+// A correct key does not clear the record: a client holding one key must not be able to reset
+// its count while guessing others.
+type AuthFailureService interface {
+	// Check returns until when ip must wait before trying an API key, or the zero time if it may try now.
+	Check(ip string) (time.Time, error)
+	// Failed records a wrong API key from ip and returns until when it must now wait, or the zero time.
+	Failed(ip string) (time.Time, error)
+	// FailedLogin records a wrong password from ip at the OpenID Connect sign-in page. It counts
+	// towards the same delays and bans as wrong API keys.
+	FailedLogin(ip string) (time.Time, error)
+	// ListBans returns the clients banned now, latest failure first.
+	ListBans() ([]model.AuthFailure, error)
+	// LiftBan lets ip try again at once, forgetting its failures. actor is logged as who lifted it.
+	// Returns ErrBanNotFound unless ip is banned.
+	LiftBan(ip, actor string) error
+	// PruneExpired deletes the records of clients that neither failed within the window nor are blocked.
+	PruneExpired() (int64, error)
+}
+
+// AuthFailureOptions set the thresholds. A zero DelayAfter or BanAfter turns delays or bans off.
+type AuthFailureOptions struct {
+	// Window is how long a failure counts: a client that does not fail for that long starts over
+	Window time.Duration
+	// DelayAfter is the number of failures from which the client waits DelayBase, doubling with
+	// each further failure up to DelayMax
+	DelayAfter int
+	DelayBase  time.Duration
+	DelayMax   time.Duration
+	// BanAfter is the number of failures at which the client is banned for BanDuration
+	BanAfter    int
+	BanDuration time.Duration
+}
+
+type authFailureService struct {
+	repo   repository.AuthFailureRepository
+	opts   AuthFailureOptions
+	logger *slog.Logger
+	now    func() time.Time
+}
+
+func NewAuthFailureService(repo repository.AuthFailureRepository, opts AuthFailureOptions, logger *slog.Logger) AuthFailureService {
+	if opts.Window == 0 {
+		opts.Window = DefaultAuthFailureWindow
+	}
+	return &authFailureService{repo: repo, opts: opts, logger: logger, now: time.Now}
+}
+
+// delay is how long a client with failures must wait before its next attempt.
+func (s *authFailureService) delay(failures int) time.Duration {
+	if s.opts.DelayAfter <= 0 || failures < s.opts.DelayAfter {
+		return 0
+	}
+	d := s.opts.DelayBase
+	for i := s.opts.DelayAfter; i < failures && (s.opts.DelayMax <= 0 || d < s.opts.DelayMax); i++ {
+		d *= 2
+	}
+	if s.opts.DelayMax > 0 && d > s.opts.DelayMax {
+		d = s.opts.DelayMax
+	}
+	return d
+}
+
+func (s *authFailureService) Check(ip string) (time.Time, error) {
+	f, err := s.repo.Get(ip)
+	if err != nil || f == nil || !f.BlockedAt(s.now()) {
+		return time.Time{}, err
+	}
+	return *f.BlockedUntil, nil
+}
+
+func (s *authFailureService) Failed(ip string) (time.Time, error) {
+	return s.failed(ip, "api_key_rejected", "Rejected API key")
+}
+
+func (s *authFailureService) FailedLogin(ip string) (time.Time, error) {
+	return s.failed(ip, "password_rejected", "Rejected password")
+}
+
+// failed records a failure from ip, logged as event, and delays or bans the client.
+func (s *authFailureService) failed(ip, event, msg string) (time.Time, error) {
+	now := s.now()
+	f, err := s.repo.RecordFailure(ip, now, now.Add(-s.opts.Window))
+	if err != nil {
+		return time.Time{}, err
+	}
+	s.logger.Warn(msg,
+		slog.String("security_event", event),
+		slog.String("client_ip", ip),
+		slog.Int("failures", f.Failures))
+
+	if s.opts.BanAfter > 0 && f.Failures >= s.opts.BanAfter {
+		until := now.Add(s.opts.BanDuration)
+		if _, err := s.repo.Block(ip, until, true); err != nil {
+			return time.Time{}, err
+		}
+		metrics.AuthBans.Inc("")
+		s.logger.Warn("Banned client after repeated authentication failures",
+			slog.String("security_event", "client_banned"),
+			slog.String("client_ip", ip),
+			slog.Int("failures", f.Failures),
+			slog.Time("until", until))
+		return until, nil
+	}
+
+	if d := s.delay(f.Failures); d > 0 {
+		until := now.Add(d)
+		if _, err := s.repo.Block(ip, until, false); err != nil {
+			return time.Time{}, err
+		}
+		s.logger.Warn("Throttled client after repeated authentication failures",
+			slog.String("security_event", "client_throttled"),
+			slog.String("client_ip", ip),
+			slog.Int("failures", f.Failures),
+			slog.Duration("delay", d))
+		return until, nil
+	}
+	return time.Time{}, nil
+}
+
+func (s *authFailureService) ListBans() ([]model.AuthFailure, error) {
+	return s.repo.ListBanned(s.now())
+}
+
+func (s *authFailureService) LiftBan(ip, actor string) error {
+	if err := s.repo.LiftBan(ip, s.now()); err != nil {
+		return err
+	}
+	s.logger.Info("Lifted client ban",
+		slog.String("security_event", "ban_lifted"),
+		slog.String("client_ip", ip),
+		slog.String("actor", actor))
+	return nil
+}
+
+func (s *authFailureService) PruneExpired() (int64, error) {
+	return s.repo.PruneExpired(s.now().Add(-s.opts.Window))
+}
@@ -0,0 +1,161 @@
+package service
+
+import (
+	"cruder/internal/errors"
+	"cruder/internal/metrics"
+	"cruder/internal/model"
+	"io"
+	"log/slog"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+)
+
+const failingIP = "203.0.113.7"
+
+// newTestAuthFailureService returns an auth failure service over repo whose clock reads *now.
+func newTestAuthFailureService(repo *MockAuthFailureRepository, now *time.Time) *authFailureService {
+	svc := NewAuthFailureService(repo, AuthFailureOptions{
+		Window:      15 * time.Minute,
+		DelayAfter:  3,
+		DelayBase:   time.Second,
+		DelayMax:    4 * time.Second,
+		BanAfter:    6,
+		BanDuration: time.Hour,
+	}, slog.New(slog.NewTextHandler(io.Discard, nil))).(*authFailureService)
+	svc.now = func() time.Time { return *now }
+	return svc
+}
+
+// failures is the record the repository returns after the nth failure from failingIP.
+func failures(n int, at time.Time) *model.AuthFailure {
+	return &model.AuthFailure{IP: failingIP, Failures: n, FirstFailedAt: at, LastFailedAt: at}
+}
+
+func TestAuthFailures_ProgressiveDelay(t *testing.T) {
+	mockRepo := new(MockAuthFailureRepository)
+	now := time.Date(2026, 3, 1, 12, 0, 0, 0, time.UTC)
+	svc := newTestAuthFailureService(mockRepo, &now)
+	since := now.Add(-15 * time.Minute)
+
+	// The first failures only count
+	for n := 1; n <= 2; n++ {
+		mockRepo.On("RecordFailure", failingIP, now, since).Return(failures(n, now), nil).Once()
+		until, err := svc.Failed(failingIP)
+		require.NoError(t, err)
+		assert.True(t, until.IsZero(), "failure %d should not delay", n)
+	}
+	mockRepo.AssertNotCalled(t, "Block", mock.Anything, mock.Anything, mock.Anything)
+
+	// The third failure starts the delay, which doubles with each further one up to the maximum
+	for n, want := range map[int]time.Duration{3: time.Second, 4: 2 * time.Second, 5: 4 * time.Second} {
+		mockRepo.On("RecordFailure", failingIP, now, since).Return(failures(n, now), nil).Once()
+		mockRepo.On("Block", failingIP, now.Add(want), false).Return(nil, nil).Once()
+		until, err := svc.Failed(failingIP)
+		require.NoError(t, err)
+		assert.Equal(t, now.Add(want), until, "failure %d", n)
+	}
+	mockRepo.AssertExpectations(t)
+}
+
+func TestAuthFailures_Check(t *testing.T) {
+	mockRepo := new(MockAuthFailureRepository)
+	now := time.Date(2026, 3, 1, 12, 0, 0, 0, time.UTC)
+	svc := newTestAuthFailureService(mockRepo, &now)
+	blockedUntil := now.Add(time.Second)
+	mockRepo.On("Get", failingIP).Return(&model.AuthFailure{IP: failingIP, Failures: 3, BlockedUntil: &blockedUntil}, nil)
+	mockRepo.On("Get", "198.51.100.1").Return(nil, nil)
+
+	blocked, err := svc.Check(failingIP)
+	require.NoError(t, err)
+	assert.Equal(t, blockedUntil, blocked)
+
+	now = blockedUntil
+	blocked, err = svc.Check(failingIP)
+	require.NoError(t, err)
+	assert.True(t, blocked.IsZero(), "the client may try again once the delay is over")
+
+	blocked, err = svc.Check("198.51.100.1")
+	require.NoError(t, err)
+	assert.True(t, blocked.IsZero(), "clients without failures are not blocked")
+}
+
+func TestAuthFailures_Ban(t *testing.T) {
+	// Given: A client at its fifth failure
+	mockRepo := new(MockAuthFailureRepository)
+	now := time.Date(2026, 3, 1, 12, 0, 0, 0, time.UTC)
+	svc := newTestAuthFailureService(mockRepo, &now)
+	bans := metrics.AuthBans.Value("")
+	mockRepo.On("RecordFailure", failingIP, now, now.Add(-15*time.Minute)).Return(failures(6, now), nil)
+	mockRepo.On("Block", failingIP, now.Add(time.Hour), true).Return(nil, nil)
+
+	// When: It fails a sixth time
+	until, err := svc.Failed(failingIP)
+
+	// Then: It is banned for the ban duration instead of delayed
+	require.NoError(t, err)
+	assert.Equal(t, now.Add(time.Hour), until)
+	assert.Equal(t, bans+1, metrics.AuthBans.Value(""))
+	mockRepo.AssertExpectations(t)
+	mockRepo.AssertNotCalled(t, "Block", failingIP, mock.Anything, false)
+}
+
+func TestAuthFailures_RepositoryError(t *testing.T) {
+	mockRepo := new(MockAuthFailureRepository)
+	now := time.Date(2026, 3, 1, 12, 0, 0, 0, time.UTC)
+	svc := newTestAuthFailureService(mockRepo, &now)
+	mockRepo.On("RecordFailure", failingIP, now, now.Add(-15*time.Minute)).Return(failures(4, now), nil)
+	mockRepo.On("Block", failingIP, now.Add(2*time.Second), false).Return(nil, assert.AnError)
+
+	_, err := svc.Failed(failingIP)
+
+	assert.ErrorIs(t, err, assert.AnError)
+}
+
+func TestAuthFailures_ListBansAndPrune(t *testing.T) {
+	mockRepo := new(MockAuthFailureRepository)
+	now := time.Date(2026, 3, 1, 12, 0, 0, 0, time.UTC)
+	svc := newTestAuthFailureService(mockRepo, &now)
+	banned := []model.AuthFailure{{IP: failingIP, Failures: 6, Banned: true}}
+	mockRepo.On("ListBanned", now).Return(banned, nil)
+	mockRepo.On("PruneExpired", now.Add(-15*time.Minute)).Return(int64(1), nil)
+
+	list, err := svc.ListBans()
+	require.NoError(t, err)
+	assert.Equal(t, banned, list)
+
+	// Failures older than the window no longer count, so their records go
+	pruned, err := svc.PruneExpired()
+	require.NoError(t, err)
+	assert.Equal(t, int64(1), pruned)
+	mockRepo.AssertExpectations(t)
+}
+
+func TestAuthFailures_LiftBan(t *testing.T) {
+	mockRepo := new(MockAuthFailureRepository)
+	now := time.Date(2026, 3, 1, 12, 0, 0, 0, time.UTC)
+	svc := newTestAuthFailureService(mockRepo, &now)
+	mockRepo.On("LiftBan", failingIP, now).Return(nil)
+	mockRepo.On("LiftBan", "198.51.100.1", now).Return(errors.ErrBanNotFound)
+
+	assert.NoError(t, svc.LiftBan(failingIP, "admin"))
+	assert.ErrorIs(t, svc.LiftBan("198.51.100.1", "admin"), errors.ErrBanNotFound)
+	mockRepo.AssertExpectations(t)
+}
+
+func TestAuthFailures_Disabled(t *testing.T) {
+	mockRepo := new(MockAuthFailureRepository)
+	now := time.Date(2026, 3, 1, 12, 0, 0, 0, time.UTC)
+	svc := newTestAuthFailureService(mockRepo, &now)
+	svc.opts.DelayAfter, svc.opts.BanAfter = 0, 0
+	mockRepo.On("RecordFailure", failingIP, now, now.Add(-15*time.Minute)).Return(failures(10, now), nil)
+
+	until, err := svc.Failed(failingIP)
+
+	require.NoError(t, err)
+	assert.True(t, until.IsZero())
+	mockRepo.AssertNotCalled(t, "Block", mock.Anything, mock.Anything, mock.Anything)
+}
@@ -0,0 +1,188 @@
+package service
+
+import (
+	"cruder/internal/errors"
+	"cruder/internal/model"
+	"cruder/internal/password"
+	"strings"
+	"testing"
+
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+)
+
+var cheapHashing = password.Params{Memory: 64, Iterations: 1, Parallelism: 1}
+
+var testUser = model.User{ID: uuid.New(), Username: "jdoe", Email: "john.doe@example.com", FullName: "John Doe", Status: model.UserActive}
+
+// credentials returns testUser's credentials with pw hashed by hasher, or none if pw is empty.
+func credentials(t *testing.T, hasher *password.Hasher, pw string, status model.UserStatus) *model.Credentials {
+	t.Helper()
+	creds := &model.Credentials{User: testUser}
+	creds.User.Status = status
+	if pw != "" {
+		hash, err := hasher.Hash(pw)
+		require.NoError(t, err)
+		creds.PasswordHash = hash
+	}
+	return creds
+}
+
+// verifies matches a password hash that pw verifies against.
+func verifies(hasher *password.Hasher, pw string) any {
+	return mock.MatchedBy(func(hash string) bool {
+		ok, _, err := hasher.Verify(pw, hash)
+		return err == nil && ok
+	})
+}
+
+func TestLogin_Success(t *testing.T) {
+	// Given: A user with a password, found by username or email
+	mockRepo := new(MockUserRepository)
+	hasher := password.NewHasher(cheapHashing)
+	auth := NewAuthService(mockRepo, hasher)
+	creds := credentials(t, hasher, "violet-tangerine-42", model.UserActive)
+	mockRepo.On("GetCredentials", "jdoe").Return(creds, nil)
+	mockRepo.On("GetCredentials", "john.doe@example.com").Return(creds, nil)
+
+	// When: Logging in with either, in any case and with spaces around
+	for _, login := range []string{"jdoe", " JDoe ", "john.doe@example.com", "John.Doe@Example.com"} {
+		user, err := auth.Login(login, "violet-tangerine-42")
+
+		// Then: The login is normalized and the user returned
+		require.NoError(t, err, "login %q", login)
+		assert.Equal(t, testUser.ID, user.ID)
+	}
+	mockRepo.AssertExpectations(t)
+	mockRepo.AssertNotCalled(t, "SetPasswordHash", mock.Anything, mock.Anything)
+}
+
+func TestLogin_InvalidCredentials(t *testing.T) {
+	mockRepo := new(MockUserRepository)
+	hasher := password.NewHasher(cheapHashing)
+	auth := NewAuthService(mockRepo, hasher)
+	mockRepo.On("GetCredentials", "jdoe").Return(credentials(t, hasher, "violet-tangerine-42", model.UserActive), nil)
+	mockRepo.On("GetCredentials", "nopassword").Return(credentials(t, hasher, "", model.UserActive), nil)
+	mockRepo.On("GetCredentials", "nobody").Return(nil, errors.ErrUserNotFound)
+
+	_, err := auth.Login("jdoe", "violet-tangerine-43")
+	assert.ErrorIs(t, err, errors.ErrInvalidCredentials)
+	_, err = auth.Login("nopassword", "anything at all")
+	assert.ErrorIs(t, err, errors.ErrInvalidCredentials, "no password set yet")
+	_, err = auth.Login("nobody", "violet-tangerine-42")
+	assert.ErrorIs(t, err, errors.ErrInvalidCredentials)
+	mockRepo.AssertExpectations(t)
+}
+
+func TestLogin_RepositoryError(t *testing.T) {
+	mockRepo := new(MockUserRepository)
+	auth := NewAuthService(mockRepo, password.NewHasher(cheapHashing))
+	mockRepo.On("GetCredentials", "jdoe").Return(nil, assert.AnError)
+
+	_, err := auth.Login("jdoe", "violet-tangerine-42")
+
+	assert.ErrorIs(t, err, assert.AnError)
+	assert.NotErrorIs(t, err, errors.ErrInvalidCredentials)
+}
+
+func TestLogin_RehashesOutdatedHash(t *testing.T) {
+	// Given: A password hashed with weaker parameters than the service's
+	mockRepo := new(MockUserRepository)
+	stronger := password.NewHasher(password.Params{Memory: 128, Iterations: 2, Parallelism: 1})
+	auth := NewAuthService(mockRepo, stronger)
+	mockRepo.On("GetCredentials", "jdoe").
+		Return(credentials(t, password.NewHasher(cheapHashing), "violet-tangerine-42", model.UserActive), nil).Once()
+	mockRepo.On("SetPasswordHash", testUser.ID, mock.MatchedBy(func(hash string) bool {
+		return strings.Contains(hash, "m=128,t=2,p=1")
+	})).Return(nil).Once()
+
+	// When: The user logs in
+	_, err := auth.Login("jdoe", "violet-tangerine-42")
+
+	// Then: The password is hashed again with the current parameters
+	require.NoError(t, err)
+	mockRepo.AssertExpectations(t)
+
+	// and a current hash is left alone
+	mockRepo.On("GetCredentials", "jdoe").Return(credentials(t, stronger, "violet-tangerine-42", model.UserActive), nil).Once()
+	_, err = auth.Login("jdoe", "violet-tangerine-42")
+	assert.NoError(t, err)
+	mockRepo.AssertNumberOfCalls(t, "SetPasswordHash", 1)
+}
+
+func TestLogin_RejectsInactiveUsers(t *testing.T) {
+	mockRepo := new(MockUserRepository)
+	hasher := password.NewHasher(cheapHashing)
+	auth := NewAuthService(mockRepo, hasher)
+	mockRepo.On("GetCredentials", "jdoe").Return(credentials(t, hasher, "violet-tangerine-42", model.UserSuspended), nil)
+
+	_, err := auth.Login("jdoe", "violet-tangerine-42")
+	assert.ErrorIs(t, err, errors.ErrUserNotActive)
+	_, err = auth.Login("jdoe", "violet-tangerine-43")
+	assert.ErrorIs(t, err, errors.ErrInvalidCredentials, "the status is only told with the right password")
+}
+
+func TestChangePassword_FirstPassword(t *testing.T) {
+	// Given: A user without a password
+	mockRepo := new(MockUserRepository)
+	hasher := password.NewHasher(cheapHashing)
+	auth := NewAuthService(mockRepo, hasher)
+	mockRepo.On("GetCredentialsByID", testUser.ID).Return(credentials(t, hasher, "", model.UserActive), nil)
+	mockRepo.On("SetPasswordHash", testUser.ID, verifies(hasher, "violet-tangerine-42")).Return(nil)
+
+	// When: Setting one, which needs no current password
+	err := auth.ChangePassword(testUser.ID, &model.ChangePasswordRequest{NewPassword: "violet-tangerine-42"})
+
+	// Then: Its hash is stored
+	assert.NoError(t, err)
+	mockRepo.AssertExpectations(t)
+}
+
+func TestChangePassword_RequiresCurrentPassword(t *testing.T) {
+	mockRepo := new(MockUserRepository)
+	hasher := password.NewHasher(cheapHashing)
+	auth := NewAuthService(mockRepo, hasher)
+	mockRepo.On("GetCredentialsByID", testUser.ID).Return(credentials(t, hasher, "violet-tangerine-42", model.UserActive), nil)
+	mockRepo.On("SetPasswordHash", testUser.ID, verifies(hasher, "amber-pelican-1977")).Return(nil)
+
+	err := auth.ChangePassword(testUser.ID, &model.ChangePasswordRequest{NewPassword: "amber-pelican-1977"})
+	assert.ErrorIs(t, err, errors.ErrWrongPassword, "current password required once set")
+	err = auth.ChangePassword(testUser.ID, &model.ChangePasswordRequest{CurrentPassword: "wrong-password-1", NewPassword: "amber-pelican-1977"})
+	assert.ErrorIs(t, err, errors.ErrWrongPassword)
+	err = auth.ChangePassword(testUser.ID, &model.ChangePasswordRequest{CurrentPassword: "violet-tangerine-42", NewPassword: "violet-tangerine-42"})
+	assert.ErrorIs(t, err, errors.ErrInvalidInput)
+	mockRepo.AssertNotCalled(t, "SetPasswordHash", mock.Anything, mock.Anything)
+
+	err = auth.ChangePassword(testUser.ID, &model.ChangePasswordRequest{CurrentPassword: "violet-tangerine-42", NewPassword: "amber-pelican-1977"})
+	assert.NoError(t, err)
+	mockRepo.AssertExpectations(t)
+}
+
+func TestChangePassword_UserNotFound(t *testing.T) {
+	mockRepo := new(MockUserRepository)
+	auth := NewAuthService(mockRepo, password.NewHasher(cheapHashing))
+	mockRepo.On("GetCredentialsByID", testUser.ID).Return(nil, errors.ErrUserNotFound)
+
+	err := auth.ChangePassword(testUser.ID, &model.ChangePasswordRequest{NewPassword: "violet-tangerine-42"})
+
+	assert.ErrorIs(t, err, errors.ErrUserNotFound)
+}
+
+func TestValidatePassword(t *testing.T) {
+	auth := NewAuthService(new(MockUserRepository), password.NewHasher(cheapHashing))
+
+	assert.NoError(t, auth.ValidatePassword("violet-tangerine-42", &testUser))
+	for pw, why := range map[string]string{
+		"short-pw":               "too short",
+		strings.Repeat("x", 129): "too long",
+		"abababababababab":       "too few distinct characters",
+		"Password1234":           "common",
+		"my-jdoe-password":       "contains the username",
+		"JOHN.DOE-rules-2025":    "contains the email local part",
+		"":                       "empty",
+	} {
+		assert.ErrorIs(t, auth.ValidatePassword(pw, &testUser), errors.ErrInvalidInput, why)
+	}
+}
@@ -0,0 +1,392 @@
+package service
+
+import (
+	"context"
+	"cruder/internal/errors"
+	"cruder/internal/mail"
+	"cruder/internal/model"
+	"cruder/internal/repository"
+	"fmt"
+	"log/slog"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+
+	stdErrors "errors"
+)
+
+// Defaults for EmailChangeOptions left zero.
+const (
+	DefaultEmailChangeTTL       = 24 * time.Hour
+	DefaultEmailChangeRevertTTL = 7 * 24 * time.Hour
+)
+
+// EmailChangeService holds email changes until the new address confirms them, and lets the old
+// address cancel or undo them.
+type EmailChangeService interface {
+	// InOrganization returns the service for the users of org. Confirm and Revert find the user
+	// by token, in whichever organization it is.
+	InOrganization(org uuid.UUID) EmailChangeService
+	// Request records newEmail as the user's pending address, replacing any earlier request. It mails
+	// a confirm token to newEmail and a revert token to the user's current address. newEmail must be
+	// normalized; it fails with ErrEmailExists if another user has it or has it pending.
+	// If only the mailing fails, the recorded change is returned along with the error.
+	Request(user *model.User, newEmail string) (*model.PendingEmailChange, error)
+	// Pending returns the user's unexpired pending change, or nil if there is none.
+	Pending(userID uuid.UUID) (*model.PendingEmailChange, error)
+	// CheckAvailable returns ErrEmailExists if email belongs to, or awaits confirmation for, a user
+	// of the organization other than userID (uuid.Nil for a user yet to be created).
+	CheckAvailable(userID uuid.UUID, email string) error
+	// Confirm consumes a confirm token and switches the user to the new, thereby verified, address.
+	// Unknown, used and expired tokens fail with ErrEmailChangeTokenInvalid.
+	Confirm(token string) (*model.User, error)
+	// Revert consumes a revert token: a pending change is cancelled, a confirmed one undone, as long
+	// as the user still has the address it changed to. Otherwise it fails with ErrEmailChangeTokenInvalid.
+	Revert(token string) (*model.User, error)
+	// PruneExpired deletes changes whose revert link has expired.
+	PruneExpired() (int64, error)
+}
+
+// EmailChangeOptions configure the email change messages.
+type EmailChangeOptions struct {
+	// TTL bounds how long the new address has to confirm
+	TTL time.Duration
+	// RevertTTL bounds how long the old address can cancel or undo the change; at least TTL
+	RevertTTL time.Duration
+	// ConfirmURL and RevertURL are the pages the emails link to, with ?token=... appended.
+	// Without them the emails only carry the token.
+	ConfirmURL string
+	RevertURL  string
+}
+
+type emailChangeService struct {
+	repo repository.EmailChangeRepository
+	// users applies confirmed and reverted changes; it must not be wrapped by WithEmailChangeConfirmation
+	users    UserService
+	userRepo repository.UserRepository
+	mailer   mail.Mailer
+	opts     EmailChangeOptions
+	now      func() time.Time
+	org      uuid.UUID
+}
+
+func NewEmailChangeService(repo repository.EmailChangeRepository, users UserService, userRepo repository.UserRepository, mailer mail.Mailer, opts EmailChangeOptions) EmailChangeService {
+	if opts.TTL == 0 {
+		opts.TTL = DefaultEmailChangeTTL
+	}
+	if opts.RevertTTL == 0 {
+		opts.RevertTTL = DefaultEmailChangeRevertTTL
+	}
+	opts.RevertTTL = max(opts.RevertTTL, opts.TTL)
+	return &emailChangeService{repo: repo, users: users, userRepo: userRepo, mailer: mailer, opts: opts, now: time.Now,
+		org: model.DefaultOrganizationID}
+}
+
+func (s *emailChangeService) InOrganization(org uuid.UUID) EmailChangeService {
+	scoped := *s
+	scoped.org = model.OrganizationOrDefault(org)
+	scoped.users = s.users.InOrganization(org)
+	scoped.userRepo = s.userRepo.InOrganization(org)
+	return &scoped
+}
+
+// forChange returns the service for the organization of change, whose token may arrive from anywhere.
+func (s *emailChangeService) forChange(change *model.EmailChange) *emailChangeService {
+	return s.InOrganization(change.OrganizationID).(*emailChangeService)
+}
+
+func (s *emailChangeService) CheckAvailable(userID uuid.UUID, email string) error {
+	owner, err := s.users.GetByEmail(email, model.UserFields{"id"})
+	switch {
+	case err == nil && owner.ID != userID:
+		return errors.ErrEmailExists
+	case err != nil && !stdErrors.Is(err, errors.ErrUserNotFound):
+		return err
+	}
+	pending, err := s.repo.IsPending(s.org, email, userID, s.now())
+	if err != nil {
+		return err
+	}
+	if pending {
+		return errors.ErrEmailExists
+	}
+	return nil
+}
+
+func (s *emailChangeService) Request(user *model.User, newEmail string) (*model.PendingEmailChange, error) {
+	if err := s.CheckAvailable(user.ID, newEmail); err != nil {
+		return nil, err
+	}
+	confirmToken, confirmHash, err := newMailToken()
+	if err != nil {
+		return nil, err
+	}
+	revertToken, revertHash, err := newMailToken()
+	if err != nil {
+		return nil, err
+	}
+
+	now := s.now()
+	change := &model.EmailChange{
+		UserID:             user.ID,
+		OrganizationID:     s.org,
+		OldEmail:           user.Email,
+		OldEmailVerifiedAt: user.EmailVerifiedAt,
+		NewEmail:           newEmail,
+		ConfirmTokenHash:   confirmHash,
+		RevertTokenHash:    revertHash,
+		ConfirmExpiresAt:   now.Add(s.opts.TTL),
+		RevertExpiresAt:    now.Add(s.opts.RevertTTL),
+		CreatedAt:          now,
+	}
+	if err := s.repo.Create(change); err != nil {
+		return nil, err
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), mailTimeout)
+	defer cancel()
+	confirmErr := s.mailer.Send(ctx, mail.Message{
+		To:      newEmail,
+		Subject: "Confirm your new email address",
+		Text:    s.confirmText(user, newEmail, confirmToken),
+	})
+	revertErr := s.mailer.Send(ctx, mail.Message{
+		To:      user.Email,
+		Subject: "Your email address is being changed",
+		Text:    s.revertText(user, newEmail, revertToken),
+	})
+	if err := stdErrors.Join(confirmErr, revertErr); err != nil {
+		return change.Pending(), fmt.Errorf("email change recorded but not mailed: %w", err)
+	}
+	return change.Pending(), nil
+}
+
+func (s *emailChangeService) confirmText(user *model.User, newEmail, token string) string {
+	var b strings.Builder
+	fmt.Fprintf(&b, "Hello %s,\n\n", user.FullName)
+	fmt.Fprintf(&b, "please confirm that %s should become the email address of the account %s.\n", newEmail, user.Username)
+	b.WriteString("The account keeps its current address until you do.\n\n")
+	writeToken(&b, "confirm", s.opts.ConfirmURL, token)
+	fmt.Fprintf(&b, "It expires in %s and works once. If you did not ask for this, you can ignore this email.\n", formatTTL(s.opts.TTL))
+	return b.String()
+}
+
+func (s *emailChangeService) revertText(user *model.User, newEmail, token string) string {
+	var b strings.Builder
+	fmt.Fprintf(&b, "Hello %s,\n\n", user.FullName)
+	fmt.Fprintf(&b, "someone asked to change the email address of the account %s from %s to %s.\n\n", user.Username, user.Email, newEmail)
+	b.WriteString("If this wasn't you, cancel the change, or undo it if it has already been confirmed.\n")
+	writeToken(&b, "cancel the change", s.opts.RevertURL, token)
+	fmt.Fprintf(&b, "This works once, for %s. If you made this change, there is nothing to do.\n", formatTTL(s.opts.RevertTTL))
+	return b.String()
+}
+
+func (s *emailChangeService) Pending(userID uuid.UUID) (*model.PendingEmailChange, error) {
+	change, err := s.repo.GetPending(userID)
+	if stdErrors.Is(err, errors.ErrEmailChangeNotFound) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	if change.OrganizationID != s.org || !s.now().Before(change.ConfirmExpiresAt) {
+		return nil, nil
+	}
+	return change.Pending(), nil
+}
+
+func (s *emailChangeService) Confirm(token string) (*model.User, error) {
+	change, err := s.repo.GetByConfirmToken(hashMailToken(strings.TrimSpace(token)))
+	if err != nil {
+		return nil, err
+	}
+	s = s.forChange(change)
+	now := s.now()
+	if !now.Before(change.ConfirmExpiresAt) {
+		return nil, errors.ErrEmailChangeTokenInvalid
+	}
+
+	// The address changes before the token is marked used, so a failed update leaves the token
+	// working. Marking only succeeds once, so of concurrent confirmations one wins.
+	newEmail := change.NewEmail
+	if _, err := s.users.Update(change.UserID, &model.UpdateUserRequest{Email: &newEmail}); err != nil {
+		// Taken by a user created meanwhile, or the user is gone: the change cannot happen any more
+		if stdErrors.Is(err, errors.ErrEmailExists) || stdErrors.Is(err, errors.ErrUserNotFound) {
+			if delErr := s.repo.Delete(change.ID); delErr != nil {
+				return nil, delErr
+			}
+		}
+		if stdErrors.Is(err, errors.ErrUserNotFound) {
+			return nil, errors.ErrEmailChangeTokenInvalid
+		}
+		return nil, err
+	}
+	if err := s.repo.MarkConfirmed(change.ID, now); err != nil {
+		// Cancelled or superseded while the address changed: put the old one back
+		if stdErrors.Is(err, errors.ErrEmailChangeNotFound) {
+			if restoreErr := s.restoreOldEmail(change); restoreErr != nil {
+				return nil, restoreErr
+			}
+			return nil, errors.ErrEmailChangeTokenInvalid
+		}
+		return nil, err
+	}
+	// Receiving the token proves the new address
+	if err := s.userRepo.MarkEmailVerified(change.UserID, newEmail, now); err != nil {
+		return nil, err
+	}
+	return s.users.GetByID(change.UserID, nil)
+}
+
+func (s *emailChangeService) Revert(token string) (*model.User, error) {
+	change, err := s.repo.TakeByRevertToken(hashMailToken(strings.TrimSpace(token)))
+	if err != nil {
+		return nil, err
+	}
+	s = s.forChange(change)
+	if !s.now().Before(change.RevertExpiresAt) {
+		return nil, errors.ErrEmailChangeTokenInvalid
+	}
+	if change.ConfirmedAt == nil {
+		// Nothing changed yet; deleting the request was enough
+		return s.users.GetByID(change.UserID, nil)
+	}
+
+	current, err := s.users.GetByID(change.UserID, model.UserFields{"email"})
+	if err != nil {
+		if stdErrors.Is(err, errors.ErrUserNotFound) {
+			return nil, errors.ErrEmailChangeTokenInvalid
+		}
+		return nil, err
+	}
+	if current.Email != change.NewEmail {
+		// Changed again since; that change has its own revert link
+		return nil, errors.ErrEmailChangeTokenInvalid
+	}
+	if err := s.restoreOldEmail(change); err != nil {
+		return nil, err
+	}
+	return s.users.GetByID(change.UserID, nil)
+}
+
+// restoreOldEmail switches the user back to the address change replaced, verified if it was.
+func (s *emailChangeService) restoreOldEmail(change *model.EmailChange) error {
+	oldEmail := change.OldEmail
+	if _, err := s.users.Update(change.UserID, &model.UpdateUserRequest{Email: &oldEmail}); err != nil {
+		return err
+	}
+	if change.OldEmailVerifiedAt != nil {
+		return s.userRepo.MarkEmailVerified(change.UserID, oldEmail, *change.OldEmailVerifiedAt)
+	}
+	return nil
+}
+
+func (s *emailChangeService) PruneExpired() (int64, error) {
+	return s.repo.PruneExpired(s.now())
+}
+
+// confirmingUserService decorates a UserService so email changes are requested rather than applied:
+// Update and Replace apply every other field and leave the address to EmailChangeService.Confirm.
+// Create and email change requests also treat addresses pending for other users as taken.
+type confirmingUserService struct {
+	UserService
+	changes EmailChangeService
+	logger  *slog.Logger
+}
+
+// WithEmailChangeConfirmation wraps users so email changes wait for confirmation from the new address.
+func WithEmailChangeConfirmation(users UserService, changes EmailChangeService, logger *slog.Logger) UserService {
+	return &confirmingUserService{UserService: users, changes: changes, logger: logger}
+}
+
+func (s *confirmingUserService) InOrganization(org uuid.UUID) UserService {
+	return &confirmingUserService{UserService: s.UserService.InOrganization(org), changes: s.changes.InOrganization(org), logger: s.logger}
+}
+
+func (s *confirmingUserService) Create(req *model.CreateUserRequest) (*model.User, error) {
+	if err := s.UserService.ValidateCreate(req); err != nil {
+		return nil, err
+	}
+	if err := s.changes.CheckAvailable(uuid.Nil, req.Email); err != nil {
+		return nil, err
+	}
+	return s.UserService.Create(req)
+}
+
+// splitEmail reports whether email (normalized) differs from the user's current address and,
+// if so, checks that it is available before anything is written.
+func (s *confirmingUserService) splitEmail(id uuid.UUID, email string) (*model.User, bool, error) {
+	current, err := s.UserService.GetByID(id, nil)
+	if err != nil {
+		return nil, false, err
+	}
+	if email == current.Email {
+		return current, false, nil
+	}
+	if err := s.changes.CheckAvailable(id, email); err != nil {
+		return nil, false, err
+	}
+	return current, true, nil
+}
+
+// request records the change for user, who has already been updated otherwise. A failure to mail
+// is logged, not returned, because the request did succeed; asking again sends new tokens.
+func (s *confirmingUserService) request(user *model.User, email string) (*model.User, error) {
+	pending, err := s.changes.Request(user, email)
+	if err != nil {
+		if pending == nil {
+			return nil, err
+		}
+		s.logger.Error("Failed to send email change messages",
+			slog.String("user_id", user.ID.String()),
+			slog.String("error", err.Error()))
+	}
+	user.PendingEmailChange = pending
+	return user, nil
+}
+
+func (s *confirmingUserService) Update(id uuid.UUID, req *model.UpdateUserRequest) (*model.User, error) {
+	if req.Email == nil {
+		return s.UserService.Update(id, req)
+	}
+	if err := s.UserService.ValidateUpdate(req); err != nil {
+		return nil, err
+	}
+	_, changed, err := s.splitEmail(id, *req.Email)
+	if err != nil {
+		return nil, err
+	}
+	if !changed {
+		return s.UserService.Update(id, req)
+	}
+
+	rest := *req
+	rest.Email = nil
+	user, err := s.UserService.Update(id, &rest)
+	if err != nil {
+		return nil, err
+	}
+	return s.request(user, *req.Email)
+}
+
+func (s *confirmingUserService) Replace(id uuid.UUID, req *model.ReplaceUserRequest) (*model.User, error) {
+	if err := s.UserService.ValidateCreate(req); err != nil {
+		return nil, err
+	}
+	current, changed, err := s.splitEmail(id, req.Email)
+	if err != nil {
+		return nil, err
+	}
+	if !changed {
+		return s.UserService.Replace(id, req)
+	}
+
+	rest := *req
+	rest.Email = current.Email
+	user, err := s.UserService.Replace(id, &rest)
+	if err != nil {
+		return nil, err
+	}
+	return s.request(user, req.Email)
+}
@@ -0,0 +1,391 @@
+package service
+
+import (
+	"cruder/internal/errors"
+	"cruder/internal/mail"
+	"cruder/internal/model"
+	"io"
+	"log/slog"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+)
+
+// newTestEmailChangeService returns an email change service over changes and users whose clock reads *now.
+func newTestEmailChangeService(changes *MockEmailChangeRepository, users *MockUserRepository, mailer mail.Mailer, now *time.Time) *emailChangeService {
+	svc := NewEmailChangeService(changes, NewUserService(users), users, mailer,
+		EmailChangeOptions{RevertURL: "https://app.example.com/revert"}).(*emailChangeService)
+	svc.now = func() time.Time { return *now }
+	return svc
+}
+
+var changingUser = model.User{ID: uuid.New(), OrganizationID: model.DefaultOrganizationID, Username: "jdoe", Email: "john.doe@example.com", FullName: "John Doe"}
+
+// emailChange is changingUser's change to newEmail, requested at now.
+func emailChange(newEmail string, now time.Time) *model.EmailChange {
+	return &model.EmailChange{
+		ID: uuid.New(), UserID: changingUser.ID, OrganizationID: model.DefaultOrganizationID,
+		OldEmail: changingUser.Email, NewEmail: newEmail,
+		ConfirmTokenHash: hashMailToken("confirm-token"), RevertTokenHash: hashMailToken("revert-token"),
+		ConfirmExpiresAt: now.Add(DefaultEmailChangeTTL), RevertExpiresAt: now.Add(DefaultEmailChangeRevertTTL), CreatedAt: now,
+	}
+}
+
+// emailUpdate is the update the user service passes on to set email.
+func emailUpdate(email string) *model.UpdateUserRequest {
+	return &model.UpdateUserRequest{Email: &email, Actor: model.ActorSystem}
+}
+
+func TestEmailChange_Request(t *testing.T) {
+	// Given: An address nobody has or awaits
+	changes := new(MockEmailChangeRepository)
+	users := new(MockUserRepository)
+	mailer := &mail.MemoryMailer{}
+	now := mailTestTime
+	svc := newTestEmailChangeService(changes, users, mailer, &now)
+	users.On("GetByEmail", "johnny@example.com", model.UserFields{"id"}).Return(nil, errors.ErrUserNotFound)
+	changes.On("IsPending", model.DefaultOrganizationID, "johnny@example.com", changingUser.ID, now).Return(false, nil)
+	var stored *model.EmailChange
+	changes.On("Create", mock.Anything).Run(func(args mock.Arguments) {
+		stored = args.Get(0).(*model.EmailChange)
+	}).Return(nil)
+
+	// When: Requesting the change
+	pending, err := svc.Request(&changingUser, "johnny@example.com")
+
+	// Then: It is recorded, the new address gets a confirm token and the old one a revert token
+	require.NoError(t, err)
+	assert.Equal(t, &model.PendingEmailChange{NewEmail: "johnny@example.com", RequestedAt: now, ExpiresAt: now.Add(DefaultEmailChangeTTL)}, pending)
+	require.NotNil(t, stored)
+	assert.Equal(t, changingUser.Email, stored.OldEmail)
+	assert.Equal(t, now.Add(DefaultEmailChangeRevertTTL), stored.RevertExpiresAt)
+	assert.Equal(t, hashMailToken(mailedToken(t, mailer, "johnny@example.com", "Confirm your new email address")), stored.ConfirmTokenHash)
+	assert.Equal(t, hashMailToken(mailedToken(t, mailer, "john.doe@example.com", "Your email address is being changed")), stored.RevertTokenHash)
+
+	notice, ok := mailer.Last("john.doe@example.com")
+	require.True(t, ok)
+	assert.Contains(t, notice.Text, "https://app.example.com/revert?token=")
+	assert.Contains(t, notice.Text, "johnny@example.com")
+}
+
+func TestEmailChange_UpdateHoldsNewEmailUntilConfirmed(t *testing.T) {
+	// Given: The user service that asks for confirmation of email changes
+	changes := new(MockEmailChangeRepository)
+	users := new(MockUserRepository)
+	now := mailTestTime
+	svc := WithEmailChangeConfirmation(NewUserService(users), newTestEmailChangeService(changes, users, &mail.MemoryMailer{}, &now),
+		slog.New(slog.NewTextHandler(io.Discard, nil)))
+	renamed := changingUser
+	renamed.FullName = "Johnny Doe"
+	users.On("GetByID", changingUser.ID, model.UserFields(nil)).Return(&changingUser, nil)
+	users.On("GetByEmail", "johnny@example.com", model.UserFields{"id"}).Return(nil, errors.ErrUserNotFound)
+	changes.On("IsPending", model.DefaultOrganizationID, "johnny@example.com", changingUser.ID, now).Return(false, nil)
+	fullName := "Johnny Doe"
+	users.On("Update", changingUser.ID, &model.UpdateUserRequest{FullName: &fullName, Actor: model.ActorSystem}).Return(&renamed, nil)
+	changes.On("Create", mock.Anything).Return(nil)
+
+	// When: Changing the address along with the name
+	newEmail := " Johnny@Example.com "
+	updated, err := svc.Update(changingUser.ID, &model.UpdateUserRequest{Email: &newEmail, FullName: &fullName})
+
+	// Then: Only the name changes now, and the normalized address is pending
+	require.NoError(t, err)
+	assert.Equal(t, "john.doe@example.com", updated.Email, "the address does not change yet")
+	assert.Equal(t, "Johnny Doe", updated.FullName, "other fields apply at once")
+	require.NotNil(t, updated.PendingEmailChange)
+	assert.Equal(t, "johnny@example.com", updated.PendingEmailChange.NewEmail)
+	assert.True(t, updated.PendingEmailChange.ExpiresAt.Equal(now.Add(DefaultEmailChangeTTL)))
+	users.AssertExpectations(t)
+	changes.AssertExpectations(t)
+}
+
+func TestEmailChange_UpdateSameEmail(t *testing.T) {
+	changes := new(MockEmailChangeRepository)
+	users := new(MockUserRepository)
+	now := mailTestTime
+	svc := WithEmailChangeConfirmation(NewUserService(users), newTestEmailChangeService(changes, users, &mail.MemoryMailer{}, &now),
+		slog.New(slog.NewTextHandler(io.Discard, nil)))
+	users.On("GetByID", changingUser.ID, model.UserFields(nil)).Return(&changingUser, nil)
+	users.On("Update", changingUser.ID, emailUpdate(changingUser.Email)).Return(&changingUser, nil)
+
+	sameEmail := "John.Doe@example.com"
+	updated, err := svc.Update(changingUser.ID, &model.UpdateUserRequest{Email: &sameEmail})
+
+	require.NoError(t, err)
+	assert.Nil(t, updated.PendingEmailChange, "nothing to confirm")
+	changes.AssertNotCalled(t, "Create", mock.Anything)
+}
+
+func TestEmailChange_Pending(t *testing.T) {
+	changes := new(MockEmailChangeRepository)
+	now := mailTestTime
+	svc := newTestEmailChangeService(changes, new(MockUserRepository), &mail.MemoryMailer{}, &now)
+	change := emailChange("johnny@example.com", now)
+	changes.On("GetPending", changingUser.ID).Return(change, nil)
+	other := uuid.New()
+	changes.On("GetPending", other).Return(nil, errors.ErrEmailChangeNotFound)
+
+	pending, err := svc.Pending(changingUser.ID)
+	require.NoError(t, err)
+	assert.Equal(t, change.Pending(), pending)
+
+	pending, err = svc.Pending(other)
+	require.NoError(t, err)
+	assert.Nil(t, pending, "none requested")
+
+	pending, err = svc.InOrganization(uuid.New()).Pending(changingUser.ID)
+	require.NoError(t, err)
+	assert.Nil(t, pending, "another organization's user")
+
+	now = change.ConfirmExpiresAt
+	pending, err = svc.Pending(changingUser.ID)
+	require.NoError(t, err)
+	assert.Nil(t, pending, "expired at the instant")
+}
+
+func TestEmailChange_Confirm(t *testing.T) {
+	// Given: A pending change
+	changes := new(MockEmailChangeRepository)
+	users := new(MockUserRepository)
+	now := mailTestTime
+	svc := newTestEmailChangeService(changes, users, &mail.MemoryMailer{}, &now)
+	change := emailChange("johnny@example.com", now)
+	confirmed := changingUser
+	confirmed.Email = "johnny@example.com"
+	confirmed.EmailVerifiedAt = &now
+	changes.On("GetByConfirmToken", hashMailToken("confirm-token")).Return(change, nil)
+	update := users.On("Update", changingUser.ID, emailUpdate("johnny@example.com")).Return(&confirmed, nil)
+	mark := changes.On("MarkConfirmed", change.ID, now).Return(nil)
+	verify := users.On("MarkEmailVerified", changingUser.ID, "johnny@example.com", now).Return(nil)
+	users.On("GetByID", changingUser.ID, model.UserFields(nil)).Return(&confirmed, nil)
+	// The address changes before the token is used up, so a failed update leaves it working
+	mock.InOrder(update, mark, verify)
+
+	// When: Confirming it with the mailed token
+	user, err := svc.Confirm(" confirm-token ")
+
+	// Then: The user has the new address, verified by the confirmation
+	require.NoError(t, err)
+	assert.Equal(t, &confirmed, user)
+	users.AssertExpectations(t)
+	changes.AssertExpectations(t)
+}
+
+func TestEmailChange_ConfirmInvalidOrExpired(t *testing.T) {
+	changes := new(MockEmailChangeRepository)
+	users := new(MockUserRepository)
+	now := mailTestTime
+	svc := newTestEmailChangeService(changes, users, &mail.MemoryMailer{}, &now)
+	change := emailChange("johnny@example.com", now)
+	changes.On("GetByConfirmToken", hashMailToken("confirm-token")).Return(change, nil)
+	changes.On("GetByConfirmToken", hashMailToken("not-a-token")).Return(nil, errors.ErrEmailChangeTokenInvalid)
+
+	_, err := svc.Confirm("not-a-token")
+	assert.ErrorIs(t, err, errors.ErrEmailChangeTokenInvalid)
+
+	now = change.ConfirmExpiresAt
+	_, err = svc.Confirm("confirm-token")
+	assert.ErrorIs(t, err, errors.ErrEmailChangeTokenInvalid, "expired at the instant")
+	users.AssertNotCalled(t, "Update", mock.Anything, mock.Anything)
+}
+
+func TestEmailChange_ConfirmUsedToken(t *testing.T) {
+	// Given: A change confirmed meanwhile, e.g. by a concurrent request
+	changes := new(MockEmailChangeRepository)
+	users := new(MockUserRepository)
+	now := mailTestTime
+	svc := newTestEmailChangeService(changes, users, &mail.MemoryMailer{}, &now)
+	change := emailChange("johnny@example.com", now)
+	changes.On("GetByConfirmToken", mock.Anything).Return(change, nil)
+	users.On("Update", changingUser.ID, emailUpdate("johnny@example.com")).Return(&changingUser, nil).Once()
+	changes.On("MarkConfirmed", change.ID, now).Return(errors.ErrEmailChangeTokenInvalid)
+
+	// When: Confirming it again
+	_, err := svc.Confirm("confirm-token")
+
+	// Then: Confirm tokens are single-use; the address is the confirmed one either way
+	assert.ErrorIs(t, err, errors.ErrEmailChangeTokenInvalid)
+	users.AssertExpectations(t)
+	users.AssertNotCalled(t, "MarkEmailVerified", mock.Anything, mock.Anything, mock.Anything)
+}
+
+func TestEmailChange_ConfirmFailureKeepsToken(t *testing.T) {
+	changes := new(MockEmailChangeRepository)
+	users := new(MockUserRepository)
+	now := mailTestTime
+	svc := newTestEmailChangeService(changes, users, &mail.MemoryMailer{}, &now)
+	change := emailChange("johnny@example.com", now)
+	changes.On("GetByConfirmToken", mock.Anything).Return(change, nil)
+	users.On("Update", changingUser.ID, mock.Anything).Return(nil, errors.ErrDatabaseOperation)
+
+	_, err := svc.Confirm("confirm-token")
+
+	assert.ErrorIs(t, err, errors.ErrDatabaseOperation)
+	changes.AssertNotCalled(t, "MarkConfirmed", mock.Anything, mock.Anything)
+	changes.AssertNotCalled(t, "Delete", mock.Anything)
+}
+
+func TestEmailChange_ConfirmNoLongerPossible(t *testing.T) {
+	for _, tc := range []struct {
+		updateErr, want error
+		why             string
+	}{
+		{errors.ErrEmailExists, errors.ErrEmailExists, "taken by a user created meanwhile"},
+		{errors.ErrUserNotFound, errors.ErrEmailChangeTokenInvalid, "the user is gone"},
+	} {
+		changes := new(MockEmailChangeRepository)
+		users := new(MockUserRepository)
+		now := mailTestTime
+		svc := newTestEmailChangeService(changes, users, &mail.MemoryMailer{}, &now)
+		change := emailChange("johnny@example.com", now)
+		changes.On("GetByConfirmToken", mock.Anything).Return(change, nil)
+		users.On("Update", changingUser.ID, mock.Anything).Return(nil, tc.updateErr)
+		changes.On("Delete", change.ID).Return(nil)
+
+		_, err := svc.Confirm("confirm-token")
+
+		assert.ErrorIs(t, err, tc.want, tc.why)
+		changes.AssertExpectations(t)
+		changes.AssertNotCalled(t, "MarkConfirmed", mock.Anything, mock.Anything)
+	}
+}
+
+func TestEmailChange_RevertDuringConfirmRestoresAddress(t *testing.T) {
+	// Given: A change to a verified address that is reverted while its confirmation updates the user
+	changes := new(MockEmailChangeRepository)
+	users := new(MockUserRepository)
+	now := mailTestTime
+	svc := newTestEmailChangeService(changes, users, &mail.MemoryMailer{}, &now)
+	verifiedAt := now.Add(-time.Hour)
+	change := emailChange("mallory@example.com", now)
+	change.OldEmailVerifiedAt = &verifiedAt
+	changes.On("GetByConfirmToken", mock.Anything).Return(change, nil)
+	confirm := users.On("Update", changingUser.ID, emailUpdate("mallory@example.com")).Return(&changingUser, nil)
+	changes.On("MarkConfirmed", change.ID, now).Return(errors.ErrEmailChangeNotFound)
+	restore := users.On("Update", changingUser.ID, emailUpdate("john.doe@example.com")).Return(&changingUser, nil)
+	users.On("MarkEmailVerified", changingUser.ID, "john.doe@example.com", verifiedAt).Return(nil)
+	mock.InOrder(confirm, restore)
+
+	// When: The confirmation goes on to use up its token
+	_, err := svc.Confirm("confirm-token")
+
+	// Then: The cancellation wins: the old address is back, still verified
+	assert.ErrorIs(t, err, errors.ErrEmailChangeTokenInvalid)
+	users.AssertExpectations(t)
+	users.AssertNotCalled(t, "MarkEmailVerified", changingUser.ID, "mallory@example.com", mock.Anything)
+}
+
+func TestEmailChange_RevertPendingCancels(t *testing.T) {
+	// Given: A change not confirmed yet, whose revert token takes the request
+	changes := new(MockEmailChangeRepository)
+	users := new(MockUserRepository)
+	now := mailTestTime
+	svc := newTestEmailChangeService(changes, users, &mail.MemoryMailer{}, &now)
+	changes.On("TakeByRevertToken", hashMailToken("revert-token")).Return(emailChange("mallory@example.com", now), nil)
+	users.On("GetByID", changingUser.ID, model.UserFields(nil)).Return(&changingUser, nil)
+
+	// When: Reverting it
+	reverted, err := svc.Revert(" revert-token ")
+
+	// Then: Nothing else needs undoing
+	require.NoError(t, err)
+	assert.Equal(t, "john.doe@example.com", reverted.Email)
+	users.AssertNotCalled(t, "Update", mock.Anything, mock.Anything)
+}
+
+func TestEmailChange_RevertConfirmedRestoresAddress(t *testing.T) {
+	// Given: A confirmed change away from a verified address
+	changes := new(MockEmailChangeRepository)
+	users := new(MockUserRepository)
+	now := mailTestTime
+	svc := newTestEmailChangeService(changes, users, &mail.MemoryMailer{}, &now)
+	verifiedAt := now.Add(-time.Hour)
+	change := emailChange("mallory@example.com", now)
+	change.OldEmailVerifiedAt = &verifiedAt
+	change.ConfirmedAt = &now
+	changes.On("TakeByRevertToken", hashMailToken("revert-token")).Return(change, nil)
+	users.On("GetByID", changingUser.ID, model.UserFields{"email"}).Return(&model.User{ID: changingUser.ID, Email: "mallory@example.com"}, nil)
+	users.On("Update", changingUser.ID, emailUpdate("john.doe@example.com")).Return(&changingUser, nil)
+	users.On("MarkEmailVerified", changingUser.ID, "john.doe@example.com", verifiedAt).Return(nil)
+	restored := changingUser
+	restored.EmailVerifiedAt = &verifiedAt
+	users.On("GetByID", changingUser.ID, model.UserFields(nil)).Return(&restored, nil)
+
+	// When: The old address reverts it days later
+	now = now.Add(3 * 24 * time.Hour)
+	reverted, err := svc.Revert("revert-token")
+
+	// Then: The old address is back and keeps its verification
+	require.NoError(t, err)
+	assert.Equal(t, &restored, reverted)
+	users.AssertExpectations(t)
+}
+
+func TestEmailChange_RevertExpiredOrSuperseded(t *testing.T) {
+	changes := new(MockEmailChangeRepository)
+	users := new(MockUserRepository)
+	now := mailTestTime
+	svc := newTestEmailChangeService(changes, users, &mail.MemoryMailer{}, &now)
+	stale := emailChange("first@example.com", now)
+	stale.ConfirmedAt = &now
+	expired := emailChange("second@example.com", now)
+	expired.ConfirmedAt = &now
+	changes.On("TakeByRevertToken", hashMailToken("stale")).Return(stale, nil)
+	changes.On("TakeByRevertToken", hashMailToken("expired")).Return(expired, nil)
+	changes.On("TakeByRevertToken", hashMailToken("used")).Return(nil, errors.ErrEmailChangeTokenInvalid)
+	users.On("GetByID", changingUser.ID, model.UserFields{"email"}).Return(&model.User{ID: changingUser.ID, Email: "second@example.com"}, nil)
+
+	_, err := svc.Revert("stale")
+	assert.ErrorIs(t, err, errors.ErrEmailChangeTokenInvalid, "the user changed email again since")
+	_, err = svc.Revert("used")
+	assert.ErrorIs(t, err, errors.ErrEmailChangeTokenInvalid, "revert tokens are single-use")
+
+	now = expired.RevertExpiresAt
+	_, err = svc.Revert("expired")
+	assert.ErrorIs(t, err, errors.ErrEmailChangeTokenInvalid, "expired at the instant")
+	users.AssertNotCalled(t, "Update", mock.Anything, mock.Anything)
+}
+
+func TestEmailChange_PendingAddressesAreTaken(t *testing.T) {
+	// Given: An address pending for another user, and one in use
+	changes := new(MockEmailChangeRepository)
+	users := new(MockUserRepository)
+	now := mailTestTime
+	svc := WithEmailChangeConfirmation(NewUserService(users), newTestEmailChangeService(changes, users, &mail.MemoryMailer{}, &now),
+		slog.New(slog.NewTextHandler(io.Discard, nil)))
+	other := model.User{ID: uuid.New(), Username: "asmith", Email: "alice@example.com", FullName: "Alice Smith"}
+	users.On("GetByEmail", "shared@example.com", model.UserFields{"id"}).Return(nil, errors.ErrUserNotFound)
+	changes.On("IsPending", model.DefaultOrganizationID, "shared@example.com", mock.Anything, now).Return(true, nil)
+	users.On("GetByEmail", "john.doe@example.com", model.UserFields{"id"}).Return(&model.User{ID: changingUser.ID}, nil)
+	users.On("GetByID", other.ID, model.UserFields(nil)).Return(&other, nil)
+
+	// When: Creating or updating users with them
+	_, err := svc.Create(&model.CreateUserRequest{Username: "bob", Email: "Shared@example.com", FullName: "Bob Builder"})
+	assert.ErrorIs(t, err, errors.ErrEmailExists)
+	shared := "shared@example.com"
+	_, err = svc.Update(other.ID, &model.UpdateUserRequest{Email: &shared})
+	assert.ErrorIs(t, err, errors.ErrEmailExists)
+	_, err = svc.Replace(other.ID, &model.ReplaceUserRequest{Username: "asmith", Email: "john.doe@example.com", FullName: "Alice Smith"})
+	assert.ErrorIs(t, err, errors.ErrEmailExists)
+
+	// Then: Addresses in use are checked before anything is written
+	users.AssertNotCalled(t, "Create", mock.Anything)
+	users.AssertNotCalled(t, "Update", mock.Anything, mock.Anything)
+	changes.AssertNotCalled(t, "Create", mock.Anything)
+}
+
+func TestEmailChange_PruneExpired(t *testing.T) {
+	changes := new(MockEmailChangeRepository)
+	now := mailTestTime
+	svc := newTestEmailChangeService(changes, new(MockUserRepository), &mail.MemoryMailer{}, &now)
+	changes.On("PruneExpired", now).Return(int64(2), nil)
+
+	n, err := svc.PruneExpired()
+
+	require.NoError(t, err)
+	assert.Equal(t, int64(2), n)
+}
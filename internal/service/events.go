@@ -0,0 +1,162 @@
+package service
+
+import (
+	"context"
+	"cruder/internal/events"
+	"cruder/internal/model"
+	"log/slog"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// eventPublishingUserService decorates a UserService so every successful mutation is announced.
+// Publishing happens after the change is committed; a failure to publish is logged,
+// not returned, because the caller's write did succeed.
+type eventPublishingUserService struct {
+	UserService
+	publisher events.Publisher
+	logger    *slog.Logger
+	// org is the organization of users, which deletion events carry
+	org uuid.UUID
+}
+
+// WithEvents wraps users so creates, updates (status changes included) and deletes are published to publisher.
+func WithEvents(users UserService, publisher events.Publisher, logger *slog.Logger) UserService {
+	return &eventPublishingUserService{UserService: users, publisher: publisher, logger: logger, org: model.DefaultOrganizationID}
+}
+
+func (s *eventPublishingUserService) InOrganization(org uuid.UUID) UserService {
+	return &eventPublishingUserService{UserService: s.UserService.InOrganization(org), publisher: s.publisher, logger: s.logger,
+		org: model.OrganizationOrDefault(org)}
+}
+
+func (s *eventPublishingUserService) publish(t events.Type, user model.User) {
+	err := s.publisher.Publish(context.Background(), events.Event{Type: t, User: user, OccurredAt: time.Now().UTC()})
+	if err != nil {
+		s.logger.Error("Failed to publish user event",
+			slog.String("type", string(t)),
+			slog.String("user_id", user.ID.String()),
+			slog.String("error", err.Error()))
+	}
+}
+
+func (s *eventPublishingUserService) Create(req *model.CreateUserRequest) (*model.User, error) {
+	user, err := s.UserService.Create(req)
+	if err != nil {
+		return nil, err
+	}
+	s.publish(events.UserCreated, *user)
+	return user, nil
+}
+
+func (s *eventPublishingUserService) Update(id uuid.UUID, req *model.UpdateUserRequest) (*model.User, error) {
+	user, err := s.UserService.Update(id, req)
+	if err != nil {
+		return nil, err
+	}
+	s.publish(events.UserUpdated, *user)
+	return user, nil
+}
+
+func (s *eventPublishingUserService) Replace(id uuid.UUID, req *model.ReplaceUserRequest) (*model.User, error) {
+	user, err := s.UserService.Replace(id, req)
+	if err != nil {
+		return nil, err
+	}
+	s.publish(events.UserUpdated, *user)
+	return user, nil
+}
+
+func (s *eventPublishingUserService) ChangeStatus(id uuid.UUID, to model.UserStatus, req *model.ChangeUserStatusRequest) (*model.User, error) {
+	user, err := s.UserService.ChangeStatus(id, to, req)
+	if err != nil {
+		return nil, err
+	}
+	s.publish(events.UserUpdated, *user)
+	return user, nil
+}
+
+func (s *eventPublishingUserService) Delete(id uuid.UUID, actor string) error {
+	if err := s.UserService.Delete(id, actor); err != nil {
+		return err
+	}
+	s.publish(events.UserDeleted, model.User{ID: id, OrganizationID: s.org})
+	return nil
+}
+
+// eventPublishingGroupService decorates a GroupService so membership changes are announced, like
+// eventPublishingUserService does for users.
+type eventPublishingGroupService struct {
+	GroupService
+	publisher events.Publisher
+	logger    *slog.Logger
+	// org is the organization of the groups, which membership events carry
+	org uuid.UUID
+}
+
+// WithGroupEvents wraps groups so members being added and removed are published to publisher,
+// including the memberships that end when a group is deleted or a user removed from all groups.
+func WithGroupEvents(groups GroupService, publisher events.Publisher, logger *slog.Logger) GroupService {
+	return &eventPublishingGroupService{GroupService: groups, publisher: publisher, logger: logger, org: model.DefaultOrganizationID}
+}
+
+func (s *eventPublishingGroupService) InOrganization(org uuid.UUID) GroupService {
+	return &eventPublishingGroupService{GroupService: s.GroupService.InOrganization(org), publisher: s.publisher, logger: s.logger,
+		org: model.OrganizationOrDefault(org)}
+}
+
+func (s *eventPublishingGroupService) publish(t events.Type, change *model.GroupMembershipChange) {
+	user := model.User{OrganizationID: s.org}
+	if change.MemberType == model.MemberTypeUser {
+		user.ID = change.MemberID
+	}
+	err := s.publisher.Publish(context.Background(), events.Event{Type: t, User: user, Membership: change, OccurredAt: change.ChangedAt})
+	if err != nil {
+		s.logger.Error("Failed to publish group membership event",
+			slog.String("type", string(t)),
+			slog.String("group_id", change.GroupID.String()),
+			slog.String("member_id", change.MemberID.String()),
+			slog.String("error", err.Error()))
+	}
+}
+
+func (s *eventPublishingGroupService) AddMember(id uuid.UUID, req *model.AddGroupMemberRequest) (*model.GroupMembershipChange, error) {
+	change, err := s.GroupService.AddMember(id, req)
+	if err != nil {
+		return nil, err
+	}
+	s.publish(events.GroupMemberAdded, change)
+	return change, nil
+}
+
+func (s *eventPublishingGroupService) RemoveMember(id uuid.UUID, memberType string, memberID uuid.UUID, actor string) (*model.GroupMembershipChange, error) {
+	change, err := s.GroupService.RemoveMember(id, memberType, memberID, actor)
+	if err != nil {
+		return nil, err
+	}
+	s.publish(events.GroupMemberRemoved, change)
+	return change, nil
+}
+
+func (s *eventPublishingGroupService) Delete(id uuid.UUID, actor string) ([]model.GroupMembershipChange, error) {
+	changes, err := s.GroupService.Delete(id, actor)
+	if err != nil {
+		return nil, err
+	}
+	for i := range changes {
+		s.publish(events.GroupMemberRemoved, &changes[i])
+	}
+	return changes, nil
+}
+
+func (s *eventPublishingGroupService) RemoveUser(userID uuid.UUID, actor string) ([]model.GroupMembershipChange, error) {
+	changes, err := s.GroupService.RemoveUser(userID, actor)
+	if err != nil {
+		return nil, err
+	}
+	for i := range changes {
+		s.publish(events.GroupMemberRemoved, &changes[i])
+	}
+	return changes, nil
+}
@@ -0,0 +1,249 @@
+package service
+
+import (
+	"cruder/internal/errors"
+	"cruder/internal/model"
+	"cruder/internal/repository"
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+
+	stdErrors "errors"
+)
+
+// GroupService manages groups and their members. Members are users or other groups; a user is
+// an effective member of every group that contains them directly or through nesting.
+type GroupService interface {
+	// InOrganization returns a GroupService that sees the groups and users of org instead.
+	InOrganization(org uuid.UUID) GroupService
+
+	// Create adds a group, returning ErrGroupExists if its name is taken.
+	Create(req *model.CreateGroupRequest) (*model.Group, error)
+	Get(id uuid.UUID) (*model.Group, error)
+	List() ([]model.Group, error)
+	Update(id uuid.UUID, req *model.UpdateGroupRequest) (*model.Group, error)
+	// Delete removes the group and returns the memberships that went with it, as removals by actor:
+	// its members', and its own in other groups.
+	Delete(id uuid.UUID, actor string) ([]model.GroupMembershipChange, error)
+
+	// AddMember adds the user or group req names to the group and returns the recorded change.
+	// A member that does not exist is ErrInvalidInput; nesting a group that contains the group,
+	// or the group itself, is ErrGroupCycle.
+	AddMember(id uuid.UUID, req *model.AddGroupMemberRequest) (*model.GroupMembershipChange, error)
+	// RemoveMember removes a direct member of memberType from the group and returns the recorded change.
+	RemoveMember(id uuid.UUID, memberType string, memberID uuid.UUID, actor string) (*model.GroupMembershipChange, error)
+	// RemoveUser removes the user from every group they are a direct member of and returns the
+	// recorded changes, e.g. before the user is deleted.
+	RemoveUser(userID uuid.UUID, actor string) ([]model.GroupMembershipChange, error)
+	// Members returns the group's direct members.
+	Members(id uuid.UUID) ([]model.GroupMember, error)
+	// EffectiveMembers returns every user in the group, directly or through nested groups, by username.
+	EffectiveMembers(id uuid.UUID) ([]model.User, error)
+	// GroupsOfUser returns the groups the user is a direct member of, or an effective one if effective.
+	GroupsOfUser(userID uuid.UUID, effective bool) ([]model.Group, error)
+	// History returns the group's membership changes, newest first.
+	History(id uuid.UUID) ([]model.GroupMembershipChange, error)
+}
+
+type groupService struct {
+	repo  repository.GroupRepository
+	users repository.UserRepository
+	now   func() time.Time
+}
+
+func NewGroupService(repo repository.GroupRepository, users repository.UserRepository) GroupService {
+	return &groupService{repo: repo, users: users, now: time.Now}
+}
+
+func (s *groupService) InOrganization(org uuid.UUID) GroupService {
+	return &groupService{repo: s.repo.InOrganization(org), users: s.users.InOrganization(org), now: s.now}
+}
+
+func (s *groupService) Create(req *model.CreateGroupRequest) (*model.Group, error) {
+	req.Name = strings.TrimSpace(req.Name)
+	req.Description = strings.TrimSpace(req.Description)
+	if req.Name == "" {
+		return nil, fmt.Errorf("%w: name is required", errors.ErrInvalidInput)
+	}
+	return s.repo.Create(req)
+}
+
+func (s *groupService) Get(id uuid.UUID) (*model.Group, error) {
+	return s.repo.GetByID(id)
+}
+
+func (s *groupService) List() ([]model.Group, error) {
+	return s.repo.List()
+}
+
+func (s *groupService) Update(id uuid.UUID, req *model.UpdateGroupRequest) (*model.Group, error) {
+	if req.Name != nil {
+		name := strings.TrimSpace(*req.Name)
+		if name == "" {
+			return nil, fmt.Errorf("%w: name must not be blank", errors.ErrInvalidInput)
+		}
+		req.Name = &name
+	}
+	if req.Description != nil {
+		description := strings.TrimSpace(*req.Description)
+		req.Description = &description
+	}
+	return s.repo.Update(id, req)
+}
+
+func (s *groupService) Delete(id uuid.UUID, actor string) ([]model.GroupMembershipChange, error) {
+	return s.repo.Delete(id, actorOrSystem(actor), s.now().UTC())
+}
+
+func actorOrSystem(actor string) string {
+	if actor == "" {
+		return model.ActorSystem
+	}
+	return actor
+}
+
+// memberName returns the username or group name of a member in the organization, or
+// ErrUserNotFound or ErrGroupNotFound if there is no such member.
+func (s *groupService) memberName(memberType string, id uuid.UUID) (string, error) {
+	if memberType == model.MemberTypeGroup {
+		g, err := s.repo.GetByID(id)
+		if err != nil {
+			return "", err
+		}
+		return g.Name, nil
+	}
+	u, err := s.users.GetByID(id, nil)
+	if err != nil {
+		return "", err
+	}
+	return u.Username, nil
+}
+
+func (s *groupService) change(id uuid.UUID, action, memberType string, memberID uuid.UUID, actor string) (*model.GroupMembershipChange, error) {
+	if _, err := s.repo.GetByID(id); err != nil {
+		return nil, err
+	}
+	change := &model.GroupMembershipChange{GroupID: id, Action: action, MemberType: memberType, MemberID: memberID,
+		Actor: actorOrSystem(actor), ChangedAt: s.now().UTC()}
+
+	name, err := s.memberName(memberType, memberID)
+	switch {
+	case err == nil:
+		change.MemberName = name
+		return change, nil
+	case action == model.MembershipRemoved && (stdErrors.Is(err, errors.ErrUserNotFound) || stdErrors.Is(err, errors.ErrGroupNotFound)):
+		return nil, errors.ErrGroupMemberNotFound
+	case stdErrors.Is(err, errors.ErrUserNotFound) || stdErrors.Is(err, errors.ErrGroupNotFound):
+		return nil, fmt.Errorf("%w: no %s with id '%s'", errors.ErrInvalidInput, memberType, memberID)
+	default:
+		return nil, err
+	}
+}
+
+func (s *groupService) AddMember(id uuid.UUID, req *model.AddGroupMemberRequest) (*model.GroupMembershipChange, error) {
+	var memberType string
+	var memberID uuid.UUID
+	switch {
+	case req.UserID != nil && req.GroupID == nil:
+		memberType, memberID = model.MemberTypeUser, *req.UserID
+	case req.GroupID != nil && req.UserID == nil:
+		memberType, memberID = model.MemberTypeGroup, *req.GroupID
+		if memberID == id {
+			return nil, errors.ErrGroupCycle
+		}
+	default:
+		return nil, fmt.Errorf("%w: exactly one of user_id and group_id is required", errors.ErrInvalidInput)
+	}
+
+	change, err := s.change(id, model.MembershipAdded, memberType, memberID, req.Actor)
+	if err != nil {
+		return nil, err
+	}
+	if err := s.repo.AddMember(change); err != nil {
+		return nil, err
+	}
+	return change, nil
+}
+
+func (s *groupService) RemoveMember(id uuid.UUID, memberType string, memberID uuid.UUID, actor string) (*model.GroupMembershipChange, error) {
+	change, err := s.change(id, model.MembershipRemoved, memberType, memberID, actor)
+	if err != nil {
+		return nil, err
+	}
+	if err := s.repo.RemoveMember(change); err != nil {
+		return nil, err
+	}
+	return change, nil
+}
+
+func (s *groupService) RemoveUser(userID uuid.UUID, actor string) ([]model.GroupMembershipChange, error) {
+	return s.repo.RemoveUser(userID, actorOrSystem(actor), s.now().UTC())
+}
+
+func (s *groupService) Members(id uuid.UUID) ([]model.GroupMember, error) {
+	if _, err := s.repo.GetByID(id); err != nil {
+		return nil, err
+	}
+	return s.repo.Members(id)
+}
+
+func (s *groupService) EffectiveMembers(id uuid.UUID) ([]model.User, error) {
+	if _, err := s.repo.GetByID(id); err != nil {
+		return nil, err
+	}
+	ids, err := s.repo.EffectiveUserIDs(id)
+	if err != nil {
+		return nil, err
+	}
+	users := []model.User{}
+	if len(ids) > 0 {
+		found, err := s.users.GetByIDs(ids)
+		if err != nil {
+			return nil, err
+		}
+		users = append(users, found...)
+	}
+	sort.Slice(users, func(i, j int) bool { return users[i].Username < users[j].Username })
+	return users, nil
+}
+
+func (s *groupService) GroupsOfUser(userID uuid.UUID, effective bool) ([]model.Group, error) {
+	if _, err := s.users.GetByID(userID, nil); err != nil {
+		return nil, err
+	}
+	return s.repo.GroupsOfUser(userID, effective)
+}
+
+func (s *groupService) History(id uuid.UUID) ([]model.GroupMembershipChange, error) {
+	if _, err := s.repo.GetByID(id); err != nil {
+		return nil, err
+	}
+	return s.repo.History(id)
+}
+
+// groupLeavingUserService decorates a UserService so users leave their groups before they are
+// deleted, and the groups record and announce it, instead of the memberships silently going
+// with the user.
+type groupLeavingUserService struct {
+	UserService
+	groups GroupService
+}
+
+// WithGroupRemoval wraps users so deleting one first removes them from their groups through groups.
+func WithGroupRemoval(users UserService, groups GroupService) UserService {
+	return &groupLeavingUserService{UserService: users, groups: groups}
+}
+
+func (s *groupLeavingUserService) InOrganization(org uuid.UUID) UserService {
+	return &groupLeavingUserService{UserService: s.UserService.InOrganization(org), groups: s.groups.InOrganization(org)}
+}
+
+func (s *groupLeavingUserService) Delete(id uuid.UUID, actor string) error {
+	if _, err := s.groups.RemoveUser(id, actor); err != nil {
+		return err
+	}
+	return s.UserService.Delete(id, actor)
+}
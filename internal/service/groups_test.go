@@ -0,0 +1,337 @@
+package service
+
+import (
+	"cruder/internal/errors"
+	"cruder/internal/events"
+	"cruder/internal/model"
+	"io"
+	"log/slog"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+)
+
+var groupTestTime = time.Date(2026, 3, 3, 10, 0, 0, 0, time.UTC)
+
+// newTestGroupService returns a group service over repo and users whose clock reads groupTestTime.
+func newTestGroupService(repo *MockGroupRepository, users *MockUserRepository) GroupService {
+	svc := NewGroupService(repo, users).(*groupService)
+	svc.now = func() time.Time { return groupTestTime }
+	return svc
+}
+
+var (
+	team  = model.Group{ID: uuid.New(), OrganizationID: model.DefaultOrganizationID, Name: "team"}
+	alice = model.User{ID: uuid.New(), OrganizationID: model.DefaultOrganizationID, Username: "alice"}
+)
+
+func groupName(s string) *string { return &s }
+
+// membership is the change of member in team by actor at groupTestTime.
+func membership(action, memberType string, memberID uuid.UUID, memberName, actor string) *model.GroupMembershipChange {
+	return &model.GroupMembershipChange{GroupID: team.ID, Action: action, MemberType: memberType, MemberID: memberID,
+		MemberName: memberName, Actor: actor, ChangedAt: groupTestTime}
+}
+
+func TestGroupService_Create(t *testing.T) {
+	// Given: A repository that stores groups
+	mockRepo := new(MockGroupRepository)
+	groups := newTestGroupService(mockRepo, new(MockUserRepository))
+	engineering := &model.Group{ID: uuid.New(), Name: "Engineering", Description: "Builds things"}
+	mockRepo.On("Create", &model.CreateGroupRequest{Name: "Engineering", Description: "Builds things"}).Return(engineering, nil)
+	mockRepo.On("Create", &model.CreateGroupRequest{Name: "Sales"}).Return(nil, errors.ErrGroupExists)
+
+	// When: Creating groups with names and descriptions in need of trimming
+	g, err := groups.Create(&model.CreateGroupRequest{Name: " Engineering ", Description: " Builds things "})
+
+	// Then: The trimmed request is stored, and a taken name is reported
+	require.NoError(t, err)
+	assert.Equal(t, engineering, g)
+	_, err = groups.Create(&model.CreateGroupRequest{Name: "Sales"})
+	assert.ErrorIs(t, err, errors.ErrGroupExists)
+	_, err = groups.Create(&model.CreateGroupRequest{Name: "  "})
+	assert.ErrorIs(t, err, errors.ErrInvalidInput)
+	mockRepo.AssertNumberOfCalls(t, "Create", 2)
+}
+
+func TestGroupService_Update(t *testing.T) {
+	mockRepo := new(MockGroupRepository)
+	groups := newTestGroupService(mockRepo, new(MockUserRepository))
+	mockRepo.On("Update", team.ID, &model.UpdateGroupRequest{Name: groupName("Engineering"), Description: groupName("")}).
+		Return(nil, errors.ErrGroupExists)
+
+	_, err := groups.Update(team.ID, &model.UpdateGroupRequest{Name: groupName(" Engineering "), Description: groupName("  ")})
+	assert.ErrorIs(t, err, errors.ErrGroupExists)
+	_, err = groups.Update(team.ID, &model.UpdateGroupRequest{Name: groupName("  ")})
+	assert.ErrorIs(t, err, errors.ErrInvalidInput, "names cannot be cleared")
+	mockRepo.AssertNumberOfCalls(t, "Update", 1)
+}
+
+func TestGroupService_AddMember(t *testing.T) {
+	// Given: A group and a user
+	mockRepo := new(MockGroupRepository)
+	users := new(MockUserRepository)
+	groups := newTestGroupService(mockRepo, users)
+	mockRepo.On("GetByID", team.ID).Return(&team, nil)
+	users.On("GetByID", alice.ID, model.UserFields(nil)).Return(&alice, nil)
+	want := membership(model.MembershipAdded, model.MemberTypeUser, alice.ID, "alice", "admin")
+	mockRepo.On("AddMember", want).Return(nil)
+
+	// When: Adding the user
+	change, err := groups.AddMember(team.ID, &model.AddGroupMemberRequest{UserID: &alice.ID, Actor: "admin"})
+
+	// Then: The change is recorded under the member's current name
+	require.NoError(t, err)
+	assert.Equal(t, want, change)
+	mockRepo.AssertExpectations(t)
+}
+
+func TestGroupService_AddMemberValidation(t *testing.T) {
+	mockRepo := new(MockGroupRepository)
+	users := new(MockUserRepository)
+	groups := newTestGroupService(mockRepo, users)
+	missing := uuid.New()
+	mockRepo.On("GetByID", team.ID).Return(&team, nil)
+	mockRepo.On("GetByID", missing).Return(nil, errors.ErrGroupNotFound)
+	users.On("GetByID", missing, model.UserFields(nil)).Return(nil, errors.ErrUserNotFound)
+
+	_, err := groups.AddMember(team.ID, &model.AddGroupMemberRequest{})
+	assert.ErrorIs(t, err, errors.ErrInvalidInput)
+	_, err = groups.AddMember(team.ID, &model.AddGroupMemberRequest{UserID: &alice.ID, GroupID: &team.ID})
+	assert.ErrorIs(t, err, errors.ErrInvalidInput)
+	_, err = groups.AddMember(team.ID, &model.AddGroupMemberRequest{UserID: &missing})
+	assert.ErrorIs(t, err, errors.ErrInvalidInput, "no such user in the organization")
+	_, err = groups.AddMember(team.ID, &model.AddGroupMemberRequest{GroupID: &missing})
+	assert.ErrorIs(t, err, errors.ErrInvalidInput, "no such group in the organization")
+	_, err = groups.AddMember(team.ID, &model.AddGroupMemberRequest{GroupID: &team.ID})
+	assert.ErrorIs(t, err, errors.ErrGroupCycle)
+	_, err = groups.AddMember(missing, &model.AddGroupMemberRequest{UserID: &alice.ID})
+	assert.ErrorIs(t, err, errors.ErrGroupNotFound)
+	mockRepo.AssertNotCalled(t, "AddMember", mock.Anything)
+}
+
+func TestGroupService_AddMemberRejectedByRepository(t *testing.T) {
+	// Cycles through nesting and duplicates are the repository's to find, in its transaction
+	for _, repoErr := range []error{errors.ErrGroupCycle, errors.ErrGroupMemberExists} {
+		mockRepo := new(MockGroupRepository)
+		groups := newTestGroupService(mockRepo, new(MockUserRepository))
+		child := model.Group{ID: uuid.New(), Name: "child"}
+		mockRepo.On("GetByID", team.ID).Return(&team, nil)
+		mockRepo.On("GetByID", child.ID).Return(&child, nil)
+		mockRepo.On("AddMember", membership(model.MembershipAdded, model.MemberTypeGroup, child.ID, "child", model.ActorSystem)).Return(repoErr)
+
+		_, err := groups.AddMember(team.ID, &model.AddGroupMemberRequest{GroupID: &child.ID})
+
+		assert.ErrorIs(t, err, repoErr)
+	}
+}
+
+func TestGroupService_RemoveMember(t *testing.T) {
+	mockRepo := new(MockGroupRepository)
+	users := new(MockUserRepository)
+	groups := newTestGroupService(mockRepo, users)
+	gone := uuid.New()
+	mockRepo.On("GetByID", team.ID).Return(&team, nil)
+	users.On("GetByID", alice.ID, model.UserFields(nil)).Return(&alice, nil)
+	users.On("GetByID", gone, model.UserFields(nil)).Return(nil, errors.ErrUserNotFound)
+	want := membership(model.MembershipRemoved, model.MemberTypeUser, alice.ID, "alice", model.ActorSystem)
+	mockRepo.On("RemoveMember", want).Return(nil).Once()
+	mockRepo.On("RemoveMember", want).Return(errors.ErrGroupMemberNotFound).Once()
+
+	change, err := groups.RemoveMember(team.ID, model.MemberTypeUser, alice.ID, "")
+	require.NoError(t, err)
+	assert.Equal(t, want, change, "removed by the system without a known caller")
+	_, err = groups.RemoveMember(team.ID, model.MemberTypeUser, alice.ID, "")
+	assert.ErrorIs(t, err, errors.ErrGroupMemberNotFound)
+	_, err = groups.RemoveMember(team.ID, model.MemberTypeUser, gone, "admin")
+	assert.ErrorIs(t, err, errors.ErrGroupMemberNotFound, "a member that no longer exists is no member")
+	mockRepo.AssertExpectations(t)
+}
+
+func TestGroupService_EffectiveMembers(t *testing.T) {
+	// Given: A group whose members, some through nesting, come back in any order
+	mockRepo := new(MockGroupRepository)
+	users := new(MockUserRepository)
+	groups := newTestGroupService(mockRepo, users)
+	bob := model.User{ID: uuid.New(), Username: "bob"}
+	carol := model.User{ID: uuid.New(), Username: "carol"}
+	mockRepo.On("GetByID", team.ID).Return(&team, nil)
+	mockRepo.On("EffectiveUserIDs", team.ID).Return([]uuid.UUID{carol.ID, alice.ID, bob.ID}, nil)
+	users.On("GetByIDs", []uuid.UUID{carol.ID, alice.ID, bob.ID}).Return([]model.User{carol, alice, bob}, nil)
+
+	// When: Listing them
+	members, err := groups.EffectiveMembers(team.ID)
+
+	// Then: They are sorted by username
+	require.NoError(t, err)
+	assert.Equal(t, []model.User{alice, bob, carol}, members)
+}
+
+func TestGroupService_EffectiveMembersOfEmptyGroup(t *testing.T) {
+	mockRepo := new(MockGroupRepository)
+	users := new(MockUserRepository)
+	groups := newTestGroupService(mockRepo, users)
+	mockRepo.On("GetByID", team.ID).Return(&team, nil)
+	mockRepo.On("EffectiveUserIDs", team.ID).Return([]uuid.UUID{}, nil)
+
+	members, err := groups.EffectiveMembers(team.ID)
+
+	require.NoError(t, err)
+	assert.NotNil(t, members, "an empty list, not null")
+	assert.Empty(t, members)
+	users.AssertNotCalled(t, "GetByIDs", mock.Anything)
+}
+
+func TestGroupService_LookupsOfMissingGroupsOrUsers(t *testing.T) {
+	mockRepo := new(MockGroupRepository)
+	users := new(MockUserRepository)
+	groups := newTestGroupService(mockRepo, users)
+	missing := uuid.New()
+	mockRepo.On("GetByID", missing).Return(nil, errors.ErrGroupNotFound)
+	users.On("GetByID", missing, model.UserFields(nil)).Return(nil, errors.ErrUserNotFound)
+
+	_, err := groups.Members(missing)
+	assert.ErrorIs(t, err, errors.ErrGroupNotFound)
+	_, err = groups.EffectiveMembers(missing)
+	assert.ErrorIs(t, err, errors.ErrGroupNotFound)
+	_, err = groups.History(missing)
+	assert.ErrorIs(t, err, errors.ErrGroupNotFound)
+	_, err = groups.GroupsOfUser(missing, true)
+	assert.ErrorIs(t, err, errors.ErrUserNotFound)
+	mockRepo.AssertNotCalled(t, "Members", mock.Anything)
+	mockRepo.AssertNotCalled(t, "EffectiveUserIDs", mock.Anything)
+	mockRepo.AssertNotCalled(t, "History", mock.Anything)
+	mockRepo.AssertNotCalled(t, "GroupsOfUser", mock.Anything, mock.Anything)
+}
+
+func TestGroupService_GroupsOfUser(t *testing.T) {
+	mockRepo := new(MockGroupRepository)
+	users := new(MockUserRepository)
+	groups := newTestGroupService(mockRepo, users)
+	users.On("GetByID", alice.ID, model.UserFields(nil)).Return(&alice, nil)
+	mockRepo.On("GroupsOfUser", alice.ID, true).Return([]model.Group{team}, nil)
+
+	found, err := groups.GroupsOfUser(alice.ID, true)
+
+	require.NoError(t, err)
+	assert.Equal(t, []model.Group{team}, found)
+	mockRepo.AssertExpectations(t)
+}
+
+func TestGroupService_DeletionsEndMemberships(t *testing.T) {
+	// Given: Group events, and users that leave their groups when deleted
+	broker := events.NewBroker()
+	ch, unsubscribe := broker.Subscribe()
+	defer unsubscribe()
+	mockRepo := new(MockGroupRepository)
+	userRepo := new(MockUserRepository)
+	groups := WithGroupEvents(newTestGroupService(mockRepo, userRepo), broker, slog.New(slog.NewTextHandler(io.Discard, nil)))
+	users := WithGroupRemoval(NewUserService(userRepo), groups)
+	company := uuid.New()
+	left := []model.GroupMembershipChange{*membership(model.MembershipRemoved, model.MemberTypeUser, alice.ID, "alice", "admin")}
+	ended := []model.GroupMembershipChange{
+		*membership(model.MembershipRemoved, model.MemberTypeUser, alice.ID, "alice", "admin"),
+		{GroupID: company, Action: model.MembershipRemoved, MemberType: model.MemberTypeGroup, MemberID: team.ID, MemberName: "team",
+			Actor: "admin", ChangedAt: groupTestTime},
+	}
+	leave := mockRepo.On("RemoveUser", alice.ID, "admin", groupTestTime).Return(left, nil)
+	remove := userRepo.On("Delete", alice.ID).Return(nil)
+	mockRepo.On("Delete", team.ID, "admin", groupTestTime).Return(ended, nil)
+	mock.InOrder(leave, remove)
+
+	// When: Deleting a user, then a group
+	require.NoError(t, users.Delete(alice.ID, "admin"))
+	changes, err := groups.Delete(team.ID, "admin")
+
+	// Then: Every membership that ended is announced as a removal
+	require.NoError(t, err)
+	assert.Equal(t, ended, changes)
+	for _, want := range append(left, ended...) {
+		e := <-ch
+		assert.Equal(t, events.GroupMemberRemoved, e.Type)
+		assert.Equal(t, want, *e.Membership)
+	}
+	assert.Empty(t, ch)
+	mockRepo.AssertExpectations(t)
+	userRepo.AssertExpectations(t)
+}
+
+func TestGroupService_UserStaysIfLeavingFails(t *testing.T) {
+	mockRepo := new(MockGroupRepository)
+	userRepo := new(MockUserRepository)
+	users := WithGroupRemoval(NewUserService(userRepo), newTestGroupService(mockRepo, userRepo))
+	mockRepo.On("RemoveUser", alice.ID, model.ActorSystem, groupTestTime).Return(nil, errors.ErrDatabaseOperation)
+
+	err := users.Delete(alice.ID, "")
+
+	assert.ErrorIs(t, err, errors.ErrDatabaseOperation)
+	userRepo.AssertNotCalled(t, "Delete", mock.Anything)
+}
+
+func TestGroupService_Events(t *testing.T) {
+	// Given: Group events in another organization than the default one
+	broker := events.NewBroker()
+	ch, unsubscribe := broker.Subscribe()
+	defer unsubscribe()
+	mockRepo := new(MockGroupRepository)
+	users := new(MockUserRepository)
+	acme := uuid.New()
+	groups := WithGroupEvents(newTestGroupService(mockRepo, users), broker, slog.New(slog.NewTextHandler(io.Discard, nil))).InOrganization(acme)
+	mockRepo.On("GetByID", team.ID).Return(&team, nil)
+	users.On("GetByID", alice.ID, model.UserFields(nil)).Return(&alice, nil)
+	mockRepo.On("AddMember", mock.Anything).Return(nil)
+	mockRepo.On("RemoveMember", mock.Anything).Return(nil).Once()
+	mockRepo.On("RemoveMember", mock.Anything).Return(errors.ErrGroupMemberNotFound)
+
+	// When: A member is added and removed, and removing them again fails
+	_, err := groups.AddMember(team.ID, &model.AddGroupMemberRequest{UserID: &alice.ID, Actor: "admin"})
+	require.NoError(t, err)
+	_, err = groups.RemoveMember(team.ID, model.MemberTypeUser, alice.ID, "admin")
+	require.NoError(t, err)
+	_, err = groups.RemoveMember(team.ID, model.MemberTypeUser, alice.ID, "admin")
+	require.ErrorIs(t, err, errors.ErrGroupMemberNotFound)
+
+	// Then: The changes are announced as the user's, in the organization; failed ones are not
+	for _, want := range []events.Type{events.GroupMemberAdded, events.GroupMemberRemoved} {
+		e := <-ch
+		assert.Equal(t, want, e.Type)
+		assert.Equal(t, acme, e.User.OrganizationID)
+		assert.Equal(t, alice.ID, e.User.ID)
+		require.NotNil(t, e.Membership)
+		assert.Equal(t, team.ID, e.Membership.GroupID)
+		assert.Equal(t, "alice", e.Membership.MemberName)
+		assert.Equal(t, groupTestTime, e.OccurredAt)
+	}
+	assert.Empty(t, ch)
+}
+
+func TestGroupService_OrganizationsAreIsolated(t *testing.T) {
+	// Given: Groups and users stored per organization
+	acmeID := uuid.New()
+	acmeGroups, acmeUsers := new(MockGroupRepository), new(MockUserRepository)
+	mockRepo := &MockGroupRepository{Organizations: map[uuid.UUID]*MockGroupRepository{acmeID: acmeGroups}}
+	users := &MockOrganizationUserRepository{Organizations: map[uuid.UUID]*MockUserRepository{acmeID: acmeUsers}}
+	groups := NewGroupService(mockRepo, users).InOrganization(acmeID)
+	acmeGroups.On("GetByID", team.ID).Return(&team, nil)
+	acmeUsers.On("GetByID", alice.ID, model.UserFields(nil)).Return(&alice, nil)
+	acmeGroups.On("AddMember", mock.Anything).Return(nil)
+	acmeGroups.On("List").Return([]model.Group{team}, nil)
+
+	// When: Working in another organization than the default one
+	_, err := groups.AddMember(team.ID, &model.AddGroupMemberRequest{UserID: &alice.ID})
+	require.NoError(t, err)
+	listed, err := groups.List()
+	require.NoError(t, err)
+	assert.Equal(t, []model.Group{team}, listed)
+
+	// Then: Only that organization's groups and users are used
+	acmeGroups.AssertExpectations(t)
+	acmeUsers.AssertExpectations(t)
+	mockRepo.AssertNotCalled(t, "GetByID", mock.Anything)
+	mockRepo.AssertNotCalled(t, "List")
+	users.AssertNotCalled(t, "GetByID", mock.Anything, mock.Anything)
+}
@@ -0,0 +1,232 @@
+package service
+
+import (
+	"context"
+	"cruder/internal/errors"
+	"cruder/internal/mail"
+	"cruder/internal/model"
+	"cruder/internal/repository"
+	"fmt"
+	"log/slog"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// DefaultInvitationTTL is how long an invitation stays valid when InvitationOptions.TTL is zero.
+const DefaultInvitationTTL = 7 * 24 * time.Hour
+
+// InvitationService invites people by email to create their own account. The invitee picks a
+// username and full name; the address is the invited one and, since the token reached it, verified.
+type InvitationService interface {
+	// InOrganization returns the service for the invitations of org; Redeem finds the invitation
+	// by token, in whichever organization it is.
+	InOrganization(org uuid.UUID) InvitationService
+	// Create records an invitation for req.Email and mails it a token. It fails with ErrEmailExists
+	// if a user has the address or awaits confirmation of it, and with ErrInvitationExists if it has
+	// a pending invitation.
+	// A failure to mail is logged, not returned, because the invitation was recorded; Resend sends it again.
+	Create(req *model.CreateInvitationRequest) (*model.Invitation, error)
+	// Get returns an invitation whatever its status, or ErrInvitationNotFound.
+	Get(id uuid.UUID) (*model.Invitation, error)
+	// ListPending returns the invitations that can still be redeemed, newest first.
+	ListPending() ([]model.Invitation, error)
+	// Resend mails an invitation a new token, invalidating the previous one, and renews its expiry,
+	// which also revives an expired invitation. Redeemed and revoked ones fail with ErrInvitationNotPending.
+	Resend(id uuid.UUID) (*model.Invitation, error)
+	// Revoke withdraws an invitation so its token no longer works, or fails with ErrInvitationNotPending.
+	Revoke(id uuid.UUID) (*model.Invitation, error)
+	// Redeem consumes a token and creates the invited user with a verified address, invited and
+	// then, as the invitee has accepted, active.
+	// Unknown tokens and those of redeemed, revoked or expired invitations fail with
+	// ErrInvitationTokenInvalid. If the user cannot be created, e.g. because the username is
+	// taken, the error is the one UserService.Create returns and the token keeps working.
+	Redeem(req *model.RedeemInvitationRequest) (*model.User, error)
+}
+
+// InvitationOptions configure the invitation emails.
+type InvitationOptions struct {
+	TTL time.Duration
+	// URL is the sign-up page the email links to, with ?token=... appended. Without one the
+	// email only carries the token.
+	URL string
+}
+
+type invitationService struct {
+	repo repository.InvitationRepository
+	// users creates the invitees; it must not be wrapped by WithEmailVerification, as redeeming proves the address
+	users    UserService
+	userRepo repository.UserRepository
+	// emailChanges tells whether an address is taken, by a user or by a pending email change
+	emailChanges EmailChangeService
+	mailer       mail.Mailer
+	opts         InvitationOptions
+	logger       *slog.Logger
+	now          func() time.Time
+}
+
+func NewInvitationService(repo repository.InvitationRepository, users UserService, userRepo repository.UserRepository, emailChanges EmailChangeService,
+	mailer mail.Mailer, opts InvitationOptions, logger *slog.Logger) InvitationService {
+	if opts.TTL == 0 {
+		opts.TTL = DefaultInvitationTTL
+	}
+	return &invitationService{repo: repo, users: users, userRepo: userRepo, emailChanges: emailChanges, mailer: mailer, opts: opts, logger: logger,
+		now: time.Now}
+}
+
+func (s *invitationService) InOrganization(org uuid.UUID) InvitationService {
+	scoped := *s
+	scoped.repo = s.repo.InOrganization(org)
+	scoped.users = s.users.InOrganization(org)
+	scoped.userRepo = s.userRepo.InOrganization(org)
+	scoped.emailChanges = s.emailChanges.InOrganization(org)
+	return &scoped
+}
+
+// withStatus fills in the status of inv as of now.
+func (s *invitationService) withStatus(inv *model.Invitation) *model.Invitation {
+	inv.Status = inv.StatusAt(s.now())
+	return inv
+}
+
+func (s *invitationService) Create(req *model.CreateInvitationRequest) (*model.Invitation, error) {
+	email := strings.TrimSpace(strings.ToLower(req.Email))
+	if email == "" {
+		return nil, fmt.Errorf("%w: email is required", errors.ErrInvalidInput)
+	}
+	if err := s.emailChanges.CheckAvailable(uuid.Nil, email); err != nil {
+		return nil, err
+	}
+
+	token, hash, err := newMailToken()
+	if err != nil {
+		return nil, err
+	}
+	actor := req.Actor
+	if actor == "" {
+		actor = model.ActorSystem
+	}
+	now := s.now()
+	inv := &model.Invitation{
+		Email:     email,
+		TokenHash: hash,
+		InvitedBy: actor,
+		ExpiresAt: now.Add(s.opts.TTL),
+		CreatedAt: now,
+	}
+	if err := s.repo.Create(inv); err != nil {
+		return nil, err
+	}
+
+	if err := s.send(inv, token); err != nil {
+		s.logger.Error("Failed to send invitation email",
+			slog.String("invitation_id", inv.ID.String()),
+			slog.String("error", err.Error()))
+	}
+	return s.withStatus(inv), nil
+}
+
+func (s *invitationService) send(inv *model.Invitation, token string) error {
+	ctx, cancel := context.WithTimeout(context.Background(), mailTimeout)
+	defer cancel()
+	return s.mailer.Send(ctx, mail.Message{
+		To:      inv.Email,
+		Subject: "You are invited to create an account",
+		Text:    s.messageText(inv, token),
+	})
+}
+
+func (s *invitationService) messageText(inv *model.Invitation, token string) string {
+	var b strings.Builder
+	b.WriteString("Hello,\n\n")
+	fmt.Fprintf(&b, "you have been invited to create an account for %s. You choose your username and name when you accept.\n\n", inv.Email)
+	writeToken(&b, "accept the invitation", s.opts.URL, token)
+	fmt.Fprintf(&b, "It expires in %s and works once. If you were not expecting this, you can ignore this email.\n", formatTTL(s.opts.TTL))
+	return b.String()
+}
+
+func (s *invitationService) Get(id uuid.UUID) (*model.Invitation, error) {
+	inv, err := s.repo.GetByID(id)
+	if err != nil {
+		return nil, err
+	}
+	return s.withStatus(inv), nil
+}
+
+func (s *invitationService) ListPending() ([]model.Invitation, error) {
+	invitations, err := s.repo.ListPending(s.now())
+	if err != nil {
+		return nil, err
+	}
+	for i := range invitations {
+		s.withStatus(&invitations[i])
+	}
+	return invitations, nil
+}
+
+func (s *invitationService) Resend(id uuid.UUID) (*model.Invitation, error) {
+	token, hash, err := newMailToken()
+	if err != nil {
+		return nil, err
+	}
+	now := s.now()
+	inv, err := s.repo.Renew(id, hash, now.Add(s.opts.TTL), now)
+	if err != nil {
+		return nil, err
+	}
+	if err := s.send(inv, token); err != nil {
+		return nil, fmt.Errorf("invitation renewed but not mailed: %w", err)
+	}
+	return s.withStatus(inv), nil
+}
+
+func (s *invitationService) Revoke(id uuid.UUID) (*model.Invitation, error) {
+	inv, err := s.repo.Revoke(id, s.now())
+	if err != nil {
+		return nil, err
+	}
+	return s.withStatus(inv), nil
+}
+
+func (s *invitationService) Redeem(req *model.RedeemInvitationRequest) (*model.User, error) {
+	inv, err := s.repo.GetByToken(hashMailToken(strings.TrimSpace(req.Token)))
+	if err != nil {
+		return nil, err
+	}
+	s = s.InOrganization(inv.OrganizationID).(*invitationService)
+	now := s.now()
+	if inv.StatusAt(now) != model.InvitationPending {
+		return nil, errors.ErrInvitationTokenInvalid
+	}
+	// Claims the invitation before the user is created, so concurrent redemptions create one user
+	if err := s.repo.Claim(inv.ID, now); err != nil {
+		return nil, err
+	}
+
+	user, err := s.users.Create(&model.CreateUserRequest{
+		Username: req.Username,
+		Email:    inv.Email,
+		FullName: req.FullName,
+		Status:   model.UserInvited,
+	})
+	if err != nil {
+		// The invitee may try again, say with another username
+		if relErr := s.repo.Release(inv.ID); relErr != nil {
+			return nil, relErr
+		}
+		return nil, err
+	}
+	if err := s.repo.SetUser(inv.ID, user.ID); err != nil {
+		return nil, err
+	}
+	// Receiving the token proves the address
+	if err := s.userRepo.MarkEmailVerified(user.ID, user.Email, now); err != nil {
+		return nil, err
+	}
+	// Accepting the invitation is what activates the account, as the status history shows
+	if _, err := s.users.ChangeStatus(user.ID, model.UserActive, &model.ChangeUserStatusRequest{Reason: "invitation accepted"}); err != nil {
+		return nil, err
+	}
+	return s.users.GetByID(user.ID, nil)
+}
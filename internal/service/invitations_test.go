@@ -0,0 +1,310 @@
+package service
+
+import (
+	"cruder/internal/errors"
+	"cruder/internal/mail"
+	"cruder/internal/model"
+	"cruder/internal/repository"
+	"io"
+	"log/slog"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+)
+
+var invitationTestTime = time.Date(2026, 3, 2, 9, 0, 0, 0, time.UTC)
+
+// newTestInvitationService returns an invitation service over repo and users, checking addresses
+// against changes, whose clock reads *now.
+func newTestInvitationService(repo *MockInvitationRepository, users repository.UserRepository, changes *MockEmailChangeRepository,
+	mailer mail.Mailer, now *time.Time) *invitationService {
+	emailChanges := NewEmailChangeService(changes, NewUserService(users), users, mailer, EmailChangeOptions{}).(*emailChangeService)
+	emailChanges.now = func() time.Time { return *now }
+	svc := NewInvitationService(repo, NewUserService(users), users, emailChanges, mailer,
+		InvitationOptions{URL: "https://app.example.com/join"}, slog.New(slog.NewTextHandler(io.Discard, nil))).(*invitationService)
+	svc.now = func() time.Time { return *now }
+	return svc
+}
+
+// pendingInvitation is an invitation of email sent at now, with "the-token" as its token.
+func pendingInvitation(email string, now time.Time) *model.Invitation {
+	return &model.Invitation{ID: uuid.New(), OrganizationID: model.DefaultOrganizationID, Email: email, InvitedBy: "admin",
+		ExpiresAt: now.Add(DefaultInvitationTTL), SentAt: now, CreatedAt: now, TokenHash: hashMailToken("the-token")}
+}
+
+func redeemRequest(username string) *model.RedeemInvitationRequest {
+	return &model.RedeemInvitationRequest{Token: " the-token ", Username: username, FullName: "New Hire"}
+}
+
+func TestInvitation_Create(t *testing.T) {
+	// Given: An address nobody has or awaits
+	mockRepo := new(MockInvitationRepository)
+	users := new(MockUserRepository)
+	changes := new(MockEmailChangeRepository)
+	mailer := &mail.MemoryMailer{}
+	now := invitationTestTime
+	svc := newTestInvitationService(mockRepo, users, changes, mailer, &now)
+	users.On("GetByEmail", "new.hire@example.com", model.UserFields{"id"}).Return(nil, errors.ErrUserNotFound)
+	changes.On("IsPending", model.DefaultOrganizationID, "new.hire@example.com", uuid.Nil, now).Return(false, nil)
+	var stored *model.Invitation
+	mockRepo.On("Create", mock.Anything).Run(func(args mock.Arguments) {
+		stored = args.Get(0).(*model.Invitation)
+		stored.ID = uuid.New()
+	}).Return(nil)
+
+	// When: Inviting it
+	inv, err := svc.Create(&model.CreateInvitationRequest{Email: " New.Hire@Example.com ", Actor: "admin"})
+
+	// Then: The invitation is pending, and only the hash of the mailed token is stored
+	require.NoError(t, err)
+	assert.Same(t, stored, inv)
+	assert.Equal(t, "new.hire@example.com", inv.Email)
+	assert.Equal(t, "admin", inv.InvitedBy)
+	assert.Equal(t, model.InvitationPending, inv.Status)
+	assert.Equal(t, now.Add(DefaultInvitationTTL), inv.ExpiresAt)
+
+	msg, ok := mailer.Last("new.hire@example.com")
+	require.True(t, ok)
+	assert.Contains(t, msg.Text, "https://app.example.com/join?token=")
+	assert.Equal(t, hashMailToken(mailedToken(t, mailer, "new.hire@example.com", "You are invited to create an account")), stored.TokenHash)
+}
+
+func TestInvitation_CreateRejectsTakenAddresses(t *testing.T) {
+	mockRepo := new(MockInvitationRepository)
+	users := new(MockUserRepository)
+	changes := new(MockEmailChangeRepository)
+	mailer := &mail.MemoryMailer{}
+	now := invitationTestTime
+	svc := newTestInvitationService(mockRepo, users, changes, mailer, &now)
+	users.On("GetByEmail", "john.doe@example.com", model.UserFields{"id"}).Return(&model.User{ID: uuid.New()}, nil)
+	users.On("GetByEmail", mock.Anything, model.UserFields{"id"}).Return(nil, errors.ErrUserNotFound)
+	changes.On("IsPending", model.DefaultOrganizationID, "alice.smith@example.com", uuid.Nil, now).Return(true, nil)
+	changes.On("IsPending", model.DefaultOrganizationID, "new@example.com", uuid.Nil, now).Return(false, nil)
+	mockRepo.On("Create", mock.Anything).Return(errors.ErrInvitationExists)
+
+	_, err := svc.Create(&model.CreateInvitationRequest{Email: "John.Doe@example.com"})
+	assert.ErrorIs(t, err, errors.ErrEmailExists, "a user has it")
+	_, err = svc.Create(&model.CreateInvitationRequest{Email: "alice.smith@example.com"})
+	assert.ErrorIs(t, err, errors.ErrEmailExists, "a user asked to change to it")
+	_, err = svc.Create(&model.CreateInvitationRequest{Email: "new@example.com"})
+	assert.ErrorIs(t, err, errors.ErrInvitationExists, "it has a pending invitation")
+	_, err = svc.Create(&model.CreateInvitationRequest{Email: "  "})
+	assert.ErrorIs(t, err, errors.ErrInvalidInput)
+
+	mockRepo.AssertNumberOfCalls(t, "Create", 1)
+	assert.Empty(t, mailer.Messages())
+}
+
+func TestInvitation_CreateMailFailureIsNotAnError(t *testing.T) {
+	mockRepo := new(MockInvitationRepository)
+	users := new(MockUserRepository)
+	changes := new(MockEmailChangeRepository)
+	now := invitationTestTime
+	svc := newTestInvitationService(mockRepo, users, changes, &mail.MemoryMailer{}, &now)
+	users.On("GetByEmail", mock.Anything, mock.Anything).Return(nil, errors.ErrUserNotFound)
+	changes.On("IsPending", mock.Anything, mock.Anything, mock.Anything, mock.Anything).Return(false, nil)
+	mockRepo.On("Create", mock.Anything).Return(nil)
+
+	// The memory mailer refuses what is not an address
+	inv, err := svc.Create(&model.CreateInvitationRequest{Email: "not an address"})
+
+	require.NoError(t, err, "the invitation is recorded; resending mails it again")
+	assert.Equal(t, model.InvitationPending, inv.Status)
+}
+
+func TestInvitation_Redeem(t *testing.T) {
+	// Given: A pending invitation
+	mockRepo := new(MockInvitationRepository)
+	users := new(MockUserRepository)
+	now := invitationTestTime
+	svc := newTestInvitationService(mockRepo, users, new(MockEmailChangeRepository), &mail.MemoryMailer{}, &now)
+	inv := pendingInvitation("new.hire@example.com", now)
+	invited := &model.User{ID: uuid.New(), Username: "newhire", Email: inv.Email, Status: model.UserInvited}
+	active := &model.User{ID: invited.ID, Username: "newhire", Email: inv.Email, Status: model.UserActive, EmailVerifiedAt: &now}
+	mockRepo.On("GetByToken", hashMailToken("the-token")).Return(inv, nil)
+	claim := mockRepo.On("Claim", inv.ID, now).Return(nil)
+	create := users.On("Create", &model.CreateUserRequest{Username: "newhire", Email: inv.Email, FullName: "New Hire", Status: model.UserInvited}).
+		Return(invited, nil)
+	setUser := mockRepo.On("SetUser", inv.ID, invited.ID).Return(nil)
+	users.On("MarkEmailVerified", invited.ID, inv.Email, now).Return(nil)
+	users.On("GetByID", invited.ID, model.UserFields{"status"}).Return(invited, nil)
+	users.On("SetStatus", invited.ID, &model.UserStatusChange{UserID: invited.ID, OldStatus: model.UserInvited, NewStatus: model.UserActive,
+		Reason: "invitation accepted", Actor: model.ActorSystem}).Return(active, nil)
+	users.On("GetByID", invited.ID, model.UserFields(nil)).Return(active, nil)
+	// The invitation is claimed first, so concurrent redemptions create one user
+	mock.InOrder(claim, create, setUser)
+
+	// When: Redeeming its token
+	user, err := svc.Redeem(redeemRequest("NewHire"))
+
+	// Then: The invitee has an account at the invited address, verified, and active since accepting
+	require.NoError(t, err)
+	assert.Equal(t, active, user)
+	mockRepo.AssertExpectations(t)
+	users.AssertExpectations(t)
+}
+
+func TestInvitation_RedeemClosedOrExpired(t *testing.T) {
+	now := invitationTestTime
+	redeemed, revoked, expired := pendingInvitation("a@example.com", now), pendingInvitation("b@example.com", now), pendingInvitation("c@example.com", now)
+	redeemed.RedeemedAt = &now
+	revoked.RevokedAt = &now
+	expired.ExpiresAt = now
+	for why, inv := range map[string]*model.Invitation{"redeemed": redeemed, "revoked": revoked, "expired at the instant": expired} {
+		mockRepo := new(MockInvitationRepository)
+		users := new(MockUserRepository)
+		svc := newTestInvitationService(mockRepo, users, new(MockEmailChangeRepository), &mail.MemoryMailer{}, &now)
+		mockRepo.On("GetByToken", mock.Anything).Return(inv, nil)
+
+		_, err := svc.Redeem(redeemRequest("newhire"))
+
+		assert.ErrorIs(t, err, errors.ErrInvitationTokenInvalid, why)
+		mockRepo.AssertNotCalled(t, "Claim", mock.Anything, mock.Anything)
+		users.AssertNotCalled(t, "Create", mock.Anything)
+	}
+}
+
+func TestInvitation_RedeemInvalidOrClaimed(t *testing.T) {
+	mockRepo := new(MockInvitationRepository)
+	users := new(MockUserRepository)
+	now := invitationTestTime
+	svc := newTestInvitationService(mockRepo, users, new(MockEmailChangeRepository), &mail.MemoryMailer{}, &now)
+	inv := pendingInvitation("new@example.com", now)
+	mockRepo.On("GetByToken", hashMailToken("the-token")).Return(inv, nil)
+	mockRepo.On("GetByToken", hashMailToken("not-a-token")).Return(nil, errors.ErrInvitationTokenInvalid)
+	// Another redemption claimed it in between
+	mockRepo.On("Claim", inv.ID, now).Return(errors.ErrInvitationTokenInvalid)
+
+	_, err := svc.Redeem(&model.RedeemInvitationRequest{Token: "not-a-token", Username: "newhire", FullName: "New Hire"})
+	assert.ErrorIs(t, err, errors.ErrInvitationTokenInvalid)
+	_, err = svc.Redeem(redeemRequest("newhire"))
+	assert.ErrorIs(t, err, errors.ErrInvitationTokenInvalid, "invitations are single-use")
+	users.AssertNotCalled(t, "Create", mock.Anything)
+}
+
+func TestInvitation_FailedRedeemKeepsToken(t *testing.T) {
+	// Given: A pending invitation and a username that is taken
+	mockRepo := new(MockInvitationRepository)
+	users := new(MockUserRepository)
+	now := invitationTestTime
+	svc := newTestInvitationService(mockRepo, users, new(MockEmailChangeRepository), &mail.MemoryMailer{}, &now)
+	inv := pendingInvitation("new@example.com", now)
+	mockRepo.On("GetByToken", mock.Anything).Return(inv, nil)
+	claim := mockRepo.On("Claim", inv.ID, now).Return(nil)
+	users.On("Create", mock.Anything).Return(nil, errors.ErrUsernameExists)
+	release := mockRepo.On("Release", inv.ID).Return(nil)
+	mock.InOrder(claim, release)
+
+	// When: Redeeming with that username
+	_, err := svc.Redeem(redeemRequest("taken"))
+
+	// Then: The claim is released, so the invitee can try another one
+	assert.ErrorIs(t, err, errors.ErrUsernameExists)
+	mockRepo.AssertExpectations(t)
+	mockRepo.AssertNotCalled(t, "SetUser", mock.Anything, mock.Anything)
+}
+
+func TestInvitation_Resend(t *testing.T) {
+	// Given: An invitation, expired or not
+	mockRepo := new(MockInvitationRepository)
+	mailer := &mail.MemoryMailer{}
+	now := invitationTestTime.Add(DefaultInvitationTTL)
+	svc := newTestInvitationService(mockRepo, new(MockUserRepository), new(MockEmailChangeRepository), mailer, &now)
+	inv := pendingInvitation("new@example.com", now)
+	var renewedHash []byte
+	mockRepo.On("Renew", inv.ID, mock.Anything, now.Add(DefaultInvitationTTL), now).Run(func(args mock.Arguments) {
+		renewedHash = args.Get(1).([]byte)
+	}).Return(inv, nil)
+
+	// When: Resending it
+	resent, err := svc.Resend(inv.ID)
+
+	// Then: It is renewed as of now with the hash of the newly mailed token
+	require.NoError(t, err)
+	assert.Equal(t, model.InvitationPending, resent.Status)
+	assert.Equal(t, hashMailToken(mailedToken(t, mailer, "new@example.com", "You are invited to create an account")), renewedHash)
+	assert.NotEqual(t, hashMailToken("the-token"), renewedHash, "resending replaces the token")
+}
+
+func TestInvitation_ResendOrRevokeClosed(t *testing.T) {
+	mockRepo := new(MockInvitationRepository)
+	mailer := &mail.MemoryMailer{}
+	now := invitationTestTime
+	svc := newTestInvitationService(mockRepo, new(MockUserRepository), new(MockEmailChangeRepository), mailer, &now)
+	closed, reinvited, missing := uuid.New(), uuid.New(), uuid.New()
+	mockRepo.On("Renew", closed, mock.Anything, mock.Anything, mock.Anything).Return(nil, errors.ErrInvitationNotPending)
+	mockRepo.On("Renew", reinvited, mock.Anything, mock.Anything, mock.Anything).Return(nil, errors.ErrInvitationExists)
+	mockRepo.On("Revoke", closed, now).Return(nil, errors.ErrInvitationNotPending)
+	mockRepo.On("Revoke", missing, now).Return(nil, errors.ErrInvitationNotFound)
+
+	_, err := svc.Resend(closed)
+	assert.ErrorIs(t, err, errors.ErrInvitationNotPending)
+	_, err = svc.Resend(reinvited)
+	assert.ErrorIs(t, err, errors.ErrInvitationExists, "the newer invitation is the one to resend")
+	_, err = svc.Revoke(closed)
+	assert.ErrorIs(t, err, errors.ErrInvitationNotPending)
+	_, err = svc.Revoke(missing)
+	assert.ErrorIs(t, err, errors.ErrInvitationNotFound)
+	assert.Empty(t, mailer.Messages())
+}
+
+func TestInvitation_StatusAsOfNow(t *testing.T) {
+	mockRepo := new(MockInvitationRepository)
+	now := invitationTestTime
+	svc := newTestInvitationService(mockRepo, new(MockUserRepository), new(MockEmailChangeRepository), &mail.MemoryMailer{}, &now)
+	inv := pendingInvitation("new@example.com", now)
+	revoked := pendingInvitation("old@example.com", now)
+	revoked.RevokedAt = &now
+	mockRepo.On("GetByID", inv.ID).Return(inv, nil)
+	mockRepo.On("Revoke", revoked.ID, now).Return(revoked, nil)
+	mockRepo.On("ListPending", now).Return([]model.Invitation{*pendingInvitation("a@example.com", now)}, nil)
+
+	got, err := svc.Revoke(revoked.ID)
+	require.NoError(t, err)
+	assert.Equal(t, model.InvitationRevoked, got.Status)
+	pending, err := svc.ListPending()
+	require.NoError(t, err)
+	require.Len(t, pending, 1)
+	assert.Equal(t, model.InvitationPending, pending[0].Status)
+
+	now = inv.ExpiresAt
+	got, err = svc.Get(inv.ID)
+	require.NoError(t, err)
+	assert.Equal(t, model.InvitationExpired, got.Status)
+}
+
+func TestInvitation_RedeemInItsOrganization(t *testing.T) {
+	// Given: An invitation of another organization than the default one
+	acmeID := uuid.New()
+	acmeInvitations, acmeUsers := new(MockInvitationRepository), new(MockUserRepository)
+	mockRepo := &MockInvitationRepository{Organizations: map[uuid.UUID]*MockInvitationRepository{acmeID: acmeInvitations}}
+	users := &MockOrganizationUserRepository{Organizations: map[uuid.UUID]*MockUserRepository{acmeID: acmeUsers}}
+	now := invitationTestTime
+	svc := newTestInvitationService(mockRepo, users, new(MockEmailChangeRepository), &mail.MemoryMailer{}, &now)
+	inv := pendingInvitation("new@example.com", now)
+	inv.OrganizationID = acmeID
+	user := &model.User{ID: uuid.New(), OrganizationID: acmeID, Username: "newhire", Email: inv.Email, Status: model.UserInvited}
+	mockRepo.On("GetByToken", hashMailToken("the-token")).Return(inv, nil)
+	acmeInvitations.On("Claim", inv.ID, now).Return(nil)
+	acmeUsers.On("Create", mock.Anything).Return(user, nil)
+	acmeInvitations.On("SetUser", inv.ID, user.ID).Return(nil)
+	acmeUsers.On("MarkEmailVerified", user.ID, inv.Email, now).Return(nil)
+	acmeUsers.On("GetByID", user.ID, model.UserFields{"status"}).Return(user, nil)
+	acmeUsers.On("SetStatus", user.ID, mock.Anything).Return(user, nil)
+	acmeUsers.On("GetByID", user.ID, model.UserFields(nil)).Return(user, nil)
+
+	// When: Redeeming its token, from wherever
+	redeemed, err := svc.Redeem(redeemRequest("newhire"))
+
+	// Then: The token finds the organization by itself, and the user is created there
+	require.NoError(t, err)
+	assert.Equal(t, acmeID, redeemed.OrganizationID)
+	acmeInvitations.AssertExpectations(t)
+	acmeUsers.AssertExpectations(t)
+	mockRepo.AssertNotCalled(t, "Claim", mock.Anything, mock.Anything)
+	users.AssertNotCalled(t, "Create", mock.Anything)
+}
@@ -0,0 +1,121 @@
+package service
+
+import (
+	"cruder/internal/errors"
+	"cruder/internal/export"
+	"cruder/internal/model"
+	"cruder/internal/repository"
+	"encoding/json"
+	"fmt"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// JobService starts background jobs and reports on them. The work itself is done by jobs.Worker.
+// Jobs belong to the organization they were started in, and are not found from any other.
+type JobService interface {
+	// InOrganization returns the service for the jobs of org; NewJobService works in the default one.
+	InOrganization(org uuid.UUID) JobService
+	EnqueueUserExport(params model.UserExportParams) (*model.Job, error)
+	// EnqueueUserImport queues an import of csvFile, which is stored with the job.
+	EnqueueUserImport(params model.UserImportParams, csvFile []byte) (*model.Job, error)
+	Get(id uuid.UUID) (*model.Job, error)
+	// Cancel stops a queued job at once; a running one stops at its worker's next heartbeat.
+	Cancel(id uuid.UUID) (*model.Job, error)
+	// Result returns the job if its result can be downloaded, ErrJobNotReady if not.
+	Result(id uuid.UUID) (*model.Job, error)
+	StreamResult(id uuid.UUID, fn func(chunk []byte) error) error
+	// PurgeFinished deletes jobs that finished more than olderThan ago, results included.
+	PurgeFinished(olderThan time.Duration) (int64, error)
+}
+
+type jobService struct {
+	repo repository.JobRepository
+	org  uuid.UUID
+}
+
+func NewJobService(repo repository.JobRepository) JobService {
+	return &jobService{repo: repo, org: model.DefaultOrganizationID}
+}
+
+func (s *jobService) InOrganization(org uuid.UUID) JobService {
+	return &jobService{repo: s.repo, org: model.OrganizationOrDefault(org)}
+}
+
+func (s *jobService) EnqueueUserExport(params model.UserExportParams) (*model.Job, error) {
+	if !slices.Contains(export.MediaTypes, params.Format) {
+		return nil, fmt.Errorf("%w: format must be one of %s", errors.ErrInvalidInput, strings.Join(export.MediaTypes, ", "))
+	}
+	fields, err := model.ParseUserFields(strings.Join(params.Fields, ","))
+	if err != nil {
+		return nil, err
+	}
+	params.Fields = fields
+	params.Query = strings.TrimSpace(params.Query)
+	params.OrganizationID = s.org
+	return s.enqueue(model.JobUserExport, params, nil)
+}
+
+func (s *jobService) EnqueueUserImport(params model.UserImportParams, csvFile []byte) (*model.Job, error) {
+	mode, err := model.ParseImportMode(string(params.Mode))
+	if err != nil {
+		return nil, err
+	}
+	params.Mode = mode
+	params.OrganizationID = s.org
+	return s.enqueue(model.JobUserImport, params, csvFile)
+}
+
+func (s *jobService) enqueue(kind string, params any, input []byte) (*model.Job, error) {
+	raw, err := json.Marshal(params)
+	if err != nil {
+		return nil, err
+	}
+	return s.repo.Create(kind, raw, input)
+}
+
+func (s *jobService) Get(id uuid.UUID) (*model.Job, error) {
+	job, err := s.repo.GetByID(id)
+	if err != nil {
+		return nil, err
+	}
+	if model.JobOrganization(job) != s.org {
+		return nil, errors.ErrJobNotFound
+	}
+	return job, nil
+}
+
+func (s *jobService) Cancel(id uuid.UUID) (*model.Job, error) {
+	if _, err := s.Get(id); err != nil {
+		return nil, err
+	}
+	return s.repo.RequestCancel(id)
+}
+
+func (s *jobService) Result(id uuid.UUID) (*model.Job, error) {
+	job, err := s.Get(id)
+	if err != nil {
+		return nil, err
+	}
+	if job.Status != model.JobSucceeded || job.ResultType == "" {
+		return job, errors.ErrJobNotReady
+	}
+	return job, nil
+}
+
+func (s *jobService) StreamResult(id uuid.UUID, fn func(chunk []byte) error) error {
+	if _, err := s.Get(id); err != nil {
+		return err
+	}
+	return s.repo.StreamResult(id, fn)
+}
+
+func (s *jobService) PurgeFinished(olderThan time.Duration) (int64, error) {
+	if olderThan <= 0 {
+		return 0, fmt.Errorf("%w: retention must be positive", errors.ErrInvalidInput)
+	}
+	return s.repo.PurgeFinished(time.Now().Add(-olderThan))
+}
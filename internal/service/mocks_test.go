@@ -0,0 +1,463 @@
+package service
+
+import (
+	"cruder/internal/model"
+	"cruder/internal/repository"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/mock"
+)
+
+// MockTokenRevocationRepository is a mock implementation of repository.TokenRevocationRepository
+type MockTokenRevocationRepository struct {
+	mock.Mock
+}
+
+func (m *MockTokenRevocationRepository) Revoke(subject string, before time.Time) (*model.TokenRevocation, error) {
+	args := m.Called(subject, before)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).(*model.TokenRevocation), args.Error(1)
+}
+
+func (m *MockTokenRevocationRepository) List() ([]model.TokenRevocation, error) {
+	args := m.Called()
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).([]model.TokenRevocation), args.Error(1)
+}
+
+// MockAuthFailureRepository is a mock implementation of repository.AuthFailureRepository
+type MockAuthFailureRepository struct {
+	mock.Mock
+}
+
+func (m *MockAuthFailureRepository) Get(ip string) (*model.AuthFailure, error) {
+	args := m.Called(ip)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).(*model.AuthFailure), args.Error(1)
+}
+
+func (m *MockAuthFailureRepository) RecordFailure(ip string, at, since time.Time) (*model.AuthFailure, error) {
+	args := m.Called(ip, at, since)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).(*model.AuthFailure), args.Error(1)
+}
+
+func (m *MockAuthFailureRepository) Block(ip string, until time.Time, banned bool) (*model.AuthFailure, error) {
+	args := m.Called(ip, until, banned)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).(*model.AuthFailure), args.Error(1)
+}
+
+func (m *MockAuthFailureRepository) ListBanned(now time.Time) ([]model.AuthFailure, error) {
+	args := m.Called(now)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).([]model.AuthFailure), args.Error(1)
+}
+
+func (m *MockAuthFailureRepository) LiftBan(ip string, now time.Time) error {
+	args := m.Called(ip, now)
+	return args.Error(0)
+}
+
+func (m *MockAuthFailureRepository) PruneExpired(before time.Time) (int64, error) {
+	args := m.Called(before)
+	return args.Get(0).(int64), args.Error(1)
+}
+
+// MockOrganizationRepository is a mock implementation of repository.OrganizationRepository
+type MockOrganizationRepository struct {
+	mock.Mock
+}
+
+func (m *MockOrganizationRepository) Create(req *model.CreateOrganizationRequest) (*model.Organization, error) {
+	args := m.Called(req)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).(*model.Organization), args.Error(1)
+}
+
+func (m *MockOrganizationRepository) GetByID(id uuid.UUID) (*model.Organization, error) {
+	args := m.Called(id)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).(*model.Organization), args.Error(1)
+}
+
+func (m *MockOrganizationRepository) GetBySlug(slug string) (*model.Organization, error) {
+	args := m.Called(slug)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).(*model.Organization), args.Error(1)
+}
+
+func (m *MockOrganizationRepository) List() ([]model.Organization, error) {
+	args := m.Called()
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).([]model.Organization), args.Error(1)
+}
+
+// MockOrganizationUserRepository is a MockUserRepository for the default organization whose
+// InOrganization returns the mock of another organization from Organizations, so tests can tell
+// which organization's storage a service used.
+type MockOrganizationUserRepository struct {
+	MockUserRepository
+	Organizations map[uuid.UUID]*MockUserRepository
+}
+
+func (m *MockOrganizationUserRepository) InOrganization(org uuid.UUID) repository.UserRepository {
+	if scoped, ok := m.Organizations[org]; ok {
+		return scoped
+	}
+	return m
+}
+
+// MockEmailVerificationRepository is a mock implementation of repository.EmailVerificationRepository
+type MockEmailVerificationRepository struct {
+	mock.Mock
+}
+
+func (m *MockEmailVerificationRepository) Create(v *model.EmailVerification) error {
+	args := m.Called(v)
+	return args.Error(0)
+}
+
+func (m *MockEmailVerificationRepository) Consume(tokenHash []byte) (*model.EmailVerification, error) {
+	args := m.Called(tokenHash)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).(*model.EmailVerification), args.Error(1)
+}
+
+func (m *MockEmailVerificationRepository) PruneExpired(before time.Time) (int64, error) {
+	args := m.Called(before)
+	return args.Get(0).(int64), args.Error(1)
+}
+
+// MockEmailChangeRepository is a mock implementation of repository.EmailChangeRepository
+type MockEmailChangeRepository struct {
+	mock.Mock
+}
+
+func (m *MockEmailChangeRepository) Create(c *model.EmailChange) error {
+	args := m.Called(c)
+	return args.Error(0)
+}
+
+func (m *MockEmailChangeRepository) GetPending(userID uuid.UUID) (*model.EmailChange, error) {
+	args := m.Called(userID)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).(*model.EmailChange), args.Error(1)
+}
+
+func (m *MockEmailChangeRepository) IsPending(org uuid.UUID, email string, except uuid.UUID, now time.Time) (bool, error) {
+	args := m.Called(org, email, except, now)
+	return args.Bool(0), args.Error(1)
+}
+
+func (m *MockEmailChangeRepository) GetByConfirmToken(confirmTokenHash []byte) (*model.EmailChange, error) {
+	args := m.Called(confirmTokenHash)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).(*model.EmailChange), args.Error(1)
+}
+
+func (m *MockEmailChangeRepository) MarkConfirmed(id uuid.UUID, at time.Time) error {
+	args := m.Called(id, at)
+	return args.Error(0)
+}
+
+func (m *MockEmailChangeRepository) TakeByRevertToken(revertTokenHash []byte) (*model.EmailChange, error) {
+	args := m.Called(revertTokenHash)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).(*model.EmailChange), args.Error(1)
+}
+
+func (m *MockEmailChangeRepository) Delete(id uuid.UUID) error {
+	args := m.Called(id)
+	return args.Error(0)
+}
+
+func (m *MockEmailChangeRepository) PruneExpired(before time.Time) (int64, error) {
+	args := m.Called(before)
+	return args.Get(0).(int64), args.Error(1)
+}
+
+// MockGroupRepository is a mock implementation of repository.GroupRepository. Its InOrganization
+// returns the mock of another organization from Organizations, or the mock itself.
+type MockGroupRepository struct {
+	mock.Mock
+	Organizations map[uuid.UUID]*MockGroupRepository
+}
+
+func (m *MockGroupRepository) InOrganization(org uuid.UUID) repository.GroupRepository {
+	if scoped, ok := m.Organizations[org]; ok {
+		return scoped
+	}
+	return m
+}
+
+func (m *MockGroupRepository) Create(req *model.CreateGroupRequest) (*model.Group, error) {
+	args := m.Called(req)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).(*model.Group), args.Error(1)
+}
+
+func (m *MockGroupRepository) GetByID(id uuid.UUID) (*model.Group, error) {
+	args := m.Called(id)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).(*model.Group), args.Error(1)
+}
+
+func (m *MockGroupRepository) List() ([]model.Group, error) {
+	args := m.Called()
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).([]model.Group), args.Error(1)
+}
+
+func (m *MockGroupRepository) Update(id uuid.UUID, req *model.UpdateGroupRequest) (*model.Group, error) {
+	args := m.Called(id, req)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).(*model.Group), args.Error(1)
+}
+
+func (m *MockGroupRepository) Delete(id uuid.UUID, actor string, at time.Time) ([]model.GroupMembershipChange, error) {
+	args := m.Called(id, actor, at)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).([]model.GroupMembershipChange), args.Error(1)
+}
+
+func (m *MockGroupRepository) AddMember(change *model.GroupMembershipChange) error {
+	args := m.Called(change)
+	return args.Error(0)
+}
+
+func (m *MockGroupRepository) RemoveMember(change *model.GroupMembershipChange) error {
+	args := m.Called(change)
+	return args.Error(0)
+}
+
+func (m *MockGroupRepository) RemoveUser(userID uuid.UUID, actor string, at time.Time) ([]model.GroupMembershipChange, error) {
+	args := m.Called(userID, actor, at)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).([]model.GroupMembershipChange), args.Error(1)
+}
+
+func (m *MockGroupRepository) Members(id uuid.UUID) ([]model.GroupMember, error) {
+	args := m.Called(id)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).([]model.GroupMember), args.Error(1)
+}
+
+func (m *MockGroupRepository) EffectiveUserIDs(id uuid.UUID) ([]uuid.UUID, error) {
+	args := m.Called(id)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).([]uuid.UUID), args.Error(1)
+}
+
+func (m *MockGroupRepository) GroupsOfUser(userID uuid.UUID, effective bool) ([]model.Group, error) {
+	args := m.Called(userID, effective)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).([]model.Group), args.Error(1)
+}
+
+func (m *MockGroupRepository) History(id uuid.UUID) ([]model.GroupMembershipChange, error) {
+	args := m.Called(id)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).([]model.GroupMembershipChange), args.Error(1)
+}
+
+// MockInvitationRepository is a mock implementation of repository.InvitationRepository. Its
+// InOrganization returns the mock of another organization from Organizations, or the mock itself.
+type MockInvitationRepository struct {
+	mock.Mock
+	Organizations map[uuid.UUID]*MockInvitationRepository
+}
+
+func (m *MockInvitationRepository) InOrganization(org uuid.UUID) repository.InvitationRepository {
+	if scoped, ok := m.Organizations[org]; ok {
+		return scoped
+	}
+	return m
+}
+
+func (m *MockInvitationRepository) Create(inv *model.Invitation) error {
+	args := m.Called(inv)
+	return args.Error(0)
+}
+
+func (m *MockInvitationRepository) GetByID(id uuid.UUID) (*model.Invitation, error) {
+	args := m.Called(id)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).(*model.Invitation), args.Error(1)
+}
+
+func (m *MockInvitationRepository) GetByToken(tokenHash []byte) (*model.Invitation, error) {
+	args := m.Called(tokenHash)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).(*model.Invitation), args.Error(1)
+}
+
+func (m *MockInvitationRepository) ListPending(now time.Time) ([]model.Invitation, error) {
+	args := m.Called(now)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).([]model.Invitation), args.Error(1)
+}
+
+func (m *MockInvitationRepository) Renew(id uuid.UUID, tokenHash []byte, expiresAt, sentAt time.Time) (*model.Invitation, error) {
+	args := m.Called(id, tokenHash, expiresAt, sentAt)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).(*model.Invitation), args.Error(1)
+}
+
+func (m *MockInvitationRepository) Revoke(id uuid.UUID, at time.Time) (*model.Invitation, error) {
+	args := m.Called(id, at)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).(*model.Invitation), args.Error(1)
+}
+
+func (m *MockInvitationRepository) Claim(id uuid.UUID, at time.Time) error {
+	args := m.Called(id, at)
+	return args.Error(0)
+}
+
+func (m *MockInvitationRepository) Release(id uuid.UUID) error {
+	args := m.Called(id)
+	return args.Error(0)
+}
+
+func (m *MockInvitationRepository) SetUser(id, userID uuid.UUID) error {
+	args := m.Called(id, userID)
+	return args.Error(0)
+}
+
+// MockOAuthRepository is a mock implementation of repository.OAuthRepository
+type MockOAuthRepository struct {
+	mock.Mock
+}
+
+func (m *MockOAuthRepository) CreateClient(c *model.OAuthClient) error {
+	args := m.Called(c)
+	return args.Error(0)
+}
+
+func (m *MockOAuthRepository) GetClient(id string) (*model.OAuthClient, error) {
+	args := m.Called(id)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).(*model.OAuthClient), args.Error(1)
+}
+
+func (m *MockOAuthRepository) ListClients() ([]model.OAuthClient, error) {
+	args := m.Called()
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).([]model.OAuthClient), args.Error(1)
+}
+
+func (m *MockOAuthRepository) DeleteClient(id string) error {
+	args := m.Called(id)
+	return args.Error(0)
+}
+
+func (m *MockOAuthRepository) SaveConsent(userID uuid.UUID, clientID, scope string, at time.Time) (*model.OAuthConsent, error) {
+	args := m.Called(userID, clientID, scope, at)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).(*model.OAuthConsent), args.Error(1)
+}
+
+func (m *MockOAuthRepository) GetConsent(userID uuid.UUID, clientID string) (*model.OAuthConsent, error) {
+	args := m.Called(userID, clientID)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).(*model.OAuthConsent), args.Error(1)
+}
+
+func (m *MockOAuthRepository) ListConsents(userID uuid.UUID) ([]model.OAuthConsent, error) {
+	args := m.Called(userID)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).([]model.OAuthConsent), args.Error(1)
+}
+
+func (m *MockOAuthRepository) DeleteConsent(userID uuid.UUID, clientID string) error {
+	args := m.Called(userID, clientID)
+	return args.Error(0)
+}
+
+func (m *MockOAuthRepository) CreateCode(code *model.AuthorizationCode) error {
+	args := m.Called(code)
+	return args.Error(0)
+}
+
+func (m *MockOAuthRepository) TakeCode(codeHash []byte) (*model.AuthorizationCode, error) {
+	args := m.Called(codeHash)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).(*model.AuthorizationCode), args.Error(1)
+}
+
+func (m *MockOAuthRepository) PruneExpiredCodes(before time.Time) (int64, error) {
+	args := m.Called(before)
+	return args.Get(0).(int64), args.Error(1)
+}
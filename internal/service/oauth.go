@@ -0,0 +1,441 @@
+package service
+
+import (
+	"cruder/internal/errors"
+	"cruder/internal/model"
+	"cruder/internal/oidc"
+	"cruder/internal/repository"
+	"cruder/internal/token"
+	"crypto/subtle"
+	"fmt"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+
+	stdErrors "errors"
+)
+
+// Lifetimes used when OAuthOptions leaves them zero
+const (
+	// DefaultAuthorizationCodeTTL follows RFC 6749 §4.1.2: codes are short-lived, at most 10 minutes
+	DefaultAuthorizationCodeTTL = time.Minute
+	// DefaultOIDCTokenTTL is the lifetime of access and ID tokens
+	DefaultOIDCTokenTTL = time.Hour
+)
+
+// maxNonceLength bounds the nonce, which is stored with the code and copied into the ID token.
+const maxNonceLength = 255
+
+// OAuthService is the built-in OpenID Connect provider: it signs the users of this service in to
+// registered client applications with the authorization code flow and PKCE.
+//
+// There is no browser session. Users enter their password at every authorization, and their
+// consent is recorded for the client, where it can be listed and revoked.
+//
+// Clients belong to the organization that registered them and sign in its users only. The
+// endpoints the client application and the user's browser call find the organization from the
+// client; the others work in the organization InOrganization selects, by default
+// model.DefaultOrganizationID.
+type OAuthService interface {
+	// InOrganization returns the service for the clients and users of org.
+	InOrganization(org uuid.UUID) OAuthService
+
+	// RegisterClient registers a client of the organization, generating its ID and, unless it is
+	// public, its secret.
+	RegisterClient(req *model.RegisterOAuthClientRequest) (*model.RegisteredOAuthClient, error)
+	ListClients() ([]model.OAuthClient, error)
+	// GetClient returns ErrOAuthClientNotFound for the clients of other organizations too.
+	GetClient(id string) (*model.OAuthClient, error)
+	// DeleteClient deletes the client with its consents and pending codes.
+	DeleteClient(id string) error
+
+	// CheckAuthorization validates an authorization request and returns its client and the scopes
+	// it would be granted. Failures are *oidc.Error; only those with Redirect set may be sent to the
+	// redirect URI, the others are about the client or the redirect URI itself.
+	CheckAuthorization(req *model.AuthorizationRequest) (*model.OAuthClient, []string, error)
+	// Authorize signs the user in with login and password, records their consent to the request's
+	// scopes and returns an authorization code for the client. A wrong login or password returns
+	// ErrInvalidCredentials, and a user who is not active ErrUserNotActive.
+	Authorize(req *model.AuthorizationRequest, login, password string) (string, error)
+	// Exchange redeems an authorization code for an access token and an ID token. Failures are
+	// *oidc.Error, unless the store fails.
+	Exchange(req *model.TokenRequest) (*model.OIDCToken, error)
+	// UserInfo returns the claims an access token grants about its user. Tokens that are invalid,
+	// expired, whose consent was revoked or whose user is no longer active fail with an *oidc.Error.
+	UserInfo(accessToken string) (*model.UserInfo, error)
+
+	// Consents returns the clients the user allowed access, or ErrUserNotFound.
+	Consents(userID uuid.UUID) ([]model.OAuthConsent, error)
+	// RevokeConsent withdraws the user's consent to the client: its access tokens stop working at
+	// the userinfo endpoint, and its pending codes for the user can no longer be exchanged.
+	RevokeConsent(userID uuid.UUID, clientID string) error
+	// PruneExpiredCodes deletes expired authorization codes.
+	PruneExpiredCodes() (int64, error)
+	// Discovery returns the provider metadata.
+	Discovery() *oidc.Discovery
+}
+
+// OAuthOptions configure the provider.
+type OAuthOptions struct {
+	// Issuer is the provider's public base URL, the iss of its tokens
+	Issuer   string
+	CodeTTL  time.Duration
+	TokenTTL time.Duration
+}
+
+type oauthService struct {
+	repo      repository.OAuthRepository
+	users     UserService
+	passwords AuthService
+	keys      *token.KeySet
+	opts      OAuthOptions
+	now       func() time.Time
+	org       uuid.UUID
+}
+
+// NewOAuthService returns the provider. It signs with keys, the same keys as the API access
+// tokens, so the one JWKS serves both.
+func NewOAuthService(repo repository.OAuthRepository, users UserService, passwords AuthService, keys *token.KeySet, opts OAuthOptions) OAuthService {
+	opts.Issuer = strings.TrimSuffix(opts.Issuer, "/")
+	if opts.CodeTTL == 0 {
+		opts.CodeTTL = DefaultAuthorizationCodeTTL
+	}
+	if opts.TokenTTL == 0 {
+		opts.TokenTTL = DefaultOIDCTokenTTL
+	}
+	return &oauthService{repo: repo, users: users, passwords: passwords, keys: keys, opts: opts, now: time.Now,
+		org: model.DefaultOrganizationID}
+}
+
+func (s *oauthService) InOrganization(org uuid.UUID) OAuthService {
+	scoped := *s
+	scoped.org = model.OrganizationOrDefault(org)
+	scoped.users = s.users.InOrganization(org)
+	scoped.passwords = s.passwords.InOrganization(org)
+	return &scoped
+}
+
+// forClient returns the service for the organization of client, whose users it signs in.
+func (s *oauthService) forClient(client *model.OAuthClient) *oauthService {
+	return s.InOrganization(client.OrganizationID).(*oauthService)
+}
+
+func (s *oauthService) RegisterClient(req *model.RegisterOAuthClientRequest) (*model.RegisteredOAuthClient, error) {
+	name := strings.TrimSpace(req.Name)
+	if name == "" {
+		return nil, fmt.Errorf("%w: name is required", errors.ErrInvalidInput)
+	}
+	for _, uri := range req.RedirectURIs {
+		if err := oidc.ValidateRedirectURI(uri); err != nil {
+			return nil, fmt.Errorf("%w: %v", errors.ErrInvalidInput, err)
+		}
+	}
+
+	registered := &model.RegisteredOAuthClient{OAuthClient: model.OAuthClient{
+		ID:             uuid.NewString(),
+		Name:           name,
+		OrganizationID: s.org,
+		Public:         req.Public,
+		RedirectURIs:   slices.Compact(slices.Clone(req.RedirectURIs)),
+	}}
+	if !req.Public {
+		// Secrets are made and hashed like the tokens sent by email: random enough that a plain hash is safe
+		secret, hash, err := newMailToken()
+		if err != nil {
+			return nil, err
+		}
+		registered.ClientSecret = secret
+		registered.SecretHash = hash
+	}
+	if err := s.repo.CreateClient(&registered.OAuthClient); err != nil {
+		return nil, err
+	}
+	return registered, nil
+}
+
+func (s *oauthService) ListClients() ([]model.OAuthClient, error) {
+	clients, err := s.repo.ListClients()
+	if err != nil {
+		return nil, err
+	}
+	return slices.DeleteFunc(clients, func(c model.OAuthClient) bool { return c.OrganizationID != s.org }), nil
+}
+
+func (s *oauthService) GetClient(id string) (*model.OAuthClient, error) {
+	client, err := s.repo.GetClient(id)
+	if err != nil {
+		return nil, err
+	}
+	if client.OrganizationID != s.org {
+		return nil, errors.ErrOAuthClientNotFound
+	}
+	return client, nil
+}
+
+func (s *oauthService) DeleteClient(id string) error {
+	if _, err := s.GetClient(id); err != nil {
+		return err
+	}
+	return s.repo.DeleteClient(id)
+}
+
+func (s *oauthService) CheckAuthorization(req *model.AuthorizationRequest) (*model.OAuthClient, []string, error) {
+	if req.ClientID == "" {
+		return nil, nil, oidc.Errorf(oidc.InvalidRequest, "client_id is required")
+	}
+	client, err := s.repo.GetClient(req.ClientID)
+	if stdErrors.Is(err, errors.ErrOAuthClientNotFound) {
+		return nil, nil, oidc.Errorf(oidc.InvalidRequest, "unknown client_id")
+	}
+	if err != nil {
+		return nil, nil, err
+	}
+	if !slices.Contains(client.RedirectURIs, req.RedirectURI) {
+		return nil, nil, oidc.Errorf(oidc.InvalidRequest, "redirect_uri is not registered for this client")
+	}
+
+	// From here on the redirect URI is trusted, and errors go back to the client
+	fail := func(code, description string) (*model.OAuthClient, []string, error) {
+		return nil, nil, &oidc.Error{Code: code, Description: description, Redirect: true}
+	}
+	if req.ResponseType != oidc.ResponseTypeCode {
+		return fail(oidc.UnsupportedResponseType, "only response_type=code is supported")
+	}
+	scopes, err := oidc.ParseScope(req.Scope)
+	if err != nil {
+		return fail(oidc.InvalidScope, err.(*oidc.Error).Description)
+	}
+	switch {
+	case req.CodeChallenge == "":
+		return fail(oidc.InvalidRequest, "code_challenge is required: every client must use PKCE")
+	case req.CodeChallengeMethod != oidc.ChallengeS256:
+		return fail(oidc.InvalidRequest, "code_challenge_method must be S256")
+	case !oidc.ValidCodeChallenge(req.CodeChallenge):
+		return fail(oidc.InvalidRequest, "code_challenge is not a base64url-encoded SHA-256 hash")
+	case len(req.Nonce) > maxNonceLength:
+		return fail(oidc.InvalidRequest, fmt.Sprintf("nonce must be at most %d characters", maxNonceLength))
+	case slices.Contains(strings.Fields(req.Prompt), oidc.PromptNone):
+		// Without sessions the user can never be signed in silently
+		return fail(oidc.LoginRequired, "the user must sign in")
+	}
+	return client, scopes, nil
+}
+
+func (s *oauthService) Authorize(req *model.AuthorizationRequest, login, password string) (string, error) {
+	client, scopes, err := s.CheckAuthorization(req)
+	if err != nil {
+		return "", err
+	}
+	user, err := s.forClient(client).passwords.Login(login, password)
+	if err != nil {
+		return "", err
+	}
+
+	now := s.now()
+	consented := scopes
+	consent, err := s.repo.GetConsent(user.ID, client.ID)
+	switch {
+	case err == nil:
+		for _, sc := range strings.Fields(consent.Scope) {
+			if !slices.Contains(consented, sc) {
+				consented = append(consented, sc)
+			}
+		}
+		consented, _ = oidc.ParseScope(strings.Join(consented, " "))
+	case !stdErrors.Is(err, errors.ErrConsentNotFound):
+		return "", err
+	}
+	if _, err := s.repo.SaveConsent(user.ID, client.ID, strings.Join(consented, " "), now); err != nil {
+		return "", err
+	}
+
+	code, hash, err := newMailToken()
+	if err != nil {
+		return "", err
+	}
+	err = s.repo.CreateCode(&model.AuthorizationCode{
+		CodeHash:      hash,
+		ClientID:      client.ID,
+		UserID:        user.ID,
+		RedirectURI:   req.RedirectURI,
+		Scope:         strings.Join(scopes, " "),
+		Nonce:         req.Nonce,
+		CodeChallenge: req.CodeChallenge,
+		AuthTime:      now,
+		ExpiresAt:     now.Add(s.opts.CodeTTL),
+	})
+	if err != nil {
+		return "", err
+	}
+	return code, nil
+}
+
+// authenticateClient checks the client credentials of a token request.
+func (s *oauthService) authenticateClient(clientID, secret string) (*model.OAuthClient, error) {
+	if clientID == "" {
+		return nil, oidc.Errorf(oidc.InvalidClient, "client authentication is required")
+	}
+	client, err := s.repo.GetClient(clientID)
+	if stdErrors.Is(err, errors.ErrOAuthClientNotFound) {
+		return nil, oidc.Errorf(oidc.InvalidClient, "unknown client")
+	}
+	if err != nil {
+		return nil, err
+	}
+	if client.Public {
+		return client, nil
+	}
+	if secret == "" || subtle.ConstantTimeCompare(hashMailToken(secret), client.SecretHash) != 1 {
+		return nil, oidc.Errorf(oidc.InvalidClient, "wrong client secret")
+	}
+	return client, nil
+}
+
+func (s *oauthService) Exchange(req *model.TokenRequest) (*model.OIDCToken, error) {
+	if req.GrantType != oidc.GrantTypeCode {
+		return nil, oidc.Errorf(oidc.UnsupportedGrantType, "only grant_type=authorization_code is supported")
+	}
+	client, err := s.authenticateClient(req.ClientID, req.ClientSecret)
+	if err != nil {
+		return nil, err
+	}
+	if req.Code == "" || req.CodeVerifier == "" {
+		return nil, oidc.Errorf(oidc.InvalidRequest, "code and code_verifier are required")
+	}
+
+	// Taking the code makes it single-use, even when the rest of the request turns out wrong
+	code, err := s.repo.TakeCode(hashMailToken(req.Code))
+	if stdErrors.Is(err, errors.ErrAuthorizationCodeInvalid) {
+		return nil, oidc.Errorf(oidc.InvalidGrant, "unknown or already used code")
+	}
+	if err != nil {
+		return nil, err
+	}
+	now := s.now()
+	switch {
+	case code.ClientID != client.ID:
+		return nil, oidc.Errorf(oidc.InvalidGrant, "code was issued to another client")
+	case !now.Before(code.ExpiresAt):
+		return nil, oidc.Errorf(oidc.InvalidGrant, "code has expired")
+	case req.RedirectURI != code.RedirectURI:
+		return nil, oidc.Errorf(oidc.InvalidGrant, "redirect_uri does not match the authorization request")
+	case !oidc.VerifyPKCE(req.CodeVerifier, code.CodeChallenge):
+		return nil, oidc.Errorf(oidc.InvalidGrant, "code_verifier does not match code_challenge")
+	}
+
+	if _, err := s.repo.GetConsent(code.UserID, client.ID); err != nil {
+		if stdErrors.Is(err, errors.ErrConsentNotFound) {
+			return nil, oidc.Errorf(oidc.InvalidGrant, "the user revoked their consent")
+		}
+		return nil, err
+	}
+	user, err := s.forClient(client).users.GetByID(code.UserID, nil)
+	if stdErrors.Is(err, errors.ErrUserNotFound) {
+		return nil, oidc.Errorf(oidc.InvalidGrant, "the user no longer exists")
+	}
+	if err != nil {
+		return nil, err
+	}
+	if user.Status != model.UserActive {
+		return nil, oidc.Errorf(oidc.InvalidGrant, "the user is %s", user.Status)
+	}
+
+	scopes := strings.Fields(code.Scope)
+	expires := now.Add(s.opts.TokenTTL)
+	accessToken, err := s.keys.Sign(token.Claims{
+		Issuer:    s.opts.Issuer,
+		Subject:   user.ID.String(),
+		Audience:  client.ID,
+		Scope:     code.Scope,
+		IssuedAt:  now.Unix(),
+		ExpiresAt: expires.Unix(),
+		ID:        uuid.NewString(),
+	})
+	if err != nil {
+		return nil, err
+	}
+	idToken, err := s.keys.SignJSON(oidc.IDToken{
+		Issuer:    s.opts.Issuer,
+		Audience:  client.ID,
+		ExpiresAt: expires.Unix(),
+		IssuedAt:  now.Unix(),
+		AuthTime:  code.AuthTime.Unix(),
+		Nonce:     code.Nonce,
+		UserInfo:  oidc.UserClaims(user, scopes),
+	})
+	if err != nil {
+		return nil, err
+	}
+	return &model.OIDCToken{
+		AccessToken: accessToken,
+		TokenType:   oidc.TokenTypeBearer,
+		ExpiresIn:   int64(s.opts.TokenTTL / time.Second),
+		Scope:       code.Scope,
+		IDToken:     idToken,
+	}, nil
+}
+
+func (s *oauthService) UserInfo(accessToken string) (*model.UserInfo, error) {
+	claims, err := s.keys.Verify(accessToken, s.now())
+	if err != nil {
+		return nil, oidc.Errorf(oidc.InvalidToken, "%v", err)
+	}
+	// API clients' tokens have no audience; they say nothing about a user
+	userID, err := uuid.Parse(claims.Subject)
+	if claims.Issuer != s.opts.Issuer || claims.Audience == "" || err != nil {
+		return nil, oidc.Errorf(oidc.InvalidToken, "not an access token for a user")
+	}
+
+	if _, err := s.repo.GetConsent(userID, claims.Audience); err != nil {
+		if stdErrors.Is(err, errors.ErrConsentNotFound) {
+			return nil, oidc.Errorf(oidc.InvalidToken, "the user revoked their consent")
+		}
+		return nil, err
+	}
+	client, err := s.repo.GetClient(claims.Audience)
+	if stdErrors.Is(err, errors.ErrOAuthClientNotFound) {
+		return nil, oidc.Errorf(oidc.InvalidToken, "the client no longer exists")
+	}
+	if err != nil {
+		return nil, err
+	}
+	user, err := s.forClient(client).users.GetByID(userID, nil)
+	if stdErrors.Is(err, errors.ErrUserNotFound) {
+		return nil, oidc.Errorf(oidc.InvalidToken, "the user no longer exists")
+	}
+	if err != nil {
+		return nil, err
+	}
+	if user.Status != model.UserActive {
+		return nil, oidc.Errorf(oidc.InvalidToken, "the user is %s", user.Status)
+	}
+	info := oidc.UserClaims(user, claims.Scopes())
+	return &info, nil
+}
+
+func (s *oauthService) Consents(userID uuid.UUID) ([]model.OAuthConsent, error) {
+	if _, err := s.users.GetByID(userID, model.UserFields{"id"}); err != nil {
+		return nil, err
+	}
+	return s.repo.ListConsents(userID)
+}
+
+func (s *oauthService) RevokeConsent(userID uuid.UUID, clientID string) error {
+	if _, err := s.users.GetByID(userID, model.UserFields{"id"}); err != nil {
+		if stdErrors.Is(err, errors.ErrUserNotFound) {
+			return errors.ErrConsentNotFound
+		}
+		return err
+	}
+	return s.repo.DeleteConsent(userID, clientID)
+}
+
+func (s *oauthService) PruneExpiredCodes() (int64, error) {
+	return s.repo.PruneExpiredCodes(s.now())
+}
+
+func (s *oauthService) Discovery() *oidc.Discovery {
+	return oidc.NewDiscovery(s.opts.Issuer)
+}
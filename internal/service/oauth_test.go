@@ -0,0 +1,415 @@
+package service
+
+import (
+	"cruder/internal/errors"
+	"cruder/internal/model"
+	"cruder/internal/oidc"
+	"cruder/internal/password"
+	"cruder/internal/repository"
+	"cruder/internal/token"
+	"crypto/sha256"
+	"encoding/base64"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+)
+
+const (
+	testRedirectURI = "https://app.example.com/callback"
+	testVerifier    = "dBjftJeZ4CVP-mB92K27uhbUJU1p1r_wW1gFWFOEjXk"
+)
+
+var oauthTestTime = time.Date(2026, 3, 4, 8, 0, 0, 0, time.UTC)
+
+// wiki is a confidential client of the default organization whose secret is "wiki-secret".
+var wiki = model.OAuthClient{ID: "wiki", Name: "Wiki", OrganizationID: model.DefaultOrganizationID,
+	RedirectURIs: []string{testRedirectURI}, SecretHash: hashMailToken("wiki-secret")}
+
+// newTestOAuthService returns the provider over repo and users, signing in with testUser's
+// password "violet-tangerine-42", whose clock reads *now. It knows the wiki client.
+func newTestOAuthService(t *testing.T, repo *MockOAuthRepository, users repository.UserRepository, now *time.Time) *oauthService {
+	t.Helper()
+	keys, err := token.GenerateKeySet()
+	require.NoError(t, err)
+	auth := NewAuthService(users, password.NewHasher(cheapHashing))
+	svc := NewOAuthService(repo, NewUserService(users), auth, keys, OAuthOptions{Issuer: "https://id.example.com/"}).(*oauthService)
+	svc.now = func() time.Time { return *now }
+	repo.On("GetClient", wiki.ID).Return(&wiki, nil)
+	return svc
+}
+
+// signingIn lets testUser, in status, sign in to users with "violet-tangerine-42".
+func signingIn(t *testing.T, users *MockUserRepository, status model.UserStatus) {
+	t.Helper()
+	users.On("GetCredentials", "jdoe").Return(credentials(t, password.NewHasher(cheapHashing), "violet-tangerine-42", status), nil)
+}
+
+func authorizationRequest() *model.AuthorizationRequest {
+	sum := sha256.Sum256([]byte(testVerifier))
+	return &model.AuthorizationRequest{
+		ResponseType:        oidc.ResponseTypeCode,
+		ClientID:            wiki.ID,
+		RedirectURI:         testRedirectURI,
+		Scope:               "openid email",
+		Nonce:               "n-0S6",
+		CodeChallenge:       base64.RawURLEncoding.EncodeToString(sum[:]),
+		CodeChallengeMethod: oidc.ChallengeS256,
+	}
+}
+
+// issuedCode is the stored code that authorizationRequest got for testUser at now, as "the-code".
+func issuedCode(now time.Time) *model.AuthorizationCode {
+	req := authorizationRequest()
+	return &model.AuthorizationCode{CodeHash: hashMailToken("the-code"), ClientID: wiki.ID, UserID: testUser.ID, RedirectURI: testRedirectURI,
+		Scope: req.Scope, Nonce: req.Nonce, CodeChallenge: req.CodeChallenge, AuthTime: now, ExpiresAt: now.Add(DefaultAuthorizationCodeTTL)}
+}
+
+func tokenRequest() *model.TokenRequest {
+	return &model.TokenRequest{
+		GrantType:    oidc.GrantTypeCode,
+		Code:         "the-code",
+		RedirectURI:  testRedirectURI,
+		ClientID:     wiki.ID,
+		ClientSecret: "wiki-secret",
+		CodeVerifier: testVerifier,
+	}
+}
+
+var wikiConsent = &model.OAuthConsent{UserID: testUser.ID, ClientID: wiki.ID, Scope: "openid email"}
+
+func oauthCode(t *testing.T, err error) string {
+	t.Helper()
+	var oe *oidc.Error
+	require.ErrorAs(t, err, &oe)
+	return oe.Code
+}
+
+func TestOAuthAuthorize(t *testing.T) {
+	// Given: A user who has not used the client before
+	mockRepo := new(MockOAuthRepository)
+	users := new(MockUserRepository)
+	now := oauthTestTime
+	svc := newTestOAuthService(t, mockRepo, users, &now)
+	signingIn(t, users, model.UserActive)
+	mockRepo.On("GetConsent", testUser.ID, wiki.ID).Return(nil, errors.ErrConsentNotFound)
+	mockRepo.On("SaveConsent", testUser.ID, wiki.ID, "openid email", now).Return(wikiConsent, nil)
+	var stored *model.AuthorizationCode
+	mockRepo.On("CreateCode", mock.Anything).Run(func(args mock.Arguments) {
+		stored = args.Get(0).(*model.AuthorizationCode)
+	}).Return(nil)
+
+	// When: They sign in to authorize it
+	code, err := svc.Authorize(authorizationRequest(), "jdoe", "violet-tangerine-42")
+
+	// Then: Their consent is recorded, and only the hash of the code is stored, with the request
+	require.NoError(t, err)
+	require.NotNil(t, stored)
+	want := issuedCode(now)
+	want.CodeHash = hashMailToken(code)
+	assert.Equal(t, want, stored)
+	mockRepo.AssertExpectations(t)
+}
+
+func TestOAuthAuthorize_WrongPassword(t *testing.T) {
+	mockRepo := new(MockOAuthRepository)
+	users := new(MockUserRepository)
+	now := oauthTestTime
+	svc := newTestOAuthService(t, mockRepo, users, &now)
+	signingIn(t, users, model.UserActive)
+
+	_, err := svc.Authorize(authorizationRequest(), "jdoe", "violet-tangerine-43")
+
+	assert.ErrorIs(t, err, errors.ErrInvalidCredentials)
+	mockRepo.AssertNotCalled(t, "SaveConsent", mock.Anything, mock.Anything, mock.Anything, mock.Anything)
+	mockRepo.AssertNotCalled(t, "CreateCode", mock.Anything)
+}
+
+func TestOAuthAuthorize_MergesConsent(t *testing.T) {
+	// Given: A user who allowed the client their email before
+	mockRepo := new(MockOAuthRepository)
+	users := new(MockUserRepository)
+	now := oauthTestTime
+	svc := newTestOAuthService(t, mockRepo, users, &now)
+	signingIn(t, users, model.UserActive)
+	mockRepo.On("GetConsent", testUser.ID, wiki.ID).Return(wikiConsent, nil)
+	mockRepo.On("SaveConsent", testUser.ID, wiki.ID, "openid profile email", now).Return(wikiConsent, nil)
+	mockRepo.On("CreateCode", mock.MatchedBy(func(code *model.AuthorizationCode) bool { return code.Scope == "openid profile" })).Return(nil)
+
+	// When: It asks for their profile
+	req := authorizationRequest()
+	req.Scope = "openid profile"
+	_, err := svc.Authorize(req, "jdoe", "violet-tangerine-42")
+
+	// Then: The consent covers both, while the code carries what was asked for
+	require.NoError(t, err)
+	mockRepo.AssertExpectations(t)
+}
+
+func TestOAuthExchange(t *testing.T) {
+	// Given: A code issued to the client
+	mockRepo := new(MockOAuthRepository)
+	users := new(MockUserRepository)
+	now := oauthTestTime
+	svc := newTestOAuthService(t, mockRepo, users, &now)
+	mockRepo.On("TakeCode", hashMailToken("the-code")).Return(issuedCode(now), nil)
+	mockRepo.On("GetConsent", testUser.ID, wiki.ID).Return(wikiConsent, nil)
+	users.On("GetByID", testUser.ID, model.UserFields(nil)).Return(&testUser, nil)
+
+	// When: The client exchanges it, and uses the access token
+	tok, err := svc.Exchange(tokenRequest())
+	require.NoError(t, err)
+	info, err := svc.UserInfo(tok.AccessToken)
+	require.NoError(t, err)
+
+	// Then: The tokens carry the scopes of the code
+	assert.Equal(t, "openid email", tok.Scope)
+	assert.Equal(t, int64(3600), tok.ExpiresIn)
+	assert.NotEmpty(t, tok.IDToken)
+	assert.Equal(t, testUser.ID.String(), info.Subject)
+	assert.Equal(t, "john.doe@example.com", info.Email)
+	assert.Empty(t, info.PreferredUsername, "profile was not granted")
+}
+
+func TestOAuthExchange_CodeWorksOnce(t *testing.T) {
+	mockRepo := new(MockOAuthRepository)
+	now := oauthTestTime
+	svc := newTestOAuthService(t, mockRepo, new(MockUserRepository), &now)
+	mockRepo.On("TakeCode", hashMailToken("the-code")).Return(nil, errors.ErrAuthorizationCodeInvalid)
+
+	_, err := svc.Exchange(tokenRequest())
+
+	assert.Equal(t, oidc.InvalidGrant, oauthCode(t, err))
+}
+
+func TestOAuthExchange_CodeMismatch(t *testing.T) {
+	// Each request is wrong in one way; taking the code used it up all the same
+	for name, tc := range map[string]struct {
+		change func(*model.TokenRequest)
+		later  time.Duration
+	}{
+		"pkce mismatch":          {func(r *model.TokenRequest) { r.CodeVerifier = strings.Repeat("a", 43) }, 0},
+		"redirect uri mismatch":  {func(r *model.TokenRequest) { r.RedirectURI = "https://app.example.com/other" }, 0},
+		"expired at the instant": {func(*model.TokenRequest) {}, DefaultAuthorizationCodeTTL},
+	} {
+		mockRepo := new(MockOAuthRepository)
+		users := new(MockUserRepository)
+		now := oauthTestTime
+		svc := newTestOAuthService(t, mockRepo, users, &now)
+		mockRepo.On("TakeCode", hashMailToken("the-code")).Return(issuedCode(now), nil).Once()
+
+		now = now.Add(tc.later)
+		req := tokenRequest()
+		tc.change(req)
+		_, err := svc.Exchange(req)
+
+		assert.Equal(t, oidc.InvalidGrant, oauthCode(t, err), name)
+		mockRepo.AssertExpectations(t)
+		users.AssertNotCalled(t, "GetByID", mock.Anything, mock.Anything)
+	}
+}
+
+func TestOAuthExchange_ClientAuthentication(t *testing.T) {
+	mockRepo := new(MockOAuthRepository)
+	now := oauthTestTime
+	svc := newTestOAuthService(t, mockRepo, new(MockUserRepository), &now)
+	spa := &model.OAuthClient{ID: "spa", OrganizationID: model.DefaultOrganizationID, Public: true, RedirectURIs: []string{testRedirectURI}}
+	mockRepo.On("GetClient", "spa").Return(spa, nil)
+	mockRepo.On("GetClient", "nope").Return(nil, errors.ErrOAuthClientNotFound)
+	mockRepo.On("TakeCode", hashMailToken("the-code")).Return(issuedCode(now), nil).Once()
+
+	req := tokenRequest()
+	req.ClientSecret = "guess"
+	_, err := svc.Exchange(req)
+	assert.Equal(t, oidc.InvalidClient, oauthCode(t, err))
+	req.ClientSecret = ""
+	_, err = svc.Exchange(req)
+	assert.Equal(t, oidc.InvalidClient, oauthCode(t, err), "confidential clients need their secret")
+	req.ClientID = "nope"
+	_, err = svc.Exchange(req)
+	assert.Equal(t, oidc.InvalidClient, oauthCode(t, err))
+	mockRepo.AssertNotCalled(t, "TakeCode", mock.Anything)
+
+	// A public client sends its ID alone, and cannot redeem another client's code
+	req.ClientID = spa.ID
+	_, err = svc.Exchange(req)
+	assert.Equal(t, oidc.InvalidGrant, oauthCode(t, err))
+	mockRepo.AssertExpectations(t)
+}
+
+func TestOAuthCheckAuthorization(t *testing.T) {
+	mockRepo := new(MockOAuthRepository)
+	now := oauthTestTime
+	svc := newTestOAuthService(t, mockRepo, new(MockUserRepository), &now)
+	mockRepo.On("GetClient", "nope").Return(nil, errors.ErrOAuthClientNotFound)
+
+	client, scopes, err := svc.CheckAuthorization(authorizationRequest())
+	require.NoError(t, err)
+	assert.Equal(t, &wiki, client)
+	assert.Equal(t, []string{oidc.ScopeOpenID, oidc.ScopeEmail}, scopes)
+
+	for name, tc := range map[string]struct {
+		change   func(*model.AuthorizationRequest)
+		code     string
+		redirect bool
+	}{
+		"unknown client":       {func(r *model.AuthorizationRequest) { r.ClientID = "nope" }, oidc.InvalidRequest, false},
+		"unregistered uri":     {func(r *model.AuthorizationRequest) { r.RedirectURI = "https://evil.example.com/" }, oidc.InvalidRequest, false},
+		"implicit flow":        {func(r *model.AuthorizationRequest) { r.ResponseType = "token" }, oidc.UnsupportedResponseType, true},
+		"no openid":            {func(r *model.AuthorizationRequest) { r.Scope = "email" }, oidc.InvalidScope, true},
+		"no pkce":              {func(r *model.AuthorizationRequest) { r.CodeChallenge = "" }, oidc.InvalidRequest, true},
+		"plain pkce":           {func(r *model.AuthorizationRequest) { r.CodeChallengeMethod = "plain" }, oidc.InvalidRequest, true},
+		"silent authorization": {func(r *model.AuthorizationRequest) { r.Prompt = "none" }, oidc.LoginRequired, true},
+	} {
+		req := authorizationRequest()
+		tc.change(req)
+		_, _, err := svc.CheckAuthorization(req)
+		var oe *oidc.Error
+		require.ErrorAs(t, err, &oe, name)
+		assert.Equal(t, tc.code, oe.Code, name)
+		assert.Equal(t, tc.redirect, oe.Redirect, name)
+	}
+}
+
+func TestOAuthRevokeConsent(t *testing.T) {
+	// Given: A token and a code issued before the user revokes their consent
+	mockRepo := new(MockOAuthRepository)
+	users := new(MockUserRepository)
+	now := oauthTestTime
+	svc := newTestOAuthService(t, mockRepo, users, &now)
+	users.On("GetByID", testUser.ID, model.UserFields{"id"}).Return(&model.User{ID: testUser.ID}, nil)
+	users.On("GetByID", testUser.ID, model.UserFields(nil)).Return(&testUser, nil)
+	mockRepo.On("TakeCode", hashMailToken("the-code")).Return(issuedCode(now), nil)
+	mockRepo.On("GetConsent", testUser.ID, wiki.ID).Return(wikiConsent, nil).Once()
+	tok, err := svc.Exchange(tokenRequest())
+	require.NoError(t, err)
+	mockRepo.On("DeleteConsent", testUser.ID, wiki.ID).Return(nil).Once()
+	mockRepo.On("DeleteConsent", testUser.ID, wiki.ID).Return(errors.ErrConsentNotFound)
+	mockRepo.On("GetConsent", testUser.ID, wiki.ID).Return(nil, errors.ErrConsentNotFound)
+
+	// When: Revoking it
+	require.NoError(t, svc.RevokeConsent(testUser.ID, wiki.ID))
+	assert.ErrorIs(t, svc.RevokeConsent(testUser.ID, wiki.ID), errors.ErrConsentNotFound)
+
+	// Then: Neither works any more
+	_, err = svc.UserInfo(tok.AccessToken)
+	assert.Equal(t, oidc.InvalidToken, oauthCode(t, err))
+	_, err = svc.Exchange(tokenRequest())
+	assert.Equal(t, oidc.InvalidGrant, oauthCode(t, err))
+}
+
+func TestOAuthUserInfo_RejectsAPITokens(t *testing.T) {
+	mockRepo := new(MockOAuthRepository)
+	now := oauthTestTime
+	svc := newTestOAuthService(t, mockRepo, new(MockUserRepository), &now)
+
+	apiToken, err := svc.keys.Sign(token.Claims{
+		Issuer: svc.opts.Issuer, Subject: testUser.ID.String(), Scope: model.ScopeAdmin,
+		IssuedAt: now.Unix(), ExpiresAt: now.Add(time.Minute).Unix(),
+	})
+	require.NoError(t, err)
+	_, err = svc.UserInfo(apiToken)
+	assert.Equal(t, oidc.InvalidToken, oauthCode(t, err))
+	mockRepo.AssertNotCalled(t, "GetConsent", mock.Anything, mock.Anything)
+}
+
+func TestOAuthClients_ScopedToOrganization(t *testing.T) {
+	// Given: A client of another organization, whose users are stored apart
+	acmeID := uuid.New()
+	acmeUsers := new(MockUserRepository)
+	users := &MockOrganizationUserRepository{Organizations: map[uuid.UUID]*MockUserRepository{acmeID: acmeUsers}}
+	mockRepo := new(MockOAuthRepository)
+	now := oauthTestTime
+	svc := newTestOAuthService(t, mockRepo, users, &now)
+	theirs := model.OAuthClient{ID: "acme-wiki", OrganizationID: acmeID, Public: true, RedirectURIs: []string{testRedirectURI}}
+	mockRepo.On("GetClient", theirs.ID).Return(&theirs, nil)
+	mockRepo.On("ListClients").Return([]model.OAuthClient{wiki, theirs}, nil)
+	acmeUsers.On("GetCredentials", "jdoe").Return(nil, errors.ErrUserNotFound)
+	acmeUsers.On("GetByID", testUser.ID, model.UserFields{"id"}).Return(nil, errors.ErrUserNotFound)
+
+	// When: Managing it from the default organization
+	_, err := svc.GetClient(theirs.ID)
+	assert.ErrorIs(t, err, errors.ErrOAuthClientNotFound)
+	assert.ErrorIs(t, svc.DeleteClient(theirs.ID), errors.ErrOAuthClientNotFound)
+	clients, err := svc.ListClients()
+	require.NoError(t, err)
+	assert.Equal(t, []model.OAuthClient{wiki}, clients)
+	mockRepo.AssertNotCalled(t, "DeleteClient", mock.Anything)
+
+	// Then: Its sign-in page only knows the users of its organization
+	req := authorizationRequest()
+	req.ClientID = theirs.ID
+	_, err = svc.Authorize(req, "jdoe", "violet-tangerine-42")
+	assert.ErrorIs(t, err, errors.ErrInvalidCredentials)
+	assert.ErrorIs(t, svc.InOrganization(acmeID).RevokeConsent(testUser.ID, wiki.ID), errors.ErrConsentNotFound)
+	acmeUsers.AssertExpectations(t)
+	users.AssertNotCalled(t, "GetCredentials", mock.Anything)
+}
+
+func TestOAuthRegisterClient(t *testing.T) {
+	// Given: A repository that stores clients
+	mockRepo := new(MockOAuthRepository)
+	now := oauthTestTime
+	svc := newTestOAuthService(t, mockRepo, new(MockUserRepository), &now)
+	var stored *model.OAuthClient
+	mockRepo.On("CreateClient", mock.Anything).Run(func(args mock.Arguments) {
+		stored = args.Get(0).(*model.OAuthClient)
+	}).Return(nil)
+
+	// When: Registering a confidential client
+	registered, err := svc.RegisterClient(&model.RegisterOAuthClientRequest{Name: " Wiki ", RedirectURIs: []string{testRedirectURI, testRedirectURI}})
+
+	// Then: Its secret is shown once, and only its hash stored
+	require.NoError(t, err)
+	require.NotNil(t, stored)
+	assert.Equal(t, "Wiki", stored.Name)
+	assert.Equal(t, []string{testRedirectURI}, stored.RedirectURIs)
+	assert.NotEmpty(t, registered.ClientSecret)
+	assert.Equal(t, hashMailToken(registered.ClientSecret), stored.SecretHash)
+}
+
+func TestOAuthRegisterClient_ValidatesRedirectURIs(t *testing.T) {
+	mockRepo := new(MockOAuthRepository)
+	now := oauthTestTime
+	svc := newTestOAuthService(t, mockRepo, new(MockUserRepository), &now)
+	mockRepo.On("CreateClient", mock.Anything).Return(nil)
+
+	for _, uri := range []string{"/relative", "http://app.example.com/cb", "https://app.example.com/cb#frag", "javascript:alert(1)"} {
+		_, err := svc.RegisterClient(&model.RegisterOAuthClientRequest{Name: "Bad", RedirectURIs: []string{uri}})
+		assert.ErrorIs(t, err, errors.ErrInvalidInput, uri)
+	}
+	public, err := svc.RegisterClient(&model.RegisterOAuthClientRequest{Name: "CLI", RedirectURIs: []string{"http://127.0.0.1:8400/cb"}, Public: true})
+	require.NoError(t, err)
+	assert.Empty(t, public.ClientSecret)
+	assert.Nil(t, public.SecretHash)
+	mockRepo.AssertNumberOfCalls(t, "CreateClient", 1)
+}
+
+func TestOAuth_RejectsSuspendedUsers(t *testing.T) {
+	// Given: A token and a code issued before the user was suspended
+	mockRepo := new(MockOAuthRepository)
+	users := new(MockUserRepository)
+	now := oauthTestTime
+	svc := newTestOAuthService(t, mockRepo, users, &now)
+	suspended := testUser
+	suspended.Status = model.UserSuspended
+	users.On("GetByID", testUser.ID, model.UserFields(nil)).Return(&testUser, nil).Once()
+	users.On("GetByID", testUser.ID, model.UserFields(nil)).Return(&suspended, nil)
+	mockRepo.On("TakeCode", hashMailToken("the-code")).Return(issuedCode(now), nil)
+	mockRepo.On("GetConsent", testUser.ID, wiki.ID).Return(wikiConsent, nil)
+	tok, err := svc.Exchange(tokenRequest())
+	require.NoError(t, err)
+	signingIn(t, users, model.UserSuspended)
+
+	// When / Then: They can neither sign in nor use either
+	_, err = svc.Authorize(authorizationRequest(), "jdoe", "violet-tangerine-42")
+	assert.ErrorIs(t, err, errors.ErrUserNotActive)
+	_, err = svc.Exchange(tokenRequest())
+	assert.Equal(t, oidc.InvalidGrant, oauthCode(t, err))
+	_, err = svc.UserInfo(tok.AccessToken)
+	assert.Equal(t, oidc.InvalidToken, oauthCode(t, err))
+}
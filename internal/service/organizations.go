@@ -0,0 +1,81 @@
+package service
+
+import (
+	"cruder/internal/errors"
+	"cruder/internal/model"
+	"cruder/internal/repository"
+	"fmt"
+	"regexp"
+	"strings"
+	"sync"
+
+	"github.com/google/uuid"
+)
+
+// slugPattern is what an organization slug may look like once lowercased: it goes in headers and
+// API client files, so it stays plain.
+var slugPattern = regexp.MustCompile(`^[a-z0-9]+(-[a-z0-9]+)*$`)
+
+// OrganizationService manages the tenants users belong to. Organizations cannot be renamed or
+// deleted, which lets Resolve cache them for good.
+type OrganizationService interface {
+	// Create adds an organization, returning ErrOrganizationExists if its slug is taken.
+	Create(req *model.CreateOrganizationRequest) (*model.Organization, error)
+	Get(id uuid.UUID) (*model.Organization, error)
+	List() ([]model.Organization, error)
+	// Resolve finds an organization by slug or ID, for auth.Authenticator.
+	Resolve(ref string) (*model.Organization, error)
+}
+
+type organizationService struct {
+	repo repository.OrganizationRepository
+
+	// cache holds resolved organizations by slug and by ID; misses are not cached, so an
+	// organization created on another replica is found at once
+	cache sync.Map
+}
+
+func NewOrganizationService(repo repository.OrganizationRepository) OrganizationService {
+	return &organizationService{repo: repo}
+}
+
+func (s *organizationService) Create(req *model.CreateOrganizationRequest) (*model.Organization, error) {
+	req.Slug = strings.TrimSpace(strings.ToLower(req.Slug))
+	req.Name = strings.TrimSpace(req.Name)
+	if !slugPattern.MatchString(req.Slug) {
+		return nil, fmt.Errorf("%w: slug may only contain letters, digits and inner hyphens", errors.ErrInvalidInput)
+	}
+	if req.Name == "" {
+		return nil, fmt.Errorf("%w: name is required", errors.ErrInvalidInput)
+	}
+	return s.repo.Create(req)
+}
+
+func (s *organizationService) Get(id uuid.UUID) (*model.Organization, error) {
+	return s.repo.GetByID(id)
+}
+
+func (s *organizationService) List() ([]model.Organization, error) {
+	return s.repo.List()
+}
+
+func (s *organizationService) Resolve(ref string) (*model.Organization, error) {
+	ref = strings.TrimSpace(strings.ToLower(ref))
+	if cached, ok := s.cache.Load(ref); ok {
+		return cached.(*model.Organization), nil
+	}
+
+	var org *model.Organization
+	var err error
+	if id, perr := uuid.Parse(ref); perr == nil {
+		org, err = s.repo.GetByID(id)
+	} else {
+		org, err = s.repo.GetBySlug(ref)
+	}
+	if err != nil {
+		return nil, err
+	}
+	s.cache.Store(org.Slug, org)
+	s.cache.Store(org.ID.String(), org)
+	return org, nil
+}
@@ -0,0 +1,133 @@
+package service
+
+import (
+	"cruder/internal/errors"
+	"cruder/internal/model"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+)
+
+func TestOrganizationService_Create(t *testing.T) {
+	// Given: A repository that stores organizations
+	mockRepo := new(MockOrganizationRepository)
+	svc := NewOrganizationService(mockRepo)
+	acme := &model.Organization{ID: uuid.New(), Slug: "acme-corp", Name: "Acme Corp"}
+	mockRepo.On("Create", &model.CreateOrganizationRequest{Slug: "acme-corp", Name: "Acme Corp"}).Return(acme, nil)
+
+	// When: Creating one with a slug and name in need of normalizing
+	org, err := svc.Create(&model.CreateOrganizationRequest{Slug: " Acme-Corp ", Name: " Acme Corp "})
+
+	// Then: The normalized request is stored
+	require.NoError(t, err)
+	assert.Equal(t, acme, org)
+	mockRepo.AssertExpectations(t)
+}
+
+func TestOrganizationService_CreateExists(t *testing.T) {
+	mockRepo := new(MockOrganizationRepository)
+	svc := NewOrganizationService(mockRepo)
+	mockRepo.On("Create", mock.Anything).Return(nil, errors.ErrOrganizationExists)
+
+	_, err := svc.Create(&model.CreateOrganizationRequest{Slug: "ACME-CORP", Name: "Again"})
+
+	assert.ErrorIs(t, err, errors.ErrOrganizationExists)
+}
+
+func TestOrganizationService_CreateValidation(t *testing.T) {
+	mockRepo := new(MockOrganizationRepository)
+	svc := NewOrganizationService(mockRepo)
+
+	for _, slug := range []string{"-acme", "acme-", "ac me", "acme--corp", "acmé"} {
+		_, err := svc.Create(&model.CreateOrganizationRequest{Slug: slug, Name: "Acme"})
+		assert.ErrorIs(t, err, errors.ErrInvalidInput, slug)
+	}
+	_, err := svc.Create(&model.CreateOrganizationRequest{Slug: "acme", Name: "  "})
+	assert.ErrorIs(t, err, errors.ErrInvalidInput, "name is required")
+	mockRepo.AssertNotCalled(t, "Create", mock.Anything)
+}
+
+func TestOrganizationService_Resolve(t *testing.T) {
+	// Given: An organization the repository finds by slug or ID
+	mockRepo := new(MockOrganizationRepository)
+	svc := NewOrganizationService(mockRepo)
+	acme := &model.Organization{ID: uuid.New(), Slug: "acme", Name: "Acme"}
+	mockRepo.On("GetBySlug", "acme").Return(acme, nil).Once()
+
+	// When: Resolving it by slug, in any case, and by ID
+	for _, ref := range []string{"acme", "ACME", acme.ID.String()} {
+		org, err := svc.Resolve(ref)
+
+		// Then: It is looked up once and served from the cache after
+		require.NoError(t, err, ref)
+		assert.Equal(t, acme.ID, org.ID, ref)
+	}
+	mockRepo.AssertExpectations(t)
+	mockRepo.AssertNotCalled(t, "GetByID", mock.Anything)
+}
+
+func TestOrganizationService_ResolveByID(t *testing.T) {
+	mockRepo := new(MockOrganizationRepository)
+	svc := NewOrganizationService(mockRepo)
+	def := &model.Organization{ID: model.DefaultOrganizationID, Slug: model.DefaultOrganizationSlug}
+	mockRepo.On("GetByID", model.DefaultOrganizationID).Return(def, nil).Once()
+
+	org, err := svc.Resolve(model.DefaultOrganizationID.String())
+	require.NoError(t, err)
+	assert.Equal(t, def, org)
+
+	org, err = svc.Resolve(model.DefaultOrganizationSlug)
+	require.NoError(t, err)
+	assert.Equal(t, def, org, "cached by slug too")
+	mockRepo.AssertExpectations(t)
+}
+
+func TestOrganizationService_ResolveMissesAreNotCached(t *testing.T) {
+	mockRepo := new(MockOrganizationRepository)
+	svc := NewOrganizationService(mockRepo)
+	nobody := &model.Organization{ID: uuid.New(), Slug: "nobody", Name: "Nobody"}
+	mockRepo.On("GetBySlug", "nobody").Return(nil, errors.ErrOrganizationNotFound).Once()
+	mockRepo.On("GetBySlug", "nobody").Return(nobody, nil).Once()
+
+	_, err := svc.Resolve("nobody")
+	assert.ErrorIs(t, err, errors.ErrOrganizationNotFound)
+
+	// Created since, e.g. on another replica
+	org, err := svc.Resolve("nobody")
+	assert.NoError(t, err)
+	assert.Equal(t, nobody, org)
+	mockRepo.AssertExpectations(t)
+}
+
+func TestUserService_OrganizationsAreIsolated(t *testing.T) {
+	// Given: Users stored per organization
+	acmeID := uuid.New()
+	acmeRepo := new(MockUserRepository)
+	mockRepo := &MockOrganizationUserRepository{Organizations: map[uuid.UUID]*MockUserRepository{acmeID: acmeRepo}}
+	users := WithUsernameQuarantine(NewUserService(mockRepo), mockRepo, time.Hour)
+	theirs := &model.User{ID: uuid.New(), OrganizationID: acmeID, Username: "jdoe"}
+	acmeRepo.On("GetFormerUsername", "jdoe", mock.Anything).Return(nil, errors.ErrUserNotFound)
+	acmeRepo.On("Create", mock.Anything).Return(theirs, nil)
+	acmeRepo.On("GetByID", theirs.ID, model.UserFields(nil)).Return(theirs, nil)
+	acmeRepo.On("Delete", theirs.ID).Return(nil)
+
+	// When: Working in another organization than the default one
+	acme := users.InOrganization(acmeID)
+	created, err := acme.Create(&model.CreateUserRequest{Username: "jdoe", Email: "john.doe@example.com", FullName: "John Doe"})
+	require.NoError(t, err)
+	assert.Equal(t, theirs, created)
+	_, err = acme.GetByID(theirs.ID, nil)
+	require.NoError(t, err)
+	require.NoError(t, acme.Delete(theirs.ID, "admin"))
+
+	// Then: Only that organization's storage is used, decorators included
+	acmeRepo.AssertExpectations(t)
+	mockRepo.AssertNotCalled(t, "GetFormerUsername", mock.Anything, mock.Anything)
+	mockRepo.AssertNotCalled(t, "Create", mock.Anything)
+	mockRepo.AssertNotCalled(t, "GetByID", mock.Anything, mock.Anything)
+	mockRepo.AssertNotCalled(t, "Delete", mock.Anything)
+}
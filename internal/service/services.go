@@ -1,13 +1,38 @@
 package service
 
-import "cruder/internal/repository"
+import (
+	"cruder/internal/password"
+	"cruder/internal/repository"
+)
 
 type Service struct {
-	Users UserService
+	Organizations OrganizationService
+	Users         UserService
+	Groups        GroupService
+	Jobs          JobService
+	TaskRuns      TaskRunService
+	Auth          AuthService
+	// Tokens needs the signing keys, so it is set by the caller; nil until then
+	Tokens TokenService
+	// Verification and EmailChanges need a mailer, so they are set by the caller too
+	Verification EmailVerificationService
+	EmailChanges EmailChangeService
+	// AuthFailures needs a logger for its security events, so it is set by the caller too
+	AuthFailures AuthFailureService
+	// OAuth signs with the same keys as Tokens, so it is set by the caller too
+	OAuth OAuthService
+	// Invitations create users and mail invitees, so it is set by the caller too
+	Invitations InvitationService
 }
 
-func NewService(repos *repository.Repository) *Service {
+// NewService wires the services over repos; hasher hashes and verifies user passwords.
+func NewService(repos *repository.Repository, hasher *password.Hasher) *Service {
 	return &Service{
-		Users: NewUserService(repos.Users),
+		Organizations: NewOrganizationService(repos.Organizations),
+		Users:         NewUserService(repos.Users),
+		Groups:        NewGroupService(repos.Groups, repos.Users),
+		Jobs:          NewJobService(repos.Jobs),
+		TaskRuns:      NewTaskRunService(repos.TaskRuns),
+		Auth:          NewAuthService(repos.Users, hasher),
 	}
 }
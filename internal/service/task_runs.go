@@ -0,0 +1,44 @@
+package service
+
+import (
+	"cruder/internal/errors"
+	"cruder/internal/model"
+	"cruder/internal/repository"
+	"fmt"
+	"strings"
+	"time"
+)
+
+// TaskRunService reports on scheduled maintenance runs. The runs themselves are made by scheduler.Scheduler.
+type TaskRunService interface {
+	// List returns the latest runs first, of one task or, if task is empty, of all of them.
+	// A limit of 0 means DefaultPageSize.
+	List(task string, limit int) ([]model.TaskRun, error)
+	// Prune deletes runs older than olderThan.
+	Prune(olderThan time.Duration) (int64, error)
+}
+
+type taskRunService struct {
+	repo repository.TaskRunRepository
+}
+
+func NewTaskRunService(repo repository.TaskRunRepository) TaskRunService {
+	return &taskRunService{repo: repo}
+}
+
+func (s *taskRunService) List(task string, limit int) ([]model.TaskRun, error) {
+	if limit == 0 {
+		limit = DefaultPageSize
+	}
+	if limit < 1 || limit > MaxPageSize {
+		return nil, fmt.Errorf("%w: limit must be between 1 and %d", errors.ErrInvalidInput, MaxPageSize)
+	}
+	return s.repo.List(strings.TrimSpace(task), limit)
+}
+
+func (s *taskRunService) Prune(olderThan time.Duration) (int64, error) {
+	if olderThan <= 0 {
+		return 0, fmt.Errorf("%w: retention must be positive", errors.ErrInvalidInput)
+	}
+	return s.repo.Prune(time.Now().Add(-olderThan))
+}
@@ -0,0 +1,176 @@
+package service
+
+import (
+	"cruder/internal/errors"
+	"cruder/internal/model"
+	"cruder/internal/repository"
+	"cruder/internal/token"
+	"fmt"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// DefaultTokenTTL is the access token lifetime when TokenOptions.TTL is zero.
+const DefaultTokenTTL = 15 * time.Minute
+
+// revocationCacheTTL bounds how long a revocation made on another replica takes to apply here.
+const revocationCacheTTL = 10 * time.Second
+
+// TokenService issues and verifies the short-lived access tokens API clients trade their API key for.
+type TokenService interface {
+	// Issue signs a token for p, narrowed to scope, a space-separated subset of p's scopes.
+	// An empty scope grants all of them.
+	Issue(p *model.Principal, scope string) (*model.AccessToken, error)
+	// Verify checks the signature, expiry and revocation of an access token and returns
+	// whom it was issued to.
+	Verify(accessToken string) (*model.Principal, error)
+	// Revoke rejects the tokens of req.Subject, or of every client, issued before req.IssuedBefore.
+	Revoke(req *model.RevokeTokensRequest) (*model.TokenRevocation, error)
+	// JWKS returns the public keys the tokens are signed with.
+	JWKS() token.JWKS
+}
+
+// TokenOptions configure the tokens a TokenService issues.
+type TokenOptions struct {
+	// Issuer goes in the iss claim and is required of the tokens verified
+	Issuer string
+	TTL    time.Duration
+}
+
+type tokenService struct {
+	repo repository.TokenRevocationRepository
+	keys *token.KeySet
+	opts TokenOptions
+	now  func() time.Time
+
+	mu       sync.Mutex
+	revoked  map[string]time.Time
+	loadedAt time.Time
+}
+
+func NewTokenService(repo repository.TokenRevocationRepository, keys *token.KeySet, opts TokenOptions) TokenService {
+	if opts.TTL == 0 {
+		opts.TTL = DefaultTokenTTL
+	}
+	return &tokenService{repo: repo, keys: keys, opts: opts, now: time.Now}
+}
+
+func (s *tokenService) Issue(p *model.Principal, scope string) (*model.AccessToken, error) {
+	granted := p.Scopes
+	if requested := strings.Fields(scope); len(requested) > 0 {
+		granted = nil
+		for _, sc := range requested {
+			if !slices.Contains(model.Scopes, sc) {
+				return nil, fmt.Errorf("%w: unknown scope %q", errors.ErrInvalidInput, sc)
+			}
+			if !p.HasScope(sc) {
+				return nil, fmt.Errorf("%w: %s", errors.ErrScopeNotGranted, sc)
+			}
+			if !slices.Contains(granted, sc) {
+				granted = append(granted, sc)
+			}
+		}
+	}
+
+	now := s.now()
+	claims := token.Claims{
+		Issuer:       s.opts.Issuer,
+		Subject:      p.Subject,
+		Scope:        strings.Join(granted, " "),
+		IssuedAt:     now.Unix(),
+		ExpiresAt:    now.Add(s.opts.TTL).Unix(),
+		ID:           uuid.NewString(),
+		Organization: p.Organization,
+	}
+	signed, err := s.keys.Sign(claims)
+	if err != nil {
+		return nil, err
+	}
+	return &model.AccessToken{
+		AccessToken: signed,
+		TokenType:   "Bearer",
+		ExpiresIn:   int64(s.opts.TTL / time.Second),
+		Scope:       claims.Scope,
+	}, nil
+}
+
+func (s *tokenService) Verify(accessToken string) (*model.Principal, error) {
+	claims, err := s.keys.Verify(accessToken, s.now())
+	if err != nil {
+		return nil, err
+	}
+	// Tokens with an audience were issued to a user for an OAuth client, not to an API client
+	if claims.Issuer != s.opts.Issuer || claims.Audience != "" {
+		return nil, token.ErrInvalidToken
+	}
+
+	revoked, err := s.revocations()
+	if err != nil {
+		return nil, err
+	}
+	// iat has one second resolution, so a token issued in the same second as a revocation is rejected too
+	issuedAt := time.Unix(claims.IssuedAt, 0)
+	for _, subject := range []string{"", claims.Subject} {
+		if before, ok := revoked[subject]; ok && issuedAt.Before(before) {
+			return nil, errors.ErrTokenRevoked
+		}
+	}
+
+	return &model.Principal{
+		Subject:      claims.Subject,
+		Scopes:       claims.Scopes(),
+		Method:       model.AuthToken,
+		IssuedAt:     issuedAt,
+		Organization: claims.Organization,
+	}, nil
+}
+
+// revocations returns the revocation list by subject, reloading it once it is older than revocationCacheTTL.
+func (s *tokenService) revocations() (map[string]time.Time, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.revoked != nil && s.now().Sub(s.loadedAt) < revocationCacheTTL {
+		return s.revoked, nil
+	}
+	list, err := s.repo.List()
+	if err != nil {
+		return nil, err
+	}
+	s.revoked = make(map[string]time.Time, len(list))
+	for _, rev := range list {
+		s.revoked[rev.Subject] = rev.IssuedBefore
+	}
+	s.loadedAt = s.now()
+	return s.revoked, nil
+}
+
+func (s *tokenService) Revoke(req *model.RevokeTokensRequest) (*model.TokenRevocation, error) {
+	now := s.now()
+	before := now
+	if req.IssuedBefore != nil {
+		if req.IssuedBefore.After(now) {
+			return nil, fmt.Errorf("%w: issued_before cannot be in the future", errors.ErrInvalidInput)
+		}
+		before = *req.IssuedBefore
+	}
+
+	rev, err := s.repo.Revoke(strings.TrimSpace(req.Subject), before)
+	if err != nil {
+		return nil, err
+	}
+
+	// Applies here at once; other replicas pick it up within revocationCacheTTL
+	s.mu.Lock()
+	s.revoked = nil
+	s.mu.Unlock()
+	return rev, nil
+}
+
+func (s *tokenService) JWKS() token.JWKS {
+	return s.keys.JWKS()
+}
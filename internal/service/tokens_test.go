@@ -0,0 +1,196 @@
+package service
+
+import (
+	"cruder/internal/errors"
+	"cruder/internal/model"
+	"cruder/internal/token"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+)
+
+var tokenTestTime = time.Date(2026, 3, 1, 12, 0, 0, 0, time.UTC)
+
+// newTestTokenService returns a token service over repo whose clock reads *now.
+func newTestTokenService(t *testing.T, repo *MockTokenRevocationRepository, now *time.Time) *tokenService {
+	t.Helper()
+	keys, err := token.GenerateKeySet()
+	require.NoError(t, err)
+	svc := NewTokenService(repo, keys, TokenOptions{Issuer: "cruder"}).(*tokenService)
+	svc.now = func() time.Time { return *now }
+	return svc
+}
+
+var ci = &model.Principal{Subject: "ci", Scopes: []string{model.ScopeUsersRead, model.ScopeUsersWrite}, Method: model.AuthAPIKey}
+
+func TestTokenService_IssueAndVerify(t *testing.T) {
+	// Given: No revocations
+	mockRepo := new(MockTokenRevocationRepository)
+	now := tokenTestTime
+	svc := newTestTokenService(t, mockRepo, &now)
+	mockRepo.On("List").Return([]model.TokenRevocation{}, nil)
+
+	// When: Issuing a token with the principal's scopes
+	issued, err := svc.Issue(ci, "")
+
+	// Then: It verifies as that principal until it expires
+	require.NoError(t, err)
+	assert.Equal(t, "Bearer", issued.TokenType)
+	assert.Equal(t, int64(900), issued.ExpiresIn)
+	assert.Equal(t, "users:read users:write", issued.Scope)
+
+	p, err := svc.Verify(issued.AccessToken)
+	require.NoError(t, err)
+	assert.Equal(t, "ci", p.Subject)
+	assert.Equal(t, model.AuthToken, p.Method)
+	assert.True(t, p.HasScope(model.ScopeUsersWrite))
+	assert.False(t, p.HasScope(model.ScopeAdmin))
+
+	now = now.Add(DefaultTokenTTL)
+	_, err = svc.Verify(issued.AccessToken)
+	assert.ErrorIs(t, err, token.ErrExpired)
+
+	other := NewTokenService(new(MockTokenRevocationRepository), svc.keys, TokenOptions{Issuer: "someone-else"})
+	foreign, err := other.Issue(ci, "")
+	require.NoError(t, err)
+	_, err = svc.Verify(foreign.AccessToken)
+	assert.ErrorIs(t, err, token.ErrInvalidToken, "issued by another issuer")
+}
+
+func TestTokenService_CarriesOrganization(t *testing.T) {
+	mockRepo := new(MockTokenRevocationRepository)
+	now := tokenTestTime
+	svc := newTestTokenService(t, mockRepo, &now)
+	mockRepo.On("List").Return([]model.TokenRevocation{}, nil)
+
+	acme := *ci
+	acme.Organization = "acme"
+	for _, p := range []*model.Principal{ci, &acme} {
+		issued, err := svc.Issue(p, "")
+		require.NoError(t, err)
+		verified, err := svc.Verify(issued.AccessToken)
+		require.NoError(t, err)
+		assert.Equal(t, p.Organization, verified.Organization)
+	}
+}
+
+func TestTokenService_IssueNarrowsScopes(t *testing.T) {
+	mockRepo := new(MockTokenRevocationRepository)
+	now := tokenTestTime
+	svc := newTestTokenService(t, mockRepo, &now)
+	mockRepo.On("List").Return([]model.TokenRevocation{}, nil)
+
+	issued, err := svc.Issue(ci, "users:read users:read")
+	require.NoError(t, err)
+	assert.Equal(t, "users:read", issued.Scope)
+	p, err := svc.Verify(issued.AccessToken)
+	require.NoError(t, err)
+	assert.False(t, p.HasScope(model.ScopeUsersWrite))
+
+	_, err = svc.Issue(ci, "admin")
+	assert.ErrorIs(t, err, errors.ErrScopeNotGranted)
+	_, err = svc.Issue(ci, "users:delete")
+	assert.ErrorIs(t, err, errors.ErrInvalidInput)
+
+	admin := &model.Principal{Subject: "ops", Scopes: []string{model.ScopeAdmin}, Method: model.AuthAPIKey}
+	issued, err = svc.Issue(admin, "users:write")
+	require.NoError(t, err, "admin implies every scope")
+	assert.Equal(t, "users:write", issued.Scope)
+}
+
+func TestTokenService_Revoke(t *testing.T) {
+	// Given: Tokens of two subjects, verified while nothing is revoked
+	mockRepo := new(MockTokenRevocationRepository)
+	now := tokenTestTime
+	svc := newTestTokenService(t, mockRepo, &now)
+	ops := &model.Principal{Subject: "ops", Scopes: []string{model.ScopeAdmin}, Method: model.AuthAPIKey}
+
+	ciToken, err := svc.Issue(ci, "")
+	require.NoError(t, err)
+	opsToken, err := svc.Issue(ops, "")
+	require.NoError(t, err)
+	mockRepo.On("List").Return([]model.TokenRevocation{}, nil).Once()
+	_, err = svc.Verify(ciToken.AccessToken)
+	require.NoError(t, err)
+
+	// When: Revoking the tokens of one subject
+	now = now.Add(time.Minute)
+	revoked := &model.TokenRevocation{Subject: "ci", IssuedBefore: now, UpdatedAt: now}
+	mockRepo.On("Revoke", "ci", now).Return(revoked, nil).Once()
+	rev, err := svc.Revoke(&model.RevokeTokensRequest{Subject: " ci "})
+
+	// Then: The subject is trimmed, the revocation applies at once, and only to that subject
+	require.NoError(t, err)
+	assert.Equal(t, revoked, rev)
+	mockRepo.On("List").Return([]model.TokenRevocation{*revoked}, nil)
+
+	_, err = svc.Verify(ciToken.AccessToken)
+	assert.ErrorIs(t, err, errors.ErrTokenRevoked)
+	_, err = svc.Verify(opsToken.AccessToken)
+	assert.NoError(t, err, "other subjects are unaffected")
+
+	now = now.Add(time.Second)
+	fresh, err := svc.Issue(ci, "")
+	require.NoError(t, err)
+	_, err = svc.Verify(fresh.AccessToken)
+	assert.NoError(t, err, "tokens issued after the revocation are valid")
+	mockRepo.AssertExpectations(t)
+}
+
+func TestTokenService_RevokeIssuedBefore(t *testing.T) {
+	mockRepo := new(MockTokenRevocationRepository)
+	now := tokenTestTime
+	svc := newTestTokenService(t, mockRepo, &now)
+
+	// An explicit time is passed on; the empty subject stands for every client
+	earlier := now.Add(-time.Hour)
+	mockRepo.On("Revoke", "", earlier).Return(&model.TokenRevocation{IssuedBefore: earlier}, nil)
+	_, err := svc.Revoke(&model.RevokeTokensRequest{IssuedBefore: &earlier})
+	require.NoError(t, err)
+
+	future := now.Add(time.Hour)
+	_, err = svc.Revoke(&model.RevokeTokensRequest{IssuedBefore: &future})
+	assert.ErrorIs(t, err, errors.ErrInvalidInput)
+	mockRepo.AssertNumberOfCalls(t, "Revoke", 1)
+}
+
+func TestTokenService_RevokeEverySubject(t *testing.T) {
+	mockRepo := new(MockTokenRevocationRepository)
+	now := tokenTestTime
+	svc := newTestTokenService(t, mockRepo, &now)
+	issued, err := svc.Issue(ci, "")
+	require.NoError(t, err)
+
+	mockRepo.On("List").Return([]model.TokenRevocation{{Subject: "", IssuedBefore: now.Add(time.Second)}}, nil)
+	_, err = svc.Verify(issued.AccessToken)
+	assert.ErrorIs(t, err, errors.ErrTokenRevoked)
+}
+
+func TestTokenService_RevocationsFromOtherReplicas(t *testing.T) {
+	// Given: A verified token, so the revocation list is cached
+	mockRepo := new(MockTokenRevocationRepository)
+	now := tokenTestTime
+	svc := newTestTokenService(t, mockRepo, &now)
+	issued, err := svc.Issue(ci, "")
+	require.NoError(t, err)
+	mockRepo.On("List").Return([]model.TokenRevocation{}, nil).Once()
+	_, err = svc.Verify(issued.AccessToken)
+	require.NoError(t, err)
+
+	// When: Another replica revokes straight in the shared store
+	now = now.Add(time.Second)
+	mockRepo.On("List").Return([]model.TokenRevocation{{Subject: "ci", IssuedBefore: now}}, nil)
+
+	// Then: The cached list still lets the token through until it is reloaded
+	_, err = svc.Verify(issued.AccessToken)
+	assert.NoError(t, err)
+
+	now = now.Add(revocationCacheTTL)
+	_, err = svc.Verify(issued.AccessToken)
+	assert.ErrorIs(t, err, errors.ErrTokenRevoked)
+	mockRepo.AssertNumberOfCalls(t, "List", 2)
+	mockRepo.AssertNotCalled(t, "Revoke", mock.Anything, mock.Anything)
+}
@@ -0,0 +1,192 @@
+package service
+
+import (
+	"cruder/internal/errors"
+	"cruder/internal/events"
+	"cruder/internal/model"
+	"io"
+	"log/slog"
+	"testing"
+
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+)
+
+func TestCreate_InitialStatus(t *testing.T) {
+	mockRepo := new(MockUserRepository)
+	users := NewUserService(mockRepo)
+	mockRepo.On("Create", mock.MatchedBy(func(req *model.CreateUserRequest) bool { return req.Username == "alice" })).
+		Return(&model.User{Username: "alice", Status: model.UserActive}, nil)
+	mockRepo.On("Create", mock.MatchedBy(func(req *model.CreateUserRequest) bool { return req.Username == "bob" })).
+		Return(&model.User{Username: "bob", Status: model.UserInvited}, nil)
+
+	// New users are active unless they are invited
+	alice := &model.CreateUserRequest{Username: "alice", Email: "alice@example.com", FullName: "Alice"}
+	_, err := users.Create(alice)
+	require.NoError(t, err)
+	assert.Equal(t, model.UserActive, alice.Status)
+	bob := &model.CreateUserRequest{Username: "bob", Email: "bob@example.com", FullName: "Bob", Status: model.UserInvited}
+	_, err = users.Create(bob)
+	require.NoError(t, err)
+	assert.Equal(t, model.UserInvited, bob.Status)
+
+	_, err = users.Create(&model.CreateUserRequest{Username: "carol", Email: "carol@example.com", FullName: "Carol", Status: model.UserSuspended})
+	assert.ErrorIs(t, err, errors.ErrInvalidInput)
+	mockRepo.AssertNumberOfCalls(t, "Create", 2)
+}
+
+func TestChangeStatus_Lifecycle(t *testing.T) {
+	// Each allowed step is applied from the status the user was found in
+	for _, step := range []struct {
+		from, to model.UserStatus
+		reason   string
+	}{
+		{model.UserInvited, model.UserActive, ""},
+		{model.UserActive, model.UserSuspended, "security review"},
+		{model.UserSuspended, model.UserActive, "review passed"},
+		{model.UserActive, model.UserDeactivated, "left the company"},
+	} {
+		mockRepo := new(MockUserRepository)
+		users := NewUserService(mockRepo)
+		id := uuid.New()
+		mockRepo.On("GetByID", id, model.UserFields{"status"}).Return(&model.User{ID: id, Status: step.from}, nil)
+		mockRepo.On("SetStatus", id, &model.UserStatusChange{UserID: id, OldStatus: step.from, NewStatus: step.to, Reason: step.reason, Actor: "admin"}).
+			Return(&model.User{ID: id, Status: step.to}, nil)
+
+		user, err := users.ChangeStatus(id, step.to, &model.ChangeUserStatusRequest{Reason: step.reason, Actor: "admin"})
+
+		require.NoError(t, err, step.to)
+		assert.Equal(t, step.to, user.Status)
+		mockRepo.AssertExpectations(t)
+	}
+}
+
+func TestChangeStatus_TransitionNotAllowed(t *testing.T) {
+	for _, step := range []struct {
+		from, to model.UserStatus
+		why      string
+	}{
+		{model.UserInvited, model.UserSuspended, "not active yet"},
+		{model.UserDeactivated, model.UserActive, "deactivation is final"},
+		{model.UserDeactivated, model.UserDeactivated, "already deactivated"},
+		{model.UserActive, model.UserActive, "already active"},
+		{model.UserActive, "banned", "not a status"},
+	} {
+		mockRepo := new(MockUserRepository)
+		users := NewUserService(mockRepo)
+		id := uuid.New()
+		mockRepo.On("GetByID", id, model.UserFields{"status"}).Return(&model.User{ID: id, Status: step.from}, nil)
+
+		_, err := users.ChangeStatus(id, step.to, &model.ChangeUserStatusRequest{Reason: "because"})
+
+		var transition *errors.StatusTransitionError
+		require.ErrorAs(t, err, &transition, step.why)
+		assert.Equal(t, errors.StatusTransitionError{From: string(step.from), To: string(step.to)}, *transition)
+		assert.ErrorIs(t, err, errors.ErrStatusTransition)
+		mockRepo.AssertNotCalled(t, "SetStatus", mock.Anything, mock.Anything)
+	}
+}
+
+func TestChangeStatus_Validation(t *testing.T) {
+	mockRepo := new(MockUserRepository)
+	users := NewUserService(mockRepo)
+	id, unknown := uuid.New(), uuid.New()
+	mockRepo.On("GetByID", unknown, mock.Anything).Return(nil, errors.ErrUserNotFound)
+
+	_, err := users.ChangeStatus(id, model.UserSuspended, &model.ChangeUserStatusRequest{Reason: "  "})
+	assert.ErrorIs(t, err, errors.ErrInvalidInput, "suspending needs a reason")
+	_, err = users.ChangeStatus(id, model.UserDeactivated, &model.ChangeUserStatusRequest{})
+	assert.ErrorIs(t, err, errors.ErrInvalidInput, "deactivating needs a reason")
+	_, err = users.ChangeStatus(unknown, model.UserSuspended, &model.ChangeUserStatusRequest{Reason: "spam"})
+	assert.ErrorIs(t, err, errors.ErrUserNotFound)
+	_, err = users.StatusHistory(unknown)
+	assert.ErrorIs(t, err, errors.ErrUserNotFound)
+	mockRepo.AssertNotCalled(t, "SetStatus", mock.Anything, mock.Anything)
+	mockRepo.AssertNotCalled(t, "StatusHistory", mock.Anything)
+}
+
+func TestChangeStatus_RecordsReasonAndActor(t *testing.T) {
+	// Given: An active user
+	mockRepo := new(MockUserRepository)
+	users := NewUserService(mockRepo)
+	id := uuid.New()
+	mockRepo.On("GetByID", id, model.UserFields{"status"}).Return(&model.User{ID: id, Status: model.UserActive}, nil)
+	mockRepo.On("SetStatus", id, &model.UserStatusChange{UserID: id, OldStatus: model.UserActive, NewStatus: model.UserSuspended,
+		Reason: "security review", Actor: model.ActorSystem}).Return(&model.User{ID: id, Status: model.UserSuspended}, nil)
+
+	// When: Suspending them without a known caller
+	_, err := users.ChangeStatus(id, model.UserSuspended, &model.ChangeUserStatusRequest{Reason: " security review "})
+
+	// Then: The trimmed reason is recorded as a change by the system
+	require.NoError(t, err)
+	mockRepo.AssertExpectations(t)
+}
+
+func TestChangeStatus_LostRace(t *testing.T) {
+	mockRepo := new(MockUserRepository)
+	users := NewUserService(mockRepo)
+	id := uuid.New()
+	mockRepo.On("GetByID", id, model.UserFields{"status"}).Return(&model.User{ID: id, Status: model.UserActive}, nil)
+	mockRepo.On("SetStatus", id, mock.Anything).
+		Return(nil, &errors.StatusTransitionError{From: string(model.UserDeactivated), To: string(model.UserSuspended)})
+
+	_, err := users.ChangeStatus(id, model.UserSuspended, &model.ChangeUserStatusRequest{Reason: "audit"})
+
+	assert.ErrorIs(t, err, errors.ErrStatusTransition, "the repository's answer stands")
+}
+
+func TestStatusHistory(t *testing.T) {
+	mockRepo := new(MockUserRepository)
+	users := NewUserService(mockRepo)
+	id := uuid.New()
+	history := []model.UserStatusChange{{UserID: id, OldStatus: model.UserActive, NewStatus: model.UserSuspended, Reason: "audit"}}
+	mockRepo.On("GetByID", id, model.UserFields{"id"}).Return(&model.User{ID: id}, nil)
+	mockRepo.On("StatusHistory", id).Return(history, nil)
+
+	got, err := users.StatusHistory(id)
+
+	require.NoError(t, err)
+	assert.Equal(t, history, got)
+	mockRepo.AssertExpectations(t)
+}
+
+func TestList_FiltersByStatus(t *testing.T) {
+	mockRepo := new(MockUserRepository)
+	users := NewUserService(mockRepo)
+	suspended := []model.User{{Username: "alice", Status: model.UserSuspended}}
+	mockRepo.On("List", model.ListUsersParams{Limit: DefaultPageSize, Status: model.UserSuspended}).Return(suspended, nil)
+
+	got, err := users.List(model.ListUsersParams{Status: model.UserSuspended})
+	require.NoError(t, err)
+	assert.Equal(t, suspended, got)
+
+	_, err = users.List(model.ListUsersParams{Status: "banned"})
+	assert.ErrorIs(t, err, errors.ErrInvalidInput)
+	mockRepo.AssertNumberOfCalls(t, "List", 1)
+}
+
+func TestChangeStatus_PublishesUpdate(t *testing.T) {
+	// Given: Subscribers to user events
+	broker := events.NewBroker()
+	ch, unsubscribe := broker.Subscribe()
+	defer unsubscribe()
+	mockRepo := new(MockUserRepository)
+	users := WithEvents(NewUserService(mockRepo), broker, slog.New(slog.NewTextHandler(io.Discard, nil)))
+	id := uuid.New()
+	mockRepo.On("GetByID", id, model.UserFields{"status"}).Return(&model.User{ID: id, Status: model.UserActive}, nil)
+	mockRepo.On("SetStatus", id, mock.Anything).Return(&model.User{ID: id, Status: model.UserSuspended}, nil)
+
+	// When: A transition succeeds and another fails
+	_, err := users.ChangeStatus(id, model.UserSuspended, &model.ChangeUserStatusRequest{Reason: "audit"})
+	require.NoError(t, err)
+	_, err = users.ChangeStatus(id, model.UserInvited, &model.ChangeUserStatusRequest{})
+	require.ErrorIs(t, err, errors.ErrStatusTransition)
+
+	// Then: Only the first is announced
+	e := <-ch
+	assert.Equal(t, events.UserUpdated, e.Type)
+	assert.Equal(t, model.UserSuspended, e.User.Status)
+	assert.Empty(t, ch, "failed transitions are not announced")
+}
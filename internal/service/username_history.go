@@ -0,0 +1,84 @@
+package service
+
+import (
+	"cruder/internal/errors"
+	"cruder/internal/model"
+	"cruder/internal/repository"
+	"time"
+
+	"github.com/google/uuid"
+
+	stdErrors "errors"
+)
+
+// quarantiningUserService decorates a UserService so a username given up in a rename cannot be
+// claimed by anyone else until the quarantine period has passed, which keeps old links from
+// pointing at an impersonator. The user who gave it up may take it back at any time.
+type quarantiningUserService struct {
+	UserService
+	repo   repository.UserRepository
+	period time.Duration
+	now    func() time.Time
+}
+
+// WithUsernameQuarantine wraps users so former usernames stay reserved for period after a rename.
+// A zero period disables the check; renames are recorded in the history either way.
+func WithUsernameQuarantine(users UserService, repo repository.UserRepository, period time.Duration) UserService {
+	return &quarantiningUserService{UserService: users, repo: repo, period: period, now: time.Now}
+}
+
+func (s *quarantiningUserService) InOrganization(org uuid.UUID) UserService {
+	return &quarantiningUserService{UserService: s.UserService.InOrganization(org), repo: s.repo.InOrganization(org), period: s.period, now: s.now}
+}
+
+// checkReserved returns ErrUsernameReserved if a user other than self gave up username within
+// the quarantine period. username must already be normalized.
+func (s *quarantiningUserService) checkReserved(self uuid.UUID, username string) error {
+	if s.period <= 0 {
+		return nil
+	}
+	change, err := s.repo.GetFormerUsername(username, s.now().Add(-s.period))
+	if stdErrors.Is(err, errors.ErrUserNotFound) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	if change.UserID == self {
+		return nil
+	}
+	return errors.ErrUsernameReserved
+}
+
+func (s *quarantiningUserService) Create(req *model.CreateUserRequest) (*model.User, error) {
+	if err := s.UserService.ValidateCreate(req); err != nil {
+		return nil, err
+	}
+	if err := s.checkReserved(uuid.Nil, req.Username); err != nil {
+		return nil, err
+	}
+	return s.UserService.Create(req)
+}
+
+func (s *quarantiningUserService) Update(id uuid.UUID, req *model.UpdateUserRequest) (*model.User, error) {
+	if req.Username == nil {
+		return s.UserService.Update(id, req)
+	}
+	if err := s.UserService.ValidateUpdate(req); err != nil {
+		return nil, err
+	}
+	if err := s.checkReserved(id, *req.Username); err != nil {
+		return nil, err
+	}
+	return s.UserService.Update(id, req)
+}
+
+func (s *quarantiningUserService) Replace(id uuid.UUID, req *model.ReplaceUserRequest) (*model.User, error) {
+	if err := s.UserService.ValidateCreate(req); err != nil {
+		return nil, err
+	}
+	if err := s.checkReserved(id, req.Username); err != nil {
+		return nil, err
+	}
+	return s.UserService.Replace(id, req)
+}
@@ -0,0 +1,170 @@
+package service
+
+import (
+	"cruder/internal/errors"
+	"cruder/internal/model"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+)
+
+const quarantine = 30 * 24 * time.Hour
+
+// newTestQuarantine returns the quarantining decorator over a user service on repo, with a clock reading now.
+func newTestQuarantine(repo *MockUserRepository, now time.Time) UserService {
+	svc := WithUsernameQuarantine(NewUserService(repo), repo, quarantine).(*quarantiningUserService)
+	svc.now = func() time.Time { return now }
+	return svc
+}
+
+func newUsername(s string) *string { return &s }
+
+func TestUsernameHistory_Success(t *testing.T) {
+	mockRepo := new(MockUserRepository)
+	svc := NewUserService(mockRepo)
+	id := uuid.New()
+	history := []model.UsernameChange{{UserID: id, OldUsername: "jdoe", NewUsername: "johnd", Actor: "admin"}}
+	mockRepo.On("GetByID", id, model.UserFields{"id"}).Return(&model.User{ID: id}, nil)
+	mockRepo.On("UsernameHistory", id).Return(history, nil)
+
+	got, err := svc.UsernameHistory(id)
+
+	assert.NoError(t, err)
+	assert.Equal(t, history, got)
+	mockRepo.AssertExpectations(t)
+}
+
+func TestUsernameHistory_UnknownUser(t *testing.T) {
+	mockRepo := new(MockUserRepository)
+	svc := NewUserService(mockRepo)
+	id := uuid.New()
+	mockRepo.On("GetByID", id, model.UserFields{"id"}).Return(nil, errors.ErrUserNotFound)
+
+	_, err := svc.UsernameHistory(id)
+
+	assert.ErrorIs(t, err, errors.ErrUserNotFound, "not an empty history")
+	mockRepo.AssertNotCalled(t, "UsernameHistory", mock.Anything)
+}
+
+func TestUpdate_RecordsActor(t *testing.T) {
+	// Given: Renames with and without a known caller
+	mockRepo := new(MockUserRepository)
+	svc := NewUserService(mockRepo)
+	id := uuid.New()
+	mockRepo.On("Update", id, &model.UpdateUserRequest{Username: newUsername("johnd"), Actor: "admin"}).Return(&model.User{ID: id}, nil)
+	mockRepo.On("Update", id, &model.UpdateUserRequest{Username: newUsername("johnny"), Actor: model.ActorSystem}).Return(&model.User{ID: id}, nil)
+
+	// When: Renaming the user
+	_, err := svc.Update(id, &model.UpdateUserRequest{Username: newUsername(" JohnD "), Actor: "admin"})
+	require.NoError(t, err)
+	_, err = svc.Update(id, &model.UpdateUserRequest{Username: newUsername("johnny")})
+	require.NoError(t, err)
+
+	// Then: The repository records the normalized name with the actor, or the system
+	mockRepo.AssertExpectations(t)
+}
+
+func TestUsernameQuarantine_ReservesFormerUsernames(t *testing.T) {
+	// Given: Another user gave up "jdoe" within the quarantine period
+	mockRepo := new(MockUserRepository)
+	now := time.Now()
+	svc := newTestQuarantine(mockRepo, now)
+	previousOwner, other := uuid.New(), uuid.New()
+	mockRepo.On("GetFormerUsername", "jdoe", now.Add(-quarantine)).
+		Return(&model.UsernameChange{UserID: previousOwner, OldUsername: "jdoe", NewUsername: "johnd"}, nil)
+
+	// When: Anyone else claims it
+	_, err := svc.Create(&model.CreateUserRequest{Username: " JDoe ", Email: "impostor@example.com", FullName: "Impostor"})
+	assert.ErrorIs(t, err, errors.ErrUsernameReserved)
+	_, err = svc.Update(other, &model.UpdateUserRequest{Username: newUsername("jdoe")})
+	assert.ErrorIs(t, err, errors.ErrUsernameReserved)
+	_, err = svc.Replace(other, &model.ReplaceUserRequest{Username: "jdoe", Email: "other@example.com", FullName: "Other User"})
+	assert.ErrorIs(t, err, errors.ErrUsernameReserved)
+
+	// Then: It stays reserved, and nothing is written
+	mockRepo.AssertNotCalled(t, "Create", mock.Anything)
+	mockRepo.AssertNotCalled(t, "Update", mock.Anything, mock.Anything)
+}
+
+func TestUsernameQuarantine_PreviousOwnerTakesItBack(t *testing.T) {
+	mockRepo := new(MockUserRepository)
+	now := time.Now()
+	svc := newTestQuarantine(mockRepo, now)
+	previousOwner := uuid.New()
+	mockRepo.On("GetFormerUsername", "jdoe", now.Add(-quarantine)).
+		Return(&model.UsernameChange{UserID: previousOwner, OldUsername: "jdoe", NewUsername: "johnd"}, nil)
+	mockRepo.On("Update", previousOwner, mock.Anything).Return(&model.User{ID: previousOwner, Username: "jdoe"}, nil)
+
+	back, err := svc.Update(previousOwner, &model.UpdateUserRequest{Username: newUsername("jdoe")})
+
+	require.NoError(t, err)
+	assert.Equal(t, "jdoe", back.Username)
+	mockRepo.AssertExpectations(t)
+}
+
+func TestUsernameQuarantine_Expired(t *testing.T) {
+	// Given: No rename away from "jdoe" within the quarantine period
+	mockRepo := new(MockUserRepository)
+	now := time.Now()
+	svc := newTestQuarantine(mockRepo, now)
+	other := uuid.New()
+	mockRepo.On("GetFormerUsername", "jdoe", now.Add(-quarantine)).Return(nil, errors.ErrUserNotFound)
+	mockRepo.On("Update", other, mock.Anything).Return(&model.User{ID: other, Username: "jdoe"}, nil)
+
+	// When: Someone claims it
+	taken, err := svc.Update(other, &model.UpdateUserRequest{Username: newUsername("jdoe")})
+
+	// Then: Anyone may have it
+	require.NoError(t, err)
+	assert.Equal(t, "jdoe", taken.Username)
+	mockRepo.AssertExpectations(t)
+}
+
+func TestUsernameQuarantine_LeavesOtherChangesAlone(t *testing.T) {
+	mockRepo := new(MockUserRepository)
+	svc := newTestQuarantine(mockRepo, time.Now())
+	id := uuid.New()
+	fullName := "Johnny Doe"
+	mockRepo.On("Update", id, mock.Anything).Return(&model.User{ID: id}, nil)
+
+	_, err := svc.Update(id, &model.UpdateUserRequest{FullName: &fullName})
+
+	require.NoError(t, err)
+	mockRepo.AssertNotCalled(t, "GetFormerUsername", mock.Anything, mock.Anything)
+}
+
+func TestUsernameQuarantine_Disabled(t *testing.T) {
+	mockRepo := new(MockUserRepository)
+	svc := WithUsernameQuarantine(NewUserService(mockRepo), mockRepo, 0)
+	mockRepo.On("Create", mock.Anything).Return(&model.User{Username: "jdoe"}, nil)
+
+	_, err := svc.Create(&model.CreateUserRequest{Username: "jdoe", Email: "someone@example.com", FullName: "Someone Else"})
+
+	assert.NoError(t, err)
+	mockRepo.AssertNotCalled(t, "GetFormerUsername", mock.Anything, mock.Anything)
+}
+
+func TestGetByFormerUsername(t *testing.T) {
+	// Given: A user formerly called "jdoe", now "johnny"
+	mockRepo := new(MockUserRepository)
+	svc := NewUserService(mockRepo)
+	user := &model.User{ID: uuid.New(), Username: "johnny"}
+	mockRepo.On("GetFormerUsername", "jdoe", time.Time{}).Return(&model.UsernameChange{UserID: user.ID, OldUsername: "jdoe"}, nil)
+	mockRepo.On("GetFormerUsername", "nobody", time.Time{}).Return(nil, errors.ErrUserNotFound)
+	mockRepo.On("GetByID", user.ID, model.UserFields(nil)).Return(user, nil)
+
+	// When: Looking them up by the former name, however it is written
+	found, err := svc.GetByFormerUsername(" JDoe ", nil)
+
+	// Then: They are found as they are now, at any time since the rename
+	require.NoError(t, err)
+	assert.Equal(t, user, found)
+
+	_, err = svc.GetByFormerUsername("nobody", nil)
+	assert.ErrorIs(t, err, errors.ErrUserNotFound)
+	mockRepo.AssertExpectations(t)
+}
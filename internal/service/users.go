@@ -7,17 +7,54 @@ import (
 	"fmt"
 	"regexp"
 	"strings"
+	"time"
 
 	"github.com/google/uuid"
 )
 
+// UserService manages the users of one organization. NewUserService returns the service for
+// model.DefaultOrganizationID; InOrganization switches.
 type UserService interface {
-	GetAll() ([]model.User, error)
-	GetByUsername(username string) (*model.User, error)
-	GetByID(id uuid.UUID) (*model.User, error)
+	// InOrganization returns the service for the users of org. Decorators must override it to
+	// wrap the service they return, or they would drop out of every organization-scoped call.
+	InOrganization(org uuid.UUID) UserService
+
+	// fields selects the attributes to load (see model.UserFields); nil loads everything.
+	GetAll(fields model.UserFields) ([]model.User, error)
+	Search(query string, fields model.UserFields) ([]model.User, error)
+	// StreamAll and StreamSearch hand users to fn one at a time as they are read (see repository.UserRepository).
+	StreamAll(fields model.UserFields, fn func(*model.User) error) error
+	StreamSearch(query string, fields model.UserFields, fn func(*model.User) error) error
+	List(params model.ListUsersParams) ([]model.User, error)
+	GetByUsername(username string, fields model.UserFields) (*model.User, error)
+	GetByEmail(email string, fields model.UserFields) (*model.User, error)
+	GetByID(id uuid.UUID, fields model.UserFields) (*model.User, error)
+	GetByIDs(ids []uuid.UUID) ([]model.User, error)
 	Create(req *model.CreateUserRequest) (*model.User, error)
 	Update(id uuid.UUID, req *model.UpdateUserRequest) (*model.User, error)
-	Delete(id uuid.UUID) error
+	Replace(id uuid.UUID, req *model.ReplaceUserRequest) (*model.User, error)
+	// Delete removes the user. actor is recorded for the group memberships that end with them
+	// (see WithGroupRemoval).
+	Delete(id uuid.UUID, actor string) error
+
+	// UsernameHistory returns the user's renames, newest first.
+	UsernameHistory(id uuid.UUID) ([]model.UsernameChange, error)
+	// GetByFormerUsername finds the user who most recently gave up username, however long ago,
+	// so links to an old username can be redirected. ErrUserNotFound if nobody ever did.
+	GetByFormerUsername(username string, fields model.UserFields) (*model.User, error)
+
+	// ChangeStatus moves the user to status to and records the transition, with req's reason and
+	// actor, in the status history. A transition the lifecycle does not allow (see
+	// model.UserStatus.CanBecome) is a *errors.StatusTransitionError; suspending and deactivating
+	// require a reason.
+	ChangeStatus(id uuid.UUID, to model.UserStatus, req *model.ChangeUserStatusRequest) (*model.User, error)
+	// StatusHistory returns the user's status changes, newest first.
+	StatusHistory(id uuid.UUID) ([]model.UserStatusChange, error)
+
+	// ValidateCreate and ValidateUpdate normalize the request in place and apply business rules
+	// without persisting anything. Used for dry runs; Create and Update call them too.
+	ValidateCreate(req *model.CreateUserRequest) error
+	ValidateUpdate(req *model.UpdateUserRequest) error
 }
 
 type userService struct {
@@ -34,14 +71,67 @@ func NewUserService(repo repository.UserRepository) UserService {
 	}
 }
 
-func (s *userService) GetAll() ([]model.User, error) {
-	return s.repo.GetAll()
+func (s *userService) InOrganization(org uuid.UUID) UserService {
+	return &userService{repo: s.repo.InOrganization(org), fullNamePattern: s.fullNamePattern}
+}
+
+func (s *userService) GetAll(fields model.UserFields) ([]model.User, error) {
+	return s.repo.GetAll(fields)
+}
+
+// Page size bounds for List
+const (
+	DefaultPageSize = 100
+	MaxPageSize     = 1000
+)
+
+func (s *userService) List(params model.ListUsersParams) ([]model.User, error) {
+	if params.Limit == 0 {
+		params.Limit = DefaultPageSize
+	}
+	if params.Limit < 1 || params.Limit > MaxPageSize {
+		return nil, fmt.Errorf("%w: limit must be between 1 and %d", errors.ErrInvalidInput, MaxPageSize)
+	}
+	if params.Status != "" {
+		if _, err := model.ParseUserStatus(string(params.Status)); err != nil {
+			return nil, err
+		}
+	}
+	return s.repo.List(params)
+}
+
+func (s *userService) Search(query string, fields model.UserFields) ([]model.User, error) {
+	query, err := normalizeQuery(query)
+	if err != nil {
+		return nil, err
+	}
+	return s.repo.Search(query, fields)
+}
+
+func (s *userService) StreamAll(fields model.UserFields, fn func(*model.User) error) error {
+	return s.repo.StreamAll(fields, fn)
+}
+
+func (s *userService) StreamSearch(query string, fields model.UserFields, fn func(*model.User) error) error {
+	query, err := normalizeQuery(query)
+	if err != nil {
+		return err
+	}
+	return s.repo.StreamSearch(query, fields, fn)
+}
+
+func normalizeQuery(query string) (string, error) {
+	query = strings.TrimSpace(query)
+	if query == "" {
+		return "", fmt.Errorf("%w: search query must not be empty", errors.ErrInvalidInput)
+	}
+	return query, nil
 }
 
-func (s *userService) GetByUsername(username string) (*model.User, error) {
+func (s *userService) GetByUsername(username string, fields model.UserFields) (*model.User, error) {
 	// assuming username is case insensitive can serve as good example of business logic being validated in service layer.
 	normalizedUsername := strings.TrimSpace(strings.ToLower(username))
-	var user, err = s.repo.GetByUsername(normalizedUsername)
+	var user, err = s.repo.GetByUsername(normalizedUsername, fields)
 	if err != nil {
 		// Repository layer maps storage errors to domain errors; service simply propagates.
 		return nil, err
@@ -49,8 +139,13 @@ func (s *userService) GetByUsername(username string) (*model.User, error) {
 	return user, nil
 }
 
-func (s *userService) GetByID(id uuid.UUID) (*model.User, error) {
-	var user, err = s.repo.GetByID(id)
+// GetByEmail finds a user by email address, which is stored lowercased like the username.
+func (s *userService) GetByEmail(email string, fields model.UserFields) (*model.User, error) {
+	return s.repo.GetByEmail(strings.TrimSpace(strings.ToLower(email)), fields)
+}
+
+func (s *userService) GetByID(id uuid.UUID, fields model.UserFields) (*model.User, error) {
+	var user, err = s.repo.GetByID(id, fields)
 	if err != nil {
 		// Repository layer maps storage errors to domain errors; service simply propagates.
 		return nil, err
@@ -58,14 +153,16 @@ func (s *userService) GetByID(id uuid.UUID) (*model.User, error) {
 	return user, nil
 }
 
-func (s *userService) Create(req *model.CreateUserRequest) (*model.User, error) {
-	// Normalize input
-	req.Username = strings.TrimSpace(strings.ToLower(req.Username))
-	req.Email = strings.TrimSpace(strings.ToLower(req.Email))
-	req.FullName = strings.TrimSpace(req.FullName)
+// GetByIDs fetches many users in one round trip; IDs that do not exist are omitted from the result.
+func (s *userService) GetByIDs(ids []uuid.UUID) ([]model.User, error) {
+	if len(ids) == 0 {
+		return nil, nil
+	}
+	return s.repo.GetByIDs(ids)
+}
 
-	// Business logic validation: full name must contain only letters, spaces, hyphens, and apostrophes
-	if err := s.validateFullName(req.FullName); err != nil {
+func (s *userService) Create(req *model.CreateUserRequest) (*model.User, error) {
+	if err := s.ValidateCreate(req); err != nil {
 		return nil, err
 	}
 
@@ -79,6 +176,25 @@ func (s *userService) Create(req *model.CreateUserRequest) (*model.User, error)
 	return user, nil
 }
 
+func (s *userService) ValidateCreate(req *model.CreateUserRequest) error {
+	// Normalize input
+	req.Username = strings.TrimSpace(strings.ToLower(req.Username))
+	req.Email = strings.TrimSpace(strings.ToLower(req.Email))
+	req.FullName = strings.TrimSpace(req.FullName)
+
+	// New users are active unless they are only invited; the other statuses are reached by transitions
+	switch req.Status {
+	case "":
+		req.Status = model.UserActive
+	case model.UserInvited, model.UserActive:
+	default:
+		return fmt.Errorf("%w: a new user's status must be invited or active", errors.ErrInvalidInput)
+	}
+
+	// Business logic validation: full name must contain only letters, spaces, hyphens, and apostrophes
+	return s.validateFullName(req.FullName)
+}
+
 // controller layer already does format check for alphanumeric characters and email format
 // Stating my assumption here that, if we have any checl/validation related to business logic , we can add it here.
 // forexample if we have a rule that validateFullName validates that a full name contains only allowed characters
@@ -91,6 +207,48 @@ func (s *userService) validateFullName(fullName string) error {
 }
 
 func (s *userService) Update(id uuid.UUID, req *model.UpdateUserRequest) (*model.User, error) {
+	if err := s.ValidateUpdate(req); err != nil {
+		return nil, err
+	}
+	if req.Actor == "" {
+		req.Actor = model.ActorSystem
+	}
+
+	// Update user in repository
+	user, err := s.repo.Update(id, req)
+	if err != nil {
+		// Repository layer maps storage errors to domain errors; service simply propagates.
+		return nil, err
+	}
+
+	return user, nil
+}
+
+// Replace overwrites every mutable field of an existing user, validated exactly like Create.
+func (s *userService) Replace(id uuid.UUID, req *model.ReplaceUserRequest) (*model.User, error) {
+	if err := s.ValidateCreate(req); err != nil {
+		return nil, err
+	}
+
+	actor := req.Actor
+	if actor == "" {
+		actor = model.ActorSystem
+	}
+	user, err := s.repo.Update(id, &model.UpdateUserRequest{
+		Username: &req.Username,
+		Email:    &req.Email,
+		FullName: &req.FullName,
+		Actor:    actor,
+	})
+	if err != nil {
+		// Repository layer maps storage errors to domain errors; service simply propagates.
+		return nil, err
+	}
+
+	return user, nil
+}
+
+func (s *userService) ValidateUpdate(req *model.UpdateUserRequest) error {
 	// Normalize input for fields that are present
 	if req.Username != nil {
 		normalized := strings.TrimSpace(strings.ToLower(*req.Username))
@@ -106,21 +264,58 @@ func (s *userService) Update(id uuid.UUID, req *model.UpdateUserRequest) (*model
 
 		// Business logic validation: full name must contain only letters, spaces, hyphens, and apostrophes
 		if err := s.validateFullName(*req.FullName); err != nil {
-			return nil, err
+			return err
 		}
 	}
 
-	// Update user in repository
-	user, err := s.repo.Update(id, req)
+	return nil
+}
+
+func (s *userService) UsernameHistory(id uuid.UUID) ([]model.UsernameChange, error) {
+	// An unknown user is not found rather than a user without renames
+	if _, err := s.repo.GetByID(id, model.UserFields{"id"}); err != nil {
+		return nil, err
+	}
+	return s.repo.UsernameHistory(id)
+}
+
+func (s *userService) GetByFormerUsername(username string, fields model.UserFields) (*model.User, error) {
+	change, err := s.repo.GetFormerUsername(strings.TrimSpace(strings.ToLower(username)), time.Time{})
 	if err != nil {
-		// Repository layer maps storage errors to domain errors; service simply propagates.
 		return nil, err
 	}
+	return s.repo.GetByID(change.UserID, fields)
+}
 
-	return user, nil
+func (s *userService) ChangeStatus(id uuid.UUID, to model.UserStatus, req *model.ChangeUserStatusRequest) (*model.User, error) {
+	req.Reason = strings.TrimSpace(req.Reason)
+	if req.Reason == "" && (to == model.UserSuspended || to == model.UserDeactivated) {
+		return nil, fmt.Errorf("%w: a reason is required to make a user %s", errors.ErrInvalidInput, to)
+	}
+	user, err := s.repo.GetByID(id, model.UserFields{"status"})
+	if err != nil {
+		return nil, err
+	}
+	if !user.Status.CanBecome(to) {
+		return nil, &errors.StatusTransitionError{From: string(user.Status), To: string(to)}
+	}
+
+	actor := req.Actor
+	if actor == "" {
+		actor = model.ActorSystem
+	}
+	// The repository only applies the change if the status is still the one checked above
+	return s.repo.SetStatus(id, &model.UserStatusChange{UserID: id, OldStatus: user.Status, NewStatus: to, Reason: req.Reason, Actor: actor})
+}
+
+func (s *userService) StatusHistory(id uuid.UUID) ([]model.UserStatusChange, error) {
+	if _, err := s.repo.GetByID(id, model.UserFields{"id"}); err != nil {
+		return nil, err
+	}
+	return s.repo.StatusHistory(id)
 }
 
-func (s *userService) Delete(id uuid.UUID) error {
+func (s *userService) Delete(id uuid.UUID, _ string) error {
 	// Delete user from repository
 	return s.repo.Delete(id)
 }
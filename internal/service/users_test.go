@@ -3,6 +3,7 @@ package service
 import (
 	"cruder/internal/errors"
 	"cruder/internal/model"
+	"cruder/internal/repository"
 	"testing"
 	"time"
 
@@ -16,30 +17,77 @@ type MockUserRepository struct {
 	mock.Mock
 }
 
-func (m *MockUserRepository) GetAll() ([]model.User, error) {
-	args := m.Called()
+// InOrganization returns the mock itself, so expectations hold in every organization.
+func (m *MockUserRepository) InOrganization(uuid.UUID) repository.UserRepository {
+	return m
+}
+
+func (m *MockUserRepository) GetAll(fields model.UserFields) ([]model.User, error) {
+	args := m.Called(fields)
 	if args.Get(0) == nil {
 		return nil, args.Error(1)
 	}
 	return args.Get(0).([]model.User), args.Error(1)
 }
 
-func (m *MockUserRepository) GetByUsername(username string) (*model.User, error) {
-	args := m.Called(username)
+func (m *MockUserRepository) Search(query string, fields model.UserFields) ([]model.User, error) {
+	args := m.Called(query, fields)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).([]model.User), args.Error(1)
+}
+
+func (m *MockUserRepository) StreamAll(fields model.UserFields, fn func(*model.User) error) error {
+	args := m.Called(fields, fn)
+	return args.Error(0)
+}
+
+func (m *MockUserRepository) StreamSearch(query string, fields model.UserFields, fn func(*model.User) error) error {
+	args := m.Called(query, fields, fn)
+	return args.Error(0)
+}
+
+func (m *MockUserRepository) List(params model.ListUsersParams) ([]model.User, error) {
+	args := m.Called(params)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).([]model.User), args.Error(1)
+}
+
+func (m *MockUserRepository) GetByUsername(username string, fields model.UserFields) (*model.User, error) {
+	args := m.Called(username, fields)
 	if args.Get(0) == nil {
 		return nil, args.Error(1)
 	}
 	return args.Get(0).(*model.User), args.Error(1)
 }
 
-func (m *MockUserRepository) GetByID(id uuid.UUID) (*model.User, error) {
-	args := m.Called(id)
+func (m *MockUserRepository) GetByEmail(email string, fields model.UserFields) (*model.User, error) {
+	args := m.Called(email, fields)
 	if args.Get(0) == nil {
 		return nil, args.Error(1)
 	}
 	return args.Get(0).(*model.User), args.Error(1)
 }
 
+func (m *MockUserRepository) GetByID(id uuid.UUID, fields model.UserFields) (*model.User, error) {
+	args := m.Called(id, fields)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).(*model.User), args.Error(1)
+}
+
+func (m *MockUserRepository) GetByIDs(ids []uuid.UUID) ([]model.User, error) {
+	args := m.Called(ids)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).([]model.User), args.Error(1)
+}
+
 func (m *MockUserRepository) Create(req *model.CreateUserRequest) (*model.User, error) {
 	args := m.Called(req)
 	if args.Get(0) == nil {
@@ -61,6 +109,64 @@ func (m *MockUserRepository) Delete(id uuid.UUID) error {
 	return args.Error(0)
 }
 
+func (m *MockUserRepository) GetCredentials(login string) (*model.Credentials, error) {
+	args := m.Called(login)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).(*model.Credentials), args.Error(1)
+}
+
+func (m *MockUserRepository) GetCredentialsByID(id uuid.UUID) (*model.Credentials, error) {
+	args := m.Called(id)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).(*model.Credentials), args.Error(1)
+}
+
+func (m *MockUserRepository) SetPasswordHash(id uuid.UUID, hash string) error {
+	args := m.Called(id, hash)
+	return args.Error(0)
+}
+
+func (m *MockUserRepository) MarkEmailVerified(id uuid.UUID, email string, at time.Time) error {
+	args := m.Called(id, email, at)
+	return args.Error(0)
+}
+
+func (m *MockUserRepository) UsernameHistory(id uuid.UUID) ([]model.UsernameChange, error) {
+	args := m.Called(id)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).([]model.UsernameChange), args.Error(1)
+}
+
+func (m *MockUserRepository) GetFormerUsername(username string, since time.Time) (*model.UsernameChange, error) {
+	args := m.Called(username, since)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).(*model.UsernameChange), args.Error(1)
+}
+
+func (m *MockUserRepository) SetStatus(id uuid.UUID, change *model.UserStatusChange) (*model.User, error) {
+	args := m.Called(id, change)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).(*model.User), args.Error(1)
+}
+
+func (m *MockUserRepository) StatusHistory(id uuid.UUID) ([]model.UserStatusChange, error) {
+	args := m.Called(id)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).([]model.UserStatusChange), args.Error(1)
+}
+
 // =============================================================================
 // GetAll Tests
 // =============================================================================
@@ -85,10 +191,10 @@ func TestGetAll_Success(t *testing.T) {
 		},
 	}
 
-	mockRepo.On("GetAll").Return(expectedUsers, nil)
+	mockRepo.On("GetAll", model.UserFields(nil)).Return(expectedUsers, nil)
 
 	// When: Calling GetAll
-	users, err := service.GetAll()
+	users, err := service.GetAll(nil)
 
 	// Then: Should return users and no error
 	assert.NoError(t, err)
@@ -100,15 +206,87 @@ func TestGetAll_RepositoryError(t *testing.T) {
 	mockRepo := new(MockUserRepository)
 	service := NewUserService(mockRepo)
 
-	mockRepo.On("GetAll").Return(nil, assert.AnError)
+	mockRepo.On("GetAll", model.UserFields(nil)).Return(nil, assert.AnError)
 
-	users, err := service.GetAll()
+	users, err := service.GetAll(nil)
 
 	assert.Error(t, err)
 	assert.Nil(t, users)
 	mockRepo.AssertExpectations(t)
 }
 
+// =============================================================================
+// List Tests
+// =============================================================================
+
+func TestList_DefaultsLimit(t *testing.T) {
+	mockRepo := new(MockUserRepository)
+	service := NewUserService(mockRepo)
+
+	mockRepo.On("List", model.ListUsersParams{Limit: DefaultPageSize}).Return([]model.User{}, nil)
+
+	users, err := service.List(model.ListUsersParams{})
+
+	assert.NoError(t, err)
+	assert.Empty(t, users)
+	mockRepo.AssertExpectations(t)
+}
+
+func TestList_LimitOutOfRange(t *testing.T) {
+	mockRepo := new(MockUserRepository)
+	service := NewUserService(mockRepo)
+
+	_, err := service.List(model.ListUsersParams{Limit: MaxPageSize + 1})
+
+	assert.ErrorIs(t, err, errors.ErrInvalidInput)
+	mockRepo.AssertNotCalled(t, "List", mock.Anything)
+}
+
+// =============================================================================
+// Search Tests
+// =============================================================================
+
+func TestSearch_TrimsQuery(t *testing.T) {
+	mockRepo := new(MockUserRepository)
+	service := NewUserService(mockRepo)
+
+	expectedUsers := []model.User{{ID: uuid.New(), Username: "johndoe"}}
+	fields := model.UserFields{"username", "email"}
+	mockRepo.On("Search", "john", fields).Return(expectedUsers, nil)
+
+	users, err := service.Search("  john ", fields)
+
+	assert.NoError(t, err)
+	assert.Equal(t, expectedUsers, users)
+	mockRepo.AssertExpectations(t)
+}
+
+func TestSearch_EmptyQuery(t *testing.T) {
+	mockRepo := new(MockUserRepository)
+	service := NewUserService(mockRepo)
+
+	users, err := service.Search("   ", nil)
+
+	assert.ErrorIs(t, err, errors.ErrInvalidInput)
+	assert.Nil(t, users)
+	mockRepo.AssertNotCalled(t, "Search", mock.Anything, mock.Anything)
+}
+
+func TestStreamSearch_TrimsQueryAndRejectsEmpty(t *testing.T) {
+	mockRepo := new(MockUserRepository)
+	service := NewUserService(mockRepo)
+
+	fields := model.UserFields{"id", "username"}
+	mockRepo.On("StreamSearch", "john", fields, mock.Anything).Return(nil)
+
+	err := service.StreamSearch(" john ", fields, func(*model.User) error { return nil })
+	assert.NoError(t, err)
+
+	err = service.StreamSearch("", fields, func(*model.User) error { return nil })
+	assert.ErrorIs(t, err, errors.ErrInvalidInput)
+	mockRepo.AssertNumberOfCalls(t, "StreamSearch", 1)
+}
+
 // =============================================================================
 // GetByUsername Tests
 // =============================================================================
@@ -126,10 +304,10 @@ func TestGetByUsername_Success(t *testing.T) {
 	}
 
 	// Service normalizes username to lowercase and trimmed
-	mockRepo.On("GetByUsername", "johndoe").Return(expectedUser, nil)
+	mockRepo.On("GetByUsername", "johndoe", model.UserFields(nil)).Return(expectedUser, nil)
 
 	// When: Calling with mixed case and spaces
-	user, err := service.GetByUsername("  JohnDoe  ")
+	user, err := service.GetByUsername("  JohnDoe  ", nil)
 
 	// Then: Should normalize and return the user
 	assert.NoError(t, err)
@@ -141,9 +319,9 @@ func TestGetByUsername_UserNotFound(t *testing.T) {
 	mockRepo := new(MockUserRepository)
 	service := NewUserService(mockRepo)
 
-	mockRepo.On("GetByUsername", "nonexistent").Return(nil, errors.ErrUserNotFound)
+	mockRepo.On("GetByUsername", "nonexistent", model.UserFields(nil)).Return(nil, errors.ErrUserNotFound)
 
-	user, err := service.GetByUsername("nonexistent")
+	user, err := service.GetByUsername("nonexistent", nil)
 
 	assert.ErrorIs(t, err, errors.ErrUserNotFound)
 	assert.Nil(t, user)
@@ -154,15 +332,29 @@ func TestGetByUsername_RepositoryError(t *testing.T) {
 	mockRepo := new(MockUserRepository)
 	service := NewUserService(mockRepo)
 
-	mockRepo.On("GetByUsername", "johndoe").Return(nil, assert.AnError)
+	mockRepo.On("GetByUsername", "johndoe", model.UserFields(nil)).Return(nil, assert.AnError)
 
-	user, err := service.GetByUsername("johndoe")
+	user, err := service.GetByUsername("johndoe", nil)
 
 	assert.Error(t, err)
 	assert.Nil(t, user)
 	mockRepo.AssertExpectations(t)
 }
 
+func TestGetByEmail_NormalizesEmail(t *testing.T) {
+	mockRepo := new(MockUserRepository)
+	service := NewUserService(mockRepo)
+
+	expectedUser := &model.User{ID: uuid.New(), Username: "johndoe", Email: "john@example.com"}
+	mockRepo.On("GetByEmail", "john@example.com", model.UserFields(nil)).Return(expectedUser, nil)
+
+	user, err := service.GetByEmail(" John@Example.com ", nil)
+
+	assert.NoError(t, err)
+	assert.Equal(t, expectedUser, user)
+	mockRepo.AssertExpectations(t)
+}
+
 // =============================================================================
 // GetByID Tests
 // =============================================================================
@@ -179,9 +371,9 @@ func TestGetByID_Success(t *testing.T) {
 		FullName: "John Doe",
 	}
 
-	mockRepo.On("GetByID", userID).Return(expectedUser, nil)
+	mockRepo.On("GetByID", userID, model.UserFields(nil)).Return(expectedUser, nil)
 
-	user, err := service.GetByID(userID)
+	user, err := service.GetByID(userID, nil)
 
 	assert.NoError(t, err)
 	assert.Equal(t, expectedUser, user)
@@ -193,9 +385,9 @@ func TestGetByID_UserNotFound(t *testing.T) {
 	service := NewUserService(mockRepo)
 
 	userID := uuid.New()
-	mockRepo.On("GetByID", userID).Return(nil, errors.ErrUserNotFound)
+	mockRepo.On("GetByID", userID, model.UserFields(nil)).Return(nil, errors.ErrUserNotFound)
 
-	user, err := service.GetByID(userID)
+	user, err := service.GetByID(userID, nil)
 
 	assert.ErrorIs(t, err, errors.ErrUserNotFound)
 	assert.Nil(t, user)
@@ -207,9 +399,9 @@ func TestGetByID_RepositoryError(t *testing.T) {
 	service := NewUserService(mockRepo)
 
 	userID := uuid.New()
-	mockRepo.On("GetByID", userID).Return(nil, assert.AnError)
+	mockRepo.On("GetByID", userID, model.UserFields(nil)).Return(nil, assert.AnError)
 
-	user, err := service.GetByID(userID)
+	user, err := service.GetByID(userID, nil)
 
 	assert.Error(t, err)
 	assert.Nil(t, user)
@@ -502,6 +694,56 @@ func TestUpdate_RepositoryError(t *testing.T) {
 	mockRepo.AssertExpectations(t)
 }
 
+// =============================================================================
+// Replace Tests
+// =============================================================================
+
+func TestReplace_NormalizesAndSetsEveryField(t *testing.T) {
+	// PUT semantics: every mutable field is written, normalized like Create
+	mockRepo := new(MockUserRepository)
+	service := NewUserService(mockRepo)
+
+	userID := uuid.New()
+	replaced := &model.User{ID: userID, Username: "jdoe", Email: "jdoe@example.com", FullName: "John Doe"}
+
+	mockRepo.On("Update", userID, mock.MatchedBy(func(req *model.UpdateUserRequest) bool {
+		return req.Username != nil && *req.Username == "jdoe" &&
+			req.Email != nil && *req.Email == "jdoe@example.com" &&
+			req.FullName != nil && *req.FullName == "John Doe"
+	})).Return(replaced, nil)
+
+	user, err := service.Replace(userID, &model.ReplaceUserRequest{Username: " JDoe ", Email: "JDoe@Example.com", FullName: " John Doe "})
+
+	assert.NoError(t, err)
+	assert.Equal(t, replaced, user)
+	mockRepo.AssertExpectations(t)
+}
+
+func TestReplace_InvalidFullName(t *testing.T) {
+	mockRepo := new(MockUserRepository)
+	service := NewUserService(mockRepo)
+
+	user, err := service.Replace(uuid.New(), &model.ReplaceUserRequest{Username: "jdoe", Email: "jdoe@example.com", FullName: "Invalid123"})
+
+	assert.ErrorIs(t, err, errors.ErrInvalidInput)
+	assert.Nil(t, user)
+	mockRepo.AssertNotCalled(t, "Update", mock.Anything, mock.Anything)
+}
+
+func TestReplace_UserNotFound(t *testing.T) {
+	mockRepo := new(MockUserRepository)
+	service := NewUserService(mockRepo)
+
+	userID := uuid.New()
+	mockRepo.On("Update", userID, mock.Anything).Return(nil, errors.ErrUserNotFound)
+
+	user, err := service.Replace(userID, &model.ReplaceUserRequest{Username: "jdoe", Email: "jdoe@example.com", FullName: "John Doe"})
+
+	assert.ErrorIs(t, err, errors.ErrUserNotFound)
+	assert.Nil(t, user)
+	mockRepo.AssertExpectations(t)
+}
+
 // =============================================================================
 // Delete Tests
 // =============================================================================
@@ -515,7 +757,7 @@ func TestDelete_Success(t *testing.T) {
 	mockRepo.On("Delete", userID).Return(nil)
 
 	// When: Deleting a user
-	err := service.Delete(userID)
+	err := service.Delete(userID, "admin")
 
 	// Then: Should succeed
 	assert.NoError(t, err)
@@ -530,7 +772,7 @@ func TestDelete_UserNotFound(t *testing.T) {
 	userID := uuid.New()
 	mockRepo.On("Delete", userID).Return(errors.ErrUserNotFound)
 
-	err := service.Delete(userID)
+	err := service.Delete(userID, "admin")
 
 	assert.ErrorIs(t, err, errors.ErrUserNotFound)
 	mockRepo.AssertExpectations(t)
@@ -543,7 +785,7 @@ func TestDelete_RepositoryError(t *testing.T) {
 	userID := uuid.New()
 	mockRepo.On("Delete", userID).Return(assert.AnError)
 
-	err := service.Delete(userID)
+	err := service.Delete(userID, "admin")
 
 	assert.Error(t, err)
 	mockRepo.AssertExpectations(t)
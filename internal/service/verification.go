@@ -0,0 +1,258 @@
+package service
+
+import (
+	"context"
+	"cruder/internal/errors"
+	"cruder/internal/mail"
+	"cruder/internal/model"
+	"cruder/internal/repository"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"fmt"
+	"log/slog"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+
+	stdErrors "errors"
+)
+
+// DefaultVerificationTTL is how long a verification token stays valid when VerificationOptions.TTL is zero.
+const DefaultVerificationTTL = 48 * time.Hour
+
+// mailTimeout bounds how long sending one email may hold up the request that triggered it.
+const mailTimeout = 10 * time.Second
+
+// EmailVerificationService proves users receive mail at their address, with single-use tokens
+// mailed to it.
+type EmailVerificationService interface {
+	// InOrganization returns the service for the users of org; Verify finds the user by token,
+	// in whichever organization it is.
+	InOrganization(org uuid.UUID) EmailVerificationService
+	// Send mails a new token for the user's current address, replacing any earlier one.
+	Send(user *model.User) error
+	// Resend sends a new token to the user with id, unless their address is already verified.
+	Resend(id uuid.UUID) error
+	// Verify consumes token and marks the address it was sent to as verified, returning the user.
+	// Unknown, used and expired tokens, and tokens for an address the user no longer has,
+	// fail with ErrVerificationTokenInvalid.
+	Verify(token string) (*model.User, error)
+	// PruneExpired deletes expired tokens.
+	PruneExpired() (int64, error)
+}
+
+// VerificationOptions configure the verification emails.
+type VerificationOptions struct {
+	TTL time.Duration
+	// URL is the page the email links to, with ?token=... appended. Without one the email
+	// only carries the token.
+	URL string
+}
+
+type emailVerificationService struct {
+	repo   repository.EmailVerificationRepository
+	users  repository.UserRepository
+	mailer mail.Mailer
+	opts   VerificationOptions
+	now    func() time.Time
+}
+
+func NewEmailVerificationService(repo repository.EmailVerificationRepository, users repository.UserRepository, mailer mail.Mailer, opts VerificationOptions) EmailVerificationService {
+	if opts.TTL == 0 {
+		opts.TTL = DefaultVerificationTTL
+	}
+	return &emailVerificationService{repo: repo, users: users, mailer: mailer, opts: opts, now: time.Now}
+}
+
+// hashMailToken is what is stored of a token sent by email, so a leaked table does not hand out valid tokens.
+func hashMailToken(token string) []byte {
+	sum := sha256.Sum256([]byte(token))
+	return sum[:]
+}
+
+// newMailToken returns a random token to send by email and its hash to store.
+func newMailToken() (string, []byte, error) {
+	raw := make([]byte, 32)
+	if _, err := rand.Read(raw); err != nil {
+		return "", nil, fmt.Errorf("failed to generate token: %w", err)
+	}
+	token := base64.RawURLEncoding.EncodeToString(raw)
+	return token, hashMailToken(token), nil
+}
+
+func (s *emailVerificationService) InOrganization(org uuid.UUID) EmailVerificationService {
+	scoped := *s
+	scoped.users = s.users.InOrganization(org)
+	return &scoped
+}
+
+func (s *emailVerificationService) Send(user *model.User) error {
+	token, hash, err := newMailToken()
+	if err != nil {
+		return err
+	}
+
+	now := s.now()
+	err = s.repo.Create(&model.EmailVerification{
+		TokenHash:      hash,
+		UserID:         user.ID,
+		OrganizationID: user.OrganizationID,
+		Email:          user.Email,
+		ExpiresAt:      now.Add(s.opts.TTL),
+		CreatedAt:      now,
+	})
+	if err != nil {
+		return err
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), mailTimeout)
+	defer cancel()
+	return s.mailer.Send(ctx, mail.Message{
+		To:      user.Email,
+		Subject: "Confirm your email address",
+		Text:    s.messageText(user, token),
+	})
+}
+
+func (s *emailVerificationService) messageText(user *model.User, token string) string {
+	var b strings.Builder
+	fmt.Fprintf(&b, "Hello %s,\n\n", user.FullName)
+	fmt.Fprintf(&b, "please confirm that %s is the email address of the account %s.\n\n", user.Email, user.Username)
+	writeToken(&b, "confirm", s.opts.URL, token)
+	fmt.Fprintf(&b, "It expires in %s and works once. If you did not ask for this, you can ignore this email.\n", formatTTL(s.opts.TTL))
+	return b.String()
+}
+
+// writeToken tells the reader how to use token to do action: by opening pageURL with it, if there
+// is a page, or by entering it.
+func writeToken(b *strings.Builder, action, pageURL, token string) {
+	if pageURL != "" {
+		fmt.Fprintf(b, "Open this link to %s:\n\n    %s?token=%s\n\n", action, pageURL, url.QueryEscape(token))
+		b.WriteString("or enter this code where you were asked for it:\n\n")
+	} else {
+		b.WriteString("Enter this code where you were asked for it:\n\n")
+	}
+	fmt.Fprintf(b, "    %s\n\n", token)
+}
+
+// formatTTL renders whole hours as "48 hours" rather than Duration's "48h0m0s".
+func formatTTL(d time.Duration) string {
+	if d >= time.Hour && d%time.Hour == 0 {
+		if d == time.Hour {
+			return "1 hour"
+		}
+		return fmt.Sprintf("%d hours", d/time.Hour)
+	}
+	return d.String()
+}
+
+func (s *emailVerificationService) Resend(id uuid.UUID) error {
+	user, err := s.users.GetByID(id, nil)
+	if err != nil {
+		return err
+	}
+	if user.EmailVerifiedAt != nil {
+		return errors.ErrEmailAlreadyVerified
+	}
+	return s.Send(user)
+}
+
+func (s *emailVerificationService) Verify(token string) (*model.User, error) {
+	v, err := s.repo.Consume(hashMailToken(strings.TrimSpace(token)))
+	if err != nil {
+		return nil, err
+	}
+	now := s.now()
+	if !now.Before(v.ExpiresAt) {
+		return nil, errors.ErrVerificationTokenInvalid
+	}
+
+	// Fails if the user has since changed address (or been deleted)
+	users := s.users.InOrganization(v.OrganizationID)
+	if err := users.MarkEmailVerified(v.UserID, v.Email, now); err != nil {
+		if stdErrors.Is(err, errors.ErrUserNotFound) {
+			return nil, errors.ErrVerificationTokenInvalid
+		}
+		return nil, err
+	}
+	return users.GetByID(v.UserID, nil)
+}
+
+func (s *emailVerificationService) PruneExpired() (int64, error) {
+	return s.repo.PruneExpired(s.now())
+}
+
+// verifyingUserService decorates a UserService so new users, and users who change their email,
+// are sent a verification token. Sending happens after the change is committed; a failure is
+// logged, not returned, because the write did succeed; Resend covers a lost email.
+type verifyingUserService struct {
+	UserService
+	verification EmailVerificationService
+	logger       *slog.Logger
+}
+
+// WithEmailVerification wraps users so creates and email changes send a verification email.
+func WithEmailVerification(users UserService, verification EmailVerificationService, logger *slog.Logger) UserService {
+	return &verifyingUserService{UserService: users, verification: verification, logger: logger}
+}
+
+func (s *verifyingUserService) InOrganization(org uuid.UUID) UserService {
+	return &verifyingUserService{UserService: s.UserService.InOrganization(org), verification: s.verification, logger: s.logger}
+}
+
+func (s *verifyingUserService) send(user *model.User) {
+	if err := s.verification.Send(user); err != nil {
+		s.logger.Error("Failed to send verification email",
+			slog.String("user_id", user.ID.String()),
+			slog.String("error", err.Error()))
+	}
+}
+
+func (s *verifyingUserService) Create(req *model.CreateUserRequest) (*model.User, error) {
+	user, err := s.UserService.Create(req)
+	if err != nil {
+		return nil, err
+	}
+	s.send(user)
+	return user, nil
+}
+
+// previousEmail is the user's address before an update that may change it; empty if it cannot.
+func (s *verifyingUserService) previousEmail(id uuid.UUID, email *string) string {
+	if email == nil {
+		return ""
+	}
+	before, err := s.UserService.GetByID(id, model.UserFields{"email"})
+	if err != nil {
+		// The update reports the same error
+		return ""
+	}
+	return before.Email
+}
+
+func (s *verifyingUserService) Update(id uuid.UUID, req *model.UpdateUserRequest) (*model.User, error) {
+	before := s.previousEmail(id, req.Email)
+	user, err := s.UserService.Update(id, req)
+	if err != nil {
+		return nil, err
+	}
+	if req.Email != nil && user.Email != before {
+		s.send(user)
+	}
+	return user, nil
+}
+
+func (s *verifyingUserService) Replace(id uuid.UUID, req *model.ReplaceUserRequest) (*model.User, error) {
+	before := s.previousEmail(id, &req.Email)
+	user, err := s.UserService.Replace(id, req)
+	if err != nil {
+		return nil, err
+	}
+	if user.Email != before {
+		s.send(user)
+	}
+	return user, nil
+}
@@ -0,0 +1,214 @@
+package service
+
+import (
+	"cruder/internal/errors"
+	"cruder/internal/mail"
+	"cruder/internal/model"
+	"io"
+	"log/slog"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+)
+
+var mailTestTime = time.Date(2026, 3, 1, 12, 0, 0, 0, time.UTC)
+
+// newTestVerificationService returns a verification service over repo and users whose clock reads *now.
+func newTestVerificationService(repo *MockEmailVerificationRepository, users *MockUserRepository, mailer mail.Mailer, now *time.Time) *emailVerificationService {
+	svc := NewEmailVerificationService(repo, users, mailer, VerificationOptions{URL: "https://app.example.com/verify"}).(*emailVerificationService)
+	svc.now = func() time.Time { return *now }
+	return svc
+}
+
+// mailedToken extracts the code from the latest email with subject sent to addr.
+func mailedToken(t *testing.T, mailer *mail.MemoryMailer, addr, subject string) string {
+	t.Helper()
+	msgs := mailer.Messages()
+	for i := len(msgs) - 1; i >= 0; i-- {
+		if msgs[i].To == addr && msgs[i].Subject == subject {
+			_, rest, found := strings.Cut(msgs[i].Text, "where you were asked for it:\n\n")
+			require.True(t, found)
+			return strings.TrimSpace(strings.SplitN(rest, "\n", 2)[0])
+		}
+	}
+	t.Fatalf("no %q email sent to %s", subject, addr)
+	return ""
+}
+
+var verifiedUser = model.User{ID: uuid.New(), OrganizationID: model.DefaultOrganizationID, Username: "jdoe", Email: "john.doe@example.com", FullName: "John Doe"}
+
+func TestEmailVerification_SendStoresHashOfMailedToken(t *testing.T) {
+	// Given: A user whose address is to be verified
+	mockRepo := new(MockEmailVerificationRepository)
+	mailer := &mail.MemoryMailer{}
+	now := mailTestTime
+	svc := newTestVerificationService(mockRepo, new(MockUserRepository), mailer, &now)
+	var stored *model.EmailVerification
+	mockRepo.On("Create", mock.Anything).Run(func(args mock.Arguments) {
+		stored = args.Get(0).(*model.EmailVerification)
+	}).Return(nil)
+
+	// When: Sending the verification email
+	require.NoError(t, svc.Send(&verifiedUser))
+
+	// Then: It links to the page with the token, of which only the hash is stored
+	msg, ok := mailer.Last("john.doe@example.com")
+	require.True(t, ok)
+	assert.Equal(t, "Confirm your email address", msg.Subject)
+	assert.Contains(t, msg.Text, "https://app.example.com/verify?token=")
+	assert.Contains(t, msg.Text, "48 hours")
+
+	token := mailedToken(t, mailer, "john.doe@example.com", "Confirm your email address")
+	require.NotNil(t, stored)
+	assert.Equal(t, hashMailToken(token), stored.TokenHash)
+	assert.Equal(t, model.EmailVerification{TokenHash: stored.TokenHash, UserID: verifiedUser.ID, OrganizationID: verifiedUser.OrganizationID,
+		Email: "john.doe@example.com", ExpiresAt: now.Add(DefaultVerificationTTL), CreatedAt: now}, *stored)
+}
+
+func TestEmailVerification_Verify(t *testing.T) {
+	// Given: A token stored for the user's current address
+	mockRepo := new(MockEmailVerificationRepository)
+	users := new(MockUserRepository)
+	now := mailTestTime
+	svc := newTestVerificationService(mockRepo, users, &mail.MemoryMailer{}, &now)
+	verified := verifiedUser
+	verified.EmailVerifiedAt = &now
+	mockRepo.On("Consume", hashMailToken("the-token")).Return(&model.EmailVerification{
+		UserID: verifiedUser.ID, OrganizationID: verifiedUser.OrganizationID, Email: verifiedUser.Email, ExpiresAt: now.Add(time.Second),
+	}, nil)
+	users.On("MarkEmailVerified", verifiedUser.ID, verifiedUser.Email, now).Return(nil)
+	users.On("GetByID", verifiedUser.ID, model.UserFields(nil)).Return(&verified, nil)
+
+	// When: Verifying it, with spaces around as pasted
+	user, err := svc.Verify(" the-token ")
+
+	// Then: The address is marked verified as of now
+	require.NoError(t, err)
+	assert.Equal(t, &verified, user)
+	mockRepo.AssertExpectations(t)
+	users.AssertExpectations(t)
+}
+
+func TestEmailVerification_VerifyInvalidToken(t *testing.T) {
+	mockRepo := new(MockEmailVerificationRepository)
+	users := new(MockUserRepository)
+	now := mailTestTime
+	svc := newTestVerificationService(mockRepo, users, &mail.MemoryMailer{}, &now)
+	mockRepo.On("Consume", hashMailToken("not-a-token")).Return(nil, errors.ErrVerificationTokenInvalid)
+
+	_, err := svc.Verify("not-a-token")
+
+	assert.ErrorIs(t, err, errors.ErrVerificationTokenInvalid)
+	users.AssertNotCalled(t, "MarkEmailVerified", mock.Anything, mock.Anything, mock.Anything)
+}
+
+func TestEmailVerification_VerifyExpired(t *testing.T) {
+	mockRepo := new(MockEmailVerificationRepository)
+	users := new(MockUserRepository)
+	now := mailTestTime
+	svc := newTestVerificationService(mockRepo, users, &mail.MemoryMailer{}, &now)
+	mockRepo.On("Consume", hashMailToken("the-token")).Return(&model.EmailVerification{
+		UserID: verifiedUser.ID, Email: verifiedUser.Email, ExpiresAt: now,
+	}, nil)
+
+	_, err := svc.Verify("the-token")
+
+	assert.ErrorIs(t, err, errors.ErrVerificationTokenInvalid, "expired at the instant")
+	users.AssertNotCalled(t, "MarkEmailVerified", mock.Anything, mock.Anything, mock.Anything)
+}
+
+func TestEmailVerification_VerifyLeftAddress(t *testing.T) {
+	// Given: A token for an address the user has since left
+	mockRepo := new(MockEmailVerificationRepository)
+	users := new(MockUserRepository)
+	now := mailTestTime
+	svc := newTestVerificationService(mockRepo, users, &mail.MemoryMailer{}, &now)
+	mockRepo.On("Consume", hashMailToken("the-token")).Return(&model.EmailVerification{
+		UserID: verifiedUser.ID, Email: "old@example.com", ExpiresAt: now.Add(time.Hour),
+	}, nil)
+	users.On("MarkEmailVerified", verifiedUser.ID, "old@example.com", now).Return(errors.ErrUserNotFound)
+
+	// When: Verifying it
+	_, err := svc.Verify("the-token")
+
+	// Then: It does not verify the new one
+	assert.ErrorIs(t, err, errors.ErrVerificationTokenInvalid)
+	users.AssertNotCalled(t, "GetByID", mock.Anything, mock.Anything)
+}
+
+func TestEmailVerification_Resend(t *testing.T) {
+	mockRepo := new(MockEmailVerificationRepository)
+	users := new(MockUserRepository)
+	mailer := &mail.MemoryMailer{}
+	now := mailTestTime
+	svc := newTestVerificationService(mockRepo, users, mailer, &now)
+	verified := verifiedUser
+	verified.ID = uuid.New()
+	verified.EmailVerifiedAt = &now
+	users.On("GetByID", verifiedUser.ID, model.UserFields(nil)).Return(&verifiedUser, nil)
+	users.On("GetByID", verified.ID, model.UserFields(nil)).Return(&verified, nil)
+	mockRepo.On("Create", mock.Anything).Return(nil)
+
+	require.NoError(t, svc.Resend(verifiedUser.ID))
+	assert.Len(t, mailer.Messages(), 1)
+	assert.ErrorIs(t, svc.Resend(verified.ID), errors.ErrEmailAlreadyVerified)
+	mockRepo.AssertNumberOfCalls(t, "Create", 1)
+}
+
+func TestEmailVerification_PruneExpired(t *testing.T) {
+	mockRepo := new(MockEmailVerificationRepository)
+	now := mailTestTime
+	svc := newTestVerificationService(mockRepo, new(MockUserRepository), &mail.MemoryMailer{}, &now)
+	mockRepo.On("PruneExpired", now).Return(int64(1), nil)
+
+	n, err := svc.PruneExpired()
+
+	require.NoError(t, err)
+	assert.Equal(t, int64(1), n)
+}
+
+func TestWithEmailVerification_SendsForNewAddresses(t *testing.T) {
+	// Given: A user service that mails verification tokens
+	users := new(MockUserRepository)
+	mockRepo := new(MockEmailVerificationRepository)
+	mailer := &mail.MemoryMailer{}
+	now := mailTestTime
+	svc := WithEmailVerification(NewUserService(users), newTestVerificationService(mockRepo, users, mailer, &now),
+		slog.New(slog.NewTextHandler(io.Discard, nil)))
+	mockRepo.On("Create", mock.Anything).Return(nil)
+	users.On("Create", mock.Anything).Return(&verifiedUser, nil)
+	users.On("GetByID", verifiedUser.ID, model.UserFields{"email"}).Return(&verifiedUser, nil)
+	renamed := verifiedUser
+	renamed.FullName = "Johnny Doe"
+	moved := verifiedUser
+	moved.Email = "johnny@example.com"
+	users.On("Update", verifiedUser.ID, mock.MatchedBy(func(req *model.UpdateUserRequest) bool { return req.Email == nil })).Return(&renamed, nil)
+	users.On("Update", verifiedUser.ID, mock.MatchedBy(func(req *model.UpdateUserRequest) bool {
+		return req.Email != nil && *req.Email == verifiedUser.Email
+	})).Return(&verifiedUser, nil)
+	users.On("Update", verifiedUser.ID, mock.MatchedBy(func(req *model.UpdateUserRequest) bool {
+		return req.Email != nil && *req.Email == moved.Email
+	})).Return(&moved, nil)
+
+	// When: Creating a user, then changing their name, setting the same address and a new one
+	_, err := svc.Create(&model.CreateUserRequest{Username: "jdoe", Email: "john.doe@example.com", FullName: "John Doe"})
+	require.NoError(t, err)
+	_, err = svc.Update(verifiedUser.ID, &model.UpdateUserRequest{FullName: &renamed.FullName})
+	require.NoError(t, err)
+	_, err = svc.Update(verifiedUser.ID, &model.UpdateUserRequest{Email: &verifiedUser.Email})
+	require.NoError(t, err)
+	_, err = svc.Update(verifiedUser.ID, &model.UpdateUserRequest{Email: &moved.Email})
+	require.NoError(t, err)
+
+	// Then: Only the new user and the new address are mailed
+	msgs := mailer.Messages()
+	require.Len(t, msgs, 2)
+	assert.Equal(t, "john.doe@example.com", msgs[0].To)
+	assert.Equal(t, "johnny@example.com", msgs[1].To)
+	mockRepo.AssertNumberOfCalls(t, "Create", 2)
+}
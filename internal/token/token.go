@@ -0,0 +1,292 @@
+// Package token signs and verifies the short-lived access tokens handed out in exchange for API keys.
+//
+// Tokens are compact JWS (RFC 7515) JWTs signed with ES256. Each signing key has a key ID that goes
+// in the token header as kid; a KeySet verifies tokens from every key it holds but signs only with the
+// active one, so a new key can be rolled out, and the old one dropped once its tokens have expired.
+// The public keys are published as a JWK Set (RFC 7517) for other services to verify tokens offline.
+package token
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/json"
+	"encoding/pem"
+	"fmt"
+	"math/big"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	stdErrors "errors"
+)
+
+// Algorithm is the only JWS algorithm issued and accepted.
+const Algorithm = "ES256"
+
+var (
+	// ErrInvalidToken is returned for a token that is malformed, signed with an unknown key or
+	// not signed by the key it names.
+	ErrInvalidToken = stdErrors.New("invalid access token")
+	// ErrExpired is returned for a correctly signed token past its expiry.
+	ErrExpired = stdErrors.New("access token has expired")
+)
+
+// Claims are the JWT claims of an access token.
+type Claims struct {
+	Issuer  string `json:"iss"`
+	Subject string `json:"sub"`
+	// Audience is the OAuth client a user's access token was issued to; empty for API clients' tokens
+	Audience string `json:"aud,omitempty"`
+	// Scope is the space-separated list of granted scopes (RFC 8693 style)
+	Scope     string `json:"scope"`
+	IssuedAt  int64  `json:"iat"`
+	ExpiresAt int64  `json:"exp"`
+	ID        string `json:"jti"`
+	// Organization is the slug of the API client's organization; empty for the default one
+	Organization string `json:"org,omitempty"`
+}
+
+// Scopes splits Scope.
+func (c *Claims) Scopes() []string {
+	return strings.Fields(c.Scope)
+}
+
+type header struct {
+	Alg string `json:"alg"`
+	Typ string `json:"typ"`
+	Kid string `json:"kid"`
+}
+
+type key struct {
+	id      string
+	public  *ecdsa.PublicKey
+	private *ecdsa.PrivateKey
+}
+
+// KeySet holds the signing keys by key ID.
+type KeySet struct {
+	keys   map[string]*key
+	active *key
+}
+
+// GenerateKeySet returns a KeySet with one freshly generated key. Its tokens stop verifying when the
+// process exits and are not accepted by other replicas, so it only suits development and tests.
+func GenerateKeySet() (*KeySet, error) {
+	private, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		return nil, fmt.Errorf("failed to generate signing key: %w", err)
+	}
+	k := &key{public: &private.PublicKey, private: private}
+	k.id = thumbprint(k.public)
+	return &KeySet{keys: map[string]*key{k.id: k}, active: k}, nil
+}
+
+// LoadKeySet reads every *.pem file in dir as a P-256 key whose key ID is the file name without the
+// extension. Private keys (PKCS#8 or SEC 1) can sign; public keys (PKIX) only verify, which is how a
+// retired key is kept around until its last tokens expire. activeID selects the signing key; when
+// empty, the private key with the greatest ID signs, so naming keys by date rotates them.
+func LoadKeySet(dir, activeID string) (*KeySet, error) {
+	paths, err := filepath.Glob(filepath.Join(dir, "*.pem"))
+	if err != nil {
+		return nil, err
+	}
+	sort.Strings(paths)
+
+	ks := &KeySet{keys: make(map[string]*key, len(paths))}
+	for _, path := range paths {
+		data, err := os.ReadFile(path)
+		if err != nil {
+			return nil, err
+		}
+		k, err := parseKey(data)
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w", path, err)
+		}
+		k.id = strings.TrimSuffix(filepath.Base(path), ".pem")
+		ks.keys[k.id] = k
+		if k.private != nil && activeID == "" {
+			ks.active = k
+		}
+	}
+
+	if activeID != "" {
+		ks.active = ks.keys[activeID]
+		if ks.active == nil {
+			return nil, fmt.Errorf("signing key %q not found in %s", activeID, dir)
+		}
+	}
+	if ks.active == nil || ks.active.private == nil {
+		return nil, fmt.Errorf("no private signing key in %s", dir)
+	}
+	return ks, nil
+}
+
+func parseKey(data []byte) (*key, error) {
+	block, _ := pem.Decode(data)
+	if block == nil {
+		return nil, stdErrors.New("no PEM block found")
+	}
+
+	var parsed any
+	var err error
+	switch block.Type {
+	case "EC PRIVATE KEY":
+		parsed, err = x509.ParseECPrivateKey(block.Bytes)
+	case "PRIVATE KEY":
+		parsed, err = x509.ParsePKCS8PrivateKey(block.Bytes)
+	case "PUBLIC KEY":
+		parsed, err = x509.ParsePKIXPublicKey(block.Bytes)
+	default:
+		return nil, fmt.Errorf("unsupported PEM block %q", block.Type)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	switch k := parsed.(type) {
+	case *ecdsa.PrivateKey:
+		if k.Curve != elliptic.P256() {
+			return nil, stdErrors.New("key is not on the P-256 curve")
+		}
+		return &key{public: &k.PublicKey, private: k}, nil
+	case *ecdsa.PublicKey:
+		if k.Curve != elliptic.P256() {
+			return nil, stdErrors.New("key is not on the P-256 curve")
+		}
+		return &key{public: k}, nil
+	default:
+		return nil, fmt.Errorf("unsupported key type %T", parsed)
+	}
+}
+
+// ActiveKeyID returns the ID of the key new tokens are signed with.
+func (ks *KeySet) ActiveKeyID() string {
+	return ks.active.id
+}
+
+// Sign returns the compact serialization of claims signed with the active key.
+func (ks *KeySet) Sign(claims Claims) (string, error) {
+	return ks.SignJSON(claims)
+}
+
+// SignJSON is Sign for any JSON-encodable claims set, such as an OpenID Connect ID token.
+func (ks *KeySet) SignJSON(claims any) (string, error) {
+	h, err := json.Marshal(header{Alg: Algorithm, Typ: "JWT", Kid: ks.active.id})
+	if err != nil {
+		return "", err
+	}
+	c, err := json.Marshal(claims)
+	if err != nil {
+		return "", err
+	}
+	signingInput := encodeSegment(h) + "." + encodeSegment(c)
+
+	digest := sha256.Sum256([]byte(signingInput))
+	r, s, err := ecdsa.Sign(rand.Reader, ks.active.private, digest[:])
+	if err != nil {
+		return "", fmt.Errorf("failed to sign token: %w", err)
+	}
+	// JWS ES256 signatures are the fixed-width big-endian R and S, not ASN.1
+	sig := make([]byte, 64)
+	r.FillBytes(sig[:32])
+	s.FillBytes(sig[32:])
+	return signingInput + "." + encodeSegment(sig), nil
+}
+
+// Verify checks the signature and expiry of token at now and returns its claims.
+func (ks *KeySet) Verify(token string, now time.Time) (*Claims, error) {
+	parts := strings.Split(token, ".")
+	if len(parts) != 3 {
+		return nil, ErrInvalidToken
+	}
+
+	var h header
+	if err := decodeSegment(parts[0], &h); err != nil || h.Alg != Algorithm {
+		return nil, ErrInvalidToken
+	}
+	k := ks.keys[h.Kid]
+	if k == nil {
+		return nil, ErrInvalidToken
+	}
+
+	sig, err := base64.RawURLEncoding.DecodeString(parts[2])
+	if err != nil || len(sig) != 64 {
+		return nil, ErrInvalidToken
+	}
+	digest := sha256.Sum256([]byte(parts[0] + "." + parts[1]))
+	r, s := new(big.Int).SetBytes(sig[:32]), new(big.Int).SetBytes(sig[32:])
+	if !ecdsa.Verify(k.public, digest[:], r, s) {
+		return nil, ErrInvalidToken
+	}
+
+	var claims Claims
+	if err := decodeSegment(parts[1], &claims); err != nil {
+		return nil, ErrInvalidToken
+	}
+	if now.Unix() >= claims.ExpiresAt {
+		return nil, ErrExpired
+	}
+	return &claims, nil
+}
+
+// JWK is the public half of a signing key as a JSON Web Key.
+type JWK struct {
+	Kty string `json:"kty"`
+	Crv string `json:"crv"`
+	X   string `json:"x"`
+	Y   string `json:"y"`
+	Kid string `json:"kid"`
+	Use string `json:"use"`
+	Alg string `json:"alg"`
+}
+
+// JWKS is a JSON Web Key Set.
+type JWKS struct {
+	Keys []JWK `json:"keys"`
+}
+
+// JWKS returns every public key in the set, sorted by key ID.
+func (ks *KeySet) JWKS() JWKS {
+	set := JWKS{Keys: make([]JWK, 0, len(ks.keys))}
+	for _, k := range ks.keys {
+		set.Keys = append(set.Keys, JWK{
+			Kty: "EC",
+			Crv: "P-256",
+			X:   encodeSegment(k.public.X.FillBytes(make([]byte, 32))),
+			Y:   encodeSegment(k.public.Y.FillBytes(make([]byte, 32))),
+			Kid: k.id,
+			Use: "sig",
+			Alg: Algorithm,
+		})
+	}
+	sort.Slice(set.Keys, func(i, j int) bool { return set.Keys[i].Kid < set.Keys[j].Kid })
+	return set
+}
+
+// thumbprint is the RFC 7638 JWK thumbprint, a stable ID for a generated key.
+func thumbprint(pub *ecdsa.PublicKey) string {
+	// Members in lexicographic order, no whitespace, as the RFC requires
+	canonical := fmt.Sprintf(`{"crv":"P-256","kty":"EC","x":"%s","y":"%s"}`,
+		encodeSegment(pub.X.FillBytes(make([]byte, 32))),
+		encodeSegment(pub.Y.FillBytes(make([]byte, 32))))
+	sum := sha256.Sum256([]byte(canonical))
+	return encodeSegment(sum[:])
+}
+
+func encodeSegment(b []byte) string {
+	return base64.RawURLEncoding.EncodeToString(b)
+}
+
+func decodeSegment(seg string, v any) error {
+	b, err := base64.RawURLEncoding.DecodeString(seg)
+	if err != nil {
+		return err
+	}
+	return json.Unmarshal(b, v)
+}
@@ -0,0 +1,126 @@
+package token
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/x509"
+	"encoding/pem"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func testClaims(now time.Time) Claims {
+	return Claims{
+		Issuer:    "cruder",
+		Subject:   "ci",
+		Scope:     "users:read users:write",
+		IssuedAt:  now.Unix(),
+		ExpiresAt: now.Add(time.Minute).Unix(),
+		ID:        "token-1",
+	}
+}
+
+// writeKey writes a fresh P-256 key to dir/<kid>.pem, as a public key only if public is set.
+func writeKey(t *testing.T, dir, kid string, public bool) {
+	t.Helper()
+	private, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	require.NoError(t, err)
+
+	block := &pem.Block{Type: "PRIVATE KEY"}
+	if public {
+		block.Type = "PUBLIC KEY"
+		block.Bytes, err = x509.MarshalPKIXPublicKey(&private.PublicKey)
+	} else {
+		block.Bytes, err = x509.MarshalPKCS8PrivateKey(private)
+	}
+	require.NoError(t, err)
+	require.NoError(t, os.WriteFile(filepath.Join(dir, kid+".pem"), pem.EncodeToMemory(block), 0o600))
+}
+
+func TestKeySet_SignAndVerify(t *testing.T) {
+	ks, err := GenerateKeySet()
+	require.NoError(t, err)
+	now := time.Now()
+
+	signed, err := ks.Sign(testClaims(now))
+	require.NoError(t, err)
+	assert.Len(t, strings.Split(signed, "."), 3)
+
+	claims, err := ks.Verify(signed, now)
+	require.NoError(t, err)
+	assert.Equal(t, "ci", claims.Subject)
+	assert.Equal(t, []string{"users:read", "users:write"}, claims.Scopes())
+
+	_, err = ks.Verify(signed, now.Add(time.Minute))
+	assert.ErrorIs(t, err, ErrExpired)
+
+	// Swapping in other claims keeps the header and signature but breaks the signature check
+	parts := strings.Split(signed, ".")
+	forged := testClaims(now)
+	forged.Scope = "admin"
+	other, err := ks.Sign(forged)
+	require.NoError(t, err)
+	_, err = ks.Verify(parts[0]+"."+strings.Split(other, ".")[1]+"."+parts[2], now)
+	assert.ErrorIs(t, err, ErrInvalidToken)
+
+	another, err := GenerateKeySet()
+	require.NoError(t, err)
+	_, err = another.Verify(signed, now)
+	assert.ErrorIs(t, err, ErrInvalidToken, "unknown kid")
+
+	for _, bad := range []string{"", "a.b", "a.b.c", "eyJhbGciOiJub25lIn0.e30."} {
+		_, err = ks.Verify(bad, now)
+		assert.ErrorIs(t, err, ErrInvalidToken, bad)
+	}
+}
+
+func TestLoadKeySet_Rotation(t *testing.T) {
+	dir := t.TempDir()
+	writeKey(t, dir, "2026-01", false)
+	writeKey(t, dir, "2026-02", false)
+	now := time.Now()
+
+	old, err := LoadKeySet(dir, "2026-01")
+	require.NoError(t, err)
+	assert.Equal(t, "2026-01", old.ActiveKeyID())
+	signedOld, err := old.Sign(testClaims(now))
+	require.NoError(t, err)
+
+	// Without an explicit ID the newest key signs; tokens from the previous key still verify
+	current, err := LoadKeySet(dir, "")
+	require.NoError(t, err)
+	assert.Equal(t, "2026-02", current.ActiveKeyID())
+	_, err = current.Verify(signedOld, now)
+	assert.NoError(t, err)
+
+	// Retiring the old key to its public half keeps verifying, and it can no longer be made active
+	require.NoError(t, os.Remove(filepath.Join(dir, "2026-01.pem")))
+	writeKey(t, dir, "2026-01", true)
+	_, err = LoadKeySet(dir, "2026-01")
+	assert.Error(t, err)
+	retired, err := LoadKeySet(dir, "")
+	require.NoError(t, err)
+	assert.Equal(t, "2026-02", retired.ActiveKeyID())
+
+	jwks := retired.JWKS()
+	require.Len(t, jwks.Keys, 2)
+	assert.Equal(t, "2026-01", jwks.Keys[0].Kid)
+	assert.Equal(t, "2026-02", jwks.Keys[1].Kid)
+	for _, k := range jwks.Keys {
+		assert.Equal(t, "EC", k.Kty)
+		assert.Equal(t, "ES256", k.Alg)
+		assert.Len(t, k.X, 43, "32 bytes, base64url without padding")
+	}
+
+	_, err = LoadKeySet(t.TempDir(), "")
+	assert.Error(t, err, "empty directory")
+	_, err = LoadKeySet(dir, "2027-01")
+	assert.Error(t, err, "unknown active key")
+}
@@ -0,0 +1,22 @@
+-- +goose Up
+-- +goose StatementBegin
+-- The initial schema migration inserts demo accounts, so they end up in every environment. It is left as it was
+-- applied; seed data now lives in seeds/ and is loaded with `cruder seed`, and this removes the copies.
+DELETE FROM users
+WHERE (username, email, full_name) IN (
+    ('jdoe', 'jdoe@example.com', 'John Doe'),
+    ('asmith', 'asmith@example.com', 'Alice Smith'),
+    ('bjones', 'bjones@example.com', 'Bob Jones')
+);
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+-- Restores the rows as the initial migration created them, so rolling back leaves the schema history consistent.
+-- Users who took one of the usernames or addresses since keep it.
+INSERT INTO users (username, email, full_name) VALUES
+('jdoe', 'jdoe@example.com', 'John Doe'),
+('asmith', 'asmith@example.com', 'Alice Smith'),
+('bjones', 'bjones@example.com', 'Bob Jones')
+ON CONFLICT DO NOTHING;
+-- +goose StatementEnd
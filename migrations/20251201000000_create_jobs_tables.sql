@@ -0,0 +1,44 @@
+-- +goose Up
+-- +goose StatementBegin
+-- Background jobs for operations that outlive an HTTP request (exports, imports, ...).
+-- Workers on any replica claim queued rows with FOR UPDATE SKIP LOCKED and keep heartbeat_at fresh;
+-- a running job whose heartbeat stops (pod killed) is claimed again by another worker.
+CREATE TABLE IF NOT EXISTS jobs (
+    id UUID PRIMARY KEY DEFAULT uuid_generate_v4(),
+    kind VARCHAR(50) NOT NULL,
+    status VARCHAR(20) NOT NULL DEFAULT 'queued'
+        CHECK (status IN ('queued', 'running', 'succeeded', 'failed', 'cancelled')),
+    params JSONB NOT NULL DEFAULT '{}',
+    input BYTEA,
+    progress_done BIGINT NOT NULL DEFAULT 0,
+    progress_total BIGINT,
+    error TEXT,
+    result_type VARCHAR(100),
+    attempts INT NOT NULL DEFAULT 0,
+    cancel_requested BOOLEAN NOT NULL DEFAULT FALSE,
+    locked_by VARCHAR(100),
+    heartbeat_at TIMESTAMP,
+    created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+    started_at TIMESTAMP,
+    finished_at TIMESTAMP,
+    updated_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP
+);
+
+-- The claim query scans only unfinished jobs
+CREATE INDEX idx_jobs_pending ON jobs(created_at) WHERE status IN ('queued', 'running');
+
+-- Job output (e.g. an export file) in chunks, so neither the worker nor a download holds it all in memory
+CREATE TABLE IF NOT EXISTS job_results (
+    job_id UUID NOT NULL REFERENCES jobs(id) ON DELETE CASCADE,
+    seq INT NOT NULL,
+    data BYTEA NOT NULL,
+    PRIMARY KEY (job_id, seq)
+);
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+DROP TABLE IF EXISTS job_results;
+DROP INDEX IF EXISTS idx_jobs_pending;
+DROP TABLE IF EXISTS jobs;
+-- +goose StatementEnd
@@ -0,0 +1,26 @@
+-- +goose Up
+-- +goose StatementBegin
+-- History of scheduled maintenance task runs. Every replica runs the scheduler; the unique
+-- (task, scheduled_at) row is how one of them claims a slot, so each slot runs once cluster-wide.
+CREATE TABLE IF NOT EXISTS task_runs (
+    id UUID PRIMARY KEY DEFAULT uuid_generate_v4(),
+    task VARCHAR(100) NOT NULL,
+    scheduled_at TIMESTAMP NOT NULL,
+    node VARCHAR(100) NOT NULL,
+    status VARCHAR(20) NOT NULL DEFAULT 'running'
+        CHECK (status IN ('running', 'succeeded', 'failed')),
+    error TEXT,
+    started_at TIMESTAMP NOT NULL,
+    finished_at TIMESTAMP,
+    duration_ms BIGINT,
+    UNIQUE (task, scheduled_at)
+);
+
+CREATE INDEX idx_task_runs_started_at ON task_runs(started_at DESC);
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+DROP INDEX IF EXISTS idx_task_runs_started_at;
+DROP TABLE IF EXISTS task_runs;
+-- +goose StatementEnd
@@ -0,0 +1,12 @@
+-- +goose Up
+-- +goose StatementBegin
+-- Optional login password per user, as an Argon2id hash in PHC string format; NULL means none set
+ALTER TABLE users ADD COLUMN IF NOT EXISTS password_hash TEXT;
+ALTER TABLE users ADD COLUMN IF NOT EXISTS password_changed_at TIMESTAMP;
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+ALTER TABLE users DROP COLUMN IF EXISTS password_changed_at;
+ALTER TABLE users DROP COLUMN IF EXISTS password_hash;
+-- +goose StatementEnd
@@ -0,0 +1,16 @@
+-- +goose Up
+-- +goose StatementBegin
+-- Access tokens are verified offline, so revoking them means rejecting every token of a subject
+-- issued before a point in time. The empty subject applies to every client's tokens. Rows only
+-- ever move issued_before forward and are small enough to be cached whole by each replica.
+CREATE TABLE IF NOT EXISTS token_revocations (
+    subject VARCHAR(100) PRIMARY KEY,
+    issued_before TIMESTAMP NOT NULL,
+    updated_at TIMESTAMP NOT NULL
+);
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+DROP TABLE IF EXISTS token_revocations;
+-- +goose StatementEnd
@@ -0,0 +1,25 @@
+-- +goose Up
+-- +goose StatementBegin
+-- When the user proved they receive mail at their current address; NULL until then and again
+-- after every email change.
+ALTER TABLE users ADD COLUMN IF NOT EXISTS email_verified_at TIMESTAMP;
+
+-- Outstanding verification tokens, stored as SHA-256 hashes. A token is bound to the address it
+-- was sent to, so it stops working once the user changes email again; each user has at most one.
+CREATE TABLE IF NOT EXISTS email_verifications (
+    token_hash BYTEA PRIMARY KEY,
+    user_id UUID NOT NULL UNIQUE REFERENCES users(id) ON DELETE CASCADE,
+    email VARCHAR(100) NOT NULL,
+    expires_at TIMESTAMP NOT NULL,
+    created_at TIMESTAMP NOT NULL
+);
+
+CREATE INDEX idx_email_verifications_expires_at ON email_verifications(expires_at);
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+DROP INDEX IF EXISTS idx_email_verifications_expires_at;
+DROP TABLE IF EXISTS email_verifications;
+ALTER TABLE users DROP COLUMN IF EXISTS email_verified_at;
+-- +goose StatementEnd
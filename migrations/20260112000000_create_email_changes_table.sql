@@ -0,0 +1,32 @@
+-- +goose Up
+-- +goose StatementBegin
+-- Requested email changes. The user keeps old_email until the token mailed to new_email is
+-- confirmed; the revert token mailed to old_email cancels the change, or undoes it once confirmed,
+-- until revert_expires_at. Tokens are stored as SHA-256 hashes. A user has at most one unconfirmed
+-- change; confirmed ones are kept until their revert link expires.
+CREATE TABLE IF NOT EXISTS email_changes (
+    id UUID PRIMARY KEY DEFAULT uuid_generate_v4(),
+    user_id UUID NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    old_email VARCHAR(100) NOT NULL,
+    old_email_verified_at TIMESTAMP,
+    new_email VARCHAR(100) NOT NULL,
+    confirm_token_hash BYTEA NOT NULL UNIQUE,
+    revert_token_hash BYTEA NOT NULL UNIQUE,
+    confirm_expires_at TIMESTAMP NOT NULL,
+    revert_expires_at TIMESTAMP NOT NULL,
+    confirmed_at TIMESTAMP,
+    created_at TIMESTAMP NOT NULL
+);
+
+CREATE UNIQUE INDEX idx_email_changes_pending_user ON email_changes(user_id) WHERE confirmed_at IS NULL;
+CREATE INDEX idx_email_changes_pending_email ON email_changes(new_email) WHERE confirmed_at IS NULL;
+CREATE INDEX idx_email_changes_revert_expires_at ON email_changes(revert_expires_at);
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+DROP INDEX IF EXISTS idx_email_changes_revert_expires_at;
+DROP INDEX IF EXISTS idx_email_changes_pending_email;
+DROP INDEX IF EXISTS idx_email_changes_pending_user;
+DROP TABLE IF EXISTS email_changes;
+-- +goose StatementEnd
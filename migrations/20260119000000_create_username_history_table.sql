@@ -0,0 +1,24 @@
+-- +goose Up
+-- +goose StatementBegin
+-- Every rename of a user: old_username was given up for new_username at changed_at by actor
+-- (the API key or token subject, "cli" or "system"). Former usernames stay reserved for a
+-- quarantine period and resolve to their user, so old links keep working.
+CREATE TABLE IF NOT EXISTS username_history (
+    id UUID PRIMARY KEY DEFAULT uuid_generate_v4(),
+    user_id UUID NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    old_username VARCHAR(50) NOT NULL,
+    new_username VARCHAR(50) NOT NULL,
+    actor VARCHAR(255) NOT NULL,
+    changed_at TIMESTAMP NOT NULL
+);
+
+CREATE INDEX idx_username_history_user_id ON username_history(user_id, changed_at DESC);
+CREATE INDEX idx_username_history_old_username ON username_history(old_username, changed_at DESC);
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+DROP INDEX IF EXISTS idx_username_history_old_username;
+DROP INDEX IF EXISTS idx_username_history_user_id;
+DROP TABLE IF EXISTS username_history;
+-- +goose StatementEnd
@@ -0,0 +1,23 @@
+-- +goose Up
+-- +goose StatementBegin
+-- Wrong API keys per client IP, shared by every replica. failures counts the attempts since
+-- first_failed_at and starts over once none came for a while. blocked_until makes the client wait
+-- before its next attempt: briefly and growing with each failure (a delay), or for a long time
+-- once banned, until it expires or an admin lifts it.
+CREATE TABLE IF NOT EXISTS auth_failures (
+    ip VARCHAR(45) PRIMARY KEY,
+    failures INTEGER NOT NULL,
+    first_failed_at TIMESTAMP NOT NULL,
+    last_failed_at TIMESTAMP NOT NULL,
+    blocked_until TIMESTAMP,
+    banned BOOLEAN NOT NULL DEFAULT FALSE
+);
+
+CREATE INDEX idx_auth_failures_last_failed_at ON auth_failures(last_failed_at);
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+DROP INDEX IF EXISTS idx_auth_failures_last_failed_at;
+DROP TABLE IF EXISTS auth_failures;
+-- +goose StatementEnd
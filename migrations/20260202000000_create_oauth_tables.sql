@@ -0,0 +1,48 @@
+-- +goose Up
+-- +goose StatementBegin
+-- Applications that sign users in through the built-in OpenID Connect provider. Public clients
+-- (single-page and native apps) have no secret; confidential ones store only its SHA-256.
+CREATE TABLE IF NOT EXISTS oauth_clients (
+    id VARCHAR(64) PRIMARY KEY,
+    name VARCHAR(100) NOT NULL,
+    public BOOLEAN NOT NULL,
+    redirect_uris TEXT[] NOT NULL,
+    secret_hash BYTEA,
+    created_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP
+);
+
+-- The scopes each user allowed each client; deleting a row revokes the client's access.
+CREATE TABLE IF NOT EXISTS oauth_consents (
+    user_id UUID NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    client_id VARCHAR(64) NOT NULL REFERENCES oauth_clients(id) ON DELETE CASCADE,
+    scope VARCHAR(255) NOT NULL,
+    granted_at TIMESTAMP NOT NULL,
+    updated_at TIMESTAMP NOT NULL,
+    PRIMARY KEY (user_id, client_id)
+);
+
+-- Authorization codes awaiting exchange. A code is deleted when it is exchanged, so it works once.
+CREATE TABLE IF NOT EXISTS oauth_authorization_codes (
+    code_hash BYTEA PRIMARY KEY,
+    client_id VARCHAR(64) NOT NULL REFERENCES oauth_clients(id) ON DELETE CASCADE,
+    user_id UUID NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    redirect_uri TEXT NOT NULL,
+    scope VARCHAR(255) NOT NULL,
+    nonce VARCHAR(255) NOT NULL,
+    code_challenge VARCHAR(64) NOT NULL,
+    auth_time TIMESTAMP NOT NULL,
+    expires_at TIMESTAMP NOT NULL
+);
+
+CREATE INDEX idx_oauth_consents_client_id ON oauth_consents(client_id);
+CREATE INDEX idx_oauth_authorization_codes_expires_at ON oauth_authorization_codes(expires_at);
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+DROP INDEX IF EXISTS idx_oauth_authorization_codes_expires_at;
+DROP INDEX IF EXISTS idx_oauth_consents_client_id;
+DROP TABLE IF EXISTS oauth_authorization_codes;
+DROP TABLE IF EXISTS oauth_consents;
+DROP TABLE IF EXISTS oauth_clients;
+-- +goose StatementEnd
@@ -0,0 +1,62 @@
+-- +goose Up
+-- +goose StatementBegin
+-- Tenants. Every user belongs to one, and usernames and email addresses are unique within it.
+-- Existing users, and rows written by replicas that predate this migration, go to the default
+-- organization, whose ID is fixed in the code (model.DefaultOrganizationID).
+CREATE TABLE IF NOT EXISTS organizations (
+    id UUID PRIMARY KEY DEFAULT uuid_generate_v4(),
+    slug VARCHAR(50) NOT NULL UNIQUE,
+    name VARCHAR(100) NOT NULL,
+    created_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP
+);
+
+INSERT INTO organizations (id, slug, name)
+VALUES ('00000000-0000-0000-0000-000000000001', 'default', 'Default')
+ON CONFLICT DO NOTHING;
+
+ALTER TABLE users
+    ADD COLUMN organization_id UUID NOT NULL DEFAULT '00000000-0000-0000-0000-000000000001' REFERENCES organizations(id);
+
+-- The constraint names keep "username" and "email", which the repository maps to ErrUsernameExists
+-- and ErrEmailExists
+ALTER TABLE users DROP CONSTRAINT users_username_key;
+ALTER TABLE users DROP CONSTRAINT users_email_key;
+ALTER TABLE users ADD CONSTRAINT users_organization_username_key UNIQUE (organization_id, username);
+ALTER TABLE users ADD CONSTRAINT users_organization_email_key UNIQUE (organization_id, email);
+-- Every lookup is within an organization now, which the unique indexes above serve
+DROP INDEX IF EXISTS idx_users_username;
+DROP INDEX IF EXISTS idx_users_email;
+
+-- Token rows carry their user's organization, so redeeming a token knows where to look for the user
+ALTER TABLE email_verifications
+    ADD COLUMN organization_id UUID NOT NULL DEFAULT '00000000-0000-0000-0000-000000000001' REFERENCES organizations(id);
+ALTER TABLE email_changes
+    ADD COLUMN organization_id UUID NOT NULL DEFAULT '00000000-0000-0000-0000-000000000001' REFERENCES organizations(id);
+DROP INDEX IF EXISTS idx_email_changes_pending_email;
+CREATE INDEX idx_email_changes_pending_email ON email_changes(organization_id, new_email) WHERE confirmed_at IS NULL;
+
+-- OAuth clients sign in the users of the organization that registered them
+ALTER TABLE oauth_clients
+    ADD COLUMN organization_id UUID NOT NULL DEFAULT '00000000-0000-0000-0000-000000000001' REFERENCES organizations(id);
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+ALTER TABLE oauth_clients DROP COLUMN IF EXISTS organization_id;
+
+DROP INDEX IF EXISTS idx_email_changes_pending_email;
+CREATE INDEX idx_email_changes_pending_email ON email_changes(new_email) WHERE confirmed_at IS NULL;
+ALTER TABLE email_changes DROP COLUMN IF EXISTS organization_id;
+ALTER TABLE email_verifications DROP COLUMN IF EXISTS organization_id;
+
+-- Fails if two organizations have a user with the same username or email; rename those first
+ALTER TABLE users DROP CONSTRAINT users_organization_email_key;
+ALTER TABLE users DROP CONSTRAINT users_organization_username_key;
+ALTER TABLE users ADD CONSTRAINT users_username_key UNIQUE (username);
+ALTER TABLE users ADD CONSTRAINT users_email_key UNIQUE (email);
+CREATE INDEX idx_users_username ON users(username);
+CREATE INDEX idx_users_email ON users(email);
+ALTER TABLE users DROP COLUMN IF EXISTS organization_id;
+
+DROP TABLE IF EXISTS organizations;
+-- +goose StatementEnd
@@ -0,0 +1,53 @@
+-- +goose Up
+-- +goose StatementBegin
+-- Named sets of users within an organization, e.g. teams. Group names are unique per organization.
+CREATE TABLE IF NOT EXISTS groups (
+    id UUID PRIMARY KEY DEFAULT uuid_generate_v4(),
+    organization_id UUID NOT NULL DEFAULT '00000000-0000-0000-0000-000000000001' REFERENCES organizations(id),
+    name VARCHAR(100) NOT NULL,
+    description VARCHAR(500) NOT NULL DEFAULT '',
+    created_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    updated_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    CONSTRAINT groups_organization_name_key UNIQUE (organization_id, name)
+);
+
+-- A member is either a user or a nested group, whose members are then effective members too.
+-- The repository keeps the nesting acyclic; deleting a user or group drops its memberships.
+CREATE TABLE IF NOT EXISTS group_members (
+    group_id UUID NOT NULL REFERENCES groups(id) ON DELETE CASCADE,
+    user_id UUID REFERENCES users(id) ON DELETE CASCADE,
+    member_group_id UUID REFERENCES groups(id) ON DELETE CASCADE,
+    added_by VARCHAR(255) NOT NULL,
+    added_at TIMESTAMP NOT NULL,
+    CONSTRAINT group_members_one_member CHECK ((user_id IS NULL) <> (member_group_id IS NULL))
+);
+
+CREATE UNIQUE INDEX group_members_user_key ON group_members(group_id, user_id) WHERE user_id IS NOT NULL;
+CREATE UNIQUE INDEX group_members_group_key ON group_members(group_id, member_group_id) WHERE member_group_id IS NOT NULL;
+-- The groups a user or group is a direct member of
+CREATE INDEX idx_group_members_user_id ON group_members(user_id) WHERE user_id IS NOT NULL;
+CREATE INDEX idx_group_members_member_group_id ON group_members(member_group_id) WHERE member_group_id IS NOT NULL;
+
+-- Every membership change: member_id (a user or group, per member_type) was added to or removed
+-- from group_id at changed_at by actor. member_name is the username or group name at the time,
+-- so the entry stays readable after the member is deleted.
+CREATE TABLE IF NOT EXISTS group_membership_history (
+    id UUID PRIMARY KEY DEFAULT uuid_generate_v4(),
+    group_id UUID NOT NULL REFERENCES groups(id) ON DELETE CASCADE,
+    action VARCHAR(10) NOT NULL,
+    member_type VARCHAR(10) NOT NULL,
+    member_id UUID NOT NULL,
+    member_name VARCHAR(100) NOT NULL,
+    actor VARCHAR(255) NOT NULL,
+    changed_at TIMESTAMP NOT NULL
+);
+
+CREATE INDEX idx_group_membership_history_group_id ON group_membership_history(group_id, changed_at DESC);
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+DROP TABLE IF EXISTS group_membership_history;
+DROP TABLE IF EXISTS group_members;
+DROP TABLE IF EXISTS groups;
+-- +goose StatementEnd
@@ -0,0 +1,33 @@
+-- +goose Up
+-- +goose StatementBegin
+-- Account lifecycle: invited -> active <-> suspended, and any of them -> deactivated, which is final.
+-- Users who leave are deactivated rather than deleted, so their history stays. Existing users are active.
+ALTER TABLE users
+    ADD COLUMN status VARCHAR(20) NOT NULL DEFAULT 'active'
+    CONSTRAINT users_status_check CHECK (status IN ('invited', 'active', 'suspended', 'deactivated'));
+
+-- Serves listing by status, which pages by ID like the unfiltered list
+CREATE INDEX idx_users_organization_status ON users(organization_id, status, id);
+
+-- Every transition: the user went from old_status to new_status at changed_at, by actor (the API key
+-- or token subject, "cli" or "system") for reason
+CREATE TABLE IF NOT EXISTS user_status_history (
+    id UUID PRIMARY KEY DEFAULT uuid_generate_v4(),
+    user_id UUID NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    old_status VARCHAR(20) NOT NULL,
+    new_status VARCHAR(20) NOT NULL,
+    reason VARCHAR(500) NOT NULL DEFAULT '',
+    actor VARCHAR(255) NOT NULL,
+    changed_at TIMESTAMP NOT NULL
+);
+
+CREATE INDEX idx_user_status_history_user_id ON user_status_history(user_id, changed_at DESC);
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+DROP INDEX IF EXISTS idx_user_status_history_user_id;
+DROP TABLE IF EXISTS user_status_history;
+DROP INDEX IF EXISTS idx_users_organization_status;
+ALTER TABLE users DROP COLUMN IF EXISTS status;
+-- +goose StatementEnd
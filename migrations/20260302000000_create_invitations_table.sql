@@ -0,0 +1,28 @@
+-- +goose Up
+-- +goose StatementBegin
+-- Invitations to create an account with the address they were mailed to. The invitee picks a username
+-- and full name when redeeming the token, which is stored as a SHA-256 hash and renewed by every resend.
+-- An invitation is pending until it is redeemed, revoked or expires; the rows are kept as a record of
+-- who invited whom. invited_by is the API key or token subject, "cli" or "system".
+CREATE TABLE IF NOT EXISTS invitations (
+    id UUID PRIMARY KEY DEFAULT uuid_generate_v4(),
+    organization_id UUID NOT NULL DEFAULT '00000000-0000-0000-0000-000000000001' REFERENCES organizations(id),
+    email VARCHAR(100) NOT NULL,
+    token_hash BYTEA NOT NULL UNIQUE,
+    invited_by VARCHAR(255) NOT NULL,
+    expires_at TIMESTAMP NOT NULL,
+    sent_at TIMESTAMP NOT NULL,
+    created_at TIMESTAMP NOT NULL,
+    redeemed_at TIMESTAMP,
+    user_id UUID REFERENCES users(id) ON DELETE SET NULL,
+    revoked_at TIMESTAMP
+);
+
+CREATE INDEX idx_invitations_open ON invitations(organization_id, email) WHERE redeemed_at IS NULL AND revoked_at IS NULL;
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+DROP INDEX IF EXISTS idx_invitations_open;
+DROP TABLE IF EXISTS invitations;
+-- +goose StatementEnd
@@ -0,0 +1,10 @@
+// Package migrations embeds the SQL schema migrations into the server binary
+// so they can be applied without an external goose installation.
+package migrations
+
+import "embed"
+
+// FS holds every goose-formatted SQL migration in this directory.
+//
+//go:embed *.sql
+var FS embed.FS
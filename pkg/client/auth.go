@@ -0,0 +1,49 @@
+package client
+
+import (
+	"context"
+	"cruder/internal/model"
+	"net/http"
+	"net/url"
+
+	"github.com/google/uuid"
+)
+
+// AuthFailure is a client IP's record of wrong API keys, see ListBans.
+type AuthFailure = model.AuthFailure
+
+// Login checks a username or email and password and returns the user they belong to.
+// Any mismatch, including an unknown user, fails with ErrInvalidCredentials.
+func (c *Client) Login(ctx context.Context, login, password string) (*User, error) {
+	var user User
+	req := model.LoginRequest{Login: login, Password: password}
+	if _, err := c.do(ctx, http.MethodPost, "/api/v1/auth/login", nil, req, &user); err != nil {
+		return nil, err
+	}
+	return &user, nil
+}
+
+// ChangePassword sets a user's password. currentPassword may be empty only while the user has
+// none; a wrong one fails with ErrWrongPassword, a weak newPassword with ErrInvalidInput.
+func (c *Client) ChangePassword(ctx context.Context, id uuid.UUID, currentPassword, newPassword string) error {
+	req := model.ChangePasswordRequest{CurrentPassword: currentPassword, NewPassword: newPassword}
+	_, err := c.do(ctx, http.MethodPut, usersPath+"/id/"+id.String()+"/password", nil, req, nil)
+	return err
+}
+
+// ListBans returns the client IPs banned for presenting too many wrong API keys, latest failure
+// first. It needs the admin scope.
+func (c *Client) ListBans(ctx context.Context) ([]AuthFailure, error) {
+	var bans []AuthFailure
+	if _, err := c.do(ctx, http.MethodGet, "/api/v1/auth/bans", nil, nil, &bans); err != nil {
+		return nil, err
+	}
+	return bans, nil
+}
+
+// LiftBan lets the banned client ip authenticate again at once. It fails with ErrBanNotFound
+// if ip is not banned, and needs the admin scope.
+func (c *Client) LiftBan(ctx context.Context, ip string) error {
+	_, err := c.do(ctx, http.MethodDelete, "/api/v1/auth/bans/"+url.PathEscape(ip), nil, nil, nil)
+	return err
+}
@@ -0,0 +1,274 @@
+// Package client is a typed Go client for the users API (/api/v1/users).
+//
+// It reuses the service's own request/response models, retries 429 responses and, for
+// idempotent requests, 503 responses with backoff (honoring Retry-After), and maps error responses back
+// to the domain sentinel errors so callers can use errors.Is:
+//
+//	c, _ := client.New("http://localhost:8080", client.WithAPIKey(key))
+//	user, err := c.GetUserByUsername(ctx, "jdoe")
+//	if errors.Is(err, client.ErrUserNotFound) { ... }
+package client
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"math/rand/v2"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const (
+	defaultMaxRetries = 3
+	defaultMinBackoff = 200 * time.Millisecond
+	defaultMaxBackoff = 5 * time.Second
+)
+
+// Client calls the users API. It is safe for concurrent use.
+type Client struct {
+	baseURL     *url.URL
+	httpClient  *http.Client
+	apiKey      string
+	bearerToken string
+	userAgent   string
+	org         string
+
+	maxRetries int
+	minBackoff time.Duration
+	maxBackoff time.Duration
+}
+
+// Option configures a Client.
+type Option func(*Client)
+
+// WithAPIKey authenticates every request with the X-API-Key header.
+func WithAPIKey(key string) Option {
+	return func(c *Client) { c.apiKey = key }
+}
+
+// WithBearerToken authenticates every request with an `Authorization: Bearer` token.
+func WithBearerToken(token string) Option {
+	return func(c *Client) { c.bearerToken = token }
+}
+
+// WithOrganization makes every request act in the organization with this slug or ID, with the
+// X-Organization header. Only callers with the admin scope may name an organization other than
+// their own; without the option they act in their own.
+func WithOrganization(ref string) Option {
+	return func(c *Client) { c.org = ref }
+}
+
+// WithHTTPClient replaces the underlying http.Client (default: 30s timeout).
+func WithHTTPClient(hc *http.Client) Option {
+	return func(c *Client) { c.httpClient = hc }
+}
+
+// WithUserAgent sets the User-Agent header.
+func WithUserAgent(ua string) Option {
+	return func(c *Client) { c.userAgent = ua }
+}
+
+// WithRetry configures retries of 429/503 responses. maxRetries of 0 disables retrying.
+// A 503 may come from a gateway after the service acted, so only idempotent requests are retried on it.
+// Backoff grows exponentially from minBackoff up to maxBackoff, with jitter;
+// a Retry-After header from the server takes precedence. A Retry-After longer than maxBackoff, as
+// sent to a client banned for wrong API keys, is not waited out: the error is returned at once.
+func WithRetry(maxRetries int, minBackoff, maxBackoff time.Duration) Option {
+	return func(c *Client) {
+		c.maxRetries = maxRetries
+		c.minBackoff = minBackoff
+		c.maxBackoff = maxBackoff
+	}
+}
+
+// New creates a client for the service at baseURL, e.g. "https://users.internal".
+func New(baseURL string, opts ...Option) (*Client, error) {
+	u, err := url.Parse(strings.TrimRight(baseURL, "/"))
+	if err != nil {
+		return nil, fmt.Errorf("invalid base URL: %w", err)
+	}
+	if u.Scheme == "" || u.Host == "" {
+		return nil, fmt.Errorf("invalid base URL %q: scheme and host are required", baseURL)
+	}
+
+	c := &Client{
+		baseURL:    u,
+		httpClient: &http.Client{Timeout: 30 * time.Second},
+		userAgent:  "cruder-go-client",
+		maxRetries: defaultMaxRetries,
+		minBackoff: defaultMinBackoff,
+		maxBackoff: defaultMaxBackoff,
+	}
+	for _, opt := range opts {
+		opt(c)
+	}
+	return c, nil
+}
+
+// do sends a request with a JSON body (if in is non-nil) and decodes a successful JSON response into out (if non-nil).
+func (c *Client) do(ctx context.Context, method, path string, query url.Values, in, out any) (*http.Response, error) {
+	return c.doAs(ctx, method, path, query, "application/json", in, out)
+}
+
+// rawBody is a request body sent as-is instead of being encoded as JSON.
+type rawBody []byte
+
+// doAs is do with a body media type other than plain JSON, such as a patch format.
+func (c *Client) doAs(ctx context.Context, method, path string, query url.Values, contentType string, in, out any) (*http.Response, error) {
+	return c.doWith(ctx, method, path, query, nil, contentType, in, out)
+}
+
+// doWith is doAs with extra request headers.
+// The body is buffered so the request can be replayed on retry.
+func (c *Client) doWith(ctx context.Context, method, path string, query url.Values, header http.Header, contentType string, in, out any) (*http.Response, error) {
+	var body []byte
+	switch in := in.(type) {
+	case nil:
+	case rawBody:
+		body = in
+	default:
+		var err error
+		if body, err = json.Marshal(in); err != nil {
+			return nil, fmt.Errorf("failed to encode request: %w", err)
+		}
+	}
+
+	u := *c.baseURL
+	u.Path += path
+	u.RawQuery = query.Encode()
+
+	for attempt := 0; ; attempt++ {
+		req, err := http.NewRequestWithContext(ctx, method, u.String(), bytes.NewReader(body))
+		if err != nil {
+			return nil, err
+		}
+		if body != nil {
+			req.Header.Set("Content-Type", contentType)
+		}
+		c.setHeaders(req)
+		for name, values := range header {
+			req.Header[name] = values
+		}
+
+		resp, err := c.httpClient.Do(req)
+		if err != nil {
+			return nil, err
+		}
+
+		retry := resp.StatusCode == http.StatusTooManyRequests ||
+			resp.StatusCode == http.StatusServiceUnavailable && idempotent(method)
+		if d, ok := parseRetryAfter(resp.Header.Get("Retry-After"), time.Now()); ok && d > c.maxBackoff {
+			retry = false
+		}
+		if retry && attempt < c.maxRetries {
+			wait := c.backoff(attempt, resp.Header.Get("Retry-After"))
+			_, _ = io.Copy(io.Discard, resp.Body)
+			_ = resp.Body.Close()
+
+			select {
+			case <-ctx.Done():
+				return nil, ctx.Err()
+			case <-time.After(wait):
+			}
+			continue
+		}
+
+		defer func() { _ = resp.Body.Close() }()
+
+		if resp.StatusCode >= http.StatusBadRequest {
+			return resp, newAPIError(resp)
+		}
+		if out != nil && resp.StatusCode != http.StatusNoContent {
+			if err := json.NewDecoder(resp.Body).Decode(out); err != nil {
+				return resp, fmt.Errorf("failed to decode response: %w", err)
+			}
+		}
+		return resp, nil
+	}
+}
+
+// stream sends a GET and returns the body of a successful response unread, for downloads too large
+// to buffer. The caller must close it. Streams are not retried.
+func (c *Client) stream(ctx context.Context, path string, query url.Values, accept string) (io.ReadCloser, error) {
+	u := *c.baseURL
+	u.Path += path
+	u.RawQuery = query.Encode()
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, u.String(), nil)
+	if err != nil {
+		return nil, err
+	}
+	c.setHeaders(req)
+	req.Header.Set("Accept", accept)
+
+	resp, err := c.httpClient.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	if resp.StatusCode >= http.StatusBadRequest {
+		defer func() { _ = resp.Body.Close() }()
+		return nil, newAPIError(resp)
+	}
+	return resp.Body, nil
+}
+
+func (c *Client) setHeaders(req *http.Request) {
+	req.Header.Set("Accept", "application/json")
+	if c.userAgent != "" {
+		req.Header.Set("User-Agent", c.userAgent)
+	}
+	if c.apiKey != "" {
+		req.Header.Set("X-API-Key", c.apiKey)
+	}
+	if c.bearerToken != "" {
+		req.Header.Set("Authorization", "Bearer "+c.bearerToken)
+	}
+	if c.org != "" {
+		req.Header.Set("X-Organization", c.org)
+	}
+}
+
+// idempotent reports whether sending a request with method twice has the effect of sending it once.
+func idempotent(method string) bool {
+	switch method {
+	case http.MethodGet, http.MethodHead, http.MethodOptions, http.MethodPut, http.MethodDelete:
+		return true
+	}
+	return false
+}
+
+// backoff returns how long to wait before retry number attempt+1.
+func (c *Client) backoff(attempt int, retryAfter string) time.Duration {
+	if d, ok := parseRetryAfter(retryAfter, time.Now()); ok {
+		return d
+	}
+
+	d := c.minBackoff << attempt
+	if d <= 0 || d > c.maxBackoff {
+		d = c.maxBackoff
+	}
+	// Full jitter avoids synchronized retries from many clients
+	return time.Duration(rand.Int64N(int64(d) + 1)) // #nosec G404 -- jitter, not security sensitive
+}
+
+// parseRetryAfter understands both forms from RFC 9110: delay-seconds and an HTTP-date.
+func parseRetryAfter(v string, now time.Time) (time.Duration, bool) {
+	if v == "" {
+		return 0, false
+	}
+	if secs, err := strconv.Atoi(v); err == nil && secs >= 0 {
+		return time.Duration(secs) * time.Second, true
+	}
+	if t, err := http.ParseTime(v); err == nil {
+		if d := t.Sub(now); d > 0 {
+			return d, true
+		}
+		return 0, true
+	}
+	return 0, false
+}
@@ -0,0 +1,1298 @@
+package client
+
+import (
+	"context"
+	"cruder/internal/auth"
+	"cruder/internal/controller"
+	"cruder/internal/handler"
+	"cruder/internal/jobs"
+	"cruder/internal/mail"
+	"cruder/internal/middleware"
+	"cruder/internal/model"
+	"cruder/internal/password"
+	"cruder/internal/repository/memory"
+	"cruder/internal/service"
+	"cruder/internal/token"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"maps"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"slices"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+const (
+	testAPIKey   = "test-key"
+	readerAPIKey = "reader-key"
+	// acmeAPIKey belongs to a read-write client of the acme organization
+	acmeAPIKey = "acme-key"
+)
+
+// newTestServer runs the real router, controllers, services and a job worker over in-memory repositories.
+func newTestServer(t *testing.T) *httptest.Server {
+	t.Helper()
+	gin.SetMode(gin.TestMode)
+
+	jobRepo := memory.NewJobRepository()
+	jobService := service.NewJobService(jobRepo)
+	userRepo := memory.NewUserRepository()
+	logger := slog.New(slog.NewTextHandler(io.Discard, nil))
+	mailer := &mail.MemoryMailer{}
+	verification := service.NewEmailVerificationService(memory.NewEmailVerificationRepository(), userRepo, mailer, service.VerificationOptions{})
+	emailChanges := service.NewEmailChangeService(memory.NewEmailChangeRepository(), service.NewUserService(userRepo), userRepo, mailer, service.EmailChangeOptions{})
+	userService := service.WithUsernameQuarantine(service.NewUserService(userRepo), userRepo, time.Hour)
+	userService = service.WithEmailVerification(service.WithEmailChangeConfirmation(userService, emailChanges, logger), verification, logger)
+	passwords := service.NewAuthService(userRepo, password.NewHasher(password.Params{Memory: 64, Iterations: 1, Parallelism: 1}))
+	keys, err := token.GenerateKeySet()
+	require.NoError(t, err)
+	tokens := service.NewTokenService(memory.NewTokenRevocationRepository(), keys, service.TokenOptions{Issuer: "test"})
+	// Every test client connects from 127.0.0.1: a few wrong keys ban it, without delays before that
+	failures := service.NewAuthFailureService(memory.NewAuthFailureRepository(), service.AuthFailureOptions{BanAfter: 3, BanDuration: time.Hour}, logger)
+	orgs := service.NewOrganizationService(memory.NewOrganizationRepository())
+	_, err = orgs.Create(&model.CreateOrganizationRequest{Slug: "acme", Name: "Acme"})
+	require.NoError(t, err)
+	authenticator := auth.NewAuthenticator([]auth.Client{
+		{ID: auth.DefaultClientID, Key: testAPIKey, Scopes: []string{model.ScopeAdmin}},
+		{ID: "reader", Key: readerAPIKey, Scopes: []string{model.ScopeUsersRead}},
+		{ID: "acme", Key: acmeAPIKey, Scopes: []string{model.ScopeUsersRead, model.ScopeUsersWrite}, Organization: "acme"},
+	}, tokens, failures, orgs)
+	users := controller.NewUserController(userService, jobService, emailChanges)
+	groups := service.NewGroupService(memory.NewGroupRepository(userRepo), userRepo)
+	invitations := service.NewInvitationService(memory.NewInvitationRepository(), service.WithEmailChangeConfirmation(service.NewUserService(userRepo), emailChanges, logger),
+		userRepo, emailChanges, mailer, service.InvitationOptions{}, logger)
+
+	worker := jobs.NewWorker(jobRepo, jobs.Config{PollInterval: 5 * time.Millisecond}, logger)
+	jobs.RegisterUserHandlers(worker, userService)
+	workerCtx, stopWorker := context.WithCancel(context.Background())
+	workerDone := make(chan struct{})
+	go func() {
+		defer close(workerDone)
+		worker.Run(workerCtx)
+	}()
+	t.Cleanup(func() {
+		stopWorker()
+		<-workerDone
+	})
+
+	// The OpenID Connect issuer is the server's own URL, known once it listens
+	srv := httptest.NewUnstartedServer(nil)
+	srv.Start()
+	t.Cleanup(srv.Close)
+	oauth := service.NewOAuthService(memory.NewOAuthRepository(), userService, passwords, keys, service.OAuthOptions{Issuer: srv.URL})
+
+	r := gin.New()
+	r.Use(middleware.Authenticate(authenticator))
+	handler.New(r, handler.Controllers{
+		Organizations: controller.NewOrganizationController(orgs),
+		Users:         users,
+		Groups:        controller.NewGroupController(groups),
+		Invitations:   controller.NewInvitationController(invitations),
+		Jobs:          controller.NewJobController(jobService),
+		Scheduler:     controller.NewSchedulerController(service.NewTaskRunService(memory.NewTaskRunRepository())),
+		Auth:          controller.NewAuthController(passwords, tokens, failures),
+		Verification:  controller.NewVerificationController(verification, emailChanges),
+		Health:        controller.NewHealthController(nil, nil),
+		Docs:          controller.NewDocsController(),
+		GraphQL:       controller.NewGraphQLController(nil),
+		OAuth:         controller.NewOAuthController(oauth, failures),
+		SCIM:          controller.NewSCIMController(userService),
+	})
+	srv.Config.Handler = r
+	return srv
+}
+
+func newTestClient(t *testing.T, baseURL string, opts ...Option) *Client {
+	t.Helper()
+	opts = append([]Option{WithAPIKey(testAPIKey), WithRetry(3, time.Millisecond, 10*time.Millisecond)}, opts...)
+	c, err := New(baseURL, opts...)
+	require.NoError(t, err)
+	return c
+}
+
+func TestClient_CRUD(t *testing.T) {
+	srv := newTestServer(t)
+	c := newTestClient(t, srv.URL)
+	ctx := context.Background()
+
+	created, err := c.CreateUser(ctx, CreateUserRequest{Username: "JDoe", Email: "jdoe@example.com", FullName: "John Doe"})
+	require.NoError(t, err)
+	assert.Equal(t, "jdoe", created.Username)
+
+	got, err := c.GetUser(ctx, created.ID)
+	require.NoError(t, err)
+	assert.Equal(t, created.ID, got.ID)
+
+	got, err = c.GetUserByUsername(ctx, "jdoe")
+	require.NoError(t, err)
+	assert.Equal(t, created.ID, got.ID)
+
+	updated, err := c.UpdateUser(ctx, created.ID, UpdateUserRequest{FullName: String("Johnny Doe")})
+	require.NoError(t, err)
+	assert.Equal(t, "Johnny Doe", updated.FullName)
+
+	all, err := c.ListUsers(ctx)
+	require.NoError(t, err)
+	assert.Len(t, all, 1)
+
+	require.NoError(t, c.DeleteUser(ctx, created.ID))
+	_, err = c.GetUser(ctx, created.ID)
+	assert.ErrorIs(t, err, ErrUserNotFound)
+}
+
+func TestClient_DomainErrors(t *testing.T) {
+	srv := newTestServer(t)
+	c := newTestClient(t, srv.URL)
+	ctx := context.Background()
+
+	_, err := c.CreateUser(ctx, CreateUserRequest{Username: "jdoe", Email: "jdoe@example.com", FullName: "John Doe"})
+	require.NoError(t, err)
+
+	_, err = c.CreateUser(ctx, CreateUserRequest{Username: "jdoe", Email: "other@example.com", FullName: "John Doe"})
+	assert.ErrorIs(t, err, ErrUsernameExists)
+
+	_, err = c.CreateUser(ctx, CreateUserRequest{Username: "other", Email: "jdoe@example.com", FullName: "John Doe"})
+	assert.ErrorIs(t, err, ErrEmailExists)
+
+	_, err = c.CreateUser(ctx, CreateUserRequest{Username: "x", Email: "bad", FullName: "John Doe"})
+	assert.ErrorIs(t, err, ErrInvalidInput)
+	var apiErr *APIError
+	require.True(t, errors.As(err, &apiErr))
+	assert.Equal(t, http.StatusBadRequest, apiErr.StatusCode)
+	assert.Contains(t, apiErr.Details, "Email")
+
+	_, err = c.GetUserByUsername(ctx, "nobody")
+	assert.ErrorIs(t, err, ErrUserNotFound)
+}
+
+func TestClient_UsernameHistory(t *testing.T) {
+	srv := newTestServer(t)
+	c := newTestClient(t, srv.URL)
+	ctx := context.Background()
+
+	created, err := c.CreateUser(ctx, CreateUserRequest{Username: "jdoe", Email: "jdoe@example.com", FullName: "John Doe"})
+	require.NoError(t, err)
+	_, err = c.UpdateUser(ctx, created.ID, UpdateUserRequest{Username: String("johnd")})
+	require.NoError(t, err)
+
+	history, err := c.UsernameHistory(ctx, created.ID)
+	require.NoError(t, err)
+	require.Len(t, history, 1)
+	assert.Equal(t, "jdoe", history[0].OldUsername)
+	assert.Equal(t, "johnd", history[0].NewUsername)
+	assert.Equal(t, auth.DefaultClientID, history[0].Actor)
+
+	_, err = c.CreateUser(ctx, CreateUserRequest{Username: "jdoe", Email: "someone@example.com", FullName: "Someone Else"})
+	assert.ErrorIs(t, err, ErrUsernameReserved)
+
+	_, err = c.GetUserByUsername(ctx, "jdoe")
+	assert.ErrorIs(t, err, ErrUserNotFound)
+	user, renamed, err := c.ResolveUsername(ctx, "jdoe")
+	require.NoError(t, err)
+	assert.True(t, renamed)
+	assert.Equal(t, "johnd", user.Username)
+	user, renamed, err = c.ResolveUsername(ctx, "johnd")
+	require.NoError(t, err)
+	assert.False(t, renamed)
+	assert.Equal(t, created.ID, user.ID)
+
+	_, err = c.UsernameHistory(ctx, uuid.New())
+	assert.ErrorIs(t, err, ErrUserNotFound)
+}
+
+func TestClient_UserLifecycle(t *testing.T) {
+	srv := newTestServer(t)
+	c := newTestClient(t, srv.URL)
+	ctx := context.Background()
+
+	invited, err := c.CreateUser(ctx, CreateUserRequest{Username: "jdoe", Email: "jdoe@example.com", FullName: "John Doe", Status: UserInvited})
+	require.NoError(t, err)
+	assert.Equal(t, UserInvited, invited.Status)
+	other, err := c.CreateUser(ctx, CreateUserRequest{Username: "asmith", Email: "asmith@example.com", FullName: "Alice Smith"})
+	require.NoError(t, err)
+	assert.Equal(t, UserActive, other.Status)
+
+	_, err = c.SuspendUser(ctx, invited.ID, "not yet")
+	assert.ErrorIs(t, err, ErrStatusTransition)
+	user, err := c.ActivateUser(ctx, invited.ID, "")
+	require.NoError(t, err)
+	assert.Equal(t, UserActive, user.Status)
+	require.NoError(t, c.ChangePassword(ctx, user.ID, "", "violet-tangerine-42"))
+
+	_, err = c.SuspendUser(ctx, user.ID, "")
+	assert.ErrorIs(t, err, ErrInvalidInput, "a reason is required")
+	user, err = c.SuspendUser(ctx, user.ID, "security review")
+	require.NoError(t, err)
+	assert.Equal(t, UserSuspended, user.Status)
+	_, err = c.Login(ctx, "jdoe", "violet-tangerine-42")
+	assert.ErrorIs(t, err, ErrUserNotActive)
+
+	page, err := c.ListUsersPage(ctx, PageOptions{Status: UserSuspended})
+	require.NoError(t, err)
+	require.Len(t, page.Users, 1)
+	assert.Equal(t, user.ID, page.Users[0].ID)
+	_, err = c.ListUsersPage(ctx, PageOptions{Status: "banned"})
+	assert.ErrorIs(t, err, ErrInvalidInput)
+
+	// Status is read-only outside the transitions
+	_, err = c.MergePatchUser(ctx, user.ID, map[string]any{"status": "active"})
+	assert.Error(t, err)
+
+	user, err = c.DeactivateUser(ctx, user.ID, "left the company")
+	require.NoError(t, err)
+	assert.Equal(t, UserDeactivated, user.Status)
+	_, err = c.ActivateUser(ctx, user.ID, "came back")
+	assert.ErrorIs(t, err, ErrStatusTransition)
+
+	history, err := c.StatusHistory(ctx, user.ID)
+	require.NoError(t, err)
+	require.Len(t, history, 3)
+	assert.Equal(t, UserDeactivated, history[0].NewStatus)
+	assert.Equal(t, "left the company", history[0].Reason)
+	assert.Equal(t, auth.DefaultClientID, history[0].Actor)
+	assert.Equal(t, UserInvited, history[2].OldStatus)
+
+	_, err = c.SuspendUser(ctx, uuid.New(), "gone")
+	assert.ErrorIs(t, err, ErrUserNotFound)
+	_, err = c.StatusHistory(ctx, uuid.New())
+	assert.ErrorIs(t, err, ErrUserNotFound)
+}
+
+func TestClient_ReplaceAndPatch(t *testing.T) {
+	srv := newTestServer(t)
+	c := newTestClient(t, srv.URL)
+	ctx := context.Background()
+
+	created, err := c.CreateUser(ctx, CreateUserRequest{Username: "jdoe", Email: "jdoe@example.com", FullName: "John Doe"})
+	require.NoError(t, err)
+
+	replaced, err := c.ReplaceUser(ctx, created.ID, ReplaceUserRequest{Username: "JaneDoe", Email: "jane@example.com", FullName: "Jane Doe"})
+	require.NoError(t, err)
+	assert.Equal(t, "janedoe", replaced.Username)
+	// A new email waits for confirmation
+	assert.Equal(t, "jdoe@example.com", replaced.Email)
+	require.NotNil(t, replaced.PendingEmailChange)
+	assert.Equal(t, "jane@example.com", replaced.PendingEmailChange.NewEmail)
+
+	// PUT needs the full representation
+	_, err = c.ReplaceUser(ctx, created.ID, ReplaceUserRequest{Username: "janedoe"})
+	assert.ErrorIs(t, err, ErrInvalidInput)
+
+	merged, err := c.MergePatchUser(ctx, created.ID, map[string]any{"full_name": "Jane Q Doe"})
+	require.NoError(t, err)
+	assert.Equal(t, "Jane Q Doe", merged.FullName)
+	assert.Equal(t, "jdoe@example.com", merged.Email)
+
+	// Removing a required field fails the creation rules
+	_, err = c.MergePatchUser(ctx, created.ID, map[string]any{"email": nil})
+	var apiErr *APIError
+	require.ErrorAs(t, err, &apiErr)
+	assert.Equal(t, http.StatusBadRequest, apiErr.StatusCode)
+	assert.Contains(t, apiErr.Details, "Email")
+
+	patched, err := c.JSONPatchUser(ctx, created.ID, []PatchOperation{
+		{Op: "test", Path: "/username", Value: "janedoe"},
+		{Op: "replace", Path: "/email", Value: "jq@example.com"},
+	})
+	require.NoError(t, err)
+	assert.Equal(t, "jdoe@example.com", patched.Email)
+	require.NotNil(t, patched.PendingEmailChange)
+	assert.Equal(t, "jq@example.com", patched.PendingEmailChange.NewEmail)
+
+	_, err = c.JSONPatchUser(ctx, created.ID, []PatchOperation{
+		{Op: "test", Path: "/username", Value: "someoneelse"},
+		{Op: "replace", Path: "/email", Value: "never@example.com"},
+	})
+	require.ErrorAs(t, err, &apiErr)
+	assert.Equal(t, http.StatusConflict, apiErr.StatusCode)
+
+	_, err = c.JSONPatchUser(ctx, created.ID, []PatchOperation{{Op: "replace", Path: "/id", Value: uuid.NewString()}})
+	require.ErrorAs(t, err, &apiErr)
+	assert.Equal(t, http.StatusUnprocessableEntity, apiErr.StatusCode)
+
+	got, err := c.GetUser(ctx, created.ID)
+	require.NoError(t, err)
+	require.NotNil(t, got.PendingEmailChange, "the latest request replaces the earlier one")
+	assert.Equal(t, "jq@example.com", got.PendingEmailChange.NewEmail)
+
+	req, err := http.NewRequest(http.MethodPatch, srv.URL+"/api/v1/users/id/"+created.ID.String(), strings.NewReader("username: x"))
+	require.NoError(t, err)
+	req.Header.Set("X-API-Key", testAPIKey)
+	req.Header.Set("Content-Type", "application/yaml")
+	resp, err := http.DefaultClient.Do(req)
+	require.NoError(t, err)
+	_ = resp.Body.Close()
+	assert.Equal(t, http.StatusUnsupportedMediaType, resp.StatusCode)
+	assert.Contains(t, resp.Header.Get("Accept-Patch"), "application/merge-patch+json")
+}
+
+func TestClient_SparseFieldsets(t *testing.T) {
+	srv := newTestServer(t)
+	c := newTestClient(t, srv.URL)
+	ctx := context.Background()
+
+	for _, name := range []string{"alice", "bob", "carol"} {
+		_, err := c.CreateUser(ctx, CreateUserRequest{Username: name, Email: name + "@example.com", FullName: "Test User"})
+		require.NoError(t, err)
+	}
+
+	// The next-page link keeps the fieldset
+	page, err := c.ListUsersPage(ctx, PageOptions{Limit: 2, Fields: []string{"username"}})
+	require.NoError(t, err)
+	require.NotNil(t, page.Next)
+	assert.Equal(t, []string{"username"}, page.Next.Fields)
+	for _, u := range page.Users {
+		assert.NotEmpty(t, u.Username)
+		assert.Empty(t, u.Email)
+		assert.Equal(t, uuid.Nil, u.ID)
+	}
+
+	// Unselected attributes are absent from the body, not zero-valued
+	req, err := http.NewRequest(http.MethodGet, srv.URL+"/api/v1/users/username/alice?fields=id,email", nil)
+	require.NoError(t, err)
+	req.Header.Set("X-API-Key", testAPIKey)
+	resp, err := http.DefaultClient.Do(req)
+	require.NoError(t, err)
+	defer func() { _ = resp.Body.Close() }()
+	var body map[string]any
+	require.NoError(t, json.NewDecoder(resp.Body).Decode(&body))
+	assert.ElementsMatch(t, []string{"id", "email"}, slices.Collect(maps.Keys(body)))
+
+	_, err = c.ListUsersPage(ctx, PageOptions{Fields: []string{"password"}})
+	var apiErr *APIError
+	require.ErrorAs(t, err, &apiErr)
+	assert.Equal(t, http.StatusBadRequest, apiErr.StatusCode)
+	assert.ErrorIs(t, err, ErrInvalidInput)
+}
+
+func TestClient_SearchAndExport(t *testing.T) {
+	srv := newTestServer(t)
+	c := newTestClient(t, srv.URL)
+	ctx := context.Background()
+
+	for _, name := range []string{"alice", "bob", "alina"} {
+		_, err := c.CreateUser(ctx, CreateUserRequest{Username: name, Email: name + "@example.com", FullName: "Test User"})
+		require.NoError(t, err)
+	}
+
+	found, err := c.SearchUsers(ctx, "ali")
+	require.NoError(t, err)
+	assert.Len(t, found, 2)
+
+	_, err = c.SearchUsers(ctx, " ")
+	assert.ErrorIs(t, err, ErrInvalidInput)
+
+	body, err := c.ExportUsers(ctx, ExportCSV, []string{"username", "email"})
+	require.NoError(t, err)
+	records, err := csv.NewReader(body).ReadAll()
+	require.NoError(t, body.Close())
+	require.NoError(t, err)
+	require.Len(t, records, 4)
+	assert.Equal(t, []string{"username", "email"}, records[0])
+
+	body, err = c.ExportUsers(ctx, ExportNDJSON, nil)
+	require.NoError(t, err)
+	defer func() { _ = body.Close() }()
+	dec := json.NewDecoder(body)
+	n := 0
+	for dec.More() {
+		var u User
+		require.NoError(t, dec.Decode(&u))
+		assert.NotEqual(t, uuid.Nil, u.ID)
+		n++
+	}
+	assert.Equal(t, 3, n)
+}
+
+func TestClient_ImportUsers(t *testing.T) {
+	srv := newTestServer(t)
+	c := newTestClient(t, srv.URL)
+	ctx := context.Background()
+
+	file := "username,email,full_name\njdoe,jdoe@example.com,John Doe\nx,bad,Bob\n"
+
+	report, err := c.ImportUsers(ctx, strings.NewReader(file), ImportOptions{DryRun: true})
+	require.NoError(t, err)
+	assert.True(t, report.DryRun)
+	assert.Equal(t, 1, report.Created)
+	assert.Equal(t, 1, report.Failed)
+	all, err := c.ListUsers(ctx)
+	require.NoError(t, err)
+	assert.Empty(t, all)
+
+	report, err = c.ImportUsers(ctx, strings.NewReader(file), ImportOptions{Mode: ImportUpsertByEmail})
+	require.NoError(t, err)
+	require.Len(t, report.Rows, 2)
+	assert.Equal(t, ImportCreated, report.Rows[0].Status)
+	assert.NotNil(t, report.Rows[0].ID)
+
+	_, err = c.ImportUsers(ctx, strings.NewReader("name\njdoe\n"), ImportOptions{})
+	assert.ErrorIs(t, err, ErrInvalidInput)
+
+	_, err = c.ImportUsers(ctx, strings.NewReader(file), ImportOptions{Mode: "overwrite"})
+	assert.ErrorIs(t, err, ErrInvalidInput)
+}
+
+func TestClient_Jobs(t *testing.T) {
+	srv := newTestServer(t)
+	c := newTestClient(t, srv.URL)
+	ctx := context.Background()
+
+	file := "username,email,full_name\njdoe,jdoe@example.com,John Doe\nasmith,asmith@example.com,Anna Smith\n"
+	job, err := c.StartImport(ctx, strings.NewReader(file), ImportOptions{})
+	require.NoError(t, err)
+	assert.Equal(t, model.JobUserImport, job.Kind)
+
+	job, err = c.WaitJob(ctx, job.ID, 5*time.Millisecond)
+	require.NoError(t, err)
+	require.Equal(t, JobSucceeded, job.Status, job.Error)
+	body, err := c.JobResult(ctx, job.ID)
+	require.NoError(t, err)
+	var report ImportReport
+	require.NoError(t, json.NewDecoder(body).Decode(&report))
+	require.NoError(t, body.Close())
+	assert.Equal(t, 2, report.Created)
+
+	job, err = c.StartExport(ctx, ExportJobOptions{Format: ExportCSV, Fields: []string{"username"}, Query: "smith"})
+	require.NoError(t, err)
+	job, err = c.WaitJob(ctx, job.ID, 5*time.Millisecond)
+	require.NoError(t, err)
+	require.Equal(t, JobSucceeded, job.Status, job.Error)
+	body, err = c.JobResult(ctx, job.ID)
+	require.NoError(t, err)
+	records, err := csv.NewReader(body).ReadAll()
+	require.NoError(t, err)
+	require.NoError(t, body.Close())
+	assert.Equal(t, [][]string{{"username"}, {"asmith"}}, records)
+
+	_, err = c.CancelJob(ctx, job.ID)
+	assert.ErrorIs(t, err, ErrJobFinished)
+	_, err = c.GetJob(ctx, uuid.New())
+	assert.ErrorIs(t, err, ErrJobNotFound)
+	_, err = c.StartExport(ctx, ExportJobOptions{Format: "application/pdf"})
+	assert.ErrorIs(t, err, ErrInvalidInput)
+}
+
+func TestClient_ListTaskRuns(t *testing.T) {
+	srv := newTestServer(t)
+	c := newTestClient(t, srv.URL)
+	ctx := context.Background()
+
+	runs, err := c.ListTaskRuns(ctx, "jobs.purge", 10)
+	require.NoError(t, err)
+	assert.Empty(t, runs)
+
+	_, err = c.ListTaskRuns(ctx, "", 5000)
+	assert.ErrorIs(t, err, ErrInvalidInput)
+}
+
+func TestClient_Passwords(t *testing.T) {
+	srv := newTestServer(t)
+	c := newTestClient(t, srv.URL)
+	ctx := context.Background()
+
+	user, err := c.CreateUser(ctx, CreateUserRequest{Username: "jdoe", Email: "jdoe@example.com", FullName: "John Doe"})
+	require.NoError(t, err)
+
+	_, err = c.Login(ctx, "jdoe", "violet-tangerine-42")
+	assert.ErrorIs(t, err, ErrInvalidCredentials)
+
+	require.NoError(t, c.ChangePassword(ctx, user.ID, "", "violet-tangerine-42"))
+	loggedIn, err := c.Login(ctx, "jdoe@example.com", "violet-tangerine-42")
+	require.NoError(t, err)
+	assert.Equal(t, user.ID, loggedIn.ID)
+
+	err = c.ChangePassword(ctx, user.ID, "wrong-password-1", "amber-pelican-1977")
+	assert.ErrorIs(t, err, ErrWrongPassword)
+	err = c.ChangePassword(ctx, user.ID, "violet-tangerine-42", "short")
+	assert.ErrorIs(t, err, ErrInvalidInput)
+	err = c.ChangePassword(ctx, uuid.New(), "", "amber-pelican-1977")
+	assert.ErrorIs(t, err, ErrUserNotFound)
+}
+
+func TestClient_EmailVerification(t *testing.T) {
+	srv := newTestServer(t)
+	c := newTestClient(t, srv.URL)
+	ctx := context.Background()
+
+	user, err := c.CreateUser(ctx, CreateUserRequest{Username: "jdoe", Email: "jdoe@example.com", FullName: "John Doe"})
+	require.NoError(t, err)
+	assert.Nil(t, user.EmailVerifiedAt)
+
+	require.NoError(t, c.ResendVerificationEmail(ctx, user.ID))
+	assert.ErrorIs(t, c.ResendVerificationEmail(ctx, uuid.New()), ErrUserNotFound)
+
+	_, err = c.VerifyEmail(ctx, "not-a-token")
+	assert.ErrorIs(t, err, ErrVerificationTokenInvalid)
+	_, err = c.VerifyEmail(ctx, "")
+	assert.ErrorIs(t, err, ErrInvalidInput)
+	assert.NotErrorIs(t, err, ErrVerificationTokenInvalid)
+
+	_, err = c.ConfirmEmailChange(ctx, "not-a-token")
+	assert.ErrorIs(t, err, ErrEmailChangeTokenInvalid)
+	_, err = c.RevertEmailChange(ctx, "not-a-token")
+	assert.ErrorIs(t, err, ErrEmailChangeTokenInvalid)
+}
+
+func TestClient_Auth(t *testing.T) {
+	srv := newTestServer(t)
+	c, err := New(srv.URL, WithAPIKey("wrong"))
+	require.NoError(t, err)
+
+	_, err = c.ListUsers(context.Background())
+
+	var apiErr *APIError
+	require.True(t, errors.As(err, &apiErr))
+	assert.Equal(t, http.StatusForbidden, apiErr.StatusCode)
+}
+
+func TestClient_Bans(t *testing.T) {
+	srv := newTestServer(t)
+	ctx := context.Background()
+	admin := newTestClient(t, srv.URL)
+	wrong, err := New(srv.URL, WithAPIKey("wrong"), WithRetry(0, 0, 0))
+	require.NoError(t, err)
+
+	bans, err := admin.ListBans(ctx)
+	require.NoError(t, err)
+	assert.Empty(t, bans)
+	assert.ErrorIs(t, admin.LiftBan(ctx, "127.0.0.1"), ErrBanNotFound)
+
+	for i := 0; i < 3; i++ {
+		_, err = wrong.ListUsers(ctx)
+		var apiErr *APIError
+		require.True(t, errors.As(err, &apiErr))
+		assert.Equal(t, http.StatusForbidden, apiErr.StatusCode)
+	}
+
+	// Banned: every key from the address is refused, even a right one
+	_, err = wrong.ListUsers(ctx)
+	assert.ErrorIs(t, err, ErrAuthThrottled)
+	req, err := http.NewRequest(http.MethodGet, srv.URL+"/api/v1/users", nil)
+	require.NoError(t, err)
+	req.Header.Set("X-API-Key", testAPIKey)
+	resp, err := http.DefaultClient.Do(req)
+	require.NoError(t, err)
+	_ = resp.Body.Close()
+	assert.Equal(t, http.StatusTooManyRequests, resp.StatusCode)
+	assert.NotEmpty(t, resp.Header.Get("Retry-After"))
+	_, err = admin.ListBans(ctx)
+	assert.ErrorIs(t, err, ErrAuthThrottled, "retries give up on a ban")
+}
+
+func TestClient_LiftBan(t *testing.T) {
+	srv := newTestServer(t)
+	ctx := context.Background()
+	// Access tokens are not throttled, so an admin can still lift a ban on its own address
+	admin := newTestClient(t, srv.URL)
+	token, err := admin.IssueToken(ctx)
+	require.NoError(t, err)
+	tokenAdmin := newTestClient(t, srv.URL, WithAPIKey(""), WithBearerToken(token.AccessToken))
+	wrong, err := New(srv.URL, WithAPIKey("wrong"), WithRetry(0, 0, 0))
+	require.NoError(t, err)
+
+	for i := 0; i < 3; i++ {
+		_, err = wrong.ListUsers(ctx)
+		require.Error(t, err)
+	}
+	bans, err := tokenAdmin.ListBans(ctx)
+	require.NoError(t, err)
+	require.Len(t, bans, 1)
+	assert.Equal(t, "127.0.0.1", bans[0].IP)
+	assert.Equal(t, 3, bans[0].Failures)
+
+	var apiErr *APIError
+	require.True(t, errors.As(tokenAdmin.LiftBan(ctx, "not-an-ip"), &apiErr))
+	assert.Equal(t, http.StatusBadRequest, apiErr.StatusCode)
+	require.NoError(t, tokenAdmin.LiftBan(ctx, "127.0.0.1"))
+
+	_, err = admin.ListUsers(ctx)
+	assert.NoError(t, err)
+}
+
+func TestClient_AccessTokens(t *testing.T) {
+	srv := newTestServer(t)
+	c := newTestClient(t, srv.URL)
+	ctx := context.Background()
+	statusOf := func(err error) int {
+		var apiErr *APIError
+		require.True(t, errors.As(err, &apiErr), "%v", err)
+		return apiErr.StatusCode
+	}
+
+	issued, err := c.IssueToken(ctx)
+	require.NoError(t, err)
+	assert.Equal(t, "Bearer", issued.TokenType)
+	assert.Equal(t, "admin", issued.Scope)
+
+	// The token stands in for the API key
+	tc, err := New(srv.URL, WithBearerToken(issued.AccessToken))
+	require.NoError(t, err)
+	_, err = tc.CreateUser(ctx, CreateUserRequest{Username: "jdoe", Email: "jdoe@example.com", FullName: "John Doe"})
+	require.NoError(t, err)
+	_, err = tc.IssueToken(ctx)
+	assert.Equal(t, http.StatusForbidden, statusOf(err), "tokens cannot mint tokens")
+
+	// Narrowed to reading
+	readOnly, err := c.IssueToken(ctx, ScopeUsersRead)
+	require.NoError(t, err)
+	rc, err := New(srv.URL, WithBearerToken(readOnly.AccessToken))
+	require.NoError(t, err)
+	all, err := rc.ListUsers(ctx)
+	require.NoError(t, err)
+	assert.Len(t, all, 1)
+	_, err = rc.CreateUser(ctx, CreateUserRequest{Username: "jane", Email: "jane@example.com", FullName: "Jane Doe"})
+	assert.Equal(t, http.StatusForbidden, statusOf(err))
+
+	// A read-only API key cannot get more than it holds
+	reader := newTestClient(t, srv.URL, WithAPIKey(readerAPIKey))
+	_, err = reader.IssueToken(ctx, ScopeUsersWrite)
+	assert.ErrorIs(t, err, ErrScopeNotGranted)
+	_, err = reader.RevokeTokens(ctx, "", time.Time{})
+	assert.Equal(t, http.StatusForbidden, statusOf(err))
+
+	rev, err := c.RevokeTokens(ctx, "default", time.Time{})
+	require.NoError(t, err)
+	assert.Equal(t, "default", rev.Subject)
+	_, err = tc.ListUsers(ctx)
+	assert.Equal(t, http.StatusUnauthorized, statusOf(err))
+	_, err = c.ListUsers(ctx)
+	assert.NoError(t, err, "the API key itself keeps working")
+
+	bad, err := New(srv.URL, WithBearerToken("not-a-token"))
+	require.NoError(t, err)
+	_, err = bad.ListUsers(ctx)
+	assert.Equal(t, http.StatusUnauthorized, statusOf(err))
+
+	// The signing keys are public
+	resp, err := http.Get(srv.URL + "/.well-known/jwks.json")
+	require.NoError(t, err)
+	defer func() { _ = resp.Body.Close() }()
+	assert.Equal(t, http.StatusOK, resp.StatusCode)
+	var jwks struct {
+		Keys []map[string]string `json:"keys"`
+	}
+	require.NoError(t, json.NewDecoder(resp.Body).Decode(&jwks))
+	require.Len(t, jwks.Keys, 1)
+	assert.Equal(t, "ES256", jwks.Keys[0]["alg"])
+}
+
+// oidcPKCE returns a PKCE verifier and its S256 challenge.
+func oidcPKCE() (verifier, challenge string) {
+	verifier = strings.Repeat("v", 20) + uuid.NewString()
+	sum := sha256.Sum256([]byte(verifier))
+	return verifier, base64.RawURLEncoding.EncodeToString(sum[:])
+}
+
+// verifyIDToken checks an ID token's signature against the provider's JWKS and returns its claims.
+func verifyIDToken(t *testing.T, jwksURI, idToken string) map[string]any {
+	t.Helper()
+	resp, err := http.Get(jwksURI)
+	require.NoError(t, err)
+	defer func() { _ = resp.Body.Close() }()
+	var jwks struct {
+		Keys []struct{ Kid, X, Y string } `json:"keys"`
+	}
+	require.NoError(t, json.NewDecoder(resp.Body).Decode(&jwks))
+
+	parts := strings.Split(idToken, ".")
+	require.Len(t, parts, 3)
+	var header struct{ Alg, Kid string }
+	data, err := base64.RawURLEncoding.DecodeString(parts[0])
+	require.NoError(t, err)
+	require.NoError(t, json.Unmarshal(data, &header))
+	assert.Equal(t, "ES256", header.Alg)
+	i := slices.IndexFunc(jwks.Keys, func(k struct{ Kid, X, Y string }) bool { return k.Kid == header.Kid })
+	require.GreaterOrEqual(t, i, 0, "signed by a published key")
+	x, _ := base64.RawURLEncoding.DecodeString(jwks.Keys[i].X)
+	y, _ := base64.RawURLEncoding.DecodeString(jwks.Keys[i].Y)
+	pub := &ecdsa.PublicKey{Curve: elliptic.P256(), X: new(big.Int).SetBytes(x), Y: new(big.Int).SetBytes(y)}
+	sig, err := base64.RawURLEncoding.DecodeString(parts[2])
+	require.NoError(t, err)
+	require.Len(t, sig, 64)
+	digest := sha256.Sum256([]byte(parts[0] + "." + parts[1]))
+	require.True(t, ecdsa.Verify(pub, digest[:], new(big.Int).SetBytes(sig[:32]), new(big.Int).SetBytes(sig[32:])), "valid signature")
+
+	var claims map[string]any
+	data, err = base64.RawURLEncoding.DecodeString(parts[1])
+	require.NoError(t, err)
+	require.NoError(t, json.Unmarshal(data, &claims))
+	return claims
+}
+
+func TestClient_OIDCFlow(t *testing.T) {
+	srv := newTestServer(t)
+	c := newTestClient(t, srv.URL)
+	ctx := context.Background()
+	// A browser that stops at redirects, to read where the provider sends the user
+	browser := &http.Client{CheckRedirect: func(*http.Request, []*http.Request) error { return http.ErrUseLastResponse }}
+	const redirectURI = "http://localhost:9999/callback"
+
+	app, err := c.RegisterOAuthClient(ctx, RegisterOAuthClientRequest{Name: "Wiki", RedirectURIs: []string{redirectURI}})
+	require.NoError(t, err)
+	require.NotEmpty(t, app.ClientSecret)
+	_, err = c.RegisterOAuthClient(ctx, RegisterOAuthClientRequest{Name: "Evil", RedirectURIs: []string{"http://evil.example/cb"}})
+	assert.ErrorIs(t, err, ErrInvalidInput, "plain http only on localhost")
+	_, err = c.GetOAuthClient(ctx, "nope")
+	assert.ErrorIs(t, err, ErrOAuthClientNotFound)
+
+	user, err := c.CreateUser(ctx, CreateUserRequest{Username: "jdoe", Email: "jdoe@example.com", FullName: "John Doe"})
+	require.NoError(t, err)
+	const pw = "Lighthouse-Keeper-42"
+	require.NoError(t, c.ChangePassword(ctx, user.ID, "", pw))
+
+	// Discovery, without credentials
+	resp, err := http.Get(srv.URL + "/.well-known/openid-configuration")
+	require.NoError(t, err)
+	var discovery struct {
+		Issuer                string `json:"issuer"`
+		AuthorizationEndpoint string `json:"authorization_endpoint"`
+		TokenEndpoint         string `json:"token_endpoint"`
+		UserInfoEndpoint      string `json:"userinfo_endpoint"`
+		JWKSURI               string `json:"jwks_uri"`
+	}
+	require.NoError(t, json.NewDecoder(resp.Body).Decode(&discovery))
+	_ = resp.Body.Close()
+	assert.Equal(t, srv.URL, discovery.Issuer)
+
+	verifier, challenge := oidcPKCE()
+	authz := url.Values{
+		"response_type": {"code"}, "client_id": {app.ID}, "redirect_uri": {redirectURI},
+		"scope": {"openid profile email"}, "state": {"xyz"}, "nonce": {"n-0S6"},
+		"code_challenge": {challenge}, "code_challenge_method": {"S256"},
+	}
+
+	resp, err = browser.Get(discovery.AuthorizationEndpoint + "?" + authz.Encode())
+	require.NoError(t, err)
+	page, _ := io.ReadAll(resp.Body)
+	_ = resp.Body.Close()
+	assert.Equal(t, http.StatusOK, resp.StatusCode)
+	assert.Contains(t, string(page), "Wiki")
+
+	signIn := func(password string) *http.Response {
+		form := maps.Clone(authz)
+		form.Set("login", "jdoe")
+		form.Set("password", password)
+		form.Set("decision", "allow")
+		resp, err := browser.PostForm(discovery.AuthorizationEndpoint, form)
+		require.NoError(t, err)
+		_ = resp.Body.Close()
+		return resp
+	}
+	assert.Equal(t, http.StatusUnauthorized, signIn("wrong password").StatusCode)
+
+	resp = signIn(pw)
+	require.Equal(t, http.StatusFound, resp.StatusCode)
+	location, err := url.Parse(resp.Header.Get("Location"))
+	require.NoError(t, err)
+	assert.Equal(t, "/callback", location.Path)
+	assert.Equal(t, "xyz", location.Query().Get("state"))
+	code := location.Query().Get("code")
+	require.NotEmpty(t, code)
+
+	exchange := func(verifier string) *http.Response {
+		form := url.Values{"grant_type": {"authorization_code"}, "code": {code}, "redirect_uri": {redirectURI}, "code_verifier": {verifier}}
+		req, err := http.NewRequest(http.MethodPost, discovery.TokenEndpoint, strings.NewReader(form.Encode()))
+		require.NoError(t, err)
+		req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+		req.SetBasicAuth(app.ID, app.ClientSecret)
+		resp, err := http.DefaultClient.Do(req)
+		require.NoError(t, err)
+		return resp
+	}
+	resp = exchange(verifier)
+	require.Equal(t, http.StatusOK, resp.StatusCode)
+	assert.Equal(t, "no-store", resp.Header.Get("Cache-Control"))
+	var tokens model.OIDCToken
+	require.NoError(t, json.NewDecoder(resp.Body).Decode(&tokens))
+	_ = resp.Body.Close()
+	assert.Equal(t, "openid profile email", tokens.Scope)
+
+	claims := verifyIDToken(t, discovery.JWKSURI, tokens.IDToken)
+	assert.Equal(t, srv.URL, claims["iss"])
+	assert.Equal(t, app.ID, claims["aud"])
+	assert.Equal(t, user.ID.String(), claims["sub"])
+	assert.Equal(t, "n-0S6", claims["nonce"])
+	assert.Equal(t, "jdoe", claims["preferred_username"])
+	assert.Equal(t, "jdoe@example.com", claims["email"])
+	assert.Equal(t, "John Doe", claims["name"])
+
+	// Codes work once
+	resp = exchange(verifier)
+	var oauthErr struct {
+		Error string `json:"error"`
+	}
+	require.NoError(t, json.NewDecoder(resp.Body).Decode(&oauthErr))
+	_ = resp.Body.Close()
+	assert.Equal(t, http.StatusBadRequest, resp.StatusCode)
+	assert.Equal(t, "invalid_grant", oauthErr.Error)
+
+	userInfo := func() (*http.Response, model.UserInfo) {
+		req, err := http.NewRequest(http.MethodGet, discovery.UserInfoEndpoint, nil)
+		require.NoError(t, err)
+		req.Header.Set("Authorization", "Bearer "+tokens.AccessToken)
+		resp, err := http.DefaultClient.Do(req)
+		require.NoError(t, err)
+		defer func() { _ = resp.Body.Close() }()
+		var info model.UserInfo
+		_ = json.NewDecoder(resp.Body).Decode(&info)
+		return resp, info
+	}
+	resp, info := userInfo()
+	require.Equal(t, http.StatusOK, resp.StatusCode)
+	assert.Equal(t, user.ID.String(), info.Subject)
+	assert.Equal(t, "jdoe", info.PreferredUsername)
+
+	// The access token is for the userinfo endpoint only, not for this API
+	tc, err := New(srv.URL, WithBearerToken(tokens.AccessToken))
+	require.NoError(t, err)
+	_, err = tc.ListUsers(ctx)
+	var apiErr *APIError
+	require.True(t, errors.As(err, &apiErr))
+	assert.Equal(t, http.StatusUnauthorized, apiErr.StatusCode)
+
+	consents, err := c.ListOAuthConsents(ctx, user.ID)
+	require.NoError(t, err)
+	require.Len(t, consents, 1)
+	assert.Equal(t, app.ID, consents[0].ClientID)
+	require.NoError(t, c.RevokeOAuthConsent(ctx, user.ID, app.ID))
+	assert.ErrorIs(t, c.RevokeOAuthConsent(ctx, user.ID, app.ID), ErrConsentNotFound)
+
+	resp, _ = userInfo()
+	assert.Equal(t, http.StatusUnauthorized, resp.StatusCode)
+	assert.Contains(t, resp.Header.Get("WWW-Authenticate"), "invalid_token")
+}
+
+// scimRequest sends a SCIM request with the admin key and decodes the JSON response, if any.
+func scimRequest(t *testing.T, method, url, body string) (*http.Response, map[string]any) {
+	t.Helper()
+	req, err := http.NewRequest(method, url, strings.NewReader(body))
+	require.NoError(t, err)
+	req.Header.Set("X-API-Key", testAPIKey)
+	req.Header.Set("Content-Type", "application/scim+json")
+	resp, err := http.DefaultClient.Do(req)
+	require.NoError(t, err)
+	defer func() { _ = resp.Body.Close() }()
+	var out map[string]any
+	if resp.StatusCode != http.StatusNoContent {
+		require.NoError(t, json.NewDecoder(resp.Body).Decode(&out))
+	}
+	return resp, out
+}
+
+func TestClient_Organizations(t *testing.T) {
+	srv := newTestServer(t)
+	admin := newTestClient(t, srv.URL)
+	acme := newTestClient(t, srv.URL, WithAPIKey(acmeAPIKey))
+	ctx := context.Background()
+	statusOf := func(err error) int {
+		var apiErr *APIError
+		require.True(t, errors.As(err, &apiErr), "%v", err)
+		return apiErr.StatusCode
+	}
+	jdoe := CreateUserRequest{Username: "jdoe", Email: "jdoe@example.com", FullName: "John Doe"}
+
+	// The same username in two organizations
+	ours, err := admin.CreateUser(ctx, jdoe)
+	require.NoError(t, err)
+	theirs, err := acme.CreateUser(ctx, jdoe)
+	require.NoError(t, err)
+	assert.NotEqual(t, ours.OrganizationID, theirs.OrganizationID)
+	_, err = acme.CreateUser(ctx, jdoe)
+	assert.Equal(t, http.StatusConflict, statusOf(err))
+
+	_, err = acme.GetUser(ctx, ours.ID)
+	assert.ErrorIs(t, err, ErrUserNotFound)
+	all, err := admin.ListUsers(ctx)
+	require.NoError(t, err)
+	require.Len(t, all, 1)
+	assert.Equal(t, ours.ID, all[0].ID)
+
+	// Admins may act in any organization, by slug or ID; others only in their own
+	got, err := newTestClient(t, srv.URL, WithOrganization("acme")).GetUserByUsername(ctx, "jdoe")
+	require.NoError(t, err)
+	assert.Equal(t, theirs.ID, got.ID)
+	got, err = newTestClient(t, srv.URL, WithOrganization(theirs.OrganizationID.String())).GetUser(ctx, theirs.ID)
+	require.NoError(t, err)
+	assert.Equal(t, theirs.ID, got.ID)
+	_, err = newTestClient(t, srv.URL, WithAPIKey(acmeAPIKey), WithOrganization("acme")).GetUser(ctx, theirs.ID)
+	assert.NoError(t, err)
+	_, err = newTestClient(t, srv.URL, WithAPIKey(acmeAPIKey), WithOrganization("default")).GetUser(ctx, ours.ID)
+	assert.Equal(t, http.StatusForbidden, statusOf(err))
+	_, err = newTestClient(t, srv.URL, WithOrganization("nowhere")).ListUsers(ctx)
+	assert.ErrorIs(t, err, ErrInvalidInput)
+
+	// Access tokens keep the organization of the API key they were issued for
+	issued, err := newTestClient(t, srv.URL, WithAPIKey(acmeAPIKey)).IssueToken(ctx)
+	require.NoError(t, err)
+	tc, err := New(srv.URL, WithBearerToken(issued.AccessToken))
+	require.NoError(t, err)
+	got, err = tc.GetUser(ctx, theirs.ID)
+	require.NoError(t, err)
+	assert.Equal(t, theirs.ID, got.ID)
+
+	// Managing organizations needs the admin scope
+	created, err := admin.CreateOrganization(ctx, CreateOrganizationRequest{Slug: "Globex", Name: "Globex"})
+	require.NoError(t, err)
+	assert.Equal(t, "globex", created.Slug)
+	_, err = admin.CreateOrganization(ctx, CreateOrganizationRequest{Slug: "globex", Name: "Globex again"})
+	assert.ErrorIs(t, err, ErrOrganizationExists)
+	fetched, err := admin.GetOrganization(ctx, created.ID)
+	require.NoError(t, err)
+	assert.Equal(t, *created, *fetched)
+	_, err = admin.GetOrganization(ctx, uuid.New())
+	assert.ErrorIs(t, err, ErrOrganizationNotFound)
+	orgs, err := admin.ListOrganizations(ctx)
+	require.NoError(t, err)
+	assert.Len(t, orgs, 3)
+	_, err = acme.ListOrganizations(ctx)
+	assert.Equal(t, http.StatusForbidden, statusOf(err))
+}
+
+func TestClient_Groups(t *testing.T) {
+	srv := newTestServer(t)
+	c := newTestClient(t, srv.URL)
+	ctx := context.Background()
+
+	alice, err := c.CreateUser(ctx, CreateUserRequest{Username: "alice", Email: "alice@example.com", FullName: "Alice Smith"})
+	require.NoError(t, err)
+	bob, err := c.CreateUser(ctx, CreateUserRequest{Username: "bob", Email: "bob@example.com", FullName: "Bob Jones"})
+	require.NoError(t, err)
+	engineering, err := c.CreateGroup(ctx, CreateGroupRequest{Name: "engineering", Description: "Everyone who builds"})
+	require.NoError(t, err)
+	backend, err := c.CreateGroup(ctx, CreateGroupRequest{Name: "backend"})
+	require.NoError(t, err)
+	_, err = c.CreateGroup(ctx, CreateGroupRequest{Name: "backend"})
+	assert.ErrorIs(t, err, ErrGroupExists)
+
+	member, err := c.AddGroupSubgroup(ctx, engineering.ID, backend.ID)
+	require.NoError(t, err)
+	assert.Equal(t, "backend", member.Name)
+	assert.Equal(t, auth.DefaultClientID, member.AddedBy)
+	_, err = c.AddGroupUser(ctx, backend.ID, alice.ID)
+	require.NoError(t, err)
+	_, err = c.AddGroupUser(ctx, engineering.ID, bob.ID)
+	require.NoError(t, err)
+	_, err = c.AddGroupUser(ctx, engineering.ID, bob.ID)
+	assert.ErrorIs(t, err, ErrGroupMemberExists)
+	_, err = c.AddGroupSubgroup(ctx, backend.ID, engineering.ID)
+	assert.ErrorIs(t, err, ErrGroupCycle)
+	_, err = c.AddGroupUser(ctx, backend.ID, uuid.New())
+	assert.ErrorIs(t, err, ErrInvalidInput)
+
+	members, err := c.GroupMembers(ctx, engineering.ID)
+	require.NoError(t, err)
+	require.Len(t, members, 2)
+	assert.Equal(t, GroupMember{Type: "group", ID: backend.ID, Name: "backend", AddedBy: member.AddedBy, AddedAt: members[0].AddedAt}, members[0])
+	assert.Equal(t, bob.ID, members[1].ID)
+
+	effective, err := c.EffectiveGroupMembers(ctx, engineering.ID)
+	require.NoError(t, err)
+	require.Len(t, effective, 2)
+	assert.Equal(t, "alice", effective[0].Username)
+	assert.Equal(t, "bob", effective[1].Username)
+
+	direct, err := c.UserGroups(ctx, alice.ID, false)
+	require.NoError(t, err)
+	require.Len(t, direct, 1)
+	assert.Equal(t, backend.ID, direct[0].ID)
+	all, err := c.UserGroups(ctx, alice.ID, true)
+	require.NoError(t, err)
+	require.Len(t, all, 2)
+	assert.Equal(t, "backend", all[0].Name)
+	assert.Equal(t, "engineering", all[1].Name)
+	_, err = c.UserGroups(ctx, uuid.New(), true)
+	assert.ErrorIs(t, err, ErrUserNotFound)
+
+	require.NoError(t, c.RemoveGroupSubgroup(ctx, engineering.ID, backend.ID))
+	assert.ErrorIs(t, c.RemoveGroupSubgroup(ctx, engineering.ID, backend.ID), ErrGroupMemberNotFound)
+	assert.ErrorIs(t, c.RemoveGroupUser(ctx, engineering.ID, alice.ID), ErrGroupMemberNotFound)
+	history, err := c.GroupHistory(ctx, engineering.ID)
+	require.NoError(t, err)
+	require.Len(t, history, 3)
+	assert.Equal(t, "removed", history[0].Action)
+	assert.Equal(t, "backend", history[0].MemberName)
+
+	renamed, err := c.UpdateGroup(ctx, engineering.ID, UpdateGroupRequest{Name: String("platform")})
+	require.NoError(t, err)
+	assert.Equal(t, "platform", renamed.Name)
+	assert.Equal(t, "Everyone who builds", renamed.Description)
+	require.NoError(t, c.DeleteGroup(ctx, engineering.ID))
+	_, err = c.GetGroup(ctx, engineering.ID)
+	assert.ErrorIs(t, err, ErrGroupNotFound)
+	groups, err := c.ListGroups(ctx)
+	require.NoError(t, err)
+	require.Len(t, groups, 1)
+	assert.Equal(t, backend.ID, groups[0].ID)
+
+	// Groups belong to the caller's organization
+	_, err = newTestClient(t, srv.URL, WithAPIKey(acmeAPIKey)).GetGroup(ctx, backend.ID)
+	assert.ErrorIs(t, err, ErrGroupNotFound)
+}
+
+func TestClient_Invitations(t *testing.T) {
+	srv := newTestServer(t)
+	c := newTestClient(t, srv.URL)
+	ctx := context.Background()
+
+	_, err := c.CreateUser(ctx, CreateUserRequest{Username: "jdoe", Email: "jdoe@example.com", FullName: "John Doe"})
+	require.NoError(t, err)
+	_, err = c.CreateInvitation(ctx, "jdoe@example.com")
+	assert.ErrorIs(t, err, ErrEmailExists)
+
+	invitation, err := c.CreateInvitation(ctx, "New@Example.com")
+	require.NoError(t, err)
+	assert.Equal(t, "new@example.com", invitation.Email)
+	assert.Equal(t, InvitationPending, invitation.Status)
+	assert.Equal(t, auth.DefaultClientID, invitation.InvitedBy)
+	_, err = c.CreateInvitation(ctx, "new@example.com")
+	assert.ErrorIs(t, err, ErrInvitationExists)
+	assert.NotErrorIs(t, err, ErrEmailExists)
+
+	pending, err := c.ListInvitations(ctx)
+	require.NoError(t, err)
+	require.Len(t, pending, 1)
+	assert.Equal(t, invitation.ID, pending[0].ID)
+	resent, err := c.ResendInvitation(ctx, invitation.ID)
+	require.NoError(t, err)
+	assert.False(t, resent.SentAt.Before(invitation.SentAt))
+
+	_, err = c.RedeemInvitation(ctx, RedeemInvitationRequest{Token: "not-a-token", Username: "newhire", FullName: "New Hire"})
+	assert.ErrorIs(t, err, ErrInvitationTokenInvalid)
+	_, err = c.RedeemInvitation(ctx, RedeemInvitationRequest{Token: "not-a-token"})
+	assert.ErrorIs(t, err, ErrInvalidInput)
+	assert.NotErrorIs(t, err, ErrInvitationTokenInvalid)
+
+	revoked, err := c.RevokeInvitation(ctx, invitation.ID)
+	require.NoError(t, err)
+	assert.Equal(t, InvitationRevoked, revoked.Status)
+	_, err = c.RevokeInvitation(ctx, invitation.ID)
+	assert.ErrorIs(t, err, ErrInvitationNotPending)
+	_, err = c.ResendInvitation(ctx, invitation.ID)
+	assert.ErrorIs(t, err, ErrInvitationNotPending)
+	got, err := c.GetInvitation(ctx, invitation.ID)
+	require.NoError(t, err)
+	assert.Equal(t, InvitationRevoked, got.Status)
+	pending, err = c.ListInvitations(ctx)
+	require.NoError(t, err)
+	assert.Empty(t, pending)
+
+	// Invitations belong to the caller's organization
+	_, err = newTestClient(t, srv.URL, WithAPIKey(acmeAPIKey)).GetInvitation(ctx, invitation.ID)
+	assert.ErrorIs(t, err, ErrInvitationNotFound)
+	_, err = c.GetInvitation(ctx, uuid.New())
+	assert.ErrorIs(t, err, ErrInvitationNotFound)
+}
+
+func TestSCIM_Provisioning(t *testing.T) {
+	srv := newTestServer(t)
+	c := newTestClient(t, srv.URL)
+	users := srv.URL + "/scim/v2/Users"
+
+	resp, config := scimRequest(t, http.MethodGet, srv.URL+"/scim/v2/ServiceProviderConfig", "")
+	require.Equal(t, http.StatusOK, resp.StatusCode)
+	assert.Equal(t, "application/scim+json", resp.Header.Get("Content-Type"))
+	assert.Equal(t, true, config["patch"].(map[string]any)["supported"])
+
+	resp, created := scimRequest(t, http.MethodPost, users, `{
+		"schemas": ["urn:ietf:params:scim:schemas:core:2.0:User"],
+		"userName": "JDoe",
+		"name": {"givenName": "John", "familyName": "Doe"},
+		"emails": [{"value": "jdoe@example.com", "type": "work", "primary": true}],
+		"active": true
+	}`)
+	require.Equal(t, http.StatusCreated, resp.StatusCode, created)
+	id := created["id"].(string)
+	assert.Equal(t, "/scim/v2/Users/"+id, resp.Header.Get("Location"))
+	assert.Equal(t, "jdoe", created["userName"])
+	assert.Equal(t, "John Doe", created["name"].(map[string]any)["formatted"])
+
+	for _, name := range []string{"alice", "bob"} {
+		_, err := c.CreateUser(context.Background(), CreateUserRequest{Username: name, Email: name + "@example.org", FullName: "Test User"})
+		require.NoError(t, err)
+	}
+
+	// The query identity providers make before provisioning a user
+	resp, list := scimRequest(t, http.MethodGet, users+"?filter="+url.QueryEscape(`userName eq "jdoe"`), "")
+	require.Equal(t, http.StatusOK, resp.StatusCode)
+	assert.Equal(t, float64(1), list["totalResults"])
+	assert.Equal(t, id, list["Resources"].([]any)[0].(map[string]any)["id"])
+
+	_, list = scimRequest(t, http.MethodGet, users+"?filter="+url.QueryEscape(`emails.value co "@example.org"`)+"&startIndex=2&count=5", "")
+	assert.Equal(t, float64(2), list["totalResults"])
+	assert.Equal(t, float64(2), list["startIndex"])
+	assert.Equal(t, float64(1), list["itemsPerPage"])
+
+	resp, scimErr := scimRequest(t, http.MethodGet, users+"?filter="+url.QueryEscape(`nickName eq "x"`), "")
+	assert.Equal(t, http.StatusBadRequest, resp.StatusCode)
+	assert.Equal(t, "invalidFilter", scimErr["scimType"])
+	assert.Equal(t, "400", scimErr["status"])
+
+	resp, patched := scimRequest(t, http.MethodPatch, users+"/"+id, `{
+		"schemas": ["urn:ietf:params:scim:api:messages:2.0:PatchOp"],
+		"Operations": [
+			{"op": "replace", "path": "name.formatted", "value": "Johnny Doe"},
+			{"op": "replace", "path": "emails[type eq \"work\"].value", "value": "johnny@example.com"}
+		]
+	}`)
+	require.Equal(t, http.StatusOK, resp.StatusCode, patched)
+	assert.Equal(t, "Johnny Doe", patched["displayName"])
+	// As in the REST API, the new address waits for confirmation
+	assert.Equal(t, "jdoe@example.com", patched["emails"].([]any)[0].(map[string]any)["value"])
+	user, err := c.GetUser(context.Background(), uuid.MustParse(id))
+	require.NoError(t, err)
+	require.NotNil(t, user.PendingEmailChange)
+	assert.Equal(t, "johnny@example.com", user.PendingEmailChange.NewEmail)
+
+	resp, scimErr = scimRequest(t, http.MethodPut, users+"/"+id, `{
+		"schemas": ["urn:ietf:params:scim:schemas:core:2.0:User"],
+		"userName": "alice",
+		"displayName": "John Doe",
+		"emails": [{"value": "jdoe@example.com"}]
+	}`)
+	assert.Equal(t, http.StatusConflict, resp.StatusCode)
+	assert.Equal(t, "uniqueness", scimErr["scimType"])
+
+	resp, _ = scimRequest(t, http.MethodDelete, users+"/"+id, "")
+	assert.Equal(t, http.StatusNoContent, resp.StatusCode)
+	resp, scimErr = scimRequest(t, http.MethodDelete, users+"/"+id, "")
+	assert.Equal(t, http.StatusNotFound, resp.StatusCode)
+	assert.Equal(t, []any{"urn:ietf:params:scim:api:messages:2.0:Error"}, scimErr["schemas"])
+}
+
+func TestClient_PaginationIterator(t *testing.T) {
+	srv := newTestServer(t)
+	c := newTestClient(t, srv.URL)
+	ctx := context.Background()
+
+	want := map[uuid.UUID]bool{}
+	for i := 0; i < 7; i++ {
+		u, err := c.CreateUser(ctx, CreateUserRequest{
+			Username: fmt.Sprintf("user%d", i),
+			Email:    fmt.Sprintf("user%d@example.com", i),
+			FullName: "Test User",
+		})
+		require.NoError(t, err)
+		want[u.ID] = true
+	}
+
+	page, err := c.ListUsersPage(ctx, PageOptions{Limit: 3})
+	require.NoError(t, err)
+	assert.Len(t, page.Users, 3)
+	require.NotNil(t, page.Next)
+
+	seen := map[uuid.UUID]bool{}
+	for u, err := range c.Users(ctx, 3) {
+		require.NoError(t, err)
+		assert.False(t, seen[u.ID], "user returned twice")
+		seen[u.ID] = true
+	}
+	assert.Equal(t, want, seen)
+}
+
+func TestClient_RetriesHonoringRetryAfter(t *testing.T) {
+	var calls atomic.Int32
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch calls.Add(1) {
+		case 1:
+			w.Header().Set("Retry-After", "0")
+			w.WriteHeader(http.StatusTooManyRequests)
+		case 2:
+			w.WriteHeader(http.StatusServiceUnavailable)
+		default:
+			w.Header().Set("Content-Type", "application/json")
+			_, _ = w.Write([]byte(`[]`))
+		}
+	}))
+	t.Cleanup(srv.Close)
+	c := newTestClient(t, srv.URL)
+
+	users, err := c.ListUsers(context.Background())
+
+	require.NoError(t, err)
+	assert.Empty(t, users)
+	assert.Equal(t, int32(3), calls.Load())
+}
+
+func TestClient_GivesUpAfterMaxRetries(t *testing.T) {
+	var calls atomic.Int32
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		calls.Add(1)
+		w.WriteHeader(http.StatusServiceUnavailable)
+	}))
+	t.Cleanup(srv.Close)
+	c := newTestClient(t, srv.URL, WithRetry(2, time.Millisecond, time.Millisecond))
+
+	_, err := c.ListUsers(context.Background())
+
+	var apiErr *APIError
+	require.True(t, errors.As(err, &apiErr))
+	assert.Equal(t, http.StatusServiceUnavailable, apiErr.StatusCode)
+	assert.Equal(t, int32(3), calls.Load())
+}
+
+func TestClient_RetriesPostOnlyWhenNotProcessed(t *testing.T) {
+	var status atomic.Int32
+	var calls atomic.Int32
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if calls.Add(1) == 1 {
+			w.WriteHeader(int(status.Load()))
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusCreated)
+		_, _ = w.Write([]byte(`{"username":"jdoe"}`))
+	}))
+	t.Cleanup(srv.Close)
+	c := newTestClient(t, srv.URL)
+	req := CreateUserRequest{Username: "jdoe", Email: "jdoe@example.com", FullName: "John Doe"}
+
+	// A gateway may answer 503 after the service created the user, so the create is not sent again
+	status.Store(http.StatusServiceUnavailable)
+	_, err := c.CreateUser(context.Background(), req)
+	var apiErr *APIError
+	require.True(t, errors.As(err, &apiErr))
+	assert.Equal(t, http.StatusServiceUnavailable, apiErr.StatusCode)
+	assert.Equal(t, int32(1), calls.Load())
+
+	// A 429 means the request was turned away unprocessed
+	calls.Store(0)
+	status.Store(http.StatusTooManyRequests)
+	user, err := c.CreateUser(context.Background(), req)
+	require.NoError(t, err)
+	assert.Equal(t, "jdoe", user.Username)
+	assert.Equal(t, int32(2), calls.Load())
+}
+
+func TestParseRetryAfter(t *testing.T) {
+	now := time.Date(2025, 11, 1, 12, 0, 0, 0, time.UTC)
+
+	d, ok := parseRetryAfter("5", now)
+	assert.True(t, ok)
+	assert.Equal(t, 5*time.Second, d)
+
+	d, ok = parseRetryAfter(now.Add(10*time.Second).Format(http.TimeFormat), now)
+	assert.True(t, ok)
+	assert.Equal(t, 10*time.Second, d)
+
+	_, ok = parseRetryAfter("soon", now)
+	assert.False(t, ok)
+}
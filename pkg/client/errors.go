@@ -0,0 +1,142 @@
+package client
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+)
+
+// Domain errors. The API tells them apart by the "code" field of its error responses,
+// so errors.Is works on anything returned by this client.
+var (
+	ErrUserNotFound     = errors.New("user not found")
+	ErrInvalidInput     = errors.New("invalid input")
+	ErrUsernameExists   = errors.New("username already exists")
+	ErrUsernameReserved = errors.New("username is reserved")
+	ErrEmailExists      = errors.New("email already exists")
+	ErrStatusTransition = errors.New("status transition not allowed")
+	ErrJobNotFound      = errors.New("job not found")
+	ErrJobFinished      = errors.New("job already finished")
+	ErrJobNotReady      = errors.New("job result not available")
+
+	ErrInvalidCredentials = errors.New("invalid username or password")
+	ErrUserNotActive      = errors.New("user account is not active")
+	ErrWrongPassword      = errors.New("current password is incorrect")
+	ErrScopeNotGranted    = errors.New("scope not granted")
+	ErrAuthThrottled      = errors.New("too many failed authentication attempts")
+	ErrBanNotFound        = errors.New("no ban for this client")
+
+	ErrVerificationTokenInvalid = errors.New("verification token is invalid or expired")
+	ErrEmailAlreadyVerified     = errors.New("email address is already verified")
+	ErrEmailChangeTokenInvalid  = errors.New("email change token is invalid or expired")
+
+	ErrOAuthClientNotFound = errors.New("OAuth client not found")
+	ErrConsentNotFound     = errors.New("consent not found")
+
+	ErrOrganizationNotFound = errors.New("organization not found")
+	ErrOrganizationExists   = errors.New("organization slug already exists")
+
+	ErrGroupNotFound       = errors.New("group not found")
+	ErrGroupExists         = errors.New("group name already exists")
+	ErrGroupMemberExists   = errors.New("already a member of the group")
+	ErrGroupMemberNotFound = errors.New("not a member of the group")
+	ErrGroupCycle          = errors.New("group membership would create a cycle")
+
+	ErrInvitationNotFound     = errors.New("invitation not found")
+	ErrInvitationExists       = errors.New("a pending invitation for this email already exists")
+	ErrInvitationNotPending   = errors.New("invitation has already been redeemed or revoked")
+	ErrInvitationTokenInvalid = errors.New("invitation token is invalid or expired")
+)
+
+// sentinels maps the "code" field of error responses to the domain errors above
+var sentinels = map[string]error{
+	"user_not_found":    ErrUserNotFound,
+	"invalid_input":     ErrInvalidInput,
+	"username_exists":   ErrUsernameExists,
+	"username_reserved": ErrUsernameReserved,
+	"email_exists":      ErrEmailExists,
+	"status_transition": ErrStatusTransition,
+	"job_not_found":     ErrJobNotFound,
+	"job_finished":      ErrJobFinished,
+	"job_not_ready":     ErrJobNotReady,
+
+	"invalid_credentials": ErrInvalidCredentials,
+	"user_not_active":     ErrUserNotActive,
+	"wrong_password":      ErrWrongPassword,
+	"scope_not_granted":   ErrScopeNotGranted,
+	"auth_throttled":      ErrAuthThrottled,
+	"ban_not_found":       ErrBanNotFound,
+
+	"verification_token_invalid": ErrVerificationTokenInvalid,
+	"email_already_verified":     ErrEmailAlreadyVerified,
+	"email_change_token_invalid": ErrEmailChangeTokenInvalid,
+
+	"oauth_client_not_found": ErrOAuthClientNotFound,
+	"consent_not_found":      ErrConsentNotFound,
+
+	"organization_not_found": ErrOrganizationNotFound,
+	"organization_exists":    ErrOrganizationExists,
+
+	"group_not_found":        ErrGroupNotFound,
+	"group_exists":           ErrGroupExists,
+	"group_member_exists":    ErrGroupMemberExists,
+	"group_member_not_found": ErrGroupMemberNotFound,
+	"group_cycle":            ErrGroupCycle,
+
+	"invitation_not_found":     ErrInvitationNotFound,
+	"invitation_exists":        ErrInvitationExists,
+	"invitation_not_pending":   ErrInvitationNotPending,
+	"invitation_token_invalid": ErrInvitationTokenInvalid,
+}
+
+// APIError is returned for any non-2xx response.
+// It unwraps to the matching domain error when the response maps to one.
+type APIError struct {
+	StatusCode int
+	// Code and Message are the "error" and "message" fields of the response body
+	Code    string
+	Message string
+	// ErrorCode is the "code" field, e.g. "user_not_found"; empty for errors no sentinel matches
+	ErrorCode string
+	// Details holds per-field validation messages, if any
+	Details map[string]string
+
+	sentinel error
+}
+
+func (e *APIError) Error() string {
+	if e.Message != "" {
+		return fmt.Sprintf("users api: %d %s: %s", e.StatusCode, e.Code, e.Message)
+	}
+	return fmt.Sprintf("users api: %d %s", e.StatusCode, e.Code)
+}
+
+func (e *APIError) Unwrap() error {
+	return e.sentinel
+}
+
+func newAPIError(resp *http.Response) *APIError {
+	apiErr := &APIError{StatusCode: resp.StatusCode}
+
+	var body struct {
+		Error   string            `json:"error"`
+		Code    string            `json:"code"`
+		Message string            `json:"message"`
+		Details map[string]string `json:"details"`
+	}
+	data, _ := io.ReadAll(io.LimitReader(resp.Body, 1<<20))
+	if err := json.Unmarshal(data, &body); err == nil {
+		apiErr.Code = body.Error
+		apiErr.ErrorCode = body.Code
+		apiErr.Message = body.Message
+		apiErr.Details = body.Details
+	}
+	if apiErr.Code == "" {
+		apiErr.Code = http.StatusText(resp.StatusCode)
+	}
+
+	apiErr.sentinel = sentinels[apiErr.ErrorCode]
+	return apiErr
+}
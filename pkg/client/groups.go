@@ -0,0 +1,140 @@
+package client
+
+import (
+	"context"
+	"cruder/internal/model"
+	"net/http"
+	"net/url"
+
+	"github.com/google/uuid"
+)
+
+// Groups of users, which may contain other groups.
+type (
+	Group                 = model.Group
+	CreateGroupRequest    = model.CreateGroupRequest
+	UpdateGroupRequest    = model.UpdateGroupRequest
+	GroupMember           = model.GroupMember
+	AddGroupMemberRequest = model.AddGroupMemberRequest
+	GroupMembershipChange = model.GroupMembershipChange
+)
+
+const groupsPath = "/api/v1/groups"
+
+func groupPath(id uuid.UUID) string {
+	return groupsPath + "/" + id.String()
+}
+
+// CreateGroup adds a group, or fails with ErrGroupExists if its name is taken.
+func (c *Client) CreateGroup(ctx context.Context, req CreateGroupRequest) (*Group, error) {
+	var group Group
+	if _, err := c.do(ctx, http.MethodPost, groupsPath, nil, req, &group); err != nil {
+		return nil, err
+	}
+	return &group, nil
+}
+
+// ListGroups returns every group, by name.
+func (c *Client) ListGroups(ctx context.Context) ([]Group, error) {
+	var groups []Group
+	if _, err := c.do(ctx, http.MethodGet, groupsPath, nil, nil, &groups); err != nil {
+		return nil, err
+	}
+	return groups, nil
+}
+
+// GetGroup returns a group, or ErrGroupNotFound.
+func (c *Client) GetGroup(ctx context.Context, id uuid.UUID) (*Group, error) {
+	var group Group
+	if _, err := c.do(ctx, http.MethodGet, groupPath(id), nil, nil, &group); err != nil {
+		return nil, err
+	}
+	return &group, nil
+}
+
+// UpdateGroup renames a group or changes its description.
+func (c *Client) UpdateGroup(ctx context.Context, id uuid.UUID, req UpdateGroupRequest) (*Group, error) {
+	var group Group
+	if _, err := c.do(ctx, http.MethodPatch, groupPath(id), nil, req, &group); err != nil {
+		return nil, err
+	}
+	return &group, nil
+}
+
+// DeleteGroup removes a group; its members are kept.
+func (c *Client) DeleteGroup(ctx context.Context, id uuid.UUID) error {
+	_, err := c.do(ctx, http.MethodDelete, groupPath(id), nil, nil, nil)
+	return err
+}
+
+// AddGroupUser adds a user to a group.
+func (c *Client) AddGroupUser(ctx context.Context, id, userID uuid.UUID) (*GroupMember, error) {
+	return c.addGroupMember(ctx, id, AddGroupMemberRequest{UserID: &userID})
+}
+
+// AddGroupSubgroup nests a group in another, or fails with ErrGroupCycle if the group already
+// contains the other one.
+func (c *Client) AddGroupSubgroup(ctx context.Context, id, groupID uuid.UUID) (*GroupMember, error) {
+	return c.addGroupMember(ctx, id, AddGroupMemberRequest{GroupID: &groupID})
+}
+
+func (c *Client) addGroupMember(ctx context.Context, id uuid.UUID, req AddGroupMemberRequest) (*GroupMember, error) {
+	var member GroupMember
+	if _, err := c.do(ctx, http.MethodPost, groupPath(id)+"/members", nil, req, &member); err != nil {
+		return nil, err
+	}
+	return &member, nil
+}
+
+// RemoveGroupUser removes a user from a group, or fails with ErrGroupMemberNotFound.
+func (c *Client) RemoveGroupUser(ctx context.Context, id, userID uuid.UUID) error {
+	_, err := c.do(ctx, http.MethodDelete, groupPath(id)+"/members/users/"+userID.String(), nil, nil, nil)
+	return err
+}
+
+// RemoveGroupSubgroup removes a nested group from a group, or fails with ErrGroupMemberNotFound.
+func (c *Client) RemoveGroupSubgroup(ctx context.Context, id, groupID uuid.UUID) error {
+	_, err := c.do(ctx, http.MethodDelete, groupPath(id)+"/members/groups/"+groupID.String(), nil, nil, nil)
+	return err
+}
+
+// GroupMembers lists the direct members of a group: nested groups, then users.
+func (c *Client) GroupMembers(ctx context.Context, id uuid.UUID) ([]GroupMember, error) {
+	var members []GroupMember
+	if _, err := c.do(ctx, http.MethodGet, groupPath(id)+"/members", nil, nil, &members); err != nil {
+		return nil, err
+	}
+	return members, nil
+}
+
+// EffectiveGroupMembers lists every user in a group, directly or through nested groups.
+func (c *Client) EffectiveGroupMembers(ctx context.Context, id uuid.UUID) ([]User, error) {
+	var users []User
+	if _, err := c.do(ctx, http.MethodGet, groupPath(id)+"/effective-members", nil, nil, &users); err != nil {
+		return nil, err
+	}
+	return users, nil
+}
+
+// GroupHistory lists a group's membership changes, newest first.
+func (c *Client) GroupHistory(ctx context.Context, id uuid.UUID) ([]GroupMembershipChange, error) {
+	var history []GroupMembershipChange
+	if _, err := c.do(ctx, http.MethodGet, groupPath(id)+"/history", nil, nil, &history); err != nil {
+		return nil, err
+	}
+	return history, nil
+}
+
+// UserGroups lists the groups a user is a direct member of or, if effective, also those that
+// contain them through nesting.
+func (c *Client) UserGroups(ctx context.Context, userID uuid.UUID, effective bool) ([]Group, error) {
+	var query url.Values
+	if effective {
+		query = url.Values{"effective": {"true"}}
+	}
+	var groups []Group
+	if _, err := c.do(ctx, http.MethodGet, usersPath+"/id/"+userID.String()+"/groups", query, nil, &groups); err != nil {
+		return nil, err
+	}
+	return groups, nil
+}
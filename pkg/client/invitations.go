@@ -0,0 +1,87 @@
+package client
+
+import (
+	"context"
+	"cruder/internal/model"
+	"net/http"
+
+	"github.com/google/uuid"
+)
+
+// Invitations for people to create their own account.
+type (
+	Invitation              = model.Invitation
+	InvitationStatus        = model.InvitationStatus
+	CreateInvitationRequest = model.CreateInvitationRequest
+	RedeemInvitationRequest = model.RedeemInvitationRequest
+)
+
+const (
+	InvitationPending  = model.InvitationPending
+	InvitationExpired  = model.InvitationExpired
+	InvitationRedeemed = model.InvitationRedeemed
+	InvitationRevoked  = model.InvitationRevoked
+)
+
+const invitationsPath = "/api/v1/invitations"
+
+func invitationPath(id uuid.UUID) string {
+	return invitationsPath + "/" + id.String()
+}
+
+// CreateInvitation mails email an invitation, or fails with ErrEmailExists if a user has the
+// address and ErrInvitationExists if it has a pending invitation.
+func (c *Client) CreateInvitation(ctx context.Context, email string) (*Invitation, error) {
+	var invitation Invitation
+	if _, err := c.do(ctx, http.MethodPost, invitationsPath, nil, CreateInvitationRequest{Email: email}, &invitation); err != nil {
+		return nil, err
+	}
+	return &invitation, nil
+}
+
+// ListInvitations returns the invitations that can still be redeemed, newest first.
+func (c *Client) ListInvitations(ctx context.Context) ([]Invitation, error) {
+	var invitations []Invitation
+	if _, err := c.do(ctx, http.MethodGet, invitationsPath, nil, nil, &invitations); err != nil {
+		return nil, err
+	}
+	return invitations, nil
+}
+
+// GetInvitation returns an invitation whatever its status, or ErrInvitationNotFound.
+func (c *Client) GetInvitation(ctx context.Context, id uuid.UUID) (*Invitation, error) {
+	var invitation Invitation
+	if _, err := c.do(ctx, http.MethodGet, invitationPath(id), nil, nil, &invitation); err != nil {
+		return nil, err
+	}
+	return &invitation, nil
+}
+
+// ResendInvitation mails a new token and renews the expiry, or fails with ErrInvitationNotPending
+// once the invitation is redeemed or revoked.
+func (c *Client) ResendInvitation(ctx context.Context, id uuid.UUID) (*Invitation, error) {
+	var invitation Invitation
+	if _, err := c.do(ctx, http.MethodPost, invitationPath(id)+"/resend", nil, nil, &invitation); err != nil {
+		return nil, err
+	}
+	return &invitation, nil
+}
+
+// RevokeInvitation withdraws an invitation, or fails with ErrInvitationNotPending.
+func (c *Client) RevokeInvitation(ctx context.Context, id uuid.UUID) (*Invitation, error) {
+	var invitation Invitation
+	if _, err := c.do(ctx, http.MethodPost, invitationPath(id)+"/revoke", nil, nil, &invitation); err != nil {
+		return nil, err
+	}
+	return &invitation, nil
+}
+
+// RedeemInvitation creates the invited user with the address the invitation was sent to. An
+// unknown, redeemed, revoked or expired token fails with ErrInvitationTokenInvalid.
+func (c *Client) RedeemInvitation(ctx context.Context, req RedeemInvitationRequest) (*User, error) {
+	var user User
+	if _, err := c.do(ctx, http.MethodPost, invitationsPath+"/redeem", nil, req, &user); err != nil {
+		return nil, err
+	}
+	return &user, nil
+}
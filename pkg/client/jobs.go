@@ -0,0 +1,108 @@
+package client
+
+import (
+	"context"
+	"cruder/internal/model"
+	"io"
+	"net/http"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// Background jobs, see StartExport and StartImport.
+type (
+	Job         = model.Job
+	JobStatus   = model.JobStatus
+	JobProgress = model.JobProgress
+)
+
+// Job statuses. Succeeded, failed and cancelled are final.
+const (
+	JobQueued    = model.JobQueued
+	JobRunning   = model.JobRunning
+	JobSucceeded = model.JobSucceeded
+	JobFailed    = model.JobFailed
+	JobCancelled = model.JobCancelled
+)
+
+const jobsPath = "/api/v1/jobs"
+
+// ExportJobOptions configure StartExport. Format is one of the Export* media types.
+type ExportJobOptions struct {
+	Format string
+	// Fields limits the exported attributes; empty means all
+	Fields []string
+	// Query exports only the users SearchUsers would return for it
+	Query string
+}
+
+// StartExport queues an export that runs on the server in the background, for exports too large
+// for ExportUsers. Wait for the job, then download the file with JobResult.
+func (c *Client) StartExport(ctx context.Context, opts ExportJobOptions) (*Job, error) {
+	params := model.UserExportParams{Format: opts.Format, Fields: opts.Fields, Query: opts.Query}
+	var job Job
+	if _, err := c.do(ctx, http.MethodPost, usersPath+"/export", nil, params, &job); err != nil {
+		return nil, err
+	}
+	return &job, nil
+}
+
+// StartImport uploads a CSV file like ImportUsers but imports it in the background.
+// The job's result is the ImportReport as JSON.
+func (c *Client) StartImport(ctx context.Context, csvFile io.Reader, opts ImportOptions) (*Job, error) {
+	data, err := io.ReadAll(csvFile)
+	if err != nil {
+		return nil, err
+	}
+
+	var job Job
+	header := http.Header{"Prefer": {"respond-async"}}
+	if _, err := c.doWith(ctx, http.MethodPost, usersPath+"/import", importQuery(opts), header, ExportCSV, rawBody(data), &job); err != nil {
+		return nil, err
+	}
+	return &job, nil
+}
+
+// GetJob fetches a job's status and progress.
+func (c *Client) GetJob(ctx context.Context, id uuid.UUID) (*Job, error) {
+	var job Job
+	if _, err := c.do(ctx, http.MethodGet, jobsPath+"/"+id.String(), nil, nil, &job); err != nil {
+		return nil, err
+	}
+	return &job, nil
+}
+
+// WaitJob polls a job every interval until it is finished and returns it in its final state,
+// whether that is succeeded, failed or cancelled.
+func (c *Client) WaitJob(ctx context.Context, id uuid.UUID, interval time.Duration) (*Job, error) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		job, err := c.GetJob(ctx, id)
+		if err != nil || job.Status.Finished() {
+			return job, err
+		}
+		select {
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		case <-ticker.C:
+		}
+	}
+}
+
+// CancelJob cancels a queued job, or asks a running one to stop; the returned job tells which.
+// Cancelling a finished job fails with ErrJobFinished.
+func (c *Client) CancelJob(ctx context.Context, id uuid.UUID) (*Job, error) {
+	var job Job
+	if _, err := c.do(ctx, http.MethodPost, jobsPath+"/"+id.String()+"/cancel", nil, nil, &job); err != nil {
+		return nil, err
+	}
+	return &job, nil
+}
+
+// JobResult downloads the result of a succeeded job, in its ResultType. The caller must close it.
+// It fails with ErrJobNotReady until the job has succeeded.
+func (c *Client) JobResult(ctx context.Context, id uuid.UUID) (io.ReadCloser, error) {
+	return c.stream(ctx, jobsPath+"/"+id.String()+"/result", nil, "*/*")
+}
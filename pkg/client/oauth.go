@@ -0,0 +1,73 @@
+package client
+
+import (
+	"context"
+	"cruder/internal/model"
+	"net/http"
+	"net/url"
+
+	"github.com/google/uuid"
+)
+
+// Applications that sign users in through the service's OpenID Connect provider, and the
+// consents users gave them.
+type (
+	OAuthClient                = model.OAuthClient
+	RegisterOAuthClientRequest = model.RegisterOAuthClientRequest
+	RegisteredOAuthClient      = model.RegisteredOAuthClient
+	OAuthConsent               = model.OAuthConsent
+)
+
+const oauthClientsPath = "/api/v1/oauth/clients"
+
+// RegisterOAuthClient registers a client application. The returned ClientSecret of a confidential
+// client is not shown again. Invalid redirect URIs fail with ErrInvalidInput. It needs the admin scope.
+func (c *Client) RegisterOAuthClient(ctx context.Context, req RegisterOAuthClientRequest) (*RegisteredOAuthClient, error) {
+	var client RegisteredOAuthClient
+	if _, err := c.do(ctx, http.MethodPost, oauthClientsPath, nil, req, &client); err != nil {
+		return nil, err
+	}
+	return &client, nil
+}
+
+// ListOAuthClients returns every registered client application, oldest first. It needs the admin scope.
+func (c *Client) ListOAuthClients(ctx context.Context) ([]OAuthClient, error) {
+	var clients []OAuthClient
+	if _, err := c.do(ctx, http.MethodGet, oauthClientsPath, nil, nil, &clients); err != nil {
+		return nil, err
+	}
+	return clients, nil
+}
+
+// GetOAuthClient returns a client application, or ErrOAuthClientNotFound. It needs the admin scope.
+func (c *Client) GetOAuthClient(ctx context.Context, id string) (*OAuthClient, error) {
+	var client OAuthClient
+	if _, err := c.do(ctx, http.MethodGet, oauthClientsPath+"/"+url.PathEscape(id), nil, nil, &client); err != nil {
+		return nil, err
+	}
+	return &client, nil
+}
+
+// DeleteOAuthClient deletes a client application with its users' consents, or fails with
+// ErrOAuthClientNotFound. It needs the admin scope.
+func (c *Client) DeleteOAuthClient(ctx context.Context, id string) error {
+	_, err := c.do(ctx, http.MethodDelete, oauthClientsPath+"/"+url.PathEscape(id), nil, nil, nil)
+	return err
+}
+
+// ListOAuthConsents returns the client applications the user allowed to sign them in, most
+// recently updated first.
+func (c *Client) ListOAuthConsents(ctx context.Context, userID uuid.UUID) ([]OAuthConsent, error) {
+	var consents []OAuthConsent
+	if _, err := c.do(ctx, http.MethodGet, usersPath+"/id/"+userID.String()+"/oauth-consents", nil, nil, &consents); err != nil {
+		return nil, err
+	}
+	return consents, nil
+}
+
+// RevokeOAuthConsent withdraws the user's consent to a client application, whose access tokens
+// stop working at the userinfo endpoint. It fails with ErrConsentNotFound if there is none.
+func (c *Client) RevokeOAuthConsent(ctx context.Context, userID uuid.UUID, clientID string) error {
+	_, err := c.do(ctx, http.MethodDelete, usersPath+"/id/"+userID.String()+"/oauth-consents/"+url.PathEscape(clientID), nil, nil, nil)
+	return err
+}
@@ -0,0 +1,45 @@
+package client
+
+import (
+	"context"
+	"cruder/internal/model"
+	"net/http"
+
+	"github.com/google/uuid"
+)
+
+// The tenants users belong to.
+type (
+	Organization              = model.Organization
+	CreateOrganizationRequest = model.CreateOrganizationRequest
+)
+
+const organizationsPath = "/api/v1/organizations"
+
+// CreateOrganization adds an organization, or fails with ErrOrganizationExists if its slug is
+// taken. It needs the admin scope.
+func (c *Client) CreateOrganization(ctx context.Context, req CreateOrganizationRequest) (*Organization, error) {
+	var org Organization
+	if _, err := c.do(ctx, http.MethodPost, organizationsPath, nil, req, &org); err != nil {
+		return nil, err
+	}
+	return &org, nil
+}
+
+// ListOrganizations returns every organization, by slug. It needs the admin scope.
+func (c *Client) ListOrganizations(ctx context.Context) ([]Organization, error) {
+	var orgs []Organization
+	if _, err := c.do(ctx, http.MethodGet, organizationsPath, nil, nil, &orgs); err != nil {
+		return nil, err
+	}
+	return orgs, nil
+}
+
+// GetOrganization returns an organization, or ErrOrganizationNotFound. It needs the admin scope.
+func (c *Client) GetOrganization(ctx context.Context, id uuid.UUID) (*Organization, error) {
+	var org Organization
+	if _, err := c.do(ctx, http.MethodGet, organizationsPath+"/"+id.String(), nil, nil, &org); err != nil {
+		return nil, err
+	}
+	return &org, nil
+}
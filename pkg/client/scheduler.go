@@ -0,0 +1,40 @@
+package client
+
+import (
+	"context"
+	"cruder/internal/model"
+	"net/http"
+	"net/url"
+	"strconv"
+)
+
+// Scheduled maintenance runs, see ListTaskRuns.
+type (
+	TaskRun       = model.TaskRun
+	TaskRunStatus = model.TaskRunStatus
+)
+
+// TaskRun statuses
+const (
+	TaskRunning   = model.TaskRunning
+	TaskSucceeded = model.TaskSucceeded
+	TaskFailed    = model.TaskFailed
+)
+
+// ListTaskRuns returns the latest scheduled maintenance runs, newest first. An empty task lists
+// runs of every task; a zero limit uses the server's default.
+func (c *Client) ListTaskRuns(ctx context.Context, task string, limit int) ([]TaskRun, error) {
+	query := url.Values{}
+	if task != "" {
+		query.Set("task", task)
+	}
+	if limit > 0 {
+		query.Set("limit", strconv.Itoa(limit))
+	}
+
+	var runs []TaskRun
+	if _, err := c.do(ctx, http.MethodGet, "/api/v1/scheduler/runs", query, nil, &runs); err != nil {
+		return nil, err
+	}
+	return runs, nil
+}
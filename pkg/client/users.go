@@ -0,0 +1,378 @@
+package client
+
+import (
+	"context"
+	"cruder/internal/model"
+	"io"
+	"iter"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+
+	"github.com/google/uuid"
+)
+
+// Models shared with the service, so consumers don't re-declare them.
+type (
+	User               = model.User
+	CreateUserRequest  = model.CreateUserRequest
+	UpdateUserRequest  = model.UpdateUserRequest
+	ReplaceUserRequest = model.ReplaceUserRequest
+	ImportReport       = model.ImportReport
+	ImportRow          = model.ImportRow
+	ImportMode         = model.ImportMode
+	UsernameChange     = model.UsernameChange
+	UserStatus         = model.UserStatus
+	UserStatusChange   = model.UserStatusChange
+)
+
+// User lifecycle states, see ActivateUser, SuspendUser and DeactivateUser.
+const (
+	UserInvited     = model.UserInvited
+	UserActive      = model.UserActive
+	UserSuspended   = model.UserSuspended
+	UserDeactivated = model.UserDeactivated
+)
+
+// Import modes and row outcomes, see ImportUsers.
+const (
+	ImportSkipExisting  = model.ImportSkipExisting
+	ImportUpsertByEmail = model.ImportUpsertByEmail
+
+	ImportCreated = model.ImportCreated
+	ImportUpdated = model.ImportUpdated
+	ImportSkipped = model.ImportSkipped
+	ImportFailed  = model.ImportFailed
+)
+
+// String returns a pointer to s, for the optional fields of UpdateUserRequest.
+func String(s string) *string {
+	return &s
+}
+
+const usersPath = "/api/v1/users"
+
+// ListUsers returns every user in a single request.
+// Prefer Users or ListUsersPage for large datasets.
+func (c *Client) ListUsers(ctx context.Context) ([]User, error) {
+	var users []User
+	if _, err := c.do(ctx, http.MethodGet, usersPath, nil, nil, &users); err != nil {
+		return nil, err
+	}
+	return users, nil
+}
+
+// PageOptions selects a page of users. Zero values use the server defaults.
+type PageOptions struct {
+	Limit int
+	After *uuid.UUID
+	// Fields, if set, asks for only these attributes (e.g. "id", "username");
+	// the others are left zero in the returned Users.
+	Fields []string
+	// Status, if set, lists only the users in that state
+	Status UserStatus
+}
+
+// UserPage is one page of users. Next is nil on the last page.
+type UserPage struct {
+	Users []User
+	Next  *PageOptions
+}
+
+// ListUsersPage fetches a single page of users ordered by ID.
+func (c *Client) ListUsersPage(ctx context.Context, opts PageOptions) (*UserPage, error) {
+	query := url.Values{}
+	if opts.Limit > 0 {
+		query.Set("limit", strconv.Itoa(opts.Limit))
+	}
+	if opts.After != nil {
+		query.Set("after", opts.After.String())
+	}
+	if opts.Status != "" {
+		query.Set("status", string(opts.Status))
+	}
+	if len(query) == 0 {
+		// An empty query would return the unpaginated list
+		query.Set("limit", "100")
+	}
+	if len(opts.Fields) > 0 {
+		query.Set("fields", strings.Join(opts.Fields, ","))
+	}
+
+	var users []User
+	resp, err := c.do(ctx, http.MethodGet, usersPath, query, nil, &users)
+	if err != nil {
+		return nil, err
+	}
+
+	page := &UserPage{Users: users}
+	if next, ok := nextPage(resp.Header.Get("Link")); ok {
+		page.Next = next
+	}
+	return page, nil
+}
+
+// Users iterates over all users, fetching pages of pageSize lazily (0 uses the server default).
+// Iteration stops at the first error, which is yielded with a zero User.
+//
+//	for user, err := range c.Users(ctx, 500) {
+//		if err != nil { return err }
+//		...
+//	}
+func (c *Client) Users(ctx context.Context, pageSize int) iter.Seq2[User, error] {
+	return func(yield func(User, error) bool) {
+		opts := PageOptions{Limit: pageSize}
+		for {
+			page, err := c.ListUsersPage(ctx, opts)
+			if err != nil {
+				yield(User{}, err)
+				return
+			}
+			for _, u := range page.Users {
+				if !yield(u, nil) {
+					return
+				}
+			}
+			if page.Next == nil {
+				return
+			}
+			opts = *page.Next
+		}
+	}
+}
+
+// SearchUsers returns the users whose username, email or full name contains q (case-insensitive).
+func (c *Client) SearchUsers(ctx context.Context, q string) ([]User, error) {
+	var users []User
+	if _, err := c.do(ctx, http.MethodGet, usersPath+"/search", url.Values{"q": {q}}, nil, &users); err != nil {
+		return nil, err
+	}
+	return users, nil
+}
+
+// Export media types accepted by ExportUsers
+const (
+	ExportCSV     = "text/csv"
+	ExportNDJSON  = "application/x-ndjson"
+	ExportMsgPack = "application/msgpack"
+)
+
+// ExportUsers streams every user in mediaType, optionally limited to fields.
+// The caller must close the returned body; it is read straight off the connection,
+// so exports of any size run in constant memory on both ends. Exports are not retried.
+func (c *Client) ExportUsers(ctx context.Context, mediaType string, fields []string) (io.ReadCloser, error) {
+	query := url.Values{}
+	if len(fields) > 0 {
+		query.Set("fields", strings.Join(fields, ","))
+	}
+	return c.stream(ctx, usersPath, query, mediaType)
+}
+
+// GetUser fetches a user by ID.
+func (c *Client) GetUser(ctx context.Context, id uuid.UUID) (*User, error) {
+	var user User
+	if _, err := c.do(ctx, http.MethodGet, usersPath+"/id/"+id.String(), nil, nil, &user); err != nil {
+		return nil, err
+	}
+	return &user, nil
+}
+
+// GetUserByUsername fetches a user by username (case-insensitive).
+func (c *Client) GetUserByUsername(ctx context.Context, username string) (*User, error) {
+	var user User
+	if _, err := c.do(ctx, http.MethodGet, usersPath+"/username/"+url.PathEscape(username), nil, nil, &user); err != nil {
+		return nil, err
+	}
+	return &user, nil
+}
+
+// ResolveUsername fetches a user by username, current or former: a username nobody holds now
+// resolves to the user who last gave it up. renamed reports that username is no longer theirs,
+// so callers holding it should switch to user.Username.
+func (c *Client) ResolveUsername(ctx context.Context, username string) (user *User, renamed bool, err error) {
+	user = &User{}
+	query := url.Values{"include_former": {"true"}}
+	resp, err := c.do(ctx, http.MethodGet, usersPath+"/username/"+url.PathEscape(username), query, nil, user)
+	if err != nil {
+		return nil, false, err
+	}
+	return user, resp.Header.Get("Link") != "", nil
+}
+
+// UsernameHistory lists a user's renames, newest first.
+func (c *Client) UsernameHistory(ctx context.Context, id uuid.UUID) ([]UsernameChange, error) {
+	var history []UsernameChange
+	if _, err := c.do(ctx, http.MethodGet, usersPath+"/id/"+id.String()+"/username-history", nil, nil, &history); err != nil {
+		return nil, err
+	}
+	return history, nil
+}
+
+// StatusHistory lists a user's status changes, newest first.
+func (c *Client) StatusHistory(ctx context.Context, id uuid.UUID) ([]UserStatusChange, error) {
+	var history []UserStatusChange
+	if _, err := c.do(ctx, http.MethodGet, usersPath+"/id/"+id.String()+"/status-history", nil, nil, &history); err != nil {
+		return nil, err
+	}
+	return history, nil
+}
+
+// ActivateUser makes an invited or suspended user active; reason may be empty.
+// A transition the user's status does not allow fails with ErrStatusTransition.
+func (c *Client) ActivateUser(ctx context.Context, id uuid.UUID, reason string) (*User, error) {
+	return c.changeStatus(ctx, id, "activate", reason)
+}
+
+// SuspendUser blocks an active user from signing in until they are activated again.
+func (c *Client) SuspendUser(ctx context.Context, id uuid.UUID, reason string) (*User, error) {
+	return c.changeStatus(ctx, id, "suspend", reason)
+}
+
+// DeactivateUser retires a user for good; unlike DeleteUser it keeps the user and their history.
+func (c *Client) DeactivateUser(ctx context.Context, id uuid.UUID, reason string) (*User, error) {
+	return c.changeStatus(ctx, id, "deactivate", reason)
+}
+
+func (c *Client) changeStatus(ctx context.Context, id uuid.UUID, action, reason string) (*User, error) {
+	var user User
+	req := model.ChangeUserStatusRequest{Reason: reason}
+	if _, err := c.do(ctx, http.MethodPost, usersPath+"/id/"+id.String()+"/"+action, nil, req, &user); err != nil {
+		return nil, err
+	}
+	return &user, nil
+}
+
+// CreateUser creates a user and returns it as stored.
+func (c *Client) CreateUser(ctx context.Context, req CreateUserRequest) (*User, error) {
+	var user User
+	if _, err := c.do(ctx, http.MethodPost, usersPath, nil, req, &user); err != nil {
+		return nil, err
+	}
+	return &user, nil
+}
+
+// UpdateUser applies a partial update; nil fields are left unchanged.
+func (c *Client) UpdateUser(ctx context.Context, id uuid.UUID, req UpdateUserRequest) (*User, error) {
+	var user User
+	if _, err := c.do(ctx, http.MethodPatch, usersPath+"/id/"+id.String(), nil, req, &user); err != nil {
+		return nil, err
+	}
+	return &user, nil
+}
+
+// ReplaceUser overwrites every mutable field of a user (PUT).
+func (c *Client) ReplaceUser(ctx context.Context, id uuid.UUID, req ReplaceUserRequest) (*User, error) {
+	var user User
+	if _, err := c.do(ctx, http.MethodPut, usersPath+"/id/"+id.String(), nil, req, &user); err != nil {
+		return nil, err
+	}
+	return &user, nil
+}
+
+// MergePatchUser applies an RFC 7396 merge patch, e.g. map[string]any{"full_name": "Jane"},
+// to the user's JSON representation.
+func (c *Client) MergePatchUser(ctx context.Context, id uuid.UUID, patch any) (*User, error) {
+	var user User
+	if _, err := c.doAs(ctx, http.MethodPatch, usersPath+"/id/"+id.String(), nil, "application/merge-patch+json", patch, &user); err != nil {
+		return nil, err
+	}
+	return &user, nil
+}
+
+// PatchOperation is one RFC 6902 operation. Paths are JSON Pointers into the user's
+// JSON representation, e.g. "/email".
+type PatchOperation struct {
+	Op    string `json:"op"`
+	Path  string `json:"path"`
+	From  string `json:"from,omitempty"`
+	Value any    `json:"value"`
+}
+
+// JSONPatchUser applies RFC 6902 operations atomically. A failed "test" operation
+// returns an *APIError with status 409 and leaves the user unchanged.
+func (c *Client) JSONPatchUser(ctx context.Context, id uuid.UUID, ops []PatchOperation) (*User, error) {
+	var user User
+	if _, err := c.doAs(ctx, http.MethodPatch, usersPath+"/id/"+id.String(), nil, "application/json-patch+json", ops, &user); err != nil {
+		return nil, err
+	}
+	return &user, nil
+}
+
+// ImportOptions configure ImportUsers. The zero value skips existing users and writes.
+type ImportOptions struct {
+	Mode   ImportMode
+	DryRun bool
+}
+
+// ImportUsers uploads a CSV file with username, email and full_name columns and returns the
+// per-row report. Rows that fail are listed in the report with a reason; err is only set when
+// the file as a whole was rejected.
+func (c *Client) ImportUsers(ctx context.Context, csvFile io.Reader, opts ImportOptions) (*ImportReport, error) {
+	data, err := io.ReadAll(csvFile)
+	if err != nil {
+		return nil, err
+	}
+
+	var report ImportReport
+	if _, err := c.doAs(ctx, http.MethodPost, usersPath+"/import", importQuery(opts), ExportCSV, rawBody(data), &report); err != nil {
+		return nil, err
+	}
+	return &report, nil
+}
+
+// importQuery encodes ImportOptions as the import endpoint's query parameters.
+func importQuery(opts ImportOptions) url.Values {
+	query := url.Values{}
+	if opts.Mode != "" {
+		query.Set("mode", string(opts.Mode))
+	}
+	if opts.DryRun {
+		query.Set("dry_run", "true")
+	}
+	return query
+}
+
+// DeleteUser deletes a user. Deleting a user that does not exist is not an error.
+func (c *Client) DeleteUser(ctx context.Context, id uuid.UUID) error {
+	_, err := c.do(ctx, http.MethodDelete, usersPath+"/id/"+id.String(), nil, nil, nil)
+	return err
+}
+
+// nextPage extracts the rel="next" target of a Link header into page options.
+func nextPage(link string) (*PageOptions, bool) {
+	for _, part := range strings.Split(link, ",") {
+		segments := strings.Split(part, ";")
+		if len(segments) < 2 {
+			continue
+		}
+		isNext := false
+		for _, param := range segments[1:] {
+			if strings.TrimSpace(param) == `rel="next"` {
+				isNext = true
+			}
+		}
+		if !isNext {
+			continue
+		}
+
+		target := strings.Trim(strings.TrimSpace(segments[0]), "<>")
+		u, err := url.Parse(target)
+		if err != nil {
+			return nil, false
+		}
+		opts := &PageOptions{}
+		if limit, err := strconv.Atoi(u.Query().Get("limit")); err == nil {
+			opts.Limit = limit
+		}
+		if after, err := uuid.Parse(u.Query().Get("after")); err == nil {
+			opts.After = &after
+		}
+		if fields := u.Query().Get("fields"); fields != "" {
+			opts.Fields = strings.Split(fields, ",")
+		}
+		opts.Status = UserStatus(u.Query().Get("status"))
+		return opts, true
+	}
+	return nil, false
+}
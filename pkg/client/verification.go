@@ -0,0 +1,48 @@
+package client
+
+import (
+	"context"
+	"cruder/internal/model"
+	"net/http"
+
+	"github.com/google/uuid"
+)
+
+// VerifyEmail redeems the token from a verification email and returns the verified user.
+// Unknown, used and expired tokens fail with ErrVerificationTokenInvalid.
+func (c *Client) VerifyEmail(ctx context.Context, token string) (*User, error) {
+	var user User
+	req := model.VerifyEmailRequest{Token: token}
+	if _, err := c.do(ctx, http.MethodPost, usersPath+"/verify-email", nil, req, &user); err != nil {
+		return nil, err
+	}
+	return &user, nil
+}
+
+// ResendVerificationEmail mails the user a new verification token, invalidating the previous one.
+// It fails with ErrEmailAlreadyVerified once the address is verified.
+func (c *Client) ResendVerificationEmail(ctx context.Context, id uuid.UUID) error {
+	_, err := c.do(ctx, http.MethodPost, usersPath+"/id/"+id.String()+"/verification-email", nil, nil, nil)
+	return err
+}
+
+// ConfirmEmailChange redeems the token mailed to a requested new address and returns the user with it.
+// Unknown, used and expired tokens fail with ErrEmailChangeTokenInvalid.
+func (c *Client) ConfirmEmailChange(ctx context.Context, token string) (*User, error) {
+	return c.redeemEmailChange(ctx, "/confirm-email", token)
+}
+
+// RevertEmailChange redeems the "this wasn't me" token mailed to the previous address, cancelling
+// or undoing the change, and returns the user.
+func (c *Client) RevertEmailChange(ctx context.Context, token string) (*User, error) {
+	return c.redeemEmailChange(ctx, "/revert-email-change", token)
+}
+
+func (c *Client) redeemEmailChange(ctx context.Context, path, token string) (*User, error) {
+	var user User
+	req := model.EmailChangeTokenRequest{Token: token}
+	if _, err := c.do(ctx, http.MethodPost, usersPath+path, nil, req, &user); err != nil {
+		return nil, err
+	}
+	return &user, nil
+}
@@ -0,0 +1,10 @@
+// Package seeds embeds per-environment fixture files loaded by `cruder seed`.
+// Each environment has its own directory; production deliberately has none.
+package seeds
+
+import "embed"
+
+// FS holds the fixture directories, e.g. development/users.yaml.
+//
+//go:embed development staging
+var FS embed.FS